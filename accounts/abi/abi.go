@@ -19,8 +19,11 @@ package abi
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+
+	"github.com/genchain/go-genchain/crypto"
 )
 
 // The ABI holds information about a contract's context and available
@@ -144,3 +147,24 @@ func (abi *ABI) MethodById(sigdata []byte) (*Method, error) {
 	}
 	return nil, fmt.Errorf("no method with id: %#x", sigdata[:4])
 }
+
+// revertSelector is the selector of the Error(string) function, which solidity
+// uses to abi encode the revert reasons.
+var revertSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+
+// errNoRevertReason is returned if revert data doesn't hold an encoded reason.
+var errNoRevertReason = errors.New("abi: no revert reason")
+
+// UnpackRevert decodes the reason of a contract revert from the data returned by
+// the reverted call, which solidity encodes as a call to Error(string).
+func UnpackRevert(data []byte) (string, error) {
+	if len(data) < 4 || !bytes.Equal(data[:4], revertSelector) {
+		return "", errNoRevertReason
+	}
+	typ, _ := NewType("string")
+	var reason string
+	if err := (Arguments{{Type: typ}}).Unpack(&reason, data[4:]); err != nil {
+		return "", err
+	}
+	return reason, nil
+}
@@ -713,3 +713,111 @@ func TestABI_MethodById(t *testing.T) {
 	}
 
 }
+
+func TestTupleMethod(t *testing.T) {
+	const abiJSON = `[
+	{"name":"f","type":"function","constant":true,
+	 "inputs":[
+		{"name":"s","type":"tuple","components":[{"name":"a","type":"uint256"},{"name":"b","type":"uint256[]"}]},
+		{"name":"t","type":"tuple","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]}],
+	 "outputs":[
+		{"name":"s","type":"tuple","components":[{"name":"a","type":"uint256"},{"name":"b","type":"uint256[]"}]},
+		{"name":"t","type":"tuple","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]}]},
+	{"name":"e","type":"event","anonymous":false,
+	 "inputs":[{"name":"ts","type":"tuple[]","indexed":false,"components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]}]}]`
+
+	abi, err := JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig := abi.Methods["f"].Sig(); sig != "f((uint256,uint256[]),(uint256,uint256))" {
+		t.Fatalf("signature mismatch: %s", sig)
+	}
+	if sig := abi.Events["e"].Id(); sig != crypto.Keccak256Hash([]byte("e((uint256,uint256)[])")) {
+		t.Fatalf("event id mismatch: %x", sig)
+	}
+	type S struct {
+		A *big.Int
+		B []*big.Int
+	}
+	type T struct {
+		X *big.Int
+		Y *big.Int
+	}
+	s := S{big.NewInt(1), []*big.Int{big.NewInt(2), big.NewInt(3)}}
+	tt := T{big.NewInt(4), big.NewInt(5)}
+
+	packed, err := abi.Pack("f", s, tt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := common.Hex2Bytes("" +
+		"0000000000000000000000000000000000000000000000000000000000000060" + // offset of s
+		"0000000000000000000000000000000000000000000000000000000000000004" + // t.x
+		"0000000000000000000000000000000000000000000000000000000000000005" + // t.y
+		"0000000000000000000000000000000000000000000000000000000000000001" + // s.a
+		"0000000000000000000000000000000000000000000000000000000000000040" + // offset of s.b
+		"0000000000000000000000000000000000000000000000000000000000000002" + // len(s.b)
+		"0000000000000000000000000000000000000000000000000000000000000002" + // s.b[0]
+		"0000000000000000000000000000000000000000000000000000000000000003") // s.b[1]
+	if !bytes.Equal(packed[4:], want) {
+		t.Fatalf("pack mismatch:\ngot  %x\nwant %x", packed[4:], want)
+	}
+
+	var out struct {
+		S S
+		T T
+	}
+	if err := abi.Unpack(&out, "f", want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out.S, s) || !reflect.DeepEqual(out.T, tt) {
+		t.Fatalf("unpack mismatch: got %+v", out)
+	}
+
+	data := common.Hex2Bytes("" +
+		"0000000000000000000000000000000000000000000000000000000000000020" + // offset of ts
+		"0000000000000000000000000000000000000000000000000000000000000002" + // len(ts)
+		"0000000000000000000000000000000000000000000000000000000000000001" + // ts[0].x
+		"0000000000000000000000000000000000000000000000000000000000000002" + // ts[0].y
+		"0000000000000000000000000000000000000000000000000000000000000003" + // ts[1].x
+		"0000000000000000000000000000000000000000000000000000000000000004") // ts[1].y
+	var ev struct{ Ts []T }
+	if err := abi.Unpack(&ev, "e", data); err != nil {
+		t.Fatal(err)
+	}
+	wantTs := []T{{big.NewInt(1), big.NewInt(2)}, {big.NewInt(3), big.NewInt(4)}}
+	if !reflect.DeepEqual(ev.Ts, wantTs) {
+		t.Fatalf("event unpack mismatch: got %+v", ev.Ts)
+	}
+}
+
+func TestUnpackRevert(t *testing.T) {
+	tests := []struct {
+		input  string
+		reason string
+		fails  bool
+	}{
+		{"", "", true},
+		{"08c379a1", "", true},
+		{"08c379a0", "", true},
+		{"08c379a0" +
+			"0000000000000000000000000000000000000000000000000000000000000020" +
+			"000000000000000000000000000000000000000000000000000000000000000d" +
+			"72657665727420726561736f6e00000000000000000000000000000000000000", "revert reason", false},
+	}
+	for i, test := range tests {
+		reason, err := UnpackRevert(common.FromHex(test.input))
+		if test.fails {
+			if err == nil {
+				t.Errorf("test %d: expected error, got reason %q", i, reason)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: failed to unpack revert reason: %v", i, err)
+		} else if reason != test.reason {
+			t.Errorf("test %d: reason mismatch: have %q, want %q", i, reason, test.reason)
+		}
+	}
+}
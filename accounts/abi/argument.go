@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"unicode"
 )
 
 // Argument holds the name of the argument and the corresponding type.
@@ -33,19 +34,25 @@ type Argument struct {
 
 type Arguments []Argument
 
+// ArgumentMarshaling is the JSON representation of an argument. The components
+// describe the fields of tuple types.
+type ArgumentMarshaling struct {
+	Name         string
+	Type         string
+	InternalType string
+	Components   []ArgumentMarshaling
+	Indexed      bool
+}
+
 // UnmarshalJSON implements json.Unmarshaler interface
 func (argument *Argument) UnmarshalJSON(data []byte) error {
-	var extarg struct {
-		Name    string
-		Type    string
-		Indexed bool
-	}
+	var extarg ArgumentMarshaling
 	err := json.Unmarshal(data, &extarg)
 	if err != nil {
 		return fmt.Errorf("argument json err: %v", err)
 	}
 
-	argument.Type, err = NewType(extarg.Type)
+	argument.Type, err = newType(extarg.Type, extarg.InternalType, extarg.Components)
 	if err != nil {
 		return err
 	}
@@ -162,8 +169,10 @@ func (arguments Arguments) unpackAtomic(v interface{}, marshalledValues []interf
 	kind := elem.Kind()
 	reflectValue := reflect.ValueOf(marshalledValues[0])
 
+	// Structs are unpacked into field by field, unless the value itself is
+	// a tuple.
 	var abi2struct map[string]string
-	if kind == reflect.Struct {
+	if kind == reflect.Struct && arguments.NonIndexed()[0].Type.T != TupleTy {
 		var err error
 		if abi2struct, err = mapAbiToStructFields(arguments, elem); err != nil {
 			return err
@@ -179,21 +188,6 @@ func (arguments Arguments) unpackAtomic(v interface{}, marshalledValues []interf
 
 }
 
-// Computes the full size of an array;
-// i.e. counting nested arrays, which count towards size for unpacking.
-func getArraySize(arr *Type) int {
-	size := arr.Size
-	// Arrays can be nested, with each element being the same size
-	arr = arr.Elem
-	for arr.T == ArrayTy {
-		// Keep multiplying by elem.Size while the elem is an array.
-		size *= arr.Size
-		arr = arr.Elem
-	}
-	// Now we have the full array size, including its children.
-	return size
-}
-
 // UnpackValues can be used to unpack ABI-encoded hexdata according to the ABI-specification,
 // without supplying a struct to unpack into. Instead, this method returns a list containing the
 // values. An atomic argument will be a list with one element.
@@ -202,18 +196,20 @@ func (arguments Arguments) UnpackValues(data []byte) ([]interface{}, error) {
 	virtualArgs := 0
 	for index, arg := range arguments.NonIndexed() {
 		marshalledValue, err := toGoType((index+virtualArgs)*32, arg.Type, data)
-		if arg.Type.T == ArrayTy {
+		if !isDynamicType(arg.Type) {
 			// If we have a static array, like [3]uint256, these are coded as
 			// just like uint256,uint256,uint256.
 			// This means that we need to add two 'virtual' arguments when
 			// we count the index from now on.
 			//
-			// Array values nested multiple levels deep are also encoded inline:
+			// Array values nested multiple levels deep, as well as static
+			// tuples, are also encoded inline:
 			// [2][3]uint256: uint256,uint256,uint256,uint256,uint256,uint256
+			// (uint256,bool): uint256,bool
 			//
-			// Calculate the full array size to get the correct offset for the next argument.
+			// Calculate the full size to get the correct offset for the next argument.
 			// Decrement it by 1, as the normal index increment is still applied.
-			virtualArgs += getArraySize(&arg.Type) - 1
+			virtualArgs += getTypeSize(arg.Type)/32 - 1
 		}
 		if err != nil {
 			return nil, err
@@ -243,11 +239,7 @@ func (arguments Arguments) Pack(args ...interface{}) ([]byte, error) {
 	// input offset is the bytes offset for packed output
 	inputOffset := 0
 	for _, abiArg := range abiArgs {
-		if abiArg.Type.T == ArrayTy {
-			inputOffset += 32 * abiArg.Type.Size
-		} else {
-			inputOffset += 32
-		}
+		inputOffset += getTypeSize(abiArg.Type)
 	}
 	var ret []byte
 	for i, a := range args {
@@ -257,8 +249,8 @@ func (arguments Arguments) Pack(args ...interface{}) ([]byte, error) {
 		if err != nil {
 			return nil, err
 		}
-		// check for a slice type (string, bytes, slice)
-		if input.Type.requiresLengthPrefix() {
+		// check for a dynamic type (string, bytes, slice, dynamic arrays and tuples)
+		if isDynamicType(input.Type) {
 			// calculate the offset
 			offset := inputOffset + len(variableInput)
 			// set the offset
@@ -288,3 +280,25 @@ func capitalise(input string) string {
 	}
 	return strings.ToUpper(input[:1]) + input[1:]
 }
+
+// ToCamelCase converts an under-score string to a camel-case string starting
+// with an upper case character, dropping any prefixing underscores. It names the
+// Go struct fields of tuple components.
+func ToCamelCase(input string) string {
+	var (
+		result  []rune
+		toupper = true
+	)
+	for _, r := range strings.TrimLeft(input, "_") {
+		switch {
+		case r == '_':
+			toupper = true
+		case toupper:
+			result = append(result, unicode.ToUpper(r))
+			toupper = false
+		default:
+			result = append(result, r)
+		}
+	}
+	return string(result)
+}
@@ -22,7 +22,9 @@ import (
 	"math/big"
 
 	"github.com/genchain/go-genchain"
+	"github.com/genchain/go-genchain/accounts/abi"
 	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/hexutil"
 	"github.com/genchain/go-genchain/core/types"
 )
 
@@ -41,6 +43,51 @@ var (
 	ErrNoCodeAfterDeploy = errors.New("no contract code after deployment")
 )
 
+// RevertError is returned by call operations if the contract reverted, with
+// the reason given by the contract, if any.
+type RevertError struct {
+	Reason string // Revert reason, empty if the contract didn't give one
+	Data   []byte // Raw data returned by the reverted call
+}
+
+func (e *RevertError) Error() string {
+	if e.Reason == "" {
+		return "execution reverted"
+	}
+	return "execution reverted: " + e.Reason
+}
+
+// revertErrorCode is the error code of reverted calls, which carry the hex
+// encoded revert data as error data.
+const revertErrorCode = 3
+
+// dataError is implemented by the backend errors carrying structured data, like
+// the errors of reverted calls returned over RPC and by the simulated backend.
+type dataError interface {
+	error
+	ErrorCode() int
+	ErrorData() interface{}
+}
+
+// unpackRevert converts the backend errors of reverted calls into RevertErrors,
+// returning any other error as is.
+func unpackRevert(err error) error {
+	derr, ok := err.(dataError)
+	if !ok || derr.ErrorCode() != revertErrorCode {
+		return err
+	}
+	hex, ok := derr.ErrorData().(string)
+	if !ok {
+		return err
+	}
+	data, decErr := hexutil.Decode(hex)
+	if decErr != nil {
+		return err
+	}
+	reason, _ := abi.UnpackRevert(data)
+	return &RevertError{Reason: reason, Data: data}
+}
+
 // ContractCaller defines the methods needed to allow operating with contract on a read
 // only basis.
 type ContractCaller interface {
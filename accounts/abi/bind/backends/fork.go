@@ -0,0 +1,254 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/genchain/go-genchain"
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/core/state"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/rlp"
+)
+
+var (
+	// forkTombstone marks the trie entries deleted locally, so that they aren't
+	// fetched from the remote node again. Neither RLP encoded accounts nor storage
+	// values can be a single zero byte.
+	forkTombstone = []byte{0x00}
+
+	emptyCodeHash = crypto.Keccak256(nil)
+)
+
+// forkSource fetches the state missing from a forked simulated chain from the
+// remote node, caching it. The remote state is pinned to a block, so the cache
+// never goes stale.
+type forkSource struct {
+	remote genchain.ChainStateReader
+	number *big.Int // Block to fork the state at
+
+	accounts map[common.Address][]byte                 // RLP encoded remote accounts, nil if missing
+	storage  map[common.Address]map[common.Hash][]byte // RLP encoded remote storage values
+	code     map[common.Hash][]byte                    // Remote contract code by hash
+	preimage map[common.Hash]common.Address            // Addresses of the remote accounts by hash
+	err      error                                     // First fetch failure since the last check
+	lock     sync.Mutex
+}
+
+func newForkSource(remote genchain.ChainStateReader, number *big.Int) *forkSource {
+	return &forkSource{
+		remote:   remote,
+		number:   number,
+		accounts: make(map[common.Address][]byte),
+		storage:  make(map[common.Address]map[common.Hash][]byte),
+		code:     make(map[common.Hash][]byte),
+		preimage: make(map[common.Hash]common.Address),
+	}
+}
+
+// fail records a remote fetch failure. The state database swallows the errors
+// of its trie, so the simulated backend checks for them after every operation.
+// The caller must hold the lock.
+func (src *forkSource) fail(err error) error {
+	if src.err == nil {
+		src.err = err
+	}
+	return err
+}
+
+// takeError returns the first remote fetch failure since the last call, if any.
+// Failed entries aren't cached, so they are fetched again on the next access.
+func (src *forkSource) takeError() error {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+
+	err := src.err
+	src.err = nil
+	return err
+}
+
+// account retrieves the RLP encoded remote account, or nil if it doesn't exist.
+// The storage of the account is fetched separately, so its root is left empty.
+func (src *forkSource) account(address common.Address) ([]byte, error) {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+
+	if enc, ok := src.accounts[address]; ok {
+		return enc, nil
+	}
+	ctx := context.Background()
+	balance, err := src.remote.BalanceAt(ctx, address, src.number)
+	if err != nil {
+		return nil, src.fail(fmt.Errorf("failed to fetch balance of %x: %v", address, err))
+	}
+	nonce, err := src.remote.NonceAt(ctx, address, src.number)
+	if err != nil {
+		return nil, src.fail(fmt.Errorf("failed to fetch nonce of %x: %v", address, err))
+	}
+	code, err := src.remote.CodeAt(ctx, address, src.number)
+	if err != nil {
+		return nil, src.fail(fmt.Errorf("failed to fetch code of %x: %v", address, err))
+	}
+	var enc []byte
+	if balance.Sign() != 0 || nonce != 0 || len(code) > 0 {
+		account := state.Account{Nonce: nonce, Balance: balance, Root: types.EmptyRootHash, CodeHash: emptyCodeHash}
+		if len(code) > 0 {
+			account.CodeHash = crypto.Keccak256(code)
+			src.code[common.BytesToHash(account.CodeHash)] = code
+		}
+		if enc, err = rlp.EncodeToBytes(&account); err != nil {
+			return nil, err
+		}
+		src.preimage[crypto.Keccak256Hash(address[:])] = address
+	}
+	src.accounts[address] = enc
+	return enc, nil
+}
+
+// storageOf returns the fetcher of the remote storage of the account with the
+// given address hash, or nil if the account doesn't exist remotely.
+func (src *forkSource) storageOf(addrHash common.Hash) func(key []byte) ([]byte, error) {
+	src.lock.Lock()
+	address, ok := src.preimage[addrHash]
+	src.lock.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return func(key []byte) ([]byte, error) {
+		return src.slot(address, common.BytesToHash(key))
+	}
+}
+
+// slot retrieves an RLP encoded remote storage value, or nil if it is zero.
+func (src *forkSource) slot(address common.Address, key common.Hash) ([]byte, error) {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+
+	if enc, ok := src.storage[address][key]; ok {
+		return enc, nil
+	}
+	value, err := src.remote.StorageAt(context.Background(), address, key, src.number)
+	if err != nil {
+		return nil, src.fail(fmt.Errorf("failed to fetch storage %x of %x: %v", key, address, err))
+	}
+	var enc []byte
+	if value = bytes.TrimLeft(value, "\x00"); len(value) > 0 {
+		if enc, err = rlp.EncodeToBytes(value); err != nil {
+			return nil, err
+		}
+	}
+	if src.storage[address] == nil {
+		src.storage[address] = make(map[common.Hash][]byte)
+	}
+	src.storage[address][key] = enc
+	return enc, nil
+}
+
+// contractCode retrieves remote contract code by hash.
+func (src *forkSource) contractCode(codeHash common.Hash) ([]byte, bool) {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+
+	code, ok := src.code[codeHash]
+	return code, ok
+}
+
+// forkDatabase is a state database falling back to the remote node for the
+// accounts, storage and code missing locally.
+type forkDatabase struct {
+	state.Database
+	src *forkSource
+}
+
+// OpenTrie opens the main account trie.
+func (db *forkDatabase) OpenTrie(root common.Hash) (state.Trie, error) {
+	tr, err := db.Database.OpenTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	fetch := func(key []byte) ([]byte, error) {
+		return db.src.account(common.BytesToAddress(key))
+	}
+	return &forkTrie{tr, fetch}, nil
+}
+
+// OpenStorageTrie opens the storage trie of an account.
+func (db *forkDatabase) OpenStorageTrie(addrHash, root common.Hash) (state.Trie, error) {
+	tr, err := db.Database.OpenStorageTrie(addrHash, root)
+	if err != nil {
+		return nil, err
+	}
+	return &forkTrie{tr, db.src.storageOf(addrHash)}, nil
+}
+
+// CopyTrie returns an independent copy of the given trie.
+func (db *forkDatabase) CopyTrie(t state.Trie) state.Trie {
+	if t, ok := t.(*forkTrie); ok {
+		return &forkTrie{db.Database.CopyTrie(t.Trie), t.fetch}
+	}
+	return db.Database.CopyTrie(t)
+}
+
+// ContractCode retrieves a particular contract's code.
+func (db *forkDatabase) ContractCode(addrHash, codeHash common.Hash) ([]byte, error) {
+	if code, ok := db.src.contractCode(codeHash); ok {
+		return code, nil
+	}
+	return db.Database.ContractCode(addrHash, codeHash)
+}
+
+// ContractCodeSize retrieves a particular contracts code's size.
+func (db *forkDatabase) ContractCodeSize(addrHash, codeHash common.Hash) (int, error) {
+	if code, ok := db.src.contractCode(codeHash); ok {
+		return len(code), nil
+	}
+	return db.Database.ContractCodeSize(addrHash, codeHash)
+}
+
+// forkTrie is a trie fetching the entries it doesn't have from the remote node.
+// Deletions leave tombstones, as the entries would be fetched again otherwise.
+type forkTrie struct {
+	state.Trie
+	fetch func(key []byte) ([]byte, error) // Remote entry fetcher, nil if none
+}
+
+func (t *forkTrie) TryGet(key []byte) ([]byte, error) {
+	enc, err := t.Trie.TryGet(key)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.Equal(enc, forkTombstone):
+		return nil, nil
+	case enc != nil:
+		return enc, nil
+	case t.fetch != nil:
+		return t.fetch(key)
+	}
+	return nil, nil
+}
+
+func (t *forkTrie) TryDelete(key []byte) error {
+	return t.Trie.TryUpdate(key, forkTombstone)
+}
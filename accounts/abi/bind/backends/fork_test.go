@@ -0,0 +1,294 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/genchain/go-genchain"
+	"github.com/genchain/go-genchain/accounts/abi/bind/backends"
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/hexutil"
+	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/ethclient"
+	"github.com/genchain/go-genchain/params"
+	"github.com/genchain/go-genchain/rpc"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	storageAddr = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	timeAddr    = common.HexToAddress("0x1000000000000000000000000000000000000002")
+	localAddr   = common.HexToAddress("0x1000000000000000000000000000000000000003")
+
+	errRemoteDown = errors.New("remote node down")
+)
+
+// RemoteAPI serves the state of a node at a single block, like a live node does.
+type RemoteAPI struct {
+	balances map[common.Address]*big.Int
+	nonces   map[common.Address]uint64
+	code     map[common.Address][]byte
+	storage  map[common.Address]map[common.Hash]common.Hash
+
+	calls   map[string]int // Number of calls of each method
+	blocks  []string       // Blocks the state was requested at
+	failing bool           // Whether the state requests fail
+	lock    sync.Mutex
+}
+
+func (api *RemoteAPI) record(method, block string) error {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	if api.failing {
+		return errRemoteDown
+	}
+	api.calls[method]++
+	api.blocks = append(api.blocks, block)
+	return nil
+}
+
+func (api *RemoteAPI) setFailing(failing bool) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	api.failing = failing
+}
+
+func (api *RemoteAPI) GetBlockByNumber(number string, fullTx bool) (*types.Header, error) {
+	return &types.Header{
+		Number:     big.NewInt(100),
+		Difficulty: big.NewInt(1),
+		Time:       big.NewInt(1000000),
+		GasLimit:   8000000,
+	}, nil
+}
+
+func (api *RemoteAPI) GetBalance(address common.Address, block string) (*hexutil.Big, error) {
+	if err := api.record("balance", block); err != nil {
+		return nil, err
+	}
+	if balance, ok := api.balances[address]; ok {
+		return (*hexutil.Big)(balance), nil
+	}
+	return new(hexutil.Big), nil
+}
+
+func (api *RemoteAPI) GetTransactionCount(address common.Address, block string) (hexutil.Uint64, error) {
+	if err := api.record("nonce", block); err != nil {
+		return 0, err
+	}
+	return hexutil.Uint64(api.nonces[address]), nil
+}
+
+func (api *RemoteAPI) GetCode(address common.Address, block string) (hexutil.Bytes, error) {
+	if err := api.record("code", block); err != nil {
+		return nil, err
+	}
+	return api.code[address], nil
+}
+
+func (api *RemoteAPI) GetStorageAt(address common.Address, key common.Hash, block string) (hexutil.Bytes, error) {
+	if err := api.record("storage", block); err != nil {
+		return nil, err
+	}
+	value := api.storage[address][key]
+	return value[:], nil
+}
+
+func newForkedBackend(t *testing.T) (*backends.SimulatedBackend, *RemoteAPI, func()) {
+	api := &RemoteAPI{
+		balances: map[common.Address]*big.Int{
+			testAddr:  big.NewInt(1000000000000000000),
+			localAddr: big.NewInt(100),
+		},
+		nonces: map[common.Address]uint64{testAddr: 5},
+		code: map[common.Address][]byte{
+			storageAddr: common.FromHex("0x60005460005260206000f3"), // Returns storage slot 0
+			timeAddr:    common.FromHex("0x4260005260206000f3"),     // Returns the block timestamp
+		},
+		storage: map[common.Address]map[common.Hash]common.Hash{
+			storageAddr: {common.Hash{}: common.BigToHash(big.NewInt(42))},
+		},
+		calls: make(map[string]int),
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("gen", api); err != nil {
+		t.Fatal(err)
+	}
+	client := ethclient.NewClient(rpc.DialInProc(server))
+
+	alloc := core.GenesisAlloc{localAddr: {Balance: big.NewInt(7)}}
+	sim, err := backends.NewForkedSimulatedBackend(client, nil, alloc)
+	if err != nil {
+		t.Fatalf("failed to fork: %v", err)
+	}
+	return sim, api, func() {
+		client.Close()
+		server.Stop()
+	}
+}
+
+// Tests that the state of a forked simulated backend is fetched from the remote
+// node at the fork block, and that local accounts replace the remote ones.
+func TestForkedState(t *testing.T) {
+	sim, api, closer := newForkedBackend(t)
+	defer closer()
+
+	ctx := context.Background()
+	if balance, err := sim.BalanceAt(ctx, testAddr, nil); err != nil || balance.Cmp(api.balances[testAddr]) != 0 {
+		t.Errorf("remote balance mismatch: have %v (%v), want %v", balance, err, api.balances[testAddr])
+	}
+	if nonce, err := sim.NonceAt(ctx, testAddr, nil); err != nil || nonce != 5 {
+		t.Errorf("remote nonce mismatch: have %d (%v), want 5", nonce, err)
+	}
+	if balance, err := sim.BalanceAt(ctx, localAddr, nil); err != nil || balance.Int64() != 7 {
+		t.Errorf("local balance mismatch: have %v (%v), want 7", balance, err)
+	}
+	if code, err := sim.CodeAt(ctx, storageAddr, nil); err != nil || common.Bytes2Hex(code) != common.Bytes2Hex(api.code[storageAddr]) {
+		t.Errorf("remote code mismatch: have %x (%v), want %x", code, err, api.code[storageAddr])
+	}
+	if value, err := sim.StorageAt(ctx, storageAddr, common.Hash{}, nil); err != nil || common.BytesToHash(value).Big().Int64() != 42 {
+		t.Errorf("remote storage mismatch: have %x (%v), want 42", value, err)
+	}
+	out, err := sim.CallContract(ctx, genchain.CallMsg{To: &storageAddr}, nil)
+	if err != nil || common.BytesToHash(out).Big().Int64() != 42 {
+		t.Errorf("remote contract call mismatch: have %x (%v), want 42", out, err)
+	}
+	// Remote state is cached and pinned to the fork block
+	fetches := api.calls["balance"]
+	sim.BalanceAt(ctx, testAddr, nil)
+	if api.calls["balance"] != fetches {
+		t.Errorf("cached balance fetched again: have %d fetches, want %d", api.calls["balance"], fetches)
+	}
+	for _, block := range api.blocks {
+		if block != "0x64" {
+			t.Errorf("remote state fetched at block %s, want 0x64", block)
+		}
+	}
+}
+
+// Tests that transactions can be committed, rolled back and time shifted on top
+// of the forked state.
+func TestForkedTransactions(t *testing.T) {
+	sim, _, closer := newForkedBackend(t)
+	defer closer()
+
+	var (
+		ctx       = context.Background()
+		recipient = common.HexToAddress("0x2000000000000000000000000000000000000001")
+		signer    = types.HomesteadSigner{}
+	)
+	send := func(nonce uint64, value int64) {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, recipient, big.NewInt(value), 21000, big.NewInt(1), nil), signer, testKey)
+		if err := sim.SendTransaction(ctx, tx); err != nil {
+			t.Fatalf("failed to send transaction: %v", err)
+		}
+	}
+	// Rolled back transactions leave the remote state untouched
+	send(5, 1000)
+	sim.Rollback()
+	if nonce, _ := sim.PendingNonceAt(ctx, testAddr); nonce != 5 {
+		t.Errorf("nonce after rollback mismatch: have %d, want 5", nonce)
+	}
+	// Committed transactions apply on top of it
+	send(5, 1000)
+	sim.Commit()
+	if balance, _ := sim.BalanceAt(ctx, recipient, nil); balance.Int64() != 1000 {
+		t.Errorf("recipient balance mismatch: have %v, want 1000", balance)
+	}
+	if nonce, _ := sim.NonceAt(ctx, testAddr, nil); nonce != 6 {
+		t.Errorf("sender nonce mismatch: have %d, want 6", nonce)
+	}
+	want := new(big.Int).Sub(big.NewInt(1000000000000000000), new(big.Int).SetUint64(1000+params.TxGas))
+	if balance, _ := sim.BalanceAt(ctx, testAddr, nil); balance.Cmp(want) != 0 {
+		t.Errorf("sender balance mismatch: have %v, want %v", balance, want)
+	}
+	// Time shifts keep the pending transactions
+	send(6, 1000)
+	if err := sim.AdjustTime(time.Hour); err != nil {
+		t.Fatalf("failed to adjust time: %v", err)
+	}
+	sim.Commit()
+	if balance, _ := sim.BalanceAt(ctx, recipient, nil); balance.Int64() != 2000 {
+		t.Errorf("recipient balance after time shift mismatch: have %v, want 2000", balance)
+	}
+	out, err := sim.CallContract(ctx, genchain.CallMsg{To: &timeAddr}, nil)
+	if err != nil {
+		t.Fatalf("failed to call remote contract: %v", err)
+	}
+	if now := common.BytesToHash(out).Big().Int64(); now < 1000000+3600 {
+		t.Errorf("block time not shifted: have %d, want at least %d", now, 1000000+3600)
+	}
+}
+
+// Tests that failures of the remote node to serve the forked state are returned
+// instead of reading as empty accounts, and that they aren't cached.
+func TestForkedRemoteFailure(t *testing.T) {
+	sim, api, closer := newForkedBackend(t)
+	defer closer()
+
+	var (
+		ctx    = context.Background()
+		signer = types.HomesteadSigner{}
+	)
+	api.setFailing(true)
+
+	if balance, err := sim.BalanceAt(ctx, testAddr, nil); err == nil {
+		t.Errorf("balance of unreachable account returned: %v", balance)
+	}
+	if nonce, err := sim.PendingNonceAt(ctx, testAddr); err == nil {
+		t.Errorf("pending nonce of unreachable account returned: %d", nonce)
+	}
+	if value, err := sim.StorageAt(ctx, storageAddr, common.Hash{}, nil); err == nil {
+		t.Errorf("unreachable storage returned: %x", value)
+	}
+	if out, err := sim.CallContract(ctx, genchain.CallMsg{To: &storageAddr}, nil); err == nil {
+		t.Errorf("call of unreachable contract returned: %x", out)
+	}
+	tx, _ := types.SignTx(types.NewTransaction(5, storageAddr, big.NewInt(1000), 50000, big.NewInt(1), nil), signer, testKey)
+	if err := sim.SendTransaction(ctx, tx); err == nil {
+		t.Errorf("transaction from unreachable account accepted")
+	}
+	// Once the remote node recovers, the state is fetched again and can be
+	// built upon without replacing the remote accounts
+	api.setFailing(false)
+
+	if err := sim.SendTransaction(ctx, tx); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	sim.Commit()
+
+	if nonce, err := sim.NonceAt(ctx, testAddr, nil); err != nil || nonce != 6 {
+		t.Errorf("sender nonce mismatch: have %d (%v), want 6", nonce, err)
+	}
+	if value, err := sim.StorageAt(ctx, storageAddr, common.Hash{}, nil); err != nil || common.BytesToHash(value).Big().Int64() != 42 {
+		t.Errorf("remote storage mismatch: have %x (%v), want 42", value, err)
+	}
+	if balance, err := sim.BalanceAt(ctx, storageAddr, nil); err != nil || balance.Int64() != 1000 {
+		t.Errorf("recipient balance mismatch: have %v (%v), want 1000", balance, err)
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/genchain/go-genchain"
 	"github.com/genchain/go-genchain/accounts/abi/bind"
 	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/hexutil"
 	"github.com/genchain/go-genchain/common/math"
 	"github.com/genchain/go-genchain/consensus/ethash"
 	"github.com/genchain/go-genchain/core"
@@ -48,6 +49,16 @@ var _ bind.ContractBackend = (*SimulatedBackend)(nil)
 var errBlockNumberUnsupported = errors.New("SimulatedBackend cannot access blocks other than the latest block")
 var errGasEstimationFailed = errors.New("gas required exceeds allowance or always failing transaction")
 
+// revertError is returned by calls that reverted, carrying the revert data of
+// the contract like the RPC API does.
+type revertError struct {
+	data []byte
+}
+
+func (e *revertError) Error() string          { return "execution reverted" }
+func (e *revertError) ErrorCode() int         { return 3 }
+func (e *revertError) ErrorData() interface{} { return hexutil.Encode(e.data) }
+
 // SimulatedBackend implements bind.ContractBackend, simulating a blockchain in
 // the background. Its main purpose is to allow easily testing contract bindings.
 type SimulatedBackend struct {
@@ -61,24 +72,86 @@ type SimulatedBackend struct {
 	events *filters.EventSystem // Event system for filtering log events live
 
 	config *params.ChainConfig
+	fork   *forkSource // Remote node the state is forked from, nil if none
 }
 
 // NewSimulatedBackend creates a new binding backend using a simulated blockchain
 // for testing purposes.
 func NewSimulatedBackend(alloc core.GenesisAlloc) *SimulatedBackend {
-	database := ethdb.NewMemDatabase()
 	genesis := core.Genesis{Config: params.AllEthashProtocolChanges, Alloc: alloc}
+	backend, _ := newSimulatedBackend(&genesis, nil) // Only forked state can fail to load
+	return backend
+}
+
+// ForkSource is the remote node a simulated blockchain forks its state from.
+// It is implemented by ethclient.Client.
+type ForkSource interface {
+	genchain.ChainStateReader
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// NewForkedSimulatedBackend creates a new binding backend using a simulated
+// blockchain whose state is forked from a remote node at the given block, or at
+// its latest one if nil. The accounts, contract code and storage missing from
+// the simulated state are fetched from the remote node when first accessed, and
+// cached. The accounts in alloc replace their remote counterparts. Failures to
+// fetch the remote state are returned by the operation needing it; Commit and
+// Rollback panic on them.
+func NewForkedSimulatedBackend(remote ForkSource, number *big.Int, alloc core.GenesisAlloc) (*SimulatedBackend, error) {
+	header, err := remote.HeaderByNumber(context.Background(), number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve fork block: %v", err)
+	}
+	genesis := core.Genesis{
+		Config:    params.AllEthashProtocolChanges,
+		Timestamp: header.Time.Uint64(),
+		GasLimit:  header.GasLimit,
+		Alloc:     alloc,
+	}
+	return newSimulatedBackend(&genesis, newForkSource(remote, header.Number))
+}
+
+func newSimulatedBackend(genesis *core.Genesis, fork *forkSource) (*SimulatedBackend, error) {
+	database := ethdb.NewMemDatabase()
 	genesis.MustCommit(database)
-	blockchain, _ := core.NewBlockChain(database, nil, genesis.Config, ethash.NewFaker(), vm.Config{})
 
 	backend := &SimulatedBackend{
-		database:   database,
-		blockchain: blockchain,
-		config:     genesis.Config,
-		events:     filters.NewEventSystem(new(event.TypeMux), &filterBackend{database, blockchain}, false),
+		database: database,
+		config:   genesis.Config,
+		fork:     fork,
 	}
-	backend.rollback()
-	return backend
+	backend.blockchain, _ = core.NewBlockChainWithState(database, backend.stateDatabase(), nil, genesis.Config, ethash.NewFaker(), vm.Config{})
+	backend.events = filters.NewEventSystem(new(event.TypeMux), &filterBackend{database, backend.blockchain}, false)
+	if err := backend.rollback(); err != nil {
+		return nil, err
+	}
+	return backend, nil
+}
+
+// stateDatabase creates a state database over the simulated chain, falling back
+// to the remote node if the state is forked.
+func (b *SimulatedBackend) stateDatabase() state.Database {
+	db := state.NewDatabase(b.database)
+	if b.fork != nil {
+		return &forkDatabase{db, b.fork}
+	}
+	return db
+}
+
+// forkError returns the first failure of the remote node to serve the state of
+// a forked chain since the last check. The state database swallows them, so
+// every operation on the state must check it before using the results.
+func (b *SimulatedBackend) forkError() error {
+	if b.fork == nil {
+		return nil
+	}
+	return b.fork.takeError()
+}
+
+// generate creates the next block on top of the current head of the chain.
+func (b *SimulatedBackend) generate(gen func(int, *core.BlockGen)) *types.Block {
+	blocks, _ := core.GenerateChainWithState(b.config, b.blockchain.CurrentBlock(), ethash.NewFaker(), b.database, b.stateDatabase(), 1, gen)
+	return blocks[0]
 }
 
 // Commit imports all the pending transactions as a single block and starts a
@@ -90,7 +163,12 @@ func (b *SimulatedBackend) Commit() {
 	if _, err := b.blockchain.InsertChain([]*types.Block{b.pendingBlock}); err != nil {
 		panic(err) // This cannot happen unless the simulator is wrong, fail in that case
 	}
-	b.rollback()
+	if err := b.forkError(); err != nil {
+		panic(err)
+	}
+	if err := b.rollback(); err != nil {
+		panic(err)
+	}
 }
 
 // Rollback aborts all pending transactions, reverting to the last committed state.
@@ -98,15 +176,21 @@ func (b *SimulatedBackend) Rollback() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	b.rollback()
+	if err := b.rollback(); err != nil {
+		panic(err)
+	}
 }
 
-func (b *SimulatedBackend) rollback() {
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), ethash.NewFaker(), b.database, 1, func(int, *core.BlockGen) {})
+func (b *SimulatedBackend) rollback() error {
+	block := b.generate(func(int, *core.BlockGen) {})
+	if err := b.forkError(); err != nil {
+		return err
+	}
 	statedb, _ := b.blockchain.State()
 
-	b.pendingBlock = blocks[0]
+	b.pendingBlock = block
 	b.pendingState, _ = state.New(b.pendingBlock.Root(), statedb.Database())
+	return nil
 }
 
 // CodeAt returns the code associated with a certain account in the blockchain.
@@ -118,7 +202,11 @@ func (b *SimulatedBackend) CodeAt(ctx context.Context, contract common.Address,
 		return nil, errBlockNumberUnsupported
 	}
 	statedb, _ := b.blockchain.State()
-	return statedb.GetCode(contract), nil
+	code := statedb.GetCode(contract)
+	if err := b.forkError(); err != nil {
+		return nil, err
+	}
+	return code, nil
 }
 
 // BalanceAt returns the shao balance of a certain account in the blockchain.
@@ -130,7 +218,11 @@ func (b *SimulatedBackend) BalanceAt(ctx context.Context, contract common.Addres
 		return nil, errBlockNumberUnsupported
 	}
 	statedb, _ := b.blockchain.State()
-	return statedb.GetBalance(contract), nil
+	balance := statedb.GetBalance(contract)
+	if err := b.forkError(); err != nil {
+		return nil, err
+	}
+	return balance, nil
 }
 
 // NonceAt returns the nonce of a certain account in the blockchain.
@@ -142,7 +234,11 @@ func (b *SimulatedBackend) NonceAt(ctx context.Context, contract common.Address,
 		return 0, errBlockNumberUnsupported
 	}
 	statedb, _ := b.blockchain.State()
-	return statedb.GetNonce(contract), nil
+	nonce := statedb.GetNonce(contract)
+	if err := b.forkError(); err != nil {
+		return 0, err
+	}
+	return nonce, nil
 }
 
 // StorageAt returns the value of key in the storage of an account in the blockchain.
@@ -155,6 +251,9 @@ func (b *SimulatedBackend) StorageAt(ctx context.Context, contract common.Addres
 	}
 	statedb, _ := b.blockchain.State()
 	val := statedb.GetState(contract, key)
+	if err := b.forkError(); err != nil {
+		return nil, err
+	}
 	return val[:], nil
 }
 
@@ -169,7 +268,11 @@ func (b *SimulatedBackend) PendingCodeAt(ctx context.Context, contract common.Ad
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	return b.pendingState.GetCode(contract), nil
+	code := b.pendingState.GetCode(contract)
+	if err := b.forkError(); err != nil {
+		return nil, err
+	}
+	return code, nil
 }
 
 // CallContract executes a contract call.
@@ -184,8 +287,14 @@ func (b *SimulatedBackend) CallContract(ctx context.Context, call genchain.CallM
 	if err != nil {
 		return nil, err
 	}
-	rval, _, _, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), state)
-	return rval, err
+	rval, _, failure, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), state)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.forkError(); err != nil {
+		return nil, err
+	}
+	return callResult(rval, failure)
 }
 
 // PendingCallContract executes a contract call on the pending state.
@@ -194,8 +303,27 @@ func (b *SimulatedBackend) PendingCallContract(ctx context.Context, call genchai
 	defer b.mu.Unlock()
 	defer b.pendingState.RevertToSnapshot(b.pendingState.Snapshot())
 
-	rval, _, _, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
-	return rval, err
+	rval, _, failure, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.forkError(); err != nil {
+		return nil, err
+	}
+	return callResult(rval, failure)
+}
+
+// callResult returns the result of an executed call like the RPC API does: the
+// revert data in the error if the call reverted, or the error of the EVM if the
+// call failed otherwise.
+func callResult(rval []byte, failure error) ([]byte, error) {
+	switch {
+	case failure == vm.ErrExecutionReverted:
+		return nil, &revertError{data: rval}
+	case failure != nil:
+		return nil, failure
+	}
+	return rval, nil
 }
 
 // PendingNonceAt implements PendingStateReader.PendingNonceAt, retrieving
@@ -204,7 +332,12 @@ func (b *SimulatedBackend) PendingNonceAt(ctx context.Context, account common.Ad
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	return b.pendingState.GetOrNewStateObject(account).Nonce(), nil
+	// Don't create the account, it would hide a failure to fetch it
+	nonce := b.pendingState.GetNonce(account)
+	if err := b.forkError(); err != nil {
+		return 0, err
+	}
+	return nonce, nil
 }
 
 // SuggestGasPrice implements ContractTransactor.SuggestGasPrice. Since the simulated
@@ -237,10 +370,10 @@ func (b *SimulatedBackend) EstimateGas(ctx context.Context, call genchain.CallMs
 		call.Gas = gas
 
 		snapshot := b.pendingState.Snapshot()
-		_, _, failed, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
+		_, _, failure, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
 		b.pendingState.RevertToSnapshot(snapshot)
 
-		if err != nil || failed {
+		if err != nil || failure != nil {
 			return false
 		}
 		return true
@@ -257,15 +390,24 @@ func (b *SimulatedBackend) EstimateGas(ctx context.Context, call genchain.CallMs
 	// Reject the transaction as invalid if it still fails at the highest allowance
 	if hi == cap {
 		if !executable(hi) {
+			if err := b.forkError(); err != nil {
+				return 0, err
+			}
 			return 0, errGasEstimationFailed
 		}
 	}
+	// The search is meaningless if the state failed to load along the way
+	if err := b.forkError(); err != nil {
+		return 0, err
+	}
 	return hi, nil
 }
 
 // callContract implements common code between normal and pending contract calls.
-// state is modified during execution, make sure to copy it if necessary.
-func (b *SimulatedBackend) callContract(ctx context.Context, call genchain.CallMsg, block *types.Block, statedb *state.StateDB) ([]byte, uint64, bool, error) {
+// state is modified during execution, make sure to copy it if necessary. The
+// error the execution failed with, if any, is returned next to the error
+// preventing the execution.
+func (b *SimulatedBackend) callContract(ctx context.Context, call genchain.CallMsg, block *types.Block, statedb *state.StateDB) ([]byte, uint64, error, error) {
 	// Ensure message is initialized properly.
 	if call.GasPrice == nil {
 		call.GasPrice = big.NewInt(1)
@@ -288,7 +430,7 @@ func (b *SimulatedBackend) callContract(ctx context.Context, call genchain.CallM
 	vmenv := vm.NewEVM(evmContext, statedb, b.config, vm.Config{})
 	gaspool := new(core.GasPool).AddGas(math.MaxUint64)
 
-	return core.NewStateTransition(vmenv, msg, gaspool).TransitionDb()
+	return core.ApplyCall(vmenv, msg, gaspool)
 }
 
 // SendTransaction updates the pending block to include the given transaction.
@@ -302,19 +444,25 @@ func (b *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transa
 		panic(fmt.Errorf("invalid transaction: %v", err))
 	}
 	nonce := b.pendingState.GetNonce(sender)
+	if err := b.forkError(); err != nil {
+		return err
+	}
 	if tx.Nonce() != nonce {
 		panic(fmt.Errorf("invalid transaction nonce: got %d, want %d", tx.Nonce(), nonce))
 	}
 
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), ethash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
+	pending := b.generate(func(number int, block *core.BlockGen) {
 		for _, tx := range b.pendingBlock.Transactions() {
 			block.AddTxWithChain(b.blockchain, tx)
 		}
 		block.AddTxWithChain(b.blockchain, tx)
 	})
+	if err := b.forkError(); err != nil {
+		return err
+	}
 	statedb, _ := b.blockchain.State()
 
-	b.pendingBlock = blocks[0]
+	b.pendingBlock = pending
 	b.pendingState, _ = state.New(b.pendingBlock.Root(), statedb.Database())
 	return nil
 }
@@ -385,15 +533,18 @@ func (b *SimulatedBackend) SubscribeFilterLogs(ctx context.Context, query gencha
 func (b *SimulatedBackend) AdjustTime(adjustment time.Duration) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), ethash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
+	pending := b.generate(func(number int, block *core.BlockGen) {
 		for _, tx := range b.pendingBlock.Transactions() {
 			block.AddTx(tx)
 		}
 		block.OffsetTime(int64(adjustment.Seconds()))
 	})
+	if err := b.forkError(); err != nil {
+		return err
+	}
 	statedb, _ := b.blockchain.State()
 
-	b.pendingBlock = blocks[0]
+	b.pendingBlock = pending
 	b.pendingState, _ = state.New(b.pendingBlock.Root(), statedb.Database())
 
 	return nil
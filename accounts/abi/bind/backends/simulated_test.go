@@ -0,0 +1,56 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/genchain/go-genchain"
+	"github.com/genchain/go-genchain/accounts/abi/bind/backends"
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/hexutil"
+	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/core/vm"
+)
+
+// Tests that failed calls return the revert data if the contract reverted, and
+// the error of the EVM otherwise.
+func TestSimulatedCallFailure(t *testing.T) {
+	var (
+		reverter = common.Address{0x01}
+		looper   = common.Address{0x02}
+	)
+	sim := backends.NewSimulatedBackend(core.GenesisAlloc{
+		reverter: {Code: hexutil.MustDecode("0x60ff60005360016000fd"), Balance: new(big.Int)}, // revert(0, 1) with 0xff
+		looper:   {Code: hexutil.MustDecode("0x5b600056"), Balance: new(big.Int)},             // jumpdest; jump(0)
+	})
+	ctx := context.Background()
+
+	_, err := sim.CallContract(ctx, genchain.CallMsg{To: &reverter}, nil)
+	derr, ok := err.(interface {
+		ErrorCode() int
+		ErrorData() interface{}
+	})
+	if !ok || derr.ErrorCode() != 3 || derr.ErrorData() != "0xff" {
+		t.Errorf("reverted call: error mismatch: have %v, want revert with data 0xff", err)
+	}
+	if _, err := sim.PendingCallContract(ctx, genchain.CallMsg{To: &looper, Gas: 100000}); err != vm.ErrOutOfGas {
+		t.Errorf("looping call: error mismatch: have %v, want %v", err, vm.ErrOutOfGas)
+	}
+}
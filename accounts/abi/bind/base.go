@@ -30,6 +30,11 @@ import (
 	"github.com/genchain/go-genchain/event"
 )
 
+var (
+	errNoEventSignature       = errors.New("no event signature")
+	errEventSignatureMismatch = errors.New("event signature mismatch")
+)
+
 // SignerFn is a signer function callback when a contract requires a method to
 // sign the transaction before submission.
 type SignerFn func(types.Signer, common.Address, *types.Transaction) (*types.Transaction, error)
@@ -159,7 +164,7 @@ func (c *BoundContract) Call(opts *CallOpts, result interface{}, method string,
 		}
 	}
 	if err != nil {
-		return err
+		return unpackRevert(err)
 	}
 	return c.abi.Unpack(result, method, output)
 }
@@ -326,6 +331,12 @@ func (c *BoundContract) WatchLogs(opts *WatchOpts, name string, query ...[]inter
 
 // UnpackLog unpacks a retrieved log into the provided output structure.
 func (c *BoundContract) UnpackLog(out interface{}, event string, log types.Log) error {
+	if len(log.Topics) == 0 {
+		return errNoEventSignature
+	}
+	if log.Topics[0] != c.abi.Events[event].Id() {
+		return errEventSignatureMismatch
+	}
 	if len(log.Data) > 0 {
 		if err := c.abi.Unpack(out, event, log.Data); err != nil {
 			return err
@@ -0,0 +1,124 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind_test
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/genchain/go-genchain/accounts/abi"
+	"github.com/genchain/go-genchain/accounts/abi/bind"
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/hexutil"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/ethclient"
+	"github.com/genchain/go-genchain/rpc"
+)
+
+const revertTestABI = `[{"constant":true,"inputs":[],"name":"get","outputs":[{"name":"","type":"uint256"}],"type":"function"},{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Sent","type":"event"}]`
+
+// RevertAPI serves calls that revert with the configured data, like a node does.
+type RevertAPI struct {
+	data []byte
+}
+
+type revertCallError struct {
+	data []byte
+}
+
+func (e *revertCallError) Error() string          { return "execution reverted" }
+func (e *revertCallError) ErrorCode() int         { return 3 }
+func (e *revertCallError) ErrorData() interface{} { return hexutil.Encode(e.data) }
+
+func (api *RevertAPI) Call(args map[string]interface{}, block string) (hexutil.Bytes, error) {
+	return nil, &revertCallError{data: api.data}
+}
+
+// Tests that the revert reasons of failed calls are decoded from the RPC errors.
+func TestCallRevertReason(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(revertTestABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reason := common.FromHex("0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"000000000000000000000000000000000000000000000000000000000000000d" +
+		"72657665727420726561736f6e00000000000000000000000000000000000000")
+
+	tests := []struct {
+		data   []byte
+		reason string
+	}{
+		{reason, "revert reason"},
+		{nil, ""},
+	}
+	for i, test := range tests {
+		server := rpc.NewServer()
+		if err := server.RegisterName("gen", &RevertAPI{data: test.data}); err != nil {
+			t.Fatal(err)
+		}
+		client := ethclient.NewClient(rpc.DialInProc(server))
+		contract := bind.NewBoundContract(common.Address{1}, parsed, client, client, client)
+
+		out := new(*big.Int)
+		err := contract.Call(nil, out, "get")
+		revert, ok := err.(*bind.RevertError)
+		if !ok {
+			t.Errorf("test %d: error type mismatch: have %T (%v), want *bind.RevertError", i, err, err)
+		} else if revert.Reason != test.reason || !bytes.Equal(revert.Data, test.data) {
+			t.Errorf("test %d: revert mismatch: have {%q, %x}, want {%q, %x}", i, revert.Reason, revert.Data, test.reason, test.data)
+		}
+		client.Close()
+		server.Stop()
+	}
+}
+
+// Tests that logs of other events are rejected when unpacking.
+func TestUnpackLogSignature(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(revertTestABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract := bind.NewBoundContract(common.Address{1}, parsed, nil, nil, nil)
+
+	var event struct {
+		From  common.Address
+		Value *big.Int
+	}
+	from := common.HexToAddress("0x0102030405060708090a0b0c0d0e0f1011121314")
+	log := types.Log{
+		Topics: []common.Hash{parsed.Events["Sent"].Id(), from.Hash()},
+		Data:   common.LeftPadBytes([]byte{42}, 32),
+	}
+	if err := contract.UnpackLog(&event, "Sent", log); err != nil {
+		t.Fatalf("failed to unpack log: %v", err)
+	}
+	if event.From != from || event.Value.Int64() != 42 {
+		t.Errorf("unpacked event mismatch: have %+v", event)
+	}
+	log.Topics[0] = crypto.Keccak256Hash([]byte("Other(address,uint256)"))
+	if err := contract.UnpackLog(&event, "Sent", log); err == nil {
+		t.Errorf("log of another event unpacked")
+	}
+	log.Topics = nil
+	if err := contract.UnpackLog(&event, "Sent", log); err == nil {
+		t.Errorf("log without topics unpacked")
+	}
+}
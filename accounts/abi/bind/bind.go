@@ -22,8 +22,10 @@ package bind
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 	"text/template"
 	"unicode"
@@ -47,7 +49,10 @@ const (
 // manually maintain hard coded strings that break on runtime.
 func Bind(types []string, abis []string, bytecodes []string, pkg string, lang Lang) (string, error) {
 	// Process each individual contract requested binding
-	contracts := make(map[string]*tmplContract)
+	var (
+		contracts = make(map[string]*tmplContract)
+		structs   = make(map[string]*tmplStruct) // Go structs of the tuples used by all contracts
+	)
 
 	for i := 0; i < len(types); i++ {
 		// Parse the actual ABI to generate the binding for
@@ -63,6 +68,13 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string, lang La
 			return r
 		}, abis[i])
 
+		// Declare the structs of all tuple arguments, in a stable order
+		if err := bindStructs(evmABI, structs); err != nil {
+			return "", err
+		}
+		if lang != LangGo && len(structs) > 0 {
+			return "", errors.New("tuple arguments are only supported in Go bindings")
+		}
 		// Extract the call and transact methods; events; and sort them alphabetically
 		var (
 			calls     = make(map[string]*tmplMethod)
@@ -131,15 +143,20 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string, lang La
 	data := &tmplData{
 		Package:   pkg,
 		Contracts: contracts,
+		Structs:   structs,
 	}
 	buffer := new(bytes.Buffer)
 
 	funcs := map[string]interface{}{
-		"bindtype":      bindType[lang],
-		"bindtopictype": bindTopicType[lang],
-		"namedtype":     namedType[lang],
-		"capitalise":    capitalise,
-		"decapitalise":  decapitalise,
+		"bindtype": func(kind abi.Type) string {
+			return bindType[lang](kind, structs)
+		},
+		"bindtopictype": func(kind abi.Type) string {
+			return bindTopicType[lang](kind, structs)
+		},
+		"namedtype":    namedType[lang],
+		"capitalise":   capitalise,
+		"decapitalise": decapitalise,
 	}
 	tmpl := template.Must(template.New("").Funcs(funcs).Parse(tmplSource[lang]))
 	if err := tmpl.Execute(buffer, data); err != nil {
@@ -157,9 +174,93 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string, lang La
 	return buffer.String(), nil
 }
 
+// bindStructs declares a struct for every tuple type used by the methods and
+// events of the contract, unless already declared.
+func bindStructs(evmABI abi.ABI, structs map[string]*tmplStruct) error {
+	args := []abi.Arguments{evmABI.Constructor.Inputs}
+
+	// Map iteration is random, but the names of anonymous structs are numbered
+	// in declaration order.
+	var names []string
+	for name := range evmABI.Methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, evmABI.Methods[name].Inputs, evmABI.Methods[name].Outputs)
+	}
+	names = names[:0]
+	for name := range evmABI.Events {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, evmABI.Events[name].Inputs)
+	}
+	for _, list := range args {
+		for _, arg := range list {
+			if err := bindStructTypeGo(arg.Type, structs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// bindStructTypeGo declares the Go structs of a tuple type, including the ones
+// of nested tuples. Structs are named after the Solidity struct, if known.
+func bindStructTypeGo(kind abi.Type, structs map[string]*tmplStruct) error {
+	switch kind.T {
+	case abi.ArrayTy, abi.SliceTy:
+		return bindStructTypeGo(*kind.Elem, structs)
+	case abi.TupleTy:
+		id := tupleID(kind)
+		if _, exist := structs[id]; exist {
+			return nil
+		}
+		fields := make([]*tmplField, len(kind.TupleElems))
+		for i, elem := range kind.TupleElems {
+			if err := bindStructTypeGo(*elem, structs); err != nil {
+				return err
+			}
+			fields[i] = &tmplField{Type: bindTypeGo(*elem, structs), Name: abi.ToCamelCase(kind.TupleRawNames[i]), SolKind: *elem}
+		}
+		name := kind.TupleRawName
+		if name == "" {
+			name = fmt.Sprintf("Struct%d", len(structs))
+		}
+		for _, s := range structs {
+			if s.Name == name {
+				return fmt.Errorf("conflicting declarations of struct %s", name)
+			}
+		}
+		structs[id] = &tmplStruct{Name: name, Fields: fields}
+	}
+	return nil
+}
+
+// tupleID identifies a tuple type by its struct name and the types and names of
+// its fields, as tuples of the same layout may be different structs.
+func tupleID(kind abi.Type) string {
+	switch kind.T {
+	case abi.ArrayTy:
+		return fmt.Sprintf("%s[%d]", tupleID(*kind.Elem), kind.Size)
+	case abi.SliceTy:
+		return tupleID(*kind.Elem) + "[]"
+	case abi.TupleTy:
+		fields := make([]string, len(kind.TupleElems))
+		for i, elem := range kind.TupleElems {
+			fields[i] = tupleID(*elem) + " " + kind.TupleRawNames[i]
+		}
+		return kind.TupleRawName + "(" + strings.Join(fields, ",") + ")"
+	default:
+		return kind.String()
+	}
+}
+
 // bindType is a set of type binders that convert Solidity types to some supported
 // programming language types.
-var bindType = map[Lang]func(kind abi.Type) string{
+var bindType = map[Lang]func(kind abi.Type, structs map[string]*tmplStruct) string{
 	LangGo:   bindTypeGo,
 	LangJava: bindTypeJava,
 }
@@ -184,25 +285,21 @@ func wrapArray(stringKind string, innerLen int, innerMapping string) (string, []
 	return innerMapping, parts
 }
 
-// Translates the array sizes to a Go-lang declaration of a (nested) array of the inner type.
-// Simply returns the inner type if arraySizes is empty.
-func arrayBindingGo(inner string, arraySizes []string) string {
-	out := ""
-	//prepend all array sizes, from outer (end arraySizes) to inner (start arraySizes)
-	for i := len(arraySizes) - 1; i >= 0; i-- {
-		out += "[" + arraySizes[i] + "]"
-	}
-	out += inner
-	return out
-}
-
 // bindTypeGo converts a Solidity type to a Go one. Since there is no clear mapping
 // from all Solidity types to Go ones (e.g. uint17), those that cannot be exactly
-// mapped will use an upscaled type (e.g. *big.Int).
-func bindTypeGo(kind abi.Type) string {
-	stringKind := kind.String()
-	innerLen, innerMapping := bindUnnestedTypeGo(stringKind)
-	return arrayBindingGo(wrapArray(stringKind, innerLen, innerMapping))
+// mapped will use an upscaled type (e.g. *big.Int). Tuples map to the structs
+// declared for them.
+func bindTypeGo(kind abi.Type, structs map[string]*tmplStruct) string {
+	switch kind.T {
+	case abi.TupleTy:
+		return structs[tupleID(kind)].Name
+	case abi.ArrayTy:
+		return fmt.Sprintf("[%d]", kind.Size) + bindTypeGo(*kind.Elem, structs)
+	case abi.SliceTy:
+		return "[]" + bindTypeGo(*kind.Elem, structs)
+	}
+	_, mapping := bindUnnestedTypeGo(kind.String())
+	return mapping
 }
 
 // The inner function of bindTypeGo, this finds the inner type of stringKind.
@@ -247,7 +344,7 @@ func arrayBindingJava(inner string, arraySizes []string) string {
 // bindTypeJava converts a Solidity type to a Java one. Since there is no clear mapping
 // from all Solidity types to Java ones (e.g. uint17), those that cannot be exactly
 // mapped will use an upscaled type (e.g. BigDecimal).
-func bindTypeJava(kind abi.Type) string {
+func bindTypeJava(kind abi.Type, structs map[string]*tmplStruct) string {
 	stringKind := kind.String()
 	innerLen, innerMapping := bindUnnestedTypeJava(stringKind)
 	return arrayBindingJava(wrapArray(stringKind, innerLen, innerMapping))
@@ -310,16 +407,17 @@ func bindUnnestedTypeJava(stringKind string) (int, string) {
 
 // bindTopicType is a set of type binders that convert Solidity types to some
 // supported programming language topic types.
-var bindTopicType = map[Lang]func(kind abi.Type) string{
+var bindTopicType = map[Lang]func(kind abi.Type, structs map[string]*tmplStruct) string{
 	LangGo:   bindTopicTypeGo,
 	LangJava: bindTopicTypeJava,
 }
 
 // bindTypeGo converts a Solidity topic type to a Go one. It is almost the same
-// funcionality as for simple types, but dynamic types get converted to hashes.
-func bindTopicTypeGo(kind abi.Type) string {
-	bound := bindTypeGo(kind)
-	if bound == "string" || bound == "[]byte" {
+// funcionality as for simple types, but dynamic types and tuples get converted
+// to hashes.
+func bindTopicTypeGo(kind abi.Type, structs map[string]*tmplStruct) string {
+	bound := bindTypeGo(kind, structs)
+	if bound == "string" || bound == "[]byte" || kind.T == abi.TupleTy {
 		bound = "common.Hash"
 	}
 	return bound
@@ -327,8 +425,8 @@ func bindTopicTypeGo(kind abi.Type) string {
 
 // bindTypeGo converts a Solidity topic type to a Java one. It is almost the same
 // funcionality as for simple types, but dynamic types get converted to hashes.
-func bindTopicTypeJava(kind abi.Type) string {
-	bound := bindTypeJava(kind)
+func bindTopicTypeJava(kind abi.Type, structs map[string]*tmplStruct) string {
+	bound := bindTypeJava(kind, structs)
 	if bound == "String" || bound == "Bytes" {
 		bound = "Hash"
 	}
@@ -199,6 +199,9 @@ var bindTests = []struct {
 			 fmt.Println(event.Num)           // Make sure the unpacked non-indexed fields are present
 			 fmt.Println(event.Addr)          // Make sure the reconstructed indexed fields are present
 
+			 pev, err := e.ParseMixed(event.Raw) // Make sure raw logs can be parsed directly
+			 fmt.Println(pev.Num, pev.Addr)
+
 			 fmt.Println(res, str, dat, hash, err)
 		 }
 		 // Run a tiny reflection test to ensure disallowed methods don't appear
@@ -653,6 +656,14 @@ var bindTests = []struct {
 			if sit.Event.Value.Uint64() != 33 || !sit.Event.Flag {
 				t.Errorf("simple log content mismatch: have %v, want {33, true}", sit.Event)
 			}
+			// Parse the raw log of the last event directly
+			parsed, err := eventer.ParseSimpleEvent(sit.Event.Raw)
+			if err != nil {
+				t.Fatalf("failed to parse simple event: %v", err)
+			}
+			if parsed.Addr != sit.Event.Addr || parsed.Value.Cmp(sit.Event.Value) != 0 || parsed.Flag != sit.Event.Flag {
+				t.Errorf("parsed simple log mismatch: have %v, want %v", parsed, sit.Event)
+			}
 
 			if sit.Next() {
 				t.Errorf("unexpected simple event found: %+v", sit.Event)
@@ -809,6 +820,58 @@ var bindTests = []struct {
 			}
 		`,
 	},
+	// Tests that tuples bind to the generated structs and round-trip through the ABI
+	{
+		`Tuple`,
+		`
+			pragma experimental ABIEncoderV2;
+
+			contract Tuple {
+				struct S { uint a; uint[] b; T[] c; }
+				struct T { uint x; uint y; }
+
+				event TupleEvent(S a, T[2] b);
+
+				function func1(S memory s, T memory t, uint a) public pure returns (S memory, T memory, uint) {
+					return (s, t, a);
+				}
+			}
+		`,
+		``,
+		`[{"constant":true,"inputs":[{"name":"s","type":"tuple","internalType":"struct Tuple.S","components":[{"name":"a","type":"uint256"},{"name":"b","type":"uint256[]"},{"name":"c","type":"tuple[]","internalType":"struct Tuple.T[]","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]}]},{"name":"t","type":"tuple","internalType":"struct Tuple.T","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]},{"name":"a","type":"uint256"}],"name":"func1","outputs":[{"name":"","type":"tuple","internalType":"struct Tuple.S","components":[{"name":"a","type":"uint256"},{"name":"b","type":"uint256[]"},{"name":"c","type":"tuple[]","internalType":"struct Tuple.T[]","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]}]},{"name":"","type":"tuple","internalType":"struct Tuple.T","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]},{"name":"","type":"uint256"}],"payable":false,"stateMutability":"pure","type":"function"},{"anonymous":false,"inputs":[{"indexed":false,"name":"a","type":"tuple","internalType":"struct Tuple.S","components":[{"name":"a","type":"uint256"},{"name":"b","type":"uint256[]"},{"name":"c","type":"tuple[]","internalType":"struct Tuple.T[]","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]}]},{"indexed":false,"name":"b","type":"tuple[2]","internalType":"struct Tuple.T[2]","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]}],"name":"TupleEvent","type":"event"}]`,
+		`
+			if b, err := NewTuple(common.Address{}, nil); b == nil || err != nil {
+				t.Fatalf("binding (%v) nil or error (%v) not nil", b, err)
+			}
+			parsed, err := abi.JSON(strings.NewReader(TupleABI))
+			if err != nil {
+				t.Fatalf("failed to parse ABI: %v", err)
+			}
+			s := TupleS{
+				A: big.NewInt(1),
+				B: []*big.Int{big.NewInt(2), big.NewInt(3)},
+				C: []TupleT{{X: big.NewInt(4), Y: big.NewInt(5)}},
+			}
+			tt := TupleT{X: big.NewInt(6), Y: big.NewInt(7)}
+
+			input, err := parsed.Pack("func1", s, tt, big.NewInt(8))
+			if err != nil {
+				t.Fatalf("failed to pack tuples: %v", err)
+			}
+			var (
+				s2  = new(TupleS)
+				tt2 = new(TupleT)
+				a2  = new(*big.Int)
+			)
+			out := []interface{}{s2, tt2, a2}
+			if err := parsed.Unpack(&out, "func1", input[4:]); err != nil {
+				t.Fatalf("failed to unpack tuples: %v", err)
+			}
+			if !reflect.DeepEqual(*s2, s) || !reflect.DeepEqual(*tt2, tt) || (*a2).Cmp(big.NewInt(8)) != 0 {
+				t.Fatalf("tuple mismatch: have %+v %+v %v", *s2, *tt2, *a2)
+			}
+		`,
+	},
 }
 
 // Tests that packages generated by the binder can be successfully compiled and
@@ -22,6 +22,7 @@ import "github.com/genchain/go-genchain/accounts/abi"
 type tmplData struct {
 	Package   string                   // Name of the package to place the generated file in
 	Contracts map[string]*tmplContract // List of contracts to generate into this file
+	Structs   map[string]*tmplStruct   // Structs of the tuples used by the contracts
 }
 
 // tmplContract contains the data needed to generate an individual contract binding.
@@ -49,6 +50,19 @@ type tmplEvent struct {
 	Normalized abi.Event // Normalized version of the parsed fields
 }
 
+// tmplStruct is a Go struct generated for an abi tuple.
+type tmplStruct struct {
+	Name   string       // Name of the Solidity struct if known, else a numbered one
+	Fields []*tmplField // Struct fields, in the order of the tuple components
+}
+
+// tmplField is a field of a struct generated for an abi tuple.
+type tmplField struct {
+	Type    string   // Go type of the field
+	Name    string   // Field name converted from the tuple component name
+	SolKind abi.Type // Abi type of the tuple component
+}
+
 // tmplSource is language to template mapping containing all the supported
 // programming languages the package can generate to.
 var tmplSource = map[Lang]string{
@@ -64,6 +78,14 @@ const tmplSourceGo = `
 
 package {{.Package}}
 
+{{range .Structs}}
+	// {{.Name}} is an auto generated low-level Go binding around a user-defined struct.
+	type {{.Name}} struct {
+	{{range .Fields}}{{.Name}} {{.Type}}
+	{{end}}
+	}
+{{end}}
+
 {{range $contract := .Contracts}}
 	// {{.Type}}ABI is the input ABI used to generate the binding from.
 	const {{.Type}}ABI = "{{.InputABI}}"
@@ -415,6 +437,18 @@ package {{.Package}}
 				}
 			}), nil
 		}
+
+		// Parse{{.Normalized.Name}} is a log parse operation binding the contract event 0x{{printf "%x" .Original.Id}}.
+		//
+		// Solidity: {{.Original.String}}
+		func (_{{$contract.Type}} *{{$contract.Type}}Filterer) Parse{{.Normalized.Name}}(log types.Log) (*{{$contract.Type}}{{.Normalized.Name}}, error) {
+			event := new({{$contract.Type}}{{.Normalized.Name}})
+			if err := _{{$contract.Type}}.contract.UnpackLog(event, "{{.Original.Name}}", log); err != nil {
+				return nil, err
+			}
+			event.Raw = log
+			return event, nil
+		}
  	{{end}}
 {{end}}
 `
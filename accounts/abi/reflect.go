@@ -81,12 +81,56 @@ func set(dst, src reflect.Value, output Argument) error {
 		dst.Set(src)
 	case dstType.Kind() == reflect.Ptr:
 		return set(dst.Elem(), src, output)
+	case dstType.Kind() == reflect.Struct && srcType.Kind() == reflect.Struct:
+		return setStruct(dst, src, output)
+	case dstType.Kind() == reflect.Slice && srcType.Kind() == reflect.Slice:
+		slice := reflect.MakeSlice(dstType, src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			if err := set(slice.Index(i), src.Index(i), output); err != nil {
+				return err
+			}
+		}
+		dst.Set(slice)
+	case dstType.Kind() == reflect.Array && srcType.Kind() == reflect.Array && dst.Len() == src.Len():
+		for i := 0; i < src.Len(); i++ {
+			if err := set(dst.Index(i), src.Index(i), output); err != nil {
+				return err
+			}
+		}
 	default:
 		return fmt.Errorf("abi: cannot unmarshal %v in to %v", src.Type(), dst.Type())
 	}
 	return nil
 }
 
+// setStruct assigns an unpacked tuple to a struct with equally named fields,
+// such as the structs generated by abigen.
+func setStruct(dst, src reflect.Value, output Argument) error {
+	for i := 0; i < src.NumField(); i++ {
+		name := src.Type().Field(i).Name
+		field := dst.FieldByName(name)
+		if !field.IsValid() {
+			return fmt.Errorf("abi: cannot unmarshal %v in to %v: field %s missing", src.Type(), dst.Type(), name)
+		}
+		if err := set(field, src.Field(i), output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tupleField looks up the struct field a tuple component is packed from: the
+// field tagged with the component name, or else the field named after it.
+func tupleField(v reflect.Value, name string) reflect.Value {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		if tag, ok := typ.Field(i).Tag.Lookup("abi"); ok && tag == name {
+			return v.Field(i)
+		}
+	}
+	return v.FieldByName(ToCamelCase(name))
+}
+
 // requireAssignable assures that `dest` is a pointer and it's not an interface.
 func requireAssignable(dst, src reflect.Value) error {
 	if dst.Kind() != reflect.Ptr && dst.Kind() != reflect.Interface {
@@ -17,6 +17,7 @@
 package abi
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"regexp"
@@ -32,6 +33,7 @@ const (
 	StringTy
 	SliceTy
 	ArrayTy
+	TupleTy
 	AddressTy
 	FixedBytesTy
 	BytesTy
@@ -50,6 +52,11 @@ type Type struct {
 	T    byte // Our own type checking
 
 	stringKind string // holds the unparsed string for deriving signatures
+
+	// Tuple relative fields
+	TupleElems    []*Type  // Type information of all tuple fields
+	TupleRawNames []string // Raw field names of all tuple fields
+	TupleRawName  string   // Name of the Solidity struct the tuple was declared as, if known
 }
 
 var (
@@ -57,8 +64,18 @@ var (
 	typeRegex = regexp.MustCompile("([a-zA-Z]+)(([0-9]+)(x([0-9]+))?)?")
 )
 
-// NewType creates a new reflection type of abi type given in t.
-func NewType(t string) (typ Type, err error) {
+// structPrefix prefixes the internal type names of Solidity structs.
+const structPrefix = "struct "
+
+// NewType creates a new reflection type of abi type given in t. Tuple types
+// (and arrays of them) need the components of the tuple.
+func NewType(t string, components ...ArgumentMarshaling) (typ Type, err error) {
+	return newType(t, "", components)
+}
+
+// newType creates a new reflection type of abi type given in t, naming tuples
+// after the Solidity struct in internalType.
+func newType(t string, internalType string, components []ArgumentMarshaling) (typ Type, err error) {
 	// check that array brackets are equal if they exist
 	if strings.Count(t, "[") != strings.Count(t, "]") {
 		return Type{}, fmt.Errorf("invalid arg type in abi")
@@ -71,7 +88,7 @@ func NewType(t string) (typ Type, err error) {
 	if strings.Count(t, "[") != 0 {
 		i := strings.LastIndex(t, "[")
 		// recursively embed the type
-		embeddedType, err := NewType(t[:i])
+		embeddedType, err := newType(t[:i], internalType, components)
 		if err != nil {
 			return Type{}, err
 		}
@@ -100,6 +117,8 @@ func NewType(t string) (typ Type, err error) {
 		} else {
 			return Type{}, fmt.Errorf("invalid formatting of array type")
 		}
+		// Tuples are written out in signatures, not as the "tuple" keyword
+		typ.stringKind = embeddedType.stringKind + sliced
 		return typ, err
 	}
 	// parse the type and size of the abi-type.
@@ -158,6 +177,48 @@ func NewType(t string) (typ Type, err error) {
 		typ.T = FunctionTy
 		typ.Size = 24
 		typ.Type = reflect.ArrayOf(24, reflect.TypeOf(byte(0)))
+	case "tuple":
+		if len(components) == 0 {
+			return Type{}, errors.New("abi: tuple without components")
+		}
+		var (
+			fields = make([]reflect.StructField, len(components))
+			elems  = make([]*Type, len(components))
+			names  = make([]string, len(components))
+			kinds  = make([]string, len(components))
+		)
+		for i, c := range components {
+			elem, err := newType(c.Type, c.InternalType, c.Components)
+			if err != nil {
+				return Type{}, err
+			}
+			name := ToCamelCase(c.Name)
+			if name == "" {
+				return Type{}, errors.New("abi: purely anonymous or underscored tuple field is not supported")
+			}
+			for _, field := range fields[:i] {
+				if field.Name == name {
+					return Type{}, fmt.Errorf("abi: duplicate tuple field %s", name)
+				}
+			}
+			fields[i] = reflect.StructField{Name: name, Type: elem.Type}
+			elems[i], names[i], kinds[i] = &elem, c.Name, elem.stringKind
+		}
+		typ.Kind = reflect.Struct
+		typ.Type = reflect.StructOf(fields)
+		typ.T = TupleTy
+		typ.TupleElems = elems
+		typ.TupleRawNames = names
+		typ.stringKind = "(" + strings.Join(kinds, ",") + ")"
+
+		// Foo.Bar is not a valid Go type name, the struct is called FooBar
+		if strings.HasPrefix(internalType, structPrefix) {
+			name := internalType[len(structPrefix):]
+			if i := strings.Index(name, "["); i >= 0 {
+				name = name[:i]
+			}
+			typ.TupleRawName = strings.Replace(name, ".", "", -1)
+		}
 	default:
 		return Type{}, fmt.Errorf("unsupported arg type: %s", t)
 	}
@@ -178,23 +239,64 @@ func (t Type) pack(v reflect.Value) ([]byte, error) {
 		return nil, err
 	}
 
-	if t.T == SliceTy || t.T == ArrayTy {
-		var packed []byte
-
+	switch t.T {
+	case SliceTy, ArrayTy:
+		var ret []byte
+		if t.requiresLengthPrefix() {
+			// slices are prefixed with their length
+			ret = append(ret, packNum(reflect.ValueOf(v.Len()))...)
+		}
+		// dynamic elements are referenced by their offsets, followed by
+		// their contents
+		var (
+			dynamic = isDynamicType(*t.Elem)
+			offset  = getTypeSize(*t.Elem) * v.Len()
+			tail    []byte
+		)
 		for i := 0; i < v.Len(); i++ {
 			val, err := t.Elem.pack(v.Index(i))
 			if err != nil {
 				return nil, err
 			}
-			packed = append(packed, val...)
+			if !dynamic {
+				ret = append(ret, val...)
+				continue
+			}
+			ret = append(ret, packNum(reflect.ValueOf(offset))...)
+			offset += len(val)
+			tail = append(tail, val...)
+		}
+		return append(ret, tail...), nil
+
+	case TupleTy:
+		// the fields are packed like the arguments of a method
+		offset := 0
+		for _, elem := range t.TupleElems {
+			offset += getTypeSize(*elem)
 		}
-		if t.T == SliceTy {
-			return packBytesSlice(packed, v.Len()), nil
-		} else if t.T == ArrayTy {
-			return packed, nil
+		var ret, tail []byte
+		for i, elem := range t.TupleElems {
+			field := tupleField(v, t.TupleRawNames[i])
+			if !field.IsValid() {
+				return nil, fmt.Errorf("abi: field %s for tuple not found in the given struct", t.TupleRawNames[i])
+			}
+			val, err := elem.pack(field)
+			if err != nil {
+				return nil, err
+			}
+			if isDynamicType(*elem) {
+				ret = append(ret, packNum(reflect.ValueOf(offset))...)
+				tail = append(tail, val...)
+				offset += len(val)
+			} else {
+				ret = append(ret, val...)
+			}
 		}
+		return append(ret, tail...), nil
+
+	default:
+		return packElement(t, v), nil
 	}
-	return packElement(t, v), nil
 }
 
 // requireLengthPrefix returns whether the type requires any sort of length
@@ -202,3 +304,32 @@ func (t Type) pack(v reflect.Value) ([]byte, error) {
 func (t Type) requiresLengthPrefix() bool {
 	return t.T == StringTy || t.T == BytesTy || t.T == SliceTy
 }
+
+// isDynamicType returns whether the type is encoded in the tail of its enclosing
+// tuple or argument list, referenced by an offset.
+func isDynamicType(t Type) bool {
+	if t.T == TupleTy {
+		for _, elem := range t.TupleElems {
+			if isDynamicType(*elem) {
+				return true
+			}
+		}
+		return false
+	}
+	return t.T == StringTy || t.T == BytesTy || t.T == SliceTy || (t.T == ArrayTy && isDynamicType(*t.Elem))
+}
+
+// getTypeSize returns the size the type occupies in the head of its enclosing
+// tuple or argument list. Dynamic types only occupy the 32 bytes of their offset.
+func getTypeSize(t Type) int {
+	if t.T == ArrayTy && !isDynamicType(*t.Elem) {
+		return t.Size * getTypeSize(*t.Elem)
+	} else if t.T == TupleTy && !isDynamicType(t) {
+		total := 0
+		for _, elem := range t.TupleElems {
+			total += getTypeSize(*elem)
+		}
+		return total
+	}
+	return 32
+}
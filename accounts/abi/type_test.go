@@ -281,3 +281,33 @@ func TestTypeCheck(t *testing.T) {
 		}
 	}
 }
+
+func TestTupleType(t *testing.T) {
+	typ, err := NewType("tuple[2][]", ArgumentMarshaling{Name: "a", Type: "uint256"}, ArgumentMarshaling{Name: "b", Type: "tuple", Components: []ArgumentMarshaling{{Name: "c_d", Type: "bytes"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := typ.String(); s != "(uint256,(bytes))[2][]" {
+		t.Errorf("wrong type string %q", s)
+	}
+	want := reflect.TypeOf([][2]struct {
+		A *big.Int
+		B struct{ CD []byte }
+	}{})
+	if typ.Type != want {
+		t.Errorf("wrong Go type:\ngot  %v\nwant %v", typ.Type, want)
+	}
+	if !isDynamicType(typ) || !isDynamicType(*typ.Elem) || isDynamicType(*typ.Elem.Elem.TupleElems[0]) {
+		t.Error("wrong dynamic type detection")
+	}
+
+	for _, components := range [][]ArgumentMarshaling{
+		nil,
+		{{Name: "a", Type: "uint256"}, {Name: "a", Type: "bool"}},
+		{{Name: "", Type: "uint256"}},
+	} {
+		if _, err := NewType("tuple", components...); err == nil {
+			t.Errorf("expected error for components %v", components)
+		}
+	}
+}
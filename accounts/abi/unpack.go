@@ -93,23 +93,12 @@ func readFixedBytes(t Type, word []byte) (interface{}, error) {
 
 }
 
-func getFullElemSize(elem *Type) int {
-	//all other should be counted as 32 (slices have pointers to respective elements)
-	size := 32
-	//arrays wrap it, each element being the same size
-	for elem.T == ArrayTy {
-		size *= elem.Size
-		elem = elem.Elem
-	}
-	return size
-}
-
 // iteratively unpack elements
 func forEachUnpack(t Type, output []byte, start, size int) (interface{}, error) {
 	if size < 0 {
 		return nil, fmt.Errorf("cannot marshal input to array, size is negative (%d)", size)
 	}
-	if start+32*size > len(output) {
+	if start+getTypeSize(*t.Elem)*size > len(output) {
 		return nil, fmt.Errorf("abi: cannot marshal in to go array: offset %d would go over slice boundary (len=%d)", len(output), start+32*size)
 	}
 
@@ -126,12 +115,9 @@ func forEachUnpack(t Type, output []byte, start, size int) (interface{}, error)
 		return nil, fmt.Errorf("abi: invalid type in array/slice unpacking stage")
 	}
 
-	// Arrays have packed elements, resulting in longer unpack steps.
-	// Slices have just 32 bytes per element (pointing to the contents).
-	elemSize := 32
-	if t.T == ArrayTy {
-		elemSize = getFullElemSize(t.Elem)
-	}
+	// Static elements are packed inline, resulting in longer unpack steps.
+	// Dynamic ones have just 32 bytes per element (pointing to the contents).
+	elemSize := getTypeSize(*t.Elem)
 
 	for i, j := start, 0; j < size; i, j = i+elemSize, j+1 {
 
@@ -148,6 +134,25 @@ func forEachUnpack(t Type, output []byte, start, size int) (interface{}, error)
 	return refSlice.Interface(), nil
 }
 
+// forTupleUnpack unpacks the fields of a tuple, encoded like an argument list at
+// the beginning of output.
+func forTupleUnpack(t Type, output []byte) (interface{}, error) {
+	retval := reflect.New(t.Type).Elem()
+	virtualArgs := 0
+	for index, elem := range t.TupleElems {
+		marshalledValue, err := toGoType((index+virtualArgs)*32, *elem, output)
+		if err != nil {
+			return nil, err
+		}
+		if !isDynamicType(*elem) {
+			// Static arrays and tuples are encoded inline, see UnpackValues.
+			virtualArgs += getTypeSize(*elem)/32 - 1
+		}
+		retval.Field(index).Set(reflect.ValueOf(marshalledValue))
+	}
+	return retval.Interface(), nil
+}
+
 // toGoType parses the output bytes and recursively assigns the value of these bytes
 // into a go type with accordance with the ABI spec.
 func toGoType(index int, t Type, output []byte) (interface{}, error) {
@@ -172,9 +177,26 @@ func toGoType(index int, t Type, output []byte) (interface{}, error) {
 	}
 
 	switch t.T {
+	case TupleTy:
+		if isDynamicType(t) {
+			begin, err := offsetPointsTo(index, output)
+			if err != nil {
+				return nil, err
+			}
+			return forTupleUnpack(t, output[begin:])
+		}
+		return forTupleUnpack(t, output[index:])
 	case SliceTy:
-		return forEachUnpack(t, output, begin, end)
+		// offsets of dynamic elements are relative to the first element
+		return forEachUnpack(t, output[begin:], 0, end)
 	case ArrayTy:
+		if isDynamicType(*t.Elem) {
+			begin, err := offsetPointsTo(index, output)
+			if err != nil {
+				return nil, err
+			}
+			return forEachUnpack(t, output[begin:], 0, t.Size)
+		}
 		return forEachUnpack(t, output, index, t.Size)
 	case StringTy: // variable arrays are written at the end of the return bytes
 		return string(output[begin : begin+end]), nil
@@ -228,3 +250,13 @@ func lengthPrefixPointsTo(index int, output []byte) (start int, length int, err
 	length = int(lengthBig.Uint64())
 	return
 }
+
+// offsetPointsTo resolves the offset at index, referencing the encoding of a
+// dynamic tuple or array.
+func offsetPointsTo(index int, output []byte) (int, error) {
+	offset := new(big.Int).SetBytes(output[index : index+32])
+	if offset.Cmp(big.NewInt(int64(len(output)))) > 0 {
+		return 0, fmt.Errorf("abi: cannot marshal in to go type: offset %v would go over slice boundary (len=%v)", offset, len(output))
+	}
+	return int(offset.Uint64()), nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package hdwallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/genchain/go-genchain/accounts"
+	"github.com/genchain/go-genchain/common/math"
+	"github.com/genchain/go-genchain/crypto"
+)
+
+// errInvalidKey is returned if a derived key is zero or out of the curve order,
+// which happens with a probability below 2^-127 (BIP-32).
+var errInvalidKey = errors.New("invalid derived key, use the next index")
+
+// extendedKey is a BIP-32 extended private key.
+type extendedKey struct {
+	key       *big.Int // Private key
+	chainCode []byte   // Chain code
+}
+
+// newMasterKey derives the BIP-32 master key from a seed.
+func newMasterKey(seed []byte) (*extendedKey, error) {
+	return newExtendedKey([]byte("Bitcoin seed"), seed, nil)
+}
+
+// child derives the BIP-32 child private key at the given index. Indices from
+// 0x80000000 on are hardened.
+func (k *extendedKey) child(index uint32) (*extendedKey, error) {
+	var data []byte
+	if index >= 0x80000000 {
+		data = append([]byte{0}, math.PaddedBigBytes(k.key, 32)...)
+	} else {
+		x, y := crypto.S256().ScalarBaseMult(math.PaddedBigBytes(k.key, 32))
+		data = crypto.CompressPubkey(&ecdsa.PublicKey{Curve: crypto.S256(), X: x, Y: y})
+	}
+	var indexBytes [4]byte
+	binary.BigEndian.PutUint32(indexBytes[:], index)
+	data = append(data, indexBytes[:]...)
+
+	return newExtendedKey(k.chainCode, data, k.key)
+}
+
+// newExtendedKey computes the key HMAC-SHA512(hmacKey, data), tweaking the parent
+// private key if given.
+func newExtendedKey(hmacKey, data []byte, parent *big.Int) (*extendedKey, error) {
+	mac := hmac.New(sha512.New, hmacKey)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	key := new(big.Int).SetBytes(sum[:32])
+	n := crypto.S256().Params().N
+	if key.Cmp(n) >= 0 {
+		return nil, errInvalidKey
+	}
+	if parent != nil {
+		key.Add(key, parent)
+		key.Mod(key, n)
+	}
+	if key.Sign() == 0 {
+		return nil, errInvalidKey
+	}
+	return &extendedKey{key: key, chainCode: sum[32:]}, nil
+}
+
+// deriveKey derives the private key at the given path from a seed.
+func deriveKey(seed []byte, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	key, err := newMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range path {
+		if key, err = key.child(index); err != nil {
+			return nil, err
+		}
+	}
+	return crypto.ToECDSA(math.PaddedBigBytes(key.key, 32))
+}
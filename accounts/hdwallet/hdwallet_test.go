@@ -0,0 +1,158 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package hdwallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/genchain/go-genchain/accounts"
+	"github.com/genchain/go-genchain/accounts/keystore"
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/crypto"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// Tests key derivation against the test vector 1 of BIP-32.
+func TestDeriveKey(t *testing.T) {
+	seed := common.FromHex("000102030405060708090a0b0c0d0e0f")
+	tests := []struct {
+		path string
+		key  string
+	}{
+		{"m/0'", "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+		{"m/0'/1", "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+		{"m/0'/1/2'", "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca"},
+		{"m/0'/1/2'/2", "0f479245fb19a38a1954c5c7c0ebab2f9bdfd96a17563ef28a6a4b1a2a764ef4"},
+		{"m/0'/1/2'/2/1000000000", "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8"},
+	}
+	for _, test := range tests {
+		path, err := accounts.ParseDerivationPath(test.path)
+		if err != nil {
+			t.Fatalf("%s: %v", test.path, err)
+		}
+		key, err := deriveKey(seed, path)
+		if err != nil {
+			t.Fatalf("%s: derivation failed: %v", test.path, err)
+		}
+		if have := hex.EncodeToString(crypto.FromECDSA(key)); have != test.key {
+			t.Errorf("%s: key mismatch: have %s, want %s", test.path, have, test.key)
+		}
+	}
+}
+
+func newTestHub(t *testing.T) (*Hub, string) {
+	dir, err := ioutil.TempDir("", "hdwallet-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewHub(dir, keystore.LightScryptN, keystore.LightScryptP), dir
+}
+
+// Tests that accounts are derived from imported mnemonics at the standard paths,
+// and that pinned accounts are persisted.
+func TestImportMnemonic(t *testing.T) {
+	hub, dir := newTestHub(t)
+	defer os.RemoveAll(dir)
+
+	if _, err := hub.ImportMnemonic("abandon abandon about", "pass"); err != ErrInvalidMnemonic {
+		t.Fatalf("invalid mnemonic error mismatch: have %v, want %v", err, ErrInvalidMnemonic)
+	}
+	wallet, err := hub.ImportMnemonic("  "+strings.Replace(testMnemonic, " ", "\n", 1), "pass")
+	if err != nil {
+		t.Fatalf("failed to import mnemonic: %v", err)
+	}
+	if _, err := wallet.Derive(accounts.DefaultBaseDerivationPath, true); err != accounts.ErrWalletClosed {
+		t.Fatalf("closed wallet derivation error mismatch: have %v, want %v", err, accounts.ErrWalletClosed)
+	}
+	if err := wallet.Open("bad"); err != keystore.ErrDecrypt {
+		t.Fatalf("bad passphrase error mismatch: have %v, want %v", err, keystore.ErrDecrypt)
+	}
+	if err := wallet.Open("pass"); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	account, err := wallet.Derive(accounts.DefaultBaseDerivationPath, true)
+	if err != nil {
+		t.Fatalf("failed to derive account: %v", err)
+	}
+	if want := common.HexToAddress("0x9858EfFD232B4033E47d90003D41EC34EcaEda94"); account.Address != want {
+		t.Errorf("derived address mismatch: have %x, want %x", account.Address, want)
+	}
+	unpinned, err := wallet.Derive(accounts.DerivationPath{0x80000000 + 44, 0x80000000 + 60, 0x80000000, 0, 1}, false)
+	if err != nil {
+		t.Fatalf("failed to derive account: %v", err)
+	}
+	if accs := wallet.Accounts(); len(accs) != 1 || accs[0] != account {
+		t.Errorf("tracked accounts mismatch: have %v, want [%v]", accs, account)
+	}
+	if wallet.Contains(unpinned) {
+		t.Errorf("unpinned account tracked")
+	}
+	// Pinned accounts are listed, and can sign, without opening the wallet again
+	reloaded := NewHub(dir, keystore.LightScryptN, keystore.LightScryptP).Wallets()
+	if len(reloaded) != 1 || reloaded[0].URL() != wallet.URL() {
+		t.Fatalf("reloaded wallets mismatch: have %v", reloaded)
+	}
+	if accs := reloaded[0].Accounts(); len(accs) != 1 || accs[0] != account {
+		t.Fatalf("reloaded accounts mismatch: have %v, want [%v]", accs, account)
+	}
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	if _, err := reloaded[0].SignTx(account, tx, big.NewInt(1)); err != accounts.ErrWalletClosed {
+		t.Fatalf("closed wallet signing error mismatch: have %v, want %v", err, accounts.ErrWalletClosed)
+	}
+	signed, err := reloaded[0].SignTxWithPassphrase(account, "pass", tx, big.NewInt(1))
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if from, _ := types.Sender(types.NewEIP155Signer(big.NewInt(1)), signed); from != account.Address {
+		t.Errorf("signer mismatch: have %x, want %x", from, account.Address)
+	}
+	if _, err := reloaded[0].SignHash(unpinned, make([]byte, 32)); err != accounts.ErrUnknownAccount {
+		t.Errorf("unknown account error mismatch: have %v, want %v", err, accounts.ErrUnknownAccount)
+	}
+}
+
+// Tests that generated mnemonics are stored encrypted.
+func TestNewWallet(t *testing.T) {
+	hub, dir := newTestHub(t)
+	defer os.RemoveAll(dir)
+
+	wallet, mnemonic, err := hub.NewWallet("pass")
+	if err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+	if words := strings.Fields(mnemonic); len(words) != 24 {
+		t.Fatalf("mnemonic length mismatch: have %d words, want 24", len(words))
+	}
+	blob, err := ioutil.ReadFile(wallet.URL().Path)
+	if err != nil {
+		t.Fatalf("failed to read wallet file: %v", err)
+	}
+	if bytes.Contains(blob, []byte(mnemonic)) {
+		t.Errorf("wallet file contains the mnemonic in the clear")
+	}
+	if wallets := hub.Wallets(); len(wallets) != 1 {
+		t.Errorf("wallet count mismatch: have %d, want 1", len(wallets))
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package hdwallet implements software hierarchical deterministic wallets, with
+// the accounts derived from a BIP-39 mnemonic stored encrypted on disk.
+package hdwallet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/genchain/go-genchain/accounts"
+	"github.com/genchain/go-genchain/accounts/keystore"
+	"github.com/genchain/go-genchain/event"
+	"github.com/genchain/go-genchain/log"
+	"github.com/pborman/uuid"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// Scheme is the protocol scheme prefixing account and wallet URLs.
+const Scheme = "hdwallet"
+
+// HubType is the reflect type of an HD wallet backend.
+var HubType = reflect.TypeOf(&Hub{})
+
+// mnemonicEntropy is the entropy in bits of the generated mnemonics, 24 words.
+const mnemonicEntropy = 256
+
+// version is the version of the wallet file format.
+const version = 1
+
+// ErrInvalidMnemonic is returned if an imported mnemonic has a wrong word count,
+// unknown words or a bad checksum.
+var ErrInvalidMnemonic = errors.New("invalid mnemonic")
+
+// walletJSON is the wallet file, with the mnemonic encrypted like keystore keys.
+// The accounts pinned to the wallet are stored in the clear, so that they can be
+// listed without the passphrase.
+type walletJSON struct {
+	Id       string              `json:"id"`
+	Crypto   keystore.CryptoJSON `json:"crypto"`
+	Accounts []pinnedAccountJSON `json:"accounts"`
+	Version  int                 `json:"version"`
+}
+
+type pinnedAccountJSON struct {
+	Address string `json:"address"`
+	Path    string `json:"path"`
+}
+
+// Hub is an accounts.Backend managing the HD wallets stored in a directory.
+type Hub struct {
+	dir     string // Directory of the wallet files
+	scryptN int    // Scrypt parameters to encrypt new wallets with
+	scryptP int
+
+	wallets     []accounts.Wallet       // Wallets in the directory, sorted by URL
+	updateFeed  event.Feed              // Event feed to notify wallet additions/removals
+	updateScope event.SubscriptionScope // Subscription scope tracking current live listeners
+
+	lock sync.Mutex
+}
+
+// NewHub creates an HD wallet backend for the wallet files in the given
+// directory, encrypting new wallets with the given scrypt parameters.
+func NewHub(dir string, scryptN, scryptP int) *Hub {
+	dir, _ = filepath.Abs(dir)
+	hub := &Hub{dir: dir, scryptN: scryptN, scryptP: scryptP}
+	hub.refreshWallets()
+	return hub
+}
+
+// Wallets implements accounts.Backend, returning the HD wallets in the directory.
+func (hub *Hub) Wallets() []accounts.Wallet {
+	hub.refreshWallets()
+
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	cpy := make([]accounts.Wallet, len(hub.wallets))
+	copy(cpy, hub.wallets)
+	return cpy
+}
+
+// Subscribe implements accounts.Backend, creating an async subscription to
+// receive notifications on the addition or removal of HD wallets.
+func (hub *Hub) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return hub.updateScope.Track(hub.updateFeed.Subscribe(sink))
+}
+
+// NewWallet generates a new mnemonic and stores it encrypted with the passphrase
+// as a new wallet. The mnemonic is returned for the user to back up.
+func (hub *Hub) NewWallet(passphrase string) (accounts.Wallet, string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropy)
+	if err != nil {
+		return nil, "", err
+	}
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return nil, "", err
+	}
+	wallet, err := hub.ImportMnemonic(mnemonic, passphrase)
+	if err != nil {
+		return nil, "", err
+	}
+	return wallet, mnemonic, nil
+}
+
+// ImportMnemonic stores the given mnemonic encrypted with the passphrase as a new
+// wallet.
+func (hub *Hub) ImportMnemonic(mnemonic, passphrase string) (accounts.Wallet, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, ErrInvalidMnemonic
+	}
+	crypto, err := keystore.EncryptDataV3([]byte(mnemonic), []byte(passphrase), hub.scryptN, hub.scryptP)
+	if err != nil {
+		return nil, err
+	}
+	id := uuid.NewRandom()
+	file := filepath.Join(hub.dir, walletFileName(id))
+	if err := writeWalletFile(file, &walletJSON{Id: id.String(), Crypto: crypto, Version: version}); err != nil {
+		return nil, err
+	}
+	hub.refreshWallets()
+
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	for _, w := range hub.wallets {
+		if w.(*wallet).file == file {
+			return w, nil
+		}
+	}
+	return nil, fmt.Errorf("wallet %s not loaded", file)
+}
+
+// refreshWallets reloads the list of wallets from the directory, firing events
+// for the wallets that arrived or were dropped.
+func (hub *Hub) refreshWallets() {
+	hub.lock.Lock()
+
+	files, err := ioutil.ReadDir(hub.dir)
+	if err != nil && !os.IsNotExist(err) {
+		hub.lock.Unlock()
+		log.Warn("Failed to read HD wallet directory", "dir", hub.dir, "err", err)
+		return
+	}
+
+	known := make(map[string]*wallet)
+	for _, w := range hub.wallets {
+		known[w.(*wallet).file] = w.(*wallet)
+	}
+	var (
+		wallets = make([]accounts.Wallet, 0, len(files))
+		events  []accounts.WalletEvent
+	)
+	for _, fi := range files {
+		if fi.IsDir() || strings.HasPrefix(fi.Name(), ".") || strings.HasSuffix(fi.Name(), "~") {
+			continue
+		}
+		file := filepath.Join(hub.dir, fi.Name())
+		if w, ok := known[file]; ok {
+			wallets = append(wallets, w)
+			delete(known, file)
+			continue
+		}
+		w, err := loadWallet(hub, file)
+		if err != nil {
+			log.Debug("Failed to load HD wallet", "file", file, "err", err)
+			continue
+		}
+		wallets = append(wallets, w)
+		events = append(events, accounts.WalletEvent{Wallet: w, Kind: accounts.WalletArrived})
+	}
+	for _, w := range known {
+		events = append(events, accounts.WalletEvent{Wallet: w, Kind: accounts.WalletDropped})
+	}
+	sort.Slice(wallets, func(i, j int) bool { return wallets[i].URL().Cmp(wallets[j].URL()) < 0 })
+	hub.wallets = wallets
+	hub.lock.Unlock()
+
+	for _, event := range events {
+		hub.updateFeed.Send(event)
+	}
+}
+
+// walletFileName returns the name of a new wallet file, sorting by creation time.
+func walletFileName(id uuid.UUID) string {
+	ts := time.Now().UTC()
+	return fmt.Sprintf("UTC--%04d-%02d-%02dT%02d-%02d-%02d.%09dZ--hd--%s", ts.Year(), ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), ts.Nanosecond(), id)
+}
+
+// writeWalletFile atomically replaces the wallet file with the given content.
+func writeWalletFile(file string, content *walletJSON) error {
+	blob, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	// Create the wallet directory with appropriate permissions
+	// in case it is not present yet.
+	const dirPerm = 0700
+	if err := os.MkdirAll(filepath.Dir(file), dirPerm); err != nil {
+		return err
+	}
+	// Atomic write: create a temporary hidden file first
+	// then move it into place. TempFile assigns mode 0600.
+	f, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(blob); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	f.Close()
+	return os.Rename(f.Name(), file)
+}
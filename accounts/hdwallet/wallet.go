@@ -0,0 +1,415 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package hdwallet
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sync"
+	"time"
+
+	genchain "github.com/genchain/go-genchain"
+	"github.com/genchain/go-genchain/accounts"
+	"github.com/genchain/go-genchain/accounts/keystore"
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/log"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// Minimum time to wait between self derivation attempts, even it the user is
+// requesting accounts like crazy.
+const selfDeriveThrottling = time.Second
+
+// wallet implements accounts.Wallet for a mnemonic stored in a wallet file.
+type wallet struct {
+	hub  *Hub
+	file string       // Wallet file holding the encrypted mnemonic
+	url  accounts.URL // Textual URL uniquely identifying this wallet
+
+	seed     []byte                                     // BIP-39 seed while the wallet is open
+	accounts []accounts.Account                         // List of derived accounts pinned on the wallet
+	paths    map[common.Address]accounts.DerivationPath // Known derivation paths for signing operations
+	pinned   []pinnedAccountJSON                        // Accounts pinned in the wallet file
+
+	deriveNextPath accounts.DerivationPath   // Next derivation path for account auto-discovery
+	deriveChain    genchain.ChainStateReader // Blockchain state reader to discover used account with
+	deriveTime     time.Time                 // Time of the last account auto-discovery
+
+	lock sync.RWMutex
+}
+
+// loadWallet loads the pinned accounts of a wallet file, leaving the mnemonic
+// encrypted until the wallet is opened.
+func loadWallet(hub *Hub, file string) (*wallet, error) {
+	content, err := readWalletFile(file)
+	if err != nil {
+		return nil, err
+	}
+	w := &wallet{
+		hub:    hub,
+		file:   file,
+		url:    accounts.URL{Scheme: Scheme, Path: file},
+		paths:  make(map[common.Address]accounts.DerivationPath),
+		pinned: content.Accounts,
+	}
+	for _, pinned := range content.Accounts {
+		path, err := accounts.ParseDerivationPath(pinned.Path)
+		if err != nil {
+			return nil, err
+		}
+		w.track(common.HexToAddress(pinned.Address), path)
+	}
+	return w, nil
+}
+
+func readWalletFile(file string) (*walletJSON, error) {
+	blob, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	content := new(walletJSON)
+	if err := json.Unmarshal(blob, content); err != nil {
+		return nil, err
+	}
+	if content.Version != version {
+		return nil, fmt.Errorf("unsupported wallet version %d", content.Version)
+	}
+	return content, nil
+}
+
+// URL implements accounts.Wallet, returning the URL of the wallet file.
+func (w *wallet) URL() accounts.URL {
+	return w.url
+}
+
+// Status implements accounts.Wallet, returning whether the mnemonic of the
+// wallet is decrypted or not.
+func (w *wallet) Status() (string, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	if w.seed != nil {
+		return "Open", nil
+	}
+	return "Closed", nil
+}
+
+// Open implements accounts.Wallet, decrypting the mnemonic with the passphrase
+// and keeping the seed in memory until the wallet is closed.
+func (w *wallet) Open(passphrase string) error {
+	w.lock.Lock()
+	if w.seed != nil {
+		w.lock.Unlock()
+		return accounts.ErrWalletAlreadyOpen
+	}
+	seed, err := w.decryptSeed(passphrase)
+	if err != nil {
+		w.lock.Unlock()
+		return err
+	}
+	w.seed = seed
+	w.lock.Unlock()
+
+	go w.hub.updateFeed.Send(accounts.WalletEvent{Wallet: w, Kind: accounts.WalletOpened})
+	return nil
+}
+
+// Close implements accounts.Wallet, dropping the seed from memory.
+func (w *wallet) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for i := range w.seed {
+		w.seed[i] = 0
+	}
+	w.seed = nil
+	return nil
+}
+
+// decryptSeed decrypts the mnemonic of the wallet and converts it to the seed
+// the accounts are derived from.
+func (w *wallet) decryptSeed(passphrase string) ([]byte, error) {
+	content, err := readWalletFile(w.file)
+	if err != nil {
+		return nil, err
+	}
+	mnemonic, err := keystore.DecryptDataV3(content.Crypto, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return bip39.NewSeedWithErrorChecking(string(mnemonic), "")
+}
+
+// Accounts implements accounts.Wallet, returning the list of accounts pinned to
+// the wallet. If self-derivation was enabled, the account list is expanded based
+// on current chain state while the wallet is open.
+func (w *wallet) Accounts() []accounts.Account {
+	w.selfDerive()
+
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	cpy := make([]accounts.Account, len(w.accounts))
+	copy(cpy, w.accounts)
+	return cpy
+}
+
+// selfDerive discovers the accounts used on chain, starting from the configured
+// self-derivation path and stopping at the first empty account. The wallet lock
+// is only held while deriving and tracking accounts, not during chain queries.
+func (w *wallet) selfDerive() {
+	w.lock.Lock()
+	if w.seed == nil || w.deriveChain == nil || time.Since(w.deriveTime) < selfDeriveThrottling {
+		w.lock.Unlock()
+		return
+	}
+	w.deriveTime = time.Now()
+
+	var (
+		chain    = w.deriveChain
+		started  = w.deriveTime
+		nextPath = make(accounts.DerivationPath, len(w.deriveNextPath))
+	)
+	copy(nextPath, w.deriveNextPath)
+	w.lock.Unlock()
+
+	ctx := context.Background()
+	for {
+		// Derive the next account, bailing out if the wallet was closed meanwhile
+		w.lock.Lock()
+		if w.seed == nil {
+			w.lock.Unlock()
+			return
+		}
+		key, err := deriveKey(w.seed, nextPath)
+		w.lock.Unlock()
+		if err != nil {
+			log.Warn("HD wallet account derivation failed", "err", err)
+			return
+		}
+		address := crypto.PubkeyToAddress(key.PublicKey)
+		zeroKey(key)
+
+		// Check the account's status against the current chain state
+		balance, err := chain.BalanceAt(ctx, address, nil)
+		if err != nil {
+			log.Warn("HD wallet balance retrieval failed", "err", err)
+			return
+		}
+		nonce, err := chain.NonceAt(ctx, address, nil)
+		if err != nil {
+			log.Warn("HD wallet nonce retrieval failed", "err", err)
+			return
+		}
+		// Start tracking the account, but stop self-derivation if it's empty
+		path := make(accounts.DerivationPath, len(nextPath))
+		copy(path, nextPath)
+
+		empty := balance.Sign() == 0 && nonce == 0
+		if !empty {
+			nextPath[len(nextPath)-1]++
+		}
+		w.lock.Lock()
+		if _, known := w.paths[address]; !known {
+			log.Info("HD wallet discovered new account", "address", address, "path", path, "balance", balance, "nonce", nonce)
+		}
+		w.track(address, path)
+		if w.deriveTime.Equal(started) { // Don't overwrite a newer SelfDerive or run
+			copy(w.deriveNextPath, nextPath)
+		}
+		w.lock.Unlock()
+
+		if empty {
+			return
+		}
+	}
+}
+
+// track adds an account to the list of accounts of the wallet, unless known.
+func (w *wallet) track(address common.Address, path accounts.DerivationPath) accounts.Account {
+	account := accounts.Account{
+		Address: address,
+		URL:     accounts.URL{Scheme: w.url.Scheme, Path: fmt.Sprintf("%s/%s", w.url.Path, path)},
+	}
+	if _, ok := w.paths[address]; !ok {
+		w.accounts = append(w.accounts, account)
+		w.paths[address] = path
+	}
+	return account
+}
+
+// Contains implements accounts.Wallet, returning whether a particular account is
+// or is not tracked by this wallet instance.
+func (w *wallet) Contains(account accounts.Account) bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	_, exists := w.paths[account.Address]
+	return exists
+}
+
+// Derive implements accounts.Wallet, deriving a new account at the specific
+// derivation path. If pin is set to true, the account will be added to the list
+// of tracked accounts and stored in the wallet file.
+func (w *wallet) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.seed == nil {
+		return accounts.Account{}, accounts.ErrWalletClosed
+	}
+	key, err := deriveKey(w.seed, path)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	zeroKey(key)
+
+	if !pin {
+		return accounts.Account{
+			Address: address,
+			URL:     accounts.URL{Scheme: w.url.Scheme, Path: fmt.Sprintf("%s/%s", w.url.Path, path)},
+		}, nil
+	}
+	for _, pinned := range w.pinned {
+		if common.HexToAddress(pinned.Address) == address {
+			return w.track(address, path), nil
+		}
+	}
+	// Store the pinned account for it to be listed after a restart
+	content, err := readWalletFile(w.file)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	content.Accounts = append(w.pinned, pinnedAccountJSON{Address: address.Hex(), Path: path.String()})
+	if err := writeWalletFile(w.file, content); err != nil {
+		return accounts.Account{}, err
+	}
+	w.pinned = content.Accounts
+	return w.track(address, path), nil
+}
+
+// SelfDerive implements accounts.Wallet, trying to discover accounts that the
+// user used previously (based on the chain state), but ones that he/she did not
+// explicitly pin to the wallet manually. To avoid chain head monitoring, self
+// derivation only runs during account listing (and even then throttled).
+func (w *wallet) SelfDerive(base accounts.DerivationPath, chain genchain.ChainStateReader) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.deriveNextPath = make(accounts.DerivationPath, len(base))
+	copy(w.deriveNextPath[:], base[:])
+
+	w.deriveChain = chain
+	w.deriveTime = time.Time{}
+}
+
+// signingKey derives the private key of an account tracked by the wallet, using
+// the seed of the open wallet, or decrypting the mnemonic with the passphrase if
+// one is given.
+func (w *wallet) signingKey(account accounts.Account, passphrase *string) (*ecdsa.PrivateKey, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	path, ok := w.paths[account.Address]
+	if !ok {
+		return nil, accounts.ErrUnknownAccount
+	}
+	seed := w.seed
+	if passphrase != nil {
+		var err error
+		if seed, err = w.decryptSeed(*passphrase); err != nil {
+			return nil, err
+		}
+		defer func() {
+			for i := range seed {
+				seed[i] = 0
+			}
+		}()
+	}
+	if seed == nil {
+		return nil, accounts.ErrWalletClosed
+	}
+	return deriveKey(seed, path)
+}
+
+// SignHash implements accounts.Wallet, signing the given hash with the given
+// account, if the wallet is open.
+func (w *wallet) SignHash(account accounts.Account, hash []byte) ([]byte, error) {
+	key, err := w.signingKey(account, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+	return crypto.Sign(hash, key)
+}
+
+// SignTx implements accounts.Wallet, signing the given transaction with the given
+// account, if the wallet is open.
+func (w *wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	key, err := w.signingKey(account, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+	return signTx(tx, chainID, key)
+}
+
+// SignHashWithPassphrase implements accounts.Wallet, attempting to sign the
+// given hash with the given account using passphrase as extra authentication.
+func (w *wallet) SignHashWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
+	key, err := w.signingKey(account, &passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+	return crypto.Sign(hash, key)
+}
+
+// SignTxWithPassphrase implements accounts.Wallet, attempting to sign the given
+// transaction with the given account using passphrase as extra authentication.
+func (w *wallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	key, err := w.signingKey(account, &passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+	return signTx(tx, chainID, key)
+}
+
+// signTx signs the transaction, with EIP155 replay protection if a chain id is
+// given.
+func signTx(tx *types.Transaction, chainID *big.Int, key *ecdsa.PrivateKey) (*types.Transaction, error) {
+	if chainID != nil {
+		return types.SignTx(tx, types.NewEIP155Signer(chainID), key)
+	}
+	return types.SignTx(tx, types.HomesteadSigner{}, key)
+}
+
+// zeroKey zeroes a private key in memory.
+func zeroKey(k *ecdsa.PrivateKey) {
+	b := k.D.Bits()
+	for i := range b {
+		b[i] = 0
+	}
+}
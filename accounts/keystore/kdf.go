@@ -0,0 +1,266 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// StandardArgon2Time is the number of passes of Argon2id over its memory.
+	StandardArgon2Time = 3
+
+	// StandardArgon2Memory is the memory of Argon2id in KiB, 256MB like the
+	// standard scrypt parameters.
+	StandardArgon2Memory = 256 * 1024
+
+	// LightArgon2Time is the number of passes of light Argon2id over its memory.
+	LightArgon2Time = 3
+
+	// LightArgon2Memory is the memory of light Argon2id in KiB, 4MB like the
+	// light scrypt parameters.
+	LightArgon2Memory = 4 * 1024
+
+	// StandardPBKDF2C is the iteration count of PBKDF2.
+	StandardPBKDF2C = 1 << 18
+
+	argon2Threads = 4
+
+	// argon2Version is the version of Argon2 implemented, 1.3.
+	argon2Version = 0x13
+
+	// maxArgon2Time and maxArgon2Memory bound the cost parameters read from
+	// key files, so a crafted key file can't make decryption hang or exhaust
+	// the memory. The memory is in KiB, 4GB.
+	maxArgon2Time   = 64
+	maxArgon2Memory = 4 * 1024 * 1024
+
+	// maxScryptNRP bounds the product of the scrypt parameters read from key
+	// files the same way, scrypt using 128*N*r*p bytes of memory, at most 4GB.
+	maxScryptNRP = 1 << 25
+)
+
+// KDF is a key derivation function along with its cost parameters, deriving the
+// encryption keys of the key files from their passphrases. The name and the
+// parameters of a KDF are stored in the "kdf" and "kdfparams" fields of the key
+// files, next to the salt and the length of the derived key.
+type KDF interface {
+	// Name returns the name of the KDF in the key files.
+	Name() string
+
+	// Params returns the cost parameters of the KDF in the key files.
+	Params() map[string]interface{}
+
+	// DeriveKey derives a key of the given length from the passphrase.
+	DeriveKey(auth, salt []byte, dkLen int) ([]byte, error)
+
+	// Weak reports whether the cost parameters are below the standard ones.
+	Weak() bool
+}
+
+// KDFDecoder creates a KDF from the parameters stored in a key file.
+type KDFDecoder func(params map[string]interface{}) (KDF, error)
+
+var (
+	kdfDecoders = map[string]KDFDecoder{
+		keyHeaderKDF: decodeScryptKDF,
+		"pbkdf2":     decodePBKDF2KDF,
+		"argon2id":   decodeArgon2idKDF,
+	}
+	kdfLock sync.RWMutex
+)
+
+// RegisterKDF registers the decoder of the key files encrypted with the named
+// KDF, replacing the existing one if any.
+func RegisterKDF(name string, decode KDFDecoder) {
+	kdfLock.Lock()
+	defer kdfLock.Unlock()
+
+	kdfDecoders[name] = decode
+}
+
+// decodeKDF creates the named KDF from the parameters stored in a key file.
+func decodeKDF(name string, params map[string]interface{}) (KDF, error) {
+	kdfLock.RLock()
+	decode, ok := kdfDecoders[name]
+	kdfLock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("Unsupported KDF: %s", name)
+	}
+	return decode(params)
+}
+
+// ScryptKDF derives keys with scrypt.
+type ScryptKDF struct {
+	N, R, P int
+}
+
+// NewScryptKDF creates a scrypt KDF with the given N and P parameters.
+func NewScryptKDF(n, p int) *ScryptKDF {
+	return &ScryptKDF{N: n, R: scryptR, P: p}
+}
+
+// kdfParamInt reads a positive integer parameter of a KDF from the parameters
+// stored in a key file.
+func kdfParamInt(params map[string]interface{}, name string) (int, error) {
+	switch v := params[name].(type) {
+	case nil:
+		return 0, fmt.Errorf("missing KDF parameter %q", name)
+	case int:
+		if v > 0 {
+			return v, nil
+		}
+	case float64:
+		// Integers decoded from JSON end up as float64
+		if v > 0 && v <= math.MaxInt32 && v == math.Trunc(v) {
+			return int(v), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid KDF parameter %q: %v", name, params[name])
+}
+
+func decodeScryptKDF(params map[string]interface{}) (KDF, error) {
+	n, err := kdfParamInt(params, "n")
+	if err != nil {
+		return nil, err
+	}
+	r, err := kdfParamInt(params, "r")
+	if err != nil {
+		return nil, err
+	}
+	p, err := kdfParamInt(params, "p")
+	if err != nil {
+		return nil, err
+	}
+	if n > maxScryptNRP || r > maxScryptNRP/n || p > maxScryptNRP/(n*r) {
+		return nil, fmt.Errorf("invalid scrypt parameters: n=%d, r=%d, p=%d", n, r, p)
+	}
+	return &ScryptKDF{N: n, R: r, P: p}, nil
+}
+
+// Name implements KDF.
+func (kdf *ScryptKDF) Name() string { return keyHeaderKDF }
+
+// Params implements KDF.
+func (kdf *ScryptKDF) Params() map[string]interface{} {
+	return map[string]interface{}{"n": kdf.N, "r": kdf.R, "p": kdf.P}
+}
+
+// DeriveKey implements KDF.
+func (kdf *ScryptKDF) DeriveKey(auth, salt []byte, dkLen int) ([]byte, error) {
+	return scrypt.Key(auth, salt, kdf.N, kdf.R, kdf.P, dkLen)
+}
+
+// Weak implements KDF.
+func (kdf *ScryptKDF) Weak() bool {
+	return kdf.N*kdf.R < StandardScryptN*scryptR
+}
+
+// PBKDF2KDF derives keys with PBKDF2, using HMAC-SHA256 as the pseudorandom
+// function.
+type PBKDF2KDF struct {
+	C int
+}
+
+func decodePBKDF2KDF(params map[string]interface{}) (KDF, error) {
+	if prf, _ := params["prf"].(string); prf != "hmac-sha256" {
+		return nil, fmt.Errorf("Unsupported PBKDF2 PRF: %v", params["prf"])
+	}
+	c, err := kdfParamInt(params, "c")
+	if err != nil {
+		return nil, err
+	}
+	return &PBKDF2KDF{C: c}, nil
+}
+
+// Name implements KDF.
+func (kdf *PBKDF2KDF) Name() string { return "pbkdf2" }
+
+// Params implements KDF.
+func (kdf *PBKDF2KDF) Params() map[string]interface{} {
+	return map[string]interface{}{"c": kdf.C, "prf": "hmac-sha256"}
+}
+
+// DeriveKey implements KDF.
+func (kdf *PBKDF2KDF) DeriveKey(auth, salt []byte, dkLen int) ([]byte, error) {
+	return pbkdf2.Key(auth, salt, kdf.C, dkLen, sha256.New), nil
+}
+
+// Weak implements KDF.
+func (kdf *PBKDF2KDF) Weak() bool {
+	return kdf.C < StandardPBKDF2C
+}
+
+// Argon2idKDF derives keys with the memory-hard Argon2id, version 1.3.
+type Argon2idKDF struct {
+	Time    uint32 // Number of passes over the memory
+	Memory  uint32 // Memory in KiB
+	Threads uint8  // Number of lanes filled in parallel
+}
+
+// NewArgon2idKDF creates an Argon2id KDF with the given time and memory cost.
+func NewArgon2idKDF(time, memory uint32) *Argon2idKDF {
+	return &Argon2idKDF{Time: time, Memory: memory, Threads: argon2Threads}
+}
+
+func decodeArgon2idKDF(params map[string]interface{}) (KDF, error) {
+	if v, err := kdfParamInt(params, "v"); err != nil || v != argon2Version {
+		return nil, fmt.Errorf("Unsupported Argon2 version: %v", params["v"])
+	}
+	t, err := kdfParamInt(params, "t")
+	if err != nil {
+		return nil, err
+	}
+	m, err := kdfParamInt(params, "m")
+	if err != nil {
+		return nil, err
+	}
+	p, err := kdfParamInt(params, "p")
+	if err != nil {
+		return nil, err
+	}
+	if t < 1 || t > maxArgon2Time || p < 1 || p > 255 || m < 8*p || m > maxArgon2Memory {
+		return nil, fmt.Errorf("invalid Argon2id parameters: t=%d, m=%d, p=%d", t, m, p)
+	}
+	return &Argon2idKDF{Time: uint32(t), Memory: uint32(m), Threads: uint8(p)}, nil
+}
+
+// Name implements KDF.
+func (kdf *Argon2idKDF) Name() string { return "argon2id" }
+
+// Params implements KDF.
+func (kdf *Argon2idKDF) Params() map[string]interface{} {
+	return map[string]interface{}{"t": kdf.Time, "m": kdf.Memory, "p": kdf.Threads, "v": argon2Version}
+}
+
+// DeriveKey implements KDF.
+func (kdf *Argon2idKDF) DeriveKey(auth, salt []byte, dkLen int) ([]byte, error) {
+	return argon2.IDKey(auth, salt, kdf.Time, kdf.Memory, kdf.Threads, uint32(dkLen)), nil
+}
+
+// Weak implements KDF.
+func (kdf *Argon2idKDF) Weak() bool {
+	return kdf.Memory < StandardArgon2Memory || kdf.Time < StandardArgon2Time
+}
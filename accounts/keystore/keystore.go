@@ -25,6 +25,7 @@ import (
 	crand "crypto/rand"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math/big"
 	"os"
 	"path/filepath"
@@ -38,12 +39,16 @@ import (
 	"github.com/genchain/go-genchain/core/types"
 	"github.com/genchain/go-genchain/crypto"
 	"github.com/genchain/go-genchain/event"
+	"github.com/genchain/go-genchain/log"
 )
 
 var (
 	ErrLocked  = accounts.NewAuthNeededError("password or unlock")
 	ErrNoMatch = errors.New("no key for given address or file")
 	ErrDecrypt = errors.New("could not decrypt key with given passphrase")
+
+	// ErrNotEncrypted is returned when the KDF of a plaintext keystore is accessed.
+	ErrNotEncrypted = errors.New("keystore is not encrypted")
 )
 
 // KeyStoreType is the reflect type of a keystore backend.
@@ -472,6 +477,62 @@ func (ks *KeyStore) Update(a accounts.Account, passphrase, newPassphrase string)
 	return ks.storage.StoreKey(a.URL.Path, key, newPassphrase)
 }
 
+// Rekey re-encrypts the key file of an existing account with the given KDF,
+// keeping its passphrase.
+func (ks *KeyStore) Rekey(a accounts.Account, passphrase string, kdf KDF) error {
+	if _, ok := ks.storage.(*keyStorePassphrase); !ok {
+		return ErrNotEncrypted
+	}
+	a, key, err := ks.getDecryptedKey(a, passphrase)
+	if err != nil {
+		return err
+	}
+	defer zeroKey(key.PrivateKey)
+
+	keyjson, err := EncryptKeyWithKDF(key, passphrase, kdf)
+	if err != nil {
+		return err
+	}
+	return writeKeyFile(a.URL.Path, keyjson)
+}
+
+// KDF returns the KDF the key file of an account is encrypted with.
+func (ks *KeyStore) KDF(a accounts.Account) (KDF, error) {
+	if _, ok := ks.storage.(*keyStorePassphrase); !ok {
+		return nil, ErrNotEncrypted
+	}
+	a, err := ks.Find(a)
+	if err != nil {
+		return nil, err
+	}
+	keyjson, err := ioutil.ReadFile(a.URL.Path)
+	if err != nil {
+		return nil, err
+	}
+	return KeyFileKDF(keyjson)
+}
+
+// WeakAccounts returns the accounts whose key files are encrypted with KDF
+// parameters below the standard ones, such as the light scrypt ones. Key files
+// whose KDF can't be read are skipped with a warning.
+func (ks *KeyStore) WeakAccounts() ([]accounts.Account, error) {
+	if _, ok := ks.storage.(*keyStorePassphrase); !ok {
+		return nil, ErrNotEncrypted
+	}
+	var weak []accounts.Account
+	for _, a := range ks.Accounts() {
+		kdf, err := ks.KDF(a)
+		if err != nil {
+			log.Warn("Failed to read key file KDF", "url", a.URL, "err", err)
+			continue
+		}
+		if kdf.Weak() {
+			weak = append(weak, a)
+		}
+	}
+	return weak, nil
+}
+
 // ImportPreSaleKey decrypts the given Genchain presale wallet and stores
 // a key file in the key directory. The key file is encrypted with the same passphrase.
 func (ks *KeyStore) ImportPreSaleKey(keyJSON []byte, passphrase string) (accounts.Account, error) {
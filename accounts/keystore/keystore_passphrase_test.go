@@ -18,6 +18,7 @@ package keystore
 
 import (
 	"io/ioutil"
+	"reflect"
 	"testing"
 
 	"github.com/genchain/go-genchain/common"
@@ -58,3 +59,108 @@ func TestKeyEncryptDecrypt(t *testing.T) {
 		}
 	}
 }
+
+// Tests that keys encrypted with any of the supported KDFs can be decrypted, and
+// that the KDFs are recovered from the key files.
+func TestKeyEncryptDecryptKDFs(t *testing.T) {
+	keyjson, err := ioutil.ReadFile("testdata/very-light-scrypt.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := DecryptKey(keyjson, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kdfs := []KDF{
+		NewScryptKDF(veryLightScryptN, veryLightScryptP),
+		&PBKDF2KDF{C: 2},
+		NewArgon2idKDF(1, 64),
+	}
+	for _, kdf := range kdfs {
+		keyjson, err := EncryptKeyWithKDF(key, "pass", kdf)
+		if err != nil {
+			t.Fatalf("%s: failed to encrypt key: %v", kdf.Name(), err)
+		}
+		if _, err := DecryptKey(keyjson, "bad"); err != ErrDecrypt {
+			t.Errorf("%s: bad password error mismatch: have %v, want %v", kdf.Name(), err, ErrDecrypt)
+		}
+		dec, err := DecryptKey(keyjson, "pass")
+		if err != nil {
+			t.Fatalf("%s: failed to decrypt key: %v", kdf.Name(), err)
+		}
+		if dec.Address != key.Address || dec.PrivateKey.D.Cmp(key.PrivateKey.D) != 0 {
+			t.Errorf("%s: decrypted key mismatch", kdf.Name())
+		}
+		have, err := KeyFileKDF(keyjson)
+		if err != nil {
+			t.Fatalf("%s: failed to read KDF: %v", kdf.Name(), err)
+		}
+		if !reflect.DeepEqual(have, kdf) {
+			t.Errorf("%s: KDF mismatch: have %+v, want %+v", kdf.Name(), have, kdf)
+		}
+		if !have.Weak() {
+			t.Errorf("%s: very light KDF not weak", kdf.Name())
+		}
+	}
+	for _, kdf := range []KDF{NewScryptKDF(StandardScryptN, StandardScryptP), &PBKDF2KDF{C: StandardPBKDF2C}, NewArgon2idKDF(StandardArgon2Time, StandardArgon2Memory)} {
+		if kdf.Weak() {
+			t.Errorf("%s: standard KDF weak", kdf.Name())
+		}
+	}
+	// Argon2id costs beyond the bounds are rejected before deriving any key
+	for _, params := range []string{
+		`{"t":1,"m":4294967295,"p":1,"v":19}`,
+		`{"t":4294967295,"m":64,"p":1,"v":19}`,
+		`{"t":1,"m":64,"p":16,"v":19}`,
+		`{"t":0,"m":64,"p":1,"v":19}`,
+	} {
+		if _, err := KeyFileKDF([]byte(`{"crypto":{"kdf":"argon2id","kdfparams":` + params + `}}`)); err == nil {
+			t.Errorf("invalid Argon2id parameters %s decoded", params)
+		}
+	}
+	// So are scrypt costs beyond the bounds
+	for _, params := range []string{
+		`{"n":2147483648,"r":8,"p":1}`,
+		`{"n":262144,"r":2147483647,"p":1}`,
+		`{"n":262144,"r":8,"p":17}`,
+		`{"n":33554432,"r":8,"p":1}`,
+	} {
+		if _, err := KeyFileKDF([]byte(`{"crypto":{"kdf":"scrypt","kdfparams":` + params + `}}`)); err == nil {
+			t.Errorf("invalid scrypt parameters %s decoded", params)
+		}
+	}
+	// Missing or malformed parameters are rejected rather than panicking
+	for _, crypto := range []string{
+		`"kdf":"scrypt","kdfparams":{}`,
+		`"kdf":"scrypt","kdfparams":{"n":262144,"r":"8","p":1}`,
+		`"kdf":"scrypt","kdfparams":{"n":262144.5,"r":8,"p":1}`,
+		`"kdf":"pbkdf2","kdfparams":{"c":262144}`,
+		`"kdf":"pbkdf2","kdfparams":{"c":-1,"prf":"hmac-sha256"}`,
+		`"kdf":"argon2id","kdfparams":{"t":1,"m":64,"p":1}`,
+		`"kdf":"argon2id","kdfparams":{"m":64,"p":1,"v":19}`,
+	} {
+		if _, err := KeyFileKDF([]byte(`{"crypto":{` + crypto + `}}`)); err == nil {
+			t.Errorf("malformed KDF parameters %s decoded", crypto)
+		}
+	}
+	// Derived keys are always split into a 16 byte encryption and MAC key
+	for _, dkLen := range []float64{16, 31, 33, 1 << 30} {
+		params := map[string]interface{}{"n": 2.0, "r": 8.0, "p": 1.0, "salt": "00", "dklen": dkLen}
+		if _, err := getKDFKey(CryptoJSON{KDF: "scrypt", KDFParams: params}, ""); err == nil {
+			t.Errorf("invalid derived key length %v accepted", dkLen)
+		}
+	}
+	// Unknown KDFs can be plugged in
+	if _, err := KeyFileKDF([]byte(`{"crypto":{"kdf":"test","kdfparams":{}}}`)); err == nil {
+		t.Errorf("unknown KDF decoded")
+	}
+	RegisterKDF("test", func(map[string]interface{}) (KDF, error) { return &PBKDF2KDF{C: 1}, nil })
+	defer func() {
+		kdfLock.Lock()
+		delete(kdfDecoders, "test")
+		kdfLock.Unlock()
+	}()
+	if _, err := KeyFileKDF([]byte(`{"crypto":{"kdf":"test","kdfparams":{}}}`)); err != nil {
+		t.Errorf("failed to decode registered KDF: %v", err)
+	}
+}
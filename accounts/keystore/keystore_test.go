@@ -17,9 +17,11 @@
 package keystore
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"reflect"
 	"runtime"
 	"sort"
 	"strings"
@@ -385,3 +387,80 @@ func tmpKeyStore(t *testing.T, encrypted bool) (string, *KeyStore) {
 	}
 	return d, new(d)
 }
+
+// testKDF is a cheap KDF claiming to be strong.
+type testKDF struct {
+	PBKDF2KDF
+}
+
+func (kdf *testKDF) Name() string { return "test" }
+func (kdf *testKDF) Weak() bool   { return false }
+
+// Tests that keys can be re-encrypted with other KDFs, and that the keys with
+// weak KDFs are reported.
+func TestRekey(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	pass := "passwd"
+	weak, err := ks.NewAccount(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strong, err := ks.NewAccount(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Rekey(weak, "bad", NewArgon2idKDF(1, 64)); err != ErrDecrypt {
+		t.Errorf("rekey with bad passphrase error mismatch: have %v, want %v", err, ErrDecrypt)
+	}
+	if err := ks.Rekey(weak, pass, NewArgon2idKDF(1, 64)); err != nil {
+		t.Fatalf("failed to rekey: %v", err)
+	}
+	if kdf, err := ks.KDF(weak); err != nil || !reflect.DeepEqual(kdf, NewArgon2idKDF(1, 64)) {
+		t.Errorf("rekeyed KDF mismatch: have %+v (%v)", kdf, err)
+	}
+	if _, err := ks.SignHashWithPassphrase(weak, pass, testSigData); err != nil {
+		t.Errorf("failed to sign with rekeyed account: %v", err)
+	}
+	// Rekey the other account with a KDF deemed strong, and check the report
+	RegisterKDF("test", func(map[string]interface{}) (KDF, error) { return &testKDF{PBKDF2KDF{C: 1}}, nil })
+	defer func() {
+		kdfLock.Lock()
+		delete(kdfDecoders, "test")
+		kdfLock.Unlock()
+	}()
+	if err := ks.Rekey(strong, pass, &testKDF{PBKDF2KDF{C: 1}}); err != nil {
+		t.Fatalf("failed to rekey: %v", err)
+	}
+	// Key files with unreadable KDFs are skipped in the report
+	broken, err := ks.NewAccount(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyjson, err := ioutil.ReadFile(broken.URL.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var key map[string]interface{}
+	if err := json.Unmarshal(keyjson, &key); err != nil {
+		t.Fatal(err)
+	}
+	delete(key["crypto"].(map[string]interface{})["kdfparams"].(map[string]interface{}), "n")
+	if keyjson, err = json.Marshal(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(broken.URL.Path, keyjson, 0600); err != nil {
+		t.Fatal(err)
+	}
+	accs, err := ks.WeakAccounts()
+	if err != nil {
+		t.Fatalf("failed to list weak accounts: %v", err)
+	}
+	if len(accs) != 1 || accs[0].Address != weak.Address {
+		t.Errorf("weak accounts mismatch: have %v, want [%x]", accs, weak.Address)
+	}
+	if _, err := ks.SignHashWithPassphrase(strong, pass, testSigData); err != nil {
+		t.Errorf("failed to sign with rekeyed account: %v", err)
+	}
+}
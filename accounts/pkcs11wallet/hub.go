@@ -0,0 +1,201 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pkcs11wallet implements support for the secp256k1 keys of hardware
+// security modules and other PKCS#11 tokens.
+package pkcs11wallet
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/genchain/go-genchain/accounts"
+	"github.com/genchain/go-genchain/event"
+	"github.com/genchain/go-genchain/log"
+)
+
+// Scheme is the protocol scheme prefixing account and wallet URLs.
+const Scheme = "pkcs11"
+
+// HubType is the reflect type of a PKCS#11 backend.
+var HubType = reflect.TypeOf(&Hub{})
+
+// refreshCycle is the maximum time between wallet refreshes, to notice tokens
+// being inserted or removed.
+const refreshCycle = 3 * time.Second
+
+// refreshThrottling is the minimum time between wallet refreshes to avoid
+// querying the module like crazy.
+const refreshThrottling = time.Second
+
+// Hub is an accounts.Backend exposing the tokens of a PKCS#11 module as wallets,
+// with the secp256k1 keys on each token as accounts.
+type Hub struct {
+	module module // PKCS#11 module the tokens are accessed through
+
+	refreshed   time.Time               // Time instance when the list of wallets was last refreshed
+	wallets     []accounts.Wallet       // List of tokens currently tracking, sorted by URL
+	updateFeed  event.Feed              // Event feed to notify wallet additions/removals
+	updateScope event.SubscriptionScope // Subscription scope tracking current live listeners
+	updating    bool                    // Whether the event notification loop is running
+
+	stateLock sync.RWMutex // Protects the internals of the hub from racey access
+}
+
+// NewHub creates a wallet backend for the tokens of the PKCS#11 module (shared
+// library) at the given path.
+func NewHub(path string) (*Hub, error) {
+	mod, err := newModule(path)
+	if err != nil {
+		return nil, err
+	}
+	return newHub(mod), nil
+}
+
+// newHub creates a wallet backend for the tokens of a PKCS#11 module.
+func newHub(mod module) *Hub {
+	hub := &Hub{module: mod}
+	hub.refreshWallets()
+	return hub
+}
+
+// Wallets implements accounts.Backend, returning all the tokens currently
+// present in the slots of the module.
+func (hub *Hub) Wallets() []accounts.Wallet {
+	// Make sure the list of wallets is up to date
+	hub.refreshWallets()
+
+	hub.stateLock.RLock()
+	defer hub.stateLock.RUnlock()
+
+	cpy := make([]accounts.Wallet, len(hub.wallets))
+	copy(cpy, hub.wallets)
+	return cpy
+}
+
+// refreshWallets lists the tokens of the module and updates the list of wallets
+// based on the found tokens.
+func (hub *Hub) refreshWallets() {
+	hub.stateLock.RLock()
+	elapsed := time.Since(hub.refreshed)
+	hub.stateLock.RUnlock()
+
+	if elapsed < refreshThrottling {
+		return
+	}
+	tokens, err := hub.module.tokens()
+	if err != nil {
+		log.Warn("Failed to list PKCS#11 tokens", "err", err)
+		return
+	}
+	hub.stateLock.Lock()
+
+	known := make(map[accounts.URL]*wallet)
+	for _, w := range hub.wallets {
+		known[w.URL()] = w.(*wallet)
+	}
+	var (
+		wallets = make([]accounts.Wallet, 0, len(tokens))
+		events  []accounts.WalletEvent
+	)
+	for _, token := range tokens {
+		url := tokenURL(token)
+
+		// Keep the wallets of the tokens still in the same slot
+		if w, ok := known[url]; ok && w.token.slot == token.slot {
+			wallets = append(wallets, w)
+			delete(known, url)
+			continue
+		}
+		w, err := newWallet(hub, token, url)
+		if err != nil {
+			log.Warn("Failed to list PKCS#11 token keys", "url", url, "err", err)
+			continue
+		}
+		wallets = append(wallets, w)
+		events = append(events, accounts.WalletEvent{Wallet: w, Kind: accounts.WalletArrived})
+	}
+	// Drop the wallets of the removed tokens
+	dropped := make([]*wallet, 0, len(known))
+	for _, w := range known {
+		dropped = append(dropped, w)
+		events = append(events, accounts.WalletEvent{Wallet: w, Kind: accounts.WalletDropped})
+	}
+	sort.Slice(wallets, func(i, j int) bool { return wallets[i].URL().Cmp(wallets[j].URL()) < 0 })
+
+	hub.refreshed = time.Now()
+	hub.wallets = wallets
+	hub.stateLock.Unlock()
+
+	// Close the sessions of the dropped wallets, if they were open
+	for _, w := range dropped {
+		w.Close()
+	}
+	// Fire all wallet events and return
+	for _, event := range events {
+		hub.updateFeed.Send(event)
+	}
+}
+
+// tokenURL returns the URL of the wallet of a token, identified by its serial
+// number, or by its slot if it has none.
+func tokenURL(token tokenInfo) accounts.URL {
+	if token.serial == "" {
+		return accounts.URL{Scheme: Scheme, Path: fmt.Sprintf("slot-%d", token.slot)}
+	}
+	return accounts.URL{Scheme: Scheme, Path: token.serial}
+}
+
+// Subscribe implements accounts.Backend, creating an async subscription to
+// receive notifications on the addition or removal of tokens.
+func (hub *Hub) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	// We need the mutex to reliably start/stop the update loop
+	hub.stateLock.Lock()
+	defer hub.stateLock.Unlock()
+
+	// Subscribe the caller and track the subscriber count
+	sub := hub.updateScope.Track(hub.updateFeed.Subscribe(sink))
+
+	// Subscribers require an active notification loop, start it
+	if !hub.updating {
+		hub.updating = true
+		go hub.updater()
+	}
+	return sub
+}
+
+// updater is responsible for maintaining an up-to-date list of wallets managed
+// by the hub, and for firing wallet addition/removal events.
+func (hub *Hub) updater() {
+	for {
+		time.Sleep(refreshCycle)
+
+		// Run the wallet refresher
+		hub.refreshWallets()
+
+		// If all our subscribers left, stop the updater
+		hub.stateLock.Lock()
+		if hub.updateScope.Count() == 0 {
+			hub.updating = false
+			hub.stateLock.Unlock()
+			return
+		}
+		hub.stateLock.Unlock()
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package pkcs11wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+
+	"github.com/genchain/go-genchain/crypto"
+)
+
+// PKCS#11 return values handled explicitly.
+const (
+	ckrOK                         = 0x000
+	ckrPINIncorrect               = 0x0a0
+	ckrUserAlreadyLoggedIn        = 0x100
+	ckrCryptokiAlreadyInitialized = 0x191
+)
+
+// errorNames are the names of the common PKCS#11 return values.
+var errorNames = map[Error]string{
+	0x005: "CKR_GENERAL_ERROR",
+	0x006: "CKR_FUNCTION_FAILED",
+	0x030: "CKR_DEVICE_ERROR",
+	0x032: "CKR_DEVICE_REMOVED",
+	0x054: "CKR_FUNCTION_NOT_SUPPORTED",
+	0x068: "CKR_KEY_FUNCTION_NOT_PERMITTED",
+	0x070: "CKR_MECHANISM_INVALID",
+	0x0a4: "CKR_PIN_LOCKED",
+	0x0b3: "CKR_SESSION_HANDLE_INVALID",
+	0x0e0: "CKR_TOKEN_NOT_PRESENT",
+	0x101: "CKR_USER_NOT_LOGGED_IN",
+}
+
+// secp256k1OID is the DER encoded object identifier of the secp256k1 curve, as
+// found in the CKA_EC_PARAMS attribute of the keys.
+var secp256k1OID = []byte{0x06, 0x05, 0x2b, 0x81, 0x04, 0x00, 0x0a}
+
+// ErrPINIncorrect is returned when logging into a token with a wrong PIN.
+var ErrPINIncorrect = errors.New("pkcs11: incorrect PIN")
+
+// Error is a PKCS#11 return value signalling a failure.
+type Error uint
+
+func (e Error) Error() string {
+	if name, ok := errorNames[e]; ok {
+		return "pkcs11: " + name
+	}
+	return fmt.Sprintf("pkcs11: error 0x%x", uint(e))
+}
+
+// module is the subset of a PKCS#11 module (cryptoki library) needed to sign
+// with the secp256k1 keys of its tokens.
+type module interface {
+	// tokens returns the slots holding a token.
+	tokens() ([]tokenInfo, error)
+
+	// openSession opens a read-only session with the token in a slot.
+	openSession(slot uint) (session, error)
+}
+
+// tokenInfo identifies a token and the slot it is in.
+type tokenInfo struct {
+	slot   uint
+	label  string
+	serial string
+}
+
+// session is a session with a token.
+type session interface {
+	// login logs the normal user into the token.
+	login(pin string) error
+
+	// logout logs the user out of the token.
+	logout() error
+
+	// publicKeys returns the secp256k1 public keys on the token.
+	publicKeys() ([]tokenKey, error)
+
+	// sign signs the hash with CKM_ECDSA, using the private key with the given
+	// CKA_ID. The signature is the concatenation of r and s.
+	sign(id, hash []byte) ([]byte, error)
+
+	// close closes the session.
+	close() error
+}
+
+// tokenKey is a secp256k1 key on a token.
+type tokenKey struct {
+	id     []byte // CKA_ID shared by the public and private key objects
+	label  string // CKA_LABEL of the public key object
+	public *ecdsa.PublicKey
+}
+
+// parseECPoint parses the CKA_EC_POINT attribute of a secp256k1 public key. The
+// standard wraps the uncompressed point into a DER octet string, but some modules
+// return the raw point.
+func parseECPoint(point []byte) (*ecdsa.PublicKey, error) {
+	if len(point) == 67 && point[0] == 0x04 && point[1] == 65 {
+		point = point[2:]
+	}
+	if len(point) != 65 || point[0] != 0x04 {
+		return nil, errors.New("pkcs11: unsupported EC point encoding")
+	}
+	pub := crypto.ToECDSAPub(point)
+	if pub.X == nil {
+		return nil, errors.New("pkcs11: invalid secp256k1 public key")
+	}
+	return pub, nil
+}
+
+// isSecp256k1 reports whether the CKA_EC_PARAMS attribute of a key names the
+// secp256k1 curve.
+func isSecp256k1(params []byte) bool {
+	return bytes.Equal(params, secp256k1OID)
+}
@@ -0,0 +1,484 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build cgo,!windows
+
+package pkcs11wallet
+
+/*
+#cgo linux LDFLAGS: -ldl
+
+#include <dlfcn.h>
+#include <stdlib.h>
+#include <string.h>
+
+// The subset of the PKCS#11 v2.20 types used, with the default alignment of the
+// Unix platforms.
+typedef unsigned long CK_ULONG;
+typedef unsigned char CK_BYTE;
+typedef CK_ULONG      CK_RV;
+
+typedef struct {
+	CK_ULONG type;
+	void    *pValue;
+	CK_ULONG ulValueLen;
+} CK_ATTRIBUTE;
+
+typedef struct {
+	CK_ULONG mechanism;
+	void    *pParameter;
+	CK_ULONG ulParameterLen;
+} CK_MECHANISM;
+
+typedef struct {
+	CK_BYTE major;
+	CK_BYTE minor;
+} CK_VERSION;
+
+typedef struct {
+	CK_BYTE    label[32];
+	CK_BYTE    manufacturerID[32];
+	CK_BYTE    model[16];
+	CK_BYTE    serialNumber[16];
+	CK_ULONG   flags;
+	CK_ULONG   ulMaxSessionCount;
+	CK_ULONG   ulSessionCount;
+	CK_ULONG   ulMaxRwSessionCount;
+	CK_ULONG   ulRwSessionCount;
+	CK_ULONG   ulMaxPinLen;
+	CK_ULONG   ulMinPinLen;
+	CK_ULONG   ulTotalPublicMemory;
+	CK_ULONG   ulFreePublicMemory;
+	CK_ULONG   ulTotalPrivateMemory;
+	CK_ULONG   ulFreePrivateMemory;
+	CK_VERSION hardwareVersion;
+	CK_VERSION firmwareVersion;
+	CK_BYTE    utcTime[16];
+} CK_TOKEN_INFO;
+
+typedef struct {
+	void    *CreateMutex;
+	void    *DestroyMutex;
+	void    *LockMutex;
+	void    *UnlockMutex;
+	CK_ULONG flags;
+	void    *pReserved;
+} CK_C_INITIALIZE_ARGS;
+
+#define CKF_OS_LOCKING_OK  0x2
+#define CKF_SERIAL_SESSION 0x4
+#define CKU_USER           1
+#define CKM_ECDSA          0x1041
+
+// pkcs11_module holds the entry points of a loaded PKCS#11 module.
+typedef struct {
+	void *handle;
+
+	CK_RV (*C_Initialize)(void *);
+	CK_RV (*C_GetSlotList)(CK_BYTE, CK_ULONG *, CK_ULONG *);
+	CK_RV (*C_GetTokenInfo)(CK_ULONG, CK_TOKEN_INFO *);
+	CK_RV (*C_OpenSession)(CK_ULONG, CK_ULONG, void *, void *, CK_ULONG *);
+	CK_RV (*C_CloseSession)(CK_ULONG);
+	CK_RV (*C_Login)(CK_ULONG, CK_ULONG, CK_BYTE *, CK_ULONG);
+	CK_RV (*C_Logout)(CK_ULONG);
+	CK_RV (*C_FindObjectsInit)(CK_ULONG, CK_ATTRIBUTE *, CK_ULONG);
+	CK_RV (*C_FindObjects)(CK_ULONG, CK_ULONG *, CK_ULONG, CK_ULONG *);
+	CK_RV (*C_FindObjectsFinal)(CK_ULONG);
+	CK_RV (*C_GetAttributeValue)(CK_ULONG, CK_ULONG, CK_ATTRIBUTE *, CK_ULONG);
+	CK_RV (*C_SignInit)(CK_ULONG, CK_MECHANISM *, CK_ULONG);
+	CK_RV (*C_Sign)(CK_ULONG, CK_BYTE *, CK_ULONG, CK_BYTE *, CK_ULONG *);
+} pkcs11_module;
+
+#define PKCS11_LOAD(m, name) \
+	if ((*(void **)(&m->name) = dlsym(m->handle, #name)) == NULL) { \
+		dlclose(m->handle); \
+		return "missing " #name; \
+	}
+
+// pkcs11_load loads the module at the given path, returning an error message on
+// failure.
+static const char *pkcs11_load(pkcs11_module *m, const char *path) {
+	if ((m->handle = dlopen(path, RTLD_NOW)) == NULL) {
+		return dlerror();
+	}
+	PKCS11_LOAD(m, C_Initialize);
+	PKCS11_LOAD(m, C_GetSlotList);
+	PKCS11_LOAD(m, C_GetTokenInfo);
+	PKCS11_LOAD(m, C_OpenSession);
+	PKCS11_LOAD(m, C_CloseSession);
+	PKCS11_LOAD(m, C_Login);
+	PKCS11_LOAD(m, C_Logout);
+	PKCS11_LOAD(m, C_FindObjectsInit);
+	PKCS11_LOAD(m, C_FindObjects);
+	PKCS11_LOAD(m, C_FindObjectsFinal);
+	PKCS11_LOAD(m, C_GetAttributeValue);
+	PKCS11_LOAD(m, C_SignInit);
+	PKCS11_LOAD(m, C_Sign);
+	return NULL;
+}
+
+static CK_RV pkcs11_initialize(pkcs11_module *m) {
+	CK_C_INITIALIZE_ARGS args;
+	memset(&args, 0, sizeof(args));
+	args.flags = CKF_OS_LOCKING_OK;
+	return m->C_Initialize(&args);
+}
+
+static CK_RV pkcs11_get_slot_list(pkcs11_module *m, CK_ULONG *slots, CK_ULONG *count) {
+	return m->C_GetSlotList(1, slots, count);
+}
+
+static CK_RV pkcs11_get_token_info(pkcs11_module *m, CK_ULONG slot, CK_TOKEN_INFO *info) {
+	return m->C_GetTokenInfo(slot, info);
+}
+
+static CK_RV pkcs11_open_session(pkcs11_module *m, CK_ULONG slot, CK_ULONG *session) {
+	return m->C_OpenSession(slot, CKF_SERIAL_SESSION, NULL, NULL, session);
+}
+
+static CK_RV pkcs11_close_session(pkcs11_module *m, CK_ULONG session) {
+	return m->C_CloseSession(session);
+}
+
+static CK_RV pkcs11_login(pkcs11_module *m, CK_ULONG session, CK_BYTE *pin, CK_ULONG pinLen) {
+	return m->C_Login(session, CKU_USER, pin, pinLen);
+}
+
+static CK_RV pkcs11_logout(pkcs11_module *m, CK_ULONG session) {
+	return m->C_Logout(session);
+}
+
+static CK_RV pkcs11_find_objects_init(pkcs11_module *m, CK_ULONG session, CK_ATTRIBUTE *tmpl, CK_ULONG count) {
+	return m->C_FindObjectsInit(session, tmpl, count);
+}
+
+static CK_RV pkcs11_find_objects(pkcs11_module *m, CK_ULONG session, CK_ULONG *objects, CK_ULONG max, CK_ULONG *count) {
+	return m->C_FindObjects(session, objects, max, count);
+}
+
+static CK_RV pkcs11_find_objects_final(pkcs11_module *m, CK_ULONG session) {
+	return m->C_FindObjectsFinal(session);
+}
+
+static CK_RV pkcs11_get_attribute_value(pkcs11_module *m, CK_ULONG session, CK_ULONG object, CK_ATTRIBUTE *tmpl, CK_ULONG count) {
+	return m->C_GetAttributeValue(session, object, tmpl, count);
+}
+
+static CK_RV pkcs11_sign(pkcs11_module *m, CK_ULONG session, CK_ULONG key, CK_BYTE *data, CK_ULONG dataLen, CK_BYTE *sig, CK_ULONG *sigLen) {
+	CK_MECHANISM mechanism = {CKM_ECDSA, NULL, 0};
+	CK_RV rv = m->C_SignInit(session, &mechanism, key);
+	if (rv != 0) {
+		return rv;
+	}
+	return m->C_Sign(session, data, dataLen, sig, sigLen);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/genchain/go-genchain/log"
+)
+
+// PKCS#11 attributes and their values used to find the keys.
+const (
+	ckaClass    = 0x000
+	ckaLabel    = 0x003
+	ckaKeyType  = 0x100
+	ckaID       = 0x102
+	ckaECParams = 0x180
+	ckaECPoint  = 0x181
+
+	ckoPublicKey  = 2
+	ckoPrivateKey = 3
+	ckkEC         = 3
+
+	ckrTokenNotPresent = 0x0e0
+)
+
+// ckUnavailableInformation is the length of the attributes that can't be read.
+const ckUnavailableInformation = ^C.CK_ULONG(0)
+
+var (
+	modules     = make(map[string]*cryptoki) // Modules loaded so far, by path
+	modulesLock sync.Mutex
+)
+
+// cryptoki is a PKCS#11 module loaded from a shared library.
+type cryptoki struct {
+	m *C.pkcs11_module // Entry points, in C memory
+}
+
+// newModule loads and initializes the PKCS#11 module at the given path. Modules
+// are loaded once and never finalized, as a module is shared by all its users in
+// the process.
+func newModule(path string) (module, error) {
+	modulesLock.Lock()
+	defer modulesLock.Unlock()
+
+	if mod, ok := modules[path]; ok {
+		return mod, nil
+	}
+	m := (*C.pkcs11_module)(C.calloc(1, C.sizeof_pkcs11_module))
+
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	if msg := C.pkcs11_load(m, cpath); msg != nil {
+		C.free(unsafe.Pointer(m))
+		return nil, fmt.Errorf("pkcs11: failed to load %s: %s", path, C.GoString(msg))
+	}
+	if rv := C.pkcs11_initialize(m); rv != ckrOK && rv != ckrCryptokiAlreadyInitialized {
+		return nil, Error(rv)
+	}
+	mod := &cryptoki{m: m}
+	modules[path] = mod
+	return mod, nil
+}
+
+// tokens implements module, returning the slots holding a token.
+func (mod *cryptoki) tokens() ([]tokenInfo, error) {
+	var count C.CK_ULONG
+	if rv := C.pkcs11_get_slot_list(mod.m, nil, &count); rv != ckrOK {
+		return nil, Error(rv)
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	slots := make([]C.CK_ULONG, count)
+	if rv := C.pkcs11_get_slot_list(mod.m, &slots[0], &count); rv != ckrOK {
+		return nil, Error(rv)
+	}
+	tokens := make([]tokenInfo, 0, count)
+	for _, slot := range slots[:count] {
+		var info C.CK_TOKEN_INFO
+		switch rv := C.pkcs11_get_token_info(mod.m, slot, &info); rv {
+		case ckrOK:
+		case ckrTokenNotPresent:
+			continue // Removed since listing the slots
+		default:
+			return nil, Error(rv)
+		}
+		tokens = append(tokens, tokenInfo{
+			slot:   uint(slot),
+			label:  paddedString(info.label[:]),
+			serial: paddedString(info.serialNumber[:]),
+		})
+	}
+	return tokens, nil
+}
+
+// openSession implements module, opening a read-only session with the token in
+// a slot.
+func (mod *cryptoki) openSession(slot uint) (session, error) {
+	var handle C.CK_ULONG
+	if rv := C.pkcs11_open_session(mod.m, C.CK_ULONG(slot), &handle); rv != ckrOK {
+		return nil, Error(rv)
+	}
+	return &cryptokiSession{m: mod.m, handle: handle}, nil
+}
+
+// cryptokiSession is a session with a token of a loaded PKCS#11 module.
+type cryptokiSession struct {
+	m      *C.pkcs11_module
+	handle C.CK_ULONG
+}
+
+// login implements session, logging the normal user into the token.
+func (s *cryptokiSession) login(pin string) error {
+	buf := []byte(pin)
+	if len(buf) == 0 {
+		buf = []byte{0}
+	}
+	switch rv := C.pkcs11_login(s.m, s.handle, (*C.CK_BYTE)(&buf[0]), C.CK_ULONG(len(pin))); rv {
+	case ckrOK, ckrUserAlreadyLoggedIn:
+		return nil
+	case ckrPINIncorrect:
+		return ErrPINIncorrect
+	default:
+		return Error(rv)
+	}
+}
+
+// logout implements session, logging the user out of the token.
+func (s *cryptokiSession) logout() error {
+	if rv := C.pkcs11_logout(s.m, s.handle); rv != ckrOK {
+		return Error(rv)
+	}
+	return nil
+}
+
+// close implements session, closing the session.
+func (s *cryptokiSession) close() error {
+	if rv := C.pkcs11_close_session(s.m, s.handle); rv != ckrOK {
+		return Error(rv)
+	}
+	return nil
+}
+
+// publicKeys implements session, returning the secp256k1 public keys visible in
+// the session.
+func (s *cryptokiSession) publicKeys() ([]tokenKey, error) {
+	objects, err := s.findObjects(
+		attribute{ckaClass, ulongValue(ckoPublicKey)},
+		attribute{ckaKeyType, ulongValue(ckkEC)},
+	)
+	if err != nil {
+		return nil, err
+	}
+	var keys []tokenKey
+	for _, object := range objects {
+		values, err := s.attributes(object, ckaID, ckaLabel, ckaECParams, ckaECPoint)
+		if err != nil {
+			return nil, err
+		}
+		if !isSecp256k1(values[2]) {
+			continue
+		}
+		pub, err := parseECPoint(values[3])
+		if err != nil {
+			log.Warn("Skipping invalid PKCS#11 key", "label", string(values[1]), "err", err)
+			continue
+		}
+		keys = append(keys, tokenKey{id: values[0], label: string(values[1]), public: pub})
+	}
+	return keys, nil
+}
+
+// sign implements session, signing the hash with CKM_ECDSA using the private key
+// with the given CKA_ID.
+func (s *cryptokiSession) sign(id, hash []byte) ([]byte, error) {
+	objects, err := s.findObjects(
+		attribute{ckaClass, ulongValue(ckoPrivateKey)},
+		attribute{ckaKeyType, ulongValue(ckkEC)},
+		attribute{ckaID, id},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, errors.New("pkcs11: private key not found")
+	}
+	var (
+		sig    [128]byte
+		sigLen = C.CK_ULONG(len(sig))
+	)
+	if rv := C.pkcs11_sign(s.m, s.handle, objects[0], (*C.CK_BYTE)(&hash[0]), C.CK_ULONG(len(hash)), (*C.CK_BYTE)(&sig[0]), &sigLen); rv != ckrOK {
+		return nil, Error(rv)
+	}
+	return append([]byte(nil), sig[:sigLen]...), nil
+}
+
+// attribute is an attribute of a search template.
+type attribute struct {
+	typ   C.CK_ULONG
+	value []byte
+}
+
+// findObjects returns the objects matching the template.
+func (s *cryptokiSession) findObjects(attrs ...attribute) ([]C.CK_ULONG, error) {
+	tmpl, free := newTemplate(len(attrs))
+	defer free()
+
+	for i, attr := range attrs {
+		tmpl[i]._type = attr.typ
+		tmpl[i].pValue = C.CBytes(attr.value)
+		tmpl[i].ulValueLen = C.CK_ULONG(len(attr.value))
+	}
+	if rv := C.pkcs11_find_objects_init(s.m, s.handle, &tmpl[0], C.CK_ULONG(len(tmpl))); rv != ckrOK {
+		return nil, Error(rv)
+	}
+	defer C.pkcs11_find_objects_final(s.m, s.handle)
+
+	var (
+		objects []C.CK_ULONG
+		batch   [16]C.CK_ULONG
+	)
+	for {
+		var count C.CK_ULONG
+		if rv := C.pkcs11_find_objects(s.m, s.handle, &batch[0], C.CK_ULONG(len(batch)), &count); rv != ckrOK {
+			return nil, Error(rv)
+		}
+		if count == 0 {
+			return objects, nil
+		}
+		objects = append(objects, batch[:count]...)
+	}
+}
+
+// attributes returns the values of the given attributes of an object.
+func (s *cryptokiSession) attributes(object C.CK_ULONG, types ...C.CK_ULONG) ([][]byte, error) {
+	tmpl, free := newTemplate(len(types))
+	defer free()
+
+	// Retrieve the lengths of the values first, then the values themselves
+	for i, typ := range types {
+		tmpl[i]._type = typ
+	}
+	if rv := C.pkcs11_get_attribute_value(s.m, s.handle, object, &tmpl[0], C.CK_ULONG(len(tmpl))); rv != ckrOK {
+		return nil, Error(rv)
+	}
+	for i := range tmpl {
+		if tmpl[i].ulValueLen == ckUnavailableInformation {
+			return nil, fmt.Errorf("pkcs11: attribute 0x%x unavailable", uint(types[i]))
+		}
+		tmpl[i].pValue = C.malloc(C.size_t(tmpl[i].ulValueLen) + 1)
+	}
+	if rv := C.pkcs11_get_attribute_value(s.m, s.handle, object, &tmpl[0], C.CK_ULONG(len(tmpl))); rv != ckrOK {
+		return nil, Error(rv)
+	}
+	values := make([][]byte, len(tmpl))
+	for i := range tmpl {
+		values[i] = C.GoBytes(tmpl[i].pValue, C.int(tmpl[i].ulValueLen))
+	}
+	return values, nil
+}
+
+// newTemplate allocates an attribute template in C memory, as the cgo pointer
+// rules forbid passing Go memory holding the Go pointers of the values. The
+// returned function frees the template along with its values.
+func newTemplate(n int) ([]C.CK_ATTRIBUTE, func()) {
+	ptr := C.calloc(C.size_t(n), C.sizeof_CK_ATTRIBUTE)
+	tmpl := (*[1 << 20]C.CK_ATTRIBUTE)(ptr)[:n:n]
+
+	return tmpl, func() {
+		for i := range tmpl {
+			C.free(tmpl[i].pValue)
+		}
+		C.free(ptr)
+	}
+}
+
+// ulongValue encodes a CK_ULONG attribute value.
+func ulongValue(v C.CK_ULONG) []byte {
+	buf := make([]byte, C.sizeof_CK_ULONG)
+	*(*C.CK_ULONG)(unsafe.Pointer(&buf[0])) = v
+	return buf
+}
+
+// paddedString converts a blank padded string of the token infos.
+func paddedString(chars []C.CK_BYTE) string {
+	buf := make([]byte, len(chars))
+	for i, c := range chars {
+		buf[i] = byte(c)
+	}
+	return strings.TrimRight(string(buf), " \x00")
+}
@@ -0,0 +1,27 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build !cgo windows
+
+package pkcs11wallet
+
+import "errors"
+
+// newModule is unsupported without cgo, as PKCS#11 modules are shared libraries
+// with a C interface.
+func newModule(path string) (module, error) {
+	return nil, errors.New("pkcs11: unsupported platform")
+}
@@ -0,0 +1,317 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package pkcs11wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	genchain "github.com/genchain/go-genchain"
+	"github.com/genchain/go-genchain/accounts"
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/math"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/crypto"
+)
+
+var (
+	secp256k1N     = crypto.S256().Params().N
+	secp256k1halfN = new(big.Int).Div(secp256k1N, big.NewInt(2))
+)
+
+// wallet implements accounts.Wallet for a token, with its secp256k1 keys as the
+// accounts. The PIN of the token is the passphrase of the wallet.
+type wallet struct {
+	hub   *Hub
+	token tokenInfo    // Slot and identity of the token
+	url   accounts.URL // Textual URL uniquely identifying this wallet
+
+	accounts []accounts.Account          // Accounts of the keys on the token
+	keys     map[common.Address]tokenKey // Keys on the token by account address
+	session  session                     // Logged in session while the wallet is open
+	pinSalt  []byte                      // Random salt of the PIN hash while the wallet is open
+	pinHash  []byte                      // Salted hash of the PIN the wallet was opened with
+
+	lock sync.RWMutex
+}
+
+// newWallet creates the wallet of a token, listing the public keys readable
+// without logging in.
+func newWallet(hub *Hub, token tokenInfo, url accounts.URL) (*wallet, error) {
+	w := &wallet{hub: hub, token: token, url: url}
+
+	s, err := hub.module.openSession(token.slot)
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+
+	if err := w.loadKeys(s); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// loadKeys replaces the accounts of the wallet with the keys visible in the
+// session.
+func (w *wallet) loadKeys(s session) error {
+	keys, err := s.publicKeys()
+	if err != nil {
+		return err
+	}
+	w.accounts = make([]accounts.Account, 0, len(keys))
+	w.keys = make(map[common.Address]tokenKey, len(keys))
+
+	for _, key := range keys {
+		address := crypto.PubkeyToAddress(*key.public)
+		if _, ok := w.keys[address]; ok {
+			continue // Public key object duplicated on the token
+		}
+		w.keys[address] = key
+		w.accounts = append(w.accounts, accounts.Account{
+			Address: address,
+			URL:     accounts.URL{Scheme: w.url.Scheme, Path: fmt.Sprintf("%s/%x", w.url.Path, key.id)},
+		})
+	}
+	return nil
+}
+
+// URL implements accounts.Wallet, returning the URL of the token.
+func (w *wallet) URL() accounts.URL {
+	return w.url
+}
+
+// Status implements accounts.Wallet, returning whether the user is logged into
+// the token or not.
+func (w *wallet) Status() (string, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	if w.session != nil {
+		return "Open", nil
+	}
+	return "Closed", nil
+}
+
+// Open implements accounts.Wallet, logging into the token with the passphrase as
+// the PIN and keeping the session until the wallet is closed.
+func (w *wallet) Open(passphrase string) error {
+	w.lock.Lock()
+	if w.session != nil {
+		w.lock.Unlock()
+		return accounts.ErrWalletAlreadyOpen
+	}
+	s, err := w.login(passphrase)
+	if err != nil {
+		w.lock.Unlock()
+		return err
+	}
+	// Some tokens only expose the public keys to logged in users
+	if err := w.loadKeys(s); err != nil {
+		w.lock.Unlock()
+		s.logout()
+		s.close()
+		return err
+	}
+	// Remember the PIN as a salted hash, to check the passphrases of the signing
+	// requests, as the token accepts any PIN while the user is logged in
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		w.lock.Unlock()
+		s.logout()
+		s.close()
+		return err
+	}
+	w.session, w.pinSalt, w.pinHash = s, salt, crypto.Keccak256(salt, []byte(passphrase))
+	w.lock.Unlock()
+
+	go w.hub.updateFeed.Send(accounts.WalletEvent{Wallet: w, Kind: accounts.WalletOpened})
+	return nil
+}
+
+// Close implements accounts.Wallet, logging out of the token.
+func (w *wallet) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.session == nil {
+		return nil
+	}
+	s := w.session
+	w.session, w.pinSalt, w.pinHash = nil, nil, nil
+
+	s.logout()
+	return s.close()
+}
+
+// login opens a session with the token and logs into it with the PIN.
+func (w *wallet) login(pin string) (session, error) {
+	s, err := w.hub.module.openSession(w.token.slot)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.login(pin); err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Accounts implements accounts.Wallet, returning the list of keys on the token.
+func (w *wallet) Accounts() []accounts.Account {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	cpy := make([]accounts.Account, len(w.accounts))
+	copy(cpy, w.accounts)
+	return cpy
+}
+
+// Contains implements accounts.Wallet, returning whether a particular account is
+// or is not a key on this token.
+func (w *wallet) Contains(account accounts.Account) bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	_, exists := w.keys[account.Address]
+	return exists
+}
+
+// Derive implements accounts.Wallet, but is a noop for PKCS#11 wallets since
+// the keys on the tokens are not hierarchical deterministic.
+func (w *wallet) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	return accounts.Account{}, accounts.ErrNotSupported
+}
+
+// SelfDerive implements accounts.Wallet, but is a noop for PKCS#11 wallets since
+// the keys on the tokens are not hierarchical deterministic.
+func (w *wallet) SelfDerive(base accounts.DerivationPath, chain genchain.ChainStateReader) {
+}
+
+// SignHash implements accounts.Wallet, signing the given hash on the token with
+// the given account, if the wallet is open.
+func (w *wallet) SignHash(account accounts.Account, hash []byte) ([]byte, error) {
+	return w.signHash(account, nil, hash)
+}
+
+// SignTx implements accounts.Wallet, signing the given transaction on the token
+// with the given account, if the wallet is open.
+func (w *wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return w.signTx(account, nil, tx, chainID)
+}
+
+// SignHashWithPassphrase implements accounts.Wallet, attempting to sign the
+// given hash with the given account, logging into the token with the passphrase
+// as the PIN for the duration of the signing if the wallet is closed.
+func (w *wallet) SignHashWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
+	return w.signHash(account, &passphrase, hash)
+}
+
+// SignTxWithPassphrase implements accounts.Wallet, attempting to sign the given
+// transaction with the given account, logging into the token with the passphrase
+// as the PIN for the duration of the signing if the wallet is closed.
+func (w *wallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return w.signTx(account, &passphrase, tx, chainID)
+}
+
+// signTx signs the transaction, with EIP155 replay protection if a chain id is
+// given.
+func (w *wallet) signTx(account accounts.Account, pin *string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	var signer types.Signer = types.HomesteadSigner{}
+	if chainID != nil {
+		signer = types.NewEIP155Signer(chainID)
+	}
+	sig, err := w.signHash(account, pin, signer.Hash(tx).Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithSignature(signer, sig)
+}
+
+// signHash signs the hash on the token, in the session of the open wallet, or in
+// a temporary session logged in with the PIN if the wallet is closed. Logging
+// out is global to the token, so the PIN is checked against the one the wallet
+// was opened with if the wallet is open.
+func (w *wallet) signHash(account accounts.Account, pin *string, hash []byte) ([]byte, error) {
+	if len(hash) != 32 {
+		return nil, fmt.Errorf("hash is required to be exactly 32 bytes (%d)", len(hash))
+	}
+	// The sessions are not safe for concurrent use, hold the write lock
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	key, ok := w.keys[account.Address]
+	if !ok {
+		return nil, accounts.ErrUnknownAccount
+	}
+	s := w.session
+	if s != nil && pin != nil {
+		if subtle.ConstantTimeCompare(crypto.Keccak256(w.pinSalt, []byte(*pin)), w.pinHash) != 1 {
+			return nil, ErrPINIncorrect
+		}
+	}
+	if s == nil {
+		if pin == nil {
+			return nil, accounts.ErrWalletClosed
+		}
+		var err error
+		if s, err = w.login(*pin); err != nil {
+			return nil, err
+		}
+		defer func() {
+			s.logout()
+			s.close()
+		}()
+	}
+	sig, err := s.sign(key.id, hash)
+	if err != nil {
+		return nil, err
+	}
+	return recoverableSignature(key.public, hash, sig)
+}
+
+// recoverableSignature converts the r||s signature of a token into the [R || S || V]
+// format of the recoverable signatures, in the lower half of the curve order as
+// required since Homestead.
+func recoverableSignature(pub *ecdsa.PublicKey, hash, sig []byte) ([]byte, error) {
+	if len(sig) != 64 {
+		return nil, fmt.Errorf("pkcs11: invalid signature length %d", len(sig))
+	}
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	if s.Cmp(secp256k1halfN) > 0 {
+		s.Sub(secp256k1N, s)
+	}
+	rec := make([]byte, 65)
+	math.ReadBits(r, rec[:32])
+	math.ReadBits(s, rec[32:64])
+
+	// Find the recovery id yielding the public key of the token
+	want := crypto.FromECDSAPub(pub)
+	for v := byte(0); v < 2; v++ {
+		rec[64] = v
+		if have, err := crypto.Ecrecover(hash, rec); err == nil && bytes.Equal(have, want) {
+			return rec, nil
+		}
+	}
+	return nil, errors.New("pkcs11: signature does not match the public key")
+}
@@ -0,0 +1,346 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package pkcs11wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/genchain/go-genchain/accounts"
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/crypto"
+)
+
+const testPIN = "1234"
+
+// testKey is a key on a test token.
+type testKey struct {
+	id      []byte
+	key     *ecdsa.PrivateKey
+	private bool // Whether the public key is only visible to logged in users
+}
+
+// testModule is an in-memory PKCS#11 module with a single token.
+type testModule struct {
+	token    tokenInfo
+	keys     []testKey
+	removed  bool // Whether the token was removed from its slot
+	sessions int  // Number of sessions currently open
+	signs    int  // Number of signatures made, to alternate their s values
+}
+
+func newTestModule(t *testing.T) *testModule {
+	mod := &testModule{token: tokenInfo{slot: 7, label: "validator", serial: "8d3f1a"}}
+	for i, private := range []bool{false, false, true} {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		mod.keys = append(mod.keys, testKey{id: []byte{byte(i + 1)}, key: key, private: private})
+	}
+	return mod
+}
+
+func (mod *testModule) tokens() ([]tokenInfo, error) {
+	if mod.removed {
+		return nil, nil
+	}
+	return []tokenInfo{mod.token}, nil
+}
+
+func (mod *testModule) openSession(slot uint) (session, error) {
+	if slot != mod.token.slot {
+		return nil, Error(0x003) // CKR_SLOT_ID_INVALID
+	}
+	mod.sessions++
+	return &testSession{mod: mod}, nil
+}
+
+type testSession struct {
+	mod      *testModule
+	loggedIn bool
+}
+
+func (s *testSession) login(pin string) error {
+	if pin != testPIN {
+		return ErrPINIncorrect
+	}
+	s.loggedIn = true
+	return nil
+}
+
+func (s *testSession) logout() error {
+	s.loggedIn = false
+	return nil
+}
+
+func (s *testSession) publicKeys() ([]tokenKey, error) {
+	var keys []tokenKey
+	for _, key := range s.mod.keys {
+		if !key.private || s.loggedIn {
+			keys = append(keys, tokenKey{id: key.id, public: &key.key.PublicKey})
+		}
+	}
+	return keys, nil
+}
+
+// sign signs like a token, without a recovery id and with any s value.
+func (s *testSession) sign(id, hash []byte) ([]byte, error) {
+	if !s.loggedIn {
+		return nil, Error(0x101) // CKR_USER_NOT_LOGGED_IN
+	}
+	for _, key := range s.mod.keys {
+		if bytes.Equal(key.id, id) {
+			sig, err := crypto.Sign(hash, key.key)
+			if err != nil {
+				return nil, err
+			}
+			if s.mod.signs++; s.mod.signs%2 == 0 {
+				high := new(big.Int).Sub(secp256k1N, new(big.Int).SetBytes(sig[32:64]))
+				copy(sig[32:64], common.LeftPadBytes(high.Bytes(), 32))
+			}
+			return sig[:64], nil
+		}
+	}
+	return nil, errors.New("private key not found")
+}
+
+func (s *testSession) close() error {
+	s.mod.sessions--
+	return nil
+}
+
+func newTestWallet(t *testing.T) (*testModule, accounts.Wallet) {
+	mod := newTestModule(t)
+	wallets := newHub(mod).Wallets()
+	if len(wallets) != 1 {
+		t.Fatalf("wallet count mismatch: have %d, want 1", len(wallets))
+	}
+	return mod, wallets[0]
+}
+
+// Tests that the tokens are listed as wallets, with their public keys as accounts.
+func TestWallets(t *testing.T) {
+	mod, wallet := newTestWallet(t)
+
+	if url := wallet.URL().String(); url != "pkcs11://8d3f1a" {
+		t.Errorf("wallet URL mismatch: have %s, want pkcs11://8d3f1a", url)
+	}
+	if status, _ := wallet.Status(); status != "Closed" {
+		t.Errorf("wallet status mismatch: have %s, want Closed", status)
+	}
+	accs := wallet.Accounts()
+	if len(accs) != 2 {
+		t.Fatalf("account count mismatch: have %d, want 2", len(accs))
+	}
+	for i, acc := range accs {
+		if want := crypto.PubkeyToAddress(mod.keys[i].key.PublicKey); acc.Address != want {
+			t.Errorf("account %d: address mismatch: have %x, want %x", i, acc.Address, want)
+		}
+		if !wallet.Contains(acc) {
+			t.Errorf("account %d: not contained in wallet", i)
+		}
+	}
+	if url := accs[1].URL.String(); url != "pkcs11://8d3f1a/02" {
+		t.Errorf("account URL mismatch: have %s, want pkcs11://8d3f1a/02", url)
+	}
+	// Opening the wallet checks the PIN and lists the private keys too
+	if err := wallet.Open("4321"); err != ErrPINIncorrect {
+		t.Fatalf("open with wrong PIN: have %v, want %v", err, ErrPINIncorrect)
+	}
+	if err := wallet.Open(testPIN); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	if status, _ := wallet.Status(); status != "Open" {
+		t.Errorf("wallet status mismatch: have %s, want Open", status)
+	}
+	if accs := wallet.Accounts(); len(accs) != 3 {
+		t.Errorf("open account count mismatch: have %d, want 3", len(accs))
+	}
+	if err := wallet.Open(testPIN); err != accounts.ErrWalletAlreadyOpen {
+		t.Errorf("reopen: have %v, want %v", err, accounts.ErrWalletAlreadyOpen)
+	}
+	if _, err := wallet.Derive(accounts.DefaultBaseDerivationPath, true); err != accounts.ErrNotSupported {
+		t.Errorf("derive: have %v, want %v", err, accounts.ErrNotSupported)
+	}
+	if err := wallet.Close(); err != nil {
+		t.Fatalf("failed to close wallet: %v", err)
+	}
+}
+
+// Tests that transactions and hashes are signed on the token, with recoverable
+// signatures in the lower half of the curve order.
+func TestSign(t *testing.T) {
+	_, wallet := newTestWallet(t)
+	acc := wallet.Accounts()[0]
+
+	tx := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	if _, err := wallet.SignTx(acc, tx, big.NewInt(1)); err != accounts.ErrWalletClosed {
+		t.Fatalf("sign with closed wallet: have %v, want %v", err, accounts.ErrWalletClosed)
+	}
+	if err := wallet.Open(testPIN); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	defer wallet.Close()
+
+	// Sign a few times to get both high and low s values out of the token
+	for i, chainID := range []*big.Int{big.NewInt(18), big.NewInt(18), nil, nil} {
+		signed, err := wallet.SignTx(acc, tx, chainID)
+		if err != nil {
+			t.Fatalf("test %d: failed to sign transaction: %v", i, err)
+		}
+		var signer types.Signer = types.HomesteadSigner{}
+		if chainID != nil {
+			signer = types.NewEIP155Signer(chainID)
+			if !signed.Protected() || signed.ChainId().Cmp(chainID) != 0 {
+				t.Errorf("test %d: transaction not replay protected: chain id %v", i, signed.ChainId())
+			}
+		}
+		if sender, err := types.Sender(signer, signed); err != nil || sender != acc.Address {
+			t.Errorf("test %d: sender mismatch: have %x (%v), want %x", i, sender, err, acc.Address)
+		}
+	}
+	hash := crypto.Keccak256([]byte("genchain"))
+	for i := 0; i < 2; i++ {
+		sig, err := wallet.SignHash(acc, hash)
+		if err != nil {
+			t.Fatalf("failed to sign hash: %v", err)
+		}
+		if s := new(big.Int).SetBytes(sig[32:64]); s.Cmp(secp256k1halfN) > 0 {
+			t.Errorf("signature s in upper half of the curve order: %x", s)
+		}
+		pub, err := crypto.SigToPub(hash, sig)
+		if err != nil || crypto.PubkeyToAddress(*pub) != acc.Address {
+			t.Errorf("hash signer mismatch: %v", err)
+		}
+	}
+	if _, err := wallet.SignHash(accounts.Account{Address: common.Address{0x01}}, hash); err != accounts.ErrUnknownAccount {
+		t.Errorf("sign with unknown account: have %v, want %v", err, accounts.ErrUnknownAccount)
+	}
+}
+
+// Tests that closed wallets sign with the passphrase as the PIN.
+func TestSignWithPassphrase(t *testing.T) {
+	_, wallet := newTestWallet(t)
+	acc := wallet.Accounts()[0]
+
+	hash := crypto.Keccak256([]byte("genchain"))
+	if _, err := wallet.SignHashWithPassphrase(acc, "4321", hash); err != ErrPINIncorrect {
+		t.Fatalf("sign with wrong PIN: have %v, want %v", err, ErrPINIncorrect)
+	}
+	sig, err := wallet.SignHashWithPassphrase(acc, testPIN, hash)
+	if err != nil {
+		t.Fatalf("failed to sign hash: %v", err)
+	}
+	if pub, err := crypto.SigToPub(hash, sig); err != nil || crypto.PubkeyToAddress(*pub) != acc.Address {
+		t.Errorf("hash signer mismatch: %v", err)
+	}
+	tx := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	signed, err := wallet.SignTxWithPassphrase(acc, testPIN, tx, big.NewInt(18))
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if sender, err := types.Sender(types.NewEIP155Signer(big.NewInt(18)), signed); err != nil || sender != acc.Address {
+		t.Errorf("sender mismatch: have %x (%v), want %x", sender, err, acc.Address)
+	}
+	if status, _ := wallet.Status(); status != "Closed" {
+		t.Errorf("wallet status mismatch: have %s, want Closed", status)
+	}
+	// Open wallets check the passphrase too, as the token doesn't once logged in
+	if err := wallet.Open(testPIN); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	defer wallet.Close()
+
+	if _, err := wallet.SignHashWithPassphrase(acc, "4321", hash); err != ErrPINIncorrect {
+		t.Errorf("sign with open wallet and wrong PIN: have %v, want %v", err, ErrPINIncorrect)
+	}
+	if _, err := wallet.SignTxWithPassphrase(acc, "4321", tx, big.NewInt(18)); err != ErrPINIncorrect {
+		t.Errorf("sign transaction with open wallet and wrong PIN: have %v, want %v", err, ErrPINIncorrect)
+	}
+	if _, err := wallet.SignHashWithPassphrase(acc, testPIN, hash); err != nil {
+		t.Errorf("failed to sign hash with open wallet: %v", err)
+	}
+}
+
+// Tests that the wallets of removed tokens are dropped, closing their sessions.
+func TestWalletDrop(t *testing.T) {
+	mod := newTestModule(t)
+	hub := newHub(mod)
+
+	wallets := hub.Wallets()
+	if len(wallets) != 1 {
+		t.Fatalf("wallet count mismatch: have %d, want 1", len(wallets))
+	}
+	if err := wallets[0].Open(testPIN); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	mod.removed = true
+
+	hub.stateLock.Lock()
+	hub.refreshed = time.Time{}
+	hub.stateLock.Unlock()
+
+	if wallets := hub.Wallets(); len(wallets) != 0 {
+		t.Errorf("wallet count mismatch: have %d, want 0", len(wallets))
+	}
+	if status, _ := wallets[0].Status(); status != "Closed" {
+		t.Errorf("dropped wallet status mismatch: have %s, want Closed", status)
+	}
+	if mod.sessions != 0 {
+		t.Errorf("open session count mismatch: have %d, want 0", mod.sessions)
+	}
+}
+
+// Tests signing with a real PKCS#11 module, e.g. SoftHSM, holding a secp256k1
+// key on a token with the given PIN:
+//
+//	PKCS11_MODULE=/usr/lib/softhsm/libsofthsm2.so PKCS11_PIN=1234 go test
+func TestModule(t *testing.T) {
+	path, pin := os.Getenv("PKCS11_MODULE"), os.Getenv("PKCS11_PIN")
+	if path == "" {
+		t.Skip("PKCS11_MODULE not set")
+	}
+	hub, err := NewHub(path)
+	if err != nil {
+		t.Fatalf("failed to load module: %v", err)
+	}
+	for _, wallet := range hub.Wallets() {
+		if err := wallet.Open(pin); err != nil {
+			t.Errorf("%s: failed to open wallet: %v", wallet.URL(), err)
+			continue
+		}
+		for _, acc := range wallet.Accounts() {
+			tx := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil)
+			signed, err := wallet.SignTx(acc, tx, big.NewInt(18))
+			if err != nil {
+				t.Errorf("%s: failed to sign transaction: %v", acc.URL, err)
+				continue
+			}
+			if sender, err := types.Sender(types.NewEIP155Signer(big.NewInt(18)), signed); err != nil || sender != acc.Address {
+				t.Errorf("%s: sender mismatch: have %x (%v), want %x", acc.URL, sender, err, acc.Address)
+			}
+		}
+		wallet.Close()
+	}
+}
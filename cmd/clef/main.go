@@ -20,6 +20,7 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
@@ -34,6 +35,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/genchain/go-genchain/cmd/utils"
 	"github.com/genchain/go-genchain/common"
@@ -48,10 +50,10 @@ import (
 )
 
 // ExternalAPIVersion -- see extapi_changelog.md
-const ExternalAPIVersion = "2.0.0"
+const ExternalAPIVersion = "2.1.0"
 
 // InternalAPIVersion -- see intapi_changelog.md
-const InternalAPIVersion = "2.0.0"
+const InternalAPIVersion = "2.1.0"
 
 const legalWarning = `
 WARNING! 
@@ -119,6 +121,24 @@ var (
 		Name:  "stdio-ui-test",
 		Usage: "Mechanism to test interface between Clef and UI. Requires 'stdio-ui'.",
 	}
+	socketuiFlag = cli.StringFlag{
+		Name: "socket-ui",
+		Usage: "Serve the UI-channel on a Unix domain socket at the given path, for an external UI " +
+			"application to connect to. Clef waits for the UI to connect before starting.",
+	}
+	webhookuiFlag = cli.StringFlag{
+		Name:  "webhook-ui",
+		Usage: "POST the requests of the UI-channel to an approval service at the given local HTTP endpoint",
+	}
+	webhookSecretFlag = cli.StringFlag{
+		Name:  "webhook-ui-secret",
+		Usage: "File containing the secret shared with the approval service, authenticating requests and decisions",
+	}
+	webhookTimeoutFlag = cli.DurationFlag{
+		Name:  "webhook-ui-timeout",
+		Usage: "Time to wait for a decision of the approval service",
+		Value: 5 * time.Minute,
+	}
 	app         = cli.NewApp()
 	initCommand = cli.Command{
 		Action:    utils.MigrateFlags(initializeSecrets),
@@ -178,6 +198,7 @@ func init() {
 		utils.NetworkIdFlag,
 		utils.LightKDFFlag,
 		utils.NoUSBFlag,
+		utils.PKCS11ModuleFlag,
 		utils.RPCListenAddrFlag,
 		utils.RPCVirtualHostsFlag,
 		utils.IPCDisabledFlag,
@@ -191,6 +212,10 @@ func init() {
 		ruleFlag,
 		stdiouiFlag,
 		testFlag,
+		socketuiFlag,
+		webhookuiFlag,
+		webhookSecretFlag,
+		webhookTimeoutFlag,
 	}
 	app.Action = signer
 	app.Commands = []cli.Command{initCommand, attestCommand, addCredentialCommand}
@@ -315,15 +340,9 @@ func signer(c *cli.Context) error {
 	if err := initialize(c); err != nil {
 		return err
 	}
-	var (
-		ui core.SignerUI
-	)
-	if c.Bool(stdiouiFlag.Name) {
-		log.Info("Using stdin/stdout as UI-channel")
-		ui = core.NewStdIOUI()
-	} else {
-		log.Info("Using CLI as UI-channel")
-		ui = core.NewCommandlineUI()
+	ui, err := newUI(c)
+	if err != nil {
+		utils.Fatalf("Failed to set up UI-channel: %v", err)
 	}
 	db, err := core.NewAbiDBFromFiles(c.String(dBFlag.Name), c.String(customDBFlag.Name))
 	if err != nil {
@@ -349,11 +368,13 @@ func signer(c *cli.Context) error {
 		pwkey := crypto.Keccak256([]byte("credentials"), stretchedKey)
 		jskey := crypto.Keccak256([]byte("jsstorage"), stretchedKey)
 		confkey := crypto.Keccak256([]byte("config"), stretchedKey)
+		policykey := crypto.Keccak256([]byte("policystate"), stretchedKey)
 
 		// Initialize the encrypted storages
 		pwStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "credentials.json"), pwkey)
 		jsStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "jsstorage.json"), jskey)
 		configStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "config.json"), confkey)
+		policyStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "policystate.json"), policykey)
 
 		//Do we have a rule-file?
 		ruleJS, err := ioutil.ReadFile(c.String(ruleFlag.Name))
@@ -368,11 +389,13 @@ func signer(c *cli.Context) error {
 				log.Info("Could not validate ruleset hash, rules not enabled", "got", hex.EncodeToString(shasum), "expected", storedShasum)
 			} else {
 				// Initialize rules
-				ruleEngine, err := rules.NewRuleEvaluator(ui, jsStorage, pwStorage)
+				ruleEngine, err := rules.NewRuleEvaluator(ui, jsStorage, pwStorage, policyStorage)
 				if err != nil {
 					utils.Fatalf(err.Error())
 				}
-				ruleEngine.Init(string(ruleJS))
+				if err := ruleEngine.Init(string(ruleJS)); err != nil {
+					utils.Fatalf("Failed to load ruleset: %v", err)
+				}
 				ui = ruleEngine
 				log.Info("Rule engine configured", "file", c.String(ruleFlag.Name))
 			}
@@ -383,6 +406,7 @@ func signer(c *cli.Context) error {
 		c.Int64(utils.NetworkIdFlag.Name),
 		c.String(keystoreFlag.Name),
 		c.Bool(utils.NoUSBFlag.Name),
+		c.String(utils.PKCS11ModuleFlag.Name),
 		ui, db,
 		c.Bool(utils.LightKDFFlag.Name))
 
@@ -469,6 +493,40 @@ func signer(c *cli.Context) error {
 	return nil
 }
 
+// newUI creates the UI-channel selected on the command line.
+func newUI(c *cli.Context) (core.SignerUI, error) {
+	var selected int
+	for _, flag := range []string{stdiouiFlag.Name, socketuiFlag.Name, webhookuiFlag.Name} {
+		if c.IsSet(flag) {
+			selected++
+		}
+	}
+	if selected > 1 {
+		return nil, fmt.Errorf("only one of --%s, --%s and --%s can be used", stdiouiFlag.Name, socketuiFlag.Name, webhookuiFlag.Name)
+	}
+	switch {
+	case c.Bool(stdiouiFlag.Name):
+		log.Info("Using stdin/stdout as UI-channel")
+		return core.NewStdIOUI(), nil
+	case c.IsSet(socketuiFlag.Name):
+		log.Info("Using Unix socket as UI-channel", "path", c.String(socketuiFlag.Name))
+		return core.NewSocketUI(context.Background(), c.String(socketuiFlag.Name))
+	case c.IsSet(webhookuiFlag.Name):
+		if !c.IsSet(webhookSecretFlag.Name) {
+			return nil, fmt.Errorf("--%s requires --%s", webhookuiFlag.Name, webhookSecretFlag.Name)
+		}
+		secret, err := ioutil.ReadFile(c.String(webhookSecretFlag.Name))
+		if err != nil {
+			return nil, err
+		}
+		log.Info("Using webhook as UI-channel", "url", c.String(webhookuiFlag.Name))
+		return core.NewWebhookUI(c.String(webhookuiFlag.Name), bytes.TrimSpace(secret), c.Duration(webhookTimeoutFlag.Name))
+	default:
+		log.Info("Using CLI as UI-channel")
+		return core.NewCommandlineUI(), nil
+	}
+}
+
 // splitAndTrim splits input separated by a comma
 // and trims excessive white space from the substrings.
 func splitAndTrim(input string) []string {
@@ -19,8 +19,10 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"sort"
 
 	"github.com/genchain/go-genchain/accounts"
+	"github.com/genchain/go-genchain/accounts/hdwallet"
 	"github.com/genchain/go-genchain/accounts/keystore"
 	"github.com/genchain/go-genchain/cmd/utils"
 	"github.com/genchain/go-genchain/console"
@@ -30,6 +32,12 @@ import (
 )
 
 var (
+	kdfFlag = cli.StringFlag{
+		Name:  "kdf",
+		Usage: `Key derivation function to re-encrypt the keys with ("scrypt" or "argon2id")`,
+		Value: "scrypt",
+	}
+
 	walletCommand = cli.Command{
 		Name:      "wallet",
 		Usage:     "Manage Genchain presale wallets",
@@ -153,6 +161,35 @@ For non-interactive use the passphrase can be specified with the --password flag
 
 Since only one password can be given, only format update can be performed,
 changing your password is only possible interactively.
+`,
+			},
+			{
+				Name:      "rekey",
+				Usage:     "Re-encrypt existing accounts with stronger key derivation",
+				Action:    utils.MigrateFlags(accountRekey),
+				ArgsUsage: "[<address> ...]",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+					utils.LightKDFFlag,
+					kdfFlag,
+				},
+				Description: `
+    ggen account rekey [options] <address> ...
+
+Re-encrypts the key files of existing accounts with the standard parameters of
+the key derivation function selected by --kdf, keeping their passphrases. The
+Argon2id KDF is available next to the default scrypt one. Key files created with
+--lightkdf, such as the ones of mobile nodes, can be strengthened this way.
+
+You are prompted for the passphrase of every account. For non-interactive use the
+passphrases can be specified with the --password flag, one per line.
+
+    ggen account rekey
+
+Without addresses, lists the accounts whose key files are encrypted with key
+derivation parameters below the standard ones.
 `,
 			},
 			{
@@ -186,6 +223,70 @@ Note:
 As you can directly copy your encrypted accounts to another genchain instance,
 this import mechanism is not needed when you transfer an account between
 nodes.
+`,
+			},
+			{
+				Name:   "newhd",
+				Usage:  "Create a new HD wallet from a generated mnemonic",
+				Action: utils.MigrateFlags(accountCreateHD),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+					utils.LightKDFFlag,
+				},
+				Description: `
+    ggen account newhd
+
+Generates a new BIP-39 mnemonic, stores it encrypted as a new hierarchical
+deterministic wallet, and prints the mnemonic and the first account of the wallet
+(m/44'/60'/0'/0/0).
+
+The mnemonic is printed only once. Write it down and keep it safe, it is the
+backup of all the accounts of the wallet.
+
+The wallet is saved in encrypted format, you are prompted for a passphrase.
+For non-interactive use the passphrase can be specified with the --password flag.
+`,
+			},
+			{
+				Name:   "importhd",
+				Usage:  "Import a BIP-39 mnemonic into a new HD wallet",
+				Action: utils.MigrateFlags(accountImportHD),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+					utils.LightKDFFlag,
+				},
+				ArgsUsage: "<mnemonicFile>",
+				Description: `
+    ggen account importhd <mnemonicfile>
+
+Imports the BIP-39 mnemonic from <mnemonicfile> into a new hierarchical
+deterministic wallet, and prints the first account of the wallet (m/44'/60'/0'/0/0).
+
+The wallet is saved in encrypted format, you are prompted for a passphrase.
+For non-interactive use the passphrase can be specified with the --password flag.
+`,
+			},
+			{
+				Name:   "derive",
+				Usage:  "Derive an account of an HD wallet and pin it to the wallet",
+				Action: utils.MigrateFlags(accountDerive),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+				},
+				ArgsUsage: "<walletURL> <derivationPath>",
+				Description: `
+    ggen account derive <walletURL> <derivationPath>
+
+Derives the account at the given path of an HD wallet, as listed by 'ggen account
+list', and pins it to the wallet. Pinned accounts are listed and available for
+signing without opening the wallet first. Paths without the 'm/' prefix are
+relative to m/44'/60'/0'/0.
 `,
 			},
 		},
@@ -316,6 +417,88 @@ func accountCreate(ctx *cli.Context) error {
 	return nil
 }
 
+// accountCreateHD creates a new HD wallet into the keystore defined by the CLI
+// flags, printing its mnemonic.
+func accountCreateHD(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	password := getPassPhrase("Your new wallet is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
+
+	hub := stack.AccountManager().Backends(hdwallet.HubType)[0].(*hdwallet.Hub)
+	wallet, mnemonic, err := hub.NewWallet(password)
+	if err != nil {
+		utils.Fatalf("Failed to create wallet: %v", err)
+	}
+	fmt.Printf("Mnemonic: %s\n\n", mnemonic)
+	fmt.Println("Write down the mnemonic and keep it safe, it is the only backup of the accounts of the wallet.")
+	return printFirstHDAccount(wallet, password)
+}
+
+// accountImportHD imports a mnemonic into a new HD wallet into the keystore
+// defined by the CLI flags.
+func accountImportHD(ctx *cli.Context) error {
+	mnemonicfile := ctx.Args().First()
+	if len(mnemonicfile) == 0 {
+		utils.Fatalf("mnemonic file must be given as argument")
+	}
+	mnemonic, err := ioutil.ReadFile(mnemonicfile)
+	if err != nil {
+		utils.Fatalf("Failed to read the mnemonic: %v", err)
+	}
+	stack, _ := makeConfigNode(ctx)
+	password := getPassPhrase("Your new wallet is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
+
+	hub := stack.AccountManager().Backends(hdwallet.HubType)[0].(*hdwallet.Hub)
+	wallet, err := hub.ImportMnemonic(string(mnemonic), password)
+	if err != nil {
+		utils.Fatalf("Could not import the mnemonic: %v", err)
+	}
+	return printFirstHDAccount(wallet, password)
+}
+
+// printFirstHDAccount pins the first account of a new HD wallet and prints it.
+func printFirstHDAccount(wallet accounts.Wallet, password string) error {
+	if err := wallet.Open(password); err != nil {
+		utils.Fatalf("Failed to open wallet: %v", err)
+	}
+	defer wallet.Close()
+
+	account, err := wallet.Derive(accounts.DefaultBaseDerivationPath, true)
+	if err != nil {
+		utils.Fatalf("Failed to derive account: %v", err)
+	}
+	fmt.Printf("Wallet: %s\n", wallet.URL())
+	fmt.Printf("Address: {%x}\n", account.Address)
+	return nil
+}
+
+// accountDerive derives an account of an HD wallet and pins it to the wallet.
+func accountDerive(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 {
+		utils.Fatalf("Wallet URL and derivation path must be given as arguments")
+	}
+	path, err := accounts.ParseDerivationPath(ctx.Args()[1])
+	if err != nil {
+		utils.Fatalf("Invalid derivation path: %v", err)
+	}
+	stack, _ := makeConfigNode(ctx)
+	wallet, err := stack.AccountManager().Wallet(ctx.Args()[0])
+	if err != nil {
+		utils.Fatalf("Could not find the wallet: %v", err)
+	}
+	password := getPassPhrase(fmt.Sprintf("Opening wallet %s", wallet.URL()), false, 0, utils.MakePasswordList(ctx))
+	if err := wallet.Open(password); err != nil {
+		utils.Fatalf("Failed to open wallet: %v", err)
+	}
+	defer wallet.Close()
+
+	account, err := wallet.Derive(path, true)
+	if err != nil {
+		utils.Fatalf("Failed to derive account: %v", err)
+	}
+	fmt.Printf("Address: {%x}\n", account.Address)
+	return nil
+}
+
 // accountUpdate transitions an account from a previous format to the current
 // one, also providing the possibility to change the pass-phrase.
 func accountUpdate(ctx *cli.Context) error {
@@ -335,6 +518,70 @@ func accountUpdate(ctx *cli.Context) error {
 	return nil
 }
 
+// accountRekey re-encrypts the given accounts with the KDF selected by the CLI
+// flags, or lists the accounts with weak KDFs if none are given.
+func accountRekey(ctx *cli.Context) error {
+	stack, cfg := makeConfigNode(ctx)
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+
+	if len(ctx.Args()) == 0 {
+		weak, err := ks.WeakAccounts()
+		if err != nil {
+			utils.Fatalf("Could not check the accounts: %v", err)
+		}
+		if len(weak) == 0 {
+			fmt.Println("No weak keys found")
+		}
+		for i, account := range weak {
+			kdf, _ := ks.KDF(account)
+			fmt.Printf("Weak key #%d: {%x} %s (%s)\n", i, account.Address, &account.URL, formatKDF(kdf))
+		}
+		return nil
+	}
+	var kdf keystore.KDF
+	switch name := ctx.String(kdfFlag.Name); name {
+	case "scrypt":
+		scryptN, scryptP, _, err := cfg.Node.AccountConfig()
+		if err != nil {
+			utils.Fatalf("Failed to read configuration: %v", err)
+		}
+		kdf = keystore.NewScryptKDF(scryptN, scryptP)
+	case "argon2id":
+		if cfg.Node.UseLightweightKDF {
+			kdf = keystore.NewArgon2idKDF(keystore.LightArgon2Time, keystore.LightArgon2Memory)
+		} else {
+			kdf = keystore.NewArgon2idKDF(keystore.StandardArgon2Time, keystore.StandardArgon2Memory)
+		}
+	default:
+		utils.Fatalf("Unsupported KDF %q", name)
+	}
+	passwords := utils.MakePasswordList(ctx)
+	for i, addr := range ctx.Args() {
+		account, password := unlockAccount(ctx, ks, addr, i, passwords)
+		if err := ks.Rekey(account, password, kdf); err != nil {
+			utils.Fatalf("Could not rekey the account: %v", err)
+		}
+		fmt.Printf("Rekeyed account {%x} (%s)\n", account.Address, formatKDF(kdf))
+	}
+	return nil
+}
+
+// formatKDF returns the name and cost parameters of a KDF in a readable form.
+func formatKDF(kdf keystore.KDF) string {
+	params := kdf.Params()
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	desc := kdf.Name()
+	for _, key := range keys {
+		desc += fmt.Sprintf(" %s=%v", key, params[key])
+	}
+	return desc
+}
+
 func importWallet(ctx *cli.Context) error {
 	keyfile := ctx.Args().First()
 	if len(keyfile) == 0 {
@@ -90,6 +90,23 @@ Fatal: Passphrases do not match
 `)
 }
 
+func TestAccountImportHD(t *testing.T) {
+	mnemonicfile := filepath.Join(tmpdir(t), "mnemonic.txt")
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about\n"
+	if err := ioutil.WriteFile(mnemonicfile, []byte(mnemonic), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ggen := runGeth(t, "account", "importhd", "--lightkdf", mnemonicfile)
+	defer ggen.ExpectExit()
+	ggen.Expect(`
+Your new wallet is locked with a password. Please give a password. Do not forget this password.
+!! Unsupported terminal, password will be echoed.
+Passphrase: {{.InputLine "foobar"}}
+Repeat passphrase: {{.InputLine "foobar"}}
+`)
+	ggen.ExpectRegexp(`Wallet: hdwallet://.+\nAddress: \{9858effd232b4033e47d90003d41ec34ecaeda94\}\n`)
+}
+
 func TestAccountUpdate(t *testing.T) {
 	datadir := tmpDatadirWithKeystore(t)
 	ggen := runGeth(t, "account", "update",
@@ -106,6 +123,28 @@ Repeat passphrase: {{.InputLine "foobar2"}}
 `)
 }
 
+func TestAccountRekey(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	ggen := runGeth(t, "account", "rekey",
+		"--datadir", datadir, "--lightkdf", "--kdf", "argon2id",
+		"f466859ead1932d743d622cb74fc058882e8648a")
+	ggen.Expect(`
+Unlocking account f466859ead1932d743d622cb74fc058882e8648a | Attempt 1/3
+!! Unsupported terminal, password will be echoed.
+Passphrase: {{.InputLine "foobar"}}
+Rekeyed account {f466859ead1932d743d622cb74fc058882e8648a} (argon2id m=4096 p=4 t=3 v=19)
+`)
+	ggen.ExpectExit()
+
+	// The light KDF parameters are still reported as weak
+	ggen = runGeth(t, "account", "rekey", "--datadir", datadir)
+	defer ggen.ExpectExit()
+	ggen.ExpectRegexp(`Weak key #0: \{7ef5a6135f1fd6a02593eedc869c6d41d934aef8\} .+ \(scrypt n=8 p=16 r=8\)
+Weak key #1: \{f466859ead1932d743d622cb74fc058882e8648a\} .+ \(argon2id m=4096 p=4 t=3 v=19\)
+Weak key #2: \{289d485d9771714cce91d3393d764e1311907acc\} .+ \(scrypt n=8 p=16 r=8\)
+`)
+}
+
 func TestWalletImport(t *testing.T) {
 	ggen := runGeth(t, "wallet", "import", "--lightkdf", "testdata/guswallet.json")
 	defer ggen.ExpectExit()
@@ -17,11 +17,14 @@
 package main
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -29,6 +32,7 @@ import (
 	"github.com/genchain/go-genchain/common"
 	"github.com/genchain/go-genchain/console"
 	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/core/archive"
 	"github.com/genchain/go-genchain/core/state"
 	"github.com/genchain/go-genchain/core/types"
 	"github.com/genchain/go-genchain/gen/downloader"
@@ -41,6 +45,23 @@ import (
 )
 
 var (
+	archiveFastFlag = cli.BoolFlag{
+		Name:  "archive.fast",
+		Usage: "Import archived blocks and receipts without executing them, fast syncing the head state",
+	}
+	archiveFlag = cli.BoolFlag{
+		Name:  "archive",
+		Usage: "Export a chain archive instead of a plain RLP stream of blocks",
+	}
+	archiveReceiptsFlag = cli.BoolFlag{
+		Name:  "archive.receipts",
+		Usage: "Include the receipts of the blocks in the exported archive",
+	}
+	archiveTDsFlag = cli.BoolFlag{
+		Name:  "archive.tds",
+		Usage: "Include the total difficulties of the blocks in the exported archive",
+	}
+
 	initCommand = cli.Command{
 		Action:    utils.MigrateFlags(initGenesis),
 		Name:      "init",
@@ -70,11 +91,17 @@ It expects the genesis file as argument.`,
 			utils.GCModeFlag,
 			utils.CacheDatabaseFlag,
 			utils.CacheGCFlag,
+			archiveFastFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
-The import command imports blocks from an RLP-encoded form. The form can be one file
-with several RLP-encoded blocks, or several files can be used.
+The import command imports blocks from chain archives or from an RLP-encoded form.
+The form can be one file with several RLP-encoded blocks, or several files can be used.
+
+Chain archives are checked against their segment checksums and refused if they
+belong to another chain. With --archive.fast, archives containing receipts are
+imported without executing their transactions, the head state being fast synced
+from the network afterwards.
 
 If only one file is used, import error will result in failure. If several files are used,
 processing will proceed even if an individual RLP-file import failure occurs.`,
@@ -88,13 +115,32 @@ processing will proceed even if an individual RLP-file import failure occurs.`,
 			utils.DataDirFlag,
 			utils.CacheFlag,
 			utils.LightModeFlag,
+			archiveFlag,
+			archiveReceiptsFlag,
+			archiveTDsFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
 Requires a first argument of the file to write to.
 Optional second and third arguments control the first and
 last block to write. In this mode, the file will be appended
-if already existing.`,
+if already existing.
+
+With --archive, the blocks are written into a chain archive instead, recording
+the chain id, genesis hash and block range along with per-segment checksums. The
+receipts and total difficulties of the blocks can be included with
+--archive.receipts and --archive.tds. An archive always covers a single block
+range, so an existing file is overwritten rather than appended to.`,
+	}
+	checkArchiveCommand = cli.Command{
+		Action:    utils.MigrateFlags(checkArchive),
+		Name:      "check-archive",
+		Usage:     "Verify the integrity of a chain archive",
+		ArgsUsage: "<filename>",
+		Category:  "BLOCKCHAIN COMMANDS",
+		Description: `
+The check-archive command verifies the segment checksums and the block linkage of
+a chain archive without importing it, and prints the chain and range it contains.`,
 	}
 	importPreimagesCommand = cli.Command{
 		Action:    utils.MigrateFlags(importPreimages),
@@ -232,12 +278,12 @@ func importChain(ctx *cli.Context) error {
 	start := time.Now()
 
 	if len(ctx.Args()) == 1 {
-		if err := utils.ImportChain(chain, ctx.Args().First()); err != nil {
+		if err := utils.ImportChain(chain, ctx.Args().First(), ctx.Bool(archiveFastFlag.Name)); err != nil {
 			log.Error("Import error", "err", err)
 		}
 	} else {
 		for _, arg := range ctx.Args() {
-			if err := utils.ImportChain(chain, arg); err != nil {
+			if err := utils.ImportChain(chain, arg, ctx.Bool(archiveFastFlag.Name)); err != nil {
 				log.Error("Import error", "file", arg, "err", err)
 			}
 		}
@@ -307,21 +353,30 @@ func exportChain(ctx *cli.Context) error {
 	chain, _ := utils.MakeChain(ctx, stack)
 	start := time.Now()
 
-	var err error
-	fp := ctx.Args().First()
-	if len(ctx.Args()) < 3 {
-		err = utils.ExportChain(chain, fp)
-	} else {
+	var (
+		err         error
+		fp          = ctx.Args().First()
+		first, last = uint64(0), chain.CurrentBlock().NumberU64()
+	)
+	if len(ctx.Args()) >= 3 {
 		// This can be improved to allow for numbers larger than 9223372036854775807
-		first, ferr := strconv.ParseInt(ctx.Args().Get(1), 10, 64)
-		last, lerr := strconv.ParseInt(ctx.Args().Get(2), 10, 64)
+		f, ferr := strconv.ParseInt(ctx.Args().Get(1), 10, 64)
+		l, lerr := strconv.ParseInt(ctx.Args().Get(2), 10, 64)
 		if ferr != nil || lerr != nil {
 			utils.Fatalf("Export error in parsing parameters: block number not an integer\n")
 		}
-		if first < 0 || last < 0 {
+		if f < 0 || l < 0 {
 			utils.Fatalf("Export error: block number must be greater than 0\n")
 		}
-		err = utils.ExportAppendChain(chain, fp, uint64(first), uint64(last))
+		first, last = uint64(f), uint64(l)
+	}
+	switch {
+	case ctx.Bool(archiveFlag.Name):
+		err = utils.ExportArchive(chain, fp, first, last, ctx.Bool(archiveReceiptsFlag.Name), ctx.Bool(archiveTDsFlag.Name))
+	case len(ctx.Args()) < 3:
+		err = utils.ExportChain(chain, fp)
+	default:
+		err = utils.ExportAppendChain(chain, fp, first, last)
 	}
 
 	if err != nil {
@@ -331,6 +386,42 @@ func exportChain(ctx *cli.Context) error {
 	return nil
 }
 
+// checkArchive verifies the integrity of a chain archive.
+func checkArchive(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	fn := ctx.Args().First()
+	fh, err := os.Open(fn)
+	if err != nil {
+		utils.Fatalf("Failed to open archive: %v", err)
+	}
+	defer fh.Close()
+
+	var reader io.Reader = fh
+	if strings.HasSuffix(fn, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			utils.Fatalf("Failed to open archive: %v", err)
+		}
+	}
+	start := time.Now()
+	header, blocks, err := archive.Verify(reader)
+	if header == nil {
+		utils.Fatalf("Failed to read archive: %v", err)
+	}
+	fmt.Printf("Archive version: %d\n", header.Version)
+	fmt.Printf("Chain id:        %v\n", header.ChainID)
+	fmt.Printf("Genesis:         %x\n", header.Genesis)
+	fmt.Printf("Blocks:          %d-%d\n", header.First, header.Last)
+	fmt.Printf("Receipts:        %v\n", header.Receipts)
+	fmt.Printf("TDs:             %v\n", header.TDs)
+	if err != nil {
+		utils.Fatalf("Archive corrupted after %d blocks: %v", blocks, err)
+	}
+	fmt.Printf("Verified %d blocks in %v\n", blocks, time.Since(start))
+	return nil
+}
+
 // importPreimages imports preimage data from the specified file.
 func importPreimages(ctx *cli.Context) error {
 	if len(ctx.Args()) < 1 {
@@ -373,7 +464,7 @@ func copyDb(ctx *cli.Context) error {
 	chain, chainDb := utils.MakeChain(ctx, stack)
 
 	syncmode := *utils.GlobalTextMarshaler(ctx, utils.SyncModeFlag.Name).(*downloader.SyncMode)
-	dl := downloader.New(syncmode, chainDb, new(event.TypeMux), chain, nil, nil)
+	dl := downloader.New(syncmode, nil, chainDb, new(event.TypeMux), chain, nil, nil)
 
 	// Create a source peer to satisfy downloader requests from
 	db, err := ethdb.NewLDBDatabase(ctx.Args().First(), ctx.GlobalInt(utils.CacheFlag.Name), 256)
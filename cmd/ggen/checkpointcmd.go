@@ -0,0 +1,233 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of go-genchain.
+//
+// go-genchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-genchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-genchain. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/genchain/go-genchain/accounts/keystore"
+	"github.com/genchain/go-genchain/cmd/utils"
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/core/rawdb"
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/light"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	checkpointFileFlag = cli.StringFlag{
+		Name:  "checkpoint",
+		Usage: "Signed checkpoint file to create or add a signature to",
+	}
+	checkpointSectionFlag = cli.Uint64Flag{
+		Name:  "section",
+		Usage: "Section index to checkpoint (default = latest indexed section)",
+	}
+	checkpointSignerFlag = cli.StringFlag{
+		Name:  "signer",
+		Usage: "Account to sign the checkpoint with",
+	}
+
+	checkpointCommand = cli.Command{
+		Name:     "checkpoint",
+		Usage:    "Manage light client checkpoints",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Light client checkpoints contain the CHT and BloomTrie roots of a section of the
+chain, allowing light clients to start syncing from the section head instead of
+the genesis block. They are computed by full nodes running a light server, and
+need to be signed by a set of signers the light clients are configured to trust
+(see --light.checkpoint.signers).`,
+		Subcommands: []cli.Command{
+			{
+				Name:   "sign",
+				Usage:  "Compute and sign a checkpoint",
+				Action: utils.MigrateFlags(signCheckpoint),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+					utils.LightKDFFlag,
+					checkpointFileFlag,
+					checkpointSectionFlag,
+					checkpointSignerFlag,
+				},
+				Description: `
+    ggen checkpoint sign --checkpoint <file> --signer <address> [--section <index>]
+
+Computes the checkpoint of a section from the local chain database, signs it with
+the given account and writes it into the checkpoint file. If the file already
+exists, the signature is added to it after ensuring that the checkpoint matches
+the local chain, which allows collecting the signatures of multiple signers.
+
+The local node must have been running as a light server (--lightserv) for the
+checkpoint data to be available.`,
+			},
+			{
+				Name:   "verify",
+				Usage:  "Verify the signatures of a checkpoint",
+				Action: utils.MigrateFlags(verifyCheckpoint),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.LightCheckpointSignersFlag,
+					utils.LightCheckpointThresholdFlag,
+					checkpointFileFlag,
+				},
+				Description: `
+    ggen checkpoint verify --checkpoint <file> --light.checkpoint.signers <addresses>
+
+Verifies that a checkpoint belongs to the local chain and is signed by enough of
+the trusted signers for light clients to accept it.`,
+			},
+		},
+	}
+)
+
+// localCheckpoint assembles the checkpoint of a section from the CHT and BloomTrie
+// roots computed by the light server of a full node.
+func localCheckpoint(db ethdb.Database, section uint64) *light.TrustedCheckpoint {
+	head := rawdb.ReadCanonicalHash(db, (section+1)*light.CHTFrequencyClient-1)
+	if head == (common.Hash{}) {
+		return nil
+	}
+	return light.ReadTrustedCheckpoint(db, section, head, true)
+}
+
+// latestLocalCheckpoint returns the checkpoint of the last section processed by
+// the light server of a full node.
+func latestLocalCheckpoint(db ethdb.Database) *light.TrustedCheckpoint {
+	number := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadBlockHash(db))
+	if number == nil {
+		return nil
+	}
+	for section := *number / light.CHTFrequencyClient; section > 0; section-- {
+		if cp := localCheckpoint(db, section-1); cp != nil {
+			return cp
+		}
+	}
+	return nil
+}
+
+func signCheckpoint(ctx *cli.Context) error {
+	file, signer := ctx.String(checkpointFileFlag.Name), ctx.String(checkpointSignerFlag.Name)
+	if file == "" || signer == "" {
+		utils.Fatalf("Both the checkpoint file (--%s) and the signer (--%s) are required", checkpointFileFlag.Name, checkpointSignerFlag.Name)
+	}
+	stack := makeFullNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	// Load the checkpoint to add a signature to, or create a new one
+	signed := &light.SignedCheckpoint{Genesis: rawdb.ReadCanonicalHash(db, 0)}
+	if _, err := os.Stat(file); err == nil {
+		if signed, err = utils.LoadCheckpoint(file); err != nil {
+			utils.Fatalf("Failed to load checkpoint: %v", err)
+		}
+		if signed.Genesis != rawdb.ReadCanonicalHash(db, 0) {
+			utils.Fatalf("Checkpoint belongs to a different chain, genesis %x", signed.Genesis)
+		}
+		if ctx.IsSet(checkpointSectionFlag.Name) && ctx.Uint64(checkpointSectionFlag.Name) != signed.Checkpoint.SectionIdx {
+			utils.Fatalf("Checkpoint is for section %d", signed.Checkpoint.SectionIdx)
+		}
+	}
+	// Ensure the checkpoint matches the local chain
+	var local *light.TrustedCheckpoint
+	switch {
+	case len(signed.Signatures) > 0:
+		local = localCheckpoint(db, signed.Checkpoint.SectionIdx)
+	case ctx.IsSet(checkpointSectionFlag.Name):
+		local = localCheckpoint(db, ctx.Uint64(checkpointSectionFlag.Name))
+	default:
+		local = latestLocalCheckpoint(db)
+	}
+	if local == nil {
+		utils.Fatalf("Checkpoint not available locally, is the light server (--lightserv) synced?")
+	}
+	if len(signed.Signatures) > 0 && local.Hash() != signed.Checkpoint.Hash() {
+		utils.Fatalf("Checkpoint mismatch with the local chain: have %x, want %x", signed.Checkpoint.Hash(), local.Hash())
+	}
+	signed.Checkpoint = *local
+
+	// Sign the checkpoint, unless the signer already did so
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	account, _ := unlockAccount(ctx, ks, signer, 0, utils.MakePasswordList(ctx))
+
+	signers, err := signed.Signers()
+	if err != nil {
+		utils.Fatalf("Failed to recover checkpoint signers: %v", err)
+	}
+	for _, addr := range signers {
+		if addr == account.Address {
+			utils.Fatalf("Checkpoint already signed by %x", account.Address)
+		}
+	}
+	sig, err := ks.SignHash(account, signed.SigningHash().Bytes())
+	if err != nil {
+		utils.Fatalf("Failed to sign checkpoint: %v", err)
+	}
+	signed.Signatures = append(signed.Signatures, sig)
+
+	blob, err := json.MarshalIndent(signed, "", "  ")
+	if err != nil {
+		utils.Fatalf("Failed to encode checkpoint: %v", err)
+	}
+	if err := ioutil.WriteFile(file, blob, 0644); err != nil {
+		utils.Fatalf("Failed to write checkpoint: %v", err)
+	}
+	fmt.Printf("Section:     %d\n", signed.Checkpoint.SectionIdx)
+	fmt.Printf("Head:        %x\n", signed.Checkpoint.SectionHead)
+	fmt.Printf("Hash:        %x\n", signed.Checkpoint.Hash())
+	fmt.Printf("Signatures:  %d\n", len(signed.Signatures))
+	return nil
+}
+
+func verifyCheckpoint(ctx *cli.Context) error {
+	file := ctx.String(checkpointFileFlag.Name)
+	if file == "" {
+		utils.Fatalf("The checkpoint file (--%s) is required", checkpointFileFlag.Name)
+	}
+	signed, err := utils.LoadCheckpoint(file)
+	if err != nil {
+		utils.Fatalf("Failed to load checkpoint: %v", err)
+	}
+	stack := makeFullNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	signers, err := signed.Signers()
+	if err != nil {
+		utils.Fatalf("Failed to recover checkpoint signers: %v", err)
+	}
+	fmt.Printf("Section:     %d\n", signed.Checkpoint.SectionIdx)
+	fmt.Printf("Hash:        %x\n", signed.Checkpoint.Hash())
+	for _, addr := range signers {
+		fmt.Printf("Signed by:   %x\n", addr)
+	}
+	if local := localCheckpoint(db, signed.Checkpoint.SectionIdx); local != nil && local.Hash() != signed.Checkpoint.Hash() {
+		utils.Fatalf("Checkpoint mismatch with the local chain: have %x, want %x", signed.Checkpoint.Hash(), local.Hash())
+	}
+	trusted := utils.MakeAddresses(ctx.GlobalString(utils.LightCheckpointSignersFlag.Name))
+	threshold := ctx.GlobalInt(utils.LightCheckpointThresholdFlag.Name)
+	if err := signed.Verify(rawdb.ReadCanonicalHash(db, 0), trusted, threshold); err != nil {
+		utils.Fatalf("Checkpoint rejected: %v", err)
+	}
+	fmt.Println("Checkpoint accepted")
+	return nil
+}
@@ -175,6 +175,9 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 	if cfg.Ethstats.URL != "" {
 		utils.RegisterEthStatsService(stack, cfg.Ethstats.URL)
 	}
+	if ctx.GlobalBool(utils.MultisigEnabledFlag.Name) {
+		utils.RegisterMultisigService(stack)
+	}
 	return stack
 }
 
@@ -0,0 +1,312 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of go-genchain.
+//
+// go-genchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-genchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-genchain. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/genchain/go-genchain/cmd/utils"
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/log"
+	"github.com/genchain/go-genchain/p2p/discover"
+	"github.com/genchain/go-genchain/p2p/dnsdisc"
+	"github.com/genchain/go-genchain/p2p/enr"
+	"github.com/genchain/go-genchain/params"
+	"github.com/genchain/go-genchain/rlp"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dnsCrawlTimeoutFlag = cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "Time to crawl the discovery network for",
+		Value: 30 * time.Minute,
+	}
+	dnsDomainFlag = cli.StringFlag{
+		Name:  "domain",
+		Usage: "Domain name the node list is published under",
+	}
+	dnsSeqFlag = cli.UintFlag{
+		Name:  "seq",
+		Usage: "Sequence number of the node list (default = current time)",
+	}
+	dnsLinksFlag = cli.StringFlag{
+		Name:  "links",
+		Usage: "Comma separated enrtree:// URLs of node lists to link to",
+	}
+
+	dnsCommand = cli.Command{
+		Name:     "dns",
+		Usage:    "Manage DNS node lists",
+		Category: "MISCELLANEOUS COMMANDS",
+		Description: `
+DNS node lists are signed trees of node records published in DNS TXT records,
+allowing nodes to find peers through --discovery.dns when discovery is blocked
+or to bootstrap without hardcoded bootnodes. A list is referred to by its
+enrtree://<public key>@<domain> URL.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "crawl",
+				Usage:     "Collect the node records of the discovery network",
+				ArgsUsage: "<nodes.json>",
+				Action:    utils.MigrateFlags(dnsCrawl),
+				Flags: []cli.Flag{
+					utils.BootnodesFlag,
+					dnsCrawlTimeoutFlag,
+				},
+				Description: `
+    ggen dns crawl [--bootnodes <enodes>] [--timeout <duration>] <nodes.json>
+
+Walks the discovery network, starting at the bootnodes, and collects the records
+of the nodes that announce one. The records are merged into the node file.`,
+			},
+			{
+				Name:      "sign",
+				Usage:     "Build and sign a node list",
+				ArgsUsage: "<nodes.json> <keyfile> <txt.json>",
+				Action:    utils.MigrateFlags(dnsSign),
+				Flags: []cli.Flag{
+					dnsDomainFlag,
+					dnsSeqFlag,
+					dnsLinksFlag,
+				},
+				Description: `
+    ggen dns sign --domain <domain> [--seq <n>] [--links <urls>] <nodes.json> <keyfile> <txt.json>
+
+Builds the tree of the nodes in the node file and the links to other lists, signs
+it with the private key in the key file (as generated by bootnode -genkey) and
+writes the TXT records to publish, keyed by their domain names. The sequence
+number must increase with every update of the list.`,
+			},
+			{
+				Name:      "sync",
+				Usage:     "Download and verify a node list",
+				ArgsUsage: "<url> [<nodes.json>]",
+				Action:    utils.MigrateFlags(dnsSync),
+				Description: `
+    ggen dns sync <enrtree://url> [<nodes.json>]
+
+Downloads the entire node list at the URL, verifying its signature and entries,
+and optionally writes its nodes into a node file.`,
+			},
+		},
+	}
+)
+
+// dnsNode is an entry of a node file.
+type dnsNode struct {
+	Record   string    `json:"record"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// dnsCrawl collects the records of the nodes of the discovery network.
+func dnsCrawl(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		utils.Fatalf("Usage: ggen dns crawl <nodes.json>")
+	}
+	file := ctx.Args().First()
+	nodes := make(map[discover.NodeID]dnsNode)
+	if _, err := os.Stat(file); err == nil {
+		nodes = loadDNSNodes(file)
+	}
+	urls := params.MainnetBootnodes
+	if ctx.IsSet(utils.BootnodesFlag.Name) {
+		urls = strings.Split(ctx.String(utils.BootnodesFlag.Name), ",")
+	}
+	var bootnodes []*discover.Node
+	for _, url := range urls {
+		node, err := discover.ParseNode(url)
+		if err != nil {
+			utils.Fatalf("Bootstrap URL %q invalid: %v", url, err)
+		}
+		bootnodes = append(bootnodes, node)
+	}
+	// Start a throwaway discovery node and look up random targets until the
+	// timeout, collecting the records fetched while bonding.
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		utils.Fatalf("Failed to generate node key: %v", err)
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{})
+	if err != nil {
+		utils.Fatalf("Failed to listen: %v", err)
+	}
+	tab, err := discover.ListenUDP(conn, discover.Config{PrivateKey: key, Bootnodes: bootnodes})
+	if err != nil {
+		utils.Fatalf("Failed to start discovery: %v", err)
+	}
+	defer tab.Close()
+
+	var (
+		deadline = time.Now().Add(ctx.Duration(dnsCrawlTimeoutFlag.Name))
+		logged   time.Time
+		found    int
+	)
+	for time.Now().Before(deadline) {
+		var target discover.NodeID
+		rand.Read(target[:])
+		results := tab.Lookup(target)
+		for _, n := range results {
+			if n.Record() == nil {
+				continue
+			}
+			if _, ok := nodes[n.ID]; !ok {
+				found++
+			}
+			nodes[n.ID] = dnsNode{Record: encodeDNSRecord(n.Record()), LastSeen: time.Now()}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Crawling discovery network", "new", found, "total", len(nodes), "left", common.PrettyDuration(time.Until(deadline)))
+			logged = time.Now()
+		}
+		if len(results) == 0 {
+			time.Sleep(time.Second) // don't spin while the network is unreachable
+		}
+	}
+	writeDNSNodes(file, nodes)
+	return nil
+}
+
+// dnsSign builds and signs the tree of a node file.
+func dnsSign(ctx *cli.Context) error {
+	if ctx.NArg() != 3 {
+		utils.Fatalf("Usage: ggen dns sign --domain <domain> <nodes.json> <keyfile> <txt.json>")
+	}
+	domain := ctx.String(dnsDomainFlag.Name)
+	if domain == "" {
+		utils.Fatalf("Missing domain name (--%s)", dnsDomainFlag.Name)
+	}
+	nodes := loadDNSNodes(ctx.Args().Get(0))
+	key, err := crypto.LoadECDSA(ctx.Args().Get(1))
+	if err != nil {
+		utils.Fatalf("Failed to load signing key: %v", err)
+	}
+	seq := ctx.Uint(dnsSeqFlag.Name)
+	if seq == 0 {
+		seq = uint(time.Now().Unix())
+	}
+	var links []string
+	if ctx.IsSet(dnsLinksFlag.Name) {
+		links = strings.Split(ctx.String(dnsLinksFlag.Name), ",")
+	}
+	records := make([]*enr.Record, 0, len(nodes))
+	for id, n := range nodes {
+		record, err := decodeDNSRecord(n.Record)
+		if err != nil {
+			utils.Fatalf("Invalid record of node %x: %v", id[:8], err)
+		}
+		records = append(records, record)
+	}
+	tree, err := dnsdisc.MakeTree(seq, records, links)
+	if err != nil {
+		utils.Fatalf("Failed to build tree: %v", err)
+	}
+	url, err := tree.Sign(key, domain)
+	if err != nil {
+		utils.Fatalf("Failed to sign tree: %v", err)
+	}
+	out, _ := json.MarshalIndent(tree.ToTXT(domain), "", "  ")
+	if err := ioutil.WriteFile(ctx.Args().Get(2), out, 0644); err != nil {
+		utils.Fatalf("Failed to write TXT records: %v", err)
+	}
+	fmt.Printf("Signed node list of %d nodes, %d links, seq %d\n", len(records), len(links), seq)
+	fmt.Printf("URL: %s\n", url)
+	return nil
+}
+
+// dnsSync downloads and verifies a node list.
+func dnsSync(ctx *cli.Context) error {
+	if ctx.NArg() < 1 || ctx.NArg() > 2 {
+		utils.Fatalf("Usage: ggen dns sync <url> [<nodes.json>]")
+	}
+	tree, err := dnsdisc.NewClient(dnsdisc.Config{}).SyncTree(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to sync node list: %v", err)
+	}
+	fmt.Printf("Node list seq %d: %d nodes, %d links\n", tree.Seq(), len(tree.Nodes()), len(tree.Links()))
+	for _, link := range tree.Links() {
+		fmt.Printf("Link: %s\n", link)
+	}
+	if ctx.NArg() == 2 {
+		nodes := make(map[discover.NodeID]dnsNode)
+		for _, record := range tree.Nodes() {
+			n, err := discover.NewNodeFromRecord(record)
+			if err != nil {
+				log.Warn("Skipping unusable node record", "err", err)
+				continue
+			}
+			nodes[n.ID] = dnsNode{Record: encodeDNSRecord(record)}
+		}
+		writeDNSNodes(ctx.Args().Get(1), nodes)
+	}
+	return nil
+}
+
+func loadDNSNodes(file string) map[discover.NodeID]dnsNode {
+	blob, err := ioutil.ReadFile(file)
+	if err != nil {
+		utils.Fatalf("Failed to read node file: %v", err)
+	}
+	nodes := make(map[discover.NodeID]dnsNode)
+	if err := json.Unmarshal(blob, &nodes); err != nil {
+		utils.Fatalf("Invalid node file: %v", err)
+	}
+	return nodes
+}
+
+func writeDNSNodes(file string, nodes map[discover.NodeID]dnsNode) {
+	out, _ := json.MarshalIndent(nodes, "", "  ")
+	if err := ioutil.WriteFile(file, out, 0644); err != nil {
+		utils.Fatalf("Failed to write node file: %v", err)
+	}
+	log.Info("Wrote node file", "file", file, "nodes", len(nodes))
+}
+
+// encodeDNSRecord encodes a node record in its textual "enr:" form.
+func encodeDNSRecord(r *enr.Record) string {
+	blob, err := rlp.EncodeToBytes(r)
+	if err != nil {
+		utils.Fatalf("Failed to encode node record: %v", err)
+	}
+	return "enr:" + base64.RawURLEncoding.EncodeToString(blob)
+}
+
+// decodeDNSRecord decodes a node record from its textual "enr:" form, verifying
+// its signature.
+func decodeDNSRecord(s string) (*enr.Record, error) {
+	if !strings.HasPrefix(s, "enr:") {
+		return nil, fmt.Errorf("missing 'enr:' prefix")
+	}
+	blob, err := base64.RawURLEncoding.DecodeString(s[4:])
+	if err != nil {
+		return nil, err
+	}
+	var r enr.Record
+	if err := rlp.DecodeBytes(blob, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -58,6 +58,7 @@ var (
 		utils.DataDirFlag,
 		utils.KeyStoreDirFlag,
 		utils.NoUSBFlag,
+		utils.PKCS11ModuleFlag,
 		utils.DashboardEnabledFlag,
 		utils.DashboardAddrFlag,
 		utils.DashboardPortFlag,
@@ -81,10 +82,17 @@ var (
 		utils.FastSyncFlag,
 		utils.LightModeFlag,
 		utils.SyncModeFlag,
+		utils.SyncCheckpointFlag,
 		utils.GCModeFlag,
+		utils.IndexTransfersFlag,
+		utils.IndexAddressesFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
 		utils.LightKDFFlag,
+		utils.LightCheckpointFlag,
+		utils.LightCheckpointSignersFlag,
+		utils.LightCheckpointThresholdFlag,
+		utils.LightFuzzyPoWFlag,
 		utils.CacheFlag,
 		utils.CacheDatabaseFlag,
 		utils.CacheGCFlag,
@@ -100,6 +108,7 @@ var (
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
+		utils.DNSDiscoveryFlag,
 		utils.NetrestrictFlag,
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
@@ -112,6 +121,7 @@ var (
 		utils.RPCCORSDomainFlag,
 		utils.RPCVirtualHostsFlag,
 		utils.EthStatsURLFlag,
+		utils.MultisigEnabledFlag,
 		utils.MetricsEnabledFlag,
 		utils.FakePoWFlag,
 		utils.NoCompactionFlag,
@@ -126,6 +136,8 @@ var (
 		utils.RPCListenAddrFlag,
 		utils.RPCPortFlag,
 		utils.RPCApiFlag,
+		utils.RPCLogsMaxBlockRangeFlag,
+		utils.RPCLogsMaxResultsFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
 		utils.WSPortFlag,
@@ -152,11 +164,14 @@ func init() {
 		initCommand,
 		importCommand,
 		exportCommand,
+		checkArchiveCommand,
 		importPreimagesCommand,
 		exportPreimagesCommand,
 		copydbCommand,
 		removedbCommand,
 		dumpCommand,
+		// See checkpointcmd.go:
+		checkpointCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
@@ -174,6 +189,8 @@ func init() {
 		licenseCommand,
 		// See config.go
 		dumpConfigCommand,
+		// See dnscmd.go:
+		dnsCommand,
 	}
 	sort.Sort(cli.CommandsByName(app.Commands))
 
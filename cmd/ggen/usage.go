@@ -71,16 +71,25 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.DataDirFlag,
 			utils.KeyStoreDirFlag,
 			utils.NoUSBFlag,
+			utils.PKCS11ModuleFlag,
 			utils.NetworkIdFlag,
 			utils.TestnetFlag,
 			utils.RinkebyFlag,
 			utils.SyncModeFlag,
+			utils.SyncCheckpointFlag,
 			utils.GCModeFlag,
+			utils.IndexTransfersFlag,
+			utils.IndexAddressesFlag,
 			utils.EthStatsURLFlag,
+			utils.MultisigEnabledFlag,
 			utils.IdentityFlag,
 			utils.LightServFlag,
 			utils.LightPeersFlag,
 			utils.LightKDFFlag,
+			utils.LightCheckpointFlag,
+			utils.LightCheckpointSignersFlag,
+			utils.LightCheckpointThresholdFlag,
+			utils.LightFuzzyPoWFlag,
 		},
 	},
 	{Name: "DEVELOPER CHAIN",
@@ -127,6 +136,8 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.RPCListenAddrFlag,
 			utils.RPCPortFlag,
 			utils.RPCApiFlag,
+			utils.RPCLogsMaxBlockRangeFlag,
+			utils.RPCLogsMaxResultsFlag,
 			utils.WSEnabledFlag,
 			utils.WSListenAddrFlag,
 			utils.WSPortFlag,
@@ -153,6 +164,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.NATFlag,
 			utils.NoDiscoverFlag,
 			utils.DiscoveryV5Flag,
+			utils.DNSDiscoveryFlag,
 			utils.NetrestrictFlag,
 			utils.NodeKeyFileFlag,
 			utils.NodeKeyHexFlag,
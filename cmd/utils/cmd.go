@@ -18,6 +18,7 @@
 package utils
 
 import (
+	"bufio"
 	"compress/gzip"
 	"fmt"
 	"io"
@@ -29,6 +30,7 @@ import (
 
 	"github.com/genchain/go-genchain/common"
 	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/core/archive"
 	"github.com/genchain/go-genchain/core/rawdb"
 	"github.com/genchain/go-genchain/core/types"
 	"github.com/genchain/go-genchain/crypto"
@@ -85,7 +87,10 @@ func StartNode(stack *node.Node) {
 	}()
 }
 
-func ImportChain(chain *core.BlockChain, fn string) error {
+// ImportChain imports a blockchain from the specified file, which is either a
+// chain archive or a plain stream of RLP encoded blocks. Archives with receipts
+// may be imported in fast mode, skipping the execution of the transactions.
+func ImportChain(chain *core.BlockChain, fn string, fast bool) error {
 	// Watch for Ctrl-C while the import is running.
 	// If a signal is received, the import will stop at the next batch.
 	interrupt := make(chan os.Signal, 1)
@@ -123,7 +128,14 @@ func ImportChain(chain *core.BlockChain, fn string) error {
 			return err
 		}
 	}
-	stream := rlp.NewStream(reader, 0)
+	buffered := bufio.NewReader(reader)
+	if archive.IsArchive(buffered) {
+		return archive.Import(chain, buffered, fast, stop)
+	}
+	if fast {
+		return fmt.Errorf("fast import needs a chain archive")
+	}
+	stream := rlp.NewStream(buffered, 0)
 
 	// Run actual the import.
 	blocks := make(types.Blocks, importBatchSize)
@@ -212,6 +224,34 @@ func ExportChain(blockchain *core.BlockChain, fn string) error {
 	return nil
 }
 
+// ExportArchive exports a range of the blockchain into a chain archive in the
+// specified file, truncating any data already present in the file.
+func ExportArchive(blockchain *core.BlockChain, fn string, first, last uint64, receipts, tds bool) error {
+	log.Info("Exporting chain archive", "file", fn)
+
+	// Open the file handle and potentially wrap with a gzip stream
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var writer io.Writer = fh
+	if strings.HasSuffix(fn, ".gz") {
+		writer = gzip.NewWriter(writer)
+		defer writer.(*gzip.Writer).Close()
+	}
+	buffered := bufio.NewWriter(writer)
+	if err := archive.Export(blockchain, buffered, first, last, receipts, tds); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	log.Info("Exported chain archive", "file", fn)
+	return nil
+}
+
 // ExportAppendChain exports a blockchain into the specified file, appending to
 // the file if data already exists in it.
 func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, last uint64) error {
@@ -19,6 +19,7 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/big"
@@ -44,14 +45,18 @@ import (
 	"github.com/genchain/go-genchain/ethstats"
 	"github.com/genchain/go-genchain/gen"
 	"github.com/genchain/go-genchain/gen/downloader"
+	"github.com/genchain/go-genchain/gen/filters"
 	"github.com/genchain/go-genchain/gen/gasprice"
 	"github.com/genchain/go-genchain/les"
+	"github.com/genchain/go-genchain/light"
 	"github.com/genchain/go-genchain/log"
 	"github.com/genchain/go-genchain/metrics"
+	"github.com/genchain/go-genchain/multisig"
 	"github.com/genchain/go-genchain/node"
 	"github.com/genchain/go-genchain/p2p"
 	"github.com/genchain/go-genchain/p2p/discover"
 	"github.com/genchain/go-genchain/p2p/discv5"
+	"github.com/genchain/go-genchain/p2p/dnsdisc"
 	"github.com/genchain/go-genchain/p2p/nat"
 	"github.com/genchain/go-genchain/p2p/netutil"
 	"github.com/genchain/go-genchain/params"
@@ -126,6 +131,10 @@ var (
 		Name:  "nousb",
 		Usage: "Disables monitoring for and managing USB hardware wallets",
 	}
+	PKCS11ModuleFlag = cli.StringFlag{
+		Name:  "pkcs11",
+		Usage: "PKCS#11 module (shared library) to sign with the secp256k1 keys of its tokens",
+	}
 	NetworkIdFlag = cli.Uint64Flag{
 		Name:  "networkid",
 		Usage: "Network identifier (integer, 1=Frontier, 2=Morden (disused), 3=Ropsten, 4=Rinkeby)",
@@ -167,14 +176,26 @@ var (
 	defaultSyncMode = gen.DefaultConfig.SyncMode
 	SyncModeFlag    = TextMarshalerFlag{
 		Name:  "syncmode",
-		Usage: `Blockchain sync mode ("fast", "full", or "light")`,
+		Usage: `Blockchain sync mode ("fast", "full", "light" or "snap")`,
 		Value: &defaultSyncMode,
 	}
+	SyncCheckpointFlag = cli.StringFlag{
+		Name:  "sync.checkpoint",
+		Usage: "Trusted block (<number>:<hash>) the chain must contain, used as the fast sync pivot (peers need its state)",
+	}
 	GCModeFlag = cli.StringFlag{
 		Name:  "gcmode",
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	IndexTransfersFlag = cli.BoolFlag{
+		Name:  "index.transfers",
+		Usage: "Maintain an index of the ERC20/ERC721 token transfers of every address",
+	}
+	IndexAddressesFlag = cli.BoolFlag{
+		Name:  "index.addresses",
+		Usage: "Maintain an index of the transactions sent or received by every address",
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -189,6 +210,22 @@ var (
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
 	}
+	LightCheckpointFlag = cli.StringFlag{
+		Name:  "light.checkpoint",
+		Usage: "Signed CHT/BloomTrie checkpoint file to start light syncing from",
+	}
+	LightCheckpointSignersFlag = cli.StringFlag{
+		Name:  "light.checkpoint.signers",
+		Usage: "Comma separated accounts trusted to sign light client checkpoints",
+	}
+	LightCheckpointThresholdFlag = cli.IntFlag{
+		Name:  "light.checkpoint.threshold",
+		Usage: "Number of trusted signatures required to accept a checkpoint (0 = majority)",
+	}
+	LightFuzzyPoWFlag = cli.Uint64Flag{
+		Name:  "light.fuzzypow",
+		Usage: "Only fully verify the proof-of-work of every Nth header and of checkpoints in light mode (0 = all)",
+	}
 	// Dashboard settings
 	DashboardEnabledFlag = cli.BoolFlag{
 		Name:  "dashboard",
@@ -360,6 +397,10 @@ var (
 		Name:  "ethstats",
 		Usage: "Reporting URL of a ethstats service (nodename:secret@host:port)",
 	}
+	MultisigEnabledFlag = cli.BoolFlag{
+		Name:  "multisig",
+		Usage: "Enable the multisig API to coordinate the transactions of multi-signature wallets",
+	}
 	MetricsEnabledFlag = cli.BoolFlag{
 		Name:  metrics.MetricsEnabledFlag,
 		Usage: "Enable metrics collection and reporting",
@@ -402,6 +443,16 @@ var (
 		Usage: "API's offered over the HTTP-RPC interface",
 		Value: "",
 	}
+	RPCLogsMaxBlockRangeFlag = cli.Uint64Flag{
+		Name:  "rpc.logs.maxrange",
+		Usage: "Maximum number of blocks a single log query may span (0 = unlimited)",
+		Value: gen.DefaultConfig.Filter.MaxBlockRange,
+	}
+	RPCLogsMaxResultsFlag = cli.IntFlag{
+		Name:  "rpc.logs.maxresults",
+		Usage: "Maximum number of logs a single log query may return (0 = unlimited)",
+		Value: gen.DefaultConfig.Filter.MaxResults,
+	}
 	IPCDisabledFlag = cli.BoolFlag{
 		Name:  "ipcdisable",
 		Usage: "Disable the IPC-RPC server",
@@ -495,6 +546,10 @@ var (
 		Name:  "v5disc",
 		Usage: "Enables the experimental RLPx V5 (Topic Discovery) mechanism",
 	}
+	DNSDiscoveryFlag = cli.StringFlag{
+		Name:  "discovery.dns",
+		Usage: "Comma separated enrtree:// URLs of DNS node lists to find peers in",
+	}
 	NetrestrictFlag = cli.StringFlag{
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
@@ -851,6 +906,19 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		cfg.DiscoveryV5 = true
 	}
 
+	if ctx.GlobalIsSet(DNSDiscoveryFlag.Name) {
+		cfg.DNSDiscovery = nil
+		for _, url := range strings.Split(ctx.GlobalString(DNSDiscoveryFlag.Name), ",") {
+			if url = strings.TrimSpace(url); url == "" {
+				continue
+			}
+			if _, _, err := dnsdisc.ParseURL(url); err != nil {
+				Fatalf("Option %q: invalid URL %q: %v", DNSDiscoveryFlag.Name, url, err)
+			}
+			cfg.DNSDiscovery = append(cfg.DNSDiscovery, url)
+		}
+	}
+
 	if netrestrict := ctx.GlobalString(NetrestrictFlag.Name); netrestrict != "" {
 		list, err := netutil.ParseNetlist(netrestrict)
 		if err != nil {
@@ -896,6 +964,9 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(NoUSBFlag.Name) {
 		cfg.NoUSB = ctx.GlobalBool(NoUSBFlag.Name)
 	}
+	if ctx.GlobalIsSet(PKCS11ModuleFlag.Name) {
+		cfg.PKCS11Module = ctx.GlobalString(PKCS11ModuleFlag.Name)
+	}
 }
 
 func setGPO(ctx *cli.Context, cfg *gasprice.Config) {
@@ -907,6 +978,61 @@ func setGPO(ctx *cli.Context, cfg *gasprice.Config) {
 	}
 }
 
+func setFilter(ctx *cli.Context, cfg *filters.Config) {
+	if ctx.GlobalIsSet(RPCLogsMaxBlockRangeFlag.Name) {
+		cfg.MaxBlockRange = ctx.GlobalUint64(RPCLogsMaxBlockRangeFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCLogsMaxResultsFlag.Name) {
+		cfg.MaxResults = ctx.GlobalInt(RPCLogsMaxResultsFlag.Name)
+	}
+}
+
+// setCheckpoint loads the signed light client checkpoint and its trusted signers
+// from the command line flags.
+func setCheckpoint(ctx *cli.Context, cfg *gen.Config) {
+	if ctx.GlobalIsSet(LightCheckpointSignersFlag.Name) {
+		cfg.CheckpointSigners = MakeAddresses(ctx.GlobalString(LightCheckpointSignersFlag.Name))
+	}
+	if ctx.GlobalIsSet(LightCheckpointThresholdFlag.Name) {
+		cfg.CheckpointThreshold = ctx.GlobalInt(LightCheckpointThresholdFlag.Name)
+	}
+	if ctx.GlobalIsSet(LightCheckpointFlag.Name) {
+		cp, err := LoadCheckpoint(ctx.GlobalString(LightCheckpointFlag.Name))
+		if err != nil {
+			Fatalf("Failed to load light checkpoint: %v", err)
+		}
+		cfg.Checkpoint = cp
+	}
+}
+
+// MakeAddresses parses a comma separated list of hex encoded accounts.
+func MakeAddresses(list string) []common.Address {
+	var addrs []common.Address
+	for _, addr := range strings.Split(list, ",") {
+		if addr = strings.TrimSpace(addr); addr == "" {
+			continue
+		}
+		if !common.IsHexAddress(addr) {
+			Fatalf("Invalid account address %q", addr)
+		}
+		addrs = append(addrs, common.HexToAddress(addr))
+	}
+	return addrs
+}
+
+// LoadCheckpoint reads a JSON encoded signed checkpoint from a file.
+func LoadCheckpoint(file string) (*light.SignedCheckpoint, error) {
+	blob, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	cp := new(light.SignedCheckpoint)
+	if err := json.Unmarshal(blob, cp); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
 func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolNoLocalsFlag.Name) {
 		cfg.NoLocals = ctx.GlobalBool(TxPoolNoLocalsFlag.Name)
@@ -1020,6 +1146,7 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *gen.Config) {
 	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
 	setEtherbase(ctx, ks, cfg)
 	setGPO(ctx, &cfg.GPO)
+	setFilter(ctx, &cfg.Filter)
 	setTxPool(ctx, &cfg.TxPool)
 	setEthash(ctx, cfg)
 
@@ -1031,12 +1158,22 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *gen.Config) {
 	case ctx.GlobalBool(LightModeFlag.Name):
 		cfg.SyncMode = downloader.LightSync
 	}
+	if ctx.GlobalIsSet(SyncCheckpointFlag.Name) {
+		cfg.SyncCheckpoint = new(downloader.Checkpoint)
+		if err := cfg.SyncCheckpoint.UnmarshalText([]byte(ctx.GlobalString(SyncCheckpointFlag.Name))); err != nil {
+			Fatalf("Invalid sync checkpoint: %v", err)
+		}
+	}
 	if ctx.GlobalIsSet(LightServFlag.Name) {
 		cfg.LightServ = ctx.GlobalInt(LightServFlag.Name)
 	}
 	if ctx.GlobalIsSet(LightPeersFlag.Name) {
 		cfg.LightPeers = ctx.GlobalInt(LightPeersFlag.Name)
 	}
+	setCheckpoint(ctx, cfg)
+	if ctx.GlobalIsSet(LightFuzzyPoWFlag.Name) {
+		cfg.LightFuzzyPoW = ctx.GlobalUint64(LightFuzzyPoWFlag.Name)
+	}
 	if ctx.GlobalIsSet(NetworkIdFlag.Name) {
 		cfg.NetworkId = ctx.GlobalUint64(NetworkIdFlag.Name)
 	}
@@ -1051,6 +1188,13 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *gen.Config) {
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
 
+	if ctx.GlobalIsSet(IndexTransfersFlag.Name) {
+		cfg.TokenTransferIndex = ctx.GlobalBool(IndexTransfersFlag.Name)
+	}
+	if ctx.GlobalIsSet(IndexAddressesFlag.Name) {
+		cfg.AddressTxIndex = ctx.GlobalBool(IndexAddressesFlag.Name)
+	}
+
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cfg.TrieCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
@@ -1175,6 +1319,24 @@ func RegisterEthStatsService(stack *node.Node, url string) {
 	}
 }
 
+// RegisterMultisigService adds the multisig coordination service to the stack,
+// on top of the full or light Genchain service.
+func RegisterMultisigService(stack *node.Node) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethServ *gen.Genchain
+		if err := ctx.Service(&ethServ); err == nil {
+			return multisig.New(ethServ.APIBackend), nil
+		}
+		var lesServ *les.LightEthereum
+		if err := ctx.Service(&lesServ); err != nil {
+			return nil, err
+		}
+		return multisig.New(lesServ.ApiBackend), nil
+	}); err != nil {
+		Fatalf("Failed to register the multisig service: %v", err)
+	}
+}
+
 // SetupNetwork configures the system for either the main net or some test network.
 func SetupNetwork(ctx *cli.Context) {
 	// TODO(fjl): move target gas limit into config
@@ -59,6 +59,7 @@ var (
 	errInvalidMixDigest  = errors.New("invalid mix digest")
 	errInvalidPoW        = errors.New("invalid proof-of-work")
 	errInvalidFuzzyHash  = errors.New("invalid errInvalidfuzzyHash")
+	errInvalidTransition = errors.New("invalid n/p transition")
 )
 
 // Author implements consensus.Engine, returning the header's coinbase as the
@@ -1023,20 +1024,49 @@ func (ethash *Ethash) VerifySeal(chain consensus.ChainReader, header *types.Head
 		return errInvalidMixDigest
 	}
 
-	var n, p uint64
-	if chain.Config().IsValleyfork(next) {
-		n, p = ethash.VerifyDifficultyByValley(header)
-	} else if chain.Config().IsRiverfork(next) {
-		n, p = ethash.VerifyDifficultyByRiver(header)
-	} else {
-		n, p = ethash.VerifyDifficultyBygen(header)
-		//fmt.Println("VerifySeal not fork:", "number:", header.Number, "  n:", n, " p: ", p)
+	n, p := ethash.verifyDifficulty(chain, header)
+	if header.N != n || header.P != p {
+		return errInvalidPoW
 	}
+	return nil
+}
 
-	if header.N != n || header.P != p {
+// verifyDifficulty calculates the N and P values a header should have, based on
+// the timespan and parent N and P values sealed into it.
+func (ethash *Ethash) verifyDifficulty(chain consensus.ChainReader, header *types.Header) (uint64, uint64) {
+	if chain.Config().IsValleyfork(header.Number) {
+		return ethash.VerifyDifficultyByValley(header)
+	}
+	if chain.Config().IsRiverfork(header.Number) {
+		return ethash.VerifyDifficultyByRiver(header)
+	}
+	return ethash.VerifyDifficultyBygen(header)
+}
 
+// VerifyTransition checks the parts of a header's seal that don't need the fuzzy
+// hash: that the N and P values follow from the parent's, and that the NP total
+// difficulty is accumulated correctly. It's orders of magnitude cheaper than the
+// full seal verification, allowing light clients to check every header even if
+// they only verify the seal of a few.
+func (ethash *Ethash) VerifyTransition(chain consensus.ChainReader, header, parent *types.Header) error {
+	// If we're running a fake PoW, accept any seal as valid
+	if ethash.config.PowMode == ModeFake || ethash.config.PowMode == ModeFullFake {
+		return nil
+	}
+	if header.NN != parent.N || header.PP != parent.P {
+		return errInvalidTransition
+	}
+	if n, p := ethash.verifyDifficulty(chain, header); header.N != n || header.P != p {
 		return errInvalidPoW
 	}
+	// The NP accumulation of forked headers is checked by verifyHeader
+	if chain.Config().IsRiverfork(header.Number) || chain.Config().IsValleyfork(header.Number) {
+		return nil
+	}
+	np := new(big.Int).SetUint64(header.N * header.N * header.N * header.P * header.P * header.P * header.P * header.P * header.P)
+	if np.Add(np, parent.NP); header.NP.Cmp(np) != 0 {
+		return fmt.Errorf("invalid np: have %v, want %v", header.NP, np)
+	}
 	return nil
 }
 
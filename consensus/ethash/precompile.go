@@ -0,0 +1,98 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/core/vm"
+	"github.com/genchain/go-genchain/params"
+)
+
+const (
+	// fuzzyHashInputLength is the length of the fuzzy hash verifier input: the
+	// header hash without nonce, followed by the nonce, P, N and the claimed fuzzy
+	// hash, each padded to a 32 byte word.
+	fuzzyHashInputLength = 160
+
+	// fuzzyHashMaxN is the largest matrix dimension the fuzzy hash verifier accepts,
+	// bounding the cost of a single verification.
+	fuzzyHashMaxN = 256
+)
+
+var errFuzzyHashInput = errors.New("invalid fuzzy hash verifier input")
+
+func init() {
+	vm.RegisterPrecompiledContract("fuzzyhash", &fuzzyHashVerifier{})
+}
+
+// fuzzyHashVerifier implements a precompiled contract verifying the fuzzy hash
+// and the proof-of-work difficulty of a block seal on chain.
+//
+// The output is a word containing 1 if the fuzzy hash matches and the final hash
+// has at least P leading zero bits or 0 otherwise, followed by the final hash so
+// callers can check it against the header's mix digest.
+type fuzzyHashVerifier struct{}
+
+// parseFuzzyHashInput splits the verifier input into its fields, returning false
+// if any of them is out of bounds.
+func parseFuzzyHashInput(input []byte) (hash []byte, nonce, p, n uint64, fuzzy common.Hash, ok bool) {
+	input = common.RightPadBytes(input, fuzzyHashInputLength)
+
+	words := make([]uint64, 3)
+	for i := range words {
+		word := input[32*(i+1) : 32*(i+2)]
+		if !bytes.Equal(word[:24], make([]byte, 24)) {
+			return nil, 0, 0, 0, common.Hash{}, false
+		}
+		words[i] = binary.BigEndian.Uint64(word[24:])
+	}
+	nonce, p, n = words[0], words[1], words[2]
+	if n == 0 || n > fuzzyHashMaxN || p > 256 {
+		return nil, 0, 0, 0, common.Hash{}, false
+	}
+	return input[:32], nonce, p, n, common.BytesToHash(input[128:160]), true
+}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract,
+// proportional to the number of multiplication steps of the matrix exponentiation.
+func (c *fuzzyHashVerifier) RequiredGas(input []byte) uint64 {
+	_, _, _, n, _, ok := parseFuzzyHashInput(input)
+	if !ok {
+		return params.FuzzyHashBaseGas
+	}
+	steps := n * n * n * uint64(bits.Len64(n)+1)
+	return params.FuzzyHashBaseGas + steps/params.FuzzyHashOpsPerGas
+}
+
+func (c *fuzzyHashVerifier) Run(input []byte) ([]byte, error) {
+	hash, nonce, p, n, fuzzy, ok := parseFuzzyHashInput(input)
+	if !ok {
+		return nil, errFuzzyHashInput
+	}
+	fhash, _, hash256 := genHash(hash, nonce, hash, p, n)
+
+	valid := make([]byte, 32)
+	if common.BytesToHash(fhash) == fuzzy && compareDiff(hash256, int(p)) {
+		valid[31] = 1
+	}
+	return append(valid, hash256...), nil
+}
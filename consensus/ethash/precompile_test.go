@@ -0,0 +1,75 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/params"
+)
+
+// Tests that the fuzzy hash verifier precompile accepts valid seals and rejects
+// mismatching fuzzy hashes and out of bound parameters.
+func TestFuzzyHashVerifier(t *testing.T) {
+	var (
+		verifier = new(fuzzyHashVerifier)
+		hash     = common.HexToHash("0x9b095b36c15eaf13044373aef8ee0bd3a382a5abb92e402afa44b8249c3a90e9")
+		nonce    = uint64(0x1234)
+	)
+	input := func(p, n uint64, fuzzy []byte) []byte {
+		var blob []byte
+		blob = append(blob, hash.Bytes()...)
+		for _, word := range []uint64{nonce, p, n} {
+			blob = append(blob, common.LeftPadBytes(new(big.Int).SetUint64(word).Bytes(), 32)...)
+		}
+		return append(blob, common.LeftPadBytes(fuzzy, 32)...)
+	}
+	fhash, _, hash256 := genHash(hash.Bytes(), nonce, hash.Bytes(), 0, 8)
+
+	// A matching fuzzy hash should verify and return the final hash
+	ret, err := verifier.Run(input(0, 8, fhash))
+	if err != nil {
+		t.Fatalf("failed to verify valid seal: %v", err)
+	}
+	if ret[31] != 1 || !bytes.Equal(ret[32:], hash256) {
+		t.Errorf("valid seal output mismatch: have %x, want 1 and %x", ret, hash256)
+	}
+	// A mismatching fuzzy hash or difficulty should not verify
+	if ret, _ := verifier.Run(input(0, 8, hash.Bytes())); ret[31] != 0 {
+		t.Errorf("mismatching fuzzy hash verified")
+	}
+	if !compareDiff(hash256, 256) {
+		if ret, _ := verifier.Run(input(256, 8, fhash)); ret[31] != 0 {
+			t.Errorf("insufficient difficulty verified")
+		}
+	}
+	// Out of bound parameters should be rejected for the base gas only
+	for _, invalid := range [][]byte{input(0, 0, fhash), input(0, fuzzyHashMaxN+1, fhash), input(257, 8, fhash)} {
+		if _, err := verifier.Run(invalid); err != errFuzzyHashInput {
+			t.Errorf("invalid input error mismatch: have %v, want %v", err, errFuzzyHashInput)
+		}
+		if gas := verifier.RequiredGas(invalid); gas != params.FuzzyHashBaseGas {
+			t.Errorf("invalid input gas mismatch: have %d, want %d", gas, params.FuzzyHashBaseGas)
+		}
+	}
+	if small, large := verifier.RequiredGas(input(0, 8, fhash)), verifier.RequiredGas(input(0, 128, fhash)); small >= large {
+		t.Errorf("gas not increasing with matrix size: n=8 %d, n=128 %d", small, large)
+	}
+}
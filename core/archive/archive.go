@@ -0,0 +1,296 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package archive implements a self-describing chain archive format.
+//
+// An archive starts with a magic marker and a header describing the chain and
+// the block range it contains, followed by segments of consecutive blocks along
+// with, optionally, their receipts and total difficulties. Every segment carries
+// a checksum of its contents, so corruption is detected before anything gets
+// imported, and segments can be decoded and checked independently of each other.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/rlp"
+)
+
+// Version is the current version of the archive format.
+const Version = 1
+
+// SegmentSize is the number of blocks stored in a single archive segment.
+const SegmentSize = 1024
+
+// magic marks the start of an archive, distinguishing it from plain RLP streams.
+var magic = []byte("genarch\x00")
+
+var (
+	// ErrNotArchive is returned if the data doesn't start with an archive header.
+	ErrNotArchive = errors.New("not a chain archive")
+
+	// ErrChecksum is returned if the contents of a segment don't match its checksum.
+	ErrChecksum = errors.New("segment checksum mismatch")
+)
+
+// Header describes the contents of an archive.
+type Header struct {
+	Version  uint64      // Version of the archive format
+	ChainID  *big.Int    // Chain id of the archived chain (EIP155)
+	Genesis  common.Hash // Genesis block hash of the archived chain
+	First    uint64      // Number of the first block in the archive
+	Last     uint64      // Number of the last block in the archive
+	Receipts bool        // Whether the archive contains the receipts of the blocks
+	TDs      bool        // Whether the archive contains the total difficulties of the blocks
+}
+
+// Check ensures that the archive belongs to the chain with the given chain id
+// and genesis block.
+func (h *Header) Check(chainID *big.Int, genesis common.Hash) error {
+	if h.Genesis != genesis {
+		return fmt.Errorf("archive of another chain: genesis %x, want %x", h.Genesis, genesis)
+	}
+	if (h.ChainID == nil) != (chainID == nil) || (chainID != nil && h.ChainID.Cmp(chainID) != 0) {
+		return fmt.Errorf("archive of another chain: chain id %v, want %v", h.ChainID, chainID)
+	}
+	return nil
+}
+
+// Segment is a run of consecutive blocks along with their receipts and total
+// difficulties, if contained in the archive.
+type Segment struct {
+	Blocks   []*types.Block
+	Receipts []types.Receipts // Receipts of the blocks, if archived
+	TDs      []*big.Int       // Total difficulties of the blocks, if archived
+}
+
+// envelope is the encoded form of a segment along with its checksum.
+type envelope struct {
+	Data     []byte
+	Checksum common.Hash
+}
+
+// Writer writes a chain archive.
+type Writer struct {
+	w      io.Writer
+	header Header
+	next   uint64 // Number of the next block expected
+}
+
+// NewWriter writes the header of an archive and returns a writer to append its
+// segments with.
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	header.Version = Version
+	if header.First > header.Last {
+		return nil, fmt.Errorf("invalid block range %d-%d", header.First, header.Last)
+	}
+	if _, err := w.Write(magic); err != nil {
+		return nil, err
+	}
+	if err := rlp.Encode(w, &header); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, header: header, next: header.First}, nil
+}
+
+// WriteSegment appends a segment of blocks to the archive. The segment must
+// continue the previous one and contain the data declared in the header.
+func (w *Writer) WriteSegment(seg *Segment) error {
+	if err := checkSegment(&w.header, seg, w.next); err != nil {
+		return err
+	}
+	data, err := rlp.EncodeToBytes(seg)
+	if err != nil {
+		return err
+	}
+	if err := rlp.Encode(w.w, &envelope{Data: data, Checksum: crypto.Keccak256Hash(data)}); err != nil {
+		return err
+	}
+	w.next += uint64(len(seg.Blocks))
+	return nil
+}
+
+// Close ensures that all the blocks declared in the header have been written.
+func (w *Writer) Close() error {
+	if w.next != w.header.Last+1 {
+		return fmt.Errorf("archive incomplete: next block %d, last %d", w.next, w.header.Last)
+	}
+	return nil
+}
+
+// Reader reads a chain archive.
+type Reader struct {
+	Header Header
+
+	stream *rlp.Stream
+}
+
+// NewReader reads the header of an archive, returning ErrNotArchive if the data
+// is not an archive.
+func NewReader(r io.Reader) (*Reader, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	if !IsArchive(br) {
+		return nil, ErrNotArchive
+	}
+	br.Discard(len(magic))
+
+	reader := &Reader{stream: rlp.NewStream(br, 0)}
+	if err := reader.stream.Decode(&reader.Header); err != nil {
+		return nil, fmt.Errorf("invalid archive header: %v", err)
+	}
+	if reader.Header.Version != Version {
+		return nil, fmt.Errorf("unsupported archive version %d", reader.Header.Version)
+	}
+	return reader, nil
+}
+
+// IsArchive reports whether the buffered data starts with an archive header,
+// without consuming any of it.
+func IsArchive(r *bufio.Reader) bool {
+	prefix, err := r.Peek(len(magic))
+	return err == nil && bytes.Equal(prefix, magic)
+}
+
+// next reads the next encoded segment of the archive without checking it,
+// returning io.EOF at the end of the archive.
+func (r *Reader) next() (*envelope, error) {
+	env := new(envelope)
+	if err := r.stream.Decode(env); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// Next reads and checks the next segment of the archive, returning io.EOF at
+// the end of the archive.
+func (r *Reader) Next() (*Segment, error) {
+	env, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	return env.decode(&r.Header)
+}
+
+// decode checks the checksum of an encoded segment and decodes it.
+func (env *envelope) decode(header *Header) (*Segment, error) {
+	if crypto.Keccak256Hash(env.Data) != env.Checksum {
+		return nil, ErrChecksum
+	}
+	seg := new(Segment)
+	if err := rlp.DecodeBytes(env.Data, seg); err != nil {
+		return nil, fmt.Errorf("invalid segment: %v", err)
+	}
+	if len(seg.Blocks) == 0 {
+		return nil, errors.New("empty segment")
+	}
+	if err := checkSegment(header, seg, seg.Blocks[0].NumberU64()); err != nil {
+		return nil, err
+	}
+	return seg, nil
+}
+
+// checkSegment ensures that a segment starts at the given block, is linked and
+// consistent with the header of the archive and that the block bodies and the
+// receipts match the block headers.
+func checkSegment(header *Header, seg *Segment, first uint64) error {
+	if len(seg.Blocks) == 0 {
+		return errors.New("empty segment")
+	}
+	if n := seg.Blocks[0].NumberU64(); n != first {
+		return fmt.Errorf("segment starts at block %d, want %d", n, first)
+	}
+	if n := seg.Blocks[len(seg.Blocks)-1].NumberU64(); n > header.Last {
+		return fmt.Errorf("segment ends at block %d, past the last archived %d", n, header.Last)
+	}
+	if want := expected(header.Receipts, len(seg.Blocks)); len(seg.Receipts) != want {
+		return fmt.Errorf("segment receipts mismatch: have %d, want %d", len(seg.Receipts), want)
+	}
+	if want := expected(header.TDs, len(seg.Blocks)); len(seg.TDs) != want {
+		return fmt.Errorf("segment total difficulties mismatch: have %d, want %d", len(seg.TDs), want)
+	}
+	for i, block := range seg.Blocks {
+		if i > 0 && (block.NumberU64() != seg.Blocks[i-1].NumberU64()+1 || block.ParentHash() != seg.Blocks[i-1].Hash()) {
+			return fmt.Errorf("non contiguous block #%d [%x…]", block.NumberU64(), block.Hash().Bytes()[:4])
+		}
+		if hash := types.DeriveSha(block.Transactions()); hash != block.TxHash() {
+			return fmt.Errorf("block #%d transaction root mismatch: have %x, want %x", block.NumberU64(), hash, block.TxHash())
+		}
+		if hash := types.CalcUncleHash(block.Uncles()); hash != block.UncleHash() {
+			return fmt.Errorf("block #%d uncle root mismatch: have %x, want %x", block.NumberU64(), hash, block.UncleHash())
+		}
+		if header.Receipts {
+			if hash := types.DeriveSha(seg.Receipts[i]); hash != block.ReceiptHash() {
+				return fmt.Errorf("block #%d receipt root mismatch: have %x, want %x", block.NumberU64(), hash, block.ReceiptHash())
+			}
+		}
+	}
+	return nil
+}
+
+// expected returns the number of receipts or total difficulties a segment of
+// the given size must contain.
+func expected(archived bool, blocks int) int {
+	if archived {
+		return blocks
+	}
+	return 0
+}
+
+// Verify checks the integrity of a whole archive without importing it, returning
+// its header and the number of blocks it contains.
+func Verify(r io.Reader) (*Header, uint64, error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	var (
+		next   = reader.Header.First
+		parent common.Hash
+	)
+	for {
+		seg, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return &reader.Header, next - reader.Header.First, fmt.Errorf("segment at block %d: %v", next, err)
+		}
+		first := seg.Blocks[0]
+		if first.NumberU64() != next || (next > reader.Header.First && first.ParentHash() != parent) {
+			return &reader.Header, next - reader.Header.First, fmt.Errorf("segment at block %d: not linked to previous segment", next)
+		}
+		if next == 0 && first.Hash() != reader.Header.Genesis {
+			return &reader.Header, 0, fmt.Errorf("genesis mismatch: have %x, want %x", first.Hash(), reader.Header.Genesis)
+		}
+		next += uint64(len(seg.Blocks))
+		parent = seg.Blocks[len(seg.Blocks)-1].Hash()
+	}
+	if next != reader.Header.Last+1 {
+		return &reader.Header, next - reader.Header.First, fmt.Errorf("archive truncated at block %d, last %d", next, reader.Header.Last)
+	}
+	return &reader.Header, next - reader.Header.First, nil
+}
@@ -0,0 +1,207 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/core/types"
+)
+
+// makeChain creates a linked chain of n blocks starting at genesis, each with
+// a single receipt, along with their total difficulties.
+func makeChain(n int) ([]*types.Block, []types.Receipts, []*big.Int) {
+	var (
+		blocks   []*types.Block
+		receipts []types.Receipts
+		tds      []*big.Int
+		parent   common.Hash
+		td       = new(big.Int)
+	)
+	for i := 0; i < n; i++ {
+		header := &types.Header{
+			ParentHash: parent,
+			Number:     big.NewInt(int64(i)),
+			Difficulty: big.NewInt(131072),
+			Rewards:    new(big.Int),
+			Time:       big.NewInt(int64(i * 15)),
+		}
+		list := types.Receipts{types.NewReceipt(nil, false, uint64(i))}
+		block := types.NewBlock(header, nil, nil, list)
+
+		blocks = append(blocks, block)
+		receipts = append(receipts, list)
+		td = new(big.Int).Add(td, header.Difficulty)
+		tds = append(tds, td)
+		parent = block.Hash()
+	}
+	return blocks, receipts, tds
+}
+
+// writeArchive encodes the given chain into an archive with segments of the
+// given size.
+func writeArchive(t *testing.T, blocks []*types.Block, receipts []types.Receipts, tds []*big.Int, size int) []byte {
+	buf := new(bytes.Buffer)
+	w, err := NewWriter(buf, Header{
+		ChainID:  big.NewInt(1),
+		Genesis:  blocks[0].Hash(),
+		First:    blocks[0].NumberU64(),
+		Last:     blocks[len(blocks)-1].NumberU64(),
+		Receipts: receipts != nil,
+		TDs:      tds != nil,
+	})
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	for i := 0; i < len(blocks); i += size {
+		end := i + size
+		if end > len(blocks) {
+			end = len(blocks)
+		}
+		seg := &Segment{Blocks: blocks[i:end]}
+		if receipts != nil {
+			seg.Receipts = receipts[i:end]
+		}
+		if tds != nil {
+			seg.TDs = tds[i:end]
+		}
+		if err := w.WriteSegment(seg); err != nil {
+			t.Fatalf("failed to write segment %d: %v", i/size, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// Tests that archives can be read back, with and without receipts and TDs.
+func TestArchiveRoundtrip(t *testing.T) {
+	blocks, receipts, tds := makeChain(100)
+
+	for _, full := range []bool{false, true} {
+		var (
+			rs []types.Receipts
+			ts []*big.Int
+		)
+		if full {
+			rs, ts = receipts, tds
+		}
+		data := writeArchive(t, blocks, rs, ts, 32)
+
+		r, err := NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("full %v: failed to open archive: %v", full, err)
+		}
+		if r.Header.Version != Version || r.Header.First != 0 || r.Header.Last != 99 || r.Header.Receipts != full || r.Header.TDs != full {
+			t.Fatalf("full %v: header mismatch: %+v", full, r.Header)
+		}
+		var n int
+		for {
+			seg, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("full %v: failed to read segment: %v", full, err)
+			}
+			for i, block := range seg.Blocks {
+				if block.Hash() != blocks[n+i].Hash() {
+					t.Fatalf("full %v: block %d mismatch", full, n+i)
+				}
+				if full && seg.TDs[i].Cmp(tds[n+i]) != 0 {
+					t.Fatalf("full %v: block %d td mismatch: have %v, want %v", full, n+i, seg.TDs[i], tds[n+i])
+				}
+			}
+			n += len(seg.Blocks)
+		}
+		if n != len(blocks) {
+			t.Fatalf("full %v: block count mismatch: have %d, want %d", full, n, len(blocks))
+		}
+		if _, count, err := Verify(bytes.NewReader(data)); err != nil || count != uint64(len(blocks)) {
+			t.Fatalf("full %v: verification failed: %d blocks, %v", full, count, err)
+		}
+	}
+}
+
+// Tests that corrupted, truncated and foreign archives are rejected.
+func TestArchiveIntegrity(t *testing.T) {
+	blocks, receipts, tds := makeChain(100)
+	data := writeArchive(t, blocks, receipts, tds, 32)
+
+	// Plain RLP streams aren't archives
+	if _, err := NewReader(bytes.NewReader(data[len(magic):])); err != ErrNotArchive {
+		t.Fatalf("plain stream error mismatch: have %v, want %v", err, ErrNotArchive)
+	}
+	// Flipping a byte in the segment data should fail the checksum
+	tampered := common.CopyBytes(data)
+	tampered[len(tampered)-100] ^= 0xff
+	if _, _, err := Verify(bytes.NewReader(tampered)); err == nil {
+		t.Fatalf("tampered archive verified")
+	}
+	// Truncating the archive should be detected
+	if _, _, err := Verify(bytes.NewReader(data[:len(data)-1])); err == nil {
+		t.Fatalf("truncated archive verified")
+	}
+	// Archives of other chains should be refused
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	if err := r.Header.Check(big.NewInt(1), blocks[0].Hash()); err != nil {
+		t.Fatalf("own chain refused: %v", err)
+	}
+	if err := r.Header.Check(big.NewInt(2), blocks[0].Hash()); err == nil {
+		t.Fatalf("archive of chain id 2 accepted")
+	}
+	if err := r.Header.Check(big.NewInt(1), blocks[1].Hash()); err == nil {
+		t.Fatalf("archive of another genesis accepted")
+	}
+}
+
+// Tests that the writer refuses segments inconsistent with the header.
+func TestArchiveWriterChecks(t *testing.T) {
+	blocks, receipts, _ := makeChain(10)
+
+	w, err := NewWriter(new(bytes.Buffer), Header{Genesis: blocks[0].Hash(), First: 0, Last: 9, Receipts: true})
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	if err := w.WriteSegment(&Segment{Blocks: blocks[:5]}); err == nil {
+		t.Fatalf("segment without receipts accepted")
+	}
+	if err := w.WriteSegment(&Segment{Blocks: blocks[1:5], Receipts: receipts[1:5]}); err == nil {
+		t.Fatalf("segment with gap accepted")
+	}
+	if err := w.WriteSegment(&Segment{Blocks: blocks[:5], Receipts: receipts[1:6]}); err == nil {
+		t.Fatalf("segment with mismatching receipts accepted")
+	}
+	unlinked := []*types.Block{blocks[0], blocks[1], blocks[3]}
+	if err := w.WriteSegment(&Segment{Blocks: unlinked, Receipts: receipts[:3]}); err == nil {
+		t.Fatalf("unlinked segment accepted")
+	}
+	if err := w.WriteSegment(&Segment{Blocks: blocks[:5], Receipts: receipts[:5]}); err != nil {
+		t.Fatalf("valid segment refused: %v", err)
+	}
+	if err := w.Close(); err == nil {
+		t.Fatalf("incomplete archive closed")
+	}
+}
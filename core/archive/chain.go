@@ -0,0 +1,236 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/log"
+)
+
+// errInterrupted is returned if an import is stopped before completion.
+var errInterrupted = errors.New("interrupted")
+
+// Export writes the given range of the canonical chain into an archive,
+// optionally including the receipts and total difficulties of the blocks.
+func Export(bc *core.BlockChain, w io.Writer, first, last uint64, receipts, tds bool) error {
+	writer, err := NewWriter(w, Header{
+		ChainID:  bc.Config().ChainId,
+		Genesis:  bc.Genesis().Hash(),
+		First:    first,
+		Last:     last,
+		Receipts: receipts,
+		TDs:      tds,
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("Exporting chain archive", "first", first, "last", last, "receipts", receipts, "tds", tds)
+
+	for number := first; number <= last; {
+		seg := new(Segment)
+		for ; number <= last && len(seg.Blocks) < SegmentSize; number++ {
+			block := bc.GetBlockByNumber(number)
+			if block == nil {
+				return fmt.Errorf("export failed on #%d: not found", number)
+			}
+			seg.Blocks = append(seg.Blocks, block)
+			if receipts {
+				list := bc.GetReceiptsByHash(block.Hash())
+				if list == nil {
+					list = types.Receipts{}
+				}
+				seg.Receipts = append(seg.Receipts, list)
+			}
+			if tds {
+				seg.TDs = append(seg.TDs, bc.GetTd(block.Hash(), number))
+			}
+		}
+		if err := writer.WriteSegment(seg); err != nil {
+			return fmt.Errorf("export failed on #%d: %v", seg.Blocks[0].NumberU64(), err)
+		}
+	}
+	return writer.Close()
+}
+
+// decoded is the result of decoding and checking a segment.
+type decoded struct {
+	seg *Segment
+	err error
+}
+
+// Import imports an archive into the chain, refusing archives of other chains.
+//
+// Segments are decoded, checked and have their transaction senders recovered
+// concurrently, then get imported in order. In fast mode, the blocks and their
+// archived receipts are written without executing the transactions, leaving the
+// state of the head block to be retrieved by fast sync.
+func Import(bc *core.BlockChain, r io.Reader, fast bool, stop <-chan struct{}) error {
+	reader, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	header := reader.Header
+	if err := header.Check(bc.Config().ChainId, bc.Genesis().Hash()); err != nil {
+		return err
+	}
+	if fast && !header.Receipts {
+		return errors.New("fast import needs an archive with receipts")
+	}
+	log.Info("Importing chain archive", "first", header.First, "last", header.Last, "receipts", header.Receipts, "tds", header.TDs, "fast", fast)
+
+	// Feed the encoded segments to the decoders, keeping their order
+	var (
+		workers = runtime.NumCPU()
+		tasks   = make(chan func(), workers)
+		results = make(chan chan decoded, workers)
+		quit    = make(chan struct{})
+	)
+	defer close(quit)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for task := range tasks {
+				task()
+			}
+		}()
+	}
+	go func() {
+		defer close(tasks)
+		defer close(results)
+
+		for {
+			env, err := reader.next()
+			res := make(chan decoded, 1)
+			if err != nil {
+				if err != io.EOF {
+					res <- decoded{err: err}
+					select {
+					case results <- res:
+					case <-quit:
+					}
+				}
+				return
+			}
+			select {
+			case results <- res:
+			case <-quit:
+				return
+			}
+			tasks <- func() {
+				seg, err := env.decode(&header)
+				if err == nil {
+					recoverSenders(bc, seg)
+				}
+				res <- decoded{seg, err}
+			}
+		}
+	}()
+	// Import the decoded segments in order
+	next := header.First
+	for res := range results {
+		select {
+		case <-stop:
+			return errInterrupted
+		default:
+		}
+		out := <-res
+		if out.err != nil {
+			return fmt.Errorf("segment at block %d: %v", next, out.err)
+		}
+		seg := out.seg
+		if seg.Blocks[0].NumberU64() != next {
+			return fmt.Errorf("segment at block %d: starts at block %d", next, seg.Blocks[0].NumberU64())
+		}
+		next += uint64(len(seg.Blocks))
+
+		if err := importSegment(bc, seg, fast); err != nil {
+			return err
+		}
+	}
+	if next != header.Last+1 {
+		return fmt.Errorf("archive truncated at block %d, last %d", next, header.Last)
+	}
+	return nil
+}
+
+// recoverSenders caches the senders of all transactions in a segment.
+func recoverSenders(bc *core.BlockChain, seg *Segment) {
+	for _, block := range seg.Blocks {
+		signer := types.MakeSigner(bc.Config(), block.Number())
+		for _, tx := range block.Transactions() {
+			types.Sender(signer, tx)
+		}
+	}
+}
+
+// importSegment imports the blocks of a segment missing from the chain, and
+// checks them against the archived total difficulties.
+func importSegment(bc *core.BlockChain, seg *Segment, fast bool) error {
+	// Skip the genesis block and anything already present
+	start := 0
+	for ; start < len(seg.Blocks); start++ {
+		block := seg.Blocks[start]
+		if block.NumberU64() == 0 {
+			if block.Hash() != bc.Genesis().Hash() {
+				return fmt.Errorf("genesis mismatch: have %x, want %x", block.Hash(), bc.Genesis().Hash())
+			}
+			continue
+		}
+		if fast && !bc.HasBlock(block.Hash(), block.NumberU64()) {
+			break
+		}
+		if !fast && !bc.HasBlockAndState(block.Hash(), block.NumberU64()) {
+			break
+		}
+	}
+	if start == len(seg.Blocks) {
+		log.Info("Skipping segment as all blocks present", "first", seg.Blocks[0].Number(), "last", seg.Blocks[len(seg.Blocks)-1].Number())
+		return nil
+	}
+	blocks := types.Blocks(seg.Blocks[start:])
+	if fast {
+		headers := make([]*types.Header, len(blocks))
+		for i, block := range blocks {
+			headers[i] = block.Header()
+		}
+		if n, err := bc.InsertHeaderChain(headers, 100); err != nil {
+			return fmt.Errorf("invalid header #%d: %v", headers[n].Number, err)
+		}
+		if n, err := bc.InsertReceiptChain(blocks, seg.Receipts[start:]); err != nil {
+			return fmt.Errorf("invalid block #%d: %v", blocks[n].Number(), err)
+		}
+	} else {
+		if n, err := bc.InsertChain(blocks); err != nil {
+			return fmt.Errorf("invalid block #%d: %v", blocks[n].Number(), err)
+		}
+	}
+	if len(seg.TDs) > 0 {
+		for i, block := range blocks {
+			td, want := bc.GetTd(block.Hash(), block.NumberU64()), seg.TDs[start+i]
+			if td == nil || want == nil || td.Cmp(want) != 0 {
+				return fmt.Errorf("block #%d total difficulty mismatch: have %v, archived %v", block.NumberU64(), td, want)
+			}
+		}
+	}
+	return nil
+}
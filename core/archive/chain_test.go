@@ -0,0 +1,217 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/consensus/ethash"
+	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/core/vm"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/params"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+	testGenesis = &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{testAddress: {Balance: big.NewInt(1000000000)}},
+	}
+)
+
+// testChainLength spans two segments, the last one partial, so imports
+// go through the concurrent decoders.
+const testChainLength = SegmentSize + 300
+
+// makeBlockChain generates a chain of n blocks with a transaction in every
+// third one, returning it along with a blockchain holding it.
+func makeBlockChain(t *testing.T, n int) (*core.BlockChain, []*types.Block) {
+	var (
+		db     = ethdb.NewMemDatabase()
+		signer = types.NewEIP155Signer(testGenesis.Config.ChainId)
+	)
+	genesis := testGenesis.MustCommit(db)
+	blocks, _ := core.GenerateChain(testGenesis.Config, genesis, ethash.NewFaker(), db, n, func(i int, block *core.BlockGen) {
+		block.SetCoinbase(common.Address{0x01})
+		if i%3 == 0 {
+			tx, err := types.SignTx(types.NewTransaction(block.TxNonce(testAddress), common.Address{0x02}, big.NewInt(1000), params.TxGas, nil, nil), signer, testKey)
+			if err != nil {
+				panic(err)
+			}
+			block.AddTx(tx)
+		}
+	})
+	bc := newBlockChain(t)
+	if n, err := bc.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	return bc, blocks
+}
+
+// newBlockChain creates an empty blockchain with the test genesis.
+func newBlockChain(t *testing.T) *core.BlockChain {
+	db := ethdb.NewMemDatabase()
+	testGenesis.MustCommit(db)
+
+	bc, err := core.NewBlockChain(db, nil, testGenesis.Config, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	return bc
+}
+
+// exportChain exports the whole canonical chain of bc into an archive.
+func exportChain(t *testing.T, bc *core.BlockChain, receipts, tds bool) []byte {
+	buf := new(bytes.Buffer)
+	if err := Export(bc, buf, 0, bc.CurrentBlock().NumberU64(), receipts, tds); err != nil {
+		t.Fatalf("failed to export chain: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// checkImported verifies that the chain in dst matches the one in src.
+func checkImported(t *testing.T, src, dst *core.BlockChain, head *types.Block) {
+	for number := uint64(1); number <= src.CurrentBlock().NumberU64(); number++ {
+		want := src.GetBlockByNumber(number)
+		have := dst.GetBlockByNumber(number)
+		if have == nil || have.Hash() != want.Hash() {
+			t.Fatalf("block #%d: have %v, want %x", number, have, want.Hash())
+		}
+		if td, wantTd := dst.GetTd(have.Hash(), number), src.GetTd(want.Hash(), number); td == nil || td.Cmp(wantTd) != 0 {
+			t.Fatalf("block #%d: total difficulty %v, want %v", number, td, wantTd)
+		}
+		haveReceipts, wantReceipts := dst.GetReceiptsByHash(have.Hash()), src.GetReceiptsByHash(want.Hash())
+		if types.DeriveSha(haveReceipts) != types.DeriveSha(wantReceipts) {
+			t.Fatalf("block #%d: receipts mismatch", number)
+		}
+	}
+	if head.Hash() != src.CurrentBlock().Hash() {
+		t.Fatalf("head mismatch: have #%d %x, want #%d %x", head.NumberU64(), head.Hash(), src.CurrentBlock().NumberU64(), src.CurrentBlock().Hash())
+	}
+}
+
+// Tests that exported chains can be imported both by executing the blocks and
+// by fast importing them along with their archived receipts.
+func TestChainImportExport(t *testing.T) {
+	src, _ := makeBlockChain(t, testChainLength)
+	defer src.Stop()
+
+	data := exportChain(t, src, true, true)
+
+	// Import by executing all the blocks
+	full := newBlockChain(t)
+	defer full.Stop()
+
+	if err := Import(full, bytes.NewReader(data), false, nil); err != nil {
+		t.Fatalf("full import failed: %v", err)
+	}
+	checkImported(t, src, full, full.CurrentBlock())
+
+	// Fast import, writing the archived receipts without executing anything
+	fast := newBlockChain(t)
+	defer fast.Stop()
+
+	if err := Import(fast, bytes.NewReader(data), true, nil); err != nil {
+		t.Fatalf("fast import failed: %v", err)
+	}
+	checkImported(t, src, fast, fast.CurrentFastBlock())
+	if head := fast.CurrentBlock(); head.NumberU64() != 0 {
+		t.Errorf("fast import executed blocks: head #%d, want #0", head.NumberU64())
+	}
+	if fast.HasState(src.CurrentBlock().Root()) {
+		t.Errorf("fast import wrote the state of the head block")
+	}
+	// Fast imports need receipts in the archive
+	if err := Import(newBlockChain(t), bytes.NewReader(exportChain(t, src, false, true)), true, nil); err == nil {
+		t.Errorf("fast import of archive without receipts succeeded")
+	}
+}
+
+// Tests that blocks already in the chain are skipped on import, so interrupted
+// imports can be resumed from the same archive.
+func TestChainImportPresent(t *testing.T) {
+	src, blocks := makeBlockChain(t, testChainLength)
+	defer src.Stop()
+
+	data := exportChain(t, src, true, true)
+
+	for _, fast := range []bool{false, true} {
+		// Import a segment and a half, then the whole archive over it
+		dst := newBlockChain(t)
+		if n, err := dst.InsertChain(blocks[:SegmentSize+100]); err != nil {
+			t.Fatalf("fast %v: failed to insert block %d: %v", fast, n, err)
+		}
+		if err := Import(dst, bytes.NewReader(data), fast, nil); err != nil {
+			t.Fatalf("fast %v: import over prefix failed: %v", fast, err)
+		}
+		head := dst.CurrentBlock()
+		if fast {
+			head = dst.CurrentFastBlock()
+		}
+		checkImported(t, src, dst, head)
+
+		// Importing the archive again is a no-op
+		if err := Import(dst, bytes.NewReader(data), fast, nil); err != nil {
+			t.Fatalf("fast %v: repeated import failed: %v", fast, err)
+		}
+		checkImported(t, src, dst, head)
+		dst.Stop()
+	}
+}
+
+// Tests that imports are rejected if the archived total difficulties don't
+// match the ones of the imported blocks.
+func TestChainImportTDMismatch(t *testing.T) {
+	src, _ := makeBlockChain(t, testChainLength)
+	defer src.Stop()
+
+	var (
+		blocks   []*types.Block
+		receipts []types.Receipts
+		tds      []*big.Int
+	)
+	for number := uint64(0); number <= src.CurrentBlock().NumberU64(); number++ {
+		block := src.GetBlockByNumber(number)
+		list := src.GetReceiptsByHash(block.Hash())
+		if list == nil {
+			list = types.Receipts{}
+		}
+		blocks = append(blocks, block)
+		receipts = append(receipts, list)
+		tds = append(tds, src.GetTd(block.Hash(), number))
+	}
+	bad := SegmentSize + 10
+	tds[bad] = new(big.Int).Add(tds[bad], common.Big1)
+	data := writeArchive(t, blocks, receipts, tds, SegmentSize)
+
+	for _, fast := range []bool{false, true} {
+		dst := newBlockChain(t)
+		err := Import(dst, bytes.NewReader(data), fast, nil)
+		if err == nil || !strings.Contains(err.Error(), "total difficulty mismatch") {
+			t.Errorf("fast %v: error mismatch: have %v, want total difficulty mismatch", fast, err)
+		}
+		dst.Stop()
+	}
+}
@@ -136,6 +136,15 @@ type BlockChain struct {
 // available in the database. It initialises the default Genchain Validator and
 // Processor.
 func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config) (*BlockChain, error) {
+	return NewBlockChainWithState(db, state.NewDatabase(db), cacheConfig, chainConfig, engine, vmConfig)
+}
+
+// NewBlockChainWithState is like NewBlockChain, but accesses the state through
+// the given state database, which must be backed by db.
+func NewBlockChainWithState(db ethdb.Database, stateDb state.Database, cacheConfig *CacheConfig, chainConfig *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config) (*BlockChain, error) {
+	if err := vm.ValidatePrecompiles(chainConfig); err != nil {
+		return nil, err
+	}
 	if cacheConfig == nil {
 		cacheConfig = &CacheConfig{
 			TrieNodeLimit: 256 * 1024 * 1024,
@@ -153,7 +162,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		cacheConfig:  cacheConfig,
 		db:           db,
 		triegc:       prque.New(nil),
-		stateCache:   state.NewDatabase(db),
+		stateCache:   stateDb,
 		quit:         make(chan struct{}),
 		bodyCache:    bodyCache,
 		bodyRLPCache: bodyRLPCache,
@@ -635,6 +644,11 @@ func (bc *BlockChain) GetUnclesInChain(block *types.Block, length int) []*types.
 	return uncles
 }
 
+// StateCache returns the caching database underpinning the blockchain instance.
+func (bc *BlockChain) StateCache() state.Database {
+	return bc.stateCache
+}
+
 // TrieNode retrieves a blob of data associated with a trie node (or code hash)
 // either from ephemeral in-memory cache, or from persistent storage.
 func (bc *BlockChain) TrieNode(hash common.Hash) ([]byte, error) {
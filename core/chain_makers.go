@@ -171,6 +171,12 @@ func (b *BlockGen) OffsetTime(seconds int64) {
 // values. Inserting them into BlockChain requires use of FakePow or
 // a similar non-validating proof of work implementation.
 func GenerateChain(config *params.ChainConfig, parent *types.Block, engine consensus.Engine, db ethdb.Database, n int, gen func(int, *BlockGen)) ([]*types.Block, []types.Receipts) {
+	return GenerateChainWithState(config, parent, engine, db, state.NewDatabase(db), n, gen)
+}
+
+// GenerateChainWithState is like GenerateChain, but accesses the state through
+// the given state database, which must be backed by db.
+func GenerateChainWithState(config *params.ChainConfig, parent *types.Block, engine consensus.Engine, db ethdb.Database, stateDb state.Database, n int, gen func(int, *BlockGen)) ([]*types.Block, []types.Receipts) {
 	if config == nil {
 		config = params.TestChainConfig
 	}
@@ -216,7 +222,7 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 		return nil, nil
 	}
 	for i := 0; i < n; i++ {
-		statedb, err := state.New(parent.Root(), state.NewDatabase(db))
+		statedb, err := state.New(parent.Root(), stateDb)
 		if err != nil {
 			panic(err)
 		}
@@ -228,6 +234,11 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 	return blocks, receipts
 }
 
+// makeHeader creates the header of the next generated block. The network power
+// fields can't be prepared by the engine, as the generated parents aren't in the
+// chain, so they are set to N = P = 1 and Alpha = 0. This grows the network power
+// by N^3 * P^6 - Alpha = 1 per block, as the rules after the valley and river
+// forks require, and keeps longer generated chains heavier in reorgs.
 func makeHeader(chain consensus.ChainReader, parent *types.Block, state *state.StateDB, engine consensus.Engine) *types.Header {
 	var time *big.Int
 	if parent.Time() == nil {
@@ -249,6 +260,13 @@ func makeHeader(chain consensus.ChainReader, parent *types.Block, state *state.S
 		GasLimit: CalcGasLimit(parent),
 		Number:   new(big.Int).Add(parent.Number(), common.Big1),
 		Time:     time,
+		Rewards:  parent.Rewards(), // Accumulated by Finalize, as for mined blocks
+		N:        1,
+		NN:       parent.N(),
+		P:        1,
+		PP:       parent.P(),
+		Alpha:    new(big.Int),
+		NP:       new(big.Int).Add(parent.NP(), common.Big1),
 	}
 }
 
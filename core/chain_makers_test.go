@@ -39,10 +39,12 @@ func ExampleGenerateChain() {
 		db      = ethdb.NewMemDatabase()
 	)
 
-	// Ensure that key1 has some funds in the genesis block.
+	// Ensure that key1 has some funds in the genesis block. The default genesis
+	// rewards are the whole supply, which would leave nothing to mine.
 	gspec := &Genesis{
-		Config: &params.ChainConfig{HomesteadBlock: new(big.Int)},
-		Alloc:  GenesisAlloc{addr1: {Balance: big.NewInt(1000000)}},
+		Config:  &params.ChainConfig{HomesteadBlock: new(big.Int)},
+		Alloc:   GenesisAlloc{addr1: {Balance: big.NewInt(1000000)}},
+		Rewards: new(big.Int),
 	}
 	genesis := gspec.MustCommit(db)
 
@@ -96,5 +98,5 @@ func ExampleGenerateChain() {
 	// last block: #5
 	// balance of addr1: 989000
 	// balance of addr2: 10000
-	// balance of addr3: 19687500000000001000
+	// balance of addr3: 1416666666666667666
 }
@@ -117,3 +117,132 @@ func WriteBloomBits(db DatabaseWriter, bit uint, section uint64, head common.Has
 		log.Crit("Failed to store bloom bits", "err", err)
 	}
 }
+
+// sectionIndexKey = prefix + address + section (uint64 big endian)
+func sectionIndexKey(prefix []byte, address common.Address, section uint64) []byte {
+	return append(append(prefix, address.Bytes()...), encodeBlockNumber(section)...)
+}
+
+// sectionListKey = prefix + section (uint64 big endian)
+func sectionListKey(prefix []byte, section uint64) []byte {
+	return append(prefix, encodeBlockNumber(section)...)
+}
+
+// readIndexList decodes the RLP list stored under a key of a sectioned address
+// index into v, reporting whether it was found and valid. Corrupt lists are
+// logged with the given kind of data and context.
+func readIndexList(db DatabaseReader, key []byte, v interface{}, kind string, ctx ...interface{}) bool {
+	data, _ := db.Get(key)
+	if len(data) == 0 {
+		return false
+	}
+	if err := rlp.DecodeBytes(data, v); err != nil {
+		log.Error("Invalid "+kind+" RLP", append(ctx, "err", err)...)
+		return false
+	}
+	return true
+}
+
+// writeIndexList stores a list of a sectioned address index under a key.
+func writeIndexList(db DatabaseWriter, key []byte, v interface{}, kind string) {
+	data, err := rlp.EncodeToBytes(v)
+	if err != nil {
+		log.Crit("Failed to encode "+kind, "err", err)
+	}
+	if err := db.Put(key, data); err != nil {
+		log.Crit("Failed to store "+kind, "err", err)
+	}
+}
+
+// deleteIndexList removes a list of a sectioned address index.
+func deleteIndexList(db DatabaseDeleter, key []byte, kind string) {
+	if err := db.Delete(key); err != nil {
+		log.Crit("Failed to delete "+kind, "err", err)
+	}
+}
+
+// ReadTokenTransfers retrieves the token transfers of an address that were
+// indexed in the given section.
+func ReadTokenTransfers(db DatabaseReader, address common.Address, section uint64) []TokenTransferEntry {
+	var transfers []TokenTransferEntry
+	if !readIndexList(db, sectionIndexKey(tokenTransferPrefix, address, section), &transfers, "token transfer list", "address", address, "section", section) {
+		return nil
+	}
+	return transfers
+}
+
+// WriteTokenTransfers stores the token transfers of an address that belong to
+// the given section.
+func WriteTokenTransfers(db DatabaseWriter, address common.Address, section uint64, transfers []TokenTransferEntry) {
+	writeIndexList(db, sectionIndexKey(tokenTransferPrefix, address, section), transfers, "token transfer list")
+}
+
+// DeleteTokenTransfers removes the token transfers of an address that belong
+// to the given section.
+func DeleteTokenTransfers(db DatabaseDeleter, address common.Address, section uint64) {
+	deleteIndexList(db, sectionIndexKey(tokenTransferPrefix, address, section), "token transfer list")
+}
+
+// ReadTokenTransferSection retrieves the addresses with token transfers indexed
+// in the given section.
+func ReadTokenTransferSection(db DatabaseReader, section uint64) []common.Address {
+	var addresses []common.Address
+	if !readIndexList(db, sectionListKey(tokenTransferSectionPrefix, section), &addresses, "token transfer section", "section", section) {
+		return nil
+	}
+	return addresses
+}
+
+// WriteTokenTransferSection stores the addresses with token transfers indexed
+// in the given section.
+func WriteTokenTransferSection(db DatabaseWriter, section uint64, addresses []common.Address) {
+	writeIndexList(db, sectionListKey(tokenTransferSectionPrefix, section), addresses, "token transfer section")
+}
+
+// DeleteTokenTransferSection removes the address list of the given section.
+func DeleteTokenTransferSection(db DatabaseDeleter, section uint64) {
+	deleteIndexList(db, sectionListKey(tokenTransferSectionPrefix, section), "token transfer section")
+}
+
+// ReadAddressTransactions retrieves the transactions involving an address that
+// were indexed in the given section.
+func ReadAddressTransactions(db DatabaseReader, address common.Address, section uint64) []AddressTxEntry {
+	var entries []AddressTxEntry
+	if !readIndexList(db, sectionIndexKey(addressTxPrefix, address, section), &entries, "address transaction list", "address", address, "section", section) {
+		return nil
+	}
+	return entries
+}
+
+// WriteAddressTransactions stores the transactions involving an address that
+// belong to the given section.
+func WriteAddressTransactions(db DatabaseWriter, address common.Address, section uint64, entries []AddressTxEntry) {
+	writeIndexList(db, sectionIndexKey(addressTxPrefix, address, section), entries, "address transaction list")
+}
+
+// DeleteAddressTransactions removes the transactions involving an address that
+// belong to the given section.
+func DeleteAddressTransactions(db DatabaseDeleter, address common.Address, section uint64) {
+	deleteIndexList(db, sectionIndexKey(addressTxPrefix, address, section), "address transaction list")
+}
+
+// ReadAddressTxSection retrieves the addresses with transactions indexed in the
+// given section.
+func ReadAddressTxSection(db DatabaseReader, section uint64) []common.Address {
+	var addresses []common.Address
+	if !readIndexList(db, sectionListKey(addressTxSectionPrefix, section), &addresses, "address transaction section", "section", section) {
+		return nil
+	}
+	return addresses
+}
+
+// WriteAddressTxSection stores the addresses with transactions indexed in the
+// given section.
+func WriteAddressTxSection(db DatabaseWriter, section uint64, addresses []common.Address) {
+	writeIndexList(db, sectionListKey(addressTxSectionPrefix, section), addresses, "address transaction section")
+}
+
+// DeleteAddressTxSection removes the address list of the given section.
+func DeleteAddressTxSection(db DatabaseDeleter, section uint64) {
+	deleteIndexList(db, sectionListKey(addressTxSectionPrefix, section), "address transaction section")
+}
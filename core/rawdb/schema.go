@@ -19,6 +19,7 @@ package rawdb
 
 import (
 	"encoding/binary"
+	"math/big"
 
 	"github.com/genchain/go-genchain/common"
 	"github.com/genchain/go-genchain/metrics"
@@ -53,11 +54,19 @@ var (
 	txLookupPrefix  = []byte("l") // txLookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
 
+	tokenTransferPrefix        = []byte("x") // tokenTransferPrefix + address + section (uint64 big endian) -> token transfers of the address
+	tokenTransferSectionPrefix = []byte("X") // tokenTransferSectionPrefix + section (uint64 big endian) -> addresses with token transfers
+
+	addressTxPrefix        = []byte("a") // addressTxPrefix + address + section (uint64 big endian) -> transactions of the address
+	addressTxSectionPrefix = []byte("A") // addressTxSectionPrefix + section (uint64 big endian) -> addresses with transactions
+
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("genchain-config-") // config prefix for the db
 
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
-	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	BloomBitsIndexPrefix     = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	TokenTransferIndexPrefix = []byte("iT") // TokenTransferIndexPrefix is the data table of the token transfer indexer to track its progress
+	AddressTxIndexPrefix     = []byte("iA") // AddressTxIndexPrefix is the data table of the address transaction indexer to track its progress
 
 	preimageCounter    = metrics.NewRegisteredCounter("db/preimage/total", nil)
 	preimageHitCounter = metrics.NewRegisteredCounter("db/preimage/hits", nil)
@@ -71,6 +80,37 @@ type TxLookupEntry struct {
 	Index      uint64
 }
 
+// TokenTransferEntry is a token transfer log, indexed under the addresses of
+// both its sender and its recipient.
+type TokenTransferEntry struct {
+	BlockHash   common.Hash
+	BlockNumber uint64
+	TxHash      common.Hash
+	LogIndex    uint64
+	Token       common.Address
+	From        common.Address
+	To          common.Address
+	Value       *big.Int // Amount for fungible tokens, token id for non-fungible ones
+	NonFungible bool
+}
+
+// Roles an address can have in a transaction indexed by the address index.
+const (
+	AddressTxSender    = 1 << iota // Address sent the transaction
+	AddressTxRecipient             // Address is the recipient of the transaction
+	AddressTxCreation              // Address is the contract created by the transaction
+)
+
+// AddressTxEntry is a transaction indexed under an address it involves, along
+// with the roles the address has in it.
+type AddressTxEntry struct {
+	BlockHash   common.Hash
+	BlockNumber uint64
+	TxHash      common.Hash
+	TxIndex     uint64
+	Roles       uint8
+}
+
 // encodeBlockNumber encodes a block number as big endian uint64
 func encodeBlockNumber(number uint64) []byte {
 	enc := make([]byte, 8)
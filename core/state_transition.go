@@ -59,6 +59,7 @@ type StateTransition struct {
 	data       []byte
 	state      vm.StateDB
 	evm        *vm.EVM
+	vmerr      error // Error the EVM execution failed with, if it did
 }
 
 // Message represents a message sent to a contract.
@@ -133,6 +134,15 @@ func ApplyMessage(evm *vm.EVM, msg Message, gp *GasPool) ([]byte, uint64, bool,
 	return NewStateTransition(evm, msg, gp).TransitionDb()
 }
 
+// ApplyCall applies a message like ApplyMessage, but returns the error the EVM
+// execution failed with instead of a failure flag, so that calls can tell a
+// revert apart from other failures.
+func ApplyCall(evm *vm.EVM, msg Message, gp *GasPool) ([]byte, uint64, error, error) {
+	st := NewStateTransition(evm, msg, gp)
+	ret, usedGas, _, err := st.TransitionDb()
+	return ret, usedGas, st.vmerr, err
+}
+
 // to returns the recipient of the message.
 func (st *StateTransition) to() common.Address {
 	if st.msg == nil || st.msg.To() == nil /* contract creation */ {
@@ -213,6 +223,7 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 		st.state.SetNonce(msg.From(), st.state.GetNonce(sender.Address())+1)
 		ret, st.gas, vmerr = evm.Call(sender, st.to(), st.data, st.gas, st.value)
 	}
+	st.vmerr = vmerr
 	if vmerr != nil {
 		log.Debug("VM returned with error", "err", vmerr)
 		// The only possible consensus-error would be if there wasn't
@@ -19,13 +19,17 @@ package vm
 import (
 	"crypto/sha256"
 	"errors"
+	"fmt"
 	"math/big"
+	"sync"
 
 	"github.com/genchain/go-genchain/common"
 	"github.com/genchain/go-genchain/common/math"
 	"github.com/genchain/go-genchain/crypto"
 	"github.com/genchain/go-genchain/crypto/bn256"
+	"github.com/genchain/go-genchain/crypto/sha3"
 	"github.com/genchain/go-genchain/params"
+	"golang.org/x/crypto/blake2b"
 	"golang.org/x/crypto/ripemd160"
 )
 
@@ -59,6 +63,80 @@ var PrecompiledContractsByzantium = map[common.Address]PrecompiledContract{
 	common.BytesToAddress([]byte{8}): &bn256Pairing{},
 }
 
+var (
+	registryLock sync.RWMutex
+
+	// precompiledRegistry contains the precompiled contracts chain configs can
+	// activate by name, on top of the default ones of the current release.
+	precompiledRegistry = map[string]PrecompiledContract{
+		"sha3-512": &sha3hash{},
+		"blake2b":  &blake2bhash{},
+	}
+)
+
+// RegisterPrecompiledContract makes a precompiled contract available for chain
+// configs to activate under the given name. The gas requirements are declared by
+// the contract itself through RequiredGas. It panics if the name is already taken.
+func RegisterPrecompiledContract(name string, p PrecompiledContract) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if _, exist := precompiledRegistry[name]; exist {
+		panic(fmt.Sprintf("precompiled contract %q already registered", name))
+	}
+	precompiledRegistry[name] = p
+}
+
+// registeredPrecompiledContract returns the precompiled contract registered under
+// the given name, or nil if there's none.
+func registeredPrecompiledContract(name string) PrecompiledContract {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	return precompiledRegistry[name]
+}
+
+// ValidatePrecompiles checks that all the chain specific precompiled contracts
+// of a chain config are registered and that they don't collide with each other
+// or with the default precompiled contracts.
+func ValidatePrecompiles(config *params.ChainConfig) error {
+	seen := make(map[common.Address]bool)
+	for _, precompile := range config.Precompiles {
+		if registeredPrecompiledContract(precompile.Name) == nil {
+			return fmt.Errorf("unknown precompiled contract %q", precompile.Name)
+		}
+		if _, exist := PrecompiledContractsByzantium[precompile.Address]; exist || seen[precompile.Address] {
+			return fmt.Errorf("precompiled contract %q address %x already in use", precompile.Name, precompile.Address)
+		}
+		seen[precompile.Address] = true
+	}
+	return nil
+}
+
+// ActivePrecompiledContracts returns the precompiled contracts active at block
+// num, the default ones of the current release along with the chain specific ones
+// activated by the chain config. The returned map must not be modified.
+func ActivePrecompiledContracts(config *params.ChainConfig, num *big.Int) map[common.Address]PrecompiledContract {
+	precompiles := PrecompiledContractsHomestead
+	if config.IsByzantium(num) {
+		precompiles = PrecompiledContractsByzantium
+	}
+	active := config.ActivePrecompiles(num)
+	if len(active) == 0 {
+		return precompiles
+	}
+	merged := make(map[common.Address]PrecompiledContract, len(precompiles)+len(active))
+	for addr, p := range precompiles {
+		merged[addr] = p
+	}
+	for _, precompile := range active {
+		if p := registeredPrecompiledContract(precompile.Name); p != nil {
+			merged[precompile.Address] = p
+		}
+	}
+	return merged
+}
+
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
 func RunPrecompiledContract(p PrecompiledContract, input []byte, contract *Contract) (ret []byte, err error) {
 	gas := p.RequiredGas(input)
@@ -132,6 +210,36 @@ func (c *ripemd160hash) Run(input []byte) ([]byte, error) {
 	return common.LeftPadBytes(ripemd.Sum(nil), 32), nil
 }
 
+// SHA3-512 implemented as a native contract.
+type sha3hash struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+//
+// This method does not require any overflow checking as the input size gas costs
+// required for anything significant is so high it's impossible to pay for.
+func (c *sha3hash) RequiredGas(input []byte) uint64 {
+	return uint64(len(input)+31)/32*params.Sha3_512PerWordGas + params.Sha3_512BaseGas
+}
+func (c *sha3hash) Run(input []byte) ([]byte, error) {
+	h := sha3.Sum512(input)
+	return h[:], nil
+}
+
+// BLAKE2b implemented as a native contract.
+type blake2bhash struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+//
+// This method does not require any overflow checking as the input size gas costs
+// required for anything significant is so high it's impossible to pay for.
+func (c *blake2bhash) RequiredGas(input []byte) uint64 {
+	return uint64(len(input)+31)/32*params.Blake2bPerWordGas + params.Blake2bBaseGas
+}
+func (c *blake2bhash) Run(input []byte) ([]byte, error) {
+	h := blake2b.Sum512(input)
+	return h[:], nil
+}
+
 // data copy implemented as a native contract.
 type dataCopy struct{}
 
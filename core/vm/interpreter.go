@@ -19,6 +19,7 @@ package vm
 import (
 	"fmt"
 	"sync/atomic"
+	"time"
 
 	"github.com/genchain/go-genchain/common/math"
 	"github.com/genchain/go-genchain/params"
@@ -102,7 +103,7 @@ func (in *Interpreter) enforceRestrictions(op OpCode, operation operation, stack
 //
 // It's important to note that any errors returned by the interpreter should be
 // considered a revert-and-consume-all-gas operation except for
-// errExecutionReverted which means revert-and-keep-gas-left.
+// ErrExecutionReverted which means revert-and-keep-gas-left.
 func (in *Interpreter) Run(contract *Contract, input []byte) (ret []byte, err error) {
 	// Increment the call depth which is restricted to 1024
 	in.evm.depth++
@@ -130,9 +131,17 @@ func (in *Interpreter) Run(contract *Contract, input []byte) (ret []byte, err er
 		pcCopy  uint64 // needed for the deferred Tracer
 		gasCopy uint64 // for Tracer to log gas remaining before execution
 		logged  bool   // deferred Tracer should ignore already logged steps
+		// execution statistics gathered if the profiler is enabled
+		stats *[256]execStats
+		start time.Time
 	)
 	contract.Input = input
 
+	if Profiling() {
+		stats = new([256]execStats)
+		defer profile.mergeOps(stats)
+	}
+
 	if in.cfg.Debug {
 		defer func() {
 			if err != nil {
@@ -153,6 +162,9 @@ func (in *Interpreter) Run(contract *Contract, input []byte) (ret []byte, err er
 			// Capture pre-execution values for tracing.
 			logged, pcCopy, gasCopy = false, pc, contract.Gas
 		}
+		if stats != nil {
+			start = time.Now()
+		}
 
 		// Get the operation from the jump table and validate the stack to ensure there are
 		// enough stack items available to perform the operation.
@@ -200,6 +212,9 @@ func (in *Interpreter) Run(contract *Contract, input []byte) (ret []byte, err er
 
 		// execute the operation
 		res, err := operation.execute(&pc, in.evm, contract, mem, stack)
+		if stats != nil {
+			stats[op].add(cost, time.Since(start))
+		}
 		// verifyPool is a build flag. Pool verification makes sure the integrity
 		// of the integer pool by comparing values to a default value.
 		if verifyPool {
@@ -215,7 +230,7 @@ func (in *Interpreter) Run(contract *Contract, input []byte) (ret []byte, err er
 		case err != nil:
 			return nil, err
 		case operation.reverts:
-			return res, errExecutionReverted
+			return res, ErrExecutionReverted
 		case operation.halts:
 			return res, nil
 		case !operation.jumps:
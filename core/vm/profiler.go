@@ -0,0 +1,217 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/metrics"
+)
+
+// profiling is the global switch of the execution profiler. It's checked once
+// per interpreter run, so a disabled profiler costs a single atomic load.
+var profiling int32
+
+// profile is the global execution profile all interpreters report into.
+var profile = &profiler{precompiles: make(map[common.Address]*execStats)}
+
+// execStats contains the accumulated execution statistics of an opcode or a
+// precompiled contract.
+type execStats struct {
+	count uint64
+	gas   uint64
+	time  time.Duration
+}
+
+// add accumulates a single execution into the statistics.
+func (s *execStats) add(gas uint64, elapsed time.Duration) {
+	s.count++
+	s.gas += gas
+	s.time += elapsed
+}
+
+// execMetrics are the metrics registry entries an execStats is exported to.
+type execMetrics struct {
+	count metrics.Counter
+	gas   metrics.Counter
+	time  metrics.Counter
+}
+
+// newExecMetrics registers the metrics of an opcode or precompile with the given
+// name prefix in the default registry.
+func newExecMetrics(prefix string) *execMetrics {
+	return &execMetrics{
+		count: metrics.GetOrRegisterCounter(prefix+"/count", nil),
+		gas:   metrics.GetOrRegisterCounter(prefix+"/gas", nil),
+		time:  metrics.GetOrRegisterCounter(prefix+"/time", nil),
+	}
+}
+
+// update exports accumulated execution statistics into the metrics.
+func (m *execMetrics) update(s *execStats) {
+	m.count.Inc(int64(s.count))
+	m.gas.Inc(int64(s.gas))
+	m.time.Inc(int64(s.time))
+}
+
+// profiler aggregates the execution statistics gathered by individual interpreter
+// runs and exports them into the metrics registry.
+type profiler struct {
+	lock sync.Mutex
+
+	ops         [256]execStats
+	opMetrics   [256]*execMetrics
+	precompiles map[common.Address]*execStats
+	preMetrics  map[common.Address]*execMetrics
+}
+
+// mergeOps folds the opcode statistics of a single interpreter run into the
+// global profile.
+func (p *profiler) mergeOps(ops *[256]execStats) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for op := range ops {
+		if ops[op].count == 0 {
+			continue
+		}
+		p.ops[op].count += ops[op].count
+		p.ops[op].gas += ops[op].gas
+		p.ops[op].time += ops[op].time
+
+		if metrics.Enabled {
+			if p.opMetrics[op] == nil {
+				p.opMetrics[op] = newExecMetrics("evm/op/" + OpCode(op).String())
+			}
+			p.opMetrics[op].update(&ops[op])
+		}
+	}
+}
+
+// addPrecompile accumulates a single precompiled contract execution into the
+// global profile.
+func (p *profiler) addPrecompile(addr common.Address, gas uint64, elapsed time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	stats := p.precompiles[addr]
+	if stats == nil {
+		stats = new(execStats)
+		p.precompiles[addr] = stats
+	}
+	stats.add(gas, elapsed)
+
+	if metrics.Enabled {
+		if p.preMetrics == nil {
+			p.preMetrics = make(map[common.Address]*execMetrics)
+		}
+		if p.preMetrics[addr] == nil {
+			p.preMetrics[addr] = newExecMetrics(fmt.Sprintf("evm/precompile/%x", addr))
+		}
+		p.preMetrics[addr].update(&execStats{count: 1, gas: gas, time: elapsed})
+	}
+}
+
+// runProfiledPrecompile runs a precompiled contract, accounting its execution
+// in the global profile.
+func runProfiledPrecompile(p PrecompiledContract, addr common.Address, input []byte, contract *Contract) ([]byte, error) {
+	start, gas := time.Now(), contract.Gas
+
+	ret, err := RunPrecompiledContract(p, input, contract)
+	profile.addPrecompile(addr, gas-contract.Gas, time.Since(start))
+
+	return ret, err
+}
+
+// SetProfiling enables or disables the collection of opcode and precompiled
+// contract execution statistics by all interpreters.
+func SetProfiling(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&profiling, 1)
+	} else {
+		atomic.StoreInt32(&profiling, 0)
+	}
+}
+
+// Profiling returns whether execution statistics are currently being collected.
+func Profiling() bool {
+	return atomic.LoadInt32(&profiling) == 1
+}
+
+// ResetProfile discards all the execution statistics collected so far. Values
+// already exported into the metrics registry are not affected.
+func ResetProfile() {
+	profile.lock.Lock()
+	defer profile.lock.Unlock()
+
+	profile.ops = [256]execStats{}
+	profile.precompiles = make(map[common.Address]*execStats)
+}
+
+// OpcodeProfile contains the execution statistics of a single opcode. The time
+// of opcodes calling into other contracts includes the time spent in the callee.
+type OpcodeProfile struct {
+	Op    string        `json:"op"`
+	Count uint64        `json:"count"`
+	Gas   uint64        `json:"gas"`
+	Time  time.Duration `json:"time"`
+}
+
+// PrecompileProfile contains the execution statistics of a precompiled contract.
+type PrecompileProfile struct {
+	Address common.Address `json:"address"`
+	Count   uint64         `json:"count"`
+	Gas     uint64         `json:"gas"`
+	Time    time.Duration  `json:"time"`
+}
+
+// ExecutionProfile is a snapshot of the execution statistics collected by the
+// profiler, with entries ordered by decreasing cumulative time.
+type ExecutionProfile struct {
+	Enabled     bool                `json:"enabled"`
+	Opcodes     []OpcodeProfile     `json:"opcodes"`
+	Precompiles []PrecompileProfile `json:"precompiles"`
+}
+
+// Profile returns a snapshot of the execution statistics collected so far.
+func Profile() *ExecutionProfile {
+	profile.lock.Lock()
+	defer profile.lock.Unlock()
+
+	result := &ExecutionProfile{
+		Enabled:     Profiling(),
+		Opcodes:     []OpcodeProfile{},
+		Precompiles: []PrecompileProfile{},
+	}
+	for op, stats := range profile.ops {
+		if stats.count > 0 {
+			result.Opcodes = append(result.Opcodes, OpcodeProfile{OpCode(op).String(), stats.count, stats.gas, stats.time})
+		}
+	}
+	for addr, stats := range profile.precompiles {
+		result.Precompiles = append(result.Precompiles, PrecompileProfile{addr, stats.count, stats.gas, stats.time})
+	}
+	sort.Slice(result.Opcodes, func(i, j int) bool { return result.Opcodes[i].Time > result.Opcodes[j].Time })
+	sort.Slice(result.Precompiles, func(i, j int) bool { return result.Precompiles[i].Time > result.Precompiles[j].Time })
+
+	return result
+}
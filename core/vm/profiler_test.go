@@ -0,0 +1,88 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/params"
+)
+
+// Tests that the profiler only collects statistics while enabled and that they
+// account for every executed opcode and precompile.
+func TestProfiler(t *testing.T) {
+	defer SetProfiling(false)
+	defer ResetProfile()
+
+	var (
+		env  = NewEVM(Context{}, nil, params.TestChainConfig, Config{})
+		code = []byte{byte(PUSH1), 0x01, byte(PUSH1), 0x02, byte(ADD), byte(POP), byte(STOP)}
+	)
+	run := func() {
+		contract := NewContract(&dummyContractRef{}, &dummyContractRef{}, new(big.Int), 100000)
+		contract.Code = code
+		if _, err := env.interpreter.Run(contract, nil); err != nil {
+			t.Fatalf("failed to run code: %v", err)
+		}
+		identity := common.BytesToAddress([]byte{4})
+
+		contract = NewContract(&dummyContractRef{}, &dummyContractRef{}, new(big.Int), 100000)
+		contract.CodeAddr = &identity
+		if _, err := run(env, contract, []byte("hello")); err != nil {
+			t.Fatalf("failed to run precompile: %v", err)
+		}
+	}
+	ResetProfile()
+	run()
+	if profile := Profile(); len(profile.Opcodes) != 0 || len(profile.Precompiles) != 0 {
+		t.Fatalf("statistics collected while disabled: %+v", profile)
+	}
+	SetProfiling(true)
+	run()
+	run()
+
+	profile := Profile()
+	if !profile.Enabled {
+		t.Errorf("profiler reported as disabled")
+	}
+	want := map[string]OpcodeProfile{
+		"PUSH1": {Count: 4, Gas: 4 * GasFastestStep},
+		"ADD":   {Count: 2, Gas: 2 * GasFastestStep},
+		"POP":   {Count: 2, Gas: 2 * GasQuickStep},
+		"STOP":  {Count: 2, Gas: 0},
+	}
+	if len(profile.Opcodes) != len(want) {
+		t.Fatalf("opcode count mismatch: have %d, want %d", len(profile.Opcodes), len(want))
+	}
+	for _, op := range profile.Opcodes {
+		if op.Count != want[op.Op].Count || op.Gas != want[op.Op].Gas {
+			t.Errorf("%s: statistics mismatch: have %d/%d, want %d/%d", op.Op, op.Count, op.Gas, want[op.Op].Count, want[op.Op].Gas)
+		}
+	}
+	if len(profile.Precompiles) != 1 {
+		t.Fatalf("precompile count mismatch: have %d, want 1", len(profile.Precompiles))
+	}
+	if pre := profile.Precompiles[0]; pre.Address != common.BytesToAddress([]byte{4}) || pre.Count != 2 || pre.Gas != 2*(params.IdentityBaseGas+params.IdentityPerWordGas) {
+		t.Errorf("precompile statistics mismatch: have %+v", pre)
+	}
+	ResetProfile()
+	if profile := Profile(); len(profile.Opcodes) != 0 || len(profile.Precompiles) != 0 {
+		t.Errorf("statistics retained after reset: %+v", profile)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/genchain/go-genchain/core/state"
 	"github.com/genchain/go-genchain/core/vm"
 	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/params"
 )
 
 func TestDefaults(t *testing.T) {
@@ -116,6 +117,76 @@ func TestCall(t *testing.T) {
 	}
 }
 
+// Tests that chain specific precompiled contracts are only callable from their
+// activation block onwards, and that they charge their declared gas.
+func TestChainPrecompiles(t *testing.T) {
+	config := &params.ChainConfig{
+		ChainId:        big.NewInt(1),
+		HomesteadBlock: new(big.Int),
+		EIP150Block:    new(big.Int),
+		EIP155Block:    new(big.Int),
+		EIP158Block:    new(big.Int),
+		ByzantiumBlock: new(big.Int),
+		Precompiles: []*params.PrecompileConfig{
+			{Name: "sha3-512", Address: common.HexToAddress("0x0100"), Block: big.NewInt(5)},
+			{Name: "blake2b", Address: common.HexToAddress("0x0101"), Block: big.NewInt(10)},
+		},
+	}
+	if err := vm.ValidatePrecompiles(config); err != nil {
+		t.Fatalf("failed to validate precompiles: %v", err)
+	}
+	// caller forwards its call data to a precompile with the given gas allowance
+	// and returns its output
+	caller := func(addr byte, gas byte) []byte {
+		return []byte{
+			byte(vm.CALLDATASIZE), byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.CALLDATACOPY),
+			byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.CALLDATASIZE), byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+			byte(vm.PUSH2), 0x01, addr, byte(vm.PUSH1), gas, byte(vm.CALL), byte(vm.POP),
+			byte(vm.RETURNDATASIZE), byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.RETURNDATACOPY),
+			byte(vm.RETURNDATASIZE), byte(vm.PUSH1), 0, byte(vm.RETURN),
+		}
+	}
+	tests := []struct {
+		addr   byte
+		number int64
+		output string
+	}{
+		{0x00, 4, ""},
+		{0x00, 5, "75d527c368f2efe848ecf6b073a36767800805e9eef2b1857d5f984f036eb6df891d75f72d9b154518c1cd58835286d1da9a38deba3de98b5a53e5ed78a84976"},
+		{0x01, 9, ""},
+		{0x01, 10, "e4cfa39a3d37be31c59609e807970799caa68a19bfaa15135f165085e01d41a65ba1e1b146aeb6bd0092b49eac214c103ccfa3a365954bbbe52f74a2b3620c94"},
+	}
+	for i, tt := range tests {
+		ret, _, err := Execute(caller(tt.addr, 0xff), []byte("hello"), &Config{ChainConfig: config, BlockNumber: big.NewInt(tt.number)})
+		if err != nil {
+			t.Fatalf("test %d: failed to execute: %v", i, err)
+		}
+		if common.Bytes2Hex(ret) != tt.output {
+			t.Errorf("test %d: output mismatch: have %x, want %s", i, ret, tt.output)
+		}
+	}
+	// Ensure the precompile gas is charged, failing the call without enough of it
+	gas := params.Sha3_512BaseGas + params.Sha3_512PerWordGas
+	for _, allowance := range []uint64{gas - 1, gas} {
+		ret, _, err := Execute(caller(0x00, byte(allowance)), []byte("hello"), &Config{ChainConfig: config, BlockNumber: big.NewInt(5)})
+		if err != nil {
+			t.Fatalf("allowance %d: failed to execute: %v", allowance, err)
+		}
+		if executed := len(ret) > 0; executed != (allowance >= gas) {
+			t.Errorf("allowance %d: execution mismatch: have %v, want %v", allowance, executed, allowance >= gas)
+		}
+	}
+	// Ensure invalid precompile configurations are rejected
+	config.Precompiles = append(config.Precompiles, &params.PrecompileConfig{Name: "unknown", Address: common.HexToAddress("0x0102")})
+	if err := vm.ValidatePrecompiles(config); err == nil {
+		t.Errorf("unknown precompile accepted")
+	}
+	config.Precompiles[2] = &params.PrecompileConfig{Name: "blake2b", Address: common.HexToAddress("0x01")}
+	if err := vm.ValidatePrecompiles(config); err == nil {
+		t.Errorf("precompile overriding ecrecover accepted")
+	}
+}
+
 func BenchmarkCall(b *testing.B) {
 	var definition = `[{"constant":true,"inputs":[],"name":"seller","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"abort","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"value","outputs":[{"name":"","type":"uint256"}],"type":"function"},{"constant":false,"inputs":[],"name":"refund","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"buyer","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmReceived","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"state","outputs":[{"name":"","type":"uint8"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmPurchase","outputs":[],"type":"function"},{"inputs":[],"type":"constructor"},{"anonymous":false,"inputs":[],"name":"Aborted","type":"event"},{"anonymous":false,"inputs":[],"name":"PurchaseConfirmed","type":"event"},{"anonymous":false,"inputs":[],"name":"ItemReceived","type":"event"},{"anonymous":false,"inputs":[],"name":"Refunded","type":"event"}]`
 
@@ -429,6 +429,10 @@ func (ec *Client) PendingTransactionCount(ctx context.Context) (uint, error) {
 // blockNumber selects the block height at which the call runs. It can be nil, in which
 // case the code is taken from the latest known block. Note that state from very old
 // blocks might not be available.
+//
+// Calls that fail return an error: if the contract reverted, an rpc.Error with
+// code 3 carrying the hex encoded revert data, otherwise the error of the VM,
+// e.g. "out of gas".
 func (ec *Client) CallContract(ctx context.Context, msg genchain.CallMsg, blockNumber *big.Int) ([]byte, error) {
 	var hex hexutil.Bytes
 	err := ec.c.CallContext(ctx, &hex, "gen_call", toCallArg(msg), toBlockNumArg(blockNumber))
@@ -0,0 +1,160 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package gen
+
+import (
+	"time"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/core/rawdb"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/log"
+	"github.com/genchain/go-genchain/params"
+)
+
+const (
+	// addressSectionSize is the number of blocks in a single address transaction
+	// index section.
+	addressSectionSize = 1024
+
+	// addressConfirms is the number of confirmation blocks before an address
+	// transaction section is considered final and gets indexed.
+	addressConfirms = 256
+
+	// addressThrottling is the time to wait between processing two consecutive
+	// index sections. It's useful to keep the background backfill of an already
+	// synced chain from starving block imports.
+	addressThrottling = 100 * time.Millisecond
+)
+
+// addressTxRoles returns the addresses involved in a transaction along with the
+// role each of them has in it.
+func addressTxRoles(signer types.Signer, tx *types.Transaction) (map[common.Address]uint8, error) {
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		return nil, err
+	}
+	roles := map[common.Address]uint8{from: rawdb.AddressTxSender}
+	if to := tx.To(); to != nil {
+		roles[*to] |= rawdb.AddressTxRecipient
+	} else {
+		roles[crypto.CreateAddress(from, tx.Nonce())] |= rawdb.AddressTxCreation
+	}
+	return roles, nil
+}
+
+// blockAddressTxs returns the transactions of a block grouped by the addresses
+// they involve. Transactions with an unrecoverable sender are skipped, they
+// can't be attributed to anyone.
+func blockAddressTxs(db ethdb.Database, config *params.ChainConfig, header *types.Header) map[common.Address][]rawdb.AddressTxEntry {
+	var (
+		hash   = header.Hash()
+		number = header.Number.Uint64()
+		signer = types.MakeSigner(config, header.Number)
+	)
+	body := rawdb.ReadBody(db, hash, number)
+	if body == nil {
+		log.Error("Missing block body for address index", "number", number, "hash", hash)
+		return nil
+	}
+	entries := make(map[common.Address][]rawdb.AddressTxEntry)
+	for i, tx := range body.Transactions {
+		roles, err := addressTxRoles(signer, tx)
+		if err != nil {
+			log.Error("Failed to derive transaction sender", "number", number, "hash", hash, "tx", tx.Hash(), "err", err)
+			continue
+		}
+		for address, role := range roles {
+			entries[address] = append(entries[address], rawdb.AddressTxEntry{
+				BlockHash:   hash,
+				BlockNumber: number,
+				TxHash:      tx.Hash(),
+				TxIndex:     uint64(i),
+				Roles:       role,
+			})
+		}
+	}
+	return entries
+}
+
+// AddressIndexer implements a core.ChainIndexerBackend, building up an index of
+// the transactions sent, received or creating a contract by every address on the
+// canonical chain.
+//
+// The index is stored in sections, a separate list of transactions maintained for
+// every address active in a section, together with a list of those addresses to
+// allow dropping the section if it's ever reprocessed after a reorg.
+type AddressIndexer struct {
+	size   uint64              // section size to generate the address index for
+	db     ethdb.Database      // database instance to write index data and metadata into
+	config *params.ChainConfig // chain configuration to derive transaction signers from
+
+	section uint64                                    // Section is the section number being processed currently
+	entries map[common.Address][]rawdb.AddressTxEntry // Transactions gathered in the current section
+}
+
+// NewAddressIndexer returns a chain indexer that maintains the address
+// transaction index of the canonical chain. When started on an already synced
+// chain, the indexer backfills the index of all past sections in the background.
+func NewAddressIndexer(db ethdb.Database, config *params.ChainConfig, size uint64) *core.ChainIndexer {
+	backend := &AddressIndexer{
+		db:     db,
+		size:   size,
+		config: config,
+	}
+	table := ethdb.NewTable(db, string(rawdb.AddressTxIndexPrefix))
+
+	return core.NewChainIndexer(db, table, backend, size, addressConfirms, addressThrottling, "addresses")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new address transaction
+// index section and dropping any stale data from a previous run over it.
+func (a *AddressIndexer) Reset(section uint64, lastSectionHead common.Hash) error {
+	for _, address := range rawdb.ReadAddressTxSection(a.db, section) {
+		rawdb.DeleteAddressTransactions(a.db, address, section)
+	}
+	rawdb.DeleteAddressTxSection(a.db, section)
+
+	a.section, a.entries = section, make(map[common.Address][]rawdb.AddressTxEntry)
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, adding the transactions of a new
+// header's block body into the index.
+func (a *AddressIndexer) Process(header *types.Header) {
+	for address, entries := range blockAddressTxs(a.db, a.config, header) {
+		a.entries[address] = append(a.entries[address], entries...)
+	}
+}
+
+// Commit implements core.ChainIndexerBackend, finalizing the address transaction
+// section and writing it out into the database.
+func (a *AddressIndexer) Commit() error {
+	batch := a.db.NewBatch()
+
+	addresses := make([]common.Address, 0, len(a.entries))
+	for address, entries := range a.entries {
+		rawdb.WriteAddressTransactions(batch, address, a.section, entries)
+		addresses = append(addresses, address)
+	}
+	rawdb.WriteAddressTxSection(batch, a.section, addresses)
+
+	return batch.Write()
+}
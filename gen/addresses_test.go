@@ -0,0 +1,148 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package gen
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/hexutil"
+	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/core/rawdb"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/params"
+	"github.com/genchain/go-genchain/rpc"
+)
+
+// Tests that the address indexer indexes transactions under their senders,
+// recipients and created contracts, and drops stale data on reprocessing.
+func TestAddressIndexer(t *testing.T) {
+	var (
+		db      = ethdb.NewMemDatabase()
+		backend = &AddressIndexer{db: db, size: 4, config: params.TestChainConfig}
+
+		key, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender    = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+		contract  = crypto.CreateAddress(sender, 1)
+	)
+	sign := func(tx *types.Transaction) *types.Transaction {
+		signed, err := types.SignTx(tx, types.HomesteadSigner{}, key)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		return signed
+	}
+	write := func(number uint64, txs ...*types.Transaction) *types.Header {
+		header := &types.Header{Number: new(big.Int).SetUint64(number), Extra: []byte{byte(len(txs))}}
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteBody(db, header.Hash(), number, &types.Body{Transactions: txs})
+		return header
+	}
+	headers := []*types.Header{
+		write(4, sign(types.NewTransaction(0, recipient, big.NewInt(1), 21000, big.NewInt(1), nil))),
+		write(5, sign(types.NewContractCreation(1, big.NewInt(0), 100000, big.NewInt(1), []byte{0x00}))),
+		write(6),
+		write(7, sign(types.NewTransaction(2, sender, big.NewInt(1), 21000, big.NewInt(1), nil)), sign(types.NewTransaction(3, contract, big.NewInt(1), 21000, big.NewInt(1), nil))),
+	}
+	indexSection(t, backend, 1, headers)
+
+	check := func(address common.Address, want ...uint8) {
+		entries := rawdb.ReadAddressTransactions(db, address, 1)
+		if len(entries) != len(want) {
+			t.Errorf("address %x: transaction count mismatch: have %d, want %d", address, len(entries), len(want))
+			return
+		}
+		for i, entry := range entries {
+			if entry.Roles != want[i] {
+				t.Errorf("address %x, tx %d: roles mismatch: have %b, want %b", address, i, entry.Roles, want[i])
+			}
+		}
+	}
+	check(sender, rawdb.AddressTxSender, rawdb.AddressTxSender, rawdb.AddressTxSender|rawdb.AddressTxRecipient, rawdb.AddressTxSender)
+	check(recipient, rawdb.AddressTxRecipient)
+	check(contract, rawdb.AddressTxCreation, rawdb.AddressTxRecipient)
+
+	// Reprocess the section after a reorg replaced the last block
+	headers[3] = write(7)
+	indexSection(t, backend, 1, headers)
+
+	check(sender, rawdb.AddressTxSender, rawdb.AddressTxSender)
+	check(recipient, rawdb.AddressTxRecipient)
+	check(contract, rawdb.AddressTxCreation)
+}
+
+// Tests the paging of address transaction queries over the indexed and the not
+// yet indexed parts of the chain, and that transactions with an unrecoverable
+// sender in the scanned blocks are skipped instead of failing the query.
+func TestGetTransactionsByAddress(t *testing.T) {
+	gen := newIndexTestChain(t, func(gen *Genchain) core.ChainIndexerBackend {
+		return &AddressIndexer{db: gen.chainDb, size: indexTestSize, config: gen.chainConfig}
+	})
+	api := NewPublicAddressAPI(gen)
+
+	query := func(address common.Address) indexTestQuery {
+		return func(from, to *rpc.BlockNumber, cursor *indexCursor) ([]indexCursor, *indexCursor, error) {
+			var resume *TxCursor
+			if cursor != nil {
+				resume = &TxCursor{BlockNumber: hexutil.Uint64(cursor.number), TxIndex: hexutil.Uint(cursor.index)}
+			}
+			page, err := api.GetTransactionsByAddress(context.Background(), address, from, to, resume)
+			if err != nil {
+				return nil, nil, err
+			}
+			results := make([]indexCursor, len(page.Transactions))
+			for i, tx := range page.Transactions {
+				if tx.Sent != (address == indexTestSender) || tx.Received != (address == transferToken) || tx.Created {
+					t.Errorf("address %x: transaction roles mismatch: have %+v", address, tx)
+				}
+				results[i] = indexCursor{number: uint64(tx.BlockNumber), index: uint64(tx.TransactionIndex)}
+			}
+			var next *indexCursor
+			if page.Next != nil {
+				next = &indexCursor{number: uint64(page.Next.BlockNumber), index: uint64(page.Next.TxIndex)}
+			}
+			return results, next, nil
+		}
+	}
+	testPagedIndexAPI(t, api.index, query(indexTestSender))
+	testPagedIndexAPI(t, api.index, query(transferToken))
+
+	// Append a transaction signed for another chain to a block not yet indexed
+	var (
+		number = uint64(indexTestBlocks - 1)
+		hash   = rawdb.ReadCanonicalHash(gen.chainDb, number)
+		body   = rawdb.ReadBody(gen.chainDb, hash, number)
+	)
+	tx, _ := types.SignTx(types.NewTransaction(0, transferToken, new(big.Int), 21000, big.NewInt(1), nil), types.NewEIP155Signer(big.NewInt(999)), indexTestKey)
+	body.Transactions = append(body.Transactions, tx)
+	rawdb.WriteBody(gen.chainDb, hash, number, body)
+
+	api.index.sections = func() uint64 { return indexTestSections }
+	block := rpc.BlockNumber(number)
+	results, _, err := query(indexTestSender)(&block, &block, nil)
+	if err != nil {
+		t.Fatalf("query over unrecoverable sender failed: %v", err)
+	}
+	if len(results) != indexTestTxs {
+		t.Errorf("transaction count mismatch: have %d, want %d", len(results), indexTestTxs)
+	}
+}
@@ -17,6 +17,7 @@
 package gen
 
 import (
+	"bufio"
 	"compress/gzip"
 	"context"
 	"errors"
@@ -29,9 +30,12 @@ import (
 	"github.com/genchain/go-genchain/common"
 	"github.com/genchain/go-genchain/common/hexutil"
 	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/core/archive"
 	"github.com/genchain/go-genchain/core/rawdb"
 	"github.com/genchain/go-genchain/core/state"
 	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/core/vm"
+	"github.com/genchain/go-genchain/gen/filters"
 	"github.com/genchain/go-genchain/log"
 	"github.com/genchain/go-genchain/miner"
 	"github.com/genchain/go-genchain/params"
@@ -212,8 +216,21 @@ func NewPrivateAdminAPI(gen *Genchain) *PrivateAdminAPI {
 	return &PrivateAdminAPI{gen: gen}
 }
 
-// ExportChain exports the current blockchain into a local file.
-func (api *PrivateAdminAPI) ExportChain(file string) (bool, error) {
+// ExportChain exports the current blockchain into a local file. The optional
+// format is either "rlp" for a plain stream of RLP encoded blocks, the default,
+// or "archive" for a chain archive including the receipts and total difficulties
+// of the blocks.
+func (api *PrivateAdminAPI) ExportChain(file string, format *string) (bool, error) {
+	archived := false
+	if format != nil {
+		switch *format {
+		case "rlp":
+		case "archive":
+			archived = true
+		default:
+			return false, fmt.Errorf("unknown export format %q", *format)
+		}
+	}
 	// Make sure we can create the file to export into
 	out, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
 	if err != nil {
@@ -228,7 +245,18 @@ func (api *PrivateAdminAPI) ExportChain(file string) (bool, error) {
 	}
 
 	// Export the blockchain
-	if err := api.gen.BlockChain().Export(writer); err != nil {
+	chain := api.gen.BlockChain()
+	if !archived {
+		if err := chain.Export(writer); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	buffered := bufio.NewWriter(writer)
+	if err := archive.Export(chain, buffered, 0, chain.CurrentBlock().NumberU64(), true, true); err != nil {
+		return false, err
+	}
+	if err := buffered.Flush(); err != nil {
 		return false, err
 	}
 	return true, nil
@@ -244,7 +272,8 @@ func hasAllBlocks(chain *core.BlockChain, bs []*types.Block) bool {
 	return true
 }
 
-// ImportChain imports a blockchain from a local file.
+// ImportChain imports a blockchain from a local file, being either a chain archive
+// or a plain stream of RLP encoded blocks.
 func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	// Make sure the can access the file to import
 	in, err := os.Open(file)
@@ -260,8 +289,15 @@ func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 		}
 	}
 
+	buffered := bufio.NewReader(reader)
+	if archive.IsArchive(buffered) {
+		if err := archive.Import(api.gen.BlockChain(), buffered, false, nil); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
 	// Run actual the import in pre-configured batches
-	stream := rlp.NewStream(reader, 0)
+	stream := rlp.NewStream(buffered, 0)
 
 	blocks, index := make([]*types.Block, 0, 2500), 0
 	for batch := 0; ; batch++ {
@@ -357,6 +393,29 @@ func (api *PrivateDebugAPI) GetBadBlocks(ctx context.Context) ([]core.BadBlockAr
 	return api.gen.BlockChain().BadBlocks()
 }
 
+// StartEvmProfile enables the collection of per-opcode and per-precompile EVM
+// execution statistics.
+func (api *PrivateDebugAPI) StartEvmProfile() {
+	vm.SetProfiling(true)
+}
+
+// StopEvmProfile disables the collection of EVM execution statistics, keeping
+// the ones collected so far.
+func (api *PrivateDebugAPI) StopEvmProfile() {
+	vm.SetProfiling(false)
+}
+
+// ResetEvmProfile discards all the EVM execution statistics collected so far.
+func (api *PrivateDebugAPI) ResetEvmProfile() {
+	vm.ResetProfile()
+}
+
+// EvmProfile returns the EVM execution statistics collected since the profiler
+// was last reset, ordered by cumulative execution time.
+func (api *PrivateDebugAPI) EvmProfile() *vm.ExecutionProfile {
+	return vm.Profile()
+}
+
 // StorageRangeResult is the result of a debug_storageRangeAt API call.
 type StorageRangeResult struct {
 	Storage storageMap   `json:"storage"`
@@ -488,3 +547,235 @@ func (api *PrivateDebugAPI) getModifiedAccounts(startBlock, endBlock *types.Bloc
 	}
 	return dirty, nil
 }
+
+// transferPageSize is the maximum number of token transfers returned by a single
+// token transfer query.
+const transferPageSize = 1000
+
+// transferMaxScan is the maximum number of blocks beyond the indexed sections a
+// token transfer query may scan directly.
+const transferMaxScan = 2 * (transferSectionSize + transferConfirms)
+
+// RPCTokenTransfer is a single ERC20 or ERC721 token transfer returned by the
+// token transfer index.
+type RPCTokenTransfer struct {
+	BlockHash   common.Hash    `json:"blockHash"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	TxHash      common.Hash    `json:"transactionHash"`
+	LogIndex    hexutil.Uint   `json:"logIndex"`
+	Token       common.Address `json:"token"`
+	From        common.Address `json:"from"`
+	To          common.Address `json:"to"`
+	Value       *hexutil.Big   `json:"value,omitempty"`
+	TokenID     *hexutil.Big   `json:"tokenId,omitempty"`
+}
+
+// TokenTransferPage is a single page of token transfers. Next is nil if the
+// queried range is exhausted.
+type TokenTransferPage struct {
+	Transfers []*RPCTokenTransfer `json:"transfers"`
+	Next      *filters.LogCursor  `json:"next"`
+}
+
+// position implements indexEntry, positioning the transfer within the chain.
+func (t *RPCTokenTransfer) position() (uint64, common.Hash, uint64) {
+	return uint64(t.BlockNumber), t.BlockHash, uint64(t.LogIndex)
+}
+
+// PublicTransferAPI provides access to the token transfer index of the node.
+type PublicTransferAPI struct {
+	index *sectionIndex
+}
+
+// NewPublicTransferAPI creates a new API definition for querying the token
+// transfer index of the Genchain service.
+func NewPublicTransferAPI(gen *Genchain) *PublicTransferAPI {
+	return &PublicTransferAPI{
+		index: &sectionIndex{
+			name:     "token transfer",
+			size:     gen.transfers.size,
+			maxScan:  transferMaxScan,
+			pageSize: transferPageSize,
+			sections: indexedSections(gen.transferIndexer),
+			chain:    gen.blockchain,
+			db:       gen.chainDb,
+		},
+	}
+}
+
+// GetTokenTransfers returns the ERC20 and ERC721 token transfers sent or received
+// by an address within the given block range, optionally limited to a single
+// token contract. Results are paged, the returned cursor can be used to resume
+// the query where the previous page ended.
+func (api *PublicTransferAPI) GetTokenTransfers(ctx context.Context, address common.Address, token *common.Address, fromBlock, toBlock *rpc.BlockNumber, cursor *filters.LogCursor) (*TokenTransferPage, error) {
+	var resume *indexCursor
+	if cursor != nil {
+		resume = &indexCursor{number: uint64(cursor.BlockNumber), index: uint64(cursor.LogIndex)}
+	}
+	read := func(section uint64) []indexEntry {
+		var (
+			transfers = rawdb.ReadTokenTransfers(api.index.db, address, section)
+			entries   []indexEntry
+		)
+		for i := range transfers {
+			if token == nil || transfers[i].Token == *token {
+				entries = append(entries, newRPCTokenTransfer(&transfers[i]))
+			}
+		}
+		return entries
+	}
+	topic := common.BytesToHash(address.Bytes())
+	scan := func(header *types.Header) []indexEntry {
+		if !types.BloomLookup(header.Bloom, topic) {
+			return nil
+		}
+		var entries []indexEntry
+		for _, transfer := range blockTransfers(api.index.db, header) {
+			if (transfer.From == address || transfer.To == address) && (token == nil || transfer.Token == *token) {
+				entries = append(entries, newRPCTokenTransfer(transfer))
+			}
+		}
+		return entries
+	}
+	entries, next, err := api.index.query(ctx, fromBlock, toBlock, resume, read, scan)
+	if err != nil {
+		return nil, err
+	}
+	page := &TokenTransferPage{Transfers: make([]*RPCTokenTransfer, len(entries))}
+	for i, entry := range entries {
+		page.Transfers[i] = entry.(*RPCTokenTransfer)
+	}
+	if next != nil {
+		page.Next = &filters.LogCursor{BlockNumber: hexutil.Uint64(next.number), LogIndex: hexutil.Uint(next.index)}
+	}
+	return page, nil
+}
+
+// newRPCTokenTransfer converts an index entry into its RPC representation.
+func newRPCTokenTransfer(entry *rawdb.TokenTransferEntry) *RPCTokenTransfer {
+	transfer := &RPCTokenTransfer{
+		BlockHash:   entry.BlockHash,
+		BlockNumber: hexutil.Uint64(entry.BlockNumber),
+		TxHash:      entry.TxHash,
+		LogIndex:    hexutil.Uint(entry.LogIndex),
+		Token:       entry.Token,
+		From:        entry.From,
+		To:          entry.To,
+	}
+	if entry.NonFungible {
+		transfer.TokenID = (*hexutil.Big)(entry.Value)
+	} else {
+		transfer.Value = (*hexutil.Big)(entry.Value)
+	}
+	return transfer
+}
+
+// addressPageSize is the maximum number of transactions returned by a single
+// address transaction query.
+const addressPageSize = 1000
+
+// addressMaxScan is the maximum number of blocks beyond the indexed sections an
+// address transaction query may scan directly.
+const addressMaxScan = 2 * (addressSectionSize + addressConfirms)
+
+// TxCursor is the position of a transaction within the chain from which a paged
+// transaction query resumes, inclusive.
+type TxCursor struct {
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	TxIndex     hexutil.Uint   `json:"transactionIndex"`
+}
+
+// RPCAddressTransaction is a transaction involving a queried address, along with
+// the roles the address has in it.
+type RPCAddressTransaction struct {
+	BlockHash        common.Hash    `json:"blockHash"`
+	BlockNumber      hexutil.Uint64 `json:"blockNumber"`
+	Hash             common.Hash    `json:"hash"`
+	TransactionIndex hexutil.Uint   `json:"transactionIndex"`
+	Sent             bool           `json:"sent"`
+	Received         bool           `json:"received"`
+	Created          bool           `json:"created"`
+}
+
+// AddressTransactionPage is a single page of transactions involving an address.
+// Next is nil if the queried range is exhausted.
+type AddressTransactionPage struct {
+	Transactions []*RPCAddressTransaction `json:"transactions"`
+	Next         *TxCursor                `json:"next"`
+}
+
+// position implements indexEntry, positioning the transaction within the chain.
+func (tx *RPCAddressTransaction) position() (uint64, common.Hash, uint64) {
+	return uint64(tx.BlockNumber), tx.BlockHash, uint64(tx.TransactionIndex)
+}
+
+// newRPCAddressTransaction converts an index entry into its RPC representation.
+func newRPCAddressTransaction(entry *rawdb.AddressTxEntry) *RPCAddressTransaction {
+	return &RPCAddressTransaction{
+		BlockHash:        entry.BlockHash,
+		BlockNumber:      hexutil.Uint64(entry.BlockNumber),
+		Hash:             entry.TxHash,
+		TransactionIndex: hexutil.Uint(entry.TxIndex),
+		Sent:             entry.Roles&rawdb.AddressTxSender != 0,
+		Received:         entry.Roles&rawdb.AddressTxRecipient != 0,
+		Created:          entry.Roles&rawdb.AddressTxCreation != 0,
+	}
+}
+
+// PublicAddressAPI provides access to the address transaction index of the node.
+type PublicAddressAPI struct {
+	index  *sectionIndex
+	config *params.ChainConfig
+}
+
+// NewPublicAddressAPI creates a new API definition for querying the address
+// transaction index of the Genchain service.
+func NewPublicAddressAPI(gen *Genchain) *PublicAddressAPI {
+	return &PublicAddressAPI{
+		index: &sectionIndex{
+			name:     "address",
+			size:     addressSectionSize,
+			maxScan:  addressMaxScan,
+			pageSize: addressPageSize,
+			sections: indexedSections(gen.addressIndexer),
+			chain:    gen.blockchain,
+			db:       gen.chainDb,
+		},
+		config: gen.chainConfig,
+	}
+}
+
+// GetTransactionsByAddress returns the transactions sent or received by an address,
+// or creating it as a contract, within the given block range. Results are paged,
+// the returned cursor can be used to resume the query where the previous page ended.
+func (api *PublicAddressAPI) GetTransactionsByAddress(ctx context.Context, address common.Address, fromBlock, toBlock *rpc.BlockNumber, cursor *TxCursor) (*AddressTransactionPage, error) {
+	var resume *indexCursor
+	if cursor != nil {
+		resume = &indexCursor{number: uint64(cursor.BlockNumber), index: uint64(cursor.TxIndex)}
+	}
+	convert := func(txs []rawdb.AddressTxEntry) []indexEntry {
+		entries := make([]indexEntry, len(txs))
+		for i := range txs {
+			entries[i] = newRPCAddressTransaction(&txs[i])
+		}
+		return entries
+	}
+	read := func(section uint64) []indexEntry {
+		return convert(rawdb.ReadAddressTransactions(api.index.db, address, section))
+	}
+	scan := func(header *types.Header) []indexEntry {
+		return convert(blockAddressTxs(api.index.db, api.config, header)[address])
+	}
+	entries, next, err := api.index.query(ctx, fromBlock, toBlock, resume, read, scan)
+	if err != nil {
+		return nil, err
+	}
+	page := &AddressTransactionPage{Transactions: make([]*RPCAddressTransaction, len(entries))}
+	for i, entry := range entries {
+		page.Transactions[i] = entry.(*RPCAddressTransaction)
+	}
+	if next != nil {
+		page.Next = &TxCursor{BlockNumber: hexutil.Uint64(next.number), TxIndex: hexutil.Uint(next.index)}
+	}
+	return page, nil
+}
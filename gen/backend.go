@@ -55,6 +55,7 @@ type LesServer interface {
 	Start(srvr *p2p.Server)
 	Stop()
 	Protocols() []p2p.Protocol
+	APIs() []rpc.API
 	SetBloomBitsIndexer(bbIndexer *core.ChainIndexer)
 }
 
@@ -82,6 +83,10 @@ type Genchain struct {
 	bloomRequests chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer  *core.ChainIndexer             // Bloom indexer operating during block imports
 
+	transferIndexer *core.ChainIndexer // Token transfer indexer, nil if the index is disabled
+	transfers       *TransferIndexer   // Token transfer index backend, purged on reorgs
+	addressIndexer  *core.ChainIndexer // Address transaction indexer, nil if the index is disabled
+
 	APIBackend *EthAPIBackend
 
 	miner     *miner.Miner
@@ -157,12 +162,21 @@ func New(ctx *node.ServiceContext, config *Config) (*Genchain, error) {
 	}
 	gen.bloomIndexer.Start(gen.blockchain)
 
+	if config.TokenTransferIndex {
+		gen.transferIndexer, gen.transfers = NewTransferIndexer(chainDb, transferSectionSize)
+		gen.transferIndexer.Start(gen.blockchain)
+	}
+	if config.AddressTxIndex {
+		gen.addressIndexer = NewAddressIndexer(chainDb, chainConfig, addressSectionSize)
+		gen.addressIndexer.Start(gen.blockchain)
+	}
+
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
 	}
 	gen.txPool = core.NewTxPool(config.TxPool, gen.chainConfig, gen.blockchain)
 
-	if gen.protocolManager, err = NewProtocolManager(gen.chainConfig, config.SyncMode, config.NetworkId, gen.eventMux, gen.txPool, gen.engine, gen.blockchain, chainDb); err != nil {
+	if gen.protocolManager, err = NewProtocolManager(gen.chainConfig, config.SyncMode, config.SyncCheckpoint, config.NetworkId, gen.eventMux, gen.txPool, gen.engine, gen.blockchain, chainDb); err != nil {
 		return nil, err
 	}
 	gen.miner = miner.New(gen, gen.chainConfig, gen.EventMux(), gen.engine)
@@ -246,6 +260,28 @@ func (s *Genchain) APIs() []rpc.API {
 	// Append any APIs exposed explicitly by the consensus engine
 	apis = append(apis, s.engine.APIs(s.BlockChain())...)
 
+	// Append the chain indexes if they're enabled
+	if s.transferIndexer != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "gen",
+			Version:   "1.0",
+			Service:   NewPublicTransferAPI(s),
+			Public:    true,
+		})
+	}
+	if s.addressIndexer != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "gen",
+			Version:   "1.0",
+			Service:   NewPublicAddressAPI(s),
+			Public:    true,
+		})
+	}
+	// Append the light server APIs if it's enabled
+	if s.lesServer != nil {
+		apis = append(apis, s.lesServer.APIs()...)
+	}
+
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
 		{
@@ -271,7 +307,7 @@ func (s *Genchain) APIs() []rpc.API {
 		}, {
 			Namespace: "gen",
 			Version:   "1.0",
-			Service:   filters.NewPublicFilterAPI(s.APIBackend, false),
+			Service:   filters.NewPublicFilterAPI(s.APIBackend, false, s.config.Filter),
 			Public:    true,
 		}, {
 			Namespace: "admin",
@@ -386,6 +422,11 @@ func (s *Genchain) Start(srvr *p2p.Server) error {
 	// Start the bloom bits servicing goroutines
 	s.startBloomHandlers()
 
+	// Keep the token transfer index free of reorged transfers
+	if s.transfers != nil {
+		go s.transferPurgeLoop()
+	}
+
 	// Start the RPC service
 	s.netRPCService = ethapi.NewPublicNetAPI(srvr, s.NetVersion())
 
@@ -399,6 +440,7 @@ func (s *Genchain) Start(srvr *p2p.Server) error {
 	}
 	// Start the networking layer and the light server if requested
 	s.protocolManager.Start(maxPeers)
+	go s.protocolManager.enrEntryLoop(srvr.SetEntry)
 	if s.lesServer != nil {
 		s.lesServer.Start(srvr)
 	}
@@ -409,6 +451,12 @@ func (s *Genchain) Start(srvr *p2p.Server) error {
 // Genchain protocol.
 func (s *Genchain) Stop() error {
 	s.bloomIndexer.Close()
+	if s.transferIndexer != nil {
+		s.transferIndexer.Close()
+	}
+	if s.addressIndexer != nil {
+		s.addressIndexer.Close()
+	}
 	s.blockchain.Stop()
 	s.protocolManager.Stop()
 	if s.lesServer != nil {
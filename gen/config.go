@@ -29,7 +29,9 @@ import (
 	"github.com/genchain/go-genchain/consensus/ethash"
 	"github.com/genchain/go-genchain/core"
 	"github.com/genchain/go-genchain/gen/downloader"
+	"github.com/genchain/go-genchain/gen/filters"
 	"github.com/genchain/go-genchain/gen/gasprice"
+	"github.com/genchain/go-genchain/light"
 	"github.com/genchain/go-genchain/params"
 )
 
@@ -83,10 +85,21 @@ type Config struct {
 	SyncMode  downloader.SyncMode
 	NoPruning bool
 
+	// Trusted block to pin the sync to and start fast sync from
+	SyncCheckpoint *downloader.Checkpoint `toml:",omitempty"`
+
 	// Light client options
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
 
+	// Light client checkpoint options
+	Checkpoint          *light.SignedCheckpoint `toml:",omitempty"` // Signed CHT/BloomTrie checkpoint to start light syncing from
+	CheckpointSigners   []common.Address        `toml:",omitempty"` // Accounts trusted to sign light client checkpoints
+	CheckpointThreshold int                     `toml:",omitempty"` // Number of trusted signatures required to accept a checkpoint (0 = majority)
+
+	// Light client fuzzy proof-of-work verification interval (0 = verify all seals)
+	LightFuzzyPoW uint64 `toml:",omitempty"`
+
 	// Database options
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
@@ -109,6 +122,15 @@ type Config struct {
 	// Gas Price Oracle options
 	GPO gasprice.Config
 
+	// Log filter API limits
+	Filter filters.Config
+
+	// Maintain an index of the ERC20 and ERC721 token transfers of every address
+	TokenTransferIndex bool `toml:",omitempty"`
+
+	// Maintain an index of the transactions sent, received or creating a contract by every address
+	AddressTxIndex bool `toml:",omitempty"`
+
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
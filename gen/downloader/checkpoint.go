@@ -0,0 +1,143 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/hexutil"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/log"
+)
+
+var (
+	errCheckpointUnreached = errors.New("peer chain below sync checkpoint")
+	errCheckpointMismatch  = errors.New("peer chain doesn't contain sync checkpoint")
+)
+
+// Checkpoint is a block trusted to be part of the canonical chain. Syncs are
+// pinned to chains containing it, and fast sync uses it as its pivot block,
+// so a node bootstrapping from it cannot be led onto a fake chain by peers
+// advertising a higher total difficulty. Before syncing, a batch of headers
+// ending at the checkpoint is fetched backward from its hash, checking that
+// the peer serves the chain leading up to it.
+type Checkpoint struct {
+	Number uint64      // Number of the checkpoint block
+	Hash   common.Hash // Hash of the checkpoint block
+}
+
+// MarshalText implements encoding.TextMarshaler, encoding the checkpoint in the
+// <number>:<hash> format.
+func (cp Checkpoint) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d:%s", cp.Number, cp.Hash.Hex())), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, parsing a checkpoint in the
+// <number>:<hash> format.
+func (cp *Checkpoint) UnmarshalText(text []byte) error {
+	parts := strings.Split(string(text), ":")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid checkpoint %q, want <number>:<hash>", text)
+	}
+	number, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid checkpoint number %q: %v", parts[0], err)
+	}
+	if number == 0 {
+		return errors.New("checkpoint can't be the genesis block")
+	}
+	hash, err := hexutil.Decode(parts[1])
+	if err != nil || len(hash) != common.HashLength {
+		return fmt.Errorf("invalid checkpoint hash %q", parts[1])
+	}
+	cp.Number, cp.Hash = number, common.BytesToHash(hash)
+	return nil
+}
+
+// fetchCheckpoint ensures that the chain of a remote peer contains the sync
+// checkpoint, retrieving the headers leading up to it backward from its hash.
+// The checkpoint header is returned, or none if there's no checkpoint or the
+// local chain is already past it.
+func (d *Downloader) fetchCheckpoint(p *peerConnection, height uint64) (*types.Header, error) {
+	cp := d.checkpoint
+	if cp == nil || d.checkpointPassed(cp) {
+		return nil, nil
+	}
+	if height < cp.Number {
+		p.log.Debug("Remote chain below sync checkpoint", "height", height, "checkpoint", cp.Number)
+		return nil, errCheckpointUnreached
+	}
+	// Request the headers down from the checkpoint, as far as the genesis
+	count := MaxHeaderFetch
+	if cp.Number < uint64(count) {
+		count = int(cp.Number) + 1
+	}
+	p.log.Debug("Retrieving sync checkpoint", "number", cp.Number, "hash", cp.Hash, "count", count)
+	go p.peer.RequestHeadersByHash(cp.Hash, count, 0, true)
+
+	ttl := d.requestTTL()
+	timeout := time.After(ttl)
+	for {
+		select {
+		case <-d.cancelCh:
+			return nil, errCancelBlockFetch
+
+		case packet := <-d.headerCh:
+			// Discard anything not from the origin peer
+			if packet.PeerId() != p.id {
+				log.Debug("Received headers from incorrect peer", "peer", packet.PeerId())
+				break
+			}
+			// The peer is past the checkpoint, so it must have all the headers
+			headers := packet.(*headerPack).headers
+			if len(headers) != count || headers[0].Hash() != cp.Hash || headers[0].Number.Uint64() != cp.Number {
+				p.log.Debug("Sync checkpoint not in remote chain", "headers", len(headers))
+				return nil, errCheckpointMismatch
+			}
+			for i := 1; i < len(headers); i++ {
+				if headers[i].Hash() != headers[i-1].ParentHash || headers[i].Number.Uint64() != cp.Number-uint64(i) {
+					p.log.Debug("Unlinked headers below sync checkpoint", "number", headers[i].Number, "hash", headers[i].Hash())
+					return nil, errCheckpointMismatch
+				}
+			}
+			return headers[0], nil
+
+		case <-timeout:
+			p.log.Debug("Waiting for sync checkpoint timed out", "elapsed", ttl)
+			return nil, errTimeout
+
+		case <-d.bodyCh:
+		case <-d.receiptCh:
+			// Out of bounds delivery, ignore
+		}
+	}
+}
+
+// checkpointPassed reports whether the local chain is already past the sync
+// checkpoint. Fast sync imports the headers ahead of the pivot state, so it is
+// only past the checkpoint once the state of the checkpoint is committed.
+func (d *Downloader) checkpointPassed(cp *Checkpoint) bool {
+	if d.mode == LightSync {
+		return d.lightchain.CurrentHeader().Number.Uint64() >= cp.Number
+	}
+	return d.blockchain.CurrentBlock().NumberU64() >= cp.Number
+}
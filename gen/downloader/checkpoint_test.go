@@ -0,0 +1,145 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"testing"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/core/types"
+)
+
+func TestCheckpointText(t *testing.T) {
+	hash := common.HexToHash("0x88e96d4537bea4d9c05d12549907b32561d3bf31f45aae734cdc119f13406cb6")
+
+	var cp Checkpoint
+	if err := cp.UnmarshalText([]byte("1:" + hash.Hex())); err != nil {
+		t.Fatalf("failed to parse checkpoint: %v", err)
+	}
+	if cp.Number != 1 || cp.Hash != hash {
+		t.Fatalf("checkpoint mismatch: have %d:%x, want 1:%x", cp.Number, cp.Hash, hash)
+	}
+	if text, _ := cp.MarshalText(); string(text) != "1:"+hash.Hex() {
+		t.Fatalf("encoded checkpoint mismatch: have %s", text)
+	}
+	for _, invalid := range []string{"", "1", hash.Hex(), "0:" + hash.Hex(), "x:" + hash.Hex(), "1:0x1234", "1:" + hash.Hex() + ":2"} {
+		if err := new(Checkpoint).UnmarshalText([]byte(invalid)); err == nil {
+			t.Errorf("invalid checkpoint %q accepted", invalid)
+		}
+	}
+}
+
+// Tests that syncs against chains not containing the sync checkpoint, or not
+// reaching it yet, are rejected without importing anything.
+func TestCheckpointRejection63Full(t *testing.T)  { testCheckpointRejection(t, 63, FullSync) }
+func TestCheckpointRejection63Fast(t *testing.T)  { testCheckpointRejection(t, 63, FastSync) }
+func TestCheckpointRejection64Full(t *testing.T)  { testCheckpointRejection(t, 64, FullSync) }
+func TestCheckpointRejection64Fast(t *testing.T)  { testCheckpointRejection(t, 64, FastSync) }
+func TestCheckpointRejection64Light(t *testing.T) { testCheckpointRejection(t, 64, LightSync) }
+
+func testCheckpointRejection(t *testing.T, protocol int, mode SyncMode) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Pin the sync to a block of a chain the peers don't have
+	hashesA, headersA, blocksA, receiptsA := tester.makeChain(MaxHashFetch, 1, tester.genesis, nil, false)
+	hashesB, headersB, blocksB, receiptsB := tester.makeChain(MaxHashFetch, 2, tester.genesis, nil, false)
+	tester.downloader.checkpoint = &Checkpoint{Number: 100, Hash: hashesA[len(hashesA)-101]}
+
+	tester.newPeer("fork", protocol, hashesB, headersB, blocksB, receiptsB)
+	if err := tester.sync("fork", nil, mode); err != errCheckpointMismatch {
+		t.Fatalf("fork sync error mismatch: have %v, want %v", err, errCheckpointMismatch)
+	}
+	short := hashesA[len(hashesA)-100:]
+	tester.newPeer("short", protocol, short, headersA, blocksA, receiptsA)
+	if err := tester.sync("short", nil, mode); err != errCheckpointUnreached {
+		t.Fatalf("short sync error mismatch: have %v, want %v", err, errCheckpointUnreached)
+	}
+	// The headers leading up to the checkpoint must be served too
+	gapped := make(map[common.Hash]*types.Header)
+	for hash, header := range headersA {
+		if header.Number.Uint64() != 50 {
+			gapped[hash] = header
+		}
+	}
+	tester.newPeer("gapped", protocol, hashesA, gapped, blocksA, receiptsA)
+	if err := tester.sync("gapped", nil, mode); err != errCheckpointMismatch {
+		t.Fatalf("gapped sync error mismatch: have %v, want %v", err, errCheckpointMismatch)
+	}
+	assertOwnChain(t, tester, 1)
+
+	// A chain containing the checkpoint must sync fine
+	tester.newPeer("valid", protocol, hashesA, headersA, blocksA, receiptsA)
+	if err := tester.sync("valid", nil, mode); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	if hs := len(tester.ownHeaders); hs != len(hashesA) {
+		t.Fatalf("synchronised headers mismatch: have %v, want %v", hs, len(hashesA))
+	}
+}
+
+// Tests that fast sync pins its pivot to the sync checkpoint, including when it
+// is restarted after importing the headers past the checkpoint.
+func TestCheckpointPivot63(t *testing.T) { testCheckpointPivot(t, 63, false) }
+func TestCheckpointPivot64(t *testing.T) { testCheckpointPivot(t, 64, false) }
+
+func TestCheckpointPivotRestart63(t *testing.T) { testCheckpointPivot(t, 63, true) }
+func TestCheckpointPivotRestart64(t *testing.T) { testCheckpointPivot(t, 64, true) }
+
+func testCheckpointPivot(t *testing.T, protocol int, restart bool) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	targetBlocks := blockCacheItems - 15
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+
+	checkpoint := uint64(100)
+	tester.downloader.checkpoint = &Checkpoint{Number: checkpoint, Hash: hashes[len(hashes)-1-int(checkpoint)]}
+
+	// Simulate an interrupted fast sync, which imported headers past the
+	// checkpoint, but not its state
+	if restart {
+		imported := make([]*types.Header, 0, checkpoint+10)
+		for i := len(hashes) - 2; i >= len(hashes)-1-int(checkpoint+10); i-- {
+			imported = append(imported, headers[hashes[i]])
+		}
+		if _, err := tester.InsertHeaderChain(imported, 1); err != nil {
+			t.Fatalf("failed to import headers: %v", err)
+		}
+	}
+	// A peer forking off below the checkpoint must still be rejected
+	forkHashes, forkHeaders, forkBlocks, forkReceipts := tester.makeChain(targetBlocks, 1, tester.genesis, nil, false)
+	tester.newPeer("fork", protocol, forkHashes, forkHeaders, forkBlocks, forkReceipts)
+	if err := tester.sync("fork", nil, FastSync); err != errCheckpointMismatch {
+		t.Fatalf("fork sync error mismatch: have %v, want %v", err, errCheckpointMismatch)
+	}
+	tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+	if err := tester.sync("peer", nil, FastSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	// Receipts are only stored up to the pivot, full blocks are imported past it
+	if rs := len(tester.ownReceipts); rs != int(checkpoint)+1 {
+		t.Fatalf("synchronised receipts mismatch: have %v, want %v (pivot not pinned)", rs, checkpoint+1)
+	}
+	if hs := len(tester.ownHeaders); hs != targetBlocks+1 {
+		t.Fatalf("synchronised headers mismatch: have %v, want %v", hs, targetBlocks+1)
+	}
+}
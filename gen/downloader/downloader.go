@@ -100,6 +100,8 @@ type Downloader struct {
 	peers   *peerSet // Set of active peers from which download can proceed
 	stateDB ethdb.Database
 
+	checkpoint *Checkpoint // Trusted block to pin syncs to and pivot fast sync at (nil = none)
+
 	rttEstimate   uint64 // Round trip time to target for download requests
 	rttConfidence uint64 // Confidence in the estimated RTT (unit: millionths to allow atomic ops)
 
@@ -133,6 +135,9 @@ type Downloader struct {
 	stateSyncStart chan *stateSync
 	trackStateReq  chan *stateReq
 	stateCh        chan dataPack // [gen/63] Channel receiving inbound node state data
+	rangeCh        chan dataPack // [gen/64] Channel receiving inbound state ranges
+
+	snapTasks []*snapAccountTask // Account range download progress kept across pivot moves
 
 	// Cancellation and termination
 	cancelPeer string         // Identifier of the peer currently being used as the master (cancel on drop)
@@ -198,13 +203,14 @@ type BlockChain interface {
 }
 
 // New creates a new downloader to fetch hashes and blocks from remote peers.
-func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockChain, lightchain LightChain, dropPeer peerDropFn) *Downloader {
+func New(mode SyncMode, checkpoint *Checkpoint, stateDb ethdb.Database, mux *event.TypeMux, chain BlockChain, lightchain LightChain, dropPeer peerDropFn) *Downloader {
 	if lightchain == nil {
 		lightchain = chain
 	}
 
 	dl := &Downloader{
 		mode:           mode,
+		checkpoint:     checkpoint,
 		stateDB:        stateDb,
 		mux:            mux,
 		queue:          newQueue(),
@@ -222,6 +228,7 @@ func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockC
 		headerProcCh:   make(chan []*types.Header, 1),
 		quitCh:         make(chan struct{}),
 		stateCh:        make(chan dataPack),
+		rangeCh:        make(chan dataPack),
 		stateSyncStart: make(chan *stateSync),
 		syncStatsState: stateSyncStats{
 			processed: rawdb.ReadFastTrieProgress(stateDb),
@@ -249,7 +256,7 @@ func (d *Downloader) Progress() genchain.SyncProgress {
 	switch d.mode {
 	case FullSync:
 		current = d.blockchain.CurrentBlock().NumberU64()
-	case FastSync:
+	case FastSync, SnapSync:
 		current = d.blockchain.CurrentFastBlock().NumberU64()
 	case LightSync:
 		current = d.lightchain.CurrentHeader().Number.Uint64()
@@ -321,7 +328,7 @@ func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode
 
 	case errTimeout, errBadPeer, errStallingPeer,
 		errEmptyHeaderSet, errPeersUnavailable, errTooOld,
-		errInvalidAncestor, errInvalidChain:
+		errInvalidAncestor, errInvalidChain, errCheckpointMismatch:
 		log.Warn("Synchronisation failed, dropping peer", "peer", id, "err", err)
 		if d.dropPeer == nil {
 			// The dropPeer method is nil when `--copydb` is used for a local copy.
@@ -427,6 +434,10 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 	}
 	height := latest.Number.Uint64()
 
+	checkpoint, err := d.fetchCheckpoint(p, height)
+	if err != nil {
+		return err
+	}
 	origin, err := d.findAncestor(p, height)
 	if err != nil {
 		return err
@@ -440,8 +451,14 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 
 	// Ensure our origin point is below any fast sync pivot point
 	pivot := uint64(0)
-	if d.mode == FastSync {
-		if height <= uint64(fsMinFullBlocks) {
+	if d.mode.isFast() {
+		if checkpoint != nil {
+			// Pin the pivot to the trusted checkpoint, fully processing the rest
+			pivot = checkpoint.Number.Uint64()
+			if pivot <= origin {
+				origin = pivot - 1
+			}
+		} else if height <= uint64(fsMinFullBlocks) {
 			origin = 0
 		} else {
 			pivot = height - uint64(fsMinFullBlocks)
@@ -451,7 +468,7 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 		}
 	}
 	d.committed = 1
-	if d.mode == FastSync && pivot != 0 {
+	if d.mode.isFast() && pivot != 0 {
 		d.committed = 0
 	}
 	// Initiate the sync using a concurrent header and content retrieval algorithm
@@ -466,8 +483,8 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 		func() error { return d.fetchReceipts(origin + 1) },        // Receipts are retrieved during fast sync
 		func() error { return d.processHeaders(origin+1, pivot, td) },
 	}
-	if d.mode == FastSync {
-		fetchers = append(fetchers, func() error { return d.processFastSyncContent(latest) })
+	if d.mode.isFast() {
+		fetchers = append(fetchers, func() error { return d.processFastSyncContent(latest, checkpoint) })
 	} else if d.mode == FullSync {
 		fetchers = append(fetchers, d.processFullSyncContent)
 	}
@@ -595,12 +612,16 @@ func (d *Downloader) findAncestor(p *peerConnection, height uint64) (uint64, err
 
 	if d.mode == FullSync {
 		ceil = d.blockchain.CurrentBlock().NumberU64()
-	} else if d.mode == FastSync {
+	} else if d.mode.isFast() {
 		ceil = d.blockchain.CurrentFastBlock().NumberU64()
 	}
 	if ceil >= MaxForkAncestry {
 		floor = int64(ceil - MaxForkAncestry)
 	}
+	// Never reorg below the sync checkpoint once past it
+	if cp := d.checkpoint; cp != nil && ceil >= cp.Number && int64(cp.Number)-1 > floor {
+		floor = int64(cp.Number) - 1
+	}
 	p.log.Debug("Looking for common ancestor", "local", ceil, "remote", height)
 
 	// Request the topmost blocks to short circuit binary ancestor lookup
@@ -1228,7 +1249,7 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 				// This check cannot be executed "as is" for full imports, since blocks may still be
 				// queued for processing when the header download completes. However, as long as the
 				// peer gave us something useful, we're already happy/progressed (above check).
-				if d.mode == FastSync || d.mode == LightSync {
+				if d.mode.isFast() || d.mode == LightSync {
 					head := d.lightchain.CurrentHeader()
 					if td.Cmp(d.lightchain.GetTd(head.Hash(), head.Number.Uint64())) > 0 {
 						return errStallingPeer
@@ -1238,6 +1259,14 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 				rollback = nil
 				return nil
 			}
+			// Reject the whole chain if it doesn't contain the sync checkpoint
+			if cp := d.checkpoint; cp != nil {
+				first, last := headers[0].Number.Uint64(), headers[len(headers)-1].Number.Uint64()
+				if first <= cp.Number && cp.Number <= last && headers[cp.Number-first].Hash() != cp.Hash {
+					log.Warn("Header chain doesn't contain sync checkpoint", "number", cp.Number, "hash", headers[cp.Number-first].Hash(), "want", cp.Hash)
+					return errInvalidChain
+				}
+			}
 			// Otherwise split the chunk of headers into batches and process them
 			gotHeaders = true
 
@@ -1256,7 +1285,7 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 				chunk := headers[:limit]
 
 				// In case of header only syncing, validate the chunk immediately
-				if d.mode == FastSync || d.mode == LightSync {
+				if d.mode.isFast() || d.mode == LightSync {
 					// Collect the yet unknown headers to mark them as uncertain
 					unknown := make([]*types.Header, 0, len(headers))
 					for _, header := range chunk {
@@ -1284,7 +1313,7 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 					}
 				}
 				// Unless we're doing light chains, schedule the headers for associated content retrieval
-				if d.mode == FullSync || d.mode == FastSync {
+				if d.mode == FullSync || d.mode.isFast() {
 					// If we've reached the allowed number of pending headers, stall a bit
 					for d.queue.PendingBlocks() >= maxQueuedHeaders || d.queue.PendingReceipts() >= maxQueuedHeaders {
 						select {
@@ -1366,11 +1395,16 @@ func (d *Downloader) importBlockResults(results []*fetchResult) error {
 }
 
 // processFastSyncContent takes fetch results from the queue and writes them to the
-// database. It also controls the synchronisation of state nodes of the pivot block.
-func (d *Downloader) processFastSyncContent(latest *types.Header) error {
+// database. It also controls the synchronisation of state nodes of the pivot block,
+// which is the given checkpoint if any.
+func (d *Downloader) processFastSyncContent(latest *types.Header, checkpoint *types.Header) error {
 	// Start syncing state of the reported head block. This should get us most of
-	// the state of the pivot block.
-	stateSync := d.syncState(latest.Root)
+	// the state of the pivot block. If the pivot is pinned, sync its state directly.
+	root := latest.Root
+	if checkpoint != nil {
+		root = checkpoint.Root
+	}
+	stateSync := d.syncState(root)
 	defer stateSync.Cancel()
 	go func() {
 		if err := stateSync.Wait(); err != nil && err != errCancelStateFetch {
@@ -1380,7 +1414,9 @@ func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 	// Figure out the ideal pivot block. Note, that this goalpost may move if the
 	// sync takes long enough for the chain head to move significantly.
 	pivot := uint64(0)
-	if height := latest.Number.Uint64(); height > uint64(fsMinFullBlocks) {
+	if checkpoint != nil {
+		pivot = checkpoint.Number.Uint64()
+	} else if height := latest.Number.Uint64(); height > uint64(fsMinFullBlocks) {
 		pivot = height - uint64(fsMinFullBlocks)
 	}
 	// To cater for moving pivot points, track the pivot block and subsequently
@@ -1412,7 +1448,7 @@ func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 			results = append(append([]*fetchResult{oldPivot}, oldTail...), results...)
 		}
 		// Split around the pivot block and process the two sides via fast/full sync
-		if atomic.LoadInt32(&d.committed) == 0 {
+		if atomic.LoadInt32(&d.committed) == 0 && checkpoint == nil {
 			latest = results[len(results)-1].Header
 			if height := latest.Number.Uint64(); height > pivot+2*uint64(fsMinFullBlocks) {
 				log.Warn("Pivot became stale, moving", "old", pivot, "new", height-uint64(fsMinFullBlocks))
@@ -1426,15 +1462,17 @@ func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 		if P != nil {
 			// If new pivot block found, cancel old state retrieval and restart
 			if oldPivot != P {
-				stateSync.Cancel()
-
-				stateSync = d.syncState(P.Header.Root)
-				defer stateSync.Cancel()
-				go func() {
-					if err := stateSync.Wait(); err != nil && err != errCancelStateFetch {
-						d.queue.Close() // wake up WaitResults
-					}
-				}()
+				if stateSync.root != P.Header.Root {
+					stateSync.Cancel()
+
+					stateSync = d.syncState(P.Header.Root)
+					defer stateSync.Cancel()
+					go func() {
+						if err := stateSync.Wait(); err != nil && err != errCancelStateFetch {
+							d.queue.Close() // wake up WaitResults
+						}
+					}()
+				}
 				oldPivot = P
 			}
 			// Wait for completion, occasionally checking for pivot staleness
@@ -1566,6 +1604,12 @@ func (d *Downloader) deliver(id string, destCh chan dataPack, packet dataPack, i
 	}
 }
 
+// DeliverRange injects a range of accounts or storage slots received from a
+// remote node.
+func (d *Downloader) DeliverRange(id string, keys []common.Hash, values [][]byte, proof [][]byte) (err error) {
+	return d.deliver(id, d.rangeCh, &rangePack{id, keys, values, proof}, rangeInMeter, rangeDropMeter)
+}
+
 // qosTuner is the quality of service tuning loop that occasionally gathers the
 // peer latency statistics and updates the estimated request round trip time.
 func (d *Downloader) qosTuner() {
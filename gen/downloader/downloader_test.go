@@ -28,11 +28,13 @@ import (
 	"github.com/genchain/go-genchain/common"
 	"github.com/genchain/go-genchain/consensus/ethash"
 	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/core/state"
 	"github.com/genchain/go-genchain/core/types"
 	"github.com/genchain/go-genchain/crypto"
 	"github.com/genchain/go-genchain/ethdb"
 	"github.com/genchain/go-genchain/event"
 	"github.com/genchain/go-genchain/params"
+	"github.com/genchain/go-genchain/rlp"
 	"github.com/genchain/go-genchain/trie"
 )
 
@@ -69,6 +71,7 @@ type downloadTester struct {
 	peerChainTds map[string]map[common.Hash]*big.Int       // Total difficulties of the blocks in the peer chains
 
 	peerMissingStates map[string]map[common.Hash]bool // State entries that fast sync should not return
+	peerRangeReqs     int32                           // Number of state range requests served by the peers
 
 	lock sync.RWMutex
 }
@@ -96,7 +99,7 @@ func newTester() *downloadTester {
 	tester.stateDb = ethdb.NewMemDatabase()
 	tester.stateDb.Put(genesis.Root().Bytes(), []byte{0x00})
 
-	tester.downloader = New(FullSync, tester.stateDb, new(event.TypeMux), tester, nil, tester.dropPeer)
+	tester.downloader = New(FullSync, nil, tester.stateDb, new(event.TypeMux), tester, nil, tester.dropPeer)
 
 	return tester
 }
@@ -131,7 +134,13 @@ func (dl *downloadTester) makeChain(n int, seed byte, parent *types.Block, paren
 			})
 		}
 	})
-	// Convert the block-chain into a hash-chain and header/block maps
+	return chainMaps(parent, parentReceipts, blocks, receipts)
+}
+
+// chainMaps converts a generated block-chain into a hash-chain ordered head->parent
+// and header/block/receipt maps, including the parent.
+func chainMaps(parent *types.Block, parentReceipts types.Receipts, blocks []*types.Block, receipts []types.Receipts) ([]common.Hash, map[common.Hash]*types.Header, map[common.Hash]*types.Block, map[common.Hash]types.Receipts) {
+	n := len(blocks)
 	hashes := make([]common.Hash, n+1)
 	hashes[len(hashes)-1] = parent.Hash()
 
@@ -520,9 +529,17 @@ func (dlp *downloadTesterPeer) RequestHeadersByNumber(origin uint64, amount int,
 	// Gather the next batch of headers
 	hashes := dlp.dl.peerHashes[dlp.id]
 	headers := dlp.dl.peerHeaders[dlp.id]
+	step := skip + 1
+	if reverse {
+		step = -step
+	}
 	result := make([]*types.Header, 0, amount)
-	for i := 0; i < amount && len(hashes)-int(origin)-1-i*(skip+1) >= 0; i++ {
-		if header, ok := headers[hashes[len(hashes)-int(origin)-1-i*(skip+1)]]; ok {
+	for i := 0; i < amount; i++ {
+		index := len(hashes) - int(origin) - 1 - i*step
+		if index < 0 || index >= len(hashes) {
+			break
+		}
+		if header, ok := headers[hashes[index]]; ok {
 			result = append(result, header)
 		}
 	}
@@ -603,6 +620,76 @@ func (dlp *downloadTesterPeer) RequestNodeData(hashes []common.Hash) error {
 	return nil
 }
 
+// RequestAccountRange constructs a getAccountRange method associated with a
+// particular peer in the download tester. The returned function can be used to
+// retrieve proven ranges of account trie leaves from the particularly requested
+// peer.
+func (dlp *downloadTesterPeer) RequestAccountRange(root, origin, limit common.Hash, bytes uint64) error {
+	dlp.waitDelay()
+
+	keys, values, proof := dlp.dl.serveRange(root, origin, limit, bytes)
+	go dlp.dl.downloader.DeliverRange(dlp.id, keys, values, proof)
+
+	return nil
+}
+
+// RequestStorageRange constructs a getStorageRange method associated with a
+// particular peer in the download tester. The returned function can be used to
+// retrieve proven ranges of storage trie leaves from the particularly requested
+// peer.
+func (dlp *downloadTesterPeer) RequestStorageRange(root, account, origin, limit common.Hash, bytes uint64) error {
+	dlp.waitDelay()
+
+	// Look up the storage root of the account, serving nothing if unknown
+	var storage common.Hash
+	if tr, err := trie.New(root, trie.NewDatabase(dlp.dl.peerDb)); err == nil {
+		if blob, err := tr.TryGet(account[:]); err == nil && blob != nil {
+			var acc state.Account
+			if err := rlp.DecodeBytes(blob, &acc); err == nil {
+				storage = acc.Root
+			}
+		}
+	}
+	keys, values, proof := dlp.dl.serveRange(storage, origin, limit, bytes)
+	go dlp.dl.downloader.DeliverRange(dlp.id, keys, values, proof)
+
+	return nil
+}
+
+// serveRange assembles a range of the leaves of a trie in the peer database,
+// along with the proofs of its boundaries, the way serving peers do. The ranges
+// are kept short to have tries assembled from several of them.
+func (dl *downloadTester) serveRange(root, origin, limit common.Hash, bytes uint64) ([]common.Hash, [][]byte, [][]byte) {
+	atomic.AddInt32(&dl.peerRangeReqs, 1)
+
+	tr, err := trie.New(root, trie.NewDatabase(dl.peerDb))
+	if root == (common.Hash{}) || err != nil {
+		return nil, nil, nil
+	}
+	var (
+		keys   []common.Hash
+		values [][]byte
+		size   uint64
+	)
+	it := trie.NewIterator(tr.NodeIterator(origin[:]))
+	for size < bytes && len(keys) < 8 && it.Next() && common.BytesToHash(it.Key).Big().Cmp(limit.Big()) <= 0 {
+		keys = append(keys, common.BytesToHash(it.Key))
+		values = append(values, common.CopyBytes(it.Value))
+		size += uint64(common.HashLength + len(it.Value))
+	}
+	proofDb := ethdb.NewMemDatabase()
+	tr.Prove(origin[:], 0, proofDb)
+	if len(keys) > 0 {
+		tr.Prove(keys[len(keys)-1][:], 0, proofDb)
+	}
+	var proof [][]byte
+	for _, key := range proofDb.Keys() {
+		node, _ := proofDb.Get(key)
+		proof = append(proof, node)
+	}
+	return keys, values, proof
+}
+
 // assertOwnChain checks if the local chain contains the correct number of items
 // of the various chain components.
 func assertOwnChain(t *testing.T, tester *downloadTester, length int) {
@@ -40,4 +40,7 @@ var (
 
 	stateInMeter   = metrics.NewRegisteredMeter("gen/downloader/states/in", nil)
 	stateDropMeter = metrics.NewRegisteredMeter("gen/downloader/states/drop", nil)
+
+	rangeInMeter   = metrics.NewRegisteredMeter("gen/downloader/ranges/in", nil)
+	rangeDropMeter = metrics.NewRegisteredMeter("gen/downloader/ranges/drop", nil)
 )
@@ -90,6 +90,13 @@ type Peer interface {
 	RequestNodeData([]common.Hash) error
 }
 
+// SnapPeer encapsulates the methods required to download the state of a remote
+// peer in ranges (gen/64 and above).
+type SnapPeer interface {
+	RequestAccountRange(root, origin, limit common.Hash, bytes uint64) error
+	RequestStorageRange(root, account, origin, limit common.Hash, bytes uint64) error
+}
+
 // lightPeerWrapper wraps a LightPeer struct, stubbing out the Peer-only methods.
 type lightPeerWrapper struct {
 	peer LightPeer
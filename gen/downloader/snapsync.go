@@ -0,0 +1,636 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/core/state"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/log"
+	"github.com/genchain/go-genchain/rlp"
+	"github.com/genchain/go-genchain/trie"
+)
+
+const (
+	snapAccountChunks  = 16         // Number of account ranges to download concurrently
+	snapRangeBytes     = 512 * 1024 // Soft size limit of a requested account or storage range
+	maxPendingAccounts = 4096       // Maximum number of accounts per range waiting for storage or code
+)
+
+var (
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+	emptyCode = crypto.Keccak256Hash(nil)
+	maxHash   = common.HexToHash("ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+
+	errRangeOrder    = errors.New("range keys not in ascending order")
+	errRangeBounds   = errors.New("range keys outside of the requested bounds")
+	errRangeValues   = errors.New("range key and value count mismatch")
+	errRangeEmptyVal = errors.New("empty value in range")
+)
+
+// snapAccountTask is a chunk of the account hash space to download in ranges.
+// The tasks outlive a single state sync, so a pivot move doesn't discard the
+// accounts already downloaded.
+type snapAccountTask struct {
+	next common.Hash // Next account hash to request
+	last common.Hash // Last account hash covered by the task
+	done bool        // Whether all the accounts of the task have been retrieved
+
+	trie    *trie.StackTrie // Trie assembled from the accounts of the task, written out as it grows
+	written bool            // Whether the completed trie was written to the database
+	pending []*snapAccount  // Retrieved accounts waiting for their storage or code
+	req     *rangeReq       // Request currently in flight for the task
+}
+
+// snapAccount is a downloaded account which can only be inserted into the state
+// once its storage trie and contract code are present, as the healing phase does
+// not descend into trie nodes already existing in the database.
+type snapAccount struct {
+	hash    common.Hash      // Hash of the account address (trie key)
+	blob    []byte           // RLP encoded account (trie value)
+	storage *snapStorageTask // Storage trie retrieval the account waits for (nil if present)
+	code    *snapCode        // Contract code retrieval the account waits for (nil if present)
+}
+
+// snapStorageTask is the download of a single storage trie. Accounts sharing the
+// same storage root are served by the same task.
+type snapStorageTask struct {
+	root    common.Hash         // Root hash of the storage trie
+	account common.Hash         // Account hash to request the storage slots by
+	next    common.Hash         // Next slot hash to request
+	trie    *trie.StackTrie     // Storage trie assembled from the retrieved slots, written out as it grows
+	tried   map[string]struct{} // Peers which failed to serve the storage
+	req     *rangeReq           // Request currently in flight for the task
+
+	done   bool // Whether the storage trie was assembled completely
+	failed bool // Whether the storage trie is left for the healing phase
+}
+
+// snapCode is the download of a contract code via node data requests.
+type snapCode struct {
+	attempts map[string]struct{} // Peers the code was already requested from
+	fetching bool                // Whether a request is currently in flight
+	done     bool                // Whether the code was retrieved
+	failed   bool                // Whether the code is left for the healing phase
+}
+
+// rangeReq is an account or storage range request sent to a peer.
+type rangeReq struct {
+	peer    *peerConnection
+	account *snapAccountTask // Account task the request is for (nil for storage)
+	storage *snapStorageTask // Storage task the request is for (nil for accounts)
+	origin  common.Hash      // First key requested
+	timer   *time.Timer      // Timer to fire when the RTT timeout expires
+}
+
+// snapSync downloads the bulk of a state in contiguous ranges of accounts and
+// storage slots instead of trie node by trie node, rebuilding the tries locally.
+//
+// The keys of every trie are inserted in ascending order into stack tries, which
+// write out the nodes left of the last key as each range arrives, so only the
+// right boundary paths stay in memory.
+//
+// Every range is checked against the Merkle proofs of its boundaries, but this
+// only catches peers serving data of the wrong state early. The soundness of the
+// assembled state rests on the database being keyed by node hash: tries built
+// from incomplete or forged ranges produce nodes that the real state root never
+// references, and any part of the trie not assembled correctly is retrieved by
+// the regular node sync healing the state afterwards.
+type snapSync struct {
+	s    *stateSync
+	root common.Hash    // State root being synced
+	db   ethdb.Database // Database to write the state into
+
+	accounts []*snapAccountTask
+	storages map[common.Hash]*snapStorageTask // Storage tries being retrieved, by root
+	codes    map[common.Hash]*snapCode        // Contract codes being retrieved, by hash
+	codeReqs int                              // Number of code requests in flight
+
+	active    map[string]*rangeReq // Range requests in flight, by peer
+	stateless map[string]struct{}  // Peers not having the state being synced
+	timeout   chan *rangeReq       // Timed out range requests
+	quit      chan struct{}        // Channel to stop pending timers on exit
+}
+
+// newSnapSync creates the range download phase of a state sync, resuming the
+// account ranges of any previous sync.
+func newSnapSync(s *stateSync) *snapSync {
+	ss := &snapSync{
+		s:         s,
+		root:      s.root,
+		db:        s.d.stateDB,
+		storages:  make(map[common.Hash]*snapStorageTask),
+		codes:     make(map[common.Hash]*snapCode),
+		active:    make(map[string]*rangeReq),
+		stateless: make(map[string]struct{}),
+		timeout:   make(chan *rangeReq),
+		quit:      make(chan struct{}),
+	}
+	if s.d.snapTasks == nil {
+		for i := 0; i < snapAccountChunks; i++ {
+			task := new(snapAccountTask)
+			task.next[0] = byte(i * 256 / snapAccountChunks)
+			task.last = maxHash
+			task.last[0] = byte((i+1)*256/snapAccountChunks - 1)
+			s.d.snapTasks = append(s.d.snapTasks, task)
+		}
+	}
+	ss.accounts = s.d.snapTasks
+	for _, task := range ss.accounts {
+		if task.trie == nil {
+			task.trie = trie.NewStackTrie(s.d.stateDB)
+		}
+	}
+	return ss
+}
+
+// loop downloads account and storage ranges until all of the state is covered
+// or no peer is able to serve it anymore.
+func (ss *snapSync) loop() error {
+	newPeer := make(chan *peerConnection, 1024)
+	newSub := ss.s.d.peers.SubscribeNewPeers(newPeer)
+	defer newSub.Unsubscribe()
+
+	peerDrop := make(chan *peerConnection, 1024)
+	dropSub := ss.s.d.peers.SubscribePeerDrops(peerDrop)
+	defer dropSub.Unsubscribe()
+
+	defer ss.close()
+
+	for !ss.finished() {
+		if err := ss.commitAccounts(); err != nil {
+			return err
+		}
+		ss.assignCodes()
+		if !ss.assignRanges() && len(ss.active) == 0 && ss.codeReqs == 0 {
+			log.Warn("No peers to download state ranges from, healing")
+			return nil
+		}
+		select {
+		case <-newPeer:
+			// New peer arrived, try to assign it download tasks
+
+		case p := <-peerDrop:
+			if req := ss.active[p.id]; req != nil {
+				ss.release(req)
+			}
+
+		case <-ss.s.cancel:
+			return errCancelStateFetch
+
+		case <-ss.s.d.cancelCh:
+			return errCancelStateFetch
+
+		case pack := <-ss.s.ranges:
+			if err := ss.processRange(pack); err != nil {
+				return err
+			}
+
+		case req := <-ss.timeout:
+			// Skip stale timeouts, otherwise drop the peer, it may be feeding
+			// us ranges too slowly on purpose
+			if ss.active[req.peer.id] != req {
+				continue
+			}
+			ss.release(req)
+			log.Warn("Stalling state range sync, dropping peer", "peer", req.peer.id)
+			ss.s.d.dropPeer(req.peer.id)
+
+		case req := <-ss.s.deliver:
+			if err := ss.processCodes(req); err != nil {
+				return err
+			}
+		}
+	}
+	return ss.commitAccounts()
+}
+
+// finished returns whether all account ranges have been retrieved and inserted.
+func (ss *snapSync) finished() bool {
+	if len(ss.active) > 0 || ss.codeReqs > 0 {
+		return false
+	}
+	for _, task := range ss.accounts {
+		if !task.done || len(task.pending) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// close stops all pending timers and rewinds the account tasks to their first
+// account not yet inserted, so that a subsequent sync can resume from there with
+// the accounts already inserted into the task tries.
+func (ss *snapSync) close() {
+	close(ss.quit)
+	for _, req := range ss.active {
+		ss.release(req)
+	}
+	for _, task := range ss.accounts {
+		if len(task.pending) > 0 {
+			task.next, task.done = task.pending[0].hash, false
+		}
+		task.pending = nil
+	}
+}
+
+// release removes a range request from the set of active ones.
+func (ss *snapSync) release(req *rangeReq) {
+	req.timer.Stop()
+	delete(ss.active, req.peer.id)
+	if req.storage != nil {
+		req.storage.req = nil
+	} else {
+		req.account.req = nil
+	}
+}
+
+// snapPeer returns the range retrieval interface of a peer if it supports it.
+func snapPeer(p *peerConnection) SnapPeer {
+	if p.version < 64 {
+		return nil
+	}
+	sp, _ := p.peer.(SnapPeer)
+	return sp
+}
+
+// assignRanges sends range requests to all idle peers supporting them, returning
+// whether any such peer having the state is connected.
+func (ss *snapSync) assignRanges() bool {
+	capable := false
+	for _, p := range ss.s.d.peers.AllPeers() {
+		sp := snapPeer(p)
+		if sp == nil {
+			continue
+		}
+		if _, ok := ss.stateless[p.id]; ok {
+			continue
+		}
+		capable = true
+		if ss.active[p.id] != nil {
+			continue
+		}
+		req := ss.nextRange(p)
+		if req == nil {
+			continue
+		}
+		var err error
+		if req.storage != nil {
+			p.log.Trace("Requesting storage range", "account", req.storage.account, "origin", req.origin)
+			err = sp.RequestStorageRange(ss.root, req.storage.account, req.origin, maxHash, snapRangeBytes)
+			req.storage.req = req
+		} else {
+			p.log.Trace("Requesting account range", "origin", req.origin, "limit", req.account.last)
+			err = sp.RequestAccountRange(ss.root, req.origin, req.account.last, snapRangeBytes)
+			req.account.req = req
+		}
+		req.timer = time.AfterFunc(ss.s.d.requestTTL(), func() {
+			select {
+			case ss.timeout <- req:
+			case <-ss.quit:
+			}
+		})
+		ss.active[p.id] = req
+		if err != nil {
+			ss.release(req)
+		}
+	}
+	return capable
+}
+
+// nextRange picks the next range to request from a peer. Storage ranges are
+// preferred, as they hold up the insertion of the accounts already retrieved.
+func (ss *snapSync) nextRange(p *peerConnection) *rangeReq {
+	for _, task := range ss.storages {
+		if task.done || task.failed || task.req != nil {
+			continue
+		}
+		if _, ok := task.tried[p.id]; ok {
+			continue
+		}
+		return &rangeReq{peer: p, storage: task, origin: task.next}
+	}
+	for _, task := range ss.accounts {
+		if task.done || task.req != nil || len(task.pending) >= maxPendingAccounts {
+			continue
+		}
+		return &rangeReq{peer: p, account: task, origin: task.next}
+	}
+	return nil
+}
+
+// processRange verifies and processes a range delivered by a peer.
+func (ss *snapSync) processRange(pack *rangePack) error {
+	req := ss.active[pack.peerId]
+	if req == nil {
+		log.Debug("Unrequested state range", "peer", pack.peerId, "len", pack.Items())
+		return nil
+	}
+	ss.release(req)
+
+	// A range without proofs means the peer doesn't have the state
+	if len(pack.proof) == 0 {
+		req.peer.log.Debug("Peer lacks the state being synced", "root", ss.root)
+		ss.stateless[req.peer.id] = struct{}{}
+		return nil
+	}
+	if req.storage != nil {
+		return ss.processStorage(req, pack)
+	}
+	return ss.processAccounts(req, pack)
+}
+
+// processAccounts schedules the retrieval of the storage tries and contract codes
+// of a delivered account range.
+func (ss *snapSync) processAccounts(req *rangeReq, pack *rangePack) error {
+	task := req.account
+	if err := verifyRange(ss.root, req.origin, task.last, pack.keys, pack.values, pack.proof); err != nil {
+		log.Warn("Invalid account range", "peer", pack.peerId, "err", err)
+		ss.s.d.dropPeer(pack.peerId)
+		return nil
+	}
+	accounts := make([]state.Account, len(pack.keys))
+	for i, blob := range pack.values {
+		if err := rlp.DecodeBytes(blob, &accounts[i]); err != nil {
+			log.Warn("Invalid account in range", "peer", pack.peerId, "err", err)
+			ss.s.d.dropPeer(pack.peerId)
+			return nil
+		}
+	}
+	for i, account := range accounts {
+		acc := &snapAccount{hash: pack.keys[i], blob: pack.values[i]}
+		if account.Root != emptyRoot {
+			if ok, _ := ss.db.Has(account.Root[:]); !ok {
+				acc.storage = ss.storages[account.Root]
+				if acc.storage == nil {
+					acc.storage = &snapStorageTask{root: account.Root, account: acc.hash, tried: make(map[string]struct{})}
+					acc.storage.trie = trie.NewStackTrie(ss.db)
+					ss.storages[account.Root] = acc.storage
+				}
+			}
+		}
+		if hash := common.BytesToHash(account.CodeHash); hash != emptyCode {
+			if ok, _ := ss.db.Has(hash[:]); !ok {
+				acc.code = ss.codes[hash]
+				if acc.code == nil {
+					acc.code = &snapCode{attempts: make(map[string]struct{})}
+					ss.codes[hash] = acc.code
+				}
+			}
+		}
+		task.pending = append(task.pending, acc)
+	}
+	// Move the task forward, finishing it if nothing's left in its range
+	if len(pack.keys) == 0 || pack.keys[len(pack.keys)-1] == task.last {
+		task.done = true
+	} else {
+		task.next = incHash(pack.keys[len(pack.keys)-1])
+	}
+	return nil
+}
+
+// processStorage inserts a delivered storage range into its storage trie.
+func (ss *snapSync) processStorage(req *rangeReq, pack *rangePack) error {
+	task := req.storage
+	if err := verifyRange(task.root, req.origin, maxHash, pack.keys, pack.values, pack.proof); err != nil {
+		log.Warn("Invalid storage range", "peer", pack.peerId, "err", err)
+		task.tried[pack.peerId] = struct{}{}
+		ss.s.d.dropPeer(pack.peerId)
+		return nil
+	}
+	start := time.Now()
+	for i, key := range pack.keys {
+		if err := task.trie.TryUpdate(key[:], pack.values[i]); err != nil {
+			return err
+		}
+	}
+	// Finish the storage once the assembled trie matches, or leave it to heal
+	// if the peer claims there's nothing more but the trie is still incomplete.
+	// The subtries completed so far are written either way, healing fills in
+	// the gaps of incomplete ones.
+	root := task.trie.Hash()
+	if root == task.root {
+		if _, err := task.trie.Commit(); err != nil {
+			return err
+		}
+	} else if err := task.trie.Flush(); err != nil {
+		return err
+	}
+	ss.s.updateStats(len(pack.keys), 0, 0, time.Since(start))
+
+	switch {
+	case root == task.root:
+		task.done = true
+		delete(ss.storages, task.root)
+	case len(pack.keys) == 0 || pack.keys[len(pack.keys)-1] == maxHash:
+		log.Debug("Incomplete storage range", "root", task.root, "have", root)
+		task.failed = true
+	default:
+		task.next = incHash(pack.keys[len(pack.keys)-1])
+	}
+	return nil
+}
+
+// commitAccounts inserts the accounts that have their storage and code available
+// into the account tries, in order, writing out the completed parts of the tries.
+// Accounts whose storage or code couldn't be retrieved are dropped and left to
+// heal.
+func (ss *snapSync) commitAccounts() error {
+	start := time.Now()
+	written := 0
+	for _, task := range ss.accounts {
+		inserted := 0
+		for _, acc := range task.pending {
+			if (acc.storage != nil && acc.storage.failed) || (acc.code != nil && acc.code.failed) {
+				inserted++
+				continue
+			}
+			if (acc.storage != nil && !acc.storage.done) || (acc.code != nil && !acc.code.done) {
+				break
+			}
+			if err := task.trie.TryUpdate(acc.hash[:], acc.blob); err != nil {
+				return err
+			}
+			inserted++
+			written++
+		}
+		task.pending = task.pending[inserted:]
+
+		if task.done && len(task.pending) == 0 && !task.written {
+			if _, err := task.trie.Commit(); err != nil {
+				return err
+			}
+			task.written = true
+		} else if inserted > 0 {
+			if err := task.trie.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if written > 0 {
+		ss.s.updateStats(written, 0, 0, time.Since(start))
+	}
+	return nil
+}
+
+// assignCodes requests the missing contract codes from the idle peers, reusing
+// the node data retrieval of the regular state sync.
+func (ss *snapSync) assignCodes() {
+	if len(ss.codes) == 0 {
+		return
+	}
+	peers, _ := ss.s.d.peers.NodeDataIdlePeers()
+	for _, p := range peers {
+		req := &stateReq{peer: p, timeout: ss.s.d.requestTTL()}
+
+		n := p.NodeDataCapacity(ss.s.d.requestRTT())
+		for hash, code := range ss.codes {
+			if len(req.items) == n {
+				break
+			}
+			if code.fetching || code.done || code.failed {
+				continue
+			}
+			if _, ok := code.attempts[p.id]; ok {
+				continue
+			}
+			code.attempts[p.id] = struct{}{}
+			code.fetching = true
+			req.items = append(req.items, hash)
+		}
+		if len(req.items) == 0 {
+			continue
+		}
+		select {
+		case ss.s.d.trackStateReq <- req:
+			req.peer.FetchNodeData(req.items)
+			ss.codeReqs++
+		case <-ss.s.cancel:
+			return
+		case <-ss.s.d.cancelCh:
+			return
+		}
+	}
+}
+
+// processCodes writes the contract codes delivered by a peer into the database
+// and reschedules the missing ones.
+func (ss *snapSync) processCodes(req *stateReq) error {
+	ss.codeReqs--
+	if len(req.items) <= 2 && !req.dropped && req.timedOut() {
+		log.Warn("Stalling state sync, dropping peer", "peer", req.peer.id)
+		ss.s.d.dropPeer(req.peer.id)
+	}
+	start := time.Now()
+	written := 0
+	for _, blob := range req.response {
+		hash := crypto.Keccak256Hash(blob)
+		code := ss.codes[hash]
+		if code == nil || code.done {
+			continue
+		}
+		if err := ss.db.Put(hash[:], blob); err != nil {
+			return fmt.Errorf("DB write error: %v", err)
+		}
+		code.done, code.fetching = true, false
+		delete(ss.codes, hash)
+		written++
+	}
+	if written > 0 {
+		ss.s.updateStats(written, 0, 0, time.Since(start))
+	}
+	// Reschedule the undelivered codes, giving up if nobody has them
+	npeers := ss.s.d.peers.Len()
+	for _, hash := range req.items {
+		code := ss.codes[hash]
+		if code == nil || code.done {
+			continue
+		}
+		code.fetching = false
+		if len(req.response) > 0 || req.timedOut() {
+			delete(code.attempts, req.peer.id)
+		}
+		if len(code.attempts) >= npeers {
+			code.failed = true
+		}
+	}
+	req.peer.SetNodeDataIdle(len(req.response))
+	return nil
+}
+
+// verifyRange checks that a range of a trie is well formed and matches the
+// proofs of its boundaries: the proof of the origin must show that it is either
+// absent from the trie or the first key of the range, and the proof of the last
+// key must show its value.
+//
+// Note, the proofs don't show that the range has no gaps, that is left for the
+// trie assembled from the range to be checked against its root.
+func verifyRange(root, origin, limit common.Hash, keys []common.Hash, values [][]byte, proof [][]byte) error {
+	if len(keys) != len(values) {
+		return errRangeValues
+	}
+	for i, key := range keys {
+		if i > 0 && bytes.Compare(keys[i-1][:], key[:]) >= 0 {
+			return errRangeOrder
+		}
+		if bytes.Compare(key[:], origin[:]) < 0 || bytes.Compare(key[:], limit[:]) > 0 {
+			return errRangeBounds
+		}
+		if len(values[i]) == 0 {
+			return errRangeEmptyVal
+		}
+	}
+	proofDb := ethdb.NewMemDatabase()
+	for _, node := range proof {
+		proofDb.Put(crypto.Keccak256(node), node)
+	}
+	value, _, err := trie.VerifyProof(root, origin[:], proofDb)
+	if err != nil {
+		return fmt.Errorf("invalid origin proof: %v", err)
+	}
+	if value != nil && (len(keys) == 0 || keys[0] != origin || !bytes.Equal(values[0], value)) {
+		return fmt.Errorf("range omits origin %x", origin)
+	}
+	if len(keys) > 0 {
+		last := keys[len(keys)-1]
+		value, _, err := trie.VerifyProof(root, last[:], proofDb)
+		if err != nil {
+			return fmt.Errorf("invalid last key proof: %v", err)
+		}
+		if !bytes.Equal(values[len(values)-1], value) {
+			return fmt.Errorf("last key %x value mismatch", last)
+		}
+	}
+	return nil
+}
+
+// incHash returns the hash following h, wrapping around at the end.
+func incHash(h common.Hash) common.Hash {
+	for i := len(h) - 1; i >= 0; i-- {
+		h[i]++
+		if h[i] != 0 {
+			break
+		}
+	}
+	return h
+}
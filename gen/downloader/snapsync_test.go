@@ -0,0 +1,308 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"bytes"
+	"math/big"
+	"sync/atomic"
+	"testing"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/consensus/ethash"
+	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/core/state"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/params"
+	"github.com/genchain/go-genchain/rlp"
+	"github.com/genchain/go-genchain/trie"
+)
+
+// makeRangeTrie creates a trie with n leaves keyed by hashes.
+func makeRangeTrie(t *testing.T, n int) (*trie.Trie, []common.Hash) {
+	tr, _ := trie.New(common.Hash{}, trie.NewDatabase(ethdb.NewMemDatabase()))
+	keys := make([]common.Hash, 0, n)
+	for i := 0; i < n; i++ {
+		key := crypto.Keccak256Hash([]byte{byte(i >> 8), byte(i)})
+		if err := tr.TryUpdate(key[:], []byte{0x80 | byte(i%64), byte(i)}); err != nil {
+			t.Fatalf("failed to insert leaf %d: %v", i, err)
+		}
+	}
+	it := trie.NewIterator(tr.NodeIterator(nil))
+	for it.Next() {
+		keys = append(keys, common.BytesToHash(it.Key))
+	}
+	return tr, keys
+}
+
+// proveRange assembles a range of a trie the way a serving peer does.
+func proveRange(t *testing.T, tr *trie.Trie, origin common.Hash, count int) ([]common.Hash, [][]byte, [][]byte) {
+	var (
+		keys   []common.Hash
+		values [][]byte
+	)
+	it := trie.NewIterator(tr.NodeIterator(origin[:]))
+	for len(keys) < count && it.Next() {
+		keys = append(keys, common.BytesToHash(it.Key))
+		values = append(values, common.CopyBytes(it.Value))
+	}
+	proofDb := ethdb.NewMemDatabase()
+	if err := tr.Prove(origin[:], 0, proofDb); err != nil {
+		t.Fatalf("failed to prove origin: %v", err)
+	}
+	if len(keys) > 0 {
+		if err := tr.Prove(keys[len(keys)-1][:], 0, proofDb); err != nil {
+			t.Fatalf("failed to prove last key: %v", err)
+		}
+	}
+	var proof [][]byte
+	for _, key := range proofDb.Keys() {
+		node, _ := proofDb.Get(key)
+		proof = append(proof, node)
+	}
+	return keys, values, proof
+}
+
+// Tests that ranges of a trie are accepted if consistent with their boundary
+// proofs and rejected otherwise.
+func TestVerifyRange(t *testing.T) {
+	tr, all := makeRangeTrie(t, 500)
+	root := tr.Hash()
+
+	// Ranges starting at an existing key and in between two keys should pass
+	keys, values, proof := proveRange(t, tr, all[100], 50)
+	if err := verifyRange(root, all[100], maxHash, keys, values, proof); err != nil {
+		t.Fatalf("valid range rejected: %v", err)
+	}
+	origin := incHash(all[100])
+	keys, values, proof = proveRange(t, tr, origin, 50)
+	if err := verifyRange(root, origin, maxHash, keys, values, proof); err != nil {
+		t.Fatalf("valid range from absent origin rejected: %v", err)
+	}
+	if keys[0] != all[101] {
+		t.Fatalf("range start mismatch: have %x, want %x", keys[0], all[101])
+	}
+	// Ranges past the last key should pass empty
+	keys, values, proof = proveRange(t, tr, incHash(all[len(all)-1]), 50)
+	if err := verifyRange(root, incHash(all[len(all)-1]), maxHash, keys, values, proof); err != nil || len(keys) != 0 {
+		t.Fatalf("valid empty range rejected: %d keys, %v", len(keys), err)
+	}
+	// Ranges omitting the origin, with tampered boundaries or not in order should fail
+	keys, values, proof = proveRange(t, tr, all[100], 50)
+	if err := verifyRange(root, all[100], maxHash, keys[1:], values[1:], proof); err == nil {
+		t.Fatalf("range omitting origin accepted")
+	}
+	tampered := append([][]byte{}, values...)
+	tampered[len(tampered)-1] = []byte{0x01}
+	if err := verifyRange(root, all[100], maxHash, keys, tampered, proof); err == nil {
+		t.Fatalf("range with tampered last value accepted")
+	}
+	if err := verifyRange(root, all[100], maxHash, keys, values, proof[:1]); err == nil {
+		t.Fatalf("range with incomplete proof accepted")
+	}
+	swapped := append([]common.Hash{}, keys...)
+	swapped[1], swapped[2] = swapped[2], swapped[1]
+	if err := verifyRange(root, all[100], maxHash, swapped, values, proof); err != errRangeOrder {
+		t.Fatalf("unordered range error mismatch: have %v, want %v", err, errRangeOrder)
+	}
+	if err := verifyRange(root, all[100], keys[10], keys, values, proof); err != errRangeBounds {
+		t.Fatalf("range past limit error mismatch: have %v, want %v", err, errRangeBounds)
+	}
+	// Ranges of a different trie should fail
+	other, _ := makeRangeTrie(t, 499)
+	keys, values, proof = proveRange(t, other, all[100], 50)
+	if err := verifyRange(root, all[100], maxHash, keys, values, proof); err == nil {
+		t.Fatalf("range of a different trie accepted")
+	}
+}
+
+// Tests that the account chunks cover the entire hash space without overlaps.
+func TestSnapAccountChunks(t *testing.T) {
+	s := &stateSync{d: &Downloader{stateDB: ethdb.NewMemDatabase()}}
+	tasks := newSnapSync(s).accounts
+
+	if len(tasks) != snapAccountChunks {
+		t.Fatalf("chunk count mismatch: have %d, want %d", len(tasks), snapAccountChunks)
+	}
+	if tasks[0].next != (common.Hash{}) || tasks[len(tasks)-1].last != maxHash {
+		t.Fatalf("chunks don't cover the hash space: %x - %x", tasks[0].next, tasks[len(tasks)-1].last)
+	}
+	for i := 1; i < len(tasks); i++ {
+		if next := incHash(tasks[i-1].last); !bytes.Equal(next[:], tasks[i].next[:]) {
+			t.Errorf("chunk %d not adjacent to previous: have %x, want %x", i, tasks[i].next, next)
+		}
+	}
+}
+
+// Tests that snap sync retrieves the state of the pivot block in ranges and
+// assembles all of it, including contract storage and code.
+func TestSnapSync(t *testing.T) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Create a chain with a contract storing 32 slots and accounts spread over
+	// the hash space
+	initcode := []byte{}
+	for i := byte(1); i <= 32; i++ {
+		initcode = append(initcode, 0x60, i, 0x60, i, 0x55) // sstore(i, i)
+	}
+	initcode = append(initcode, 0x60, 0x00, 0x60, 0x00, 0x53, 0x60, 0x01, 0x60, 0x00, 0xf3) // return {0x00}
+
+	var contract common.Address
+	targetBlocks := blockCacheItems - 15
+	blocks, receipts := core.GenerateChain(params.TestChainConfig, tester.genesis, ethash.NewFaker(), tester.peerDb, targetBlocks, func(i int, block *core.BlockGen) {
+		signer := types.MakeSigner(params.TestChainConfig, block.Number())
+		switch {
+		case i == 0:
+			tx, _ := types.SignTx(types.NewContractCreation(block.TxNonce(testAddress), new(big.Int), 1000000, nil, initcode), signer, testKey)
+			block.AddTx(tx)
+			contract = crypto.CreateAddress(testAddress, tx.Nonce())
+		case i <= 50:
+			for j := 0; j < 10; j++ {
+				tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testAddress), common.Address{byte(i), byte(j)}, big.NewInt(1000), params.TxGas, nil, nil), signer, testKey)
+				block.AddTx(tx)
+			}
+		default:
+			// Change the state in every block, the tester marks states by root
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testAddress), common.Address{0xff}, big.NewInt(1000), params.TxGas, nil, nil), signer, testKey)
+			block.AddTx(tx)
+		}
+	})
+	hashes, headers, blockm, receiptm := chainMaps(tester.genesis, nil, blocks, receipts)
+
+	tester.newPeer("peer", 64, hashes, headers, blockm, receiptm)
+	if err := tester.sync("peer", nil, SnapSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	assertOwnChain(t, tester, targetBlocks+1)
+
+	if atomic.LoadInt32(&tester.peerRangeReqs) == 0 {
+		t.Fatalf("no state ranges requested")
+	}
+	// The pivot state must be complete
+	pivot := blocks[targetBlocks-fsMinFullBlocks-1]
+	statedb, err := state.New(pivot.Root(), state.NewDatabase(tester.stateDb))
+	if err != nil {
+		t.Fatalf("failed to open pivot state: %v", err)
+	}
+	if value := statedb.GetState(contract, common.BigToHash(big.NewInt(32))); value != common.BigToHash(big.NewInt(32)) {
+		t.Errorf("contract storage mismatch: have %x, want %x", value, common.BigToHash(big.NewInt(32)))
+	}
+	if code := statedb.GetCode(contract); !bytes.Equal(code, []byte{0x00}) {
+		t.Errorf("contract code mismatch: have %x, want 00", code)
+	}
+	reachable := make(map[common.Hash]bool)
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+		if it.Hash != (common.Hash{}) {
+			reachable[it.Hash] = true
+		}
+	}
+	if it.Error != nil {
+		t.Fatalf("pivot state incomplete: %v", it.Error)
+	}
+}
+
+// Tests that the account tries assembled from ranges are written out as the
+// ranges arrive, and completely once all the accounts of their task are retrieved.
+func TestSnapAccountCommit(t *testing.T) {
+	// Create a state with accounts lacking storage and code
+	tr, _ := trie.New(common.Hash{}, trie.NewDatabase(ethdb.NewMemDatabase()))
+	for i := 0; i < 200; i++ {
+		blob, _ := rlp.EncodeToBytes(state.Account{Nonce: uint64(i), Balance: big.NewInt(int64(i)), Root: emptyRoot, CodeHash: emptyCode[:]})
+		key := crypto.Keccak256Hash([]byte{byte(i)})
+		tr.Update(key[:], blob)
+	}
+	root := tr.Hash()
+
+	// Assemble the state from several ranges of a single task
+	db := ethdb.NewMemDatabase()
+	ss := newStateSync(&Downloader{mode: SnapSync, stateDB: db}, root).snap
+	task := ss.accounts[0]
+	task.last = maxHash
+	ss.accounts = ss.accounts[:1]
+
+	for ranges := 0; !task.done; ranges++ {
+		if n := countNodes(db); ranges > 0 && n == 0 {
+			t.Fatalf("no nodes written after %d ranges", ranges)
+		}
+		keys, values, proof := proveRange(t, tr, task.next, 80)
+		if err := ss.processAccounts(&rangeReq{account: task, origin: task.next}, &rangePack{"peer", keys, values, proof}); err != nil {
+			t.Fatalf("failed to process range: %v", err)
+		}
+		if err := ss.commitAccounts(); err != nil {
+			t.Fatalf("failed to commit range: %v", err)
+		}
+	}
+	if !task.written {
+		t.Fatalf("finished task not written")
+	}
+	assembled, err := trie.New(root, trie.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("assembled trie not written: %v", err)
+	}
+	it := assembled.NodeIterator(nil)
+	for it.Next(true) {
+	}
+	if it.Error() != nil {
+		t.Fatalf("assembled trie incomplete: %v", it.Error())
+	}
+}
+
+// Tests that accounts are inserted into the account tries in order, holding back
+// the ones following an account still waiting for its storage.
+func TestSnapAccountOrder(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	ss := newStateSync(&Downloader{mode: SnapSync, stateDB: db}, common.Hash{}).snap
+	task := ss.accounts[0]
+	ss.accounts = ss.accounts[:1]
+
+	storage := &snapStorageTask{}
+	task.pending = []*snapAccount{
+		{hash: common.Hash{0x01}, blob: []byte{0x01}},
+		{hash: common.Hash{0x02}, blob: []byte{0x02}, storage: storage},
+		{hash: common.Hash{0x03}, blob: []byte{0x03}},
+	}
+	if err := ss.commitAccounts(); err != nil {
+		t.Fatalf("failed to commit accounts: %v", err)
+	}
+	if len(task.pending) != 2 || task.pending[0].hash != (common.Hash{0x02}) {
+		t.Fatalf("accounts after pending storage inserted: %d left", len(task.pending))
+	}
+	storage.done = true
+	if err := ss.commitAccounts(); err != nil {
+		t.Fatalf("failed to commit accounts: %v", err)
+	}
+	if len(task.pending) != 0 {
+		t.Fatalf("%d accounts left after storage retrieved", len(task.pending))
+	}
+}
+
+// countNodes counts the trie nodes in a database.
+func countNodes(db *ethdb.MemDatabase) int {
+	n := 0
+	for _, key := range db.Keys() {
+		if len(key) == common.HashLength {
+			n++
+		}
+	}
+	return n
+}
@@ -80,6 +80,8 @@ func (d *Downloader) stateFetcher() {
 			}
 		case <-d.stateCh:
 			// Ignore state responses while no sync is running.
+		case <-d.rangeCh:
+			// Ignore state ranges while no sync is running.
 		case <-d.quitCh:
 			return
 		}
@@ -152,6 +154,19 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 			finished = append(finished, req)
 			delete(active, pack.PeerId())
 
+		// Handle incoming state ranges:
+		case pack := <-d.rangeCh:
+			// Range requests are tracked by the sync itself, forward if it's expecting any
+			if s.ranges == nil {
+				log.Debug("Unrequested state range", "peer", pack.PeerId(), "len", pack.Items())
+				continue
+			}
+			select {
+			case s.ranges <- pack.(*rangePack):
+			default:
+				log.Debug("Dropping state range, sync busy", "peer", pack.PeerId(), "len", pack.Items())
+			}
+
 			// Handle dropped peer connections:
 		case p := <-peerDrop:
 			// Skip if no request is currently pending
@@ -212,7 +227,11 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 // stateSync schedules requests for downloading a particular state trie defined
 // by a given state root.
 type stateSync struct {
-	d *Downloader // Downloader instance to access and manage current peerset
+	d    *Downloader // Downloader instance to access and manage current peerset
+	root common.Hash // State root being synced
+
+	snap   *snapSync       // Range download phase preceding the trie node sync (snap sync only)
+	ranges chan *rangePack // Delivery channel of state ranges for the range download phase
 
 	sched  *trie.TrieSync             // State trie sync scheduler defining the tasks
 	keccak hash.Hash                  // Keccak256 hasher to verify deliveries with
@@ -237,8 +256,9 @@ type stateTask struct {
 // newStateSync creates a new state trie download scheduler. This method does not
 // yet start the sync. The user needs to call run to initiate.
 func newStateSync(d *Downloader, root common.Hash) *stateSync {
-	return &stateSync{
+	s := &stateSync{
 		d:       d,
+		root:    root,
 		sched:   state.NewStateSync(root, d.stateDB),
 		keccak:  sha3.NewKeccak256(),
 		tasks:   make(map[common.Hash]*stateTask),
@@ -246,13 +266,30 @@ func newStateSync(d *Downloader, root common.Hash) *stateSync {
 		cancel:  make(chan struct{}),
 		done:    make(chan struct{}),
 	}
+	if d.mode == SnapSync {
+		s.snap = newSnapSync(s)
+		s.ranges = make(chan *rangePack, 64)
+	}
+	return s
 }
 
 // run starts the task assignment and response processing loop, blocking until
 // it finishes, and finally notifying any goroutines waiting for the loop to
 // finish.
 func (s *stateSync) run() {
-	s.err = s.loop()
+	if s.snap != nil {
+		// Download the bulk of the state in ranges, then heal the gaps and the
+		// changes since the ranges were retrieved with the trie node sync
+		if s.err = s.snap.loop(); s.err == nil {
+			s.sched = state.NewStateSync(s.root, s.d.stateDB)
+			s.err = s.loop()
+		}
+		if s.err == nil {
+			s.d.snapTasks = nil
+		}
+	} else {
+		s.err = s.loop()
+	}
 	close(s.done)
 }
 
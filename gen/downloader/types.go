@@ -19,6 +19,7 @@ package downloader
 import (
 	"fmt"
 
+	"github.com/genchain/go-genchain/common"
 	"github.com/genchain/go-genchain/core/types"
 )
 
@@ -77,3 +78,16 @@ type statePack struct {
 func (p *statePack) PeerId() string { return p.peerId }
 func (p *statePack) Items() int     { return len(p.states) }
 func (p *statePack) Stats() string  { return fmt.Sprintf("%d", len(p.states)) }
+
+// rangePack is a range of accounts or storage slots returned by a peer, along
+// with the proofs of its boundaries.
+type rangePack struct {
+	peerId string
+	keys   []common.Hash
+	values [][]byte
+	proof  [][]byte
+}
+
+func (p *rangePack) PeerId() string { return p.peerId }
+func (p *rangePack) Items() int     { return len(p.keys) }
+func (p *rangePack) Stats() string  { return fmt.Sprintf("%d", len(p.keys)) }
@@ -0,0 +1,205 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package gen
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"math/big"
+	"sort"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/log"
+	"github.com/genchain/go-genchain/p2p/enr"
+	"github.com/genchain/go-genchain/params"
+	"github.com/genchain/go-genchain/rlp"
+)
+
+// genEntry is the "gen" node record entry, announcing the chain a node follows
+// so that nodes of other chains can be skipped before dialing them.
+type genEntry struct {
+	Genesis  common.Hash // Hash of the genesis block of the chain
+	ForkHash [4]byte     // Checksum of the genesis hash and the fork blocks passed by the node
+	NextFork uint64      // First fork block past the head of the node, zero if none
+
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// ENRKey implements enr.Entry.
+func (e genEntry) ENRKey() string {
+	return "gen"
+}
+
+// gatherForks returns the sorted, distinct non-genesis fork blocks of a chain
+// configuration.
+func gatherForks(config *params.ChainConfig) []uint64 {
+	blocks := []*big.Int{
+		config.SeaBlock,
+		config.RiverBlock,
+		config.ValleyBlock,
+		config.HomesteadBlock,
+		config.DAOForkBlock,
+		config.EIP150Block,
+		config.EIP155Block,
+		config.EIP158Block,
+		config.ByzantiumBlock,
+		config.ConstantinopleBlock,
+	}
+	for _, precompile := range config.Precompiles {
+		blocks = append(blocks, precompile.Block)
+	}
+	var forks []uint64
+	for _, block := range blocks {
+		if block != nil && block.Sign() > 0 {
+			forks = append(forks, block.Uint64())
+		}
+	}
+	sort.Slice(forks, func(i, j int) bool { return forks[i] < forks[j] })
+
+	distinct := forks[:0]
+	for i, fork := range forks {
+		if i == 0 || fork != forks[i-1] {
+			distinct = append(distinct, fork)
+		}
+	}
+	return distinct
+}
+
+// nextFork returns the first fork block past the given head, zero if none.
+func nextFork(forks []uint64, head uint64) uint64 {
+	for _, fork := range forks {
+		if fork > head {
+			return fork
+		}
+	}
+	return 0
+}
+
+// forkHash returns the CRC32 checksum of the genesis hash and the given fork
+// blocks, identifying the forks a node has passed.
+func forkHash(genesis common.Hash, forks []uint64) [4]byte {
+	sum := crc32.ChecksumIEEE(genesis[:])
+	for _, fork := range forks {
+		sum = updateForkHash(sum, fork)
+	}
+	var hash [4]byte
+	binary.BigEndian.PutUint32(hash[:], sum)
+	return hash
+}
+
+// updateForkHash adds a fork block to a fork checksum.
+func updateForkHash(sum uint32, fork uint64) uint32 {
+	var blob [8]byte
+	binary.BigEndian.PutUint64(blob[:], fork)
+	return crc32.Update(sum, crc32.IEEETable, blob[:])
+}
+
+// newGenEntry constructs the "gen" entry of a node following the given chain,
+// with its head at the given block.
+func newGenEntry(genesis common.Hash, forks []uint64, head uint64) *genEntry {
+	passed := 0
+	for passed < len(forks) && forks[passed] <= head {
+		passed++
+	}
+	return &genEntry{
+		Genesis:  genesis,
+		ForkHash: forkHash(genesis, forks[:passed]),
+		NextFork: nextFork(forks, head),
+	}
+}
+
+// currentENREntry constructs the "gen" entry announcing the local chain.
+func (pm *ProtocolManager) currentENREntry() *genEntry {
+	return newGenEntry(pm.blockchain.Genesis().Hash(), gatherForks(pm.chainconfig), pm.blockchain.CurrentBlock().NumberU64())
+}
+
+// enrEntryLoop signs the local node record again with a fresh "gen" entry when
+// the chain head passes a fork, so that the record doesn't announce stale forks.
+func (pm *ProtocolManager) enrEntryLoop(setEntry func(enr.Entry) error) {
+	headCh := make(chan core.ChainHeadEvent, chainHeadChanSize)
+	headSub := pm.blockchain.SubscribeChainHeadEvent(headCh)
+	defer headSub.Unsubscribe()
+
+	current := pm.currentENREntry()
+	for {
+		select {
+		case <-headCh:
+			entry := pm.currentENREntry()
+			if entry.ForkHash == current.ForkHash && entry.NextFork == current.NextFork {
+				continue
+			}
+			if err := setEntry(entry); err != nil {
+				log.Warn("Failed to update node record", "err", err)
+				continue
+			}
+			current = entry
+
+		case <-headSub.Err():
+			return
+		case <-pm.quitSync:
+			return
+		}
+	}
+}
+
+// dialFilter reports whether a node found through discovery may follow the local
+// chain, judging by the "gen" entry of its record. Only nodes announcing another
+// chain are rejected, records without the entry can't tell.
+func (pm *ProtocolManager) dialFilter(record *enr.Record) bool {
+	var entry genEntry
+	if err := record.Load(&entry); err != nil {
+		return enr.IsNotFound(err)
+	}
+	return entry.compatible(pm.blockchain.Genesis().Hash(), gatherForks(pm.chainconfig), pm.blockchain.CurrentBlock().NumberU64())
+}
+
+// compatible reports whether the announced chain is compatible with a local
+// one. The forks passed by the remote node must be the local forks up to some
+// block: all the forks passed locally if the remote is ahead, with the next
+// local fork announced if it is behind. A remote that passed the same forks is
+// rejected if it announces a next fork that was passed locally without it.
+func (e *genEntry) compatible(genesis common.Hash, forks []uint64, head uint64) bool {
+	if e.Genesis != genesis {
+		return false
+	}
+	passed := 0
+	for passed < len(forks) && forks[passed] <= head {
+		passed++
+	}
+	sum := crc32.ChecksumIEEE(genesis[:])
+	for i := 0; i <= len(forks); i++ {
+		var hash [4]byte
+		binary.BigEndian.PutUint32(hash[:], sum)
+
+		if hash == e.ForkHash {
+			switch {
+			case i == passed:
+				return e.NextFork == 0 || e.NextFork > head
+			case i < passed:
+				return e.NextFork == forks[i]
+			default:
+				return true
+			}
+		}
+		if i < len(forks) {
+			sum = updateForkHash(sum, forks[i])
+		}
+	}
+	return false
+}
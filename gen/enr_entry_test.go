@@ -0,0 +1,161 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package gen
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/consensus/ethash"
+	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/gen/downloader"
+	"github.com/genchain/go-genchain/p2p/enr"
+	"github.com/genchain/go-genchain/params"
+)
+
+// Tests that fork blocks are gathered sorted and without duplicates.
+func TestGatherForks(t *testing.T) {
+	config := &params.ChainConfig{
+		HomesteadBlock: big.NewInt(0),
+		EIP150Block:    big.NewInt(20),
+		EIP155Block:    big.NewInt(10),
+		EIP158Block:    big.NewInt(10),
+		ByzantiumBlock: big.NewInt(30),
+		Precompiles:    []*params.PrecompileConfig{{Name: "test", Block: big.NewInt(25)}},
+	}
+	forks := gatherForks(config)
+	if want := []uint64{10, 20, 25, 30}; !reflect.DeepEqual(forks, want) {
+		t.Fatalf("forks mismatch: have %v, want %v", forks, want)
+	}
+	for head, want := range map[uint64]uint64{0: 10, 10: 20, 24: 25, 30: 0} {
+		if next := nextFork(forks, head); next != want {
+			t.Errorf("head %d: next fork mismatch: have %d, want %d", head, next, want)
+		}
+	}
+}
+
+// Tests that announced chains are checked against the local one.
+func TestGenEntryCompatible(t *testing.T) {
+	var (
+		genesis = common.HexToHash("0x01")
+		forks   = []uint64{10, 20}
+	)
+	entry := func(passed []uint64, next uint64) genEntry {
+		return genEntry{Genesis: genesis, ForkHash: forkHash(genesis, passed), NextFork: next}
+	}
+	tests := []struct {
+		entry genEntry
+		head  uint64
+		want  bool
+	}{
+		{*newGenEntry(genesis, forks, 5), 5, true},
+		{*newGenEntry(genesis, forks, 25), 25, true},
+		{*newGenEntry(common.HexToHash("0x02"), forks, 5), 5, false},
+		{entry(nil, 15), 5, true},               // unknown fork not yet reached
+		{entry([]uint64{10}, 15), 12, true},     // unknown fork not yet reached
+		{entry([]uint64{10}, 15), 15, false},    // unknown fork already passed
+		{entry(nil, 10), 25, true},              // remote behind on a known fork
+		{entry([]uint64{10}, 20), 25, true},     // remote behind on a known fork
+		{entry(nil, 0), 25, false},              // remote behind, unaware of the passed forks
+		{entry([]uint64{10}, 0), 25, false},     // remote behind, unaware of the passed forks
+		{entry(nil, 15), 25, false},             // remote behind, heading for another fork
+		{entry([]uint64{10, 20}, 0), 5, true},   // remote ahead on known forks
+		{entry([]uint64{10, 15}, 0), 12, false}, // remote ahead on an unknown fork
+		{genEntry{Genesis: genesis}, 0, false},  // no fork checksum
+	}
+	for i, tt := range tests {
+		if have := tt.entry.compatible(genesis, forks, tt.head); have != tt.want {
+			t.Errorf("test %d: compatibility mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that the dial filter rejects records announcing another chain only.
+func TestDialFilter(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	sign := func(entries ...enr.Entry) *enr.Record {
+		key, _ := crypto.GenerateKey()
+		record := new(enr.Record)
+		for _, entry := range entries {
+			record.Set(entry)
+		}
+		if err := enr.SignV4(record, key); err != nil {
+			t.Fatalf("failed to sign record: %v", err)
+		}
+		return record
+	}
+	if !pm.dialFilter(sign(pm.currentENREntry())) {
+		t.Errorf("record of the local chain rejected")
+	}
+	if pm.dialFilter(sign(&genEntry{Genesis: common.HexToHash("0x01")})) {
+		t.Errorf("record of another chain accepted")
+	}
+	if !pm.dialFilter(sign()) {
+		t.Errorf("record without chain rejected")
+	}
+	if pm.dialFilter(sign(enr.WithEntry("gen", []byte{0x01}))) {
+		t.Errorf("record with invalid chain entry accepted")
+	}
+}
+
+// Tests that the node record is signed again once the chain passes a fork.
+func TestENREntryUpdate(t *testing.T) {
+	pm, db := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	config := *pm.chainconfig
+	config.Precompiles = append(config.Precompiles, &params.PrecompileConfig{Name: "test", Block: big.NewInt(2)})
+	pm.chainconfig = &config
+
+	entries := make(chan enr.Entry, 1)
+	go pm.enrEntryLoop(func(entry enr.Entry) error {
+		entries <- entry
+		return nil
+	})
+	if entry := pm.currentENREntry(); entry.NextFork != 2 {
+		t.Fatalf("next fork mismatch: have %d, want 2", entry.NextFork)
+	}
+	time.Sleep(50 * time.Millisecond) // Wait for the loop to subscribe
+
+	blocks, _ := core.GenerateChain(pm.blockchain.Config(), pm.blockchain.Genesis(), ethash.NewFaker(), db, 2, nil)
+	if _, err := pm.blockchain.InsertChain(blocks[:1]); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+	select {
+	case entry := <-entries:
+		t.Fatalf("record updated before the fork: %+v", entry)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, err := pm.blockchain.InsertChain(blocks[1:]); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+	select {
+	case entry := <-entries:
+		want := newGenEntry(pm.blockchain.Genesis().Hash(), []uint64{2}, 2)
+		if !reflect.DeepEqual(entry, want) {
+			t.Errorf("entry mismatch: have %+v, want %+v", entry, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("record not updated after the fork")
+	}
+}
@@ -38,6 +38,48 @@ var (
 	deadline = 5 * time.Minute // consider a filter inactive if it has not been polled for within deadline
 )
 
+// defaultPageSize is the number of logs returned by a paged log query if neither
+// the client nor the node configuration specify a page size.
+const defaultPageSize = 1000
+
+// Config contains the limits imposed on historical log queries served by the
+// filter API. A zero value disables the respective limit.
+type Config struct {
+	MaxBlockRange uint64 // Maximum number of blocks a single log query may span
+	MaxResults    int    // Maximum number of logs a single log query may return
+}
+
+// LimitExceededError is returned if a historical log query exceeds one of the
+// limits configured on the filter API.
+type LimitExceededError struct {
+	Limit string `json:"limit"` // Name of the exceeded limit (blockRange or results)
+	Max   uint64 `json:"max"`   // Configured maximum of the exceeded limit
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("query exceeds %s limit of %d, use gen_getLogsPaged", e.Limit, e.Max)
+}
+
+// ErrorCode returns the JSON-RPC error code for exceeded resource limits.
+func (e *LimitExceededError) ErrorCode() int { return -32005 }
+
+// ErrorData returns the exceeded limit to the client.
+func (e *LimitExceededError) ErrorData() interface{} { return e }
+
+// LogCursor is the position within the chain from which a paged log query
+// resumes, inclusive.
+type LogCursor struct {
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	LogIndex    hexutil.Uint   `json:"logIndex"`
+}
+
+// LogPage is a single page of results returned by a paged log query. Next is
+// nil if the queried range is exhausted.
+type LogPage struct {
+	Logs []*types.Log `json:"logs"`
+	Next *LogCursor   `json:"next"`
+}
+
 // filter is a helper struct that holds meta information over the filter type
 // and associated subscription in the event system.
 type filter struct {
@@ -53,6 +95,7 @@ type filter struct {
 // information related to the Genchain protocol such als blocks, transactions and logs.
 type PublicFilterAPI struct {
 	backend   Backend
+	config    Config
 	mux       *event.TypeMux
 	quit      chan struct{}
 	chainDb   ethdb.Database
@@ -62,9 +105,10 @@ type PublicFilterAPI struct {
 }
 
 // NewPublicFilterAPI returns a new PublicFilterAPI instance.
-func NewPublicFilterAPI(backend Backend, lightMode bool) *PublicFilterAPI {
+func NewPublicFilterAPI(backend Backend, lightMode bool, config Config) *PublicFilterAPI {
 	api := &PublicFilterAPI{
 		backend: backend,
+		config:  config,
 		mux:     backend.EventMux(),
 		chainDb: backend.ChainDb(),
 		events:  NewEventSystem(backend.EventMux(), backend, lightMode),
@@ -322,6 +366,9 @@ func (api *PublicFilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
 
 // GetLogs returns logs matching the given argument that are stored within the state.
 //
+// If the query spans more blocks or matches more logs than permitted by the node
+// configuration, a LimitExceededError is returned.
+//
 // https://github.com/genchain/wiki/wiki/JSON-RPC#gen_getlogs
 func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
 	// Convert the RPC block numbers into internal representations
@@ -331,14 +378,84 @@ func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([
 	if crit.ToBlock == nil {
 		crit.ToBlock = big.NewInt(rpc.LatestBlockNumber.Int64())
 	}
-	// Create and run the filter to get all the logs
-	filter := New(api.backend, crit.FromBlock.Int64(), crit.ToBlock.Int64(), crit.Addresses, crit.Topics)
+	return api.limitedLogs(ctx, crit.FromBlock.Int64(), crit.ToBlock.Int64(), crit.Addresses, crit.Topics)
+}
 
-	logs, err := filter.Logs(ctx)
+// GetLogsPaged returns a single page of logs matching the given argument that are
+// stored within the state, starting at the optional cursor. If the page is full
+// or the configured block range limit cut the search short, the returned page
+// contains the cursor from which to continue the query.
+//
+// The number of logs per page defaults to the result limit of the node and can
+// be lowered, but not raised, with the limit argument.
+func (api *PublicFilterAPI) GetLogsPaged(ctx context.Context, crit FilterCriteria, cursor *LogCursor, limit *hexutil.Uint) (*LogPage, error) {
+	// Convert the RPC block numbers into internal representations
+	begin, end := rpc.LatestBlockNumber.Int64(), rpc.LatestBlockNumber.Int64()
+	if crit.FromBlock != nil {
+		begin = crit.FromBlock.Int64()
+	}
+	if crit.ToBlock != nil {
+		end = crit.ToBlock.Int64()
+	}
+	from, to, err := api.resolveRange(ctx, begin, end)
 	if err != nil {
 		return nil, err
 	}
-	return returnLogs(logs), err
+	if cursor != nil {
+		if uint64(cursor.BlockNumber) < from || uint64(cursor.BlockNumber) > to {
+			return nil, fmt.Errorf("cursor block %d outside of queried range [%d, %d]", cursor.BlockNumber, from, to)
+		}
+		from = uint64(cursor.BlockNumber)
+	}
+	// Figure out the size of the page and the part of the range to search
+	size := defaultPageSize
+	if api.config.MaxResults > 0 {
+		size = api.config.MaxResults
+	}
+	if limit != nil && *limit > 0 && int(*limit) < size {
+		size = int(*limit)
+	}
+	last := to
+	if max := api.config.MaxBlockRange; max > 0 && from <= to && to-from >= max {
+		to = from + max - 1
+	}
+	page := &LogPage{Logs: []*types.Log{}}
+
+	// Gather the logs of the cursor block first, skipping the ones already returned
+	if cursor != nil && cursor.LogIndex > 0 {
+		logs, err := New(api.backend, int64(from), int64(from), crit.Addresses, crit.Topics).Logs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			if log.Index >= uint(cursor.LogIndex) {
+				page.Logs = append(page.Logs, log)
+			}
+		}
+		from++
+	}
+	// Gather the logs of the remaining blocks until the page overflows
+	if from <= to && len(page.Logs) <= size {
+		filter := New(api.backend, int64(from), int64(to), crit.Addresses, crit.Topics)
+		filter.limit = size + 1 - len(page.Logs)
+
+		logs, err := filter.Logs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		page.Logs = append(page.Logs, logs...)
+	}
+	// Trim the page and point the cursor to the first log left out
+	switch {
+	case len(page.Logs) > size:
+		next := page.Logs[size]
+		page.Next = &LogCursor{BlockNumber: hexutil.Uint64(next.BlockNumber), LogIndex: hexutil.Uint(next.Index)}
+		page.Logs = page.Logs[:size]
+
+	case to < last:
+		page.Next = &LogCursor{BlockNumber: hexutil.Uint64(to + 1)}
+	}
+	return page, nil
 }
 
 // UninstallFilter removes the filter with the given filter id.
@@ -379,16 +496,58 @@ func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*ty
 	if f.crit.ToBlock != nil {
 		end = f.crit.ToBlock.Int64()
 	}
-	// Create and run the filter to get all the logs
-	filter := New(api.backend, begin, end, f.crit.Addresses, f.crit.Topics)
+	return api.limitedLogs(ctx, begin, end, f.crit.Addresses, f.crit.Topics)
+}
 
+// limitedLogs runs a log filter over the given block range, enforcing the block
+// range and result limits configured on the API.
+func (api *PublicFilterAPI) limitedLogs(ctx context.Context, begin, end int64, addresses []common.Address, topics [][]common.Hash) ([]*types.Log, error) {
+	if api.config.MaxBlockRange > 0 {
+		from, to, err := api.resolveRange(ctx, begin, end)
+		if err != nil {
+			return nil, err
+		}
+		if from <= to && to-from >= api.config.MaxBlockRange {
+			return nil, &LimitExceededError{Limit: "blockRange", Max: api.config.MaxBlockRange}
+		}
+	}
+	// Create and run the filter to get all the logs, stopping if the limit is exceeded
+	filter := New(api.backend, begin, end, addresses, topics)
+	if api.config.MaxResults > 0 {
+		filter.limit = api.config.MaxResults + 1
+	}
 	logs, err := filter.Logs(ctx)
 	if err != nil {
 		return nil, err
 	}
+	if api.config.MaxResults > 0 && len(logs) > api.config.MaxResults {
+		return nil, &LimitExceededError{Limit: "results", Max: uint64(api.config.MaxResults)}
+	}
 	return returnLogs(logs), nil
 }
 
+// resolveRange converts the given block range, which may reference the latest
+// block, into absolute block numbers.
+func (api *PublicFilterAPI) resolveRange(ctx context.Context, begin, end int64) (uint64, uint64, error) {
+	header, err := api.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return 0, 0, err
+	}
+	if header == nil {
+		return 0, 0, errors.New("latest header not found")
+	}
+	head := header.Number.Uint64()
+
+	from, to := uint64(begin), uint64(end)
+	if begin < 0 {
+		from = head
+	}
+	if end < 0 {
+		to = head
+	}
+	return from, to, nil
+}
+
 // GetFilterChanges returns the logs for the filter with the given id since
 // last time it was called. This can be used for polling.
 //
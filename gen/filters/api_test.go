@@ -17,11 +17,18 @@
 package filters
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"testing"
 
 	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/hexutil"
+	"github.com/genchain/go-genchain/core/rawdb"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/event"
 	"github.com/genchain/go-genchain/rpc"
 )
 
@@ -183,3 +190,145 @@ func TestUnmarshalJSONNewFilterArgs(t *testing.T) {
 		t.Fatalf("expected 0 topics, got %d topics", len(test7.Topics[2]))
 	}
 }
+
+// newLogChainBackend creates a test backend with a canonical chain of the given
+// length, where every block contains the given number of logs from addr.
+func newLogChainBackend(blocks int, perBlock int, addr common.Address) *testBackend {
+	db := ethdb.NewMemDatabase()
+	for i := 0; i < blocks; i++ {
+		receipt := types.NewReceipt(nil, false, 0)
+		for j := 0; j < perBlock; j++ {
+			receipt.Logs = append(receipt.Logs, &types.Log{
+				Address:     addr,
+				BlockNumber: uint64(i),
+				Index:       uint(j),
+			})
+		}
+		receipts := types.Receipts{receipt}
+		receipt.Bloom = types.CreateBloom(receipts)
+
+		header := &types.Header{Number: big.NewInt(int64(i)), Bloom: types.CreateBloom(receipts)}
+		for _, log := range receipt.Logs {
+			log.BlockHash = header.Hash()
+		}
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteCanonicalHash(db, header.Hash(), uint64(i))
+		rawdb.WriteReceipts(db, header.Hash(), uint64(i), receipts)
+		rawdb.WriteHeadBlockHash(db, header.Hash())
+	}
+	return &testBackend{mux: new(event.TypeMux), db: db, txFeed: new(event.Feed), rmLogsFeed: new(event.Feed), logsFeed: new(event.Feed), chainFeed: new(event.Feed)}
+}
+
+// Tests that historical log queries exceeding the configured limits are rejected
+// with a structured error, and queries within them are served in full.
+func TestGetLogsLimits(t *testing.T) {
+	var (
+		addr    = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		backend = newLogChainBackend(10, 3, addr)
+	)
+	tests := []struct {
+		config Config
+		from   int64
+		to     int64
+		logs   int
+		limit  string
+	}{
+		{Config{}, 0, 9, 30, ""},
+		{Config{MaxBlockRange: 10}, 0, 9, 30, ""},
+		{Config{MaxBlockRange: 9}, 0, 9, 0, "blockRange"},
+		{Config{MaxBlockRange: 5}, 5, -1, 15, ""},
+		{Config{MaxBlockRange: 4}, 5, -1, 0, "blockRange"},
+		{Config{MaxResults: 30}, 0, 9, 30, ""},
+		{Config{MaxResults: 29}, 0, 9, 0, "results"},
+		{Config{MaxResults: 29}, 1, 9, 27, ""},
+	}
+	for i, tt := range tests {
+		api := &PublicFilterAPI{backend: backend, config: tt.config}
+		crit := FilterCriteria{FromBlock: big.NewInt(tt.from), ToBlock: big.NewInt(tt.to), Addresses: []common.Address{addr}}
+
+		logs, err := api.GetLogs(context.Background(), crit)
+		if tt.limit != "" {
+			lerr, ok := err.(*LimitExceededError)
+			if !ok {
+				t.Errorf("test %d: error mismatch: have %v, want limit exceeded", i, err)
+				continue
+			}
+			if lerr.Limit != tt.limit {
+				t.Errorf("test %d: exceeded limit mismatch: have %s, want %s", i, lerr.Limit, tt.limit)
+			}
+			if lerr.ErrorCode() != -32005 {
+				t.Errorf("test %d: error code mismatch: have %d, want %d", i, lerr.ErrorCode(), -32005)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: failed to retrieve logs: %v", i, err)
+			continue
+		}
+		if len(logs) != tt.logs {
+			t.Errorf("test %d: log count mismatch: have %d, want %d", i, len(logs), tt.logs)
+		}
+	}
+}
+
+// Tests that paged log queries return every matching log exactly once and in
+// order, regardless of the page size and the configured block range limit.
+func TestGetLogsPaged(t *testing.T) {
+	var (
+		addr    = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		backend = newLogChainBackend(10, 3, addr)
+	)
+	tests := []struct {
+		config Config
+		limit  uint
+		pages  int
+	}{
+		{Config{}, 0, 1},
+		{Config{MaxResults: 7}, 0, 5},
+		{Config{MaxResults: 7}, 10, 5},
+		{Config{}, 2, 15},
+		{Config{}, 4, 8},
+		{Config{MaxBlockRange: 3}, 0, 4},
+		{Config{MaxBlockRange: 3, MaxResults: 5}, 0, 6},
+	}
+	for i, tt := range tests {
+		var (
+			api    = &PublicFilterAPI{backend: backend, config: tt.config}
+			crit   = FilterCriteria{FromBlock: big.NewInt(0), ToBlock: big.NewInt(-1), Addresses: []common.Address{addr}}
+			cursor *LogCursor
+			logs   []*types.Log
+			pages  int
+		)
+		for {
+			var limit *hexutil.Uint
+			if tt.limit > 0 {
+				limit = (*hexutil.Uint)(&tt.limit)
+			}
+			page, err := api.GetLogsPaged(context.Background(), crit, cursor, limit)
+			if err != nil {
+				t.Fatalf("test %d, page %d: failed to retrieve logs: %v", i, pages, err)
+			}
+			pages++
+			logs = append(logs, page.Logs...)
+
+			if cursor = page.Next; cursor == nil {
+				break
+			}
+			if pages > 100 {
+				t.Fatalf("test %d: paging did not terminate", i)
+			}
+		}
+		if pages != tt.pages {
+			t.Errorf("test %d: page count mismatch: have %d, want %d", i, pages, tt.pages)
+		}
+		if len(logs) != 30 {
+			t.Errorf("test %d: log count mismatch: have %d, want %d", i, len(logs), 30)
+			continue
+		}
+		for j, log := range logs {
+			if log.BlockNumber != uint64(j/3) || log.Index != uint(j%3) {
+				t.Errorf("test %d: log %d position mismatch: have %d/%d, want %d/%d", i, j, log.BlockNumber, log.Index, j/3, j%3)
+			}
+		}
+	}
+}
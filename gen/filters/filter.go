@@ -53,6 +53,7 @@ type Filter struct {
 	begin, end int64
 	addresses  []common.Address
 	topics     [][]common.Hash
+	limit      int // Number of logs after which to stop at the next block boundary (0 = unlimited)
 
 	matcher *bloombits.Matcher
 }
@@ -94,6 +95,10 @@ func New(backend Backend, begin, end int64, addresses []common.Address, topics [
 
 // Logs searches the blockchain for matching log entries, returning all from the
 // first block that contains matches, updating the start of the filter accordingly.
+//
+// If a result limit is set on the filter, the search is aborted at the first block
+// boundary after the limit is reached, leaving the start of the filter pointing to
+// the next block to process.
 func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	// Figure out the limits of the filter range
 	header, _ := f.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
@@ -121,11 +126,11 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 		} else {
 			logs, err = f.indexedLogs(ctx, indexed-1)
 		}
-		if err != nil {
+		if err != nil || f.limitReached(len(logs)) {
 			return logs, err
 		}
 	}
-	rest, err := f.unindexedLogs(ctx, end)
+	rest, err := f.unindexedLogs(ctx, end, len(logs))
 	logs = append(logs, rest...)
 	return logs, err
 }
@@ -171,6 +176,10 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 			}
 			logs = append(logs, found...)
 
+			if f.limitReached(len(logs)) {
+				return logs, nil
+			}
+
 		case <-ctx.Done():
 			return logs, ctx.Err()
 		}
@@ -178,11 +187,12 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 }
 
 // indexedLogs returns the logs matching the filter criteria based on raw block
-// iteration and bloom matching.
-func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
+// iteration and bloom matching. The found parameter is the number of logs already
+// gathered by a previous indexed search, counting towards the result limit.
+func (f *Filter) unindexedLogs(ctx context.Context, end uint64, found int) ([]*types.Log, error) {
 	var logs []*types.Log
 
-	for ; f.begin <= int64(end); f.begin++ {
+	for ; f.begin <= int64(end) && !f.limitReached(found+len(logs)); f.begin++ {
 		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(f.begin))
 		if header == nil || err != nil {
 			return logs, err
@@ -229,6 +239,12 @@ func (f *Filter) checkMatches(ctx context.Context, header *types.Header) (logs [
 	return nil, nil
 }
 
+// limitReached returns whether the given number of logs exhausts the result limit
+// of the filter.
+func (f *Filter) limitReached(found int) bool {
+	return f.limit > 0 && found >= f.limit
+}
+
 func includes(addresses []common.Address, a common.Address) bool {
 	for _, addr := range addresses {
 		if addr == a {
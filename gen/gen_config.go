@@ -10,7 +10,9 @@ import (
 	"github.com/genchain/go-genchain/consensus/ethash"
 	"github.com/genchain/go-genchain/core"
 	"github.com/genchain/go-genchain/gen/downloader"
+	"github.com/genchain/go-genchain/gen/filters"
 	"github.com/genchain/go-genchain/gen/gasprice"
+	"github.com/genchain/go-genchain/light"
 )
 
 var _ = (*configMarshaling)(nil)
@@ -20,10 +22,15 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		Genesis                 *core.Genesis `toml:",omitempty"`
 		NetworkId               uint64
 		SyncMode                downloader.SyncMode
-		LightServ               int  `toml:",omitempty"`
-		LightPeers              int  `toml:",omitempty"`
-		SkipBcVersionCheck      bool `toml:"-"`
-		DatabaseHandles         int  `toml:"-"`
+		SyncCheckpoint          *downloader.Checkpoint  `toml:",omitempty"`
+		LightServ               int                     `toml:",omitempty"`
+		LightPeers              int                     `toml:",omitempty"`
+		Checkpoint              *light.SignedCheckpoint `toml:",omitempty"`
+		CheckpointSigners       []common.Address        `toml:",omitempty"`
+		CheckpointThreshold     int                     `toml:",omitempty"`
+		LightFuzzyPoW           uint64                  `toml:",omitempty"`
+		SkipBcVersionCheck      bool                    `toml:"-"`
+		DatabaseHandles         int                     `toml:"-"`
 		DatabaseCache           int
 		Etherbase               common.Address `toml:",omitempty"`
 		MinerThreads            int            `toml:",omitempty"`
@@ -32,6 +39,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		Ethash                  ethash.Config
 		TxPool                  core.TxPoolConfig
 		GPO                     gasprice.Config
+		Filter                  filters.Config
+		TokenTransferIndex      bool `toml:",omitempty"`
+		AddressTxIndex          bool `toml:",omitempty"`
 		EnablePreimageRecording bool
 		DocRoot                 string `toml:"-"`
 	}
@@ -39,8 +49,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.Genesis = c.Genesis
 	enc.NetworkId = c.NetworkId
 	enc.SyncMode = c.SyncMode
+	enc.SyncCheckpoint = c.SyncCheckpoint
 	enc.LightServ = c.LightServ
 	enc.LightPeers = c.LightPeers
+	enc.Checkpoint = c.Checkpoint
+	enc.CheckpointSigners = c.CheckpointSigners
+	enc.CheckpointThreshold = c.CheckpointThreshold
+	enc.LightFuzzyPoW = c.LightFuzzyPoW
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
@@ -51,6 +66,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.Ethash = c.Ethash
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
+	enc.Filter = c.Filter
+	enc.TokenTransferIndex = c.TokenTransferIndex
+	enc.AddressTxIndex = c.AddressTxIndex
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
 	enc.DocRoot = c.DocRoot
 	return &enc, nil
@@ -61,10 +79,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		Genesis                 *core.Genesis `toml:",omitempty"`
 		NetworkId               *uint64
 		SyncMode                *downloader.SyncMode
-		LightServ               *int  `toml:",omitempty"`
-		LightPeers              *int  `toml:",omitempty"`
-		SkipBcVersionCheck      *bool `toml:"-"`
-		DatabaseHandles         *int  `toml:"-"`
+		SyncCheckpoint          *downloader.Checkpoint  `toml:",omitempty"`
+		LightServ               *int                    `toml:",omitempty"`
+		LightPeers              *int                    `toml:",omitempty"`
+		Checkpoint              *light.SignedCheckpoint `toml:",omitempty"`
+		CheckpointSigners       []common.Address        `toml:",omitempty"`
+		CheckpointThreshold     *int                    `toml:",omitempty"`
+		LightFuzzyPoW           *uint64                 `toml:",omitempty"`
+		SkipBcVersionCheck      *bool                   `toml:"-"`
+		DatabaseHandles         *int                    `toml:"-"`
 		DatabaseCache           *int
 		Etherbase               *common.Address `toml:",omitempty"`
 		MinerThreads            *int            `toml:",omitempty"`
@@ -73,6 +96,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		Ethash                  *ethash.Config
 		TxPool                  *core.TxPoolConfig
 		GPO                     *gasprice.Config
+		Filter                  *filters.Config
+		TokenTransferIndex      *bool `toml:",omitempty"`
+		AddressTxIndex          *bool `toml:",omitempty"`
 		EnablePreimageRecording *bool
 		DocRoot                 *string `toml:"-"`
 	}
@@ -89,12 +115,27 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.SyncMode != nil {
 		c.SyncMode = *dec.SyncMode
 	}
+	if dec.SyncCheckpoint != nil {
+		c.SyncCheckpoint = dec.SyncCheckpoint
+	}
 	if dec.LightServ != nil {
 		c.LightServ = *dec.LightServ
 	}
 	if dec.LightPeers != nil {
 		c.LightPeers = *dec.LightPeers
 	}
+	if dec.Checkpoint != nil {
+		c.Checkpoint = dec.Checkpoint
+	}
+	if dec.CheckpointSigners != nil {
+		c.CheckpointSigners = dec.CheckpointSigners
+	}
+	if dec.CheckpointThreshold != nil {
+		c.CheckpointThreshold = *dec.CheckpointThreshold
+	}
+	if dec.LightFuzzyPoW != nil {
+		c.LightFuzzyPoW = *dec.LightFuzzyPoW
+	}
 	if dec.SkipBcVersionCheck != nil {
 		c.SkipBcVersionCheck = *dec.SkipBcVersionCheck
 	}
@@ -125,6 +166,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.GPO != nil {
 		c.GPO = *dec.GPO
 	}
+	if dec.Filter != nil {
+		c.Filter = *dec.Filter
+	}
+	if dec.TokenTransferIndex != nil {
+		c.TokenTransferIndex = *dec.TokenTransferIndex
+	}
+	if dec.AddressTxIndex != nil {
+		c.AddressTxIndex = *dec.AddressTxIndex
+	}
 	if dec.EnablePreimageRecording != nil {
 		c.EnablePreimageRecording = *dec.EnablePreimageRecording
 	}
@@ -38,6 +38,7 @@ import (
 	"github.com/genchain/go-genchain/log"
 	"github.com/genchain/go-genchain/p2p"
 	"github.com/genchain/go-genchain/p2p/discover"
+	"github.com/genchain/go-genchain/p2p/enr"
 	"github.com/genchain/go-genchain/params"
 	"github.com/genchain/go-genchain/rlp"
 )
@@ -49,6 +50,12 @@ const (
 	// txChanSize is the size of channel listening to NewTxsEvent.
 	// The number is referenced from the size of tx pool.
 	txChanSize = 4096
+
+	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
+	chainHeadChanSize = 10
+
+	syncFailurePenalty = 10 // Reputation penalty for stalling or misbehaving during synchronisation, decays over time
+	newBlockReward     = 1  // Reputation reward for propagating a block that got imported
 )
 
 var (
@@ -67,6 +74,7 @@ type ProtocolManager struct {
 	networkId uint64
 
 	fastSync  uint32 // Flag whether fast sync is enabled (gets disabled if we already have blocks)
+	snapSync  bool   // Flag whether fast sync should download the state in ranges
 	acceptTxs uint32 // Flag whether we're considered synchronised (enables transaction processing)
 
 	txpool      txPool
@@ -98,7 +106,7 @@ type ProtocolManager struct {
 
 // NewProtocolManager returns a new Genchain sub protocol manager. The Genchain sub protocol manages peers capable
 // with the Genchain network.
-func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, networkId uint64, mux *event.TypeMux, txpool txPool, engine consensus.Engine, blockchain *core.BlockChain, chaindb ethdb.Database) (*ProtocolManager, error) {
+func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, checkpoint *downloader.Checkpoint, networkId uint64, mux *event.TypeMux, txpool txPool, engine consensus.Engine, blockchain *core.BlockChain, chaindb ethdb.Database) (*ProtocolManager, error) {
 	// Create the protocol manager with the base fields
 	manager := &ProtocolManager{
 		networkId:   networkId,
@@ -113,18 +121,21 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		quitSync:    make(chan struct{}),
 	}
 	// Figure out whether to allow fast sync or not
-	if mode == downloader.FastSync && blockchain.CurrentBlock().NumberU64() > 0 {
+	if (mode == downloader.FastSync || mode == downloader.SnapSync) && blockchain.CurrentBlock().NumberU64() > 0 {
 		log.Warn("Blockchain not empty, fast sync disabled")
 		mode = downloader.FullSync
 	}
-	if mode == downloader.FastSync {
+	if mode == downloader.FastSync || mode == downloader.SnapSync {
 		manager.fastSync = uint32(1)
 	}
-	// Initiate a sub-protocol for every implemented version we can handle
+	manager.snapSync = mode == downloader.SnapSync
+	// Initiate a sub-protocol for every implemented version we can handle,
+	// announcing the local chain in the node record
+	entry := manager.currentENREntry()
 	manager.SubProtocols = make([]p2p.Protocol, 0, len(ProtocolVersions))
 	for i, version := range ProtocolVersions {
 		// Skip protocol version if incompatible with the mode of operation
-		if mode == downloader.FastSync && version < eth63 {
+		if (mode == downloader.FastSync || mode == downloader.SnapSync) && version < eth63 {
 			continue
 		}
 		// Compatible; initialise the sub-protocol
@@ -153,13 +164,15 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 				}
 				return nil
 			},
+			Attributes: []enr.Entry{entry},
+			DialFilter: manager.dialFilter,
 		})
 	}
 	if len(manager.SubProtocols) == 0 {
 		return nil, errIncompatibleConfig
 	}
 	// Construct the different synchronisation mechanisms
-	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.removePeer)
+	manager.downloader = downloader.New(mode, checkpoint, chaindb, manager.eventMux, blockchain, nil, manager.dropSyncPeer)
 
 	validator := func(header *types.Header) error {
 		return engine.VerifyHeader(blockchain, header, true)
@@ -174,9 +187,18 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 			return 0, nil
 		}
 		atomic.StoreUint32(&manager.acceptTxs, 1) // Mark initial sync done on any fetcher import
-		return manager.blockchain.InsertChain(blocks)
+		n, err := manager.blockchain.InsertChain(blocks)
+		if err == nil {
+			// Reward the peers that propagated the imported blocks
+			for _, block := range blocks {
+				if p, ok := block.ReceivedFrom.(*peer); ok {
+					p.Reward(newBlockReward)
+				}
+			}
+		}
+		return n, err
 	}
-	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.removePeer)
+	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.dropInvalidPeer)
 
 	return manager, nil
 }
@@ -200,6 +222,28 @@ func (pm *ProtocolManager) removePeer(id string) {
 	}
 }
 
+// dropInvalidPeer removes a peer that propagated a block failing verification,
+// such as a header with an invalid fuzzy proof-of-work, and bans it so that it
+// can't reconnect right away.
+func (pm *ProtocolManager) dropInvalidPeer(id string) {
+	if peer := pm.peers.Peer(id); peer != nil {
+		peer.Ban("invalid propagated block")
+	}
+	pm.removePeer(id)
+}
+
+// dropSyncPeer removes a peer the downloader gave up on, lowering its reputation.
+func (pm *ProtocolManager) dropSyncPeer(id string) {
+	pm.penalizePeer(id, syncFailurePenalty, "synchronisation failure")
+}
+
+func (pm *ProtocolManager) penalizePeer(id string, points float64, reason string) {
+	if peer := pm.peers.Peer(id); peer != nil {
+		peer.Penalize(points, reason)
+	}
+	pm.removePeer(id)
+}
+
 func (pm *ProtocolManager) Start(maxPeers int) {
 	pm.maxPeers = maxPeers
 
@@ -602,6 +646,36 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			log.Debug("Failed to deliver receipts", "err", err)
 		}
 
+	case p.version >= eth64 && msg.Code == GetAccountRangeMsg:
+		// Decode the range query and serve it from the requested state
+		var query getAccountRangeData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		return p.SendAccountRange(pm.serveRange(query.Root, query.Origin, query.Limit, query.Bytes))
+
+	case p.version >= eth64 && msg.Code == GetStorageRangeMsg:
+		// Decode the range query, look up the storage root and serve the range
+		var query getStorageRangeData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		return p.SendStorageRange(pm.serveRange(pm.storageRoot(query.Root, query.Account), query.Origin, query.Limit, query.Bytes))
+
+	case p.version >= eth64 && (msg.Code == AccountRangeMsg || msg.Code == StorageRangeMsg):
+		// A range of accounts or storage slots arrived to one of our previous requests
+		var data rangeData
+		if err := msg.Decode(&data); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if len(data.Keys) != len(data.Values) {
+			return errResp(ErrDecode, "msg %v: %d keys, %d values", msg, len(data.Keys), len(data.Values))
+		}
+		// Deliver all to the downloader
+		if err := pm.downloader.DeliverRange(p.id, data.Keys, data.Values, data.Proof); err != nil {
+			log.Debug("Failed to deliver state range", "err", err)
+		}
+
 	case msg.Code == NewBlockHashesMsg:
 		var announces newBlockHashesData
 		if err := msg.Decode(&announces); err != nil {
@@ -631,7 +705,8 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		request.Block.ReceivedAt = msg.ReceivedAt
 		request.Block.ReceivedFrom = p
 
-		// Mark the peer as owning the block and schedule it for import
+		// Mark the peer as owning the block and schedule it for import. The peer
+		// is rewarded once the block is imported, a bad block gets it banned.
 		p.MarkBlock(request.Block.Hash())
 		pm.fetcher.Enqueue(p.id, request.Block)
 
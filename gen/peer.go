@@ -287,6 +287,18 @@ func (p *peer) SendReceiptsRLP(receipts []rlp.RawValue) error {
 	return p2p.Send(p.rw, ReceiptsMsg, receipts)
 }
 
+// SendAccountRange sends a range of accounts along with its boundary proofs,
+// corresponding to the range requested.
+func (p *peer) SendAccountRange(data *rangeData) error {
+	return p2p.Send(p.rw, AccountRangeMsg, data)
+}
+
+// SendStorageRange sends a range of storage slots along with its boundary proofs,
+// corresponding to the range requested.
+func (p *peer) SendStorageRange(data *rangeData) error {
+	return p2p.Send(p.rw, StorageRangeMsg, data)
+}
+
 // RequestOneHeader is a wrapper around the header query functions to fetch a
 // single header. It is used solely by the fetcher.
 func (p *peer) RequestOneHeader(hash common.Hash) error {
@@ -322,6 +334,21 @@ func (p *peer) RequestNodeData(hashes []common.Hash) error {
 	return p2p.Send(p.rw, GetNodeDataMsg, hashes)
 }
 
+// RequestAccountRange fetches a range of accounts of the state with the given
+// root from a remote node, starting at origin and ending at most at limit.
+func (p *peer) RequestAccountRange(root, origin, limit common.Hash, bytes uint64) error {
+	p.Log().Debug("Fetching range of accounts", "root", root, "origin", origin, "limit", limit)
+	return p2p.Send(p.rw, GetAccountRangeMsg, &getAccountRangeData{Root: root, Origin: origin, Limit: limit, Bytes: bytes})
+}
+
+// RequestStorageRange fetches a range of storage slots of an account of the state
+// with the given root from a remote node, starting at origin and ending at most
+// at limit.
+func (p *peer) RequestStorageRange(root, account, origin, limit common.Hash, bytes uint64) error {
+	p.Log().Debug("Fetching range of storage slots", "root", root, "account", account, "origin", origin, "limit", limit)
+	return p2p.Send(p.rw, GetStorageRangeMsg, &getStorageRangeData{Root: root, Account: account, Origin: origin, Limit: limit, Bytes: bytes})
+}
+
 // RequestReceipts fetches a batch of transaction receipts from a remote node.
 func (p *peer) RequestReceipts(hashes []common.Hash) error {
 	p.Log().Debug("Fetching batch of receipts", "count", len(hashes))
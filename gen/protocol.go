@@ -32,16 +32,17 @@ import (
 const (
 	eth62 = 62
 	eth63 = 63
+	eth64 = 64
 )
 
 // ProtocolName is the official short name of the protocol used during capability negotiation.
 var ProtocolName = "gen"
 
 // ProtocolVersions are the upported versions of the gen protocol (first is primary).
-var ProtocolVersions = []uint{eth63, eth62}
+var ProtocolVersions = []uint{eth64, eth63, eth62}
 
 // ProtocolLengths are the number of implemented message corresponding to different protocol versions.
-var ProtocolLengths = []uint64{17, 8}
+var ProtocolLengths = []uint64{21, 17, 8}
 
 const ProtocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
 
@@ -62,6 +63,12 @@ const (
 	NodeDataMsg    = 0x0e
 	GetReceiptsMsg = 0x0f
 	ReceiptsMsg    = 0x10
+
+	// Protocol messages belonging to gen/64
+	GetAccountRangeMsg = 0x11
+	AccountRangeMsg    = 0x12
+	GetStorageRangeMsg = 0x13
+	StorageRangeMsg    = 0x14
 )
 
 type errCode int
@@ -181,3 +188,30 @@ type blockBody struct {
 
 // blockBodiesData is the network packet for block content distribution.
 type blockBodiesData []*blockBody
+
+// getAccountRangeData represents a query for a range of the accounts of a state.
+type getAccountRangeData struct {
+	Root   common.Hash // State root of the accounts to retrieve
+	Origin common.Hash // Hash of the first account to retrieve
+	Limit  common.Hash // Hash of the last account to retrieve
+	Bytes  uint64      // Soft limit on the response size
+}
+
+// getStorageRangeData represents a query for a range of the storage slots of an
+// account of a state.
+type getStorageRangeData struct {
+	Root    common.Hash // State root of the account
+	Account common.Hash // Hash of the account to retrieve the storage of
+	Origin  common.Hash // Hash of the first storage slot to retrieve
+	Limit   common.Hash // Hash of the last storage slot to retrieve
+	Bytes   uint64      // Soft limit on the response size
+}
+
+// rangeData is the network packet for account and storage range distribution,
+// containing consecutive trie leaves along with the Merkle proofs of the range
+// origin and the last returned key.
+type rangeData struct {
+	Keys   []common.Hash // Hashes of the accounts or storage slots
+	Values [][]byte      // RLP encoded accounts or storage slots
+	Proof  [][]byte      // Trie nodes proving the boundaries of the range
+}
@@ -0,0 +1,91 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package gen
+
+import (
+	"bytes"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/core/state"
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/rlp"
+	"github.com/genchain/go-genchain/trie"
+)
+
+// serveRange assembles the range of a trie with the given root starting at origin
+// and ending at most at limit, along with the proofs of the origin and the last
+// key returned. The range is empty and comes without proofs if the trie is not
+// available (e.g. the state was pruned already).
+func (pm *ProtocolManager) serveRange(root common.Hash, origin, limit common.Hash, maxBytes uint64) *rangeData {
+	data := new(rangeData)
+	if root == (common.Hash{}) {
+		return data
+	}
+	tr, err := trie.New(root, pm.blockchain.StateCache().TrieDB())
+	if err != nil {
+		return data
+	}
+	if maxBytes > softResponseLimit {
+		maxBytes = softResponseLimit
+	}
+	var size uint64
+	it := trie.NewIterator(tr.NodeIterator(origin[:]))
+	for size < maxBytes && it.Next() {
+		if bytes.Compare(it.Key, limit[:]) > 0 {
+			break
+		}
+		data.Keys = append(data.Keys, common.BytesToHash(it.Key))
+		data.Values = append(data.Values, common.CopyBytes(it.Value))
+		size += uint64(common.HashLength + len(it.Value))
+	}
+	if it.Err != nil {
+		return new(rangeData)
+	}
+	// Prove the boundaries of the range
+	proof := ethdb.NewMemDatabase()
+	if err := tr.Prove(origin[:], 0, proof); err != nil {
+		return new(rangeData)
+	}
+	if len(data.Keys) > 0 {
+		if err := tr.Prove(data.Keys[len(data.Keys)-1][:], 0, proof); err != nil {
+			return new(rangeData)
+		}
+	}
+	for _, key := range proof.Keys() {
+		node, _ := proof.Get(key)
+		data.Proof = append(data.Proof, node)
+	}
+	return data
+}
+
+// storageRoot retrieves the storage root of an account (by address hash) in the
+// state with the given root, or an empty hash if it's not available.
+func (pm *ProtocolManager) storageRoot(root common.Hash, account common.Hash) common.Hash {
+	tr, err := trie.New(root, pm.blockchain.StateCache().TrieDB())
+	if err != nil {
+		return common.Hash{}
+	}
+	blob, err := tr.TryGet(account[:])
+	if err != nil || blob == nil {
+		return common.Hash{}
+	}
+	var acc state.Account
+	if err := rlp.DecodeBytes(blob, &acc); err != nil {
+		return common.Hash{}
+	}
+	return acc.Root
+}
@@ -0,0 +1,146 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package gen
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/core/rawdb"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/rpc"
+)
+
+// indexEntry is a single result of a sectioned index query, positioned within
+// the chain by its block and its index within that block.
+type indexEntry interface {
+	position() (number uint64, hash common.Hash, index uint64)
+}
+
+// indexCursor is the position of an entry within the chain from which a paged
+// index query resumes, inclusive.
+type indexCursor struct {
+	number uint64
+	index  uint64
+}
+
+// sectionIndex queries an index of the canonical chain that a chain indexer
+// maintains per address in sections. The blocks not yet covered by the indexed
+// sections are scanned directly, as long as the index doesn't lag behind them
+// too far.
+type sectionIndex struct {
+	name     string        // Name of the index to report errors with
+	size     uint64        // Number of blocks in a single index section
+	maxScan  uint64        // Maximum number of unindexed blocks to scan directly
+	pageSize int           // Maximum number of entries returned in a page
+	sections func() uint64 // Number of sections indexed so far
+
+	chain *core.BlockChain
+	db    ethdb.Database
+}
+
+// indexedSections returns the number of valid sections of a chain indexer.
+func indexedSections(indexer *core.ChainIndexer) func() uint64 {
+	return func() uint64 {
+		sections, _, _ := indexer.Sections()
+		return sections
+	}
+}
+
+// query gathers a page of the entries within the given block range, resuming
+// from the cursor if set. The entries indexed in a section are retrieved by
+// read, while scan extracts those of a single block not yet indexed. Entries
+// of blocks reorged out since their section was indexed are dropped. If the
+// page overflows, the cursor of the next one is returned too.
+func (idx *sectionIndex) query(ctx context.Context, fromBlock, toBlock *rpc.BlockNumber, cursor *indexCursor, read func(section uint64) []indexEntry, scan func(header *types.Header) []indexEntry) ([]indexEntry, *indexCursor, error) {
+	// Resolve the queried block range
+	head := idx.chain.CurrentBlock().NumberU64()
+
+	from, to := uint64(0), head
+	if fromBlock != nil && *fromBlock >= 0 {
+		from = uint64(*fromBlock)
+	}
+	if toBlock != nil && *toBlock >= 0 && uint64(*toBlock) < head {
+		to = uint64(*toBlock)
+	}
+	if cursor != nil {
+		if cursor.number < from || cursor.number > to {
+			return nil, nil, fmt.Errorf("cursor block %d outside of queried range [%d, %d]", cursor.number, from, to)
+		}
+		from = cursor.number
+	}
+	// Refuse the query if the index lags too far behind to scan the rest
+	sections := idx.sections()
+
+	start := sections * idx.size
+	if start < from {
+		start = from
+	}
+	if to >= start && to-start > idx.maxScan {
+		return nil, nil, fmt.Errorf("%s index not yet available above block %d", idx.name, sections*idx.size)
+	}
+	// Gather the matching entries until the page overflows
+	var (
+		page []indexEntry
+		next *indexCursor
+	)
+	add := func(entry indexEntry) bool {
+		number, hash, index := entry.position()
+		if number < from || number > to {
+			return true
+		}
+		if cursor != nil && number == cursor.number && index < cursor.index {
+			return true
+		}
+		// Skip any entries of blocks reorged out since the section was indexed
+		if rawdb.ReadCanonicalHash(idx.db, number) != hash {
+			return true
+		}
+		if len(page) == idx.pageSize {
+			next = &indexCursor{number: number, index: index}
+			return false
+		}
+		page = append(page, entry)
+		return true
+	}
+	for section := from / idx.size; section < sections && section*idx.size <= to; section++ {
+		for _, entry := range read(section) {
+			if !add(entry) {
+				return page, next, nil
+			}
+		}
+	}
+	// Scan the blocks not yet covered by the index directly
+	for number := start; number <= to; number++ {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		header := idx.chain.GetHeaderByNumber(number)
+		if header == nil {
+			break
+		}
+		for _, entry := range scan(header) {
+			if !add(entry) {
+				return page, next, nil
+			}
+		}
+	}
+	return page, nil, nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package gen
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/genchain/go-genchain/consensus/ethash"
+	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/core/vm"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/params"
+	"github.com/genchain/go-genchain/rpc"
+)
+
+var (
+	indexTestKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	indexTestSender = crypto.PubkeyToAddress(indexTestKey.PublicKey)
+)
+
+const (
+	indexTestBlocks   = 12 // Number of blocks in the test chain
+	indexTestTxs      = 2  // Number of token transfers in every block
+	indexTestSize     = 4  // Section size of the test indexes
+	indexTestSections = 2  // Number of sections indexed, the rest is scanned
+)
+
+// indexSection runs an index backend over a single section of headers.
+func indexSection(t *testing.T, backend core.ChainIndexerBackend, section uint64, headers []*types.Header) {
+	if err := backend.Reset(section, headers[0].ParentHash); err != nil {
+		t.Fatalf("failed to reset section %d: %v", section, err)
+	}
+	for _, header := range headers {
+		backend.Process(header)
+	}
+	if err := backend.Commit(); err != nil {
+		t.Fatalf("failed to commit section %d: %v", section, err)
+	}
+}
+
+// newIndexTestChain creates a chain whose blocks all contain transactions of
+// the test sender calling a token contract, each emitting a transfer from the
+// caller to transferBob. The first sections of the chain are indexed by the
+// given backend, the rest is left to be scanned.
+func newIndexTestChain(t *testing.T, backend func(*Genchain) core.ChainIndexerBackend) *Genchain {
+	// Transfer 1000 tokens from the caller to transferBob, emitting the event
+	code := []byte{0x61, 0x03, 0xe8, 0x60, 0x00, 0x52, 0x73} // PUSH2 1000, PUSH1 0, MSTORE, PUSH20
+	code = append(code, transferBob.Bytes()...)
+	code = append(code, 0x33, 0x7f) // CALLER, PUSH32
+	code = append(code, transferTopic.Bytes()...)
+	code = append(code, 0x60, 0x20, 0x60, 0x00, 0xa3, 0x00) // PUSH1 32, PUSH1 0, LOG3, STOP
+
+	var (
+		db      = ethdb.NewMemDatabase()
+		config  = params.TestChainConfig
+		genesis = (&core.Genesis{
+			Config: config,
+			Alloc: core.GenesisAlloc{
+				indexTestSender: {Balance: big.NewInt(1000000000000000000)},
+				transferToken:   {Code: code, Balance: new(big.Int)},
+			},
+		}).MustCommit(db)
+	)
+	blocks, _ := core.GenerateChain(config, genesis, ethash.NewFaker(), db, indexTestBlocks, func(i int, block *core.BlockGen) {
+		for j := 0; j < indexTestTxs; j++ {
+			tx := types.NewTransaction(block.TxNonce(indexTestSender), transferToken, new(big.Int), 100000, big.NewInt(1), nil)
+			tx, _ = types.SignTx(tx, types.HomesteadSigner{}, indexTestKey)
+			block.AddTx(tx)
+		}
+	})
+	chain, err := core.NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import block %d: %v", n, err)
+	}
+	gen := &Genchain{blockchain: chain, chainDb: db, chainConfig: config}
+
+	indexer := backend(gen)
+	for section := uint64(0); section < indexTestSections; section++ {
+		var headers []*types.Header
+		for number := section * indexTestSize; number < (section+1)*indexTestSize; number++ {
+			headers = append(headers, chain.GetHeaderByNumber(number))
+		}
+		indexSection(t, indexer, section, headers)
+	}
+	return gen
+}
+
+// indexTestQuery is a paged index API query, returning the positions of the
+// results and the cursor of the next page.
+type indexTestQuery func(from, to *rpc.BlockNumber, cursor *indexCursor) ([]indexCursor, *indexCursor, error)
+
+// testPagedIndexAPI checks the paging of an index API over the test chain: page
+// overflows, resuming from cursors, the rejection of cursors out of the queried
+// range, the refusal to scan too far past the indexed sections and the direct
+// scan of the blocks not yet indexed.
+func testPagedIndexAPI(t *testing.T, index *sectionIndex, query indexTestQuery) {
+	index.size, index.maxScan, index.pageSize = indexTestSize, indexTestSize, 3
+	index.sections = func() uint64 { return indexTestSections }
+
+	blockNumber := func(n int64) *rpc.BlockNumber {
+		number := rpc.BlockNumber(n)
+		return &number
+	}
+	// Page through the whole chain, crossing from the index into the direct scan
+	var (
+		have   []indexCursor
+		cursor *indexCursor
+	)
+	for pages := 0; ; pages++ {
+		if pages > indexTestBlocks*indexTestTxs {
+			t.Fatalf("paging doesn't terminate")
+		}
+		results, next, err := query(nil, nil, cursor)
+		if err != nil {
+			t.Fatalf("page %d: query failed: %v", pages, err)
+		}
+		if len(results) > index.pageSize {
+			t.Fatalf("page %d: page overflow: have %d results, want at most %d", pages, len(results), index.pageSize)
+		}
+		if next != nil && len(results) != index.pageSize {
+			t.Fatalf("page %d: partial page continued: have %d results", pages, len(results))
+		}
+		have = append(have, results...)
+		if cursor = next; cursor == nil {
+			break
+		}
+	}
+	var want []indexCursor
+	for number := uint64(1); number <= indexTestBlocks; number++ {
+		for i := uint64(0); i < indexTestTxs; i++ {
+			want = append(want, indexCursor{number: number, index: i})
+		}
+	}
+	if len(have) != len(want) {
+		t.Fatalf("result count mismatch: have %d, want %d", len(have), len(want))
+	}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Errorf("result %d: position mismatch: have %+v, want %+v", i, have[i], want[i])
+		}
+	}
+	// Resume from a cursor in the middle of a block
+	results, next, err := query(nil, nil, &indexCursor{number: 2, index: 1})
+	if err != nil {
+		t.Fatalf("failed to resume query: %v", err)
+	}
+	if len(results) != 3 || results[0] != (indexCursor{number: 2, index: 1}) {
+		t.Errorf("resumed page mismatch: have %+v", results)
+	}
+	if next == nil || *next != (indexCursor{number: 4, index: 0}) {
+		t.Errorf("resumed page next cursor mismatch: have %+v, want {4 0}", next)
+	}
+	// Reject cursors outside of the queried range
+	if _, _, err := query(blockNumber(5), blockNumber(8), &indexCursor{number: 2}); err == nil {
+		t.Errorf("cursor below the queried range accepted")
+	}
+	if _, _, err := query(blockNumber(5), blockNumber(8), &indexCursor{number: 9}); err == nil {
+		t.Errorf("cursor above the queried range accepted")
+	}
+	// Refuse to scan too far past the indexed sections, unless the queried range
+	// itself is short enough
+	index.sections = func() uint64 { return 0 }
+	if _, _, err := query(nil, nil, nil); err == nil {
+		t.Errorf("query scanning %d unindexed blocks accepted", indexTestBlocks)
+	}
+	results, _, err = query(blockNumber(indexTestBlocks-indexTestSize), nil, nil)
+	if err != nil {
+		t.Fatalf("short unindexed query failed: %v", err)
+	}
+	if len(results) != 3 || results[0] != (indexCursor{number: indexTestBlocks - indexTestSize}) {
+		t.Errorf("scanned page mismatch: have %+v", results)
+	}
+}
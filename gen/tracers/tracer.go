@@ -296,8 +296,9 @@ type Tracer struct {
 	depthValue *uint   // Swappable depth value wrapped by a log accessor
 	errorValue *string // Swappable error value wrapped by a log accessor
 
-	ctx map[string]interface{} // Transaction context gathered throughout execution
-	err error                  // Error, if one has occurred
+	ctx         map[string]interface{}                    // Transaction context gathered throughout execution
+	precompiles map[common.Address]vm.PrecompiledContract // Precompiled contracts active in the traced block
+	err         error                                     // Error, if one has occurred
 
 	interrupt uint32 // Atomic flag to signal execution interruption
 	reason    error  // Textual reason for the interruption
@@ -366,7 +367,11 @@ func New(code string) (*Tracer, error) {
 		return 1
 	})
 	tracer.vm.PushGlobalGoFunction("isPrecompiled", func(ctx *duktape.Context) int {
-		_, ok := vm.PrecompiledContractsByzantium[common.BytesToAddress(popSlice(ctx))]
+		precompiles := tracer.precompiles
+		if precompiles == nil {
+			precompiles = vm.PrecompiledContractsByzantium
+		}
+		_, ok := precompiles[common.BytesToAddress(popSlice(ctx))]
 		ctx.PushBoolean(ok)
 		return 1
 	})
@@ -515,6 +520,7 @@ func (jst *Tracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost
 		// Initialize the context if it wasn't done yet
 		if !jst.inited {
 			jst.ctx["block"] = env.BlockNumber.Uint64()
+			jst.precompiles = vm.ActivePrecompiledContracts(env.ChainConfig(), env.BlockNumber)
 			jst.inited = true
 		}
 		// If tracing was interrupted, set the error and stop
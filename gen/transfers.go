@@ -0,0 +1,219 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package gen
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/core/rawdb"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/log"
+)
+
+const (
+	// transferSectionSize is the number of blocks in a single token transfer
+	// index section.
+	transferSectionSize = 4096
+
+	// transferConfirms is the number of confirmation blocks before a token
+	// transfer section is considered final and gets indexed.
+	transferConfirms = 256
+
+	// transferThrottling is the time to wait between processing two consecutive
+	// index sections. It's useful during chain upgrades to prevent disk overload.
+	transferThrottling = 100 * time.Millisecond
+)
+
+// transferTopic is the topic of the Transfer event shared by ERC20 and ERC721
+// tokens, Transfer(address indexed from, address indexed to, uint256 value).
+var transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// transferPurgeLoop feeds the logs dropped from the canonical chain by reorgs
+// into the token transfer index until the Genchain service is stopped.
+func (gen *Genchain) transferPurgeLoop() {
+	removed := make(chan core.RemovedLogsEvent, 16)
+	sub := gen.blockchain.SubscribeRemovedLogsEvent(removed)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-removed:
+			gen.transfers.Purge(ev.Logs)
+		case <-sub.Err():
+			return
+		case <-gen.shutdownChan:
+			return
+		}
+	}
+}
+
+// parseTransfer decodes a token transfer from a log, returning nil if the log is
+// not a Transfer event. ERC20 transfers carry the amount in the log data, while
+// ERC721 transfers index the token id as a third topic.
+func parseTransfer(l *types.Log) *rawdb.TokenTransferEntry {
+	if len(l.Topics) < 3 || l.Topics[0] != transferTopic {
+		return nil
+	}
+	transfer := &rawdb.TokenTransferEntry{
+		BlockHash:   l.BlockHash,
+		BlockNumber: l.BlockNumber,
+		TxHash:      l.TxHash,
+		LogIndex:    uint64(l.Index),
+		Token:       l.Address,
+		From:        common.BytesToAddress(l.Topics[1].Bytes()),
+		To:          common.BytesToAddress(l.Topics[2].Bytes()),
+	}
+	switch {
+	case len(l.Topics) == 3 && len(l.Data) == 32:
+		transfer.Value = new(big.Int).SetBytes(l.Data)
+	case len(l.Topics) == 4 && len(l.Data) == 0:
+		transfer.Value = new(big.Int).SetBytes(l.Topics[3].Bytes())
+		transfer.NonFungible = true
+	default:
+		return nil
+	}
+	return transfer
+}
+
+// blockTransfers returns the token transfers of a block, read from its receipts.
+func blockTransfers(db ethdb.Database, header *types.Header) []*rawdb.TokenTransferEntry {
+	if !types.BloomLookup(header.Bloom, transferTopic) {
+		return nil
+	}
+	var transfers []*rawdb.TokenTransferEntry
+	for _, receipt := range rawdb.ReadReceipts(db, header.Hash(), header.Number.Uint64()) {
+		for _, l := range receipt.Logs {
+			if transfer := parseTransfer(l); transfer != nil {
+				transfers = append(transfers, transfer)
+			}
+		}
+	}
+	return transfers
+}
+
+// TransferIndexer implements a core.ChainIndexerBackend, building up an index of
+// the ERC20 and ERC721 token transfers of every address on the canonical chain.
+//
+// The index is stored in sections, a separate list of transfers being maintained
+// for every address active in a section, together with a list of those addresses
+// to allow dropping the section if it's ever reprocessed after a reorg.
+type TransferIndexer struct {
+	size uint64         // section size to generate the transfer index for
+	db   ethdb.Database // database instance to write index data and metadata into
+	lock sync.Mutex     // lock protecting the index data against concurrent purges
+
+	section   uint64                                        // Section is the section number being processed currently
+	transfers map[common.Address][]rawdb.TokenTransferEntry // Transfers gathered in the current section
+}
+
+// NewTransferIndexer returns a chain indexer that maintains the token transfer
+// index of the canonical chain, along with its backend to purge reorged logs.
+func NewTransferIndexer(db ethdb.Database, size uint64) (*core.ChainIndexer, *TransferIndexer) {
+	backend := &TransferIndexer{
+		db:   db,
+		size: size,
+	}
+	table := ethdb.NewTable(db, string(rawdb.TokenTransferIndexPrefix))
+
+	return core.NewChainIndexer(db, table, backend, size, transferConfirms, transferThrottling, "transfers"), backend
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new token transfer index
+// section and dropping any stale data from a previous run over it.
+func (t *TransferIndexer) Reset(section uint64, lastSectionHead common.Hash) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, address := range rawdb.ReadTokenTransferSection(t.db, section) {
+		rawdb.DeleteTokenTransfers(t.db, address, section)
+	}
+	rawdb.DeleteTokenTransferSection(t.db, section)
+
+	t.section, t.transfers = section, make(map[common.Address][]rawdb.TokenTransferEntry)
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, adding the token transfers of a
+// new header's receipts into the index.
+func (t *TransferIndexer) Process(header *types.Header) {
+	for _, transfer := range blockTransfers(t.db, header) {
+		t.transfers[transfer.From] = append(t.transfers[transfer.From], *transfer)
+		if transfer.To != transfer.From {
+			t.transfers[transfer.To] = append(t.transfers[transfer.To], *transfer)
+		}
+	}
+}
+
+// Commit implements core.ChainIndexerBackend, finalizing the token transfer
+// section and writing it out into the database.
+func (t *TransferIndexer) Commit() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	batch := t.db.NewBatch()
+
+	addresses := make([]common.Address, 0, len(t.transfers))
+	for address, transfers := range t.transfers {
+		rawdb.WriteTokenTransfers(batch, address, t.section, transfers)
+		addresses = append(addresses, address)
+	}
+	rawdb.WriteTokenTransferSection(batch, t.section, addresses)
+
+	return batch.Write()
+}
+
+// Purge removes the token transfers contained in the given logs from the index.
+// It is meant to be fed the logs dropped from the canonical chain by a reorg, so
+// that the index never references transfers of a side chain, even if the indexer
+// hasn't yet reprocessed the affected sections.
+func (t *TransferIndexer) Purge(logs []*types.Log) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, l := range logs {
+		transfer := parseTransfer(l)
+		if transfer == nil {
+			continue
+		}
+		section := transfer.BlockNumber / t.size
+		for _, address := range []common.Address{transfer.From, transfer.To} {
+			transfers := rawdb.ReadTokenTransfers(t.db, address, section)
+
+			kept := transfers[:0]
+			for _, entry := range transfers {
+				if entry.BlockHash != transfer.BlockHash || entry.LogIndex != transfer.LogIndex {
+					kept = append(kept, entry)
+				}
+			}
+			if len(kept) == len(transfers) {
+				continue
+			}
+			log.Debug("Purged reorged token transfer", "address", address, "number", transfer.BlockNumber, "hash", transfer.BlockHash)
+			if len(kept) == 0 {
+				rawdb.DeleteTokenTransfers(t.db, address, section)
+			} else {
+				rawdb.WriteTokenTransfers(t.db, address, section, kept)
+			}
+		}
+	}
+}
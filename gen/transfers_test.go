@@ -0,0 +1,182 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package gen
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/hexutil"
+	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/core/rawdb"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/gen/filters"
+	"github.com/genchain/go-genchain/rpc"
+)
+
+var (
+	transferToken  = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	transferAlice  = common.HexToAddress("0x0000000000000000000000000000000000000a11")
+	transferBob    = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	transferCarol  = common.HexToAddress("0x00000000000000000000000000000000000ca201")
+	transferAmount = common.BigToHash(big.NewInt(1000))
+)
+
+// erc20Transfer creates an ERC20 Transfer event log.
+func erc20Transfer(from, to common.Address) *types.Log {
+	return &types.Log{
+		Address: transferToken,
+		Topics:  []common.Hash{transferTopic, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+		Data:    transferAmount.Bytes(),
+	}
+}
+
+// erc721Transfer creates an ERC721 Transfer event log.
+func erc721Transfer(from, to common.Address, id int64) *types.Log {
+	return &types.Log{
+		Address: transferToken,
+		Topics:  []common.Hash{transferTopic, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes()), common.BigToHash(big.NewInt(id))},
+	}
+}
+
+// writeTransferBlock stores a header with a single receipt containing the given
+// logs into the database, returning the header.
+func writeTransferBlock(db ethdb.Database, number uint64, logs ...*types.Log) *types.Header {
+	receipt := types.NewReceipt(nil, false, 0)
+	receipt.Logs = logs
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+	header := &types.Header{Number: new(big.Int).SetUint64(number), Bloom: receipt.Bloom}
+	for i, l := range logs {
+		l.BlockNumber, l.BlockHash, l.Index = number, header.Hash(), uint(i)
+	}
+	rawdb.WriteHeader(db, header)
+	rawdb.WriteReceipts(db, header.Hash(), number, types.Receipts{receipt})
+	return header
+}
+
+// Tests that token transfer logs are correctly recognised and decoded.
+func TestParseTransfer(t *testing.T) {
+	if transfer := parseTransfer(erc20Transfer(transferAlice, transferBob)); transfer == nil {
+		t.Errorf("ERC20 transfer not recognised")
+	} else if transfer.From != transferAlice || transfer.To != transferBob || transfer.Value.Int64() != 1000 || transfer.NonFungible {
+		t.Errorf("ERC20 transfer mismatch: have %+v", transfer)
+	}
+	if transfer := parseTransfer(erc721Transfer(transferAlice, transferBob, 7)); transfer == nil {
+		t.Errorf("ERC721 transfer not recognised")
+	} else if transfer.From != transferAlice || transfer.To != transferBob || transfer.Value.Int64() != 7 || !transfer.NonFungible {
+		t.Errorf("ERC721 transfer mismatch: have %+v", transfer)
+	}
+	// Approval(address,address,uint256) shares the layout but not the topic
+	approval := erc20Transfer(transferAlice, transferBob)
+	approval.Topics[0] = common.HexToHash("0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925")
+	if transfer := parseTransfer(approval); transfer != nil {
+		t.Errorf("non-transfer log recognised: %+v", transfer)
+	}
+	// Malformed transfers must not be indexed
+	malformed := erc20Transfer(transferAlice, transferBob)
+	malformed.Data = nil
+	if transfer := parseTransfer(malformed); transfer != nil {
+		t.Errorf("malformed transfer recognised: %+v", transfer)
+	}
+}
+
+// Tests that the transfer indexer indexes transfers under both participants,
+// drops stale data on reprocessing a section and purges reorged transfers.
+func TestTransferIndexer(t *testing.T) {
+	var (
+		db      = ethdb.NewMemDatabase()
+		backend = &TransferIndexer{db: db, size: 4}
+		headers = []*types.Header{
+			writeTransferBlock(db, 4, erc20Transfer(transferAlice, transferBob)),
+			writeTransferBlock(db, 5),
+			writeTransferBlock(db, 6, erc721Transfer(transferBob, transferCarol, 1), erc20Transfer(transferAlice, transferAlice)),
+			writeTransferBlock(db, 7, erc20Transfer(transferCarol, transferAlice)),
+		}
+	)
+	indexSection(t, backend, 1, headers)
+
+	check := func(address common.Address, want int) {
+		if have := len(rawdb.ReadTokenTransfers(db, address, 1)); have != want {
+			t.Errorf("address %x: transfer count mismatch: have %d, want %d", address, have, want)
+		}
+	}
+	check(transferAlice, 3)
+	check(transferBob, 2)
+	check(transferCarol, 2)
+
+	// Reprocess the section after a reorg replaced the last block
+	headers[3] = writeTransferBlock(db, 7)
+	indexSection(t, backend, 1, headers)
+
+	check(transferAlice, 2)
+	check(transferBob, 2)
+	check(transferCarol, 1)
+
+	// Purge the transfers of a reorged block and ensure others are untouched
+	logs := rawdb.ReadReceipts(db, headers[2].Hash(), 6)[0].Logs
+	backend.Purge(logs)
+
+	check(transferAlice, 1)
+	check(transferBob, 1)
+	check(transferCarol, 0)
+}
+
+// Tests the paging of token transfer queries over the indexed and the not yet
+// indexed parts of the chain, and the filtering of the transfers by token.
+func TestGetTokenTransfers(t *testing.T) {
+	gen := newIndexTestChain(t, func(gen *Genchain) core.ChainIndexerBackend {
+		gen.transfers = &TransferIndexer{db: gen.chainDb, size: indexTestSize}
+		return gen.transfers
+	})
+	api := NewPublicTransferAPI(gen)
+
+	query := func(token *common.Address) indexTestQuery {
+		return func(from, to *rpc.BlockNumber, cursor *indexCursor) ([]indexCursor, *indexCursor, error) {
+			var resume *filters.LogCursor
+			if cursor != nil {
+				resume = &filters.LogCursor{BlockNumber: hexutil.Uint64(cursor.number), LogIndex: hexutil.Uint(cursor.index)}
+			}
+			page, err := api.GetTokenTransfers(context.Background(), indexTestSender, token, from, to, resume)
+			if err != nil {
+				return nil, nil, err
+			}
+			results := make([]indexCursor, len(page.Transfers))
+			for i, transfer := range page.Transfers {
+				if transfer.Token != transferToken || transfer.From != indexTestSender || transfer.To != transferBob || transfer.Value.ToInt().Int64() != 1000 {
+					t.Errorf("transfer mismatch: have %+v", transfer)
+				}
+				results[i] = indexCursor{number: uint64(transfer.BlockNumber), index: uint64(transfer.LogIndex)}
+			}
+			var next *indexCursor
+			if page.Next != nil {
+				next = &indexCursor{number: uint64(page.Next.BlockNumber), index: uint64(page.Next.LogIndex)}
+			}
+			return results, next, nil
+		}
+	}
+	testPagedIndexAPI(t, api.index, query(&transferToken))
+
+	// Transfers of other tokens must be filtered out, both indexed and scanned
+	api.index.sections = func() uint64 { return indexTestSections }
+	if results, next, err := query(&transferCarol)(nil, nil, nil); err != nil || len(results) != 0 || next != nil {
+		t.Errorf("transfers of other token returned: %+v, next %+v (%v)", results, next, err)
+	}
+}
@@ -27,6 +27,7 @@ import (
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/genchain/go-genchain/accounts"
+	"github.com/genchain/go-genchain/accounts/abi"
 	"github.com/genchain/go-genchain/accounts/keystore"
 	"github.com/genchain/go-genchain/common"
 	"github.com/genchain/go-genchain/common/hexutil"
@@ -42,6 +43,7 @@ import (
 	"github.com/genchain/go-genchain/params"
 	"github.com/genchain/go-genchain/rlp"
 	"github.com/genchain/go-genchain/rpc"
+	"github.com/genchain/go-genchain/signer/typeddata"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/util"
 )
@@ -441,6 +443,36 @@ func (s *PrivateAccountAPI) Sign(ctx context.Context, data hexutil.Bytes, addr c
 	return signature, nil
 }
 
+// SignTypedData calculates an Genchain ECDSA signature over EIP-712 typed data:
+// keccak256("\x19\x01" + domainSeparator + hashStruct(message))
+//
+// Typed data bound to another chain is rejected. The V value of the signature
+// will be 27 or 28, as with Sign.
+//
+// The key used to calculate the signature is decrypted with the given password.
+func (s *PrivateAccountAPI) SignTypedData(ctx context.Context, typedData typeddata.TypedData, addr common.Address, passwd string) (hexutil.Bytes, error) {
+	if id, chainId := typedData.Domain.ChainId, s.b.ChainConfig().ChainId; id != nil && (*big.Int)(id).Cmp(chainId) != 0 {
+		return nil, fmt.Errorf("typed data chain id %v does not match chain id %v", (*big.Int)(id), chainId)
+	}
+	sighash, _, err := typeddata.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, err
+	}
+	// Look up the wallet containing the requested signer
+	account := accounts.Account{Address: addr}
+
+	wallet, err := s.b.AccountManager().Find(account)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := wallet.SignHashWithPassphrase(account, passwd, sighash)
+	if err != nil {
+		return nil, err
+	}
+	signature[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	return signature, nil
+}
+
 // EcRecover returns the address for the account that was used to create the signature.
 // Note, this function is compatible with gen_sign and personal_sign. As such it recovers
 // the address of:
@@ -613,12 +645,14 @@ type CallArgs struct {
 	Data     hexutil.Bytes   `json:"data"`
 }
 
-func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config, timeout time.Duration) ([]byte, uint64, bool, error) {
+// doCall executes the call, returning the error the execution failed with, if
+// any, next to the error preventing the execution.
+func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config, timeout time.Duration) ([]byte, uint64, error, error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
 	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
 	if state == nil || err != nil {
-		return nil, 0, false, err
+		return nil, 0, nil, err
 	}
 	// Set sender address or use a default if none specified
 	addr := args.From
@@ -656,7 +690,7 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	// Get a new instance of the EVM.
 	evm, vmError, err := s.b.GetEVM(ctx, msg, state, header, vmCfg)
 	if err != nil {
-		return nil, 0, false, err
+		return nil, 0, nil, err
 	}
 	// Wait for the context to be done and cancel the evm. Even if the
 	// EVM has finished, cancelling may be done (repeatedly)
@@ -668,18 +702,55 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	// Setup the gas pool (also for unmetered requests)
 	// and apply the message.
 	gp := new(core.GasPool).AddGas(math.MaxUint64)
-	res, gas, failed, err := core.ApplyMessage(evm, msg, gp)
+	res, gas, failure, err := core.ApplyCall(evm, msg, gp)
 	if err := vmError(); err != nil {
-		return nil, 0, false, err
+		return nil, 0, nil, err
+	}
+	return res, gas, failure, err
+}
+
+// revertError is returned by calls that reverted, carrying the revert data of
+// the contract so that clients can decode the reason.
+type revertError struct {
+	reason string // Revert reason decoded from the data, if any
+	data   []byte // Data returned by the reverted call
+}
+
+func newRevertError(data []byte) *revertError {
+	reason, _ := abi.UnpackRevert(data)
+	return &revertError{reason: reason, data: data}
+}
+
+func (e *revertError) Error() string {
+	if e.reason == "" {
+		return "execution reverted"
 	}
-	return res, gas, failed, err
+	return "execution reverted: " + e.reason
 }
 
+// ErrorCode returns the JSON-RPC error code of reverted calls.
+func (e *revertError) ErrorCode() int { return 3 }
+
+// ErrorData returns the hex encoded revert data to the client.
+func (e *revertError) ErrorData() interface{} { return hexutil.Encode(e.data) }
+
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
+// If the execution reverts, the returned error carries the revert data, with
+// error code 3. Other execution failures, like running out of gas, return the
+// error of the EVM.
 func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	result, _, _, err := s.doCall(ctx, args, blockNr, vm.Config{}, 5*time.Second)
-	return (hexutil.Bytes)(result), err
+	result, _, failure, err := s.doCall(ctx, args, blockNr, vm.Config{}, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if failure == vm.ErrExecutionReverted {
+		return nil, newRevertError(result)
+	}
+	if failure != nil {
+		return nil, failure
+	}
+	return (hexutil.Bytes)(result), nil
 }
 
 // EstimateGas returns an estimate of the amount of gas needed to execute the
@@ -707,8 +778,8 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	executable := func(gas uint64) bool {
 		args.Gas = hexutil.Uint64(gas)
 
-		_, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{}, 0)
-		if err != nil || failed {
+		_, _, failure, err := s.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{}, 0)
+		if err != nil || failure != nil {
 			return false
 		}
 		return true
@@ -1041,9 +1112,9 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 
 // GetTransactionReceipt returns the transaction receipt for the given transaction hash.
 func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
-	tx, blockHash, blockNumber, index := rawdb.ReadTransaction(s.b.ChainDb(), hash)
+	tx, blockHash, blockNumber, index, err := s.b.GetTransaction(ctx, hash)
 	if tx == nil {
-		return nil, nil
+		return nil, err
 	}
 	receipts, err := s.b.GetReceipts(ctx, blockHash)
 	if err != nil {
@@ -23,7 +23,9 @@ var Modules = map[string]string{
 	"clique":     Clique_JS,
 	"debug":      Debug_JS,
 	"gen":        Gen_JS,
+	"les":        LES_JS,
 	"miner":      Miner_JS,
+	"multisig":   Multisig_JS,
 	"net":        Net_JS,
 	"personal":   Personal_JS,
 	"rpc":        RPC_JS,
@@ -123,11 +125,22 @@ web3._extend({
 			call: 'admin_removePeer',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'banPeer',
+			call: 'admin_banPeer',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'unban',
+			call: 'admin_unban',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'exportChain',
 			call: 'admin_exportChain',
-			params: 1,
-			inputFormatter: [null]
+			params: 2,
+			inputFormatter: [null, null]
 		}),
 		new web3._extend.Method({
 			name: 'importChain',
@@ -173,6 +186,10 @@ web3._extend({
 			name: 'datadir',
 			getter: 'admin_datadir'
 		}),
+		new web3._extend.Property({
+			name: 'peerScores',
+			getter: 'admin_peerScores'
+		}),
 	]
 });
 `
@@ -368,6 +385,26 @@ web3._extend({
 			call: 'debug_getBadBlocks',
 			params: 0,
 		}),
+		new web3._extend.Method({
+			name: 'startEvmProfile',
+			call: 'debug_startEvmProfile',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'stopEvmProfile',
+			call: 'debug_stopEvmProfile',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'resetEvmProfile',
+			call: 'debug_resetEvmProfile',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'evmProfile',
+			call: 'debug_evmProfile',
+			params: 0
+		}),
 		new web3._extend.Method({
 			name: 'storageRangeAt',
 			call: 'debug_storageRangeAt',
@@ -431,6 +468,24 @@ web3._extend({
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.utils.toHex]
 		}),
+		new web3._extend.Method({
+			name: 'getLogsPaged',
+			call: 'gen_getLogsPaged',
+			params: 3,
+			inputFormatter: [null, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'getTokenTransfers',
+			call: 'gen_getTokenTransfers',
+			params: 5,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'getTransactionsByAddress',
+			call: 'gen_getTransactionsByAddress',
+			params: 4,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -489,6 +544,56 @@ web3._extend({
 });
 `
 
+const Multisig_JS = `
+web3._extend({
+	property: 'multisig',
+	methods: [
+		new web3._extend.Method({
+			name: 'propose',
+			call: 'multisig_propose',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'import',
+			call: 'multisig_import',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'list',
+			call: 'multisig_list',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'get',
+			call: 'multisig_get',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'approve',
+			call: 'multisig_approve',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'addSignature',
+			call: 'multisig_addSignature',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'submit',
+			call: 'multisig_submit',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'discard',
+			call: 'multisig_discard',
+			params: 1
+		}),
+	]
+});
+`
+
 const Net_JS = `
 web3._extend({
 	property: 'net',
@@ -517,6 +622,12 @@ web3._extend({
 			params: 3,
 			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter, null]
 		}),
+		new web3._extend.Method({
+			name: 'signTypedData',
+			call: 'personal_signTypedData',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter, null]
+		}),
 		new web3._extend.Method({
 			name: 'ecRecover',
 			call: 'personal_ecRecover',
@@ -631,3 +742,38 @@ web3._extend({
 	]
 });
 `
+
+const LES_JS = `
+web3._extend({
+	property: 'les',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'addPriorityClient',
+			call: 'les_addPriorityClient',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'removePriorityClient',
+			call: 'les_removePriorityClient',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setTotalCapacity',
+			call: 'les_setTotalCapacity',
+			params: 1
+		}),
+	],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'latestCheckpoint',
+			getter: 'les_latestCheckpoint'
+		}),
+		new web3._extend.Property({
+			name: 'clients',
+			getter: 'les_clients'
+		}),
+	]
+});
+`
@@ -0,0 +1,117 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"errors"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/light"
+	"github.com/genchain/go-genchain/p2p/discover"
+)
+
+var errNoCheckpoint = errors.New("no checkpoint available")
+
+// latestCheckpoint returns the checkpoint of the last LES/2 section processed by
+// both the CHT and BloomTrie indexers, or nil if there's none yet. Servers index
+// their CHTs in LES/1 sized sections.
+func latestCheckpoint(db ethdb.Database, chtIndexer, bloomTrieIndexer *core.ChainIndexer, server bool) *light.TrustedCheckpoint {
+	chtSections, _, _ := chtIndexer.Sections()
+	if server {
+		chtSections /= light.CHTFrequencyClient / light.CHTFrequencyServer
+	}
+	sections, _, _ := bloomTrieIndexer.Sections()
+	if chtSections < sections {
+		sections = chtSections
+	}
+	if sections == 0 {
+		return nil
+	}
+	head := bloomTrieIndexer.SectionHead(sections - 1)
+	return light.ReadTrustedCheckpoint(db, sections-1, head, server)
+}
+
+// RPCCheckpoint is a trusted checkpoint along with the hash identifying it.
+type RPCCheckpoint struct {
+	light.TrustedCheckpoint
+	Hash common.Hash `json:"hash"`
+}
+
+// PublicCheckpointAPI provides an API to access the CHT/BloomTrie checkpoints
+// of a light client or server.
+type PublicCheckpointAPI struct {
+	db                           ethdb.Database
+	chtIndexer, bloomTrieIndexer *core.ChainIndexer
+	server                       bool
+}
+
+// NewPublicCheckpointAPI creates a new checkpoint API over the given indexers.
+func NewPublicCheckpointAPI(db ethdb.Database, chtIndexer, bloomTrieIndexer *core.ChainIndexer, server bool) *PublicCheckpointAPI {
+	return &PublicCheckpointAPI{
+		db:               db,
+		chtIndexer:       chtIndexer,
+		bloomTrieIndexer: bloomTrieIndexer,
+		server:           server,
+	}
+}
+
+// LatestCheckpoint returns the most recent checkpoint available locally, which a
+// server operator can sign with `ggen checkpoint sign` for light clients to start
+// syncing from.
+func (api *PublicCheckpointAPI) LatestCheckpoint() (*RPCCheckpoint, error) {
+	cp := latestCheckpoint(api.db, api.chtIndexer, api.bloomTrieIndexer, api.server)
+	if cp == nil {
+		return nil, errNoCheckpoint
+	}
+	return &RPCCheckpoint{TrustedCheckpoint: *cp, Hash: cp.Hash()}, nil
+}
+
+// PrivateLightServerAPI provides an API to manage the serving capacity of a light
+// server, assigning guaranteed capacity to priority clients.
+type PrivateLightServerAPI struct {
+	cm *capacityManager
+}
+
+// NewPrivateLightServerAPI creates a new capacity management API for the server.
+func NewPrivateLightServerAPI(server *LesServer) *PrivateLightServerAPI {
+	return &PrivateLightServerAPI{cm: server.capManager}
+}
+
+// AddPriorityClient assigns a guaranteed serving capacity to the client with the
+// given node ID, reconnecting it if it's currently served with a different one.
+func (api *PrivateLightServerAPI) AddPriorityClient(id discover.NodeID, capacity uint64) error {
+	return api.cm.addPriority(id, capacity)
+}
+
+// RemovePriorityClient turns a priority client into a free one.
+func (api *PrivateLightServerAPI) RemovePriorityClient(id discover.NodeID) error {
+	return api.cm.removePriority(id)
+}
+
+// SetTotalCapacity changes the total serving capacity shared by the clients,
+// disconnecting free clients if it's exceeded.
+func (api *PrivateLightServerAPI) SetTotalCapacity(capacity uint64) error {
+	return api.cm.setTotal(capacity)
+}
+
+// Clients returns the serving capacity distribution of the server, listing the
+// connected clients with their usage statistics and the priority clients.
+func (api *PrivateLightServerAPI) Clients() *CapacityInfo {
+	return api.cm.info()
+}
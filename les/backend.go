@@ -114,9 +114,20 @@ func New(ctx *node.ServiceContext, config *gen.Config) (*LightEthereum, error) {
 	leth.serverPool = newServerPool(chainDb, quitSync, &leth.wg)
 	leth.retriever = newRetrieveManager(peers, leth.reqDist, leth.serverPool)
 	leth.odr = NewLesOdr(chainDb, leth.chtIndexer, leth.bloomTrieIndexer, leth.bloomIndexer, leth.retriever)
+	if config.LightFuzzyPoW > 0 {
+		leth.engine = light.NewFuzzyEngine(leth.engine, config.LightFuzzyPoW)
+	}
 	if leth.blockchain, err = light.NewLightChain(leth.odr, leth.chainConfig, leth.engine); err != nil {
 		return nil, err
 	}
+	if config.Checkpoint != nil {
+		if err := config.Checkpoint.Verify(genesisHash, config.CheckpointSigners, config.CheckpointThreshold); err != nil {
+			return nil, fmt.Errorf("untrusted light checkpoint: %v", err)
+		}
+		cp := config.Checkpoint.Checkpoint
+		cp.Name = "signed"
+		leth.blockchain.AddTrustedCheckpoint(&cp)
+	}
 	leth.bloomIndexer.Start(leth.blockchain)
 	// Rewind the chain in case of an incompatible config upgrade.
 	if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
@@ -145,6 +156,8 @@ func lesTopic(genesisHash common.Hash, protocolVersion uint) discv5.Topic {
 		name = "LES"
 	case lpv2:
 		name = "LES2"
+	case lpv3:
+		name = "LES3"
 	default:
 		panic(nil)
 	}
@@ -190,7 +203,12 @@ func (s *LightEthereum) APIs() []rpc.API {
 		}, {
 			Namespace: "gen",
 			Version:   "1.0",
-			Service:   filters.NewPublicFilterAPI(s.ApiBackend, true),
+			Service:   filters.NewPublicFilterAPI(s.ApiBackend, true, s.config.Filter),
+			Public:    true,
+		}, {
+			Namespace: "les",
+			Version:   "1.0",
+			Service:   NewPublicCheckpointAPI(s.chainDb, s.chtIndexer, s.bloomTrieIndexer, false),
 			Public:    true,
 		}, {
 			Namespace: "net",
@@ -0,0 +1,348 @@
+// Copyright 2018The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/genchain/go-genchain/common/mclock"
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/les/flowcontrol"
+	"github.com/genchain/go-genchain/log"
+	"github.com/genchain/go-genchain/p2p"
+	"github.com/genchain/go-genchain/p2p/discover"
+	"github.com/genchain/go-genchain/rlp"
+)
+
+var (
+	errNoCapacity       = errors.New("not enough serving capacity")
+	errZeroCapacity     = errors.New("zero client capacity")
+	errUnknownPriority  = errors.New("unknown priority client")
+	errPriorityOverflow = errors.New("priority capacity exceeds total capacity")
+)
+
+var capacityKey = []byte("_serverCapacity")
+
+// capacityClient is a client connected to a light server, together with the
+// serving capacity assigned to it.
+type capacityClient struct {
+	peer      *peer
+	params    *flowcontrol.ServerParams
+	priority  bool
+	connected mclock.AbsTime
+}
+
+// capacityManager distributes the serving capacity of a light server among its
+// clients. Capacity is measured in flow control recharge units (the MinRecharge
+// parameter of the clients). Priority clients are guaranteed their assigned
+// capacity, evicting free clients if needed, while free clients are served with
+// the default flow control parameters as long as enough capacity remains.
+//
+// Flow control parameters are announced during the handshake, so capacity
+// changes of connected clients take effect by disconnecting them, letting them
+// reconnect with the updated parameters.
+type capacityManager struct {
+	db        ethdb.Database
+	defParams *flowcontrol.ServerParams // flow control parameters of free clients
+
+	lock     sync.Mutex
+	total    uint64                              // total serving capacity
+	custom   bool                                // whether the total was set through the API
+	priority map[discover.NodeID]uint64          // assigned capacities of priority clients
+	clients  map[discover.NodeID]*capacityClient // currently connected clients
+}
+
+// newCapacityManager creates a capacity manager, loading the total capacity and
+// the priority clients set through the API from the database. If the total was
+// never changed, the given default is used.
+func newCapacityManager(db ethdb.Database, defParams *flowcontrol.ServerParams, total uint64) *capacityManager {
+	cm := &capacityManager{
+		db:        db,
+		defParams: defParams,
+		total:     total,
+		priority:  make(map[discover.NodeID]uint64),
+		clients:   make(map[discover.NodeID]*capacityClient),
+	}
+	cm.load()
+	if priority := cm.priorityCapacity(); cm.total < priority {
+		cm.total = priority
+	}
+	return cm
+}
+
+// capacityRlp is the database representation of the capacity settings.
+type capacityRlp struct {
+	Total    uint64 // zero if the default total capacity is used
+	Priority []priorityClientRlp
+}
+
+// priorityClientRlp is the database representation of a priority client.
+type priorityClientRlp struct {
+	ID       discover.NodeID
+	Capacity uint64
+}
+
+// load reads the capacity settings from the database.
+func (cm *capacityManager) load() {
+	if cm.db == nil {
+		return
+	}
+	data, err := cm.db.Get(capacityKey)
+	if err != nil {
+		return
+	}
+	var stored capacityRlp
+	if err := rlp.DecodeBytes(data, &stored); err != nil {
+		log.Error("Failed to decode server capacity settings", "err", err)
+		return
+	}
+	if stored.Total != 0 {
+		cm.total, cm.custom = stored.Total, true
+	}
+	for _, entry := range stored.Priority {
+		cm.priority[entry.ID] = entry.Capacity
+	}
+}
+
+// store writes the capacity settings into the database.
+func (cm *capacityManager) store() {
+	if cm.db == nil {
+		return
+	}
+	stored := capacityRlp{Priority: make([]priorityClientRlp, 0, len(cm.priority))}
+	if cm.custom {
+		stored.Total = cm.total
+	}
+	for id, capacity := range cm.priority {
+		stored.Priority = append(stored.Priority, priorityClientRlp{id, capacity})
+	}
+	if data, err := rlp.EncodeToBytes(stored); err == nil {
+		cm.db.Put(capacityKey, data)
+	}
+}
+
+// params returns the flow control parameters of a client with the given capacity,
+// scaling the buffer limit of free clients to keep the same buffer recharge time.
+func (cm *capacityManager) params(capacity uint64) *flowcontrol.ServerParams {
+	return &flowcontrol.ServerParams{
+		BufLimit:    cm.defParams.BufLimit / cm.defParams.MinRecharge * capacity,
+		MinRecharge: capacity,
+	}
+}
+
+// used returns the capacity assigned to the connected clients.
+func (cm *capacityManager) used() (used uint64) {
+	for _, client := range cm.clients {
+		used += client.params.MinRecharge
+	}
+	return used
+}
+
+// priorityCapacity returns the capacity assigned to priority clients, connected
+// or not.
+func (cm *capacityManager) priorityCapacity() (capacity uint64) {
+	for _, c := range cm.priority {
+		capacity += c
+	}
+	return capacity
+}
+
+// evict disconnects free clients, most recently connected first, until the used
+// capacity is at most the given limit.
+func (cm *capacityManager) evict(limit uint64) {
+	var free []*capacityClient
+	for _, client := range cm.clients {
+		if !client.priority {
+			free = append(free, client)
+		}
+	}
+	sort.Slice(free, func(i, j int) bool { return free[i].connected > free[j].connected })
+
+	for used := cm.used(); used > limit && len(free) > 0; free = free[1:] {
+		cm.drop(free[0], p2p.DiscTooManyPeers)
+		used -= free[0].params.MinRecharge
+	}
+}
+
+// drop disconnects a client and releases its capacity.
+func (cm *capacityManager) drop(client *capacityClient, reason p2p.DiscReason) {
+	delete(cm.clients, client.peer.ID())
+	client.peer.Log().Debug("Dropping light client", "priority", client.priority, "reason", reason)
+	client.peer.Disconnect(reason)
+}
+
+// isPriority returns whether the client with the given ID is a priority client.
+func (cm *capacityManager) isPriority(id discover.NodeID) bool {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+
+	_, ok := cm.priority[id]
+	return ok
+}
+
+// connect assigns serving capacity to a newly connected client, returning the
+// flow control parameters it should be served with.
+func (cm *capacityManager) connect(p *peer) (*flowcontrol.ServerParams, error) {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+
+	id := p.ID()
+	if _, ok := cm.clients[id]; ok {
+		return nil, errAlreadyRegistered
+	}
+	client := &capacityClient{peer: p, params: cm.defParams, connected: mclock.Now()}
+	if capacity, ok := cm.priority[id]; ok {
+		client.params, client.priority = cm.params(capacity), true
+		cm.evict(cm.total - capacity)
+	}
+	if cm.used()+client.params.MinRecharge > cm.total {
+		return nil, errNoCapacity
+	}
+	cm.clients[id] = client
+	return client.params, nil
+}
+
+// disconnect releases the capacity of a disconnected client.
+func (cm *capacityManager) disconnect(p *peer) {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+
+	if client, ok := cm.clients[p.ID()]; ok && client.peer == p {
+		delete(cm.clients, p.ID())
+	}
+}
+
+// addPriority assigns a guaranteed capacity to a client, disconnecting it if
+// it's currently served with different parameters.
+func (cm *capacityManager) addPriority(id discover.NodeID, capacity uint64) error {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+
+	if capacity == 0 {
+		return errZeroCapacity
+	}
+	if cm.priorityCapacity()-cm.priority[id]+capacity > cm.total {
+		return errPriorityOverflow
+	}
+	cm.priority[id] = capacity
+	cm.store()
+
+	if client, ok := cm.clients[id]; ok && client.params.MinRecharge != capacity {
+		cm.drop(client, p2p.DiscRequested)
+	}
+	return nil
+}
+
+// removePriority turns a priority client into a free one, disconnecting it if
+// it's currently connected.
+func (cm *capacityManager) removePriority(id discover.NodeID) error {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+
+	if _, ok := cm.priority[id]; !ok {
+		return errUnknownPriority
+	}
+	delete(cm.priority, id)
+	cm.store()
+
+	if client, ok := cm.clients[id]; ok && client.priority {
+		cm.drop(client, p2p.DiscRequested)
+	}
+	return nil
+}
+
+// setTotal changes the total serving capacity, evicting free clients if the
+// connected ones exceed it.
+func (cm *capacityManager) setTotal(total uint64) error {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+
+	if total == 0 {
+		return errZeroCapacity
+	}
+	if cm.priorityCapacity() > total {
+		return errPriorityOverflow
+	}
+	cm.total, cm.custom = total, true
+	cm.store()
+	cm.evict(total)
+	return nil
+}
+
+// ClientInfo contains the capacity and usage statistics of a light client.
+type ClientInfo struct {
+	ID        discover.NodeID `json:"id"`
+	Priority  bool            `json:"priority"`
+	Connected bool            `json:"connected"`
+	Capacity  uint64          `json:"capacity"`
+	Duration  time.Duration   `json:"duration"` // Time since the client connected
+	Requests  uint64          `json:"requests"` // Number of requests served
+	Cost      uint64          `json:"cost"`     // Sum cost of the requests served
+}
+
+// CapacityInfo contains the distribution of a light server's serving capacity.
+type CapacityInfo struct {
+	Total    uint64       `json:"total"`
+	Priority uint64       `json:"priority"` // Capacity reserved for priority clients
+	Used     uint64       `json:"used"`     // Capacity assigned to connected clients
+	Clients  []ClientInfo `json:"clients"`
+}
+
+// info returns the current capacity distribution, including the connected and
+// the priority clients.
+func (cm *capacityManager) info() *CapacityInfo {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+
+	info := &CapacityInfo{
+		Total:    cm.total,
+		Priority: cm.priorityCapacity(),
+		Used:     cm.used(),
+		Clients:  []ClientInfo{},
+	}
+	now := mclock.Now()
+	for id, client := range cm.clients {
+		entry := ClientInfo{
+			ID:        id,
+			Priority:  client.priority,
+			Connected: true,
+			Capacity:  client.params.MinRecharge,
+			Duration:  time.Duration(now - client.connected),
+		}
+		if client.peer.fcClient != nil {
+			entry.Requests, entry.Cost = client.peer.fcClient.Usage()
+		}
+		info.Clients = append(info.Clients, entry)
+	}
+	for id, capacity := range cm.priority {
+		if _, ok := cm.clients[id]; !ok {
+			info.Clients = append(info.Clients, ClientInfo{ID: id, Priority: true, Capacity: capacity})
+		}
+	}
+	sort.Slice(info.Clients, func(i, j int) bool {
+		a, b := info.Clients[i], info.Clients[j]
+		if a.Priority != b.Priority {
+			return a.Priority
+		}
+		return bytes.Compare(a.ID[:], b.ID[:]) < 0
+	})
+	return info
+}
@@ -0,0 +1,118 @@
+// Copyright 2018The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"testing"
+
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/les/flowcontrol"
+	"github.com/genchain/go-genchain/p2p"
+	"github.com/genchain/go-genchain/p2p/discover"
+)
+
+// newCapacityPeer creates a light client peer with the given node ID byte.
+func newCapacityPeer(id byte) *peer {
+	return newPeer(lpv2, NetworkId, p2p.NewPeer(discover.NodeID{id}, "client", nil), nil)
+}
+
+// countClients returns the number of free and priority clients connected.
+func countClients(cm *capacityManager) (free, priority int) {
+	for _, client := range cm.clients {
+		if client.priority {
+			priority++
+		} else {
+			free++
+		}
+	}
+	return free, priority
+}
+
+// Tests that priority clients get their guaranteed capacity, evicting free ones
+// if needed, and that free clients only share the remaining capacity.
+func TestCapacityManager(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	cm := newCapacityManager(db, &flowcontrol.ServerParams{BufLimit: 600, MinRecharge: 10}, 30)
+
+	// Fill up the capacity with free clients
+	for i := byte(1); i <= 3; i++ {
+		params, err := cm.connect(newCapacityPeer(i))
+		if err != nil {
+			t.Fatalf("free client %d: failed to connect: %v", i, err)
+		}
+		if params.MinRecharge != 10 || params.BufLimit != 600 {
+			t.Errorf("free client %d: params mismatch: have %+v", i, params)
+		}
+	}
+	if _, err := cm.connect(newCapacityPeer(4)); err != errNoCapacity {
+		t.Fatalf("free client over capacity: error mismatch: have %v, want %v", err, errNoCapacity)
+	}
+	// Add a priority client and ensure free ones make room for it
+	prio := newCapacityPeer(0xff)
+	if err := cm.addPriority(prio.ID(), 20); err != nil {
+		t.Fatalf("failed to add priority client: %v", err)
+	}
+	if err := cm.addPriority(discover.NodeID{0xfe}, 20); err != errPriorityOverflow {
+		t.Fatalf("priority overflow: error mismatch: have %v, want %v", err, errPriorityOverflow)
+	}
+	params, err := cm.connect(prio)
+	if err != nil {
+		t.Fatalf("priority client failed to connect: %v", err)
+	}
+	if params.MinRecharge != 20 || params.BufLimit != 1200 {
+		t.Errorf("priority client params mismatch: have %+v", params)
+	}
+	if free, priority := countClients(cm); free != 1 || priority != 1 {
+		t.Errorf("client count mismatch: have %d free, %d priority, want 1 free, 1 priority", free, priority)
+	}
+	// Change the total capacity and check the limits
+	if err := cm.setTotal(10); err != errPriorityOverflow {
+		t.Fatalf("total below priority: error mismatch: have %v, want %v", err, errPriorityOverflow)
+	}
+	if err := cm.setTotal(20); err != nil {
+		t.Fatalf("failed to set total capacity: %v", err)
+	}
+	if free, priority := countClients(cm); free != 0 || priority != 1 {
+		t.Errorf("client count mismatch: have %d free, %d priority, want 0 free, 1 priority", free, priority)
+	}
+	info := cm.info()
+	if info.Total != 20 || info.Priority != 20 || info.Used != 20 || len(info.Clients) != 1 || !info.Clients[0].Connected {
+		t.Errorf("capacity info mismatch: have %+v", info)
+	}
+	// Ensure the settings are persisted
+	if cm := newCapacityManager(db, cm.defParams, 30); cm.total != 20 || cm.priority[prio.ID()] != 20 {
+		t.Errorf("persisted settings mismatch: have total %d, priority %v", cm.total, cm.priority)
+	}
+	// Demote the priority client and ensure it's dropped
+	if err := cm.removePriority(prio.ID()); err != nil {
+		t.Fatalf("failed to remove priority client: %v", err)
+	}
+	if err := cm.removePriority(prio.ID()); err != errUnknownPriority {
+		t.Fatalf("unknown priority client: error mismatch: have %v, want %v", err, errUnknownPriority)
+	}
+	if free, priority := countClients(cm); free != 0 || priority != 0 {
+		t.Errorf("client count mismatch: have %d free, %d priority, want none", free, priority)
+	}
+	cm.disconnect(prio)
+
+	if _, err := cm.connect(prio); err != nil {
+		t.Fatalf("demoted client failed to connect: %v", err)
+	}
+	if free, priority := countClients(cm); free != 1 || priority != 0 {
+		t.Errorf("client count mismatch: have %d free, %d priority, want 1 free", free, priority)
+	}
+}
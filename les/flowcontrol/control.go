@@ -37,6 +37,8 @@ type ClientNode struct {
 	lock     sync.Mutex
 	cm       *ClientManager
 	cmNode   *cmNode
+
+	requests, sumCost uint64 // usage statistics of the client
 }
 
 func NewClientNode(cm *ClientManager, params *ServerParams) *ClientNode {
@@ -82,6 +84,8 @@ func (peer *ClientNode) RequestProcessed(cost uint64) (bv, realCost uint64) {
 	time := mclock.Now()
 	peer.recalcBV(time)
 	peer.bufValue -= cost
+	peer.requests++
+	peer.sumCost += cost
 	peer.recalcBV(time)
 	rcValue, rcost := peer.cm.processed(peer.cmNode, time)
 	if rcValue < peer.params.BufLimit {
@@ -93,6 +97,14 @@ func (peer *ClientNode) RequestProcessed(cost uint64) (bv, realCost uint64) {
 	return peer.bufValue, rcost
 }
 
+// Usage returns the number of requests served to the client and their sum cost.
+func (peer *ClientNode) Usage() (requests, cost uint64) {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+
+	return peer.requests, peer.sumCost
+}
+
 type ServerNode struct {
 	bufEstimate uint64
 	lastTime    mclock.AbsTime
@@ -59,6 +59,7 @@ const (
 	MaxHelperTrieProofsFetch = 64  // Amount of merkle proofs to be fetched per retrieval request
 	MaxTxSend                = 64  // Amount of transactions to be send per request
 	MaxTxStatus              = 256 // Amount of transactions to queried per request
+	MaxTxSubscriptions       = 256 // Amount of transaction inclusion subscriptions allowed per client
 
 	disableClientRemovePeer = false
 )
@@ -205,7 +206,7 @@ func NewProtocolManager(chainConfig *params.ChainConfig, lightSync bool, protoco
 	}
 
 	if lightSync {
-		manager.downloader = downloader.New(downloader.LightSync, chainDb, manager.eventMux, nil, blockchain, removePeer)
+		manager.downloader = downloader.New(downloader.LightSync, nil, chainDb, manager.eventMux, nil, blockchain, removePeer)
 		manager.peers.notify((*downloaderPeerNotify)(manager))
 		manager.fetcher = newLightFetcher(manager)
 	}
@@ -261,10 +262,18 @@ func (pm *ProtocolManager) newPeer(pv int, nv uint64, p *p2p.Peer, rw p2p.MsgRea
 // handle is the callback invoked to manage the life cycle of a les peer. When
 // this function terminates, the peer is disconnected.
 func (pm *ProtocolManager) handle(p *peer) error {
-	// Ignore maxPeers if this is a trusted peer
-	if pm.peers.Len() >= pm.maxPeers && !p.Peer.Info().Network.Trusted {
+	// Ignore maxPeers if this is a trusted peer or a priority client
+	if pm.peers.Len() >= pm.maxPeers && !p.Peer.Info().Network.Trusted && (pm.server == nil || !pm.server.isPriorityClient(p)) {
 		return p2p.DiscTooManyPeers
 	}
+	// Assign serving capacity to the client if we're a server
+	if pm.server != nil {
+		if err := pm.server.connectClient(p); err != nil {
+			p.Log().Debug("Light client rejected", "err", err)
+			return p2p.DiscTooManyPeers
+		}
+		defer pm.server.disconnectClient(p)
+	}
 
 	p.Log().Debug("Light Genchain peer connected", "name", p.Name())
 
@@ -331,7 +340,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	}
 }
 
-var reqList = []uint64{GetBlockHeadersMsg, GetBlockBodiesMsg, GetCodeMsg, GetReceiptsMsg, GetProofsV1Msg, SendTxMsg, SendTxV2Msg, GetTxStatusMsg, GetHeaderProofsMsg, GetProofsV2Msg, GetHelperTrieProofsMsg}
+var reqList = []uint64{GetBlockHeadersMsg, GetBlockBodiesMsg, GetCodeMsg, GetReceiptsMsg, GetProofsV1Msg, SendTxMsg, SendTxV2Msg, GetTxStatusMsg, GetHeaderProofsMsg, GetProofsV2Msg, GetHelperTrieProofsMsg, SubscribeTxsMsg}
 
 // handleMsg is invoked whenever an inbound message is received from a remote
 // peer. The remote connection is torn down upon returning any error.
@@ -350,11 +359,11 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		}
 		bufValue, _ := p.fcClient.AcceptRequest()
 		cost := costs.baseCost + reqCnt*costs.reqCost
-		if cost > pm.server.defParams.BufLimit {
-			cost = pm.server.defParams.BufLimit
+		if cost > p.fcParams.BufLimit {
+			cost = p.fcParams.BufLimit
 		}
 		if cost > bufValue {
-			recharge := time.Duration((cost - bufValue) * 1000000 / pm.server.defParams.MinRecharge)
+			recharge := time.Duration((cost - bufValue) * 1000000 / p.fcParams.MinRecharge)
 			p.Log().Error("Request came too early", "recharge", common.PrettyDuration(recharge))
 			return true
 		}
@@ -1080,13 +1089,61 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		p.Log().Trace("Received tx status response")
 		var resp struct {
 			ReqID, BV uint64
-			Status    []txStatus
+			Status    []light.TxStatus
 		}
 		if err := msg.Decode(&resp); err != nil {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
 
 		p.fcServer.GotReply(resp.ReqID, resp.BV)
+		// Replies to relayed transactions are not awaited by any retrieval
+		if pm.retriever.requested(resp.ReqID) {
+			deliverMsg = &Msg{
+				MsgType: MsgTxStatus,
+				ReqID:   resp.ReqID,
+				Obj:     resp.Status,
+			}
+		}
+
+	case SubscribeTxsMsg:
+		if pm.server == nil || pm.server.txNotifier == nil {
+			return errResp(ErrRequestRejected, "")
+		}
+		p.Log().Trace("Received transaction subscription")
+		var req struct {
+			ReqID uint64
+			Sub   txSubscription
+		}
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		reqCnt := len(req.Sub.Add) + len(req.Sub.Remove)
+		if reject(uint64(reqCnt), MaxTxSubscriptions) {
+			return errResp(ErrRequestRejected, "")
+		}
+		included, err := pm.server.txNotifier.subscribe(p, req.Sub.Add, req.Sub.Remove)
+		if err != nil {
+			return errResp(ErrRequestRejected, "%v", err)
+		}
+		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
+
+		return p.SendTxInclusions(req.ReqID, bv, included)
+
+	case TxInclusionMsg:
+		if pm.txrelay == nil {
+			return errResp(ErrUnexpectedResponse, "")
+		}
+		p.Log().Trace("Received transaction inclusions")
+		var resp struct {
+			ReqID, BV  uint64
+			Inclusions []light.TxInclusion
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.fcServer.GotReply(resp.ReqID, resp.BV)
+		pm.txrelay.deliverInclusions(p, resp.Inclusions)
 
 	default:
 		p.Log().Trace("Received unknown message", "code", msg.Code)
@@ -576,3 +576,29 @@ func TestTransactionStatusLes2(t *testing.T) {
 	test(tx1, false, txStatus{Status: core.TxStatusPending})
 	test(tx2, false, txStatus{Status: core.TxStatusPending})
 }
+
+// Tests that transaction inclusion subscriptions are flow controlled requests,
+// replied to with the inclusions of the already included transactions.
+func TestTxSubscriptionLes3(t *testing.T) {
+	signer := types.HomesteadSigner{}
+	tx1, _ := types.SignTx(types.NewTransaction(0, acc1Addr, big.NewInt(10000), params.TxGas, nil, nil), signer, testBankKey)
+	tx2, _ := types.SignTx(types.NewTransaction(1, acc1Addr, big.NewInt(10000), params.TxGas, nil, nil), signer, testBankKey)
+
+	db := ethdb.NewMemDatabase()
+	pm := newTestProtocolManagerMust(t, false, 1, func(i int, block *core.BlockGen) { block.AddTx(tx1) }, nil, nil, db)
+	pm.server.txNotifier = newTxNotifier(db)
+	peer, _ := newTestPeer(t, "peer", lpv3, pm, true)
+	defer peer.close()
+
+	header := pm.blockchain.CurrentHeader()
+	test := func(reqID uint64, add, remove []common.Hash, exp []light.TxInclusion) {
+		t.Helper()
+		cost := peer.GetRequestCost(SubscribeTxsMsg, len(add)+len(remove))
+		sendRequest(peer.app, SubscribeTxsMsg, reqID, cost, &txSubscription{Add: add, Remove: remove})
+		if err := expectResponse(peer.app, TxInclusionMsg, reqID, testBufLimit, exp); err != nil {
+			t.Errorf("request %d: inclusions mismatch: %v", reqID, err)
+		}
+	}
+	test(1, []common.Hash{tx1.Hash(), tx2.Hash()}, nil, []light.TxInclusion{inclusion(tx1, header, 0)})
+	test(2, nil, []common.Hash{tx1.Hash()}, []light.TxInclusion{})
+}
@@ -35,6 +35,7 @@ import (
 	"github.com/genchain/go-genchain/p2p"
 	"github.com/genchain/go-genchain/p2p/discv5"
 	"github.com/genchain/go-genchain/rlp"
+	"github.com/genchain/go-genchain/rpc"
 )
 
 type LesServer struct {
@@ -43,6 +44,8 @@ type LesServer struct {
 	fcManager       *flowcontrol.ClientManager // nil if our node is client only
 	fcCostStats     *requestCostStats
 	defParams       *flowcontrol.ServerParams
+	capManager      *capacityManager // nil if serving all clients equally (tests)
+	txNotifier      *txNotifier      // nil if not reporting transaction inclusions (tests)
 	lesTopics       []discv5.Topic
 	privateKey      *ecdsa.PrivateKey
 	quitSync        chan struct{}
@@ -100,13 +103,60 @@ func NewLesServer(gen *gen.Genchain, config *gen.Config) (*LesServer, error) {
 	}
 	srv.fcManager = flowcontrol.NewClientManager(uint64(config.LightServ), 10, 1000000000)
 	srv.fcCostStats = newCostStats(gen.ChainDb())
+	srv.capManager = newCapacityManager(gen.ChainDb(), srv.defParams, uint64(config.LightPeers)*srv.defParams.MinRecharge)
+	srv.txNotifier = newTxNotifier(gen.ChainDb())
+	pm.peers.notify(srv.txNotifier)
 	return srv, nil
 }
 
+// isPriorityClient returns whether the peer is a priority client, which may be
+// connected above the peer limit.
+func (s *LesServer) isPriorityClient(p *peer) bool {
+	return s.capManager != nil && s.capManager.isPriority(p.ID())
+}
+
+// connectClient assigns serving capacity and flow control parameters to a newly
+// connected client.
+func (s *LesServer) connectClient(p *peer) error {
+	if s.capManager == nil {
+		p.fcParams = s.defParams
+		return nil
+	}
+	params, err := s.capManager.connect(p)
+	if err != nil {
+		return err
+	}
+	p.fcParams = params
+	return nil
+}
+
+// disconnectClient releases the serving capacity of a disconnected client.
+func (s *LesServer) disconnectClient(p *peer) {
+	if s.capManager != nil {
+		s.capManager.disconnect(p)
+	}
+}
+
 func (s *LesServer) Protocols() []p2p.Protocol {
 	return s.protocolManager.SubProtocols
 }
 
+// APIs returns the collection of RPC services the LES server offers.
+func (s *LesServer) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "les",
+			Version:   "1.0",
+			Service:   NewPublicCheckpointAPI(s.protocolManager.chainDb, s.chtIndexer, s.bloomTrieIndexer, true),
+			Public:    true,
+		}, {
+			Namespace: "les",
+			Version:   "1.0",
+			Service:   NewPrivateLightServerAPI(s),
+		},
+	}
+}
+
 // Start starts the LES server
 func (s *LesServer) Start(srvr *p2p.Server) {
 	s.protocolManager.Start(s.config.LightPeers)
@@ -325,6 +375,9 @@ func (pm *ProtocolManager) blockLoop() {
 		for {
 			select {
 			case ev := <-headCh:
+				if pm.server.txNotifier != nil {
+					pm.notifyTxInclusions(ev.Block.Header())
+				}
 				peers := pm.peers.AllPeers()
 				if len(peers) > 0 {
 					header := ev.Block.Header()
@@ -0,0 +1,174 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/core/rawdb"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/light"
+)
+
+// maxTxNotifyDepth is the maximum number of blocks becoming canonical with a
+// single new head that are checked for subscribed transactions.
+const maxTxNotifyDepth = 64
+
+var errTooManySubscriptions = errors.New("too many transaction subscriptions")
+
+// txNotifier keeps track of the transactions light clients are interested in and
+// reports their inclusion in canonical blocks, sparing the clients from fetching
+// every new block while waiting for their transactions to get mined.
+//
+// Subscriptions are kept until removed by the client or the client disconnects,
+// so inclusions in new canonical blocks after a reorg are reported too.
+type txNotifier struct {
+	db   ethdb.Database
+	lock sync.Mutex
+
+	head  *types.Header                      // last processed chain head
+	subs  map[common.Hash]map[*peer]struct{} // subscribed clients by tx hash
+	peers map[*peer]map[common.Hash]struct{} // subscribed tx hashes by client
+}
+
+// newTxNotifier creates a transaction inclusion notifier processing new heads
+// starting from the current head of the database.
+func newTxNotifier(db ethdb.Database) *txNotifier {
+	n := &txNotifier{
+		db:    db,
+		subs:  make(map[common.Hash]map[*peer]struct{}),
+		peers: make(map[*peer]map[common.Hash]struct{}),
+	}
+	if hash := rawdb.ReadHeadHeaderHash(db); hash != (common.Hash{}) {
+		if number := rawdb.ReadHeaderNumber(db, hash); number != nil {
+			n.head = rawdb.ReadHeader(db, hash, *number)
+		}
+	}
+	return n
+}
+
+// subscribe updates the subscriptions of a client and returns the inclusions of
+// the newly subscribed transactions that are already part of the chain.
+func (n *txNotifier) subscribe(p *peer, add, remove []common.Hash) ([]light.TxInclusion, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	for _, hash := range remove {
+		n.unsubscribe(p, hash)
+	}
+	hashes := n.peers[p]
+	if hashes == nil {
+		hashes = make(map[common.Hash]struct{})
+		n.peers[p] = hashes
+	}
+	var included []light.TxInclusion
+	for _, hash := range add {
+		if _, ok := hashes[hash]; ok {
+			continue
+		}
+		if len(hashes) >= MaxTxSubscriptions {
+			return nil, errTooManySubscriptions
+		}
+		hashes[hash] = struct{}{}
+		if n.subs[hash] == nil {
+			n.subs[hash] = make(map[*peer]struct{})
+		}
+		n.subs[hash][p] = struct{}{}
+
+		if block, number, index := rawdb.ReadTxLookupEntry(n.db, hash); block != (common.Hash{}) {
+			included = append(included, light.TxInclusion{Hash: hash, BlockHash: block, BlockNumber: number, Index: index})
+		}
+	}
+	return included, nil
+}
+
+// unsubscribe removes a single subscription of a client. The lock is assumed to
+// be held by the caller.
+func (n *txNotifier) unsubscribe(p *peer, hash common.Hash) {
+	if peers, ok := n.subs[hash]; ok {
+		delete(peers, p)
+		if len(peers) == 0 {
+			delete(n.subs, hash)
+		}
+	}
+	if hashes, ok := n.peers[p]; ok {
+		delete(hashes, hash)
+	}
+}
+
+// registerPeer implements peerSetNotify.
+func (n *txNotifier) registerPeer(p *peer) {}
+
+// unregisterPeer implements peerSetNotify, dropping all the subscriptions of a
+// disconnected client.
+func (n *txNotifier) unregisterPeer(p *peer) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	for hash := range n.peers[p] {
+		n.unsubscribe(p, hash)
+	}
+	delete(n.peers, p)
+}
+
+// newHead processes the blocks that became canonical since the last head and
+// returns the inclusions of subscribed transactions to report to each client.
+func (n *txNotifier) newHead(head *types.Header) map[*peer][]light.TxInclusion {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	last := n.head
+	n.head = head
+	if len(n.subs) == 0 || last == nil {
+		return nil
+	}
+	ancestor := rawdb.FindCommonAncestor(n.db, head, last)
+	if ancestor == nil {
+		return nil
+	}
+	from, to := ancestor.Number.Uint64()+1, head.Number.Uint64()
+	if to >= from+maxTxNotifyDepth {
+		from = to - maxTxNotifyDepth + 1
+	}
+	notify := make(map[*peer][]light.TxInclusion)
+	for number := from; number <= to; number++ {
+		hash := rawdb.ReadCanonicalHash(n.db, number)
+		body := rawdb.ReadBody(n.db, hash, number)
+		if body == nil {
+			continue
+		}
+		for i, tx := range body.Transactions {
+			txHash := tx.Hash()
+			for p := range n.subs[txHash] {
+				notify[p] = append(notify[p], light.TxInclusion{Hash: txHash, BlockHash: hash, BlockNumber: number, Index: uint64(i)})
+			}
+		}
+	}
+	return notify
+}
+
+// notifyTxInclusions sends the inclusion notifications of subscribed transactions
+// made canonical by a new head to the clients.
+func (pm *ProtocolManager) notifyTxInclusions(head *types.Header) {
+	for p, list := range pm.server.txNotifier.newHead(head) {
+		p, list := p, list
+		p.queueSend(func() { p.SendTxInclusions(0, 0, list) })
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/core/rawdb"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/light"
+)
+
+// writeNotifyBlock writes a block with the given transactions on top of parent
+// into the database as the canonical one at its height.
+func writeNotifyBlock(db ethdb.Database, parent *types.Header, extra byte, txs ...*types.Transaction) *types.Header {
+	header := &types.Header{
+		Number: big.NewInt(0),
+		Extra:  []byte{extra},
+	}
+	if parent != nil {
+		header.ParentHash = parent.Hash()
+		header.Number = new(big.Int).Add(parent.Number, common.Big1)
+	}
+	block := types.NewBlock(header, txs, nil, nil)
+
+	rawdb.WriteBlock(db, block)
+	rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+	rawdb.WriteHeadHeaderHash(db, block.Hash())
+	rawdb.WriteTxLookupEntries(db, block)
+
+	return block.Header()
+}
+
+// inclusion assembles the expected notification of a transaction included in a block.
+func inclusion(tx *types.Transaction, header *types.Header, index uint64) light.TxInclusion {
+	return light.TxInclusion{Hash: tx.Hash(), BlockHash: header.Hash(), BlockNumber: header.Number.Uint64(), Index: index}
+}
+
+func TestTxNotifier(t *testing.T) {
+	var (
+		db      = ethdb.NewMemDatabase()
+		genesis = writeNotifyBlock(db, nil, 0)
+		n       = newTxNotifier(db)
+
+		tx1 = types.NewTransaction(1, common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil)
+		tx2 = types.NewTransaction(2, common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil)
+		p1  = newCapacityPeer(1)
+		p2  = newCapacityPeer(2)
+	)
+	// Subscribe to transactions not included yet, and get them included
+	if included, err := n.subscribe(p1, []common.Hash{tx1.Hash(), tx2.Hash()}, nil); err != nil || len(included) != 0 {
+		t.Fatalf("subscription mismatch: have %v/%v, want none", included, err)
+	}
+	block1 := writeNotifyBlock(db, genesis, 1, tx1)
+	if notify := n.newHead(block1); !reflect.DeepEqual(notify, map[*peer][]light.TxInclusion{p1: {inclusion(tx1, block1, 0)}}) {
+		t.Fatalf("inclusion mismatch: have %v", notify)
+	}
+	// Subscribe to an already included transaction
+	included, err := n.subscribe(p2, []common.Hash{tx1.Hash()}, nil)
+	if err != nil || !reflect.DeepEqual(included, []light.TxInclusion{inclusion(tx1, block1, 0)}) {
+		t.Fatalf("subscription mismatch: have %v/%v", included, err)
+	}
+	// Reorg both transactions into a new chain
+	block1b := writeNotifyBlock(db, genesis, 2, tx2, tx1)
+	block2b := writeNotifyBlock(db, block1b, 2)
+
+	want := map[*peer][]light.TxInclusion{
+		p1: {inclusion(tx2, block1b, 0), inclusion(tx1, block1b, 1)},
+		p2: {inclusion(tx1, block1b, 1)},
+	}
+	if notify := n.newHead(block2b); !reflect.DeepEqual(notify, want) {
+		t.Fatalf("reorg inclusion mismatch: have %v, want %v", notify, want)
+	}
+	// Drop all subscriptions to the first transaction
+	if _, err := n.subscribe(p1, nil, []common.Hash{tx1.Hash()}); err != nil {
+		t.Fatalf("failed to unsubscribe: %v", err)
+	}
+	n.unregisterPeer(p2)
+
+	block3 := writeNotifyBlock(db, block2b, 3, tx1, tx2)
+	if notify := n.newHead(block3); !reflect.DeepEqual(notify, map[*peer][]light.TxInclusion{p1: {inclusion(tx2, block3, 1)}}) {
+		t.Fatalf("inclusion mismatch after unsubscribe: have %v", notify)
+	}
+	if _, ok := n.subs[tx1.Hash()]; ok {
+		t.Fatalf("stale subscriptions to unsubscribed transaction")
+	}
+	if _, ok := n.peers[p2]; ok {
+		t.Fatalf("stale subscriptions of unregistered peer")
+	}
+}
+
+func TestTxNotifierLimit(t *testing.T) {
+	n := newTxNotifier(ethdb.NewMemDatabase())
+	p := newCapacityPeer(1)
+
+	hashes := make([]common.Hash, MaxTxSubscriptions+1)
+	for i := range hashes {
+		hashes[i][0], hashes[i][1] = byte(i>>8), byte(i)
+	}
+	if _, err := n.subscribe(p, hashes[:MaxTxSubscriptions], nil); err != nil {
+		t.Fatalf("failed to subscribe up to the limit: %v", err)
+	}
+	if _, err := n.subscribe(p, hashes[MaxTxSubscriptions:], nil); err != errTooManySubscriptions {
+		t.Fatalf("subscription above limit error mismatch: have %v, want %v", err, errTooManySubscriptions)
+	}
+	// Removing a subscription should make room for another one
+	if _, err := n.subscribe(p, hashes[MaxTxSubscriptions:], hashes[:1]); err != nil {
+		t.Fatalf("failed to subscribe after removal: %v", err)
+	}
+}
@@ -21,11 +21,14 @@ import (
 
 	"github.com/genchain/go-genchain/common"
 	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/event"
+	"github.com/genchain/go-genchain/light"
 )
 
 type ltrInfo struct {
-	tx     *types.Transaction
-	sentTo map[*peer]struct{}
+	tx         *types.Transaction
+	sentTo     map[*peer]struct{}
+	subscribed map[*peer]struct{} // servers reporting the inclusion of the tx
 }
 
 type LesTxRelay struct {
@@ -34,15 +37,18 @@ type LesTxRelay struct {
 	ps           *peerSet
 	peerList     []*peer
 	peerStartPos int
+	peerSubs     map[*peer]int // number of inclusion subscriptions at each server
 	lock         sync.RWMutex
 
-	reqDist *requestDistributor
+	reqDist       *requestDistributor
+	inclusionFeed event.Feed
 }
 
 func NewLesTxRelay(ps *peerSet, reqDist *requestDistributor) *LesTxRelay {
 	r := &LesTxRelay{
 		txSent:    make(map[common.Hash]*ltrInfo),
 		txPending: make(map[common.Hash]struct{}),
+		peerSubs:  make(map[*peer]int),
 		ps:        ps,
 		reqDist:   reqDist,
 	}
@@ -62,12 +68,19 @@ func (self *LesTxRelay) unregisterPeer(p *peer) {
 	defer self.lock.Unlock()
 
 	self.peerList = self.ps.AllPeers()
+	if _, ok := self.peerSubs[p]; ok {
+		for _, ltr := range self.txSent {
+			delete(ltr.subscribed, p)
+		}
+		delete(self.peerSubs, p)
+	}
 }
 
 // send sends a list of transactions to at most a given number of peers at
 // once, never resending any particular transaction to the same peer twice
 func (self *LesTxRelay) send(txs types.Transactions, count int) {
 	sendTo := make(map[*peer]types.Transactions)
+	subscribe := make(map[*peer][]common.Hash)
 
 	self.peerStartPos++ // rotate the starting position of the peer list
 	if self.peerStartPos >= len(self.peerList) {
@@ -79,8 +92,9 @@ func (self *LesTxRelay) send(txs types.Transactions, count int) {
 		ltr, ok := self.txSent[hash]
 		if !ok {
 			ltr = &ltrInfo{
-				tx:     tx,
-				sentTo: make(map[*peer]struct{}),
+				tx:         tx,
+				sentTo:     make(map[*peer]struct{}),
+				subscribed: make(map[*peer]struct{}),
 			}
 			self.txSent[hash] = ltr
 			self.txPending[hash] = struct{}{}
@@ -94,6 +108,11 @@ func (self *LesTxRelay) send(txs types.Transactions, count int) {
 				if _, ok := ltr.sentTo[peer]; !ok {
 					sendTo[peer] = append(sendTo[peer], tx)
 					ltr.sentTo[peer] = struct{}{}
+					if peer.version >= lpv3 && self.peerSubs[peer] < MaxTxSubscriptions {
+						subscribe[peer] = append(subscribe[peer], hash)
+						ltr.subscribed[peer] = struct{}{}
+						self.peerSubs[peer]++
+					}
 					cnt--
 				}
 				if cnt == 0 {
@@ -132,6 +151,31 @@ func (self *LesTxRelay) send(txs types.Transactions, count int) {
 		}
 		self.reqDist.queue(rq)
 	}
+	for p, hashes := range subscribe {
+		self.subscribe(p, hashes, nil)
+	}
+}
+
+// subscribe queues a request adding and removing transaction inclusion
+// subscriptions at a server.
+func (self *LesTxRelay) subscribe(p *peer, add, remove []common.Hash) {
+	reqID := genReqID()
+	count := len(add) + len(remove)
+	rq := &distReq{
+		getCost: func(dp distPeer) uint64 {
+			return dp.(*peer).GetRequestCost(SubscribeTxsMsg, count)
+		},
+		canSend: func(dp distPeer) bool {
+			return dp.(*peer) == p
+		},
+		request: func(dp distPeer) func() {
+			peer := dp.(*peer)
+			cost := peer.GetRequestCost(SubscribeTxsMsg, count)
+			peer.fcServer.QueueRequest(reqID, cost)
+			return func() { peer.SubscribeTxs(reqID, cost, add, remove) }
+		},
+	}
+	self.reqDist.queue(rq)
 }
 
 func (self *LesTxRelay) Send(txs types.Transactions) {
@@ -168,8 +212,53 @@ func (self *LesTxRelay) Discard(hashes []common.Hash) {
 	self.lock.Lock()
 	defer self.lock.Unlock()
 
+	unsubscribe := make(map[*peer][]common.Hash)
 	for _, hash := range hashes {
+		if ltr, ok := self.txSent[hash]; ok {
+			for p := range ltr.subscribed {
+				unsubscribe[p] = append(unsubscribe[p], hash)
+				self.peerSubs[p]--
+			}
+		}
 		delete(self.txSent, hash)
 		delete(self.txPending, hash)
 	}
+	for p, hashes := range unsubscribe {
+		self.subscribe(p, nil, hashes)
+	}
+}
+
+// SubscribeTxInclusions subscribes to the inclusion notifications received from
+// servers about the sent transactions (implementation of light.TxInclusionBackend).
+func (self *LesTxRelay) SubscribeTxInclusions(ch chan<- []light.TxInclusion) event.Subscription {
+	return self.inclusionFeed.Subscribe(ch)
+}
+
+// Watched returns whether a connected server has been asked to report the inclusion
+// of a transaction (implementation of light.TxInclusionBackend).
+func (self *LesTxRelay) Watched(hash common.Hash) bool {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	ltr, ok := self.txSent[hash]
+	return ok && len(ltr.subscribed) > 0
+}
+
+// deliverInclusions forwards the inclusion notifications received from a server
+// to the subscribers, dropping the ones about transactions not watched by it.
+func (self *LesTxRelay) deliverInclusions(p *peer, list []light.TxInclusion) {
+	self.lock.RLock()
+	watched := make([]light.TxInclusion, 0, len(list))
+	for _, inc := range list {
+		if ltr, ok := self.txSent[inc.Hash]; ok {
+			if _, ok := ltr.subscribed[p]; ok {
+				watched = append(watched, inc)
+			}
+		}
+	}
+	self.lock.RUnlock()
+
+	if len(watched) > 0 {
+		go self.inclusionFeed.Send(watched) // don't block message handling on the pool
+	}
 }
@@ -0,0 +1,146 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/hexutil"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/ethdb"
+)
+
+var (
+	errCheckpointGenesis  = errors.New("checkpoint belongs to a different chain")
+	errNoCheckpointSigner = errors.New("no trusted checkpoint signers configured")
+)
+
+// TrustedCheckpoint represents a set of post-processed trie roots (CHT and BloomTrie) associated with
+// the appropriate section index and head hash. It is used to start light syncing from this checkpoint
+// and avoid downloading the entire header chain while still being able to securely access old headers/logs.
+type TrustedCheckpoint struct {
+	Name        string      `json:"-" toml:",omitempty"`
+	SectionIdx  uint64      `json:"sectionIndex"`
+	SectionHead common.Hash `json:"sectionHead"`
+	ChtRoot     common.Hash `json:"chtRoot"`
+	BloomRoot   common.Hash `json:"bloomRoot"`
+}
+
+// Hash returns the hash identifying the contents of the checkpoint.
+func (cp *TrustedCheckpoint) Hash() common.Hash {
+	var section [8]byte
+	binary.BigEndian.PutUint64(section[:], cp.SectionIdx)
+	return crypto.Keccak256Hash(section[:], cp.SectionHead.Bytes(), cp.ChtRoot.Bytes(), cp.BloomRoot.Bytes())
+}
+
+// ReadTrustedCheckpoint assembles the checkpoint of a LES/2 sized section from the
+// CHT and BloomTrie roots stored in the database, returning nil if the section was
+// not yet processed by both indexers. Servers store their CHTs in LES/1 sections.
+func ReadTrustedCheckpoint(db ethdb.Database, section uint64, head common.Hash, server bool) *TrustedCheckpoint {
+	cp := &TrustedCheckpoint{
+		SectionIdx:  section,
+		SectionHead: head,
+		BloomRoot:   GetBloomTrieRoot(db, section, head),
+	}
+	if server {
+		cp.ChtRoot = GetChtV2Root(db, section, head)
+	} else {
+		cp.ChtRoot = GetChtRoot(db, section, head)
+	}
+	if cp.ChtRoot == (common.Hash{}) || cp.BloomRoot == (common.Hash{}) {
+		return nil
+	}
+	return cp
+}
+
+// SignedCheckpoint is a trusted checkpoint of a specific chain, endorsed by the
+// signatures of a set of checkpoint signers. It allows checkpoints of private
+// networks to be distributed without hard coding them into the client.
+type SignedCheckpoint struct {
+	Checkpoint TrustedCheckpoint `json:"checkpoint"`
+	Genesis    common.Hash       `json:"genesis"`
+	Signatures []hexutil.Bytes   `json:"signatures"`
+}
+
+// SigningHash returns the hash checkpoint signers sign, binding the checkpoint
+// to the genesis block of its chain.
+func (cp *SignedCheckpoint) SigningHash() common.Hash {
+	return crypto.Keccak256Hash(cp.Genesis.Bytes(), cp.Checkpoint.Hash().Bytes())
+}
+
+// Sign adds the signature of the given key to the checkpoint.
+func (cp *SignedCheckpoint) Sign(key *ecdsa.PrivateKey) error {
+	sig, err := crypto.Sign(cp.SigningHash().Bytes(), key)
+	if err != nil {
+		return err
+	}
+	cp.Signatures = append(cp.Signatures, sig)
+	return nil
+}
+
+// Signers recovers the addresses of the accounts that signed the checkpoint.
+func (cp *SignedCheckpoint) Signers() ([]common.Address, error) {
+	hash := cp.SigningHash()
+
+	signers := make([]common.Address, 0, len(cp.Signatures))
+	for i, sig := range cp.Signatures {
+		pubkey, err := crypto.SigToPub(hash.Bytes(), sig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature #%d: %v", i, err)
+		}
+		signers = append(signers, crypto.PubkeyToAddress(*pubkey))
+	}
+	return signers, nil
+}
+
+// Verify checks that the checkpoint belongs to the chain with the given genesis
+// and that it's signed by at least threshold distinct trusted signers. A zero
+// threshold requires the signatures of the majority of the trusted signers.
+func (cp *SignedCheckpoint) Verify(genesis common.Hash, trusted []common.Address, threshold int) error {
+	if cp.Genesis != genesis {
+		return errCheckpointGenesis
+	}
+	if len(trusted) == 0 {
+		return errNoCheckpointSigner
+	}
+	if threshold == 0 {
+		threshold = len(trusted)/2 + 1
+	}
+	if threshold < 0 || threshold > len(trusted) {
+		return fmt.Errorf("invalid checkpoint signature threshold %d for %d signers", threshold, len(trusted))
+	}
+	signers, err := cp.Signers()
+	if err != nil {
+		return err
+	}
+	approved := make(map[common.Address]bool)
+	for _, signer := range signers {
+		for _, addr := range trusted {
+			if signer == addr {
+				approved[signer] = true
+			}
+		}
+	}
+	if len(approved) < threshold {
+		return fmt.Errorf("checkpoint signed by %d trusted signers, %d required", len(approved), threshold)
+	}
+	return nil
+}
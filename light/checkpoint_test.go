@@ -0,0 +1,115 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"crypto/ecdsa"
+	"testing"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/ethdb"
+)
+
+// Tests that checkpoints are only accepted if signed by enough trusted signers
+// of the right chain.
+func TestSignedCheckpointVerify(t *testing.T) {
+	var (
+		keys    = make([]*ecdsa.PrivateKey, 4)
+		trusted = make([]common.Address, 3)
+		genesis = common.HexToHash("0x01")
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		if i < len(trusted) {
+			trusted[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+		}
+	}
+	sign := func(signers ...int) *SignedCheckpoint {
+		cp := &SignedCheckpoint{
+			Checkpoint: *mainnetCheckpoint,
+			Genesis:    genesis,
+		}
+		for _, i := range signers {
+			if err := cp.Sign(keys[i]); err != nil {
+				t.Fatalf("failed to sign checkpoint: %v", err)
+			}
+		}
+		return cp
+	}
+	tests := []struct {
+		signed    *SignedCheckpoint
+		genesis   common.Hash
+		trusted   []common.Address
+		threshold int
+		ok        bool
+	}{
+		{sign(0, 1), genesis, trusted, 0, true},           // majority
+		{sign(0), genesis, trusted, 0, false},             // minority
+		{sign(0), genesis, trusted, 1, true},              // explicit threshold
+		{sign(0, 0), genesis, trusted, 2, false},          // duplicate signatures
+		{sign(0, 3), genesis, trusted, 2, false},          // untrusted signer
+		{sign(0, 1, 2), genesis, trusted, 4, false},       // threshold above signer count
+		{sign(0, 1), common.Hash{}, trusted, 0, false},    // different chain
+		{sign(0, 1), genesis, nil, 0, false},              // no trusted signers
+		{sign(0, 1, 2, 3), genesis, trusted, 3, true},     // all trusted signers
+		{sign(0, 1, 2, 3), genesis, trusted[:1], 0, true}, // single trusted signer
+	}
+	for i, tt := range tests {
+		err := tt.signed.Verify(tt.genesis, tt.trusted, tt.threshold)
+		if (err == nil) != tt.ok {
+			t.Errorf("test %d: verification mismatch: have %v, want ok %v", i, err, tt.ok)
+		}
+	}
+	// Tampering with the checkpoint must invalidate the signatures
+	cp := sign(0, 1)
+	cp.Checkpoint.ChtRoot = common.Hash{}
+	if err := cp.Verify(genesis, trusted, 0); err == nil {
+		t.Errorf("tampered checkpoint accepted")
+	}
+}
+
+// Tests that checkpoints can be assembled from the roots stored by the indexers
+// of both light servers and clients.
+func TestReadTrustedCheckpoint(t *testing.T) {
+	var (
+		head  = common.HexToHash("0x01")
+		cht   = common.HexToHash("0x02")
+		bloom = common.HexToHash("0x03")
+	)
+	for _, server := range []bool{false, true} {
+		db := ethdb.NewMemDatabase()
+		if cp := ReadTrustedCheckpoint(db, 2, head, server); cp != nil {
+			t.Fatalf("server %v: checkpoint available without roots: %v", server, cp)
+		}
+		if server {
+			StoreChtRoot(db, 3*(CHTFrequencyClient/CHTFrequencyServer)-1, head, cht)
+		} else {
+			StoreChtRoot(db, 2, head, cht)
+		}
+		if cp := ReadTrustedCheckpoint(db, 2, head, server); cp != nil {
+			t.Fatalf("server %v: checkpoint available without bloom trie root: %v", server, cp)
+		}
+		StoreBloomTrieRoot(db, 2, head, bloom)
+
+		want := &TrustedCheckpoint{SectionIdx: 2, SectionHead: head, ChtRoot: cht, BloomRoot: bloom}
+		cp := ReadTrustedCheckpoint(db, 2, head, server)
+		if cp == nil || cp.Hash() != want.Hash() {
+			t.Errorf("server %v: checkpoint mismatch: have %v, want %v", server, cp, want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"github.com/genchain/go-genchain/consensus"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/log"
+)
+
+// TransitionVerifier is a consensus engine able to verify the chain state carried
+// by header seals (the N/P values and NP total difficulty of ethash) without
+// verifying the much more expensive proof-of-work itself.
+type TransitionVerifier interface {
+	consensus.Engine
+
+	// VerifyTransition checks that the seal related fields of a header correctly
+	// follow from the ones of its parent.
+	VerifyTransition(chain consensus.ChainReader, header, parent *types.Header) error
+}
+
+// fuzzyEngine is a consensus engine wrapper used by light clients that can't
+// afford verifying the proof-of-work of every header (e.g. mobile devices). It
+// fully verifies the seal of every interval-th header and of every CHT section
+// head (the checkpoint boundaries), but checks the N/P transitions and the NP
+// accumulation of all headers.
+//
+// Security notes:
+//   - The seal of a header is either verified deterministically or not at all,
+//     replacing the random sampling done by the header chain. A peer can thus
+//     feed at most interval-1 headers with forged proof-of-work on top of the
+//     last fully verified one, the next seal check rejecting the entire batch.
+//   - Since the N/P transitions and the NP accumulation are checked on every
+//     header, forged headers cannot claim more total difficulty than honest
+//     ones mined with the same timestamps, but they can still temporarily win
+//     the fork choice until the honest chain overtakes them. Light clients in
+//     this mode should treat the newest interval headers as unconfirmed.
+//   - Section heads are always fully verified, so checkpoints and the CHTs built
+//     on top of the header chain never include headers with unverified seals.
+type fuzzyEngine struct {
+	TransitionVerifier
+	interval uint64
+}
+
+// NewFuzzyEngine wraps a consensus engine into a light client verifier which only
+// verifies the full seal of every interval-th header and of checkpoint boundaries.
+// If the engine doesn't support verifying seal transitions, it's returned as is.
+func NewFuzzyEngine(engine consensus.Engine, interval uint64) consensus.Engine {
+	verifier, ok := engine.(TransitionVerifier)
+	if !ok || interval <= 1 {
+		if !ok {
+			log.Warn("Fuzzy PoW verification not supported by consensus engine")
+		}
+		return engine
+	}
+	log.Info("Enabled fuzzy PoW verification", "interval", interval)
+	return &fuzzyEngine{TransitionVerifier: verifier, interval: interval}
+}
+
+// fullSeal returns whether the seal of the header with the given number needs
+// to be fully verified.
+func (f *fuzzyEngine) fullSeal(number uint64) bool {
+	return number%f.interval == 0 || (number+1)%CHTFrequencyClient == 0
+}
+
+// VerifyHeader implements consensus.Engine, verifying the seal of the header only
+// if it's due for a full check, and its seal transition otherwise.
+func (f *fuzzyEngine) VerifyHeader(chain consensus.ChainReader, header *types.Header, seal bool) error {
+	number := header.Number.Uint64()
+	if err := f.TransitionVerifier.VerifyHeader(chain, header, f.fullSeal(number)); err != nil {
+		return err
+	}
+	if chain.GetHeader(header.Hash(), number) != nil {
+		return nil
+	}
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	return f.VerifyTransition(chain, header, parent)
+}
+
+// VerifyHeaders implements consensus.Engine, verifying the seal of the headers
+// due for a full check and the seal transitions of all of them.
+func (f *fuzzyEngine) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
+	full := make([]bool, len(headers))
+	for i, header := range headers {
+		full[i] = f.fullSeal(header.Number.Uint64())
+	}
+	abort, results := f.TransitionVerifier.VerifyHeaders(chain, headers, full)
+
+	quit, errs := make(chan struct{}), make(chan error, len(headers))
+	go func() {
+		defer close(abort)
+
+		for i := range headers {
+			var err error
+			select {
+			case err = <-results:
+			case <-quit:
+				return
+			}
+			if err == nil {
+				err = f.verifyTransition(chain, headers, i)
+			}
+			errs <- err
+		}
+	}()
+	return quit, errs
+}
+
+// verifyTransition checks the seal transition of a header within a batch, taking
+// its parent either from the batch or from the chain.
+func (f *fuzzyEngine) verifyTransition(chain consensus.ChainReader, headers []*types.Header, index int) error {
+	header := headers[index]
+	if chain.GetHeader(header.Hash(), header.Number.Uint64()) != nil {
+		return nil // known header
+	}
+	var parent *types.Header
+	if index > 0 && headers[index-1].Hash() == header.ParentHash {
+		parent = headers[index-1]
+	} else {
+		parent = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	return f.VerifyTransition(chain, header, parent)
+}
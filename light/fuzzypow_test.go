@@ -0,0 +1,186 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/genchain/go-genchain/consensus"
+	"github.com/genchain/go-genchain/consensus/ethash"
+	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/params"
+)
+
+var errTestTransition = errors.New("invalid test transition")
+
+// transitionTester is a consensus engine recording the headers whose seal and
+// seal transition were verified, failing the transition of a specific header.
+type transitionTester struct {
+	consensus.Engine
+	fail uint64
+
+	lock        sync.Mutex
+	seals       map[uint64]bool
+	transitions map[uint64]bool
+}
+
+func newTransitionTester(fail uint64) *transitionTester {
+	return &transitionTester{
+		Engine:      ethash.NewFaker(),
+		fail:        fail,
+		seals:       make(map[uint64]bool),
+		transitions: make(map[uint64]bool),
+	}
+}
+
+func (t *transitionTester) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
+	t.lock.Lock()
+	for i, header := range headers {
+		t.seals[header.Number.Uint64()] = seals[i]
+	}
+	t.lock.Unlock()
+	return t.Engine.VerifyHeaders(chain, headers, seals)
+}
+
+func (t *transitionTester) VerifyTransition(chain consensus.ChainReader, header, parent *types.Header) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if header.ParentHash != parent.Hash() {
+		return consensus.ErrUnknownAncestor
+	}
+	t.transitions[header.Number.Uint64()] = true
+	if header.Number.Uint64() == t.fail {
+		return errTestTransition
+	}
+	return nil
+}
+
+// makeFuzzyHeaders creates a chain of headers rooted at parent, valid apart from
+// their seals.
+func makeFuzzyHeaders(parent *types.Header, n int) []*types.Header {
+	headers := make([]*types.Header, n)
+	for i := range headers {
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			UncleHash:  types.EmptyUncleHash,
+			Number:     new(big.Int).Add(parent.Number, big.NewInt(1)),
+			GasLimit:   parent.GasLimit,
+			Time:       new(big.Int).Add(parent.Time, big.NewInt(10)),
+			Rewards:    new(big.Int),
+			Alpha:      new(big.Int),
+			NP:         new(big.Int),
+		}
+		header.Difficulty = ethash.CalcDifficulty(params.TestChainConfig, header.Time.Uint64(), parent)
+		headers[i], parent = header, header
+	}
+	return headers
+}
+
+// newFuzzyChain creates a light chain verifying headers through a fuzzy engine
+// wrapping the given one.
+func newFuzzyChain(t *testing.T, engine consensus.Engine, interval uint64) (*LightChain, *types.Header) {
+	db := ethdb.NewMemDatabase()
+	gspec := core.Genesis{Config: params.TestChainConfig}
+	genesis := gspec.MustCommit(db)
+
+	lc, err := NewLightChain(&dummyOdr{db: db}, gspec.Config, NewFuzzyEngine(engine, interval))
+	if err != nil {
+		t.Fatalf("failed to create light chain: %v", err)
+	}
+	return lc, genesis.Header()
+}
+
+// Tests that the fuzzy engine only verifies the full seal of every interval-th
+// header, but checks the seal transitions of all of them.
+func TestFuzzyPoWVerification(t *testing.T) {
+	tester := newTransitionTester(0)
+	lc, genesis := newFuzzyChain(t, tester, 4)
+
+	headers := makeFuzzyHeaders(genesis, 18)
+	if _, err := lc.InsertHeaderChain(headers[:10], 1); err != nil {
+		t.Fatalf("failed to insert headers: %v", err)
+	}
+	// Insert the remaining headers one by one, as announced by the fetcher
+	for _, header := range headers[10:] {
+		if err := lc.engine.VerifyHeader(lc.hc, header, true); err != nil {
+			t.Fatalf("failed to verify header #%d: %v", header.Number, err)
+		}
+		if _, err := lc.InsertHeaderChain([]*types.Header{header}, 1); err != nil {
+			t.Fatalf("failed to insert header #%d: %v", header.Number, err)
+		}
+	}
+	for number := uint64(1); number <= 18; number++ {
+		if want := number%4 == 0; tester.seals[number] != want {
+			t.Errorf("header #%d: full seal verification mismatch: have %v, want %v", number, tester.seals[number], want)
+		}
+		if !tester.transitions[number] {
+			t.Errorf("header #%d: seal transition not verified", number)
+		}
+	}
+	if head := lc.CurrentHeader().Number.Uint64(); head != 18 {
+		t.Errorf("head mismatch: have #%d, want #18", head)
+	}
+}
+
+// Tests that headers with invalid seal transitions are rejected even if their
+// full seal is not verified.
+func TestFuzzyPoWInvalidTransition(t *testing.T) {
+	tester := newTransitionTester(7)
+	lc, genesis := newFuzzyChain(t, tester, 4)
+
+	headers := makeFuzzyHeaders(genesis, 10)
+	n, err := lc.InsertHeaderChain(headers, 1)
+	if err != errTestTransition {
+		t.Fatalf("error mismatch: have %v, want %v", err, errTestTransition)
+	}
+	if n != 6 {
+		t.Errorf("failing header index mismatch: have %d, want %d", n, 6)
+	}
+	if head := lc.CurrentHeader().Number.Uint64(); head != 0 {
+		t.Errorf("head mismatch: have #%d, want #0", head)
+	}
+}
+
+// Tests that checkpoint boundaries always have their seals fully verified.
+func TestFuzzyPoWCheckpointSeals(t *testing.T) {
+	engine := NewFuzzyEngine(newTransitionTester(0), 1000).(*fuzzyEngine)
+
+	for section := uint64(0); section < 4; section++ {
+		head := (section+1)*CHTFrequencyClient - 1
+		if !engine.fullSeal(head) {
+			t.Errorf("section %d: head #%d seal not verified", section, head)
+		}
+		if engine.fullSeal(head - 1) {
+			t.Errorf("section %d: header #%d seal verified", section, head-1)
+		}
+	}
+	// Engines without seal transition support and disabled intervals are not wrapped
+	faker := ethash.NewFaker()
+	if engine := NewFuzzyEngine(faker, 1); engine != consensus.Engine(faker) {
+		t.Errorf("engine wrapped with full verification interval")
+	}
+	tester := &struct{ consensus.Engine }{faker}
+	if engine := NewFuzzyEngine(tester, 4); engine != consensus.Engine(tester) {
+		t.Errorf("engine without transition verification wrapped")
+	}
+}
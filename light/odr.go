@@ -129,6 +129,24 @@ func (req *ReceiptsRequest) StoreResult(db ethdb.Database) {
 	rawdb.WriteReceipts(db, req.Hash, req.Number, req.Receipts)
 }
 
+// TxStatus is the status of a transaction as reported by a server. The lookup
+// entry of included transactions is unverified.
+type TxStatus struct {
+	Status core.TxStatus
+	Lookup *rawdb.TxLookupEntry `rlp:"nil"`
+	Error  string
+}
+
+// TxStatusRequest is the ODR request type for retrieving transaction status
+type TxStatusRequest struct {
+	OdrRequest
+	Hashes []common.Hash
+	Status []TxStatus
+}
+
+// StoreResult does nothing, the reported status needs further verification
+func (req *TxStatusRequest) StoreResult(db ethdb.Database) {}
+
 // ChtRequest is the ODR request type for state/storage trie entries
 type ChtRequest struct {
 	OdrRequest
@@ -173,6 +173,41 @@ func GetBlockLogs(ctx context.Context, odr OdrBackend, hash common.Hash, number
 	return logs, nil
 }
 
+// GetTransaction retrieves a canonical transaction along with the hash and number
+// of its block and its index within. Transactions unknown locally are looked up
+// through a server, and only returned if found in the retrieved canonical block.
+func GetTransaction(ctx context.Context, odr OdrBackend, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
+	db := odr.Database()
+	if tx, blockHash, number, index := rawdb.ReadTransaction(db, txHash); tx != nil {
+		return tx, blockHash, number, index, nil
+	}
+	r := &TxStatusRequest{Hashes: []common.Hash{txHash}}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return nil, common.Hash{}, 0, 0, err
+	}
+	lookup := r.Status[0].Lookup
+	if r.Status[0].Status != core.TxStatusIncluded || lookup == nil {
+		return nil, common.Hash{}, 0, 0, nil
+	}
+	// Verify the reported position against the canonical chain
+	header, err := GetHeaderByNumber(ctx, odr, lookup.BlockIndex)
+	if err != nil {
+		return nil, common.Hash{}, 0, 0, err
+	}
+	if header.Hash() != lookup.BlockHash {
+		return nil, common.Hash{}, 0, 0, nil
+	}
+	block, err := GetBlock(ctx, odr, lookup.BlockHash, lookup.BlockIndex)
+	if err != nil {
+		return nil, common.Hash{}, 0, 0, err
+	}
+	txs := block.Transactions()
+	if lookup.Index >= uint64(len(txs)) || txs[lookup.Index].Hash() != txHash {
+		return nil, common.Hash{}, 0, 0, nil
+	}
+	return txs[lookup.Index], lookup.BlockHash, lookup.BlockIndex, lookup.Index, nil
+}
+
 // GetBloomBits retrieves a batch of compressed bloomBits vectors belonging to the given bit index and section indexes
 func GetBloomBits(ctx context.Context, odr OdrBackend, bitIdx uint, sectionIdxList []uint64) ([][]byte, error) {
 	db := odr.Database()
@@ -61,10 +61,14 @@ type TxPool struct {
 	odr          OdrBackend
 	chainDb      ethdb.Database
 	relay        TxRelayBackend
+	inclusions   TxInclusionBackend // nil if the relay doesn't report inclusions
+	inclusionCh  chan []TxInclusion
+	inclusionSub event.Subscription
 	head         common.Hash
 	nonce        map[common.Address]uint64            // "pending" nonce
 	pending      map[common.Hash]*types.Transaction   // pending transactions by tx hash
 	mined        map[common.Hash][]*types.Transaction // mined transactions by block hash
+	included     map[common.Hash]uint64               // numbers of blocks reported to include local transactions
 	clearIdx     uint64                               // earliest block nr that can contain mined tx info
 
 	homestead bool
@@ -85,6 +89,32 @@ type TxRelayBackend interface {
 	Discard(hashes []common.Hash)
 }
 
+// TxInclusion is a notification about a transaction being included in a
+// canonical block.
+type TxInclusion struct {
+	Hash        common.Hash // Hash of the included transaction
+	BlockHash   common.Hash // Hash of the block including the transaction
+	BlockNumber uint64      // Number of the block including the transaction
+	Index       uint64      // Position of the transaction within the block
+}
+
+// TxInclusionBackend is an optional extension of TxRelayBackend, implemented by
+// relays able to get notified by servers about the inclusion of the transactions
+// they have forwarded.
+//
+// As long as all pending transactions are watched, the pool only retrieves the
+// blocks it has been notified about instead of every new block. Notifications are
+// only hints, transactions are always looked up in the retrieved block bodies.
+type TxInclusionBackend interface {
+	// SubscribeTxInclusions subscribes to the inclusion notifications received
+	// for forwarded transactions.
+	SubscribeTxInclusions(ch chan<- []TxInclusion) event.Subscription
+
+	// Watched returns whether a server has been asked to report the inclusion of
+	// the given transaction.
+	Watched(hash common.Hash) bool
+}
+
 // NewTxPool creates a new light transaction pool
 func NewTxPool(config *params.ChainConfig, chain *LightChain, relay TxRelayBackend) *TxPool {
 	pool := &TxPool{
@@ -93,6 +123,7 @@ func NewTxPool(config *params.ChainConfig, chain *LightChain, relay TxRelayBacke
 		nonce:       make(map[common.Address]uint64),
 		pending:     make(map[common.Hash]*types.Transaction),
 		mined:       make(map[common.Hash][]*types.Transaction),
+		included:    make(map[common.Hash]uint64),
 		quit:        make(chan bool),
 		chainHeadCh: make(chan core.ChainHeadEvent, chainHeadChanSize),
 		chain:       chain,
@@ -104,6 +135,11 @@ func NewTxPool(config *params.ChainConfig, chain *LightChain, relay TxRelayBacke
 	}
 	// Subscribe events from blockchain
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
+	if inclusions, ok := relay.(TxInclusionBackend); ok {
+		pool.inclusions = inclusions
+		pool.inclusionCh = make(chan []TxInclusion, chainHeadChanSize)
+		pool.inclusionSub = inclusions.SubscribeTxInclusions(pool.inclusionCh)
+	}
 	go pool.eventLoop()
 
 	return pool
@@ -167,6 +203,10 @@ func (pool *TxPool) checkMinedTxs(ctx context.Context, hash common.Hash, number
 	if len(pool.pending) == 0 {
 		return nil
 	}
+	// If servers report the inclusion of all of them, only check reported blocks
+	if _, ok := pool.included[hash]; !ok && pool.allWatched() {
+		return nil
+	}
 	block, err := GetBlock(ctx, pool.odr, hash, number)
 	if err != nil {
 		return err
@@ -193,9 +233,24 @@ func (pool *TxPool) checkMinedTxs(ctx context.Context, hash common.Hash, number
 		}
 		pool.mined[hash] = list
 	}
+	delete(pool.included, hash)
 	return nil
 }
 
+// allWatched returns whether the inclusion of all pending transactions is
+// reported by the relay backend.
+func (pool *TxPool) allWatched() bool {
+	if pool.inclusions == nil {
+		return false
+	}
+	for hash := range pool.pending {
+		if !pool.inclusions.Watched(hash) {
+			return false
+		}
+	}
+	return true
+}
+
 // rollbackTxs marks the transactions contained in recently rolled back blocks
 // as rolled back. It also removes any positional lookup entries.
 func (pool *TxPool) rollbackTxs(hash common.Hash, txc txStateChanges) {
@@ -269,6 +324,11 @@ func (pool *TxPool) reorgOnNewHead(ctx context.Context, newHeader *types.Header)
 				}
 			}
 		}
+		for hash, number := range pool.included {
+			if number < idx2 {
+				delete(pool.included, hash)
+			}
+		}
 		pool.clearIdx = idx2
 	}
 
@@ -290,6 +350,9 @@ func (pool *TxPool) eventLoop() {
 			// be replaced by a subsequent PR.
 			time.Sleep(time.Millisecond)
 
+		case list := <-pool.inclusionCh:
+			pool.newInclusions(list)
+
 		// System stopped
 		case <-pool.chainHeadSub.Err():
 			return
@@ -311,12 +374,42 @@ func (pool *TxPool) setNewHead(head *types.Header) {
 	pool.signer = types.MakeSigner(pool.config, head.Number)
 }
 
+// newInclusions processes transaction inclusion notifications. Blocks already
+// processed as part of the canonical chain are checked right away, the others
+// are remembered and checked when they are reached by a new head.
+func (pool *TxPool) newInclusions(list []TxInclusion) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), blockCheckTimeout)
+	defer cancel()
+
+	head := pool.chain.GetHeaderByHash(pool.head)
+	txc := make(txStateChanges)
+	for _, inc := range list {
+		pool.included[inc.BlockHash] = inc.BlockNumber
+
+		if head == nil || inc.BlockNumber > head.Number.Uint64() || rawdb.ReadCanonicalHash(pool.chainDb, inc.BlockNumber) != inc.BlockHash {
+			continue
+		}
+		if err := pool.checkMinedTxs(ctx, inc.BlockHash, inc.BlockNumber, txc); err != nil {
+			log.Debug("Failed to check reported block", "number", inc.BlockNumber, "hash", inc.BlockHash, "err", err)
+		}
+	}
+	if m, r := txc.getLists(); len(m) > 0 || len(r) > 0 {
+		pool.relay.NewHead(pool.head, m, r)
+	}
+}
+
 // Stop stops the light transaction pool
 func (pool *TxPool) Stop() {
 	// Unsubscribe all subscriptions registered from txpool
 	pool.scope.Close()
 	// Unsubscribe subscriptions registered from blockchain
 	pool.chainHeadSub.Unsubscribe()
+	if pool.inclusionSub != nil {
+		pool.inclusionSub.Unsubscribe()
+	}
 	close(pool.quit)
 	log.Info("Transaction pool stopped")
 }
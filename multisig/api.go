@@ -0,0 +1,99 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package multisig
+
+import (
+	"context"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/hexutil"
+)
+
+// ProposeArgs represents the arguments to propose a wallet transaction.
+type ProposeArgs struct {
+	Wallet      common.Address `json:"wallet"`
+	ABI         string         `json:"abi"` // JSON interface of the wallet contract
+	Destination common.Address `json:"destination"`
+	Value       *hexutil.Big   `json:"value"`
+	Data        hexutil.Bytes  `json:"data"`
+	Nonce       *hexutil.Big   `json:"nonce"` // Wallet nonce, the current one if nil
+}
+
+// PrivateMultisigAPI provides an API to coordinate the transactions of
+// multi-signature wallets with the local accounts of their owners.
+type PrivateMultisigAPI struct {
+	s *Service
+}
+
+// NewPrivateMultisigAPI creates a new multisig API.
+func NewPrivateMultisigAPI(s *Service) *PrivateMultisigAPI {
+	return &PrivateMultisigAPI{s}
+}
+
+// Propose creates a pending transaction of a wallet, bound with the given
+// contract interface. Proposing the same transaction again returns the existing
+// proposal.
+func (api *PrivateMultisigAPI) Propose(ctx context.Context, args ProposeArgs) (*Proposal, error) {
+	value := new(hexutil.Big)
+	if args.Value != nil {
+		value = args.Value
+	}
+	return api.s.propose(ctx, args.Wallet, args.ABI, args.Destination, value.ToInt(), args.Data, args.Nonce.ToInt())
+}
+
+// Import stores a proposal exported from another node, along with its valid
+// signatures, so that the local owners can approve it.
+func (api *PrivateMultisigAPI) Import(ctx context.Context, proposal Proposal) (*Proposal, error) {
+	return api.s.importProposal(ctx, &proposal)
+}
+
+// List returns the proposals of a wallet, or of all wallets if none is given.
+func (api *PrivateMultisigAPI) List(wallet *common.Address) ([]*Proposal, error) {
+	return api.s.list(wallet)
+}
+
+// Get returns a proposal.
+func (api *PrivateMultisigAPI) Get(id common.Hash) (*Proposal, error) {
+	return api.s.get(id)
+}
+
+// Approve signs a pending proposal with the account of a wallet owner. The
+// account must be unlocked, unless the passphrase is given.
+func (api *PrivateMultisigAPI) Approve(ctx context.Context, id common.Hash, owner common.Address, passphrase *string) (*Proposal, error) {
+	return api.s.approve(ctx, id, owner, passphrase)
+}
+
+// AddSignature adds the signature of a wallet owner made elsewhere to a pending
+// proposal.
+func (api *PrivateMultisigAPI) AddSignature(ctx context.Context, id common.Hash, signature hexutil.Bytes) (*Proposal, error) {
+	return api.s.sign(ctx, id, signature)
+}
+
+// Submit sends the transaction executing a proposal approved by the threshold
+// of owners, from a local account. The account must be unlocked, unless the
+// passphrase is given. It returns the hash of the transaction.
+//
+// A submitted proposal can be submitted again once its previous transaction is
+// no longer pending without having executed it, e.g. if it was dropped.
+func (api *PrivateMultisigAPI) Submit(ctx context.Context, id common.Hash, from common.Address, passphrase *string) (common.Hash, error) {
+	return api.s.submit(ctx, id, from, passphrase)
+}
+
+// Discard deletes a proposal.
+func (api *PrivateMultisigAPI) Discard(id common.Hash) error {
+	return api.s.discard(id)
+}
@@ -0,0 +1,119 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package multisig
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/genchain/go-genchain"
+	"github.com/genchain/go-genchain/accounts/abi/bind"
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/hexutil"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/internal/ethapi"
+	"github.com/genchain/go-genchain/rpc"
+)
+
+// Backend is the chain access needed to read the multisig contracts and to
+// submit their transactions.
+type Backend interface {
+	bind.ContractCaller
+	bind.ContractTransactor
+
+	// ChainID returns the chain id to sign transactions with, or nil if replay
+	// protection is not active yet.
+	ChainID() *big.Int
+
+	// TransactionPending reports whether a transaction is waiting in the
+	// transaction pool.
+	TransactionPending(ctx context.Context, hash common.Hash) (bool, error)
+}
+
+// apiBackend implements Backend on top of the API backend of a full or light
+// node.
+type apiBackend struct {
+	b     ethapi.Backend
+	chain *ethapi.PublicBlockChainAPI
+}
+
+func newAPIBackend(b ethapi.Backend) *apiBackend {
+	return &apiBackend{b: b, chain: ethapi.NewPublicBlockChainAPI(b)}
+}
+
+func (b *apiBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return b.chain.GetCode(ctx, contract, toBlockNumber(blockNumber))
+}
+
+func (b *apiBackend) CallContract(ctx context.Context, call genchain.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return b.chain.Call(ctx, toCallArgs(call), toBlockNumber(blockNumber))
+}
+
+func (b *apiBackend) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return b.chain.GetCode(ctx, account, rpc.PendingBlockNumber)
+}
+
+func (b *apiBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return b.b.GetPoolNonce(ctx, account)
+}
+
+func (b *apiBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return b.b.SuggestPrice(ctx)
+}
+
+func (b *apiBackend) EstimateGas(ctx context.Context, call genchain.CallMsg) (uint64, error) {
+	gas, err := b.chain.EstimateGas(ctx, toCallArgs(call))
+	return uint64(gas), err
+}
+
+func (b *apiBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	return b.b.SendTx(ctx, tx)
+}
+
+func (b *apiBackend) ChainID() *big.Int {
+	if config := b.b.ChainConfig(); config.IsEIP155(b.b.CurrentBlock().Number()) {
+		return config.ChainId
+	}
+	return nil
+}
+
+func (b *apiBackend) TransactionPending(ctx context.Context, hash common.Hash) (bool, error) {
+	return b.b.GetPoolTransaction(hash) != nil, nil
+}
+
+func toBlockNumber(number *big.Int) rpc.BlockNumber {
+	if number == nil {
+		return rpc.LatestBlockNumber
+	}
+	return rpc.BlockNumber(number.Int64())
+}
+
+func toCallArgs(call genchain.CallMsg) ethapi.CallArgs {
+	args := ethapi.CallArgs{
+		From: call.From,
+		To:   call.To,
+		Gas:  hexutil.Uint64(call.Gas),
+		Data: call.Data,
+	}
+	if call.GasPrice != nil {
+		args.GasPrice = hexutil.Big(*call.GasPrice)
+	}
+	if call.Value != nil {
+		args.Value = hexutil.Big(*call.Value)
+	}
+	return args
+}
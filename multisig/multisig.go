@@ -0,0 +1,617 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package multisig implements a service coordinating the signatures of the
+// owners of multi-signature wallet contracts.
+//
+// A proposer creates a pending transaction for a wallet, the owners approve it
+// by signing its hash with their local accounts and, once the threshold of the
+// wallet is met, anyone submits it to the contract with the collected
+// signatures. The pending transactions persist in the chain database.
+//
+// The wallet contracts are bound with the ABI given along with each proposal,
+// which must define the threshold, nonce, isOwner and execute methods of the
+// SimpleMultiSig contracts: the owners sign, without the Ethereum message
+// prefix, the hash
+//
+//	keccak256(0x19, 0x00, wallet, destination, value, data, nonce)
+//
+// and execute verifies that the signatures are from distinct owners, in
+// ascending order of owner address.
+package multisig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/genchain/go-genchain/accounts"
+	"github.com/genchain/go-genchain/accounts/abi"
+	"github.com/genchain/go-genchain/accounts/abi/bind"
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/hexutil"
+	"github.com/genchain/go-genchain/common/math"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/ethdb"
+	"github.com/genchain/go-genchain/internal/ethapi"
+	"github.com/genchain/go-genchain/log"
+	"github.com/genchain/go-genchain/p2p"
+	"github.com/genchain/go-genchain/rpc"
+)
+
+// walletMethods are the methods the wallet contracts must define.
+var walletMethods = []string{"threshold", "nonce", "isOwner", "execute"}
+
+var (
+	proposalPrefix = []byte("multisig-p") // proposalPrefix + id -> proposal
+	proposalIndex  = []byte("multisig-i") // proposalIndex -> ids of the stored proposals
+)
+
+var (
+	errUnknownProposal = errors.New("unknown proposal")
+	errSubmitted       = errors.New("proposal already submitted")
+	errPending         = errors.New("proposal submission still pending")
+)
+
+// Signature is the signature of a proposal by one of the wallet owners.
+type Signature struct {
+	Signer    common.Address `json:"signer"`
+	Signature hexutil.Bytes  `json:"signature"` // [R || S || V] with V 0 or 1
+}
+
+// Proposal is a transaction of a wallet pending the signatures of its owners.
+type Proposal struct {
+	ID          common.Hash    `json:"id"` // Hash signed by the owners
+	Wallet      common.Address `json:"wallet"`
+	ABI         string         `json:"abi"` // JSON interface of the wallet contract
+	Destination common.Address `json:"destination"`
+	Value       *hexutil.Big   `json:"value"`
+	Data        hexutil.Bytes  `json:"data"`
+	Nonce       *hexutil.Big   `json:"nonce"` // Wallet nonce the transaction executes at
+	Signatures  []Signature    `json:"signatures"`
+	Transaction *common.Hash   `json:"transaction"` // Hash of the submission, nil if pending
+}
+
+// hash computes the hash the owners sign to approve the proposal.
+func (p *Proposal) hash() common.Hash {
+	return crypto.Keccak256Hash(
+		[]byte{0x19, 0x00},
+		p.Wallet.Bytes(),
+		p.Destination.Bytes(),
+		math.PaddedBigBytes(math.U256(new(big.Int).Set(p.Value.ToInt())), 32),
+		p.Data,
+		math.PaddedBigBytes(math.U256(new(big.Int).Set(p.Nonce.ToInt())), 32),
+	)
+}
+
+// signed reports whether the owner already signed the proposal.
+func (p *Proposal) signed(owner common.Address) bool {
+	for _, sig := range p.Signatures {
+		if sig.Signer == owner {
+			return true
+		}
+	}
+	return false
+}
+
+// Service is a node service coordinating the transactions of multi-signature
+// wallets between their owners.
+type Service struct {
+	am      *accounts.Manager
+	db      ethdb.Database
+	backend Backend
+
+	lock sync.Mutex // Serializes the updates of the stored proposals
+}
+
+// New creates a multisig service signing with the accounts and storing the
+// proposals in the database of the given full or light node backend.
+func New(backend ethapi.Backend) *Service {
+	return newService(backend.AccountManager(), backend.ChainDb(), newAPIBackend(backend))
+}
+
+func newService(am *accounts.Manager, db ethdb.Database, backend Backend) *Service {
+	return &Service{am: am, db: db, backend: backend}
+}
+
+// Protocols implements node.Service, returning the P2P network protocols used
+// by the multisig service (nil as it doesn't use the devp2p overlay network).
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the RPC API endpoints provided by the
+// multisig service.
+func (s *Service) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "multisig",
+			Version:   "1.0",
+			Service:   NewPrivateMultisigAPI(s),
+			Public:    false,
+		},
+	}
+}
+
+// Start implements node.Service, doing nothing as the service has no background
+// tasks.
+func (s *Service) Start(server *p2p.Server) error {
+	log.Info("Multisig coordination service started")
+	return nil
+}
+
+// Stop implements node.Service, doing nothing as the service has no background
+// tasks.
+func (s *Service) Stop() error {
+	return nil
+}
+
+// propose stores a new proposal for the wallet with the given interface,
+// executing at the given wallet nonce or, if nil, at the current one.
+func (s *Service) propose(ctx context.Context, wallet common.Address, walletABI string, destination common.Address, value *big.Int, data []byte, nonce *big.Int) (*Proposal, error) {
+	contract, err := s.bind(wallet, walletABI)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.threshold(ctx, contract); err != nil {
+		return nil, fmt.Errorf("not a multisig wallet: %v", err)
+	}
+	if nonce == nil {
+		if nonce, err = s.nonce(ctx, contract); err != nil {
+			return nil, err
+		}
+	}
+	p := &Proposal{
+		Wallet:      wallet,
+		ABI:         walletABI,
+		Destination: destination,
+		Value:       (*hexutil.Big)(value),
+		Data:        common.CopyBytes(data),
+		Nonce:       (*hexutil.Big)(nonce),
+		Signatures:  []Signature{},
+	}
+	p.ID = p.hash()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if old, err := s.load(p.ID); err != nil {
+		return nil, err
+	} else if old != nil {
+		return old, nil
+	}
+	if err := s.store(p); err != nil {
+		return nil, err
+	}
+	log.Info("Created multisig proposal", "id", p.ID, "wallet", wallet, "nonce", nonce)
+	return p, nil
+}
+
+// importProposal stores a proposal received from another node, keeping the
+// valid signatures of owners.
+func (s *Service) importProposal(ctx context.Context, p *Proposal) (*Proposal, error) {
+	if p.Value == nil || p.Nonce == nil {
+		return nil, errors.New("missing value or nonce")
+	}
+	imported := &Proposal{
+		Wallet:      p.Wallet,
+		ABI:         p.ABI,
+		Destination: p.Destination,
+		Value:       p.Value,
+		Data:        p.Data,
+		Nonce:       p.Nonce,
+		Signatures:  []Signature{},
+	}
+	imported.ID = imported.hash()
+	if p.ID != (common.Hash{}) && p.ID != imported.ID {
+		return nil, fmt.Errorf("proposal id mismatch: have %x, want %x", p.ID, imported.ID)
+	}
+	contract, err := s.bind(p.Wallet, p.ABI)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.threshold(ctx, contract); err != nil {
+		return nil, fmt.Errorf("not a multisig wallet: %v", err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if old, err := s.load(imported.ID); err != nil {
+		return nil, err
+	} else if old != nil {
+		// Keep the stored interface, the signatures are checked against it
+		imported = old
+		if contract, err = s.bind(old.Wallet, old.ABI); err != nil {
+			return nil, err
+		}
+	}
+	for _, sig := range p.Signatures {
+		if err := s.addSignature(ctx, contract, imported, sig.Signature); err != nil {
+			log.Debug("Dropped imported multisig signature", "id", imported.ID, "signer", sig.Signer, "err", err)
+		}
+	}
+	if err := s.store(imported); err != nil {
+		return nil, err
+	}
+	return imported, nil
+}
+
+// approve signs the proposal with a local account of an owner.
+func (s *Service) approve(ctx context.Context, id common.Hash, owner common.Address, passphrase *string) (*Proposal, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	p, err := s.pending(id)
+	if err != nil {
+		return nil, err
+	}
+	if p.signed(owner) {
+		return p, nil
+	}
+	contract, err := s.bind(p.Wallet, p.ABI)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkOwner(ctx, contract, owner); err != nil {
+		return nil, err
+	}
+	account := accounts.Account{Address: owner}
+	wallet, err := s.am.Find(account)
+	if err != nil {
+		return nil, err
+	}
+	var sig []byte
+	if passphrase != nil {
+		sig, err = wallet.SignHashWithPassphrase(account, *passphrase, p.ID[:])
+	} else {
+		sig, err = wallet.SignHash(account, p.ID[:])
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.addSignature(ctx, contract, p, sig); err != nil {
+		return nil, err
+	}
+	if err := s.store(p); err != nil {
+		return nil, err
+	}
+	log.Info("Approved multisig proposal", "id", id, "owner", owner, "signatures", len(p.Signatures))
+	return p, nil
+}
+
+// sign adds a signature made elsewhere to the proposal.
+func (s *Service) sign(ctx context.Context, id common.Hash, sig []byte) (*Proposal, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	p, err := s.pending(id)
+	if err != nil {
+		return nil, err
+	}
+	contract, err := s.bind(p.Wallet, p.ABI)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.addSignature(ctx, contract, p, sig); err != nil {
+		return nil, err
+	}
+	if err := s.store(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// addSignature verifies that the signature is from an owner of the wallet and
+// adds it to the proposal. V may be 27 or 28 as well as 0 or 1.
+func (s *Service) addSignature(ctx context.Context, contract *bind.BoundContract, p *Proposal, sig []byte) error {
+	if len(sig) != 65 {
+		return fmt.Errorf("invalid signature length %d", len(sig))
+	}
+	sig = common.CopyBytes(sig)
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	pubkey, err := crypto.SigToPub(p.ID[:], sig)
+	if err != nil {
+		return err
+	}
+	signer := crypto.PubkeyToAddress(*pubkey)
+	if p.signed(signer) {
+		return nil
+	}
+	if err := s.checkOwner(ctx, contract, signer); err != nil {
+		return err
+	}
+	p.Signatures = append(p.Signatures, Signature{Signer: signer, Signature: sig})
+	sort.Slice(p.Signatures, func(i, j int) bool {
+		return bytes.Compare(p.Signatures[i].Signer[:], p.Signatures[j].Signer[:]) < 0
+	})
+	return nil
+}
+
+// submit sends the execution of a proposal signed by enough owners from a local
+// account. A submitted proposal is sent again if its previous transaction left
+// the pool without executing it, e.g. because it was dropped or it failed.
+func (s *Service) submit(ctx context.Context, id common.Hash, from common.Address, passphrase *string) (common.Hash, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	p, err := s.load(id)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if p == nil {
+		return common.Hash{}, errUnknownProposal
+	}
+	if p.Transaction != nil {
+		pending, err := s.backend.TransactionPending(ctx, *p.Transaction)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		if pending {
+			return common.Hash{}, errPending
+		}
+	}
+	contract, err := s.bind(p.Wallet, p.ABI)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	threshold, err := s.threshold(ctx, contract)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if have := big.NewInt(int64(len(p.Signatures))); have.Cmp(threshold) < 0 {
+		return common.Hash{}, fmt.Errorf("threshold not met: have %d signatures, want %d", have, threshold)
+	}
+	nonce, err := s.nonce(ctx, contract)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if nonce.Cmp(p.Nonce.ToInt()) != 0 {
+		if p.Transaction != nil {
+			return common.Hash{}, errSubmitted
+		}
+		return common.Hash{}, fmt.Errorf("wallet nonce mismatch: have %v, want %v", nonce, p.Nonce.ToInt())
+	}
+	// The signatures are sorted by owner, execute takes exactly threshold of them
+	count := int(threshold.Int64())
+	var (
+		sigV = make([]uint8, count)
+		sigR = make([][32]byte, count)
+		sigS = make([][32]byte, count)
+	)
+	for i, sig := range p.Signatures[:count] {
+		sigV[i] = sig.Signature[64] + 27
+		copy(sigR[i][:], sig.Signature[:32])
+		copy(sigS[i][:], sig.Signature[32:64])
+	}
+	account := accounts.Account{Address: from}
+	wallet, err := s.am.Find(account)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	chainID := s.backend.ChainID()
+	opts := &bind.TransactOpts{
+		From:    from,
+		Context: ctx,
+		Signer: func(signer types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if passphrase != nil {
+				return wallet.SignTxWithPassphrase(account, *passphrase, tx, chainID)
+			}
+			return wallet.SignTx(account, tx, chainID)
+		},
+	}
+	tx, err := contract.Transact(opts, "execute", sigV, sigR, sigS, p.Destination, p.Value.ToInt(), []byte(p.Data))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	hash := tx.Hash()
+	p.Transaction = &hash
+	if err := s.store(p); err != nil {
+		return common.Hash{}, err
+	}
+	log.Info("Submitted multisig proposal", "id", id, "wallet", p.Wallet, "tx", hash)
+	return hash, nil
+}
+
+// discard deletes a proposal.
+func (s *Service) discard(id common.Hash) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ids, err := s.index()
+	if err != nil {
+		return err
+	}
+	for i, have := range ids {
+		if have == id {
+			ids = append(ids[:i], ids[i+1:]...)
+			if err := s.storeIndex(ids); err != nil {
+				return err
+			}
+			return s.db.Delete(proposalKey(id))
+		}
+	}
+	return errUnknownProposal
+}
+
+// get retrieves a proposal.
+func (s *Service) get(id common.Hash) (*Proposal, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	p, err := s.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, errUnknownProposal
+	}
+	return p, nil
+}
+
+// list retrieves the proposals of a wallet, or of all wallets if nil, ordered
+// by wallet and nonce.
+func (s *Service) list(wallet *common.Address) ([]*Proposal, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ids, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	proposals := make([]*Proposal, 0, len(ids))
+	for _, id := range ids {
+		p, err := s.load(id)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil && (wallet == nil || p.Wallet == *wallet) {
+			proposals = append(proposals, p)
+		}
+	}
+	sort.SliceStable(proposals, func(i, j int) bool {
+		if c := bytes.Compare(proposals[i].Wallet[:], proposals[j].Wallet[:]); c != 0 {
+			return c < 0
+		}
+		return proposals[i].Nonce.ToInt().Cmp(proposals[j].Nonce.ToInt()) < 0
+	})
+	return proposals, nil
+}
+
+// pending retrieves a proposal that was not submitted yet.
+func (s *Service) pending(id common.Hash) (*Proposal, error) {
+	p, err := s.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, errUnknownProposal
+	}
+	if p.Transaction != nil {
+		return nil, errSubmitted
+	}
+	return p, nil
+}
+
+// bind binds a wallet contract with the given interface, checking that it
+// defines the methods of multisig wallets.
+func (s *Service) bind(wallet common.Address, walletABI string) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(walletABI))
+	if err != nil {
+		return nil, fmt.Errorf("invalid wallet ABI: %v", err)
+	}
+	for _, name := range walletMethods {
+		if _, ok := parsed.Methods[name]; !ok {
+			return nil, fmt.Errorf("wallet ABI lacks method %s", name)
+		}
+	}
+	return bind.NewBoundContract(wallet, parsed, s.backend, s.backend, nil), nil
+}
+
+func (s *Service) threshold(ctx context.Context, contract *bind.BoundContract) (*big.Int, error) {
+	out := new(*big.Int)
+	err := contract.Call(&bind.CallOpts{Context: ctx}, out, "threshold")
+	return *out, err
+}
+
+func (s *Service) nonce(ctx context.Context, contract *bind.BoundContract) (*big.Int, error) {
+	out := new(*big.Int)
+	err := contract.Call(&bind.CallOpts{Context: ctx}, out, "nonce")
+	return *out, err
+}
+
+func (s *Service) checkOwner(ctx context.Context, contract *bind.BoundContract, account common.Address) error {
+	owner := new(bool)
+	if err := contract.Call(&bind.CallOpts{Context: ctx}, owner, "isOwner", account); err != nil {
+		return err
+	}
+	if !*owner {
+		return fmt.Errorf("%s is not an owner of the wallet", account.Hex())
+	}
+	return nil
+}
+
+// load reads a proposal from the database, returning nil if it doesn't exist.
+func (s *Service) load(id common.Hash) (*Proposal, error) {
+	key := proposalKey(id)
+	if ok, err := s.db.Has(key); err != nil || !ok {
+		return nil, err
+	}
+	blob, err := s.db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	p := new(Proposal)
+	if err := json.Unmarshal(blob, p); err != nil {
+		return nil, fmt.Errorf("corrupt proposal %x: %v", id, err)
+	}
+	return p, nil
+}
+
+// store writes a proposal to the database, indexing it if new.
+func (s *Service) store(p *Proposal) error {
+	key := proposalKey(p.ID)
+	if ok, err := s.db.Has(key); err != nil {
+		return err
+	} else if !ok {
+		ids, err := s.index()
+		if err != nil {
+			return err
+		}
+		if err := s.storeIndex(append(ids, p.ID)); err != nil {
+			return err
+		}
+	}
+	blob, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(key, blob)
+}
+
+func (s *Service) index() ([]common.Hash, error) {
+	if ok, err := s.db.Has(proposalIndex); err != nil || !ok {
+		return nil, err
+	}
+	blob, err := s.db.Get(proposalIndex)
+	if err != nil {
+		return nil, err
+	}
+	var ids []common.Hash
+	if err := json.Unmarshal(blob, &ids); err != nil {
+		return nil, fmt.Errorf("corrupt proposal index: %v", err)
+	}
+	return ids, nil
+}
+
+func (s *Service) storeIndex(ids []common.Hash) error {
+	blob, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(proposalIndex, blob)
+}
+
+// proposalKey = proposalPrefix + id
+func proposalKey(id common.Hash) []byte {
+	return append(append([]byte{}, proposalPrefix...), id[:]...)
+}
@@ -0,0 +1,654 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package multisig
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/genchain/go-genchain/accounts"
+	"github.com/genchain/go-genchain/accounts/abi"
+	"github.com/genchain/go-genchain/accounts/abi/bind"
+	"github.com/genchain/go-genchain/accounts/abi/bind/backends"
+	"github.com/genchain/go-genchain/accounts/keystore"
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/hexutil"
+	"github.com/genchain/go-genchain/core"
+	"github.com/genchain/go-genchain/core/asm"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/ethdb"
+)
+
+// simpleMultiSigABI is the interface of the SimpleMultiSig wallet contracts.
+const simpleMultiSigABI = `[{"constant":true,"inputs":[],"name":"threshold","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":true,"inputs":[],"name":"nonce","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":true,"inputs":[{"name":"","type":"address"}],"name":"isOwner","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":false,"inputs":[{"name":"sigV","type":"uint8[]"},{"name":"sigR","type":"bytes32[]"},{"name":"sigS","type":"bytes32[]"},{"name":"destination","type":"address"},{"name":"value","type":"uint256"},{"name":"data","type":"bytes"}],"name":"execute","outputs":[],"payable":false,"stateMutability":"nonpayable","type":"function"}]`
+
+// simpleMultiSigCode is the runtime code of the SimpleMultiSig contract, in the
+// assembly of core/asm. It stores the threshold in slot 0, the nonce in slot 1
+// and the isOwner mapping in slot 2.
+const simpleMultiSigCode = `
+	;; Dispatch on the method id
+	push 0
+	calldataload
+	push 0x0100000000000000000000000000000000000000000000000000000000
+	swap1
+	div
+	dup1
+	push 0x42cde4e8
+	eq
+	jumpi @threshold
+	dup1
+	push 0xaffed0e0
+	eq
+	jumpi @nonce
+	dup1
+	push 0x2f54bf6e
+	eq
+	jumpi @isowner
+	dup1
+	push 0xf12d394f
+	eq
+	jumpi @execute
+	jump @fail
+
+threshold:
+	push 0
+	sload
+	push 0
+	mstore
+	push 32
+	push 0
+	return
+
+nonce:
+	push 1
+	sload
+	push 0
+	mstore
+	push 32
+	push 0
+	return
+
+isowner:
+	push 4
+	calldataload
+	push 0
+	mstore
+	push 2
+	push 32
+	mstore
+	push 64
+	push 0
+	sha3
+	sload
+	push 0
+	mstore
+	push 32
+	push 0
+	return
+
+execute:
+	;; Memory: 0x00 hash, 0x20 index, 0x40 last signer, 0x60 threshold, 0x80,
+	;; 0xa0 and 0xc0 offsets of the V, R and S values, 0xe0 offset and 0x100
+	;; length of the data, 0x120-0x1df ecrecover scratch, 0x200+ message
+	push 0
+	sload
+	push 0x60
+	mstore
+	push 4
+	calldataload
+	push 4
+	add
+	dup1
+	calldataload
+	push 0x60
+	mload
+	eq
+	iszero
+	jumpi @fail
+	push 32
+	add
+	push 0x80
+	mstore
+	push 36
+	calldataload
+	push 4
+	add
+	dup1
+	calldataload
+	push 0x60
+	mload
+	eq
+	iszero
+	jumpi @fail
+	push 32
+	add
+	push 0xa0
+	mstore
+	push 68
+	calldataload
+	push 4
+	add
+	dup1
+	calldataload
+	push 0x60
+	mload
+	eq
+	iszero
+	jumpi @fail
+	push 32
+	add
+	push 0xc0
+	mstore
+	push 164
+	calldataload
+	push 4
+	add
+	dup1
+	calldataload
+	push 0x100
+	mstore
+	push 32
+	add
+	push 0xe0
+	mstore
+
+	;; hash = keccak256(0x19, 0x00, this, destination, value, data, nonce)
+	push 0x19
+	push 0x200
+	mstore8
+	push 0
+	push 0x201
+	mstore8
+	address
+	push 0x1000000000000000000000000
+	mul
+	push 0x202
+	mstore
+	push 100
+	calldataload
+	push 0x1000000000000000000000000
+	mul
+	push 0x216
+	mstore
+	push 132
+	calldataload
+	push 0x22a
+	mstore
+	push 0x100
+	mload
+	push 0xe0
+	mload
+	push 0x24a
+	calldatacopy
+	push 1
+	sload
+	push 0x100
+	mload
+	push 0x24a
+	add
+	mstore
+	push 0x100
+	mload
+	push 0x6a
+	add
+	push 0x200
+	sha3
+	push 0
+	mstore
+
+	;; The signers must be distinct owners in ascending order
+	push 0
+	push 0x20
+	mstore
+	push 0
+	push 0x40
+	mstore
+loop:
+	push 0x60
+	mload
+	push 0x20
+	mload
+	lt
+	iszero
+	jumpi @exec
+	push 0
+	mload
+	push 0x120
+	mstore
+	push 0x20
+	mload
+	push 32
+	mul
+	dup1
+	push 0x80
+	mload
+	add
+	calldataload
+	push 0x140
+	mstore
+	dup1
+	push 0xa0
+	mload
+	add
+	calldataload
+	push 0x160
+	mstore
+	push 0xc0
+	mload
+	add
+	calldataload
+	push 0x180
+	mstore
+	push 0
+	push 0x1a0
+	mstore
+	push 32
+	push 0x1a0
+	push 128
+	push 0x120
+	push 0
+	push 1
+	gas
+	call
+	pop
+	push 0x1a0
+	mload
+	dup1
+	push 0x40
+	mload
+	lt
+	iszero
+	jumpi @fail
+	push 0x40
+	mstore
+	push 2
+	push 0x1c0
+	mstore
+	push 64
+	push 0x1a0
+	sha3
+	sload
+	iszero
+	jumpi @fail
+	push 0x20
+	mload
+	push 1
+	add
+	push 0x20
+	mstore
+	jump @loop
+
+exec:
+	;; Bump the nonce and execute the transaction
+	push 1
+	sload
+	push 1
+	add
+	push 1
+	sstore
+	push 0x100
+	mload
+	push 0xe0
+	mload
+	push 0x200
+	calldatacopy
+	push 0
+	push 0
+	push 0x100
+	mload
+	push 0x200
+	push 132
+	calldataload
+	push 100
+	calldataload
+	gas
+	call
+	iszero
+	jumpi @fail
+	stop
+
+fail:
+	push 0
+	dup1
+	revert
+`
+
+// compile assembles EVM code, failing the test on errors.
+func compile(t *testing.T, src string) []byte {
+	compiler := asm.NewCompiler(false)
+	compiler.Feed(asm.Lex("multisig", []byte(src), false))
+	bin, errs := compiler.Compile()
+	if len(errs) > 0 {
+		t.Fatalf("failed to compile: %v", errs)
+	}
+	return common.FromHex(bin)
+}
+
+// deployWallet deploys a SimpleMultiSig wallet holding the given value.
+func deployWallet(t *testing.T, sim *backends.SimulatedBackend, key *ecdsa.PrivateKey, threshold int, owners []common.Address, value *big.Int) common.Address {
+	runtime := compile(t, simpleMultiSigCode)
+
+	// The constructor initializes the storage and returns the runtime code
+	// appended to it
+	constructor := new(bytes.Buffer)
+	for _, owner := range owners {
+		slot := crypto.Keccak256(common.LeftPadBytes(owner[:], 32), common.LeftPadBytes([]byte{2}, 32))
+		fmt.Fprintf(constructor, "push 1\npush 0x%x\nsstore\n", slot)
+	}
+	fmt.Fprintf(constructor, "push %d\npush 0\nsstore\n", threshold)
+	fmt.Fprintf(constructor, "push %d\npush %d\ncodesize\nsub\npush 0\ncodecopy\n", len(runtime), len(runtime))
+	fmt.Fprintf(constructor, "push %d\npush 0\nreturn\n", len(runtime))
+
+	parsed, err := abi.JSON(strings.NewReader(simpleMultiSigABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := bind.NewKeyedTransactor(key)
+	opts.Value = value
+	wallet, _, _, err := bind.DeployContract(opts, parsed, append(compile(t, constructor.String()), runtime...), sim)
+	if err != nil {
+		t.Fatalf("failed to deploy wallet: %v", err)
+	}
+	sim.Commit()
+	return wallet
+}
+
+// testBackend is a Backend on top of a simulated chain, keeping track of the
+// transactions it sends until they are mined or dropped.
+type testBackend struct {
+	*backends.SimulatedBackend
+	sent map[common.Hash]bool
+}
+
+func (b *testBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := b.SimulatedBackend.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+	b.sent[tx.Hash()] = true
+	return nil
+}
+
+func (b *testBackend) ChainID() *big.Int {
+	return nil
+}
+
+func (b *testBackend) TransactionPending(ctx context.Context, hash common.Hash) (bool, error) {
+	receipt, _ := b.TransactionReceipt(ctx, hash)
+	return b.sent[hash] && receipt == nil, nil
+}
+
+// drop discards the pending transactions.
+func (b *testBackend) drop() {
+	b.Rollback()
+	b.sent = make(map[common.Hash]bool)
+}
+
+// newTestEnv creates a keystore with n funded accounts and a simulated chain
+// with a wallet owned by the first three of them.
+func newTestEnv(t *testing.T, n int) (*keystore.KeyStore, []accounts.Account, *testBackend, common.Address, string) {
+	dir, err := ioutil.TempDir("", "multisig-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	key, _ := crypto.GenerateKey()
+
+	funds := new(big.Int).Mul(big.NewInt(10), big.NewInt(1e18))
+	alloc := core.GenesisAlloc{crypto.PubkeyToAddress(key.PublicKey): {Balance: funds}}
+	accs := make([]accounts.Account, n)
+	for i := range accs {
+		if accs[i], err = ks.NewAccount("pass"); err != nil {
+			t.Fatal(err)
+		}
+		alloc[accs[i].Address] = core.GenesisAccount{Balance: funds}
+	}
+	backend := &testBackend{SimulatedBackend: backends.NewSimulatedBackend(alloc), sent: make(map[common.Hash]bool)}
+
+	owners := []common.Address{accs[0].Address, accs[1].Address, accs[2].Address}
+	wallet := deployWallet(t, backend.SimulatedBackend, key, 2, owners, big.NewInt(1e18))
+	return ks, accs, backend, wallet, dir
+}
+
+// Tests the lifecycle of a proposal, from its creation to its execution once
+// approved by the threshold of owners.
+func TestProposalLifecycle(t *testing.T) {
+	ks, accs, backend, wallet, dir := newTestEnv(t, 4)
+	defer os.RemoveAll(dir)
+
+	var (
+		db   = ethdb.NewMemDatabase()
+		am   = accounts.NewManager(ks)
+		api  = NewPrivateMultisigAPI(newService(am, db, backend))
+		ctx  = context.Background()
+		pass = "pass"
+	)
+	if _, err := api.Propose(ctx, ProposeArgs{Wallet: accs[0].Address, ABI: simpleMultiSigABI}); err == nil {
+		t.Fatalf("proposal for a non wallet account succeeded")
+	}
+	if _, err := api.Propose(ctx, ProposeArgs{Wallet: wallet, ABI: `[]`}); err == nil {
+		t.Fatalf("proposal with a non wallet ABI succeeded")
+	}
+	destination := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	p, err := api.Propose(ctx, ProposeArgs{Wallet: wallet, ABI: simpleMultiSigABI, Destination: destination, Value: (*hexutil.Big)(big.NewInt(1000)), Data: []byte{0xca, 0xfe}})
+	if err != nil {
+		t.Fatalf("failed to propose: %v", err)
+	}
+	if p.Nonce.ToInt().Sign() != 0 {
+		t.Errorf("proposal nonce mismatch: have %v, want 0", p.Nonce.ToInt())
+	}
+	want := crypto.Keccak256Hash(common.FromHex("0x1900" +
+		common.Bytes2Hex(wallet[:]) +
+		"2000000000000000000000000000000000000002" +
+		"00000000000000000000000000000000000000000000000000000000000003e8" +
+		"cafe" +
+		"0000000000000000000000000000000000000000000000000000000000000000"))
+	if p.ID != want {
+		t.Fatalf("proposal id mismatch: have %x, want %x", p.ID, want)
+	}
+	// Non-owners can't approve and the threshold must be met
+	if _, err := api.Approve(ctx, p.ID, accs[3].Address, &pass); err == nil {
+		t.Errorf("non-owner approval succeeded")
+	}
+	if _, err := api.Approve(ctx, p.ID, accs[2].Address, &pass); err != nil {
+		t.Fatalf("failed to approve: %v", err)
+	}
+	if _, err := api.Submit(ctx, p.ID, accs[3].Address, &pass); err == nil {
+		t.Fatalf("submission below threshold succeeded")
+	}
+	// Add a signature made elsewhere, with V in the Ethereum encoding
+	sig, err := ks.SignHashWithPassphrase(accs[0], pass, p.ID[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig[64] += 27
+	if _, err := api.AddSignature(ctx, p.ID, sig); err != nil {
+		t.Fatalf("failed to add signature: %v", err)
+	}
+	// Proposals persist in the database
+	api = NewPrivateMultisigAPI(newService(am, db, backend))
+	proposals, err := api.List(&wallet)
+	if err != nil {
+		t.Fatalf("failed to list proposals: %v", err)
+	}
+	if len(proposals) != 1 || len(proposals[0].Signatures) != 2 {
+		t.Fatalf("listed proposals mismatch: have %+v", proposals)
+	}
+	hash, err := api.Submit(ctx, p.ID, accs[3].Address, &pass)
+	if err != nil {
+		t.Fatalf("failed to submit: %v", err)
+	}
+	backend.Commit()
+
+	// The wallet must have executed the transaction
+	receipt, _ := backend.TransactionReceipt(ctx, hash)
+	if receipt == nil || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("execution failed: receipt %+v", receipt)
+	}
+	if balance, _ := backend.BalanceAt(ctx, destination, nil); balance.Int64() != 1000 {
+		t.Errorf("destination balance mismatch: have %v, want 1000", balance)
+	}
+	if nonce, _ := api.s.nonce(ctx, mustBind(t, api.s, wallet)); nonce.Int64() != 1 {
+		t.Errorf("wallet nonce mismatch: have %v, want 1", nonce)
+	}
+	// Submitted proposals are kept, but can't change anymore
+	if p, err = api.Get(p.ID); err != nil || p.Transaction == nil || *p.Transaction != hash {
+		t.Errorf("submitted proposal mismatch: have %+v, %v", p, err)
+	}
+	if _, err := api.Approve(ctx, p.ID, accs[1].Address, &pass); err != errSubmitted {
+		t.Errorf("approval after submission error mismatch: have %v, want %v", err, errSubmitted)
+	}
+	if _, err := api.Submit(ctx, p.ID, accs[3].Address, &pass); err != errSubmitted {
+		t.Errorf("submission after execution error mismatch: have %v, want %v", err, errSubmitted)
+	}
+	if err := api.Discard(p.ID); err != nil {
+		t.Fatalf("failed to discard: %v", err)
+	}
+	if proposals, _ := api.List(nil); len(proposals) != 0 {
+		t.Errorf("proposals left after discarding: %v", proposals)
+	}
+}
+
+// Tests that a proposal can be submitted again if its transaction was dropped,
+// but not while it is pending.
+func TestResubmitProposal(t *testing.T) {
+	ks, accs, backend, wallet, dir := newTestEnv(t, 3)
+	defer os.RemoveAll(dir)
+
+	var (
+		api  = NewPrivateMultisigAPI(newService(accounts.NewManager(ks), ethdb.NewMemDatabase(), backend))
+		ctx  = context.Background()
+		pass = "pass"
+	)
+	destination := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	p, err := api.Propose(ctx, ProposeArgs{Wallet: wallet, ABI: simpleMultiSigABI, Destination: destination, Value: (*hexutil.Big)(big.NewInt(1000))})
+	if err != nil {
+		t.Fatalf("failed to propose: %v", err)
+	}
+	for _, acc := range accs[:2] {
+		if _, err := api.Approve(ctx, p.ID, acc.Address, &pass); err != nil {
+			t.Fatalf("failed to approve: %v", err)
+		}
+	}
+	if _, err := api.Submit(ctx, p.ID, accs[0].Address, &pass); err != nil {
+		t.Fatalf("failed to submit: %v", err)
+	}
+	if _, err := api.Submit(ctx, p.ID, accs[0].Address, &pass); err != errPending {
+		t.Fatalf("submission while pending error mismatch: have %v, want %v", err, errPending)
+	}
+	backend.drop()
+
+	hash, err := api.Submit(ctx, p.ID, accs[0].Address, &pass)
+	if err != nil {
+		t.Fatalf("failed to resubmit: %v", err)
+	}
+	backend.Commit()
+
+	if p, _ = api.Get(p.ID); p.Transaction == nil || *p.Transaction != hash {
+		t.Errorf("resubmitted transaction mismatch: have %v, want %x", p.Transaction, hash)
+	}
+	if balance, _ := backend.BalanceAt(ctx, destination, nil); balance.Int64() != 1000 {
+		t.Errorf("destination balance mismatch: have %v, want 1000", balance)
+	}
+}
+
+// Tests that proposals exported from one node can be imported and approved on
+// another one.
+func TestImportProposal(t *testing.T) {
+	ks, accs, backend, wallet, dir := newTestEnv(t, 3)
+	defer os.RemoveAll(dir)
+
+	var (
+		ctx    = context.Background()
+		pass   = "pass"
+		first  = NewPrivateMultisigAPI(newService(accounts.NewManager(ks), ethdb.NewMemDatabase(), backend))
+		second = NewPrivateMultisigAPI(newService(accounts.NewManager(ks), ethdb.NewMemDatabase(), backend))
+	)
+	p, err := first.Propose(ctx, ProposeArgs{Wallet: wallet, ABI: simpleMultiSigABI, Nonce: (*hexutil.Big)(big.NewInt(3))})
+	if err != nil {
+		t.Fatalf("failed to propose: %v", err)
+	}
+	if p, err = first.Approve(ctx, p.ID, accs[0].Address, &pass); err != nil {
+		t.Fatalf("failed to approve: %v", err)
+	}
+	// Tampered proposals and forged signatures are rejected
+	tampered := *p
+	tampered.Nonce = (*hexutil.Big)(big.NewInt(4))
+	if _, err := second.Import(ctx, tampered); err == nil {
+		t.Errorf("tampered proposal imported")
+	}
+	unbound := *p
+	unbound.ABI = ""
+	if _, err := second.Import(ctx, unbound); err == nil {
+		t.Errorf("proposal without ABI imported")
+	}
+	forged := *p
+	forged.Signatures = []Signature{{Signer: accs[1].Address, Signature: p.Signatures[0].Signature}}
+	imported, err := second.Import(ctx, forged)
+	if err != nil {
+		t.Fatalf("failed to import proposal: %v", err)
+	}
+	if len(imported.Signatures) != 1 || imported.Signatures[0].Signer != accs[0].Address {
+		t.Errorf("imported signatures mismatch: have %+v", imported.Signatures)
+	}
+	if imported, err = second.Approve(ctx, p.ID, accs[1].Address, &pass); err != nil {
+		t.Fatalf("failed to approve imported proposal: %v", err)
+	}
+	if len(imported.Signatures) != 2 {
+		t.Errorf("signature count mismatch: have %d, want 2", len(imported.Signatures))
+	}
+}
+
+// Tests that database failures are reported instead of being taken for missing
+// proposals.
+func TestLoadFailure(t *testing.T) {
+	db := &failingDatabase{ethdb.NewMemDatabase()}
+	s := newService(nil, db, nil)
+	if _, err := s.get(common.Hash{1}); err != errDatabase {
+		t.Errorf("get error mismatch: have %v, want %v", err, errDatabase)
+	}
+	if _, err := s.list(nil); err != errDatabase {
+		t.Errorf("list error mismatch: have %v, want %v", err, errDatabase)
+	}
+}
+
+var errDatabase = errors.New("database failure")
+
+// failingDatabase is a database failing all reads.
+type failingDatabase struct {
+	*ethdb.MemDatabase
+}
+
+func (db *failingDatabase) Has(key []byte) (bool, error) { return false, errDatabase }
+
+func (db *failingDatabase) Get(key []byte) ([]byte, error) { return nil, errDatabase }
+
+func mustBind(t *testing.T, s *Service, wallet common.Address) *bind.BoundContract {
+	contract, err := s.bind(wallet, simpleMultiSigABI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return contract
+}
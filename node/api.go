@@ -75,6 +75,62 @@ func (api *PrivateAdminAPI) RemovePeer(url string) (bool, error) {
 	return true, nil
 }
 
+// BanPeer bans a remote node, given by its enode URL or node ID, for the given
+// number of seconds (the default ban duration if omitted), disconnecting it if
+// it is connected.
+func (api *PrivateAdminAPI) BanPeer(url string, seconds *uint64) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	id, err := parsePeerID(url)
+	if err != nil {
+		return false, err
+	}
+	duration := p2p.DefaultBanDuration
+	if seconds != nil {
+		duration = time.Duration(*seconds) * time.Second
+	}
+	if err := server.BanPeer(id, duration); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Unban lifts the ban of a remote node, given by its enode URL or node ID.
+func (api *PrivateAdminAPI) Unban(url string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	id, err := parsePeerID(url)
+	if err != nil {
+		return false, err
+	}
+	if err := server.Unban(id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// parsePeerID extracts the node ID from an enode URL or a hex encoded node ID.
+func parsePeerID(url string) (discover.NodeID, error) {
+	if strings.HasPrefix(url, "enode://") {
+		node, err := discover.ParseNode(url)
+		if err != nil {
+			return discover.NodeID{}, fmt.Errorf("invalid enode: %v", err)
+		}
+		return node.ID, nil
+	}
+	id, err := discover.HexID(url)
+	if err != nil {
+		return discover.NodeID{}, fmt.Errorf("invalid node ID: %v", err)
+	}
+	return id, nil
+}
+
 // PeerEvents creates an RPC subscription which receives peer events from the
 // node's p2p.Server
 func (api *PrivateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error) {
@@ -261,6 +317,16 @@ func (api *PublicAdminAPI) NodeInfo() (*p2p.NodeInfo, error) {
 	return server.NodeInfo(), nil
 }
 
+// PeerScores retrieves the reputation scores of the remote nodes tracked by the
+// server, including the banned ones.
+func (api *PublicAdminAPI) PeerScores() ([]p2p.PeerScore, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.PeerScores(), nil
+}
+
 // Datadir retrieves the current data directory the node is using.
 func (api *PublicAdminAPI) Datadir() string {
 	return api.node.DataDir()
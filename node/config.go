@@ -26,7 +26,9 @@ import (
 	"strings"
 
 	"github.com/genchain/go-genchain/accounts"
+	"github.com/genchain/go-genchain/accounts/hdwallet"
 	"github.com/genchain/go-genchain/accounts/keystore"
+	"github.com/genchain/go-genchain/accounts/pkcs11wallet"
 	"github.com/genchain/go-genchain/accounts/usbwallet"
 	"github.com/genchain/go-genchain/common"
 	"github.com/genchain/go-genchain/crypto"
@@ -41,6 +43,8 @@ const (
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+
+	keystoreHDWallets = "hd" // Path within the keystore to the HD wallets
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -85,6 +89,11 @@ type Config struct {
 	// NoUSB disables hardware wallet monitoring and connectivity.
 	NoUSB bool `toml:",omitempty"`
 
+	// PKCS11Module is the path of a PKCS#11 module (shared library) whose tokens,
+	// like hardware security modules, to manage the secp256k1 keys of. An empty
+	// path disables PKCS#11 support.
+	PKCS11Module string `toml:",omitempty"`
+
 	// IPCPath is the requested location to place the IPC endpoint. If the path is
 	// a simple file name, it is placed inside the data directory (or on the root
 	// pipe path on Windows), whereas if it's a resolvable path name (absolute or
@@ -418,6 +427,7 @@ func makeAccountManager(conf *Config) (*accounts.Manager, string, error) {
 	// Assemble the account manager and supported backends
 	backends := []accounts.Backend{
 		keystore.NewKeyStore(keydir, scryptN, scryptP),
+		hdwallet.NewHub(filepath.Join(keydir, keystoreHDWallets), scryptN, scryptP),
 	}
 	if !conf.NoUSB {
 		// Start a USB hub for Ledger hardware wallets
@@ -433,5 +443,13 @@ func makeAccountManager(conf *Config) (*accounts.Manager, string, error) {
 			backends = append(backends, trezorhub)
 		}
 	}
+	if conf.PKCS11Module != "" {
+		// Start a hub for the tokens of the PKCS#11 module
+		if pkcs11hub, err := pkcs11wallet.NewHub(conf.PKCS11Module); err != nil {
+			log.Warn(fmt.Sprintf("Failed to start PKCS#11 hub, disabling: %v", err))
+		} else {
+			backends = append(backends, pkcs11hub)
+		}
+	}
 	return accounts.NewManager(backends...), ephemeral, nil
 }
@@ -72,8 +72,12 @@ type dialstate struct {
 	maxDynDials int
 	ntab        discoverTable
 	netrestrict *netutil.Netlist
+	filter      func(*discover.Node) bool  // filter for discovered candidates, if any
+	dns         nodeIterator               // nodes of DNS node lists, if any
+	banned      func(discover.NodeID) bool // reports banned nodes, if set
 
 	lookupRunning bool
+	dnsRunning    bool
 	dialing       map[discover.NodeID]connFlag
 	lookupBuf     []*discover.Node // current discovery lookup results
 	randomNodes   []*discover.Node // filled from Table
@@ -92,6 +96,13 @@ type discoverTable interface {
 	ReadRandomNodes([]*discover.Node) int
 }
 
+// nodeIterator is a source of dial candidates besides the discovery table, such
+// as the DNS node lists of package dnsdisc.
+type nodeIterator interface {
+	Next() bool
+	Node() *discover.Node
+}
+
 // the dial history remembers recent dials.
 type dialHistory []pastDial
 
@@ -121,6 +132,14 @@ type discoverTask struct {
 	results []*discover.Node
 }
 
+// dnsTask takes dial candidates from DNS node lists.
+// Only one dnsTask is active at any time.
+type dnsTask struct {
+	it      nodeIterator
+	want    int
+	results []*discover.Node
+}
+
 // A waitExpireTask is generated if there are no other tasks
 // to keep the loop in Server.run ticking.
 type waitExpireTask struct {
@@ -174,6 +193,13 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 		newtasks = append(newtasks, &dialTask{flags: flag, dest: n})
 		return true
 	}
+	addDiscovered := func(n *discover.Node) bool {
+		if s.filter != nil && !s.filter(n) {
+			log.Trace("Skipping filtered dial candidate", "id", n.ID, "addr", &net.TCPAddr{IP: n.IP, Port: int(n.TCP)})
+			return false
+		}
+		return addDial(dynDialedConn, n)
+	}
 
 	// Compute number of dynamic dials necessary at this point.
 	needDynDials := s.maxDynDials
@@ -218,10 +244,10 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 	// Use random nodes from the table for half of the necessary
 	// dynamic dials.
 	randomCandidates := needDynDials / 2
-	if randomCandidates > 0 {
+	if randomCandidates > 0 && s.ntab != nil {
 		n := s.ntab.ReadRandomNodes(s.randomNodes)
 		for i := 0; i < randomCandidates && i < n; i++ {
-			if addDial(dynDialedConn, s.randomNodes[i]) {
+			if addDiscovered(s.randomNodes[i]) {
 				needDynDials--
 			}
 		}
@@ -230,16 +256,21 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 	// items from the result buffer.
 	i := 0
 	for ; i < len(s.lookupBuf) && needDynDials > 0; i++ {
-		if addDial(dynDialedConn, s.lookupBuf[i]) {
+		if addDiscovered(s.lookupBuf[i]) {
 			needDynDials--
 		}
 	}
 	s.lookupBuf = s.lookupBuf[:copy(s.lookupBuf, s.lookupBuf[i:])]
 	// Launch a discovery lookup if more candidates are needed.
-	if len(s.lookupBuf) < needDynDials && !s.lookupRunning {
+	if len(s.lookupBuf) < needDynDials && !s.lookupRunning && s.ntab != nil {
 		s.lookupRunning = true
 		newtasks = append(newtasks, &discoverTask{})
 	}
+	// Walk the DNS node lists for more candidates as well.
+	if len(s.lookupBuf) < needDynDials && !s.dnsRunning && s.dns != nil {
+		s.dnsRunning = true
+		newtasks = append(newtasks, &dnsTask{it: s.dns, want: needDynDials - len(s.lookupBuf)})
+	}
 
 	// Launch a timer to wait for the next node to expire if all
 	// candidates have been tried and no task is currently active.
@@ -258,6 +289,7 @@ var (
 	errAlreadyConnected = errors.New("already connected")
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNotWhitelisted   = errors.New("not contained in netrestrict whitelist")
+	errBanned           = errors.New("banned")
 )
 
 func (s *dialstate) checkDial(n *discover.Node, peers map[discover.NodeID]*Peer) error {
@@ -273,6 +305,8 @@ func (s *dialstate) checkDial(n *discover.Node, peers map[discover.NodeID]*Peer)
 		return errNotWhitelisted
 	case s.hist.contains(n.ID):
 		return errRecentlyDialed
+	case s.banned != nil && s.banned(n.ID):
+		return errBanned
 	}
 	return nil
 }
@@ -285,6 +319,9 @@ func (s *dialstate) taskDone(t task, now time.Time) {
 	case *discoverTask:
 		s.lookupRunning = false
 		s.lookupBuf = append(s.lookupBuf, t.results...)
+	case *dnsTask:
+		s.dnsRunning = false
+		s.lookupBuf = append(s.lookupBuf, t.results...)
 	}
 }
 
@@ -380,6 +417,24 @@ func (t *discoverTask) String() string {
 	return s
 }
 
+func (t *dnsTask) Do(srv *Server) {
+	// Node lists are walked at random, so the same nodes come up repeatedly
+	// once most of them were dialed. Throttle the walks like lookups to keep
+	// the event loop from spinning.
+	next := srv.lastDNSLookup.Add(lookupInterval)
+	if now := time.Now(); now.Before(next) {
+		time.Sleep(next.Sub(now))
+	}
+	srv.lastDNSLookup = time.Now()
+	for len(t.results) < t.want && t.it.Next() {
+		t.results = append(t.results, t.it.Node())
+	}
+}
+
+func (t *dnsTask) String() string {
+	return fmt.Sprintf("DNS node list walk (%d results)", len(t.results))
+}
+
 func (t waitExpireTask) Do(*Server) {
 	time.Sleep(t.Duration)
 }
@@ -432,6 +432,145 @@ func TestDialStateNetRestrict(t *testing.T) {
 	})
 }
 
+// This test checks that discovered candidates rejected by the filter are not dialed.
+func TestDialStateFilter(t *testing.T) {
+	table := fakeTable{
+		{ID: uintID(1)},
+		{ID: uintID(2)},
+		{ID: uintID(3)},
+		{ID: uintID(4)},
+	}
+	state := newDialState(nil, nil, table, 8, nil)
+	state.filter = func(n *discover.Node) bool { return n.ID != uintID(1) && n.ID != uintID(3) }
+
+	runDialTest(t, dialtest{
+		init: state,
+		rounds: []round{
+			{
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: table[1]},
+					&dialTask{flags: dynDialedConn, dest: table[3]},
+					&discoverTask{},
+				},
+			},
+			{
+				done: []task{
+					&discoverTask{results: []*discover.Node{
+						{ID: uintID(5)},
+						{ID: uintID(3)}, // rejected by the filter
+						{ID: uintID(6)},
+					}},
+				},
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(5)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(6)}},
+					&discoverTask{},
+				},
+			},
+		},
+	})
+}
+
+// This test checks that banned nodes are not dialed, static or not.
+func TestDialStateBanned(t *testing.T) {
+	static := []*discover.Node{
+		{ID: uintID(1)},
+		{ID: uintID(2)},
+	}
+	table := fakeTable{
+		{ID: uintID(3)},
+		{ID: uintID(4)},
+	}
+	state := newDialState(static, nil, table, 4, nil)
+	state.banned = func(id discover.NodeID) bool { return id == uintID(1) || id == uintID(3) || id == uintID(5) }
+
+	runDialTest(t, dialtest{
+		init: state,
+		rounds: []round{
+			{
+				new: []task{
+					&dialTask{flags: staticDialedConn, dest: static[1]},
+					&dialTask{flags: dynDialedConn, dest: table[1]},
+					&discoverTask{},
+				},
+			},
+			{
+				done: []task{
+					&discoverTask{results: []*discover.Node{
+						{ID: uintID(5)}, // banned
+						{ID: uintID(6)},
+					}},
+				},
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(6)}},
+					&discoverTask{},
+				},
+			},
+		},
+	})
+}
+
+type fakeIterator struct{ nodes []*discover.Node }
+
+func (it *fakeIterator) Next() bool {
+	if len(it.nodes) == 0 {
+		return false
+	}
+	it.nodes = it.nodes[1:]
+	return true
+}
+func (it *fakeIterator) Node() *discover.Node { return it.nodes[0] }
+
+// This test checks that DNS node lists are walked for dial candidates, also
+// when discovery is disabled.
+func TestDialStateDNS(t *testing.T) {
+	it := new(fakeIterator)
+	state := newDialState(nil, nil, nil, 4, nil)
+	state.dns = it
+	state.filter = func(n *discover.Node) bool { return n.ID != uintID(2) }
+
+	runDialTest(t, dialtest{
+		init: state,
+		rounds: []round{
+			{
+				new: []task{
+					&dnsTask{it: it, want: 4},
+				},
+			},
+			{
+				done: []task{
+					&dnsTask{it: it, want: 4, results: []*discover.Node{
+						{ID: uintID(1)},
+						{ID: uintID(2)}, // rejected by the filter
+						{ID: uintID(3)},
+					}},
+				},
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(1)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(3)}},
+					&dnsTask{it: it, want: 2},
+				},
+			},
+			{
+				peers: []*Peer{
+					{rw: &conn{flags: dynDialedConn, id: uintID(1)}},
+				},
+				done: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(1)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(3)}},
+					&dnsTask{it: it, want: 2, results: []*discover.Node{
+						{ID: uintID(4)},
+					}},
+				},
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(4)}},
+					&dnsTask{it: it, want: 2},
+				},
+			},
+		},
+	})
+}
+
 // This test checks that static dials are launched.
 func TestDialStateStaticDial(t *testing.T) {
 	wantStatic := []*discover.Node{
@@ -56,6 +56,7 @@ type nodeDB struct {
 var (
 	nodeDBVersionKey = []byte("version") // Version of the database to flush if changes
 	nodeDBItemPrefix = []byte("n:")      // Identifier to prefix node entries with
+	nodeDBBanPrefix  = []byte("ban:")    // Identifier to prefix node bans with, kept apart from expiring node entries
 
 	nodeDBDiscoverRoot      = ":discover"
 	nodeDBDiscoverPing      = nodeDBDiscoverRoot + ":lastping"
@@ -303,6 +304,53 @@ func (db *nodeDB) updateFindFails(id NodeID, fails int) error {
 	return db.storeInt64(makeKey(id, nodeDBDiscoverFindFails), int64(fails))
 }
 
+// makeBanKey generates the leveldb key-blob holding the ban of a node.
+func makeBanKey(id NodeID) []byte {
+	return append(append([]byte{}, nodeDBBanPrefix...), id[:]...)
+}
+
+// banExpiry retrieves the time until which a node is banned, zero if not.
+func (db *nodeDB) banExpiry(id NodeID) time.Time {
+	until := db.fetchInt64(makeBanKey(id))
+	if until == 0 {
+		return time.Time{}
+	}
+	return time.Unix(until, 0)
+}
+
+// updateBan bans a node until the given time, or lifts its ban if zero.
+func (db *nodeDB) updateBan(id NodeID, until time.Time) error {
+	key := makeBanKey(id)
+	if until.IsZero() {
+		return db.lvl.Delete(key, nil)
+	}
+	return db.storeInt64(key, until.Unix())
+}
+
+// bans retrieves all bans still in effect, deleting the expired ones.
+func (db *nodeDB) bans() map[NodeID]time.Time {
+	var (
+		now  = time.Now()
+		bans = make(map[NodeID]time.Time)
+		it   = db.lvl.NewIterator(util.BytesPrefix(nodeDBBanPrefix), nil)
+	)
+	defer it.Release()
+
+	for it.Next() {
+		var id NodeID
+		if len(it.Key()) != len(nodeDBBanPrefix)+len(id) {
+			continue
+		}
+		copy(id[:], it.Key()[len(nodeDBBanPrefix):])
+		if until := db.banExpiry(id); until.After(now) {
+			bans[id] = until
+		} else {
+			db.updateBan(id, time.Time{})
+		}
+	}
+	return bans
+}
+
 // querySeeds retrieves random nodes to be used as potential seed nodes
 // for bootstrapping.
 func (db *nodeDB) querySeeds(n int, maxAge time.Duration) []*Node {
@@ -368,3 +416,40 @@ func (db *nodeDB) close() {
 	close(db.quit)
 	db.lvl.Close()
 }
+
+// NodeDB is a handle to the node database, allowing it to be shared between
+// the discovery table and the p2p server, which keeps the bans of misbehaving
+// peers in it.
+type NodeDB struct {
+	db *nodeDB
+}
+
+// OpenNodeDB opens the node database at the given path, or creates an in-memory
+// one if the path is empty.
+func OpenNodeDB(path string, self NodeID) (*NodeDB, error) {
+	db, err := newNodeDB(path, Version, self)
+	if err != nil {
+		return nil, err
+	}
+	return &NodeDB{db}, nil
+}
+
+// BanExpiry returns the time until which a node is banned, zero if it isn't.
+func (db *NodeDB) BanExpiry(id NodeID) time.Time {
+	return db.db.banExpiry(id)
+}
+
+// Ban bans a node until the given time, or lifts its ban if the time is zero.
+func (db *NodeDB) Ban(id NodeID, until time.Time) error {
+	return db.db.updateBan(id, until)
+}
+
+// Bans returns all bans still in effect.
+func (db *NodeDB) Bans() map[NodeID]time.Time {
+	return db.db.bans()
+}
+
+// Close closes the database. Tables using the database must be closed first.
+func (db *NodeDB) Close() {
+	db.db.close()
+}
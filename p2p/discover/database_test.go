@@ -378,3 +378,40 @@ func TestNodeDBSelfExpiration(t *testing.T) {
 		t.Errorf("self not evacuated")
 	}
 }
+
+func TestNodeDBBans(t *testing.T) {
+	db, _ := newNodeDB("", Version, NodeID{})
+	defer db.close()
+
+	var (
+		banned  = NodeID{1}
+		expired = NodeID{2}
+		lifted  = NodeID{3}
+		until   = time.Now().Add(time.Hour)
+	)
+	db.updateBan(banned, until)
+	db.updateBan(expired, time.Now().Add(-time.Hour))
+	db.updateBan(lifted, until)
+	db.updateBan(lifted, time.Time{})
+
+	if have := db.banExpiry(banned); have.Unix() != until.Unix() {
+		t.Errorf("ban expiry mismatch: have %v, want %v", have, until)
+	}
+	if have := db.banExpiry(lifted); !have.IsZero() {
+		t.Errorf("lifted ban still present: %v", have)
+	}
+	bans := db.bans()
+	if len(bans) != 1 || bans[banned].Unix() != until.Unix() {
+		t.Errorf("active bans mismatch: %v", bans)
+	}
+	if have := db.banExpiry(expired); !have.IsZero() {
+		t.Errorf("expired ban not deleted: %v", have)
+	}
+	// Bans must survive node expiration.
+	if err := db.expireNodes(); err != nil {
+		t.Fatalf("failed to expire nodes: %v", err)
+	}
+	if have := db.banExpiry(banned); have.IsZero() {
+		t.Errorf("ban removed by node expiration")
+	}
+}
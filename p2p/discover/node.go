@@ -34,6 +34,7 @@ import (
 	"github.com/genchain/go-genchain/common"
 	"github.com/genchain/go-genchain/crypto"
 	"github.com/genchain/go-genchain/crypto/secp256k1"
+	"github.com/genchain/go-genchain/p2p/enr"
 )
 
 const NodeIDBits = 512
@@ -54,6 +55,9 @@ type Node struct {
 
 	// Time when the node was added to the table.
 	addedAt time.Time
+
+	// The signed node record of the node, if retrieved.
+	record *enr.Record
 }
 
 // NewNode creates a new node. It is mostly meant to be used for
@@ -71,6 +75,49 @@ func NewNode(id NodeID, ip net.IP, udpPort, tcpPort uint16) *Node {
 	}
 }
 
+// NewNodeFromRecord creates a node from its signed node record. The record must
+// use the "v4" identity scheme and contain the endpoint of the node.
+func NewNodeFromRecord(r *enr.Record) (*Node, error) {
+	var (
+		pubkey enr.Secp256k1
+		ip     enr.IP
+		udp    enr.UDP
+		tcp    enr.TCP
+	)
+	if err := r.Load(&pubkey); err != nil {
+		return nil, err
+	}
+	if err := r.Load(&ip); err != nil {
+		return nil, err
+	}
+	if err := r.Load(&tcp); err != nil {
+		return nil, err
+	}
+	// Nodes without a UDP port don't take part in discovery, they are
+	// reachable over TCP nonetheless.
+	if err := r.Load(&udp); err != nil && !enr.IsNotFound(err) {
+		return nil, err
+	}
+	n := NewNode(PubkeyID((*ecdsa.PublicKey)(&pubkey)), net.IP(ip), uint16(udp), uint16(tcp))
+	n.record = r
+	return n, nil
+}
+
+// Record returns the signed node record (EIP-778) of the node, or nil if it
+// isn't known. Records are retrieved from nodes announcing them in discovery.
+func (n *Node) Record() *enr.Record {
+	return n.record
+}
+
+// recordSeq returns the sequence number of the known node record, zero if
+// there is none.
+func (n *Node) recordSeq() uint64 {
+	if n.record == nil {
+		return 0
+	}
+	return n.record.Seq()
+}
+
 func (n *Node) addr() *net.UDPAddr {
 	return &net.UDPAddr{IP: n.IP, Port: int(n.UDP)}
 }
@@ -36,6 +36,7 @@ import (
 	"github.com/genchain/go-genchain/common"
 	"github.com/genchain/go-genchain/crypto"
 	"github.com/genchain/go-genchain/log"
+	"github.com/genchain/go-genchain/p2p/enr"
 	"github.com/genchain/go-genchain/p2p/netutil"
 )
 
@@ -73,6 +74,7 @@ type Table struct {
 	ips     netutil.DistinctNetSet
 
 	db         *nodeDB // database of known nodes
+	ownDB      bool    // whether the database is closed along with the table
 	refreshReq chan chan struct{}
 	initDone   chan struct{}
 	closeReq   chan struct{}
@@ -98,9 +100,10 @@ type bondproc struct {
 // it is an interface so we can test without opening lots of UDP
 // sockets and without generating a private key.
 type transport interface {
-	ping(NodeID, *net.UDPAddr) error
+	ping(NodeID, *net.UDPAddr) (seq uint64, err error)
 	waitping(NodeID) error
 	findnode(toid NodeID, addr *net.UDPAddr, target NodeID) ([]*Node, error)
+	requestENR(NodeID, *net.UDPAddr) (*enr.Record, error)
 	close()
 }
 
@@ -118,9 +121,16 @@ func newTable(t transport, ourID NodeID, ourAddr *net.UDPAddr, nodeDBPath string
 	if err != nil {
 		return nil, err
 	}
+	return newTableWithDB(t, ourID, ourAddr, db, true, bootnodes)
+}
+
+// newTableWithDB creates a table on top of an open node database, closing it
+// along with the table if owned.
+func newTableWithDB(t transport, ourID NodeID, ourAddr *net.UDPAddr, db *nodeDB, ownDB bool, bootnodes []*Node) (*Table, error) {
 	tab := &Table{
 		net:        t,
 		db:         db,
+		ownDB:      ownDB,
 		self:       NewNode(ourID, ourAddr.IP, uint16(ourAddr.Port), uint16(ourAddr.Port)),
 		bonding:    make(map[NodeID]*bondproc),
 		bondslots:  make(chan struct{}, maxBondingPingPongs),
@@ -167,6 +177,17 @@ func (tab *Table) Self() *Node {
 	return tab.self
 }
 
+// SetEntry sets an entry of the local node record, signing the record again
+// with an increased sequence number. Remote nodes retrieve the new record when
+// they learn of the new sequence number through pings.
+func (tab *Table) SetEntry(entry enr.Entry) error {
+	t, ok := tab.net.(*udp)
+	if !ok {
+		return errors.New("node record not served")
+	}
+	return t.setEntry(entry)
+}
+
 // ReadRandomNodes fills the given slice with random nodes from the
 // table. It will not write the same node more than once. The nodes in
 // the slice are copies and can be modified by the caller.
@@ -416,7 +437,9 @@ loop:
 	for _, ch := range waiting {
 		close(ch)
 	}
-	tab.db.close()
+	if tab.ownDB {
+		tab.db.close()
+	}
 	close(tab.closed)
 }
 
@@ -473,15 +496,24 @@ func (tab *Table) doRevalidate(done chan<- struct{}) {
 	}
 
 	// Ping the selected node and wait for a pong.
-	err := tab.ping(last.ID, last.addr())
-
+	seq, err := tab.ping(last.ID, last.addr())
+
+	// Retrieve the node record if the node announced a newer one.
+	node := last
+	if err == nil && seq > last.recordSeq() {
+		if record := tab.requestENR(last.ID, last.addr()); record != nil {
+			cpy := *last
+			cpy.record = record
+			node = &cpy
+		}
+	}
 	tab.mutex.Lock()
 	defer tab.mutex.Unlock()
 	b := tab.buckets[bi]
 	if err == nil {
 		// The node responded, move it to the front.
-		log.Debug("Revalidated node", "b", bi, "id", last.ID)
-		b.bump(last)
+		log.Debug("Revalidated node", "b", bi, "id", last.ID, "seq", seq)
+		b.bump(node)
 		return
 	}
 	// No reply received, pick a replacement or delete the node if there aren't
@@ -641,7 +673,8 @@ func (tab *Table) pingpong(w *bondproc, pinged bool, id NodeID, addr *net.UDPAdd
 	defer func() { tab.bondslots <- struct{}{} }()
 
 	// Ping the remote side and wait for a pong.
-	if w.err = tab.ping(id, addr); w.err != nil {
+	var seq uint64
+	if seq, w.err = tab.ping(id, addr); w.err != nil {
 		close(w.done)
 		return
 	}
@@ -653,18 +686,40 @@ func (tab *Table) pingpong(w *bondproc, pinged bool, id NodeID, addr *net.UDPAdd
 	}
 	// Bonding succeeded, update the node database.
 	w.n = NewNode(id, addr.IP, uint16(addr.Port), tcpPort)
+	if seq > 0 {
+		w.n.record = tab.requestENR(id, addr)
+	}
 	close(w.done)
 }
 
+// requestENR retrieves the record of a remote node, returning nil if it can't
+// be retrieved. The request is retried once on timeout, as the remote node may
+// not have processed our side of the bond yet.
+func (tab *Table) requestENR(id NodeID, addr *net.UDPAddr) *enr.Record {
+	for i := 0; i < 2; i++ {
+		record, err := tab.net.requestENR(id, addr)
+		if err == nil {
+			return record
+		}
+		log.Trace("Node record retrieval failed", "id", id, "addr", addr, "err", err)
+		if err != errTimeout {
+			break
+		}
+	}
+	return nil
+}
+
 // ping a remote endpoint and wait for a reply, also updating the node
-// database accordingly.
-func (tab *Table) ping(id NodeID, addr *net.UDPAddr) error {
+// database accordingly. The sequence number of the remote node record is
+// returned.
+func (tab *Table) ping(id NodeID, addr *net.UDPAddr) (uint64, error) {
 	tab.db.updateLastPing(id, time.Now())
-	if err := tab.net.ping(id, addr); err != nil {
-		return err
+	seq, err := tab.net.ping(id, addr)
+	if err != nil {
+		return 0, err
 	}
 	tab.db.updateBondTime(id, time.Now())
-	return nil
+	return seq, nil
 }
 
 // bucket returns the bucket for the given node ID hash.
@@ -780,10 +835,16 @@ func (tab *Table) replace(b *bucket, last *Node) *Node {
 }
 
 // bump moves the given node to the front of the bucket entry list
-// if it is contained in that list.
+// if it is contained in that list. The known record of the node is
+// kept if n doesn't carry one.
 func (b *bucket) bump(n *Node) bool {
 	for i := range b.entries {
 		if b.entries[i].ID == n.ID {
+			if n.record == nil && b.entries[i].record != nil {
+				cpy := *n
+				cpy.record = b.entries[i].record
+				n = &cpy
+			}
 			// move it to the front
 			copy(b.entries[1:], b.entries[:i])
 			b.entries[0] = n
@@ -30,6 +30,7 @@ import (
 
 	"github.com/genchain/go-genchain/common"
 	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/p2p/enr"
 )
 
 func TestTable_pingReplace(t *testing.T) {
@@ -210,17 +211,20 @@ func (t *pingRecorder) close() {}
 func (t *pingRecorder) waitping(from NodeID) error {
 	return nil // remote always pings
 }
-func (t *pingRecorder) ping(toid NodeID, toaddr *net.UDPAddr) error {
+func (t *pingRecorder) ping(toid NodeID, toaddr *net.UDPAddr) (uint64, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
 	t.pinged[toid] = true
 	if t.dead[toid] {
-		return errTimeout
+		return 0, errTimeout
 	} else {
-		return nil
+		return 0, nil
 	}
 }
+func (t *pingRecorder) requestENR(toid NodeID, toaddr *net.UDPAddr) (*enr.Record, error) {
+	return nil, errTimeout
+}
 
 func TestTable_closest(t *testing.T) {
 	t.Parallel()
@@ -576,9 +580,12 @@ func (tn *preminedTestnet) findnode(toid NodeID, toaddr *net.UDPAddr, target Nod
 	return result, nil
 }
 
-func (*preminedTestnet) close()                                      {}
-func (*preminedTestnet) waitping(from NodeID) error                  { return nil }
-func (*preminedTestnet) ping(toid NodeID, toaddr *net.UDPAddr) error { return nil }
+func (*preminedTestnet) close()                                                {}
+func (*preminedTestnet) waitping(from NodeID) error                            { return nil }
+func (*preminedTestnet) ping(toid NodeID, toaddr *net.UDPAddr) (uint64, error) { return 0, nil }
+func (*preminedTestnet) requestENR(toid NodeID, toaddr *net.UDPAddr) (*enr.Record, error) {
+	return nil, errTimeout
+}
 
 // mine generates a testnet struct literal with nodes at
 // various distances to the given target.
@@ -23,10 +23,12 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/genchain/go-genchain/crypto"
 	"github.com/genchain/go-genchain/log"
+	"github.com/genchain/go-genchain/p2p/enr"
 	"github.com/genchain/go-genchain/p2p/nat"
 	"github.com/genchain/go-genchain/p2p/netutil"
 	"github.com/genchain/go-genchain/rlp"
@@ -44,6 +46,7 @@ var (
 	errTimeout          = errors.New("RPC timeout")
 	errClockWarp        = errors.New("reply deadline too far in the future")
 	errClosed           = errors.New("socket closed")
+	errRecordSigner     = errors.New("node record not signed by node")
 )
 
 // Timeouts
@@ -62,6 +65,8 @@ const (
 	pongPacket
 	findnodePacket
 	neighborsPacket
+	enrRequestPacket
+	enrResponsePacket
 )
 
 // RPC request structures
@@ -103,6 +108,21 @@ type (
 		Rest []rlp.RawValue `rlp:"tail"`
 	}
 
+	// enrRequest is a query for the node record of the recipient (EIP-868).
+	enrRequest struct {
+		Expiration uint64
+		// Ignore additional fields (for forward compatibility).
+		Rest []rlp.RawValue `rlp:"tail"`
+	}
+
+	// reply to enrRequest
+	enrResponse struct {
+		ReplyTok []byte // Hash of the enrRequest packet.
+		Record   enr.Record
+		// Ignore additional fields (for forward compatibility).
+		Rest []rlp.RawValue `rlp:"tail"`
+	}
+
 	rpcNode struct {
 		IP  net.IP // len 4 for IPv4 or 16 for IPv6
 		UDP uint16 // for discovery protocol
@@ -144,6 +164,24 @@ func nodeToRPC(n *Node) rpcNode {
 	return rpcNode{ID: n.ID, IP: n.IP, UDP: n.UDP, TCP: n.TCP}
 }
 
+// seqTail encodes the sequence number of the local node record as the first
+// additional element of ping and pong packets. Nodes unaware of records ignore
+// it, being forward compatible.
+func seqTail(seq uint64) []rlp.RawValue {
+	enc, _ := rlp.EncodeToBytes(seq)
+	return []rlp.RawValue{enc}
+}
+
+// tailSeq decodes the record sequence number announced in the additional
+// elements of a ping or pong packet, zero if the sender has no record.
+func tailSeq(rest []rlp.RawValue) uint64 {
+	var seq uint64
+	if len(rest) == 0 || rlp.DecodeBytes(rest[0], &seq) != nil {
+		return 0
+	}
+	return seq
+}
+
 type packet interface {
 	handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error
 	name() string
@@ -163,6 +201,9 @@ type udp struct {
 	priv        *ecdsa.PrivateKey
 	ourEndpoint rpcEndpoint
 
+	recordMu sync.Mutex  // protects record
+	record   *enr.Record // signed record of the local node, replaced on updates
+
 	addpending chan *pending
 	gotreply   chan reply
 
@@ -223,9 +264,11 @@ type Config struct {
 	// These settings are optional:
 	AnnounceAddr *net.UDPAddr      // local address announced in the DHT
 	NodeDBPath   string            // if set, the node database is stored at this filesystem location
+	NodeDB       *NodeDB           // if set, this open node database is used instead of NodeDBPath
 	NetRestrict  *netutil.Netlist  // network whitelist
 	Bootnodes    []*Node           // list of bootstrap nodes
 	Unhandled    chan<- ReadPacket // unhandled packets are sent on this channel
+	Entries      []enr.Entry       // additional entries of the local node record
 }
 
 // ListenUDP returns a new table that listens for UDP packets on laddr.
@@ -253,7 +296,27 @@ func newUDP(c conn, cfg Config) (*Table, *udp, error) {
 	}
 	// TODO: separate TCP port
 	udp.ourEndpoint = makeEndpoint(realaddr, uint16(realaddr.Port))
-	tab, err := newTable(udp, PubkeyID(&cfg.PrivateKey.PublicKey), realaddr, cfg.NodeDBPath, cfg.Bootnodes)
+
+	// Sign the record of the local node, announcing its endpoint and entries
+	udp.record = new(enr.Record)
+	udp.record.Set(enr.IP(udp.ourEndpoint.IP))
+	udp.record.Set(enr.UDP(udp.ourEndpoint.UDP))
+	udp.record.Set(enr.TCP(udp.ourEndpoint.TCP))
+	for _, entry := range cfg.Entries {
+		udp.record.Set(entry)
+	}
+	if err := enr.SignV4(udp.record, cfg.PrivateKey); err != nil {
+		return nil, nil, fmt.Errorf("can't sign node record: %v", err)
+	}
+	var (
+		tab *Table
+		err error
+	)
+	if cfg.NodeDB != nil {
+		tab, err = newTableWithDB(udp, PubkeyID(&cfg.PrivateKey.PublicKey), realaddr, cfg.NodeDB.db, false, cfg.Bootnodes)
+	} else {
+		tab, err = newTable(udp, PubkeyID(&cfg.PrivateKey.PublicKey), realaddr, cfg.NodeDBPath, cfg.Bootnodes)
+	}
 	if err != nil {
 		return nil, nil, err
 	}
@@ -264,29 +327,63 @@ func newUDP(c conn, cfg Config) (*Table, *udp, error) {
 	return udp.Table, udp, nil
 }
 
+// localRecord returns the current signed record of the local node.
+func (t *udp) localRecord() *enr.Record {
+	t.recordMu.Lock()
+	defer t.recordMu.Unlock()
+
+	return t.record
+}
+
+// setEntry sets an entry of the local node record and signs it again, with an
+// increased sequence number. The record is copied, so the previous one can be
+// sent out concurrently.
+func (t *udp) setEntry(entry enr.Entry) error {
+	t.recordMu.Lock()
+	defer t.recordMu.Unlock()
+
+	record := *t.record
+	record.Set(entry)
+	if err := enr.SignV4(&record, t.priv); err != nil {
+		return fmt.Errorf("can't sign node record: %v", err)
+	}
+	t.record = &record
+	return nil
+}
+
 func (t *udp) close() {
 	close(t.closing)
 	t.conn.Close()
 	// TODO: wait for the loops to end.
 }
 
-// ping sends a ping message to the given node and waits for a reply.
-func (t *udp) ping(toid NodeID, toaddr *net.UDPAddr) error {
+// ping sends a ping message to the given node and waits for a reply, returning
+// the sequence number of the node record announced by the remote node.
+func (t *udp) ping(toid NodeID, toaddr *net.UDPAddr) (uint64, error) {
 	req := &ping{
 		Version:    Version,
 		From:       t.ourEndpoint,
 		To:         makeEndpoint(toaddr, 0), // TODO: maybe use known TCP port from DB
 		Expiration: uint64(time.Now().Add(expiration).Unix()),
+		Rest:       seqTail(t.localRecord().Seq()),
 	}
 	packet, hash, err := encodePacket(t.priv, pingPacket, req)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	var seq uint64
 	errc := t.pending(toid, pongPacket, func(p interface{}) bool {
-		return bytes.Equal(p.(*pong).ReplyTok, hash)
+		matched := bytes.Equal(p.(*pong).ReplyTok, hash)
+		if matched {
+			seq = tailSeq(p.(*pong).Rest)
+		}
+		return matched
 	})
 	t.write(toaddr, req.name(), packet)
-	return <-errc
+	if err := <-errc; err != nil {
+		return 0, err
+	}
+	return seq, nil
 }
 
 func (t *udp) waitping(from NodeID) error {
@@ -319,6 +416,39 @@ func (t *udp) findnode(toid NodeID, toaddr *net.UDPAddr, target NodeID) ([]*Node
 	return nodes, err
 }
 
+// requestENR sends an enrRequest to the given node and waits for its record,
+// ensuring that the record was signed by the node.
+func (t *udp) requestENR(toid NodeID, toaddr *net.UDPAddr) (*enr.Record, error) {
+	req := &enrRequest{
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	}
+	packet, hash, err := encodePacket(t.priv, enrRequestPacket, req)
+	if err != nil {
+		return nil, err
+	}
+	var record *enr.Record
+	errc := t.pending(toid, enrResponsePacket, func(r interface{}) bool {
+		reply := r.(*enrResponse)
+		if !bytes.Equal(reply.ReplyTok, hash) {
+			return false
+		}
+		record = &reply.Record
+		return true
+	})
+	t.write(toaddr, req.name(), packet)
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	var pubkey enr.Secp256k1
+	if err := record.Load(&pubkey); err != nil {
+		return nil, err
+	}
+	if PubkeyID((*ecdsa.PublicKey)(&pubkey)) != toid {
+		return nil, errRecordSigner
+	}
+	return record, nil
+}
+
 // pending adds a reply callback to the pending reply queue.
 // see the documentation of type pending for a detailed explanation.
 func (t *udp) pending(id NodeID, ptype byte, callback func(interface{}) bool) <-chan error {
@@ -570,6 +700,10 @@ func decodePacket(buf []byte) (packet, NodeID, []byte, error) {
 		req = new(findnode)
 	case neighborsPacket:
 		req = new(neighbors)
+	case enrRequestPacket:
+		req = new(enrRequest)
+	case enrResponsePacket:
+		req = new(enrResponse)
 	default:
 		return nil, fromID, hash, fmt.Errorf("unknown type: %d", ptype)
 	}
@@ -586,6 +720,7 @@ func (req *ping) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) er
 		To:         makeEndpoint(from, req.From.TCP),
 		ReplyTok:   mac,
 		Expiration: uint64(time.Now().Add(expiration).Unix()),
+		Rest:       seqTail(t.localRecord().Seq()),
 	})
 	if !t.handleReply(fromID, pingPacket, req) {
 		// Note: we're ignoring the provided IP address right now
@@ -661,6 +796,32 @@ func (req *neighbors) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byt
 
 func (req *neighbors) name() string { return "NEIGHBORS/v4" }
 
+func (req *enrRequest) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	if !t.db.hasBond(fromID) {
+		// Records are only served to bonded nodes, like neighbors.
+		return errUnknownNode
+	}
+	t.send(from, enrResponsePacket, &enrResponse{
+		ReplyTok: mac,
+		Record:   *t.localRecord(),
+	})
+	return nil
+}
+
+func (req *enrRequest) name() string { return "ENRREQUEST/v4" }
+
+func (req *enrResponse) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if !t.handleReply(fromID, enrResponsePacket, req) {
+		return errUnsolicitedReply
+	}
+	return nil
+}
+
+func (req *enrResponse) name() string { return "ENRRESPONSE/v4" }
+
 func expired(ts uint64) bool {
 	return time.Unix(int64(ts), 0).Before(time.Now())
 }
@@ -36,6 +36,7 @@ import (
 	"github.com/davecgh/go-spew/spew"
 	"github.com/genchain/go-genchain/common"
 	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/p2p/enr"
 	"github.com/genchain/go-genchain/rlp"
 )
 
@@ -137,7 +138,7 @@ func TestUDP_pingTimeout(t *testing.T) {
 
 	toaddr := &net.UDPAddr{IP: net.ParseIP("1.2.3.4"), Port: 2222}
 	toid := NodeID{1, 2, 3, 4}
-	if err := test.udp.ping(toid, toaddr); err != errTimeout {
+	if _, err := test.udp.ping(toid, toaddr); err != errTimeout {
 		t.Error("expected timeout error, got", err)
 	}
 }
@@ -386,6 +387,127 @@ func TestUDP_successfulPing(t *testing.T) {
 	}
 }
 
+func TestUDP_ENRRequest(t *testing.T) {
+	t.Parallel()
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	// Records are not served to unbonded nodes.
+	test.packetIn(errUnknownNode, enrRequestPacket, &enrRequest{Expiration: futureExp})
+
+	// Once bonded, the signed local record is returned.
+	test.table.db.updateBondTime(PubkeyID(&test.remotekey.PublicKey), time.Now())
+	test.packetIn(nil, enrRequestPacket, &enrRequest{Expiration: futureExp})
+	test.waitPacketOut(func(p *enrResponse) {
+		if reqhash := test.sent[len(test.sent)-1][:macSize]; !bytes.Equal(p.ReplyTok, reqhash) {
+			t.Errorf("got enrResponse.ReplyTok %x, want %x", p.ReplyTok, reqhash)
+		}
+		if p.Record.Seq() != test.udp.record.Seq() {
+			t.Errorf("wrong record seq: got %d, want %d", p.Record.Seq(), test.udp.record.Seq())
+		}
+		var pubkey enr.Secp256k1
+		if err := p.Record.Load(&pubkey); err != nil {
+			t.Fatalf("can't load record key: %v", err)
+		}
+		if PubkeyID((*ecdsa.PublicKey)(&pubkey)) != PubkeyID(&test.localkey.PublicKey) {
+			t.Errorf("record has wrong key")
+		}
+		var udp enr.UDP
+		if err := p.Record.Load(&udp); err != nil || uint16(udp) != test.udp.ourEndpoint.UDP {
+			t.Errorf("record has wrong UDP port: got %d, want %d (err %v)", udp, test.udp.ourEndpoint.UDP, err)
+		}
+	})
+}
+
+func TestUDP_requestENR(t *testing.T) {
+	t.Parallel()
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	remoteID := PubkeyID(&test.remotekey.PublicKey)
+	sign := func(key *ecdsa.PrivateKey) enr.Record {
+		var r enr.Record
+		r.Set(enr.UDP(test.remoteaddr.Port))
+		if err := enr.SignV4(&r, key); err != nil {
+			t.Fatalf("can't sign record: %v", err)
+		}
+		return r
+	}
+	for _, tt := range []struct {
+		key     *ecdsa.PrivateKey
+		wantErr error
+	}{
+		{key: test.remotekey},
+		{key: newkey(), wantErr: errRecordSigner},
+	} {
+		type result struct {
+			record *enr.Record
+			err    error
+		}
+		done := make(chan result, 1)
+		go func() {
+			record, err := test.udp.requestENR(remoteID, test.remoteaddr)
+			done <- result{record, err}
+		}()
+		hash, _ := test.waitPacketOut(func(p *enrRequest) {})
+		test.packetIn(nil, enrResponsePacket, &enrResponse{ReplyTok: hash, Record: sign(tt.key)})
+
+		res := <-done
+		if res.err != tt.wantErr {
+			t.Fatalf("error mismatch: got %v, want %v", res.err, tt.wantErr)
+		}
+		if tt.wantErr == nil && res.record.NodeAddr() == nil {
+			t.Errorf("invalid record returned")
+		}
+	}
+}
+
+func TestUDP_setEntry(t *testing.T) {
+	t.Parallel()
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	old := test.udp.localRecord()
+	if err := test.table.SetEntry(enr.TCP(30311)); err != nil {
+		t.Fatalf("can't set entry: %v", err)
+	}
+	record := test.udp.localRecord()
+	if record.Seq() <= old.Seq() {
+		t.Errorf("record seq not increased: got %d, previous %d", record.Seq(), old.Seq())
+	}
+	var tcp enr.TCP
+	if err := record.Load(&tcp); err != nil || tcp != 30311 {
+		t.Errorf("record has wrong TCP port: got %d, want 30311 (err %v)", tcp, err)
+	}
+	if err := old.Load(&tcp); err != nil || uint16(tcp) != test.udp.ourEndpoint.TCP {
+		t.Errorf("previous record modified: TCP port %d", tcp)
+	}
+	var pubkey enr.Secp256k1
+	if err := record.Load(&pubkey); err != nil {
+		t.Fatalf("can't load record key: %v", err)
+	}
+	// The new record is announced and served.
+	go test.udp.ping(PubkeyID(&test.remotekey.PublicKey), test.remoteaddr)
+	test.waitPacketOut(func(p *ping) {
+		if seq := tailSeq(p.Rest); seq != record.Seq() {
+			t.Errorf("ping announces wrong seq: got %d, want %d", seq, record.Seq())
+		}
+	})
+}
+
+func TestUDP_pingAnnouncesSeq(t *testing.T) {
+	t.Parallel()
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	go test.udp.ping(PubkeyID(&test.remotekey.PublicKey), test.remoteaddr)
+	test.waitPacketOut(func(p *ping) {
+		if seq := tailSeq(p.Rest); seq != test.udp.record.Seq() {
+			t.Errorf("ping announces wrong seq: got %d, want %d", seq, test.udp.record.Seq())
+		}
+	})
+}
+
 var testPackets = []struct {
 	input      string
 	wantPacket interface{}
@@ -0,0 +1,397 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/genchain/go-genchain/log"
+	"github.com/genchain/go-genchain/p2p/discover"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// retryDelay is the time a failing tree is skipped by iterators before being
+// queried again.
+const retryDelay = 10 * time.Second
+
+// Client discovers nodes by querying DNS servers.
+type Client struct {
+	cfg     Config
+	entries *lru.Cache
+}
+
+// Config holds configuration options for the client.
+type Config struct {
+	Timeout         time.Duration // timeout used for DNS lookups (default 5s)
+	RecheckInterval time.Duration // time between tree root update checks (default 30min)
+	CacheLimit      int           // maximum number of cached records (default 1000)
+	Resolver        Resolver      // the DNS resolver to use (defaults to system DNS)
+}
+
+// Resolver is a DNS resolver that can query TXT records.
+type Resolver interface {
+	LookupTXT(ctx context.Context, domain string) ([]string, error)
+}
+
+func (cfg Config) withDefaults() Config {
+	const (
+		defaultTimeout = 5 * time.Second
+		defaultRecheck = 30 * time.Minute
+		defaultCache   = 1000
+	)
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.RecheckInterval == 0 {
+		cfg.RecheckInterval = defaultRecheck
+	}
+	if cfg.CacheLimit == 0 {
+		cfg.CacheLimit = defaultCache
+	}
+	if cfg.Resolver == nil {
+		cfg.Resolver = new(net.Resolver)
+	}
+	return cfg
+}
+
+// NewClient creates a client.
+func NewClient(cfg Config) *Client {
+	cfg = cfg.withDefaults()
+	cache, err := lru.New(cfg.CacheLimit)
+	if err != nil {
+		panic(err)
+	}
+	return &Client{cfg: cfg, entries: cache}
+}
+
+// SyncTree downloads the entire node tree at the given URL, verifying it.
+func (c *Client) SyncTree(url string) (*Tree, error) {
+	le, err := parseLink(url)
+	if err != nil {
+		return nil, err
+	}
+	ct := &clientTree{c: c, loc: le}
+	if err := ct.updateRoot(); err != nil {
+		return nil, err
+	}
+	t := &Tree{root: ct.root, entries: make(map[string]entry)}
+	if err := ct.syncSubtree(ct.root.lroot, true, t.entries); err != nil {
+		return nil, err
+	}
+	if err := ct.syncSubtree(ct.root.eroot, false, t.entries); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// resolveRoot retrieves a root entry via DNS, verifying its signature.
+func (c *Client) resolveRoot(loc *linkEntry) (rootEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+	defer cancel()
+
+	txts, err := c.cfg.Resolver.LookupTXT(ctx, loc.domain)
+	log.Trace("Updating DNS discovery root", "tree", loc.domain, "err", err)
+	if err != nil {
+		return rootEntry{}, err
+	}
+	for _, txt := range txts {
+		if strings.HasPrefix(txt, rootPrefix) {
+			root, err := parseRoot(txt)
+			if err != nil {
+				return rootEntry{}, nameError{loc.domain, err}
+			}
+			if !root.verifySignature(loc.pubkey) {
+				return rootEntry{}, nameError{loc.domain, entryError{"root", errInvalidSig}}
+			}
+			return root, nil
+		}
+	}
+	return rootEntry{}, nameError{loc.domain, errNoRoot}
+}
+
+// resolveEntry retrieves an entry from the cache or fetches it from the network
+// if it isn't cached. Entries are verified against the hash they are named by.
+func (c *Client) resolveEntry(domain, hash string) (entry, error) {
+	if e, ok := c.entries.Get(hash); ok {
+		return e.(entry), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+	defer cancel()
+
+	name := hash + "." + domain
+	txts, err := c.cfg.Resolver.LookupTXT(ctx, name)
+	log.Trace("DNS discovery lookup", "name", name, "err", err)
+	if err != nil {
+		return nil, err
+	}
+	for _, txt := range txts {
+		// The hash check ensures that entries cannot be forged by the DNS
+		// servers, only the signed root needs to be trusted.
+		if entryHash(txt) != hash {
+			continue
+		}
+		e, err := parseEntry(txt)
+		if err != nil {
+			return nil, nameError{name, err}
+		}
+		c.entries.Add(hash, e)
+		return e, nil
+	}
+	if len(txts) > 0 {
+		return nil, nameError{name, errHashMismatch}
+	}
+	return nil, nameError{name, errNoEntry}
+}
+
+// clientTree is the client-side state of a tree being synced.
+type clientTree struct {
+	c             *Client
+	loc           *linkEntry
+	root          *rootEntry
+	lastRootCheck time.Time
+	retryAt       time.Time // time before which failing trees are skipped
+}
+
+// updateRoot retrieves the current root of the tree. Changed roots are only
+// accepted with a higher sequence number, so old roots can't be replayed.
+func (ct *clientTree) updateRoot() error {
+	root, err := ct.c.resolveRoot(ct.loc)
+	if err != nil {
+		return err
+	}
+	if ct.root != nil {
+		if ct.root.seq == root.seq && ct.root.eroot == root.eroot && ct.root.lroot == root.lroot {
+			return nil
+		}
+		if root.seq <= ct.root.seq {
+			return nameError{ct.loc.domain, errStaleRoot}
+		}
+	}
+	log.Debug("Updated DNS discovery tree", "tree", ct.loc.domain, "seq", root.seq)
+	ct.root = &root
+	return nil
+}
+
+// syncSubtree fetches all entries below the given hash into dest.
+func (ct *clientTree) syncSubtree(hash string, link bool, dest map[string]entry) error {
+	e, err := ct.c.resolveEntry(ct.loc.domain, hash)
+	if err != nil {
+		return err
+	}
+	dest[hash] = e
+
+	switch e := e.(type) {
+	case *branchEntry:
+		for _, child := range e.children {
+			if err := ct.syncSubtree(child, link, dest); err != nil {
+				return err
+			}
+		}
+	case *linkEntry:
+		if !link {
+			return errLinkInENRTree
+		}
+	case *enrEntry:
+		if link {
+			return errENRInLinkTree
+		}
+	}
+	return nil
+}
+
+// links retrieves all links of the current tree root.
+func (ct *clientTree) links() ([]*linkEntry, error) {
+	entries := make(map[string]entry)
+	if err := ct.syncSubtree(ct.root.lroot, true, entries); err != nil {
+		return nil, err
+	}
+	var links []*linkEntry
+	for _, e := range entries {
+		if le, ok := e.(*linkEntry); ok {
+			links = append(links, le)
+		}
+	}
+	return links, nil
+}
+
+// randomNode walks the node list of the tree from its root down to a random
+// node record.
+func (ct *clientTree) randomNode() (*discover.Node, error) {
+	hash := ct.root.eroot
+	for {
+		e, err := ct.c.resolveEntry(ct.loc.domain, hash)
+		if err != nil {
+			return nil, err
+		}
+		switch e := e.(type) {
+		case *branchEntry:
+			if len(e.children) == 0 {
+				return nil, errEmptyTree
+			}
+			hash = e.children[rand.Intn(len(e.children))]
+		case *enrEntry:
+			return discover.NewNodeFromRecord(e.record)
+		default:
+			return nil, errLinkInENRTree
+		}
+	}
+}
+
+// Iterator walks the node lists of a set of trees and the trees they link to,
+// returning their nodes in random order. Trees are checked for updates every
+// recheck interval.
+type Iterator struct {
+	c     *Client
+	trees map[string]*clientTree // trees by URL
+	order []*clientTree          // trees in round-robin order
+	next  int
+	cur   *discover.Node
+
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewIterator creates an iterator walking the trees at the given URLs.
+func (c *Client) NewIterator(urls ...string) (*Iterator, error) {
+	it := &Iterator{
+		c:       c,
+		trees:   make(map[string]*clientTree),
+		closing: make(chan struct{}),
+	}
+	for _, url := range urls {
+		le, err := parseLink(url)
+		if err != nil {
+			return nil, err
+		}
+		it.addTree(le)
+	}
+	return it, nil
+}
+
+// addTree adds a tree to the iterator, unless already present.
+func (it *Iterator) addTree(le *linkEntry) {
+	url := le.url()
+	if _, ok := it.trees[url]; ok {
+		return
+	}
+	ct := &clientTree{c: it.c, loc: le}
+	it.trees[url] = ct
+	it.order = append(it.order, ct)
+}
+
+// Next moves the iterator to the next node, blocking until one is found. It
+// returns false when the iterator is closed.
+func (it *Iterator) Next() bool {
+	it.cur = nil
+	for it.cur == nil {
+		ct := it.nextTree()
+		if ct == nil {
+			return false
+		}
+		n, err := it.nextNode(ct)
+		if err != nil {
+			log.Debug("Error in DNS discovery", "tree", ct.loc.domain, "err", err)
+			ct.retryAt = time.Now().Add(retryDelay)
+			continue
+		}
+		it.cur = n
+	}
+	return true
+}
+
+// Node returns the current node.
+func (it *Iterator) Node() *discover.Node {
+	return it.cur
+}
+
+// Close ends the iteration, unblocking any pending call to Next.
+func (it *Iterator) Close() {
+	it.closeOnce.Do(func() { close(it.closing) })
+}
+
+// nextTree returns the next tree to take a node from, waiting for failing
+// trees to become eligible again. It returns nil if the iterator is closed.
+func (it *Iterator) nextTree() *clientTree {
+	for {
+		select {
+		case <-it.closing:
+			return nil
+		default:
+		}
+		if len(it.order) == 0 {
+			return nil
+		}
+		// Pick the next tree not recently failing, tracking the earliest one
+		// to retry in case all of them are.
+		now := time.Now()
+		earliest := it.order[0].retryAt
+		for i := 0; i < len(it.order); i++ {
+			ct := it.order[(it.next+i)%len(it.order)]
+			if !now.Before(ct.retryAt) {
+				it.next = (it.next + i + 1) % len(it.order)
+				return ct
+			}
+			if ct.retryAt.Before(earliest) {
+				earliest = ct.retryAt
+			}
+		}
+		timer := time.NewTimer(earliest.Sub(now))
+		select {
+		case <-timer.C:
+		case <-it.closing:
+			timer.Stop()
+			return nil
+		}
+	}
+}
+
+// nextNode returns a random node of the given tree, updating the root and the
+// linked trees first if they weren't checked recently. A tree which fails to
+// update keeps being walked from its last verified root.
+func (it *Iterator) nextNode(ct *clientTree) (*discover.Node, error) {
+	if ct.root == nil || time.Since(ct.lastRootCheck) > it.c.cfg.RecheckInterval {
+		synced := ct.root != nil
+		if err := it.updateTree(ct); err != nil {
+			if !synced {
+				return nil, err
+			}
+			log.Debug("Failed to update DNS discovery tree, keeping old root", "tree", ct.loc.domain, "seq", ct.root.seq, "err", err)
+		}
+		ct.lastRootCheck = time.Now()
+	}
+	return ct.randomNode()
+}
+
+// updateTree updates the root of the given tree and adds the trees it links to.
+func (it *Iterator) updateTree(ct *clientTree) error {
+	if err := ct.updateRoot(); err != nil {
+		return err
+	}
+	links, err := ct.links()
+	if err != nil {
+		return err
+	}
+	for _, le := range links {
+		it.addTree(le)
+	}
+	return nil
+}
@@ -0,0 +1,340 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/rand"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/p2p/discover"
+	"github.com/genchain/go-genchain/p2p/enr"
+)
+
+const (
+	signingKeySeed = 0x111111
+	nodesSeed1     = 0x2945237
+	nodesSeed2     = 0x4567299
+)
+
+// Tests that a full tree can be downloaded and verified.
+func TestClientSyncTree(t *testing.T) {
+	var (
+		key   = testKey(signingKeySeed)
+		nodes = testNodes(nodesSeed1, 30)
+	)
+	tree, url := makeTestTree(t, key, "n", nodes, nil)
+	c := NewClient(Config{Resolver: newMapResolver(tree.ToTXT("n"))})
+
+	synced, err := c.SyncTree(url)
+	if err != nil {
+		t.Fatal("sync error:", err)
+	}
+	if !reflect.DeepEqual(synced.Nodes(), sortedNodes(nodes)) {
+		t.Errorf("wrong nodes in synced tree")
+	}
+	if synced.Seq() != tree.Seq() || synced.Signature() != tree.Signature() {
+		t.Errorf("wrong root in synced tree")
+	}
+}
+
+// Tests that trees signed by another key, or with forged entries, are refused.
+func TestClientSyncTreeBad(t *testing.T) {
+	var (
+		key   = testKey(signingKeySeed)
+		other = testKey(nodesSeed2)
+		nodes = testNodes(nodesSeed1, 3)
+	)
+	tree, url := makeTestTree(t, key, "n", nodes, nil)
+
+	// A tree signed by another key
+	forged, _ := makeTestTree(t, other, "n", nodes, nil)
+	c := NewClient(Config{Resolver: newMapResolver(forged.ToTXT("n"))})
+	if _, err := c.SyncTree(url); err == nil {
+		t.Errorf("tree signed by another key accepted")
+	}
+	// A tree with a replaced node record
+	records := tree.ToTXT("n")
+	for name, txt := range records {
+		if name != "n" && txt[:len(enrPrefix)] == enrPrefix {
+			records[name] = (&enrEntry{testNodes(nodesSeed2, 1)[0]}).String()
+			break
+		}
+	}
+	c = NewClient(Config{Resolver: newMapResolver(records)})
+	if _, err := c.SyncTree(url); err == nil {
+		t.Errorf("tree with forged entry accepted")
+	}
+}
+
+// Tests that root updates are only accepted with a higher sequence number.
+func TestClientTreeUpdateRoot(t *testing.T) {
+	var (
+		key    = testKey(signingKeySeed)
+		nodes1 = testNodes(nodesSeed1, 3)
+		nodes2 = testNodes(nodesSeed2, 3)
+	)
+	resolver := newMapResolver()
+	publish := func(seq uint, nodes []*enr.Record) *linkEntry {
+		tree, err := MakeTree(seq, nodes, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		url, err := tree.Sign(key, "n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, txt := range tree.ToTXT("n") {
+			resolver[name] = txt
+		}
+		le, err := parseLink(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return le
+	}
+	ct := &clientTree{c: NewClient(Config{Resolver: resolver}), loc: publish(2, nodes1)}
+	if err := ct.updateRoot(); err != nil {
+		t.Fatal("initial update failed:", err)
+	}
+	current := ct.root
+
+	// Unchanged roots are accepted, changed ones need a higher sequence number
+	if err := ct.updateRoot(); err != nil || ct.root != current {
+		t.Errorf("unchanged root: err %v, root replaced %v", err, ct.root != current)
+	}
+	publish(1, nodes1)
+	if err := ct.updateRoot(); err == nil || ct.root != current {
+		t.Errorf("older root: err %v, root replaced %v", err, ct.root != current)
+	}
+	publish(2, nodes2)
+	if err := ct.updateRoot(); err == nil || ct.root != current {
+		t.Errorf("changed root with same seq: err %v, root replaced %v", err, ct.root != current)
+	}
+	publish(3, nodes2)
+	if err := ct.updateRoot(); err != nil || ct.root.seq != 3 {
+		t.Errorf("newer root: err %v, seq %d", err, ct.root.seq)
+	}
+}
+
+// Tests that the iterator returns the nodes of linked trees, and skips the nodes
+// of trees it can't retrieve.
+func TestIteratorLinks(t *testing.T) {
+	var (
+		key1   = testKey(signingKeySeed)
+		key2   = testKey(nodesSeed2)
+		nodes1 = testNodes(nodesSeed1, 10)
+		nodes2 = testNodes(nodesSeed2, 10)
+	)
+	tree2, url2 := makeTestTree(t, key2, "n2", nodes2, nil)
+	tree1, url1 := makeTestTree(t, key1, "n1", nodes1, []string{url2})
+
+	resolver := newMapResolver(tree1.ToTXT("n1"), tree2.ToTXT("n2"))
+	// Add a linked tree that can't be resolved
+	_, url3 := makeTestTree(t, testKey(0x99), "n3", testNodes(0x99, 1), nil)
+
+	it, err := NewClient(Config{Resolver: resolver}).NewIterator(url1, url3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	want := make(map[discover.NodeID]bool)
+	for _, r := range append(nodes1, nodes2...) {
+		n, _ := discover.NewNodeFromRecord(r)
+		want[n.ID] = true
+	}
+	seen := make(map[discover.NodeID]bool)
+	for i := 0; i < 1000 && len(seen) < len(want); i++ {
+		if !it.Next() {
+			t.Fatal("iterator ended")
+		}
+		n := it.Node()
+		if !want[n.ID] {
+			t.Fatalf("unexpected node %v", n)
+		}
+		if n.Record() == nil {
+			t.Fatalf("node %v without record", n)
+		}
+		seen[n.ID] = true
+	}
+	if len(seen) != len(want) {
+		t.Fatalf("only %d of %d nodes seen", len(seen), len(want))
+	}
+}
+
+// Tests that the iterator keeps walking the last verified root of a tree when
+// the root is replaced by an older one.
+func TestIteratorStaleRoot(t *testing.T) {
+	var (
+		key    = testKey(signingKeySeed)
+		nodes1 = testNodes(nodesSeed1, 5)
+		nodes2 = testNodes(nodesSeed2, 5)
+	)
+	publish := func(resolver mapResolver, seq uint, nodes []*enr.Record) string {
+		tree, err := MakeTree(seq, nodes, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		url, err := tree.Sign(key, "n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, txt := range tree.ToTXT("n") {
+			resolver[name] = txt
+		}
+		return url
+	}
+	resolver := newMapResolver()
+	url := publish(resolver, 2, nodes1)
+
+	it, err := NewClient(Config{Resolver: resolver, RecheckInterval: time.Nanosecond}).NewIterator(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	want := make(map[discover.NodeID]bool)
+	for _, r := range nodes1 {
+		n, _ := discover.NewNodeFromRecord(r)
+		want[n.ID] = true
+	}
+	next := func() {
+		t.Helper()
+		done := make(chan bool, 1)
+		go func() { done <- it.Next() }()
+		select {
+		case ok := <-done:
+			if !ok {
+				t.Fatal("iterator ended")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no node returned")
+		}
+		if n := it.Node(); !want[n.ID] {
+			t.Fatalf("unexpected node %v", n)
+		}
+	}
+	next()
+
+	// Serve an older root, the nodes of the verified one are still returned
+	publish(resolver, 1, nodes2)
+	for i := 0; i < 10; i++ {
+		next()
+	}
+}
+
+// Tests that closing the iterator unblocks Next.
+func TestIteratorClose(t *testing.T) {
+	_, url := makeTestTree(t, testKey(signingKeySeed), "n", testNodes(nodesSeed1, 1), nil)
+	it, err := NewClient(Config{Resolver: newMapResolver()}).NewIterator(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan bool)
+	go func() { done <- it.Next() }()
+
+	time.Sleep(50 * time.Millisecond)
+	it.Close()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatal("Next returned true without any resolvable tree")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Next didn't return after Close")
+	}
+}
+
+func makeTestTree(t *testing.T, key *ecdsa.PrivateKey, domain string, nodes []*enr.Record, links []string) (*Tree, string) {
+	tree, err := MakeTree(1, nodes, links)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, err := tree.Sign(key, domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree, url
+}
+
+// testKeys creates deterministic private keys for testing.
+func testKeys(seed int64, n int) []*ecdsa.PrivateKey {
+	rand := rand.New(rand.NewSource(seed))
+	keys := make([]*ecdsa.PrivateKey, n)
+	for i := 0; i < n; i++ {
+		key, err := ecdsa.GenerateKey(crypto.S256(), rand)
+		if err != nil {
+			panic("can't generate key: " + err.Error())
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+func testKey(seed int64) *ecdsa.PrivateKey {
+	return testKeys(seed, 1)[0]
+}
+
+func testNodes(seed int64, n int) []*enr.Record {
+	keys := testKeys(seed, n)
+	nodes := make([]*enr.Record, n)
+	for i, key := range keys {
+		var r enr.Record
+		r.SetSeq(uint64(i))
+		r.Set(enr.IP(net.IP{127, 0, 0, byte(i + 1)}))
+		r.Set(enr.TCP(30303))
+		r.Set(enr.UDP(30303))
+		if err := enr.SignV4(&r, key); err != nil {
+			panic(err)
+		}
+		nodes[i] = &r
+	}
+	return nodes
+}
+
+func sortedNodes(nodes []*enr.Record) []*enr.Record {
+	sorted := make([]*enr.Record, len(nodes))
+	copy(sorted, nodes)
+	sortByEncoding(sorted)
+	return sorted
+}
+
+// mapResolver is a DNS resolver serving records from memory.
+type mapResolver map[string]string
+
+func newMapResolver(maps ...map[string]string) mapResolver {
+	mr := make(mapResolver)
+	for _, m := range maps {
+		for name, txt := range m {
+			mr[name] = txt
+		}
+	}
+	return mr
+}
+
+func (mr mapResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if record, ok := mr[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name}
+}
@@ -0,0 +1,164 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/genchain/go-genchain/crypto"
+)
+
+func TestParseRoot(t *testing.T) {
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{
+			input: "enrtree-root:v1 e=TO4Q75OQ2N7DX4EOOR7X66A6OM seq=3 sig=N-YY6UB9xD0hFx1Gmnt7v0RfSxch5tKyry2SRDoLx7B4GfPXagwLxQqyf7gAMvApFn_ORwZQekMWa_pXrcGCtw",
+			err:   entryError{"root", errSyntax},
+		},
+		{
+			input: "enrtree-root:v1 e=TO4Q75OQ2N7DX4EOOR7X66A6OM l=TO4Q75OQ2N7DX4EOOR7X66A6OM seq=3 sig=N-YY6UB9xD0hFx1Gmnt7v0RfSxch5tKyry2SRDoLx7B4GfPXagwLxQqyf7gAMvApFn_ORwZQekMWa_pXrcGCtw",
+			err:   entryError{"root", errInvalidSig},
+		},
+		{
+			input: "enrtree-root:v1 e=TO4Q75OQ2 l=TO4Q75OQ2N7DX4EOOR7X66A6OM seq=3 sig=N-YY6UB9xD0hFx1Gmnt7v0RfSxch5tKyry2SRDoLx7B4GfPXagwLxQqyf7gAMvApFn_ORwZQekMWa_pXrcGCtwE",
+			err:   entryError{"root", errInvalidChild},
+		},
+	}
+	for i, test := range tests {
+		if _, err := parseRoot(test.input); !reflect.DeepEqual(err, test.err) {
+			t.Errorf("test %d: wrong error %v, want %v", i, err, test.err)
+		}
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	testkey := testKey(signingKeySeed)
+	tests := []struct {
+		input string
+		e     entry
+		err   error
+	}{
+		// Subtrees:
+		{
+			input: "enrtree-branch:1,2",
+			err:   entryError{"branch", errInvalidChild},
+		},
+		{
+			input: "enrtree-branch:AAAAAAAAAAAAAAAAAAAAAAAAAA",
+			e:     &branchEntry{[]string{"AAAAAAAAAAAAAAAAAAAAAAAAAA"}},
+		},
+		{
+			input: "enrtree-branch:",
+			e:     &branchEntry{},
+		},
+		{
+			input: "enrtree-branch:AAAAAAAAAAAAAAAAAAAAAAAAAA,BBBBBBBBBBBBBBBBBBBBBBBBBB",
+			e:     &branchEntry{[]string{"AAAAAAAAAAAAAAAAAAAAAAAAAA", "BBBBBBBBBBBBBBBBBBBBBBBBBB"}},
+		},
+		// Links
+		{
+			input: "enrtree://" + b32format.EncodeToString(crypto.CompressPubkey(&testkey.PublicKey)) + "@nodes.example.org",
+			e:     &linkEntry{"nodes.example.org", &testkey.PublicKey},
+		},
+		{
+			input: "enrtree://nodes.example.org",
+			err:   entryError{"link", errNoPubkey},
+		},
+		{
+			input: "enrtree://AP62DT7WOTEQZGQZOU474PP3KMEGVTTE7A7NPRXKX3DUD57@nodes.example.org",
+			err:   entryError{"link", errBadPubkey},
+		},
+		// ENRs
+		{
+			input: "enr:-HW4QES8QIeXTYlDzbfr1WEzE-XKY4f8gJFJzjJL-9D7TC9lJb4Z3JPRRz1lP4pL_N_QpT6rGQjAU9Apnc-C1iMP36OAgmlkgnY0iXNlY3AyNTZrMaED5IdwfMxdmR8W37HqSFdQLjDkIwBd4Q_MjxgZifgKSdM!",
+			err:   entryError{"enr", errInvalidENR},
+		},
+		// Invalid:
+		{input: "", err: errUnknownEntry},
+		{input: "foo", err: errUnknownEntry},
+		{input: "enrtree", err: errUnknownEntry},
+		{input: "enrtree-x=", err: errUnknownEntry},
+	}
+	for i, test := range tests {
+		e, err := parseEntry(test.input)
+		if !reflect.DeepEqual(e, test.e) {
+			t.Errorf("test %d: wrong entry %#v, want %#v", i, e, test.e)
+		}
+		if !reflect.DeepEqual(err, test.err) {
+			t.Errorf("test %d: wrong error %q, want %q", i, err, test.err)
+		}
+	}
+}
+
+func TestMakeTree(t *testing.T) {
+	nodes := testNodes(nodesSeed1, 50)
+	tree, err := MakeTree(2, nodes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt := tree.ToTXT("")
+	if len(txt) < len(nodes)+1 {
+		t.Fatal("too few TXT records in output")
+	}
+	for name, record := range txt {
+		if name != "" && len(record) > 370 {
+			t.Errorf("entry %s too long: %d bytes", name, len(record))
+		}
+	}
+	if got := tree.Nodes(); !reflect.DeepEqual(got, sortedNodes(nodes)) {
+		t.Errorf("tree nodes mismatch")
+	}
+}
+
+func TestSignTree(t *testing.T) {
+	var (
+		key   = testKey(signingKeySeed)
+		other = testKey(nodesSeed1)
+		nodes = testNodes(nodesSeed1, 4)
+	)
+	tree, err := MakeTree(1, nodes, []string{"enrtree://" + b32format.EncodeToString(crypto.CompressPubkey(&other.PublicKey)) + "@other.example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, err := tree.Sign(key, "nodes.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	domain, pubkey, err := ParseURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if domain != "nodes.example.org" || !reflect.DeepEqual(pubkey, &key.PublicKey) {
+		t.Fatalf("wrong URL %s", url)
+	}
+	if !tree.root.verifySignature(&key.PublicKey) {
+		t.Fatal("signature doesn't verify")
+	}
+	if err := tree.SetSignature(&other.PublicKey, tree.Signature()); err != errInvalidSig {
+		t.Fatalf("signature of another key accepted: %v", err)
+	}
+	if err := tree.SetSignature(&key.PublicKey, tree.Signature()); err != nil {
+		t.Fatalf("own signature refused: %v", err)
+	}
+	if links := tree.Links(); len(links) != 1 || !strings.HasSuffix(links[0], "@other.example.org") {
+		t.Fatalf("wrong links %v", links)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/genchain/go-genchain/common"
 	"github.com/genchain/go-genchain/common/mclock"
 	"github.com/genchain/go-genchain/event"
 	"github.com/genchain/go-genchain/log"
@@ -107,6 +108,9 @@ type Peer struct {
 
 	// events receives message send / receive events if set
 	events *event.Feed
+
+	// rep tracks the reputation of remote nodes if set
+	rep *reputation
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -163,6 +167,52 @@ func (p *Peer) Inbound() bool {
 	return p.rw.flags&inboundConn != 0
 }
 
+// Score returns the current reputation score of the peer.
+func (p *Peer) Score() float64 {
+	if p.rep == nil {
+		return 0
+	}
+	return p.rep.score(p.ID(), time.Now())
+}
+
+// Reward raises the reputation of the peer for useful behaviour, such as
+// delivering valid data that was not requested by us.
+func (p *Peer) Reward(points float64) {
+	if p.rep == nil {
+		return
+	}
+	p.rep.adjust(p.ID(), points, time.Now())
+}
+
+// Penalize lowers the reputation of the peer for misbehaviour. If the score
+// drops to BanThreshold, the peer is banned for DefaultBanDuration and
+// disconnected. Trusted peers are never banned.
+func (p *Peer) Penalize(points float64, reason string) {
+	if p.rep == nil {
+		return
+	}
+	score := p.rep.adjust(p.ID(), -points, time.Now())
+	p.log.Debug("Penalized peer", "points", points, "score", score, "reason", reason)
+
+	if score <= BanThreshold {
+		p.Ban(reason)
+	}
+}
+
+// Ban bans the peer for DefaultBanDuration regardless of its reputation, for
+// misbehaviour that can't be accidental, and disconnects it. Trusted peers are
+// never banned.
+func (p *Peer) Ban(reason string) {
+	if p.rep == nil || p.rw.is(trustedConn) {
+		return
+	}
+	p.log.Info("Banning misbehaving peer", "reason", reason, "duration", common.PrettyDuration(DefaultBanDuration))
+	if err := p.rep.ban(p.ID(), time.Now().Add(DefaultBanDuration)); err != nil {
+		p.log.Warn("Failed to store peer ban", "err", err)
+	}
+	p.Disconnect(DiscUselessPeer)
+}
+
 func newPeer(conn *conn, protocols []Protocol) *Peer {
 	protomap := matchProtocols(protocols, conn.caps, conn)
 	p := &Peer{
@@ -212,6 +262,8 @@ loop:
 			if r, ok := err.(DiscReason); ok {
 				remoteRequested = true
 				reason = r
+			} else if _, ok := err.(*peerError); ok {
+				reason = DiscProtocolError
 			} else {
 				reason = DiscNetworkError
 			}
@@ -228,6 +280,12 @@ loop:
 	close(p.closed)
 	p.rw.close(reason)
 	p.wg.Wait()
+
+	// Remember peers breaking the protocols, so they get banned if they
+	// keep doing so.
+	if !remoteRequested && isProtocolBreach(err) {
+		p.Penalize(protocolBreachPenalty, err.Error())
+	}
 	return remoteRequested, err
 }
 
@@ -283,7 +341,7 @@ func (p *Peer) handle(msg Msg) error {
 		// it's a subprotocol message
 		proto, err := p.getProto(msg.Code)
 		if err != nil {
-			return fmt.Errorf("msg code out of range: %v", msg.Code)
+			return err
 		}
 		select {
 		case proto.in <- msg:
@@ -20,6 +20,7 @@ import (
 	"fmt"
 
 	"github.com/genchain/go-genchain/p2p/discover"
+	"github.com/genchain/go-genchain/p2p/enr"
 )
 
 // Protocol represents a P2P subprotocol implementation.
@@ -52,6 +53,16 @@ type Protocol struct {
 	// about a certain peer in the network. If an info retrieval function is set,
 	// but returns nil, it is assumed that the protocol handshake is still running.
 	PeerInfo func(id discover.NodeID) interface{}
+
+	// Attributes contains protocol specific entries for the node record, which
+	// is announced to other nodes through discovery.
+	Attributes []enr.Entry
+
+	// DialFilter is an optional filter for dial candidates found through
+	// discovery, called with the node records of the candidates. Candidates
+	// rejected by the filters of all protocols are not dialed. Candidates
+	// without a record can't be judged and are always dialed.
+	DialFilter func(*enr.Record) bool
 }
 
 func (p Protocol) cap() Cap {
@@ -0,0 +1,204 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"io"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/genchain/go-genchain/p2p/discover"
+)
+
+const (
+	// MaxScore is the highest reputation a peer can earn by good behaviour.
+	MaxScore = 100
+
+	// BanThreshold is the reputation at which a peer gets banned.
+	BanThreshold = -100
+
+	// DefaultBanDuration is the time a peer is banned for when its reputation
+	// drops to the ban threshold.
+	DefaultBanDuration = 12 * time.Hour
+
+	scoreHalfLife         = 30 * time.Minute // time for a score to decay to half of its value
+	minTrackedScore       = 0.5              // scores decayed below this are forgotten
+	protocolBreachPenalty = 25               // penalty for being dropped on a protocol error
+)
+
+// PeerScore is the reputation of a remote node, as reported by Server.PeerScores.
+type PeerScore struct {
+	ID          discover.NodeID `json:"id"`
+	Score       float64         `json:"score"`
+	BannedUntil *time.Time      `json:"bannedUntil,omitempty"`
+}
+
+// peerScore is a decaying reputation score.
+type peerScore struct {
+	value   float64
+	updated time.Time
+}
+
+// at returns the value of the score decayed up to the given time.
+func (s *peerScore) at(now time.Time) float64 {
+	elapsed := now.Sub(s.updated)
+	if elapsed <= 0 {
+		return s.value
+	}
+	return s.value * math.Pow(0.5, float64(elapsed)/float64(scoreHalfLife))
+}
+
+// reputation tracks the scores of remote nodes reported by the protocols and
+// bans the nodes whose score drops to the ban threshold. Scores are kept in
+// memory only, bans are persisted in the node database.
+type reputation struct {
+	db *discover.NodeDB
+
+	lock      sync.Mutex
+	scores    map[discover.NodeID]*peerScore
+	bans      map[discover.NodeID]time.Time
+	lastPrune time.Time
+}
+
+// newReputation creates a reputation tracker, loading the bans still in effect
+// from the node database.
+func newReputation(db *discover.NodeDB) *reputation {
+	return &reputation{
+		db:     db,
+		scores: make(map[discover.NodeID]*peerScore),
+		bans:   db.Bans(),
+	}
+}
+
+// adjust adds delta to the score of a node and returns the new score.
+func (r *reputation) adjust(id discover.NodeID, delta float64, now time.Time) float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if now.Sub(r.lastPrune) > scoreHalfLife {
+		r.prune(now)
+	}
+	s := r.scores[id]
+	if s == nil {
+		s = new(peerScore)
+		r.scores[id] = s
+	}
+	s.value, s.updated = math.Min(s.at(now)+delta, MaxScore), now
+	return s.value
+}
+
+// score returns the current score of a node.
+func (r *reputation) score(id discover.NodeID, now time.Time) float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if s := r.scores[id]; s != nil {
+		return s.at(now)
+	}
+	return 0
+}
+
+// prune forgets the scores that have decayed to insignificance. The lock must
+// be held.
+func (r *reputation) prune(now time.Time) {
+	for id, s := range r.scores {
+		if math.Abs(s.at(now)) < minTrackedScore {
+			delete(r.scores, id)
+		}
+	}
+	r.lastPrune = now
+}
+
+// ban bans a node until the given time, resetting its score.
+func (r *reputation) ban(id discover.NodeID, until time.Time) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err := r.db.Ban(id, until); err != nil {
+		return err
+	}
+	r.bans[id] = until
+	delete(r.scores, id)
+	return nil
+}
+
+// unban lifts the ban of a node.
+func (r *reputation) unban(id discover.NodeID) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err := r.db.Ban(id, time.Time{}); err != nil {
+		return err
+	}
+	delete(r.bans, id)
+	return nil
+}
+
+// banned reports whether a node is banned at the given time.
+func (r *reputation) banned(id discover.NodeID, now time.Time) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	until, ok := r.bans[id]
+	if ok && !now.Before(until) {
+		// The ban expired, the database entry is dropped on the next load.
+		delete(r.bans, id)
+		return false
+	}
+	return ok
+}
+
+// list returns the scores and bans of all tracked nodes, sorted by score.
+func (r *reputation) list(now time.Time) []PeerScore {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.prune(now)
+	list := make([]PeerScore, 0, len(r.scores)+len(r.bans))
+	for id, s := range r.scores {
+		list = append(list, PeerScore{ID: id, Score: s.at(now)})
+	}
+	for id, until := range r.bans {
+		if !now.Before(until) {
+			delete(r.bans, id)
+			continue
+		}
+		until := until
+		list = append(list, PeerScore{ID: id, Score: BanThreshold, BannedUntil: &until})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Score < list[j].Score })
+	return list
+}
+
+// isProtocolBreach reports whether the error a peer was dropped with was caused
+// by the remote end violating a protocol, rather than by the network.
+func isProtocolBreach(err error) bool {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return false
+	}
+	if _, ok := err.(net.Error); ok {
+		return false
+	}
+	switch discReasonForError(err) {
+	case DiscProtocolError, DiscSubprotocolError:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/genchain/go-genchain/p2p/discover"
+)
+
+func newTestReputation(t *testing.T, path string) (*reputation, *discover.NodeDB) {
+	db, err := discover.OpenNodeDB(path, discover.NodeID{})
+	if err != nil {
+		t.Fatal("can't open node database:", err)
+	}
+	return newReputation(db), db
+}
+
+func TestReputationDecay(t *testing.T) {
+	rep, db := newTestReputation(t, "")
+	defer db.Close()
+
+	var (
+		id  = randomID()
+		now = time.Now()
+	)
+	if score := rep.adjust(id, 40, now); score != 40 {
+		t.Fatalf("wrong score after reward: %v", score)
+	}
+	if score := rep.score(id, now.Add(scoreHalfLife)); math.Abs(score-20) > 1e-9 {
+		t.Errorf("wrong score after one half-life: %v", score)
+	}
+	if score := rep.adjust(id, 1000, now); score != MaxScore {
+		t.Errorf("score not capped: %v", score)
+	}
+	// Scores decayed to insignificance are forgotten.
+	later := now.Add(20 * scoreHalfLife)
+	if list := rep.list(later); len(list) != 0 {
+		t.Errorf("decayed score still listed: %v", list)
+	}
+}
+
+func TestReputationBanPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reputation-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "nodes")
+
+	var (
+		banned = randomID()
+		lifted = randomID()
+		until  = time.Now().Add(time.Hour)
+	)
+	rep, db := newTestReputation(t, path)
+	rep.ban(banned, until)
+	rep.ban(lifted, until)
+	rep.unban(lifted)
+	db.Close()
+
+	rep, db = newTestReputation(t, path)
+	defer db.Close()
+	if !rep.banned(banned, time.Now()) {
+		t.Error("ban lost after reopening the database")
+	}
+	if rep.banned(lifted, time.Now()) {
+		t.Error("lifted ban restored after reopening the database")
+	}
+	if rep.banned(banned, until.Add(time.Second)) {
+		t.Error("ban still in effect after expiry")
+	}
+}
+
+func TestPeerPenalize(t *testing.T) {
+	rep, db := newTestReputation(t, "")
+	defer db.Close()
+
+	p := NewPeer(randomID(), "test", nil)
+	p.rep = rep
+	p.Reward(10)
+	for i := 1; i <= 4; i++ {
+		p.Penalize(protocolBreachPenalty, "test")
+		if rep.banned(p.ID(), time.Now()) {
+			t.Fatalf("peer banned after %d penalties, score %v", i, p.Score())
+		}
+	}
+	p.Penalize(protocolBreachPenalty, "test")
+	if !rep.banned(p.ID(), time.Now()) {
+		t.Fatal("peer not banned below the ban threshold")
+	}
+	if until := db.BanExpiry(p.ID()); time.Until(until) < DefaultBanDuration-time.Minute {
+		t.Errorf("wrong ban expiry %v", until)
+	}
+	// Trusted peers are never banned.
+	trusted := NewPeer(randomID(), "trusted", nil)
+	trusted.rw.flags |= trustedConn
+	trusted.rep = rep
+	for i := 0; i < 10; i++ {
+		trusted.Penalize(protocolBreachPenalty, "test")
+	}
+	if rep.banned(trusted.ID(), time.Now()) {
+		t.Fatal("trusted peer banned")
+	}
+}
+
+func TestPeerBan(t *testing.T) {
+	rep, db := newTestReputation(t, "")
+	defer db.Close()
+
+	// Peers are banned outright, even with the highest reputation.
+	p := NewPeer(randomID(), "test", nil)
+	p.rep = rep
+	p.Reward(MaxScore)
+	p.Ban("test")
+	if !rep.banned(p.ID(), time.Now()) {
+		t.Fatal("peer not banned")
+	}
+	trusted := NewPeer(randomID(), "trusted", nil)
+	trusted.rw.flags |= trustedConn
+	trusted.rep = rep
+	trusted.Ban("test")
+	if rep.banned(trusted.ID(), time.Now()) {
+		t.Fatal("trusted peer banned")
+	}
+}
+
+func TestIsProtocolBreach(t *testing.T) {
+	tests := []struct {
+		err    error
+		breach bool
+	}{
+		{io.EOF, false},
+		{DiscQuitting, false},
+		{errProtocolReturned, false},
+		{&net.OpError{Op: "read", Err: errors.New("reset")}, false},
+		{newPeerError(errInvalidMsgCode, "%d", 99), true},
+		{errors.New("invalid block"), true},
+	}
+	for i, test := range tests {
+		if breach := isProtocolBreach(test.err); breach != test.breach {
+			t.Errorf("test %d (%v): breach %t, want %t", i, test.err, breach, test.breach)
+		}
+	}
+}
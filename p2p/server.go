@@ -31,6 +31,8 @@ import (
 	"github.com/genchain/go-genchain/log"
 	"github.com/genchain/go-genchain/p2p/discover"
 	"github.com/genchain/go-genchain/p2p/discv5"
+	"github.com/genchain/go-genchain/p2p/dnsdisc"
+	"github.com/genchain/go-genchain/p2p/enr"
 	"github.com/genchain/go-genchain/p2p/nat"
 	"github.com/genchain/go-genchain/p2p/netutil"
 )
@@ -93,6 +95,11 @@ type Config struct {
 	// protocol.
 	BootstrapNodesV5 []*discv5.Node `toml:",omitempty"`
 
+	// DNSDiscovery contains the enrtree:// URLs of signed node lists published
+	// in DNS. Their nodes are used as dial candidates, even when discovery is
+	// disabled.
+	DNSDiscovery []string `toml:",omitempty"`
+
 	// Static nodes are used as pre-configured connections which are always
 	// maintained and re-connected on disconnects.
 	StaticNodes []*discover.Node
@@ -162,6 +169,12 @@ type Server struct {
 	lastLookup   time.Time
 	DiscV5       *discv5.Network
 
+	dnsNodes      *dnsdisc.Iterator
+	lastDNSLookup time.Time
+
+	nodedb *discover.NodeDB
+	rep    *reputation
+
 	// These are for Peers, PeerCount (and nothing else).
 	peerOp     chan peerOpFunc
 	peerOpDone chan struct{}
@@ -300,6 +313,44 @@ func (srv *Server) RemovePeer(node *discover.Node) {
 	}
 }
 
+// PeerScores returns the reputation scores of the tracked remote nodes,
+// including the banned ones.
+func (srv *Server) PeerScores() []PeerScore {
+	if srv.rep == nil {
+		return nil
+	}
+	return srv.rep.list(time.Now())
+}
+
+// BanPeer bans the given node for the given duration, disconnecting it if it is
+// connected. Banned nodes are neither dialed nor accepted, unless trusted.
+func (srv *Server) BanPeer(id discover.NodeID, duration time.Duration) error {
+	if srv.rep == nil {
+		return errServerStopped
+	}
+	if err := srv.rep.ban(id, time.Now().Add(duration)); err != nil {
+		return err
+	}
+	select {
+	case srv.peerOp <- func(peers map[discover.NodeID]*Peer) {
+		if p := peers[id]; p != nil && !p.rw.is(trustedConn) {
+			p.Disconnect(DiscUselessPeer)
+		}
+	}:
+		<-srv.peerOpDone
+	case <-srv.quit:
+	}
+	return nil
+}
+
+// Unban lifts the ban of the given node.
+func (srv *Server) Unban(id discover.NodeID) error {
+	if srv.rep == nil {
+		return errServerStopped
+	}
+	return srv.rep.unban(id)
+}
+
 // SubscribePeers subscribes the given channel to peer events
 func (srv *Server) SubscribeEvents(ch chan *PeerEvent) event.Subscription {
 	return srv.peerFeed.Subscribe(ch)
@@ -316,6 +367,47 @@ func (srv *Server) Self() *discover.Node {
 	return srv.makeSelf(srv.listener, srv.ntab)
 }
 
+// SetEntry sets an entry of the local node record announced through discovery,
+// e.g. to update a protocol attribute, and signs the record again. It is a noop
+// if the server is not running or discovery is disabled.
+func (srv *Server) SetEntry(entry enr.Entry) error {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	if tab, ok := srv.ntab.(*discover.Table); ok {
+		return tab.SetEntry(entry)
+	}
+	return nil
+}
+
+// dialFilter combines the dial filters of the protocols, accepting candidates
+// accepted by any of the filters. Candidates without a node record, like nodes
+// predating records or not answering record requests, can't be judged and are
+// accepted. It returns nil if no protocol filters its candidates.
+func (srv *Server) dialFilter() func(*discover.Node) bool {
+	var filters []func(*enr.Record) bool
+	for _, p := range srv.Protocols {
+		if p.DialFilter != nil {
+			filters = append(filters, p.DialFilter)
+		}
+	}
+	if len(filters) == 0 {
+		return nil
+	}
+	return func(n *discover.Node) bool {
+		record := n.Record()
+		if record == nil {
+			return true
+		}
+		for _, filter := range filters {
+			if filter(record) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 func (srv *Server) makeSelf(listener net.Listener, ntab discoverTable) *discover.Node {
 	// If the server's not running, return an empty node.
 	// If the node is running but discovery is off, manually assemble the node infos.
@@ -420,6 +512,18 @@ func (srv *Server) Start() (err error) {
 		unhandled chan discover.ReadPacket
 	)
 
+	// node database, shared by discovery and the peer reputation tracker
+	srv.nodedb, err = discover.OpenNodeDB(srv.NodeDatabase, discover.PubkeyID(&srv.PrivateKey.PublicKey))
+	if err != nil {
+		return err
+	}
+	srv.rep = newReputation(srv.nodedb)
+	defer func() {
+		if err != nil {
+			srv.nodedb.Close()
+		}
+	}()
+
 	if !srv.NoDiscovery || srv.DiscoveryV5 {
 		addr, err := net.ResolveUDPAddr("udp", srv.ListenAddr)
 		if err != nil {
@@ -451,11 +555,14 @@ func (srv *Server) Start() (err error) {
 		cfg := discover.Config{
 			PrivateKey:   srv.PrivateKey,
 			AnnounceAddr: realaddr,
-			NodeDBPath:   srv.NodeDatabase,
+			NodeDB:       srv.nodedb,
 			NetRestrict:  srv.NetRestrict,
 			Bootnodes:    srv.BootstrapNodes,
 			Unhandled:    unhandled,
 		}
+		for _, p := range srv.Protocols {
+			cfg.Entries = append(cfg.Entries, p.Attributes...)
+		}
 		ntab, err := discover.ListenUDP(conn, cfg)
 		if err != nil {
 			return err
@@ -482,8 +589,22 @@ func (srv *Server) Start() (err error) {
 		srv.DiscV5 = ntab
 	}
 
+	// DNS node lists
+	if len(srv.DNSDiscovery) > 0 {
+		it, err := dnsdisc.NewClient(dnsdisc.Config{}).NewIterator(srv.DNSDiscovery...)
+		if err != nil {
+			return err
+		}
+		srv.dnsNodes = it
+	}
+
 	dynPeers := srv.maxDialedConns()
 	dialer := newDialState(srv.StaticNodes, srv.BootstrapNodes, srv.ntab, dynPeers, srv.NetRestrict)
+	dialer.filter = srv.dialFilter()
+	dialer.banned = func(id discover.NodeID) bool { return srv.rep.banned(id, time.Now()) }
+	if srv.dnsNodes != nil {
+		dialer.dns = srv.dnsNodes
+	}
 
 	// handshake
 	srv.ourHandshake = &protoHandshake{Version: baseProtocolVersion, Name: srv.Name, ID: discover.PubkeyID(&srv.PrivateKey.PublicKey)}
@@ -636,6 +757,7 @@ running:
 			if err == nil {
 				// The handshakes are done and it passed all checks.
 				p := newPeer(c, srv.Protocols)
+				p.rep = srv.rep
 				// If message events are enabled, pass the peerFeed
 				// to the peer
 				if srv.EnableMsgEvents {
@@ -677,6 +799,9 @@ running:
 	if srv.DiscV5 != nil {
 		srv.DiscV5.Close()
 	}
+	if srv.dnsNodes != nil {
+		srv.dnsNodes.Close()
+	}
 	// Disconnect all peers.
 	for _, p := range peers {
 		p.Disconnect(DiscQuitting)
@@ -689,6 +814,11 @@ running:
 		p.log.Trace("<-delpeer (spindown)", "remainingTasks", len(runningTasks))
 		delete(peers, p.ID())
 	}
+	// The node database is closed last, after discovery has stopped and
+	// peers can't be banned anymore.
+	if srv.nodedb != nil {
+		srv.nodedb.Close()
+	}
 }
 
 func (srv *Server) protoHandshakeChecks(peers map[discover.NodeID]*Peer, inboundCount int, c *conn) error {
@@ -711,6 +841,8 @@ func (srv *Server) encHandshakeChecks(peers map[discover.NodeID]*Peer, inboundCo
 		return DiscAlreadyConnected
 	case c.id == srv.Self().ID:
 		return DiscSelf
+	case !c.is(trustedConn) && srv.rep != nil && srv.rep.banned(c.id, time.Now()):
+		return DiscUselessPeer
 	default:
 		return nil
 	}
@@ -721,7 +853,7 @@ func (srv *Server) maxInboundConns() int {
 }
 
 func (srv *Server) maxDialedConns() int {
-	if srv.NoDiscovery || srv.NoDial {
+	if (srv.NoDiscovery && len(srv.DNSDiscovery) == 0) || srv.NoDial {
 		return 0
 	}
 	r := srv.DialRatio
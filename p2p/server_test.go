@@ -29,6 +29,7 @@ import (
 	"github.com/genchain/go-genchain/crypto/sha3"
 	"github.com/genchain/go-genchain/log"
 	"github.com/genchain/go-genchain/p2p/discover"
+	"github.com/genchain/go-genchain/p2p/enr"
 )
 
 func init() {
@@ -488,6 +489,49 @@ func (c *setupTransport) ReadMsg() (Msg, error) {
 	panic("ReadMsg called on setupTransport")
 }
 
+// This test checks that the dial filters of the protocols are combined, and that
+// candidates without a node record are accepted.
+func TestServerDialFilter(t *testing.T) {
+	srv := &Server{Config: Config{Protocols: []Protocol{{Name: "nofilter"}}}}
+	if srv.dialFilter() != nil {
+		t.Fatal("dial filter set without protocol filters")
+	}
+	srv.Protocols = append(srv.Protocols,
+		Protocol{Name: "a", DialFilter: func(r *enr.Record) bool { return r.Load(new(enr.UDP)) == nil }},
+		Protocol{Name: "b", DialFilter: func(r *enr.Record) bool {
+			var ip enr.IP
+			return r.Load(&ip) == nil && net.IP(ip).Equal(net.IP{10, 0, 0, 1})
+		}},
+	)
+	filter := srv.dialFilter()
+
+	node := func(ip net.IP, entries ...enr.Entry) *discover.Node {
+		var r enr.Record
+		r.Set(enr.IP(ip))
+		r.Set(enr.TCP(30303))
+		for _, entry := range entries {
+			r.Set(entry)
+		}
+		if err := enr.SignV4(&r, newkey()); err != nil {
+			t.Fatalf("can't sign record: %v", err)
+		}
+		n, err := discover.NewNodeFromRecord(&r)
+		if err != nil {
+			t.Fatalf("can't create node: %v", err)
+		}
+		return n
+	}
+	if !filter(discover.NewNode(randomID(), net.IP{127, 0, 0, 1}, 30303, 30303)) {
+		t.Error("candidate without record rejected")
+	}
+	if !filter(node(net.IP{127, 0, 0, 1}, enr.UDP(30303))) || !filter(node(net.IP{10, 0, 0, 1})) {
+		t.Error("candidate accepted by a protocol filter rejected")
+	}
+	if filter(node(net.IP{127, 0, 0, 1})) {
+		t.Error("candidate rejected by all protocol filters accepted")
+	}
+}
+
 func newkey() *ecdsa.PrivateKey {
 	key, err := crypto.GenerateKey()
 	if err != nil {
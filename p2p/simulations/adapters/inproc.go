@@ -75,14 +75,23 @@ func (s *SimAdapter) NewNode(config *NodeConfig) (Node, error) {
 		}
 	}
 
+	p2pConfig := p2p.Config{
+		PrivateKey:      config.PrivateKey,
+		MaxPeers:        math.MaxInt32,
+		NoDiscovery:     true,
+		Dialer:          s,
+		EnableMsgEvents: true,
+	}
+	if config.Discovery {
+		// Discovery needs a real UDP socket, and a bounded number of dialed
+		// peers as the dialer buffers candidates for every slot
+		p2pConfig.NoDiscovery = false
+		p2pConfig.ListenAddr = "127.0.0.1:0"
+		p2pConfig.MaxPeers = 25
+		p2pConfig.BootstrapNodes = config.BootstrapNodes
+	}
 	n, err := node.New(&node.Config{
-		P2P: p2p.Config{
-			PrivateKey:      config.PrivateKey,
-			MaxPeers:        math.MaxInt32,
-			NoDiscovery:     true,
-			Dialer:          s,
-			EnableMsgEvents: true,
-		},
+		P2P:    p2pConfig,
 		NoUSB:  true,
 		Logger: log.New("node.id", id.String()),
 	})
@@ -158,8 +167,12 @@ func (sn *SimNode) Addr() []byte {
 	return []byte(sn.Node().String())
 }
 
-// Node returns a discover.Node representing the SimNode
+// Node returns a discover.Node representing the SimNode, which is the node
+// announced through discovery if enabled and running.
 func (sn *SimNode) Node() *discover.Node {
+	if srv := sn.Server(); sn.config.Discovery && srv != nil {
+		return srv.Self()
+	}
 	return discover.NewNode(sn.ID, net.IP{127, 0, 0, 1}, 60606, 60606)
 }
 
@@ -97,6 +97,12 @@ type NodeConfig struct {
 
 	// function to sanction or prevent suggesting a peer
 	Reachable func(id discover.NodeID) bool
+
+	// Discovery enables the discovery protocol of SimNodes on a loopback UDP
+	// socket, bootstrapping from the given nodes. Peers are then found and
+	// dialed by the nodes themselves.
+	Discovery      bool
+	BootstrapNodes []*discover.Node
 }
 
 // nodeConfigJSON is used to encode and decode NodeConfig as JSON by encoding
@@ -0,0 +1,138 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/genchain/go-genchain/node"
+	"github.com/genchain/go-genchain/p2p"
+	"github.com/genchain/go-genchain/p2p/discover"
+	"github.com/genchain/go-genchain/p2p/enr"
+	"github.com/genchain/go-genchain/p2p/simulations/adapters"
+	"github.com/genchain/go-genchain/rpc"
+)
+
+// chainService is a service announcing the chain it follows in the node record,
+// and only dialing discovered nodes following the same chain.
+type chainService struct {
+	chain string
+}
+
+func newChainService(chain string) adapters.ServiceFunc {
+	return func(*adapters.ServiceContext) (node.Service, error) {
+		return &chainService{chain: chain}, nil
+	}
+}
+
+func (s *chainService) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{{
+		Name:    "chain",
+		Version: 1,
+		Length:  1,
+		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+			for {
+				msg, err := rw.ReadMsg()
+				if err != nil {
+					return err
+				}
+				msg.Discard()
+			}
+		},
+		Attributes: []enr.Entry{enr.WithEntry("chain", s.chain)},
+		DialFilter: func(record *enr.Record) bool {
+			var chain string
+			if err := record.Load(enr.WithEntry("chain", &chain)); err != nil {
+				return false
+			}
+			return chain == s.chain
+		},
+	}}
+}
+
+func (s *chainService) APIs() []rpc.API         { return nil }
+func (s *chainService) Start(*p2p.Server) error { return nil }
+func (s *chainService) Stop() error             { return nil }
+
+// Tests that nodes find each other through discovery, retrieving their records
+// and only dialing the nodes that follow the same chain.
+func TestDiscoveryDialFilter(t *testing.T) {
+	adapter := adapters.NewSimAdapter(adapters.Services{
+		"chain1": newChainService("chain1"),
+		"chain2": newChainService("chain2"),
+	})
+	network := NewNetwork(adapter, &NetworkConfig{})
+	defer network.Shutdown()
+
+	start := func(name, service string, bootnodes []*discover.Node) discover.NodeID {
+		conf := adapters.RandomNodeConfig()
+		conf.Name = name
+		conf.Services = []string{service}
+		conf.Discovery = true
+		conf.BootstrapNodes = bootnodes
+
+		node, err := network.NewNodeWithConfig(conf)
+		if err != nil {
+			t.Fatalf("error creating node %s: %v", name, err)
+		}
+		if err := network.Start(node.ID()); err != nil {
+			t.Fatalf("error starting node %s: %v", name, err)
+		}
+		return node.ID()
+	}
+	server := func(id discover.NodeID) *p2p.Server {
+		node, _ := adapter.GetNode(id)
+		return node.Server()
+	}
+	boot := start("boot", "chain1", nil)
+	bootnodes := []*discover.Node{server(boot).Self()}
+
+	var (
+		chain1 = []discover.NodeID{start("a", "chain1", bootnodes), start("b", "chain1", bootnodes)}
+		other  = start("c", "chain2", bootnodes)
+	)
+	// The nodes of the same chain should find and dial each other
+	connected := func(one, other discover.NodeID) bool {
+		for _, peer := range server(one).Peers() {
+			if peer.ID() == other {
+				return true
+			}
+		}
+		return false
+	}
+	deadline := time.Now().Add(15 * time.Second)
+	for !connected(chain1[0], chain1[1]) {
+		if time.Now().After(deadline) {
+			t.Fatalf("nodes of the same chain didn't connect")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	// The node of the other chain shouldn't be connected to the nodes it found
+	// through discovery. The bootnode is given without a record, so it can't be
+	// filtered and may be connected.
+	for _, id := range chain1 {
+		if connected(id, other) {
+			t.Errorf("node %s connected to the node of another chain", network.GetNode(id))
+		}
+	}
+	for _, peer := range server(other).Peers() {
+		if peer.ID() != boot {
+			t.Errorf("node of another chain connected to %x", peer.ID().Bytes()[:8])
+		}
+	}
+}
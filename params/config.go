@@ -99,6 +99,7 @@ var (
 		big.NewInt(0),
 		big.NewInt(0),
 		nil,
+		nil,
 		new(EthashConfig),
 		nil}
 
@@ -107,9 +108,9 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -139,6 +140,9 @@ type ChainConfig struct {
 	ByzantiumBlock      *big.Int `json:"byzantiumBlock,omitempty"`      // Byzantium switch block (nil = no fork, 0 = already on byzantium)
 	ConstantinopleBlock *big.Int `json:"constantinopleBlock,omitempty"` // Constantinople switch block (nil = no fork, 0 = already activated)
 
+	// Precompiles activates additional, chain specific precompiled contracts
+	Precompiles []*PrecompileConfig `json:"precompiles,omitempty"`
+
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
@@ -152,6 +156,19 @@ func (c *EthashConfig) String() string {
 	return "ethash"
 }
 
+// PrecompileConfig activates a precompiled contract registered with the virtual
+// machine under a given name at an address, from a fork block onwards.
+type PrecompileConfig struct {
+	Name    string         `json:"name"`            // Name of the registered precompiled contract
+	Address common.Address `json:"address"`         // Address to activate the precompiled contract at
+	Block   *big.Int       `json:"block,omitempty"` // Activation block (nil = no fork, 0 = already activated)
+}
+
+// String implements the stringer interface, returning the precompile details.
+func (c *PrecompileConfig) String() string {
+	return fmt.Sprintf("%s@%x:%v", c.Name, c.Address, c.Block)
+}
+
 // CliqueConfig is the consensus engine configs for proof-of-authority based sealing.
 type CliqueConfig struct {
 	Period uint64 `json:"period"` // Number of seconds between blocks to enforce
@@ -178,7 +195,7 @@ func (c *ChainConfig) String() string {
 	default:
 		engine = "unknown"
 	}
-	return fmt.Sprintf("{ChainID: %v RiverBlock: %v ValleyBlock: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Engine: %v}",
+	return fmt.Sprintf("{ChainID: %v RiverBlock: %v ValleyBlock: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Precompiles: %v Engine: %v}",
 		c.ChainId,
 		c.RiverBlock,
 		c.ValleyBlock,
@@ -190,6 +207,7 @@ func (c *ChainConfig) String() string {
 		c.EIP158Block,
 		c.ByzantiumBlock,
 		c.ConstantinopleBlock,
+		c.Precompiles,
 		engine,
 	)
 }
@@ -236,6 +254,29 @@ func (c *ChainConfig) IsConstantinople(num *big.Int) bool {
 	return isForked(c.ConstantinopleBlock, num)
 }
 
+// ActivePrecompiles returns the chain specific precompiled contracts activated
+// at or before block num.
+func (c *ChainConfig) ActivePrecompiles(num *big.Int) []*PrecompileConfig {
+	var active []*PrecompileConfig
+	for _, precompile := range c.Precompiles {
+		if isForked(precompile.Block, num) {
+			active = append(active, precompile)
+		}
+	}
+	return active
+}
+
+// precompile returns the chain specific precompiled contract configured at the
+// given address, or nil if there's none.
+func (c *ChainConfig) precompile(addr common.Address) *PrecompileConfig {
+	for _, precompile := range c.Precompiles {
+		if precompile.Address == addr {
+			return precompile
+		}
+	}
+	return nil
+}
+
 // GasTable returns the gas table corresponding to the current phase (homestead or homestead reprice).
 //
 // The returned GasTable's fields shouldn't, under any circumstances, be changed.
@@ -308,6 +349,38 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.ConstantinopleBlock, newcfg.ConstantinopleBlock, head) {
 		return newCompatError("Constantinople fork block", c.ConstantinopleBlock, newcfg.ConstantinopleBlock)
 	}
+	if err := c.checkPrecompilesCompatible(newcfg, head); err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkPrecompilesCompatible checks whether any chain specific precompiled
+// contract was added, removed, rescheduled or replaced after already being active.
+func (c *ChainConfig) checkPrecompilesCompatible(newcfg *ChainConfig, head *big.Int) *ConfigCompatError {
+	var addrs []common.Address
+	for _, precompile := range append(append([]*PrecompileConfig{}, c.Precompiles...), newcfg.Precompiles...) {
+		addrs = append(addrs, precompile.Address)
+	}
+	for _, addr := range addrs {
+		var (
+			stored, update = c.precompile(addr), newcfg.precompile(addr)
+			storedblock    *big.Int
+			newblock       *big.Int
+		)
+		if stored != nil {
+			storedblock = stored.Block
+		}
+		if update != nil {
+			newblock = update.Block
+		}
+		if isForkIncompatible(storedblock, newblock, head) {
+			return newCompatError(fmt.Sprintf("precompile %x activation block", addr), storedblock, newblock)
+		}
+		if isForked(storedblock, head) && stored.Name != update.Name {
+			return newCompatError(fmt.Sprintf("precompile %x contract", addr), storedblock, newblock)
+		}
+	}
 	return nil
 }
 
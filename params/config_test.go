@@ -20,6 +20,8 @@ import (
 	"math/big"
 	"reflect"
 	"testing"
+
+	"github.com/genchain/go-genchain/common"
 )
 
 func TestCheckCompatible(t *testing.T) {
@@ -70,6 +72,34 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     9,
 			},
 		},
+		{
+			stored:  &ChainConfig{Precompiles: []*PrecompileConfig{{Name: "sha3-512", Address: common.Address{0x10}, Block: big.NewInt(10)}}},
+			new:     &ChainConfig{Precompiles: []*PrecompileConfig{{Name: "sha3-512", Address: common.Address{0x10}, Block: big.NewInt(20)}}},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{},
+			new:    &ChainConfig{Precompiles: []*PrecompileConfig{{Name: "sha3-512", Address: common.Address{0x10}, Block: big.NewInt(10)}}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "precompile 1000000000000000000000000000000000000000 activation block",
+				StoredConfig: nil,
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{Precompiles: []*PrecompileConfig{{Name: "sha3-512", Address: common.Address{0x10}, Block: big.NewInt(10)}}},
+			new:    &ChainConfig{Precompiles: []*PrecompileConfig{{Name: "blake2b", Address: common.Address{0x10}, Block: big.NewInt(10)}}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "precompile 1000000000000000000000000000000000000000 contract",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -76,6 +76,12 @@ const (
 	Ripemd160PerWordGas     uint64 = 120    // Per-word price for a RIPEMD160 operation
 	IdentityBaseGas         uint64 = 15     // Base price for a data copy operation
 	IdentityPerWordGas      uint64 = 3      // Per-work price for a data copy operation
+	Sha3_512BaseGas         uint64 = 60     // Base price for a SHA3-512 operation
+	Sha3_512PerWordGas      uint64 = 12     // Per-word price for a SHA3-512 operation
+	Blake2bBaseGas          uint64 = 30     // Base price for a BLAKE2b operation
+	Blake2bPerWordGas       uint64 = 6      // Per-word price for a BLAKE2b operation
+	FuzzyHashBaseGas        uint64 = 3000   // Base price for a fuzzy hash verification
+	FuzzyHashOpsPerGas      uint64 = 16     // Number of matrix multiplication steps of a fuzzy hash verification paid by a unit of gas
 	ModExpQuadCoeffDiv      uint64 = 20     // Divisor for the quadratic particle of the big int modular exponentiation
 	Bn256AddGas             uint64 = 500    // Gas needed for an elliptic curve addition
 	Bn256ScalarMulGas       uint64 = 40000  // Gas needed for an elliptic curve scalar multiplication
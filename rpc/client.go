@@ -220,6 +220,35 @@ func DialStdIO(ctx context.Context) (*Client, error) {
 	})
 }
 
+// DialListener creates a client that talks to the peers connecting to the given
+// listener, one at a time. It waits for the first peer to connect. When the
+// connection is lost, the next connection is accepted on the following call.
+func DialListener(ctx context.Context, listener net.Listener) (*Client, error) {
+	conns := make(chan net.Conn)
+	go func() {
+		defer close(conns)
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				log.Debug("RPC listener closed", "addr", listener.Addr(), "err", err)
+				return
+			}
+			conns <- conn
+		}
+	}()
+	return newClient(ctx, func(ctx context.Context) (net.Conn, error) {
+		select {
+		case conn, ok := <-conns:
+			if !ok {
+				return nil, errors.New("listener closed")
+			}
+			return conn, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+}
+
 func newClient(initctx context.Context, connectFunc func(context.Context) (net.Conn, error)) (*Client, error) {
 	conn, err := connectFunc(initctx)
 	if err != nil {
@@ -318,6 +318,12 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 	if req.callb.errPos >= 0 { // test if method returned an error
 		if !reply[req.callb.errPos].IsNil() {
 			e := reply[req.callb.errPos].Interface().(error)
+			switch e := e.(type) {
+			case DataError:
+				return codec.CreateErrorResponseWithInfo(&req.id, e, e.ErrorData()), nil
+			case Error:
+				return codec.CreateErrorResponse(&req.id, e), nil
+			}
 			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
 			return res, nil
 		}
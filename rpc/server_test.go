@@ -19,6 +19,8 @@ package rpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
 	"reflect"
 	"testing"
@@ -75,6 +77,22 @@ func (s *Service) Subscription(ctx context.Context) (*Subscription, error) {
 	return nil, nil
 }
 
+type codeError struct{}
+
+func (codeError) Error() string  { return "code error" }
+func (codeError) ErrorCode() int { return 444 }
+
+type dataError struct{ codeError }
+
+func (dataError) Error() string          { return "data error" }
+func (dataError) ErrorData() interface{} { return map[string]uint64{"limit": 10} }
+
+type ErrorService struct{}
+
+func (s *ErrorService) Plain() error { return errors.New("plain error") }
+func (s *ErrorService) Code() error  { return codeError{} }
+func (s *ErrorService) Data() error  { return dataError{} }
+
 func TestServerRegisterName(t *testing.T) {
 	server := NewServer()
 	service := new(Service)
@@ -160,3 +178,51 @@ func TestServerMethodExecution(t *testing.T) {
 func TestServerMethodWithCtx(t *testing.T) {
 	testServerMethodExecution(t, "echoWithCtx")
 }
+
+// Tests that the errors returned by methods are encoded with their codes, and
+// with their data if they carry any.
+func TestServerMethodErrors(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(ErrorService)); err != nil {
+		t.Fatal(err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+
+	out := json.NewEncoder(clientConn)
+	in := json.NewDecoder(clientConn)
+
+	tests := []struct {
+		method  string
+		code    int
+		message string
+		data    string
+	}{
+		{"test_plain", -32000, "plain error", ""},
+		{"test_code", 444, "code error", ""},
+		{"test_data", 444, "data error", `{"limit":10}`},
+	}
+	for i, tt := range tests {
+		request := map[string]interface{}{"id": i, "method": tt.method, "version": "2.0"}
+		if err := out.Encode(request); err != nil {
+			t.Fatal(err)
+		}
+		var response struct {
+			Error map[string]json.RawMessage `json:"error"`
+		}
+		if err := in.Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if code := string(response.Error["code"]); code != fmt.Sprint(tt.code) {
+			t.Errorf("%s: code mismatch: have %s, want %d", tt.method, code, tt.code)
+		}
+		if message := string(response.Error["message"]); message != `"`+tt.message+`"` {
+			t.Errorf("%s: message mismatch: have %s, want %q", tt.method, message, tt.message)
+		}
+		if data := string(response.Error["data"]); data != tt.data {
+			t.Errorf("%s: data mismatch: have %q, want %q", tt.method, data, tt.data)
+		}
+	}
+}
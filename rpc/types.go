@@ -93,6 +93,13 @@ type Error interface {
 	ErrorCode() int // returns the code
 }
 
+// DataError is an Error which additionally carries structured data that is
+// forwarded to the client in the data field of the JSON-RPC error object.
+type DataError interface {
+	Error
+	ErrorData() interface{} // returns the error data
+}
+
 // ServerCodec implements reading, parsing and writing RPC messages for the server side of
 // a RPC session. Implementations must be go-routine safe since the codec can be called in
 // multiple go-routines concurrently.
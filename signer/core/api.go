@@ -27,6 +27,7 @@ import (
 
 	"github.com/genchain/go-genchain/accounts"
 	"github.com/genchain/go-genchain/accounts/keystore"
+	"github.com/genchain/go-genchain/accounts/pkcs11wallet"
 	"github.com/genchain/go-genchain/accounts/usbwallet"
 	"github.com/genchain/go-genchain/common"
 	"github.com/genchain/go-genchain/common/hexutil"
@@ -34,6 +35,7 @@ import (
 	"github.com/genchain/go-genchain/internal/ethapi"
 	"github.com/genchain/go-genchain/log"
 	"github.com/genchain/go-genchain/rlp"
+	"github.com/genchain/go-genchain/signer/typeddata"
 )
 
 // ExternalAPI defines the external API through which signing requests are made.
@@ -46,6 +48,8 @@ type ExternalAPI interface {
 	SignTransaction(ctx context.Context, args SendTxArgs, methodSelector *string) (*ethapi.SignTransactionResult, error)
 	// Sign - request to sign the given data (plus prefix)
 	Sign(ctx context.Context, addr common.MixedcaseAddress, data hexutil.Bytes) (hexutil.Bytes, error)
+	// SignTypedData - request to sign the given EIP-712 typed data
+	SignTypedData(ctx context.Context, addr common.MixedcaseAddress, typedData typeddata.TypedData) (hexutil.Bytes, error)
 	// EcRecover - request to perform ecrecover
 	EcRecover(ctx context.Context, data, sig hexutil.Bytes) (common.Address, error)
 	// Export - request to export an account
@@ -81,6 +85,15 @@ type SignerUI interface {
 	OnSignerStartup(info StartupInfo)
 }
 
+// FailedTxNotifier is implemented by the UIs that need to know when a transaction
+// they approved couldn't be signed, for example to release the spending limits
+// they reserved for it.
+type FailedTxNotifier interface {
+	// OnFailedTx notifies the UI about an approved transaction that failed to be
+	// signed, as it was returned by the approval.
+	OnFailedTx(tx SendTxArgs, err error)
+}
+
 // SignerAPI defines the actual implementation of ExternalAPI
 type SignerAPI struct {
 	chainID   *big.Int
@@ -154,12 +167,17 @@ type (
 		OldPassword string `json:"old_password"`
 		NewPassword string `json:"new_password"`
 	}
+	// SignDataRequest info about a request to sign data. Typed data comes
+	// with its members formatted for display in Messages.
 	SignDataRequest struct {
-		Address common.MixedcaseAddress `json:"address"`
-		Rawdata hexutil.Bytes           `json:"raw_data"`
-		Message string                  `json:"message"`
-		Hash    hexutil.Bytes           `json:"hash"`
-		Meta    Metadata                `json:"meta"`
+		ContentType string                     `json:"content_type"`
+		Address     common.MixedcaseAddress    `json:"address"`
+		Rawdata     hexutil.Bytes              `json:"raw_data"`
+		Message     string                     `json:"message"`
+		Messages    []*typeddata.NameValueType `json:"messages,omitempty"`
+		TypedData   *typeddata.TypedData       `json:"typed_data,omitempty"`
+		Hash        hexutil.Bytes              `json:"hash"`
+		Meta        Metadata                   `json:"meta"`
 	}
 	SignDataResponse struct {
 		Approved bool `json:"approved"`
@@ -187,6 +205,12 @@ type (
 	}
 )
 
+// Content types of data signing requests
+const (
+	TextPlain = "text/plain" // personal_sign style messages
+	DataTyped = "data/typed" // EIP-712 typed data
+)
+
 var ErrRequestDenied = errors.New("Request denied")
 
 type errorWrapper struct {
@@ -203,7 +227,9 @@ func (ew errorWrapper) String() string {
 // key that is generated when a new Account is created.
 // noUSB disables USB support that is required to support hardware devices such as
 // ledger and trezor.
-func NewSignerAPI(chainID int64, ksLocation string, noUSB bool, ui SignerUI, abidb *AbiDb, lightKDF bool) *SignerAPI {
+// pkcs11Module is the path of a PKCS#11 module to sign with the keys of its tokens,
+// like hardware security modules. An empty path disables PKCS#11 support.
+func NewSignerAPI(chainID int64, ksLocation string, noUSB bool, pkcs11Module string, ui SignerUI, abidb *AbiDb, lightKDF bool) *SignerAPI {
 	var (
 		backends []accounts.Backend
 		n, p     = keystore.StandardScryptN, keystore.StandardScryptP
@@ -231,6 +257,15 @@ func NewSignerAPI(chainID int64, ksLocation string, noUSB bool, ui SignerUI, abi
 			log.Debug("Trezor support enabled")
 		}
 	}
+	if pkcs11Module != "" {
+		// Start a hub for the tokens of the PKCS#11 module
+		if pkcs11hub, err := pkcs11wallet.NewHub(pkcs11Module); err != nil {
+			log.Warn(fmt.Sprintf("Failed to start PKCS#11 hub, disabling: %v", err))
+		} else {
+			backends = append(backends, pkcs11hub)
+			log.Debug("PKCS#11 support enabled", "module", pkcs11Module)
+		}
+	}
 	return &SignerAPI{big.NewInt(chainID), accounts.NewManager(backends...), ui, NewValidator(abidb)}
 }
 
@@ -346,6 +381,14 @@ func (api *SignerAPI) SignTransaction(ctx context.Context, args SendTxArgs, meth
 	}
 	// Log changes made by the UI to the signing-request
 	logDiff(&req, &result)
+
+	// Let the UI know if the approved transaction can't be signed after all
+	failed := func(err error) error {
+		if notifier, ok := api.UI.(FailedTxNotifier); ok {
+			notifier.OnFailedTx(result.Transaction, err)
+		}
+		return err
+	}
 	var (
 		acc    accounts.Account
 		wallet accounts.Wallet
@@ -353,7 +396,7 @@ func (api *SignerAPI) SignTransaction(ctx context.Context, args SendTxArgs, meth
 	acc = accounts.Account{Address: result.Transaction.From.Address()}
 	wallet, err = api.am.Find(acc)
 	if err != nil {
-		return nil, err
+		return nil, failed(err)
 	}
 	// Convert fields into a real transaction
 	var unsignedTx = result.Transaction.toTransaction()
@@ -362,7 +405,7 @@ func (api *SignerAPI) SignTransaction(ctx context.Context, args SendTxArgs, meth
 	signedTx, err := wallet.SignTxWithPassphrase(acc, result.Password, unsignedTx, api.chainID)
 	if err != nil {
 		api.UI.ShowError(err.Error())
-		return nil, err
+		return nil, failed(err)
 	}
 
 	rlpdata, err := rlp.EncodeToBytes(signedTx)
@@ -388,9 +431,42 @@ func (api *SignerAPI) Sign(ctx context.Context, addr common.MixedcaseAddress, da
 	sighash, msg := SignHash(data)
 	// We make the request prior to looking up if we actually have the account, to prevent
 	// account-enumeration via the API
-	req := &SignDataRequest{Address: addr, Rawdata: data, Message: msg, Hash: sighash, Meta: MetadataFromContext(ctx)}
-	res, err := api.UI.ApproveSignData(req)
+	req := &SignDataRequest{ContentType: TextPlain, Address: addr, Rawdata: data, Message: msg, Hash: sighash, Meta: MetadataFromContext(ctx)}
+	return api.sign(addr, req)
+}
 
+// SignTypedData calculates an Genchain ECDSA signature over EIP-712 typed data:
+// keccak256("\x19\x01" + domainSeparator + hashStruct(message))
+//
+// The user approves the signature with the domain and the message laid out as
+// their members. Typed data bound to another chain is rejected.
+func (api *SignerAPI) SignTypedData(ctx context.Context, addr common.MixedcaseAddress, typedData typeddata.TypedData) (hexutil.Bytes, error) {
+	if id := typedData.Domain.ChainId; id != nil && (*big.Int)(id).Cmp(api.chainID) != 0 {
+		return nil, fmt.Errorf("typed data chain id %v does not match signer chain id %v", (*big.Int)(id), api.chainID)
+	}
+	sighash, rawData, err := typeddata.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := typedData.Format()
+	if err != nil {
+		return nil, err
+	}
+	req := &SignDataRequest{
+		ContentType: DataTyped,
+		Address:     addr,
+		Rawdata:     []byte(rawData),
+		Messages:    messages,
+		TypedData:   &typedData,
+		Hash:        sighash,
+		Meta:        MetadataFromContext(ctx),
+	}
+	return api.sign(addr, req)
+}
+
+// sign asks the UI to approve a data signing request and signs its hash.
+func (api *SignerAPI) sign(addr common.MixedcaseAddress, req *SignDataRequest) (hexutil.Bytes, error) {
+	res, err := api.UI.ApproveSignData(req)
 	if err != nil {
 		return nil, err
 	}
@@ -404,7 +480,7 @@ func (api *SignerAPI) Sign(ctx context.Context, addr common.MixedcaseAddress, da
 		return nil, err
 	}
 	// Assemble sign the data with the wallet
-	signature, err := wallet.SignHashWithPassphrase(account, res.Password, sighash)
+	signature, err := wallet.SignHashWithPassphrase(account, res.Password, req.Hash)
 	if err != nil {
 		api.UI.ShowError(err.Error())
 		return nil, err
@@ -19,6 +19,7 @@ package core
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/big"
@@ -32,13 +33,16 @@ import (
 	"github.com/genchain/go-genchain/common"
 	"github.com/genchain/go-genchain/common/hexutil"
 	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/crypto"
 	"github.com/genchain/go-genchain/internal/ethapi"
 	"github.com/genchain/go-genchain/rlp"
+	"github.com/genchain/go-genchain/signer/typeddata"
 )
 
 //Used for testing
 type HeadlessUI struct {
 	controller chan string
+	failedTxs  int // Number of approved transactions that failed to be signed
 }
 
 func (ui *HeadlessUI) OnSignerStartup(info StartupInfo) {
@@ -48,6 +52,10 @@ func (ui *HeadlessUI) OnApprovedTx(tx ethapi.SignTransactionResult) {
 	fmt.Printf("OnApproved called")
 }
 
+func (ui *HeadlessUI) OnFailedTx(tx SendTxArgs, err error) {
+	ui.failedTxs++
+}
+
 func (ui *HeadlessUI) ApproveTx(request *SignTxRequest) (SignTxResponse, error) {
 
 	switch <-ui.controller {
@@ -130,11 +138,12 @@ func setup(t *testing.T) (*SignerAPI, chan string) {
 		utils.Fatalf(err.Error())
 	}
 	var (
-		ui  = &HeadlessUI{controller}
+		ui  = &HeadlessUI{controller: controller}
 		api = NewSignerAPI(
 			1,
 			tmpDirName(t),
 			true,
+			"",
 			ui,
 			db,
 			true)
@@ -233,6 +242,9 @@ func TestSignData(t *testing.T) {
 	if err != keystore.ErrDecrypt {
 		t.Errorf("Expected ErrLocked! %v", err)
 	}
+	if failed := api.UI.(*HeadlessUI).failedTxs; failed != 1 {
+		t.Errorf("Expected the UI to be notified of the signing failure, got %d notifications", failed)
+	}
 
 	control <- "No way"
 	h, err = api.Sign(context.Background(), a, []byte("EHLO world"))
@@ -254,6 +266,59 @@ func TestSignData(t *testing.T) {
 		t.Errorf("Expected 65 byte signature (got %d bytes)", len(h))
 	}
 }
+
+const testTypedData = `{
+	"types": {
+		"EIP712Domain": [{"name": "name", "type": "string"}, {"name": "chainId", "type": "uint256"}],
+		"Greeting": [{"name": "text", "type": "string"}, {"name": "count", "type": "uint8"}]
+	},
+	"primaryType": "Greeting",
+	"domain": {"name": "Test", "chainId": %d},
+	"message": {"text": "EHLO world", "count": 3}
+}`
+
+func TestSignTypedData(t *testing.T) {
+	api, control := setup(t)
+	createAccount(control, api, t)
+	control <- "A"
+	list, err := api.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := common.NewMixedcaseAddress(list[0].Address)
+
+	var typedData typeddata.TypedData
+	if err := json.Unmarshal([]byte(fmt.Sprintf(testTypedData, 1)), &typedData); err != nil {
+		t.Fatal(err)
+	}
+	control <- "No way"
+	if _, err := api.SignTypedData(context.Background(), a, typedData); err != ErrRequestDenied {
+		t.Errorf("Expected ErrRequestDenied! %v", err)
+	}
+	control <- "Y"
+	control <- "apassword"
+	sig, err := api.SignTypedData(context.Background(), a, typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sighash, _, _ := typeddata.TypedDataAndHash(typedData)
+	sig[64] -= 27
+	pub, err := crypto.SigToPub(sighash, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr := crypto.PubkeyToAddress(*pub); addr != a.Address() {
+		t.Errorf("signature recovers to %x, want %x", addr, a.Address())
+	}
+	// Typed data of other chains is rejected before asking the user
+	if err := json.Unmarshal([]byte(fmt.Sprintf(testTypedData, 2)), &typedData); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.SignTypedData(context.Background(), a, typedData); err == nil {
+		t.Error("Expected chain id mismatch error")
+	}
+}
+
 func mkTestTx(from common.MixedcaseAddress) SendTxArgs {
 	to := common.NewMixedcaseAddress(common.HexToAddress("0x1337"))
 	gas := hexutil.Uint64(21000)
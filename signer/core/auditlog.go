@@ -26,6 +26,7 @@ import (
 	"github.com/genchain/go-genchain/common/hexutil"
 	"github.com/genchain/go-genchain/internal/ethapi"
 	"github.com/genchain/go-genchain/log"
+	"github.com/genchain/go-genchain/signer/typeddata"
 )
 
 type AuditLogger struct {
@@ -72,6 +73,14 @@ func (l *AuditLogger) Sign(ctx context.Context, addr common.MixedcaseAddress, da
 	return b, e
 }
 
+func (l *AuditLogger) SignTypedData(ctx context.Context, addr common.MixedcaseAddress, typedData typeddata.TypedData) (hexutil.Bytes, error) {
+	l.log.Info("SignTypedData", "type", "request", "metadata", MetadataFromContext(ctx).String(),
+		"addr", addr.String(), "primaryType", typedData.PrimaryType, "domain", typedData.Domain.Name)
+	b, e := l.api.SignTypedData(ctx, addr, typedData)
+	l.log.Info("SignTypedData", "type", "response", "data", common.Bytes2Hex(b), "error", e)
+	return b, e
+}
+
 func (l *AuditLogger) EcRecover(ctx context.Context, data, sig hexutil.Bytes) (common.Address, error) {
 	l.log.Info("EcRecover", "type", "request", "metadata", MetadataFromContext(ctx).String(),
 		"data", common.Bytes2Hex(data))
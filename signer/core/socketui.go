@@ -0,0 +1,63 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of go-genchain.
+//
+// go-genchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-genchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-genchain. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/genchain/go-genchain/log"
+	"github.com/genchain/go-genchain/rpc"
+)
+
+// SocketUI is a UI served by an external application connected to a Unix domain
+// socket. It speaks the same JSON-RPC protocol as the stdio UI, with the signer
+// sending the requests. One application is served at a time; when it goes away
+// the signer waits for the next one to connect.
+type SocketUI struct {
+	*StdIOUI
+	listener net.Listener
+}
+
+// NewSocketUI opens the UI socket at the given path, accessible to the current
+// user only, and waits for the first UI to connect.
+func NewSocketUI(ctx context.Context, path string) (*SocketUI, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	listener, err := listenSocket(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Waiting for UI to connect", "socket", path)
+	client, err := rpc.DialListener(ctx, listener)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	log.Info("UI connected", "socket", path)
+	return &SocketUI{StdIOUI: &StdIOUI{client: client, retry: true}, listener: listener}, nil
+}
+
+// Close disconnects the UI and removes the socket.
+func (ui *SocketUI) Close() error {
+	err := ui.listener.Close()
+	ui.client.Close()
+	return err
+}
@@ -0,0 +1,141 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of go-genchain.
+//
+// go-genchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-genchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-genchain. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSocketUI(t *testing.T) {
+	path := filepath.Join(tmpDirName(t), "ui.sock")
+
+	// Serve the UI side of the protocol, one connection at a time. Every UI reads
+	// the given number of requests, answering all but the last if it drops the
+	// connection early, and reports the methods called.
+	methods := make(chan []string)
+	serve := func(requests int, drop bool) {
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			t.Error(err)
+			methods <- nil
+			return
+		}
+		var (
+			dec    = json.NewDecoder(bufio.NewReader(conn))
+			called []string
+		)
+		for i := 0; i < requests; i++ {
+			var req webhookMessage
+			if err := dec.Decode(&req); err != nil {
+				t.Error(err)
+				break
+			}
+			called = append(called, req.Method)
+			if drop && i == requests-1 {
+				break
+			}
+			result := json.RawMessage("null")
+			if req.Method == "ApproveExport" {
+				result, _ = json.Marshal(&ExportResponse{Approved: true})
+			}
+			json.NewEncoder(conn).Encode(&webhookMessage{Version: "2.0", ID: req.ID, Result: result})
+		}
+		conn.Close()
+		methods <- called
+	}
+	go func() {
+		for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+			if _, err := os.Stat(path); err == nil {
+				break
+			}
+		}
+		serve(1, false)
+		serve(2, true)
+		serve(1, false)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ui, err := NewSocketUI(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ui.Close()
+
+	check := func(want ...string) {
+		if have := <-methods; strings.Join(have, ",") != strings.Join(want, ",") {
+			t.Errorf("UI methods mismatch: have %v, want %v", have, want)
+		}
+	}
+	if res, err := ui.ApproveExport(&ExportRequest{}); err != nil || !res.Approved {
+		t.Fatalf("expected approval, got %+v, %v", res, err)
+	}
+	check("ApproveExport")
+
+	// The first UI went away, notifications are delivered to the next one
+	ui.ShowInfo("reconnected")
+
+	// Approvals lost with the connection fail instead of being asked again
+	if res, err := ui.ApproveExport(&ExportRequest{}); err == nil || res.Approved {
+		t.Fatalf("expected failure, got %+v, %v", res, err)
+	}
+	check("ShowInfo", "ApproveExport")
+
+	ui.ShowInfo("reconnected")
+	check("ShowInfo")
+}
+
+func TestSocketUIListen(t *testing.T) {
+	dir := tmpDirName(t)
+
+	// Other files are never replaced by the socket
+	path := filepath.Join(dir, "keystore")
+	if err := ioutil.WriteFile(path, []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listenSocket(path); err == nil {
+		t.Fatal("listened on a regular file")
+	}
+	if blob, err := ioutil.ReadFile(path); err != nil || string(blob) != "key" {
+		t.Fatalf("regular file modified: %q, %v", blob, err)
+	}
+	// Sockets left behind are replaced, accessible to the current user only
+	path = filepath.Join(dir, "ui.sock")
+	for i := 0; i < 2; i++ {
+		listener, err := listenSocket(path)
+		if err != nil {
+			t.Fatalf("listen %d: %v", i, err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm&0077 != 0 {
+			t.Errorf("listen %d: socket accessible to others: %v", i, perm)
+		}
+		// Leave the socket behind, like a crashed signer
+		listener.(*socketListener).UnixListener.Close()
+	}
+}
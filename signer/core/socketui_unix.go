@@ -0,0 +1,77 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of go-genchain.
+//
+// go-genchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-genchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-genchain. If not, see <http://www.gnu.org/licenses/>.
+
+// +build darwin dragonfly freebsd linux nacl netbsd openbsd solaris
+
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// socketListener is a Unix domain socket listener whose socket was moved into
+// place after listening, removing it from there when closed.
+type socketListener struct {
+	*net.UnixListener
+	path string
+}
+
+// Close stops listening and removes the socket.
+func (l *socketListener) Close() error {
+	err := l.UnixListener.Close()
+	os.Remove(l.path)
+	return err
+}
+
+// listenSocket listens on the Unix domain socket at the given path, replacing
+// the socket left behind by a previous run. The socket is created accessible to
+// the current user only.
+func listenSocket(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket == 0 {
+		return nil, fmt.Errorf("%s exists and is not a socket", path)
+	}
+	// Whoever connects approves requests, keep everyone else out. The socket is
+	// created in a private directory and only moved into place once its mode is
+	// restricted, leaving no window for others to connect. The process umask is
+	// left alone, as other goroutines may be creating files meanwhile.
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".ui")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "sock")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	listener.SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmp, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	// Renaming atomically replaces any socket left behind
+	if err := os.Rename(tmp, path); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return &socketListener{UnixListener: listener, path: path}, nil
+}
@@ -26,8 +26,14 @@ import (
 	"github.com/genchain/go-genchain/rpc"
 )
 
+// maxUICallAttempts is the number of times a notification is sent to UIs that
+// reconnect. It may fail both on the connection of a UI that went away and on
+// the closing of that connection before reaching the next UI.
+const maxUICallAttempts = 3
+
 type StdIOUI struct {
-	client rpc.Client
+	client *rpc.Client
+	retry  bool // re-send calls failed by a lost connection, for UIs that reconnect
 	mu     sync.Mutex
 }
 
@@ -37,10 +43,12 @@ func NewStdIOUI() *StdIOUI {
 	if err != nil {
 		log.Crit("Could not create stdio client", "err", err)
 	}
-	return &StdIOUI{client: *client}
+	return &StdIOUI{client: client}
 }
 
-// dispatch sends a request over the stdio
+// dispatch sends a request over the stdio. Requests are never re-sent, as the
+// UI may have prompted the user, and acted on the answer, before the connection
+// was lost.
 func (ui *StdIOUI) dispatch(serviceMethod string, args interface{}, reply interface{}) error {
 	err := ui.client.Call(&reply, serviceMethod, args)
 	if err != nil {
@@ -49,6 +57,34 @@ func (ui *StdIOUI) dispatch(serviceMethod string, args interface{}, reply interf
 	return err
 }
 
+// notify sends a notification or a message to show over the stdio. Unlike the
+// requests, these are re-sent to the next UI if the connection is lost, for UIs
+// that reconnect.
+func (ui *StdIOUI) notify(serviceMethod string, args interface{}) error {
+	ctx := context.Background()
+
+	err := ui.client.CallContext(ctx, nil, serviceMethod, args)
+	for attempt := 1; ui.retry && attempt < maxUICallAttempts && isUIConnError(ctx, err); attempt++ {
+		// The UI went away without answering, tell the next one.
+		log.Debug("Retrying UI notification", "method", serviceMethod, "err", err)
+		err = ui.client.CallContext(ctx, nil, serviceMethod, args)
+	}
+	if err != nil {
+		log.Info("Error", "exc", err.Error())
+	}
+	return err
+}
+
+// isUIConnError reports whether a call failed because the connection to the UI
+// was lost, rather than being answered with an error.
+func isUIConnError(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	_, isRPCErr := err.(rpc.Error)
+	return !isRPCErr
+}
+
 func (ui *StdIOUI) ApproveTx(request *SignTxRequest) (SignTxResponse, error) {
 	var result SignTxResponse
 	err := ui.dispatch("ApproveTx", request, &result)
@@ -86,27 +122,27 @@ func (ui *StdIOUI) ApproveNewAccount(request *NewAccountRequest) (NewAccountResp
 }
 
 func (ui *StdIOUI) ShowError(message string) {
-	err := ui.dispatch("ShowError", &Message{message}, nil)
+	err := ui.notify("ShowError", &Message{message})
 	if err != nil {
 		log.Info("Error calling 'ShowError'", "exc", err.Error(), "msg", message)
 	}
 }
 
 func (ui *StdIOUI) ShowInfo(message string) {
-	err := ui.dispatch("ShowInfo", Message{message}, nil)
+	err := ui.notify("ShowInfo", Message{message})
 	if err != nil {
 		log.Info("Error calling 'ShowInfo'", "exc", err.Error(), "msg", message)
 	}
 }
 func (ui *StdIOUI) OnApprovedTx(tx ethapi.SignTransactionResult) {
-	err := ui.dispatch("OnApprovedTx", tx, nil)
+	err := ui.notify("OnApprovedTx", tx)
 	if err != nil {
 		log.Info("Error calling 'OnApprovedTx'", "exc", err.Error(), "tx", tx)
 	}
 }
 
 func (ui *StdIOUI) OnSignerStartup(info StartupInfo) {
-	err := ui.dispatch("OnSignerStartup", info, nil)
+	err := ui.notify("OnSignerStartup", info)
 	if err != nil {
 		log.Info("Error calling 'OnSignerStartup'", "exc", err.Error(), "info", info)
 	}
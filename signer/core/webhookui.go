@@ -0,0 +1,245 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of go-genchain.
+//
+// go-genchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-genchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-genchain. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/genchain/go-genchain/internal/ethapi"
+	"github.com/genchain/go-genchain/log"
+)
+
+// WebhookSignatureHeader carries the hex encoded HMAC-SHA256 of the body of the
+// webhook requests and responses, keyed with the shared secret.
+const WebhookSignatureHeader = "X-Clef-Signature"
+
+const (
+	minWebhookSecretLength = 16
+	maxWebhookResponseSize = 1024 * 1024
+)
+
+var (
+	errWebhookNotLocal     = errors.New("webhook endpoint is not on the local host")
+	errWebhookSecret       = fmt.Errorf("webhook secret must be at least %d bytes", minWebhookSecretLength)
+	errWebhookSignature    = errors.New("invalid webhook response signature")
+	errWebhookIDMismatch   = errors.New("webhook response does not match the request")
+	errWebhookEmptyMessage = errors.New("empty webhook response")
+)
+
+// WebhookUI is a UI served by an approval service on the local host. Every
+// request is POSTed to the service as a JSON-RPC call, the same as sent to the
+// stdio UI, and the service answers with its decision as the JSON-RPC result.
+//
+// Both the requests and the decisions are authenticated with an HMAC of their
+// body in the X-Clef-Signature header, keyed with a secret shared with the
+// service. The signed body of a decision includes the random id of the request,
+// so decisions can't be replayed.
+type WebhookUI struct {
+	endpoint string
+	secret   []byte
+	timeout  time.Duration // time to wait for a decision, or for a notification to be received
+	client   *http.Client
+}
+
+type webhookMessage struct {
+	Version string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Method  string          `json:"method,omitempty"`
+	Params  []interface{}   `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// NewWebhookUI creates a UI posting the requests to the given endpoint, which
+// must be on the local host, and waiting up to timeout for the decisions.
+func NewWebhookUI(endpoint string, secret []byte, timeout time.Duration) (*WebhookUI, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported webhook scheme %q", u.Scheme)
+	}
+	if host := u.Hostname(); host != "localhost" {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			return nil, errWebhookNotLocal
+		}
+	}
+	if len(secret) < minWebhookSecretLength {
+		return nil, errWebhookSecret
+	}
+	return &WebhookUI{
+		endpoint: endpoint,
+		secret:   secret,
+		timeout:  timeout,
+		client: &http.Client{
+			Transport: &http.Transport{Proxy: nil},
+			// Redirects could take the signed requests off the local host
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}, nil
+}
+
+// call posts a request to the approval service and decodes its decision.
+func (ui *WebhookUI) call(timeout time.Duration, method string, args interface{}, reply interface{}) error {
+	var idb [8]byte
+	if _, err := io.ReadFull(rand.Reader, idb[:]); err != nil {
+		return err
+	}
+	// Keep the id within the exactly representable integers of JavaScript
+	id := binary.BigEndian.Uint64(idb[:]) >> 11
+
+	body, err := json.Marshal(&webhookMessage{Version: "2.0", ID: id, Method: method, Params: []interface{}{args}})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequest("POST", ui.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, hex.EncodeToString(ui.mac(body)))
+
+	resp, err := ui.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	blob, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxWebhookResponseSize))
+	if err != nil {
+		return err
+	}
+	sig, err := hex.DecodeString(resp.Header.Get(WebhookSignatureHeader))
+	if err != nil || !hmac.Equal(sig, ui.mac(blob)) {
+		return errWebhookSignature
+	}
+	var res webhookMessage
+	if err := json.Unmarshal(blob, &res); err != nil {
+		return err
+	}
+	if res.ID != id {
+		return errWebhookIDMismatch
+	}
+	if res.Error != nil {
+		return fmt.Errorf("webhook error %d: %s", res.Error.Code, res.Error.Message)
+	}
+	if reply == nil {
+		return nil
+	}
+	if len(res.Result) == 0 {
+		return errWebhookEmptyMessage
+	}
+	return json.Unmarshal(res.Result, reply)
+}
+
+// mac returns the HMAC-SHA256 of the data, keyed with the shared secret.
+func (ui *WebhookUI) mac(data []byte) []byte {
+	mac := hmac.New(sha256.New, ui.secret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func (ui *WebhookUI) dispatch(method string, args interface{}, reply interface{}) error {
+	err := ui.call(ui.timeout, method, args, reply)
+	if err != nil {
+		log.Warn("Webhook UI request failed", "method", method, "err", err)
+	}
+	return err
+}
+
+func (ui *WebhookUI) notify(method string, args interface{}) {
+	if err := ui.call(ui.timeout, method, args, nil); err != nil {
+		log.Info("Webhook UI notification failed", "method", method, "err", err)
+	}
+}
+
+func (ui *WebhookUI) ApproveTx(request *SignTxRequest) (SignTxResponse, error) {
+	var result SignTxResponse
+	err := ui.dispatch("ApproveTx", request, &result)
+	return result, err
+}
+
+func (ui *WebhookUI) ApproveSignData(request *SignDataRequest) (SignDataResponse, error) {
+	var result SignDataResponse
+	err := ui.dispatch("ApproveSignData", request, &result)
+	return result, err
+}
+
+func (ui *WebhookUI) ApproveExport(request *ExportRequest) (ExportResponse, error) {
+	var result ExportResponse
+	err := ui.dispatch("ApproveExport", request, &result)
+	return result, err
+}
+
+func (ui *WebhookUI) ApproveImport(request *ImportRequest) (ImportResponse, error) {
+	var result ImportResponse
+	err := ui.dispatch("ApproveImport", request, &result)
+	return result, err
+}
+
+func (ui *WebhookUI) ApproveListing(request *ListRequest) (ListResponse, error) {
+	var result ListResponse
+	err := ui.dispatch("ApproveListing", request, &result)
+	return result, err
+}
+
+func (ui *WebhookUI) ApproveNewAccount(request *NewAccountRequest) (NewAccountResponse, error) {
+	var result NewAccountResponse
+	err := ui.dispatch("ApproveNewAccount", request, &result)
+	return result, err
+}
+
+func (ui *WebhookUI) ShowError(message string) {
+	ui.notify("ShowError", &Message{message})
+}
+
+func (ui *WebhookUI) ShowInfo(message string) {
+	ui.notify("ShowInfo", &Message{message})
+}
+
+func (ui *WebhookUI) OnApprovedTx(tx ethapi.SignTransactionResult) {
+	ui.notify("OnApprovedTx", tx)
+}
+
+func (ui *WebhookUI) OnSignerStartup(info StartupInfo) {
+	ui.notify("OnSignerStartup", info)
+}
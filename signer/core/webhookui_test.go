@@ -0,0 +1,172 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of go-genchain.
+//
+// go-genchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-genchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-genchain. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/hexutil"
+)
+
+var testWebhookSecret = []byte("0123456789abcdef")
+
+func webhookMAC(data []byte) string {
+	mac := hmac.New(sha256.New, testWebhookSecret)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newTestWebhook starts an approval service answering requests with the result
+// returned by decide, signing the responses with the given secret.
+func newTestWebhook(t *testing.T, secret []byte, decide func(req *webhookMessage) interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(WebhookSignatureHeader) != webhookMAC(body) {
+			t.Errorf("invalid request signature")
+			http.Error(w, "bad signature", http.StatusForbidden)
+			return
+		}
+		var req webhookMessage
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("invalid request: %v", err)
+			return
+		}
+		result, _ := json.Marshal(decide(&req))
+		resp, _ := json.Marshal(&webhookMessage{Version: "2.0", ID: req.ID, Result: result})
+
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(resp)
+		w.Header().Set(WebhookSignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+		w.Write(resp)
+	}))
+}
+
+func TestWebhookUI(t *testing.T) {
+	srv := newTestWebhook(t, testWebhookSecret, func(req *webhookMessage) interface{} {
+		if req.Method != "ApproveTx" {
+			return nil
+		}
+		tx := req.Params[0].(map[string]interface{})["transaction"].(map[string]interface{})
+		return map[string]interface{}{"transaction": tx, "approved": tx["value"] == "0x1", "password": "secret"}
+	})
+	defer srv.Close()
+
+	ui, err := NewWebhookUI(srv.URL, testWebhookSecret, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := mkTestTx(common.NewMixedcaseAddress(common.HexToAddress("0x1337")))
+	tx.Value = hexutil.Big(*big.NewInt(1))
+	res, err := ui.ApproveTx(&SignTxRequest{Transaction: tx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Approved || res.Password != "secret" {
+		t.Errorf("wrong decision: %+v", res)
+	}
+	tx.Value = hexutil.Big(*big.NewInt(2))
+	if res, err = ui.ApproveTx(&SignTxRequest{Transaction: tx}); err != nil || res.Approved {
+		t.Errorf("expected rejection, got %+v, %v", res, err)
+	}
+	ui.ShowInfo("notifications don't fail")
+}
+
+func TestWebhookUIForgedDecision(t *testing.T) {
+	approve := func(req *webhookMessage) interface{} {
+		return map[string]interface{}{"approved": true, "password": "secret"}
+	}
+	// Decisions signed with another key are rejected
+	srv := newTestWebhook(t, []byte("fedcba9876543210"), approve)
+	defer srv.Close()
+	ui, _ := NewWebhookUI(srv.URL, testWebhookSecret, time.Second)
+	if res, err := ui.ApproveSignData(&SignDataRequest{}); err != errWebhookSignature || res.Approved {
+		t.Errorf("expected signature error, got %+v, %v", res, err)
+	}
+	// Decisions replayed for another request are rejected
+	var recorded []byte
+	srv2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var req webhookMessage
+		json.Unmarshal(body, &req)
+		if recorded == nil {
+			result, _ := json.Marshal(approve(&req))
+			recorded, _ = json.Marshal(&webhookMessage{Version: "2.0", ID: req.ID, Result: result})
+		}
+		w.Header().Set(WebhookSignatureHeader, webhookMAC(recorded))
+		w.Write(recorded)
+	}))
+	defer srv2.Close()
+	ui, _ = NewWebhookUI(srv2.URL, testWebhookSecret, time.Second)
+	if res, err := ui.ApproveSignData(&SignDataRequest{}); err != nil || !res.Approved {
+		t.Fatalf("expected approval, got %+v, %v", res, err)
+	}
+	if res, err := ui.ApproveSignData(&SignDataRequest{}); err != errWebhookIDMismatch || res.Approved {
+		t.Errorf("expected id mismatch, got %+v, %v", res, err)
+	}
+}
+
+func TestWebhookUIRedirect(t *testing.T) {
+	var followed bool
+	target := newTestWebhook(t, testWebhookSecret, func(req *webhookMessage) interface{} {
+		followed = true
+		return map[string]interface{}{"approved": true, "password": "secret"}
+	})
+	defer target.Close()
+
+	// Redirects are not followed, the signed requests stay with the endpoint
+	srv := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer srv.Close()
+	ui, _ := NewWebhookUI(srv.URL, testWebhookSecret, time.Second)
+	if res, err := ui.ApproveSignData(&SignDataRequest{}); err == nil || res.Approved {
+		t.Errorf("expected failure, got %+v, %v", res, err)
+	}
+	if followed {
+		t.Error("redirect followed")
+	}
+}
+
+func TestWebhookUIEndpoint(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"http://127.0.0.1:8550/approve", true},
+		{"http://localhost:8550", true},
+		{"https://[::1]:8550", true},
+		{"http://10.0.0.1:8550", false},
+		{"http://dashboard.example.com", false},
+		{"ftp://127.0.0.1", false},
+	}
+	for _, test := range tests {
+		if _, err := NewWebhookUI(test.url, testWebhookSecret, time.Second); (err == nil) != test.ok {
+			t.Errorf("%s: got error %v", test.url, err)
+		}
+	}
+	if _, err := NewWebhookUI("http://127.0.0.1", []byte("short"), time.Second); err != errWebhookSecret {
+		t.Errorf("expected short secret error, got %v", err)
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of go-genchain.
+//
+// go-genchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-genchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-genchain. If not, see <http://www.gnu.org/licenses/>.
+
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/hexutil"
+	"github.com/genchain/go-genchain/common/math"
+	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/signer/core"
+	"github.com/genchain/go-genchain/signer/storage"
+)
+
+const (
+	valueWindow = 24 * time.Hour // Window of the daily value limit
+	rateWindow  = time.Hour      // Window of the transaction rate limit
+)
+
+// AccountPolicy limits the transactions of an account that the ruleset may
+// approve. Unset fields don't limit anything.
+type AccountPolicy struct {
+	DailyLimit   *math.HexOrDecimal256 `json:"dailyLimit"`   // Max value (wei) sent in 24 hours
+	Recipients   []common.Address      `json:"recipients"`   // Allowed recipients, no contract creation if set
+	MaxTxPerHour uint64                `json:"maxTxPerHour"` // Max number of transactions in an hour
+	MaxGasPrice  *math.HexOrDecimal256 `json:"maxGasPrice"`  // Max gas price (wei)
+}
+
+// spend is a transaction signed under a policy, as persisted in the storage.
+type spend struct {
+	Time  int64        `json:"time"` // Unix time of the signing
+	Value *hexutil.Big `json:"value"`
+}
+
+// reservation is the spend of a transaction approved under a policy that isn't
+// signed yet. It counts against the limits until the transaction is signed or
+// fails to be, so that concurrent approvals can't overrun them together.
+type reservation struct {
+	spend
+	nonce uint64
+}
+
+// policyEngine enforces the account policies declared by the ruleset, keeping
+// the spending history of the accounts in a storage of its own, out of reach of
+// the rules, so that the limits survive restarts.
+type policyEngine struct {
+	policies map[common.Address]*AccountPolicy
+	storage  storage.Storage
+	reserved map[common.Address][]reservation // Approved transactions being signed
+	now      func() time.Time
+
+	lock sync.Mutex // Protects the spending history in the storage and the reservations
+}
+
+func newPolicyEngine(policies map[common.Address]*AccountPolicy, storage storage.Storage) *policyEngine {
+	return &policyEngine{
+		policies: policies,
+		storage:  storage,
+		reserved: make(map[common.Address][]reservation),
+		now:      time.Now,
+	}
+}
+
+// check returns the first limit the transaction would exceed, or nil if it is
+// within the policy of its sender.
+func (p *policyEngine) check(tx *core.SendTxArgs) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	_, err := p.verify(tx)
+	return err
+}
+
+// reserve checks the transaction against the policy of its sender like check,
+// and if it is within the limits, reserves its spend until it is either signed
+// and recorded, or released.
+func (p *policyEngine) reserve(tx *core.SendTxArgs) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, err := p.verify(tx); err != nil {
+		return err
+	}
+	from := tx.From.Address()
+	if p.policies[from] != nil {
+		spent := spend{Time: p.now().Unix(), Value: (*hexutil.Big)(new(big.Int).Set(tx.Value.ToInt()))}
+		p.reserved[from] = append(p.reserved[from], reservation{spend: spent, nonce: uint64(tx.Nonce)})
+	}
+	return nil
+}
+
+// release drops the spend reserved for an approved transaction that failed to
+// be signed.
+func (p *policyEngine) release(tx *core.SendTxArgs) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.unreserve(tx.From.Address(), uint64(tx.Nonce), tx.Value.ToInt())
+}
+
+// unreserve drops the reservation matching a transaction, if any. The caller
+// must hold the lock.
+func (p *policyEngine) unreserve(from common.Address, nonce uint64, value *big.Int) {
+	reserved := p.reserved[from]
+	for i, res := range reserved {
+		if res.nonce == nonce && res.Value.ToInt().Cmp(value) == 0 {
+			p.reserved[from] = append(reserved[:i:i], reserved[i+1:]...)
+			break
+		}
+	}
+	if len(p.reserved[from]) == 0 {
+		delete(p.reserved, from)
+	}
+}
+
+// record adds a signed transaction to the spending history of its sender,
+// regardless of the limits, so it counts against later approvals.
+func (p *policyEngine) record(tx *types.Transaction) error {
+	if len(p.policies) == 0 {
+		return nil
+	}
+	var signer types.Signer = types.HomesteadSigner{}
+	if tx.Protected() {
+		signer = types.NewEIP155Signer(tx.ChainId())
+	}
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		return err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.policies[from] == nil {
+		return nil
+	}
+	// The spend is persisted in the history now, drop its reservation
+	p.unreserve(from, tx.Nonce(), tx.Value())
+
+	history, err := p.history(from)
+	if err != nil {
+		return err
+	}
+	history = append(history, spend{Time: p.now().Unix(), Value: (*hexutil.Big)(tx.Value())})
+	blob, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	p.storage.Put(policyKey(from), string(blob))
+	return nil
+}
+
+// verify checks the transaction against the policy of its sender, returning the
+// recent spending history of the account, including the reserved spends. The
+// history is nil if the account has no policy. The caller must hold the lock.
+func (p *policyEngine) verify(tx *core.SendTxArgs) ([]spend, error) {
+	policy := p.policies[tx.From.Address()]
+	if policy == nil {
+		return nil, nil
+	}
+	if policy.MaxGasPrice != nil && tx.GasPrice.ToInt().Cmp((*big.Int)(policy.MaxGasPrice)) > 0 {
+		return nil, fmt.Errorf("gas price %v above ceiling of %v", tx.GasPrice.ToInt(), (*big.Int)(policy.MaxGasPrice))
+	}
+	if len(policy.Recipients) > 0 {
+		if tx.To == nil {
+			return nil, fmt.Errorf("contract creation not allowed")
+		}
+		allowed := false
+		for _, recipient := range policy.Recipients {
+			if recipient == tx.To.Address() {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, fmt.Errorf("recipient %s not allowed", tx.To.Address().Hex())
+		}
+	}
+	history, err := p.history(tx.From.Address())
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range p.reserved[tx.From.Address()] {
+		history = append(history, res.spend)
+	}
+	if policy.MaxTxPerHour > 0 {
+		var count uint64
+		since := p.now().Add(-rateWindow).Unix()
+		for _, s := range history {
+			if s.Time > since {
+				count++
+			}
+		}
+		if count >= policy.MaxTxPerHour {
+			return nil, fmt.Errorf("rate limit of %d transactions per hour reached", policy.MaxTxPerHour)
+		}
+	}
+	if policy.DailyLimit != nil {
+		sum := new(big.Int).Set(tx.Value.ToInt())
+		for _, s := range history {
+			sum.Add(sum, s.Value.ToInt())
+		}
+		if sum.Cmp((*big.Int)(policy.DailyLimit)) > 0 {
+			return nil, fmt.Errorf("daily limit of %v wei exceeded", (*big.Int)(policy.DailyLimit))
+		}
+	}
+	return history, nil
+}
+
+// history loads the transactions of the account signed within the longest of
+// the limit windows.
+func (p *policyEngine) history(address common.Address) ([]spend, error) {
+	blob := p.storage.Get(policyKey(address))
+	if blob == "" {
+		return []spend{}, nil
+	}
+	var stored []spend
+	if err := json.Unmarshal([]byte(blob), &stored); err != nil {
+		return nil, fmt.Errorf("corrupt policy state: %v", err)
+	}
+	since := p.now().Add(-valueWindow).Unix()
+	history := make([]spend, 0, len(stored))
+	for _, s := range stored {
+		if s.Time > since && s.Value != nil {
+			history = append(history, s)
+		}
+	}
+	return history, nil
+}
+
+func policyKey(address common.Address) string {
+	return strings.ToLower(address.Hex())
+}
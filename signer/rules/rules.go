@@ -53,14 +53,16 @@ type rulesetUI struct {
 	storage     storage.Storage
 	credentials storage.Storage
 	jsRules     string // The rules to use
+	policy      *policyEngine
 }
 
-func NewRuleEvaluator(next core.SignerUI, jsbackend, credentialsBackend storage.Storage) (*rulesetUI, error) {
+func NewRuleEvaluator(next core.SignerUI, jsbackend, credentialsBackend, policyBackend storage.Storage) (*rulesetUI, error) {
 	c := &rulesetUI{
 		next:        next,
 		storage:     jsbackend,
 		credentials: credentialsBackend,
 		jsRules:     "",
+		policy:      newPolicyEngine(nil, policyBackend),
 	}
 
 	return c, nil
@@ -68,11 +70,32 @@ func NewRuleEvaluator(next core.SignerUI, jsbackend, credentialsBackend storage.
 
 func (r *rulesetUI) Init(javascriptRules string) error {
 	r.jsRules = javascriptRules
+
+	// Load the account policies declared by the ruleset
+	vm, err := r.newVM()
+	if err != nil {
+		return err
+	}
+	declared, err := vm.Get("policies")
+	if err != nil || declared.IsUndefined() {
+		return err
+	}
+	blob, err := vm.Call("JSON.stringify", nil, declared)
+	if err != nil {
+		return err
+	}
+	var policies map[common.Address]*AccountPolicy
+	if err := json.Unmarshal([]byte(blob.String()), &policies); err != nil {
+		return fmt.Errorf("invalid policies: %v", err)
+	}
+	r.policy.policies = policies
+	log.Info("Loaded account policies", "accounts", len(policies))
 	return nil
 }
-func (r *rulesetUI) execute(jsfunc string, jsarg interface{}) (otto.Value, error) {
 
-	// Instantiate a fresh vm engine every time
+// newVM instantiates a fresh vm engine with the native callbacks and the
+// libraries set, and the rule implementation loaded.
+func (r *rulesetUI) newVM() (*otto.Otto, error) {
 	vm := otto.New()
 	// Set the native callbacks
 	consoleObj, _ := vm.Get("console")
@@ -80,11 +103,15 @@ func (r *rulesetUI) execute(jsfunc string, jsarg interface{}) (otto.Value, error
 	consoleObj.Object().Set("error", consoleOutput)
 	vm.Set("storage", r.storage)
 
+	policyObj, _ := vm.Object("({})")
+	policyObj.Set("Check", r.checkPolicy)
+	vm.Set("policy", policyObj)
+
 	// Load bootstrap libraries
 	script, err := vm.Compile("bignumber.js", BigNumber_JS)
 	if err != nil {
 		log.Warn("Failed loading libraries", "err", err)
-		return otto.UndefinedValue(), err
+		return nil, err
 	}
 	vm.Run(script)
 
@@ -92,6 +119,34 @@ func (r *rulesetUI) execute(jsfunc string, jsarg interface{}) (otto.Value, error
 	_, err = vm.Run(r.jsRules)
 	if err != nil {
 		log.Warn("Execution failed", "err", err)
+		return nil, err
+	}
+	return vm, nil
+}
+
+// checkPolicy is the policy.Check callback of the rules, returning why the given
+// transaction is outside the policy of its sender, or an empty string if it is
+// within the limits.
+func (r *rulesetUI) checkPolicy(call otto.FunctionCall) otto.Value {
+	blob, err := call.Otto.Call("JSON.stringify", nil, call.Argument(0))
+	if err == nil {
+		var tx core.SendTxArgs
+		if err = json.Unmarshal([]byte(blob.String()), &tx); err == nil {
+			err = r.policy.check(&tx)
+		}
+	}
+	if err != nil {
+		v, _ := otto.ToValue(err.Error())
+		return v
+	}
+	v, _ := otto.ToValue("")
+	return v
+}
+
+func (r *rulesetUI) execute(jsfunc string, jsarg interface{}) (otto.Value, error) {
+	// Instantiate a fresh vm engine every time
+	vm, err := r.newVM()
+	if err != nil {
 		return otto.UndefinedValue(), err
 	}
 
@@ -148,6 +203,12 @@ func (r *rulesetUI) ApproveTx(request *core.SignTxRequest) (core.SignTxResponse,
 	}
 
 	if approved {
+		// The policies bound what the rules may approve, leave the rest to the user.
+		// The spend is reserved right away, so concurrent requests can't all pass.
+		if err := r.policy.reserve(&request.Transaction); err != nil {
+			log.Info("Rule-based approval outside account policy, going to manual", "error", err)
+			return r.next.ApproveTx(request)
+		}
 		return core.SignTxResponse{
 				Transaction: request.Transaction,
 				Approved:    true,
@@ -235,7 +296,22 @@ func (r *rulesetUI) OnSignerStartup(info core.StartupInfo) {
 	}
 }
 
+// OnFailedTx implements core.FailedTxNotifier, releasing the spend reserved for
+// an approved transaction that failed to be signed.
+func (r *rulesetUI) OnFailedTx(tx core.SendTxArgs, err error) {
+	r.policy.release(&tx)
+	if notifier, ok := r.next.(core.FailedTxNotifier); ok {
+		notifier.OnFailedTx(tx, err)
+	}
+}
+
 func (r *rulesetUI) OnApprovedTx(tx ethapi.SignTransactionResult) {
+	// Count the transaction against the policy of its sender, now that it's signed
+	if tx.Tx != nil {
+		if err := r.policy.record(tx.Tx); err != nil {
+			log.Warn("Failed to record signed transaction", "error", err)
+		}
+	}
 	jsonTx, err := json.Marshal(tx)
 	if err != nil {
 		log.Warn("failed marshalling transaction", "tx", tx)
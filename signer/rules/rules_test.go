@@ -17,18 +17,24 @@
 package rules
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/genchain/go-genchain/accounts"
 	"github.com/genchain/go-genchain/common"
 	"github.com/genchain/go-genchain/common/hexutil"
 	"github.com/genchain/go-genchain/core/types"
+	"github.com/genchain/go-genchain/crypto"
 	"github.com/genchain/go-genchain/internal/ethapi"
 	"github.com/genchain/go-genchain/signer/core"
 	"github.com/genchain/go-genchain/signer/storage"
+	"github.com/genchain/go-genchain/signer/typeddata"
 )
 
 const JS = `
@@ -114,7 +120,7 @@ func (alwaysDenyUI) OnApprovedTx(tx ethapi.SignTransactionResult) {
 }
 
 func initRuleEngine(js string) (*rulesetUI, error) {
-	r, err := NewRuleEvaluator(&alwaysDenyUI{}, storage.NewEphemeralStorage(), storage.NewEphemeralStorage())
+	r, err := NewRuleEvaluator(&alwaysDenyUI{}, storage.NewEphemeralStorage(), storage.NewEphemeralStorage(), storage.NewEphemeralStorage())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create js engine: %v", err)
 	}
@@ -251,7 +257,7 @@ func TestForwarding(t *testing.T) {
 	ui := &dummyUI{make([]string, 0)}
 	jsBackend := storage.NewEphemeralStorage()
 	credBackend := storage.NewEphemeralStorage()
-	r, err := NewRuleEvaluator(ui, jsBackend, credBackend)
+	r, err := NewRuleEvaluator(ui, jsBackend, credBackend, storage.NewEphemeralStorage())
 	if err != nil {
 		t.Fatalf("Failed to create js engine: %v", err)
 	}
@@ -574,7 +580,7 @@ func TestContextIsCleared(t *testing.T) {
 	}
 	`
 	ui := &dontCallMe{t}
-	r, err := NewRuleEvaluator(ui, storage.NewEphemeralStorage(), storage.NewEphemeralStorage())
+	r, err := NewRuleEvaluator(ui, storage.NewEphemeralStorage(), storage.NewEphemeralStorage(), storage.NewEphemeralStorage())
 	if err != nil {
 		t.Fatalf("Failed to create js engine: %v", err)
 	}
@@ -629,3 +635,264 @@ function ApproveSignData(r){
 		t.Fatalf("Expected approved")
 	}
 }
+
+func TestSignTypedData(t *testing.T) {
+	js := `function ApproveSignData(r){
+    if (r.content_type != "data/typed") {
+        return "Reject"
+    }
+    if (r.typed_data.domain.verifyingContract.toLowerCase() == "0xcccccccccccccccccccccccccccccccccccccccc" &&
+        r.typed_data.message.amount <= 100) {
+        return "Approve"
+    }
+    return "Reject"
+}`
+	r, err := initRuleEngine(js)
+	if err != nil {
+		t.Fatalf("Couldn't create evaluator %v", err)
+	}
+	addr, _ := mixAddr("0x694267f14675d7e1b9494fd8d72fefe1755710fa")
+	approve := func(amount int) bool {
+		typedData := typeddata.TypedData{
+			Types: typeddata.Types{
+				"EIP712Domain": {{Name: "verifyingContract", Type: "address"}},
+				"Payment":      {{Name: "amount", Type: "uint256"}},
+			},
+			PrimaryType: "Payment",
+			Domain:      typeddata.TypedDataDomain{VerifyingContract: "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"},
+			Message:     typeddata.TypedDataMessage{"amount": float64(amount)},
+		}
+		resp, err := r.ApproveSignData(&core.SignDataRequest{
+			ContentType: core.DataTyped,
+			Address:     *addr,
+			TypedData:   &typedData,
+			Meta:        core.Metadata{Remote: "remoteip", Local: "localip", Scheme: "inproc"},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		return resp.Approved
+	}
+	if !approve(50) {
+		t.Error("Expected small payment to be approved")
+	}
+	if approve(500) {
+		t.Error("Expected large payment to be rejected")
+	}
+}
+
+const ExamplePolicies = `
+	var policies = {
+		"0x71562b71999873db5b286df957af199ec94617f7": {
+			"dailyLimit": "1000",
+			"recipients": ["0x000000000000000000000000000000000000dead"],
+			"maxTxPerHour": 3,
+			"maxGasPrice": "0x2dc6c0"
+		}
+	}
+	function ApproveTx(r){
+		return "Approve"
+	}
+	function CheckTx(tx){
+		return policy.Check(tx)
+	}
+`
+
+// policyTestKey is the key of the account limited by the test policies.
+var policyTestKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+// policyTx creates a transaction request sent from the account with a policy.
+func policyTx(value uint64) *core.SignTxRequest {
+	req := dummyTxWithV(value)
+	req.Transaction.From = common.NewMixedcaseAddress(crypto.PubkeyToAddress(policyTestKey.PublicKey))
+	return req
+}
+
+// signPolicyTx signs an approved transaction of the account with a policy, and
+// notifies the ruleset about it like the signer does.
+func signPolicyTx(t *testing.T, r *rulesetUI, args *core.SendTxArgs) {
+	tx := types.NewTransaction(uint64(args.Nonce), args.To.Address(), args.Value.ToInt(), uint64(args.Gas), args.GasPrice.ToInt(), nil)
+	signed, err := types.SignTx(tx, types.NewEIP155Signer(big.NewInt(1)), policyTestKey)
+	if err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	r.OnApprovedTx(ethapi.SignTransactionResult{Tx: signed})
+}
+
+func TestPolicies(t *testing.T) {
+	ui := &dummyUI{make([]string, 0)}
+	policyBackend := storage.NewEphemeralStorage()
+	newEngine := func() *rulesetUI {
+		r, err := NewRuleEvaluator(ui, storage.NewEphemeralStorage(), storage.NewEphemeralStorage(), policyBackend)
+		if err != nil {
+			t.Fatalf("Failed to create js engine: %v", err)
+		}
+		if err = r.Init(ExamplePolicies); err != nil {
+			t.Fatalf("Failed to load policies: %v", err)
+		}
+		return r
+	}
+	request := func(r *rulesetUI, value uint64, exp bool) *core.SignTxResponse {
+		t.Helper()
+		calls := len(ui.calls)
+		resp, _ := r.ApproveTx(policyTx(value))
+		if resp.Approved != exp {
+			t.Errorf("value %d: expected approval %v, got %v", value, exp, resp.Approved)
+		}
+		// Transactions outside the policy go to manual processing
+		if forwarded := len(ui.calls) > calls; forwarded == exp {
+			t.Errorf("value %d: expected forwarding %v, got %v", value, !exp, forwarded)
+		}
+		return &resp
+	}
+	approve := func(r *rulesetUI, value uint64, exp bool) {
+		t.Helper()
+		if resp := request(r, value, exp); resp.Approved {
+			signPolicyTx(t, r, &resp.Transaction)
+		}
+	}
+	// Approved transactions count until they fail to be signed
+	r := newEngine()
+	for i := 0; i < 3; i++ {
+		resp := request(r, 1000, true)
+		request(r, 1, false)
+		r.OnFailedTx(resp.Transaction, errors.New("signing failed"))
+	}
+
+	approve(r, 600, true)
+	approve(r, 500, false) // Daily limit
+	approve(r, 400, true)
+	approve(r, 0, true)
+	approve(r, 0, false) // Rate limit
+
+	// The spending history survives restarts, and expires with the windows
+	r = newEngine()
+	approve(r, 0, false)
+	r.policy.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	approve(r, 0, true)
+	approve(r, 1, false)
+	r.policy.now = func() time.Time { return time.Now().Add(25 * time.Hour) }
+	approve(r, 1000, true)
+
+	// The limits are exposed to the rules
+	check := func(tx *core.SignTxRequest) string {
+		blob, _ := json.Marshal(&tx.Transaction)
+		v, err := r.execute("CheckTx", string(blob))
+		if err != nil {
+			t.Fatalf("Failed to check policy: %v", err)
+		}
+		return v.String()
+	}
+	if v := check(policyTx(1)); v != "daily limit of 1000 wei exceeded" {
+		t.Errorf("Unexpected policy check result: %q", v)
+	}
+	tx := policyTx(0)
+	tx.Transaction.GasPrice = hexutil.Big(*big.NewInt(3000001))
+	if v := check(tx); v != "gas price 3000001 above ceiling of 3000000" {
+		t.Errorf("Unexpected policy check result: %q", v)
+	}
+	tx = policyTx(0)
+	tx.Transaction.To, _ = mixAddr("0x0000000000000000000000000000000000001337")
+	if v := check(tx); v != "recipient 0x0000000000000000000000000000000000001337 not allowed" {
+		t.Errorf("Unexpected policy check result: %q", v)
+	}
+	tx.Transaction.From, tx.Transaction.To = *tx.Transaction.To, nil
+	if v := check(tx); v != "" {
+		t.Errorf("Expected no policy for account, got %q", v)
+	}
+}
+
+// approvingUI is a dummyUI approving all transactions.
+type approvingUI struct {
+	*dummyUI
+}
+
+func (a approvingUI) ApproveTx(request *core.SignTxRequest) (core.SignTxResponse, error) {
+	a.calls = append(a.calls, "ApproveTx")
+	return core.SignTxResponse{Transaction: request.Transaction, Approved: true}, nil
+}
+
+// Tests that manually approved transactions count against the policy limits, and
+// that the rules can't tamper with the spending history.
+func TestPolicyManualApprovals(t *testing.T) {
+	js := `
+	var policies = {
+		"0x71562b71999873db5b286df957af199ec94617f7": {
+			"dailyLimit": "1000"
+		}
+	}
+	function ApproveTx(r){
+		storage.Put("0x71562b71999873db5b286df957af199ec94617f7", "[]")
+		return "Approve"
+	}
+	`
+	ui := approvingUI{&dummyUI{make([]string, 0)}}
+	r, err := NewRuleEvaluator(ui, storage.NewEphemeralStorage(), storage.NewEphemeralStorage(), storage.NewEphemeralStorage())
+	if err != nil {
+		t.Fatalf("Failed to create js engine: %v", err)
+	}
+	if err = r.Init(js); err != nil {
+		t.Fatalf("Failed to load policies: %v", err)
+	}
+	approve := func(value uint64, manual bool) {
+		t.Helper()
+		calls := len(ui.calls)
+		resp, _ := r.ApproveTx(policyTx(value))
+		if !resp.Approved {
+			t.Errorf("value %d: expected approval", value)
+		}
+		if forwarded := len(ui.calls) > calls; forwarded != manual {
+			t.Errorf("value %d: expected forwarding %v, got %v", value, manual, forwarded)
+		}
+		signPolicyTx(t, r, &resp.Transaction)
+	}
+	approve(1500, true) // Over the limit, approved manually
+	approve(1, true)    // Limit used up by the manual approval
+	approve(0, true)
+}
+
+// Tests that concurrent requests can't exceed the policy limits together, as the
+// spends are reserved on approval, and that reservations turn into the history
+// once the transactions are signed.
+func TestPolicyConcurrentApprovals(t *testing.T) {
+	r, err := initRuleEngine(ExamplePolicies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	approveAll := func(value uint64, requests int) []core.SignTxResponse {
+		var (
+			approved []core.SignTxResponse
+			lock     sync.Mutex
+			pend     sync.WaitGroup
+		)
+		for i := 0; i < requests; i++ {
+			pend.Add(1)
+			go func() {
+				defer pend.Done()
+				if resp, _ := r.ApproveTx(policyTx(value)); resp.Approved {
+					lock.Lock()
+					approved = append(approved, resp)
+					lock.Unlock()
+				}
+			}()
+		}
+		pend.Wait()
+		return approved
+	}
+	// Only two of the concurrent requests fit in the daily limit
+	approved := approveAll(400, 10)
+	if len(approved) != 2 {
+		t.Fatalf("daily limit: expected 2 approvals, got %d", len(approved))
+	}
+	// Signing one moves its spend to the history, failing the other releases it
+	signPolicyTx(t, r, &approved[0].Transaction)
+	r.OnFailedTx(approved[1].Transaction, errors.New("signing failed"))
+
+	if approved = approveAll(300, 10); len(approved) != 2 {
+		t.Fatalf("daily limit after signing: expected 2 approvals, got %d", len(approved))
+	}
+	// The rate limit of three transactions an hour is reserved likewise
+	if approved = approveAll(0, 10); len(approved) != 0 {
+		t.Fatalf("rate limit: expected no approvals, got %d", len(approved))
+	}
+}
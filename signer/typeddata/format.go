@@ -0,0 +1,105 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package typeddata
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// NameValueType is a member of a typed message, formatted for display. The value
+// of structs and arrays is the list of their members.
+type NameValueType struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+	Typ   string      `json:"type"`
+}
+
+// Pprint returns the member as indented text.
+func (nvt *NameValueType) Pprint(depth int) string {
+	var output bytes.Buffer
+	output.WriteString(strings.Repeat(" ", depth*2))
+	output.WriteString(fmt.Sprintf("%s [%s]: ", nvt.Name, nvt.Typ))
+	if members, ok := nvt.Value.([]*NameValueType); ok {
+		output.WriteString("\n")
+		for _, member := range members {
+			output.WriteString(member.Pprint(depth + 1))
+		}
+		return output.String()
+	}
+	output.WriteString(fmt.Sprintf("%v\n", nvt.Value))
+	return output.String()
+}
+
+// Format returns the domain and the message of the typed data as a tree of
+// members, for the user to review before signing.
+func (typedData *TypedData) Format() ([]*NameValueType, error) {
+	domain, err := typedData.formatData(DomainType, typedData.Domain.Map())
+	if err != nil {
+		return nil, err
+	}
+	message, err := typedData.formatData(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, err
+	}
+	return []*NameValueType{
+		{Name: DomainType, Value: domain, Typ: "domain"},
+		{Name: typedData.PrimaryType, Value: message, Typ: "primary type"},
+	}, nil
+}
+
+func (typedData *TypedData) formatData(primaryType string, data map[string]interface{}) ([]*NameValueType, error) {
+	var output []*NameValueType
+	for _, field := range typedData.Types[primaryType] {
+		value, err := typedData.formatValue(field.Type, data[field.Name])
+		if err != nil {
+			return nil, fmt.Errorf("typed data: %s.%s: %v", primaryType, field.Name, err)
+		}
+		output = append(output, &NameValueType{Name: field.Name, Value: value, Typ: field.Type})
+	}
+	return output, nil
+}
+
+func (typedData *TypedData) formatValue(typ string, value interface{}) (interface{}, error) {
+	if inner, _, ok := arrayType(typ); ok {
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected array, got %T", value)
+		}
+		var output []*NameValueType
+		for i, item := range items {
+			formatted, err := typedData.formatValue(inner, item)
+			if err != nil {
+				return nil, err
+			}
+			output = append(output, &NameValueType{Name: fmt.Sprintf("[%d]", i), Value: formatted, Typ: inner})
+		}
+		return output, nil
+	}
+	if _, ok := typedData.Types[typ]; ok {
+		data, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected %s struct, got %T", typ, value)
+		}
+		return typedData.formatData(typ, data)
+	}
+	if n, err := parseInteger(value); err == nil && strings.Contains(typ, "int") {
+		return n.String(), nil
+	}
+	return fmt.Sprintf("%v", value), nil
+}
@@ -0,0 +1,491 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package typeddata implements the hashing of EIP-712 typed structured data.
+//
+// Typed data is signed over
+//
+//   keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message))
+//
+// where the domain separator is the struct hash of the EIP712Domain, binding the
+// signature to a single DApp, contract and chain.
+//
+// https://eips.ethereum.org/EIPS/eip-712
+package typeddata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/hexutil"
+	"github.com/genchain/go-genchain/common/math"
+	"github.com/genchain/go-genchain/crypto"
+)
+
+// DomainType is the name of the type describing the signing domain.
+const DomainType = "EIP712Domain"
+
+var (
+	typeNameRegex     = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*$`)
+	sizedPrimitiveRe  = regexp.MustCompile(`^(u?int|bytes)([0-9]*)$`)
+	errMissingDomain  = fmt.Errorf("typed data: missing %s type", DomainType)
+	errMissingPrimary = errors.New("typed data: primary type not defined")
+)
+
+// TypedData is an EIP-712 typed message, as sent by DApps in eth_signTypedData
+// requests.
+type TypedData struct {
+	Types       Types            `json:"types"`
+	PrimaryType string           `json:"primaryType"`
+	Domain      TypedDataDomain  `json:"domain"`
+	Message     TypedDataMessage `json:"message"`
+}
+
+// Type is a member of a struct type.
+type Type struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Types are the struct types of a typed message, keyed by type name.
+type Types map[string][]Type
+
+// TypedDataMessage is the primary struct of a typed message.
+type TypedDataMessage map[string]interface{}
+
+// UnmarshalJSON decodes a message, keeping its numbers as json.Number so that
+// integers beyond the precision of float64 don't get rounded.
+func (m *TypedDataMessage) UnmarshalJSON(input []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(input))
+	dec.UseNumber()
+
+	var msg map[string]interface{}
+	if err := dec.Decode(&msg); err != nil {
+		return err
+	}
+	*m = msg
+	return nil
+}
+
+// TypedDataDomain is the signing domain of a typed message. Only the fields set
+// are part of the domain, and they must be declared in the EIP712Domain type.
+type TypedDataDomain struct {
+	Name              string                `json:"name,omitempty"`
+	Version           string                `json:"version,omitempty"`
+	ChainId           *math.HexOrDecimal256 `json:"chainId,omitempty"`
+	VerifyingContract string                `json:"verifyingContract,omitempty"`
+	Salt              string                `json:"salt,omitempty"`
+}
+
+// UnmarshalJSON decodes a domain, accepting the chain id both as JSON number
+// and as hex or decimal string.
+func (d *TypedDataDomain) UnmarshalJSON(input []byte) error {
+	type domain TypedDataDomain
+	var dec struct {
+		domain
+		ChainId json.RawMessage `json:"chainId"`
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	*d = TypedDataDomain(dec.domain)
+	if len(dec.ChainId) > 0 && string(dec.ChainId) != "null" {
+		id, ok := math.ParseBig256(strings.Trim(string(dec.ChainId), `"`))
+		if !ok {
+			return fmt.Errorf("typed data: invalid chain id %s", dec.ChainId)
+		}
+		d.ChainId = (*math.HexOrDecimal256)(id)
+	}
+	return nil
+}
+
+// Map returns the fields of the domain that are set.
+func (d *TypedDataDomain) Map() map[string]interface{} {
+	fields := make(map[string]interface{})
+	if d.Name != "" {
+		fields["name"] = d.Name
+	}
+	if d.Version != "" {
+		fields["version"] = d.Version
+	}
+	if d.ChainId != nil {
+		fields["chainId"] = (*big.Int)(d.ChainId)
+	}
+	if d.VerifyingContract != "" {
+		fields["verifyingContract"] = d.VerifyingContract
+	}
+	if d.Salt != "" {
+		fields["salt"] = d.Salt
+	}
+	return fields
+}
+
+// TypedDataAndHash validates the typed data and returns the hash to sign along
+// with the raw preimage of the hash.
+func TypedDataAndHash(typedData TypedData) ([]byte, string, error) {
+	if err := typedData.validate(); err != nil {
+		return nil, "", err
+	}
+	domainSeparator, err := typedData.HashStruct(DomainType, typedData.Domain.Map())
+	if err != nil {
+		return nil, "", err
+	}
+	typedDataHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, "", err
+	}
+	rawData := fmt.Sprintf("\x19\x01%s%s", string(domainSeparator), string(typedDataHash))
+	return crypto.Keccak256([]byte(rawData)), rawData, nil
+}
+
+// HashStruct computes the struct hash of the given data as the given type.
+func (typedData *TypedData) HashStruct(primaryType string, data map[string]interface{}) (hexutil.Bytes, error) {
+	encoded, err := typedData.EncodeData(primaryType, data)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(encoded), nil
+}
+
+// Dependencies returns the struct types the given type refers to, itself first
+// and the rest in the order they are found.
+func (typedData *TypedData) Dependencies(primaryType string, found []string) []string {
+	primaryType = baseType(primaryType)
+	for _, dep := range found {
+		if dep == primaryType {
+			return found
+		}
+	}
+	if _, ok := typedData.Types[primaryType]; !ok {
+		return found
+	}
+	found = append(found, primaryType)
+	for _, field := range typedData.Types[primaryType] {
+		found = typedData.Dependencies(field.Type, found)
+	}
+	return found
+}
+
+// EncodeType returns the encoding of a type and the types it refers to, e.g.
+//
+//   Mail(Person from,Person to,string contents)Person(string name,address wallet)
+func (typedData *TypedData) EncodeType(primaryType string) hexutil.Bytes {
+	deps := typedData.Dependencies(primaryType, nil)
+	if len(deps) > 0 {
+		sort.Strings(deps[1:])
+	}
+	var buffer bytes.Buffer
+	for _, dep := range deps {
+		buffer.WriteString(dep)
+		buffer.WriteString("(")
+		for i, field := range typedData.Types[dep] {
+			if i > 0 {
+				buffer.WriteString(",")
+			}
+			buffer.WriteString(field.Type)
+			buffer.WriteString(" ")
+			buffer.WriteString(field.Name)
+		}
+		buffer.WriteString(")")
+	}
+	return buffer.Bytes()
+}
+
+// TypeHash returns the hash of the encoding of a type.
+func (typedData *TypedData) TypeHash(primaryType string) hexutil.Bytes {
+	return crypto.Keccak256(typedData.EncodeType(primaryType))
+}
+
+// EncodeData encodes the given data as the given type: the type hash followed by
+// the encoded members. Every member must be present in the data, and the data
+// may not hold values that are not members, so that everything signed is shown.
+func (typedData *TypedData) EncodeData(primaryType string, data map[string]interface{}) (hexutil.Bytes, error) {
+	fields, ok := typedData.Types[primaryType]
+	if !ok {
+		return nil, fmt.Errorf("typed data: unknown type %q", primaryType)
+	}
+	if len(data) != len(fields) {
+		for name := range data {
+			if !hasField(fields, name) {
+				return nil, fmt.Errorf("typed data: %s has no member %q", primaryType, name)
+			}
+		}
+	}
+	buffer := bytes.NewBuffer(typedData.TypeHash(primaryType))
+	for _, field := range fields {
+		value, ok := data[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("typed data: missing value of %s.%s", primaryType, field.Name)
+		}
+		encoded, err := typedData.encodeValue(field.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("typed data: %s.%s: %v", primaryType, field.Name, err)
+		}
+		buffer.Write(encoded)
+	}
+	return buffer.Bytes(), nil
+}
+
+// encodeValue encodes a member value into a single word: arrays and structs are
+// hashed, atomic values are padded.
+func (typedData *TypedData) encodeValue(typ string, value interface{}) ([]byte, error) {
+	if inner, size, ok := arrayType(typ); ok {
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected array, got %T", value)
+		}
+		if size >= 0 && len(items) != size {
+			return nil, fmt.Errorf("expected %d items, got %d", size, len(items))
+		}
+		var buffer bytes.Buffer
+		for _, item := range items {
+			encoded, err := typedData.encodeValue(inner, item)
+			if err != nil {
+				return nil, err
+			}
+			buffer.Write(encoded)
+		}
+		return crypto.Keccak256(buffer.Bytes()), nil
+	}
+	if _, ok := typedData.Types[typ]; ok {
+		data, ok := value.(map[string]interface{})
+		if !ok {
+			if msg, isMsg := value.(TypedDataMessage); isMsg {
+				data, ok = msg, true
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("expected %s struct, got %T", typ, value)
+		}
+		encoded, err := typedData.EncodeData(typ, data)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(encoded), nil
+	}
+	return encodePrimitive(typ, value)
+}
+
+// encodePrimitive encodes an atomic or dynamic value.
+func encodePrimitive(typ string, value interface{}) ([]byte, error) {
+	switch typ {
+	case "address":
+		s, ok := value.(string)
+		if !ok || !common.IsHexAddress(s) {
+			return nil, fmt.Errorf("invalid address %v", value)
+		}
+		return common.LeftPadBytes(common.HexToAddress(s).Bytes(), 32), nil
+	case "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("invalid bool %v", value)
+		}
+		if b {
+			return math.PaddedBigBytes(common.Big1, 32), nil
+		}
+		return make([]byte, 32), nil
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid string %v", value)
+		}
+		return crypto.Keccak256([]byte(s)), nil
+	case "bytes":
+		b, err := parseBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(b), nil
+	}
+	match := sizedPrimitiveRe.FindStringSubmatch(typ)
+	if match == nil || match[2] == "" {
+		return nil, fmt.Errorf("unknown type %q", typ)
+	}
+	size, err := strconv.Atoi(match[2])
+	if err != nil {
+		return nil, fmt.Errorf("unknown type %q", typ)
+	}
+	if match[1] == "bytes" {
+		if size < 1 || size > 32 {
+			return nil, fmt.Errorf("unknown type %q", typ)
+		}
+		b, err := parseBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > size {
+			return nil, fmt.Errorf("%d bytes don't fit %s", len(b), typ)
+		}
+		return common.RightPadBytes(b, 32), nil
+	}
+	if size < 8 || size > 256 || size%8 != 0 {
+		return nil, fmt.Errorf("unknown type %q", typ)
+	}
+	n, err := parseInteger(value)
+	if err != nil {
+		return nil, err
+	}
+	if match[1] == "uint" {
+		if n.Sign() < 0 || n.BitLen() > size {
+			return nil, fmt.Errorf("%v overflows %s", n, typ)
+		}
+	} else {
+		limit := new(big.Int).Lsh(common.Big1, uint(size-1))
+		if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return nil, fmt.Errorf("%v overflows %s", n, typ)
+		}
+	}
+	return math.PaddedBigBytes(math.U256(new(big.Int).Set(n)), 32), nil
+}
+
+// parseBytes decodes a hex encoded byte string.
+func parseBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		return hexutil.Decode(v)
+	case []byte:
+		return v, nil
+	case hexutil.Bytes:
+		return v, nil
+	}
+	return nil, fmt.Errorf("invalid bytes %v", value)
+}
+
+// parseInteger converts a JSON number or a hex or decimal string to an integer,
+// rejecting numbers with a fraction or an exponent.
+func parseInteger(value interface{}) (*big.Int, error) {
+	switch v := value.(type) {
+	case *big.Int:
+		return v, nil
+	case *math.HexOrDecimal256:
+		return (*big.Int)(v), nil
+	case float64:
+		n, accuracy := new(big.Float).SetFloat64(v).Int(nil)
+		if accuracy != big.Exact {
+			return nil, fmt.Errorf("invalid integer %v", v)
+		}
+		return n, nil
+	case json.Number:
+		return parseIntegerString(string(v))
+	case string:
+		return parseIntegerString(v)
+	}
+	return nil, fmt.Errorf("invalid integer %v", value)
+}
+
+func parseIntegerString(s string) (*big.Int, error) {
+	if strings.HasPrefix(s, "-") {
+		n, ok := math.ParseBig256(s[1:])
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %q", s)
+		}
+		return n.Neg(n), nil
+	}
+	n, ok := math.ParseBig256(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer %q", s)
+	}
+	return n, nil
+}
+
+// validate checks that the types are well formed and refer only to primitive
+// and defined struct types.
+func (typedData *TypedData) validate() error {
+	if _, ok := typedData.Types[DomainType]; !ok {
+		return errMissingDomain
+	}
+	if _, ok := typedData.Types[typedData.PrimaryType]; !ok {
+		return errMissingPrimary
+	}
+	for name, fields := range typedData.Types {
+		if !typeNameRegex.MatchString(name) {
+			return fmt.Errorf("typed data: invalid type name %q", name)
+		}
+		for _, field := range fields {
+			if field.Name == "" {
+				return fmt.Errorf("typed data: unnamed member of %s", name)
+			}
+			if !typedData.isKnownType(baseType(field.Type)) {
+				return fmt.Errorf("typed data: %s.%s has unknown type %q", name, field.Name, field.Type)
+			}
+		}
+	}
+	return nil
+}
+
+func (typedData *TypedData) isKnownType(typ string) bool {
+	if _, ok := typedData.Types[typ]; ok {
+		return true
+	}
+	switch typ {
+	case "address", "bool", "string", "bytes":
+		return true
+	}
+	match := sizedPrimitiveRe.FindStringSubmatch(typ)
+	if match == nil || match[2] == "" {
+		return false
+	}
+	size, _ := strconv.Atoi(match[2])
+	if match[1] == "bytes" {
+		return size >= 1 && size <= 32
+	}
+	return size >= 8 && size <= 256 && size%8 == 0
+}
+
+// arrayType splits an array type into its element type and size, which is -1
+// for dynamic arrays.
+func arrayType(typ string) (string, int, bool) {
+	if !strings.HasSuffix(typ, "]") {
+		return typ, 0, false
+	}
+	i := strings.LastIndex(typ, "[")
+	if i < 0 {
+		return typ, 0, false
+	}
+	if typ[i+1:len(typ)-1] == "" {
+		return typ[:i], -1, true
+	}
+	size, err := strconv.Atoi(typ[i+1 : len(typ)-1])
+	if err != nil || size < 0 {
+		return typ, 0, false
+	}
+	return typ[:i], size, true
+}
+
+// baseType strips all array suffixes from a type.
+func baseType(typ string) string {
+	if i := strings.Index(typ, "["); i >= 0 {
+		return typ[:i]
+	}
+	return typ
+}
+
+func hasField(fields []Type, name string) bool {
+	for _, field := range fields {
+		if field.Name == name {
+			return true
+		}
+	}
+	return false
+}
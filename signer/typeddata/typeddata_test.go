@@ -0,0 +1,206 @@
+// Copyright 2018 The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package typeddata
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/common/hexutil"
+	"github.com/genchain/go-genchain/crypto"
+)
+
+// mailJSON is the example message of the EIP-712 specification.
+const mailJSON = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+func loadMail(t *testing.T) TypedData {
+	var typedData TypedData
+	if err := json.Unmarshal([]byte(mailJSON), &typedData); err != nil {
+		t.Fatalf("failed to decode typed data: %v", err)
+	}
+	return typedData
+}
+
+func TestTypedDataHashing(t *testing.T) {
+	typedData := loadMail(t)
+
+	if enc := string(typedData.EncodeType("Mail")); enc != "Mail(Person from,Person to,string contents)Person(string name,address wallet)" {
+		t.Errorf("wrong type encoding: %s", enc)
+	}
+	if hash := typedData.TypeHash("Mail").String(); hash != "0xa0cedeb2dc280ba39b857546d74f5549c3a1d7bdc2dd96bf881f76108e23dac2" {
+		t.Errorf("wrong type hash: %s", hash)
+	}
+	domainSeparator, err := typedData.HashStruct(DomainType, typedData.Domain.Map())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if domainSeparator.String() != "0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f" {
+		t.Errorf("wrong domain separator: %s", domainSeparator)
+	}
+	structHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if structHash.String() != "0xc52c0ee5d84264471806290a3f2c4cecfc5490626bf912d01f240d7a274b371e" {
+		t.Errorf("wrong message hash: %s", structHash)
+	}
+	sighash, _, err := TypedDataAndHash(typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hexutil.Encode(sighash) != "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2" {
+		t.Fatalf("wrong signing hash: %x", sighash)
+	}
+	// The signature of the specification is made with keccak256("cow")
+	key, _ := crypto.ToECDSA(crypto.Keccak256([]byte("cow")))
+	if addr := crypto.PubkeyToAddress(key.PublicKey); addr != common.HexToAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826") {
+		t.Fatalf("wrong signer %x", addr)
+	}
+	sig, err := crypto.Sign(sighash, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b9156201"
+	if hexutil.Encode(sig) != want {
+		t.Errorf("wrong signature: %x", sig)
+	}
+}
+
+func TestTypedDataErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*TypedData)
+		err    string
+	}{
+		{"missing domain type", func(td *TypedData) { delete(td.Types, DomainType) }, "missing EIP712Domain"},
+		{"missing primary type", func(td *TypedData) { td.PrimaryType = "Letter" }, "primary type not defined"},
+		{"unknown member type", func(td *TypedData) { td.Types["Person"][1].Type = "wallet" }, "unknown type"},
+		{"missing value", func(td *TypedData) { delete(td.Message, "contents") }, "missing value of Mail.contents"},
+		{"extra value", func(td *TypedData) { td.Message["cc"] = "Alice" }, `no member "cc"`},
+		{"invalid address", func(td *TypedData) {
+			td.Message["to"].(map[string]interface{})["wallet"] = "0xbb"
+		}, "invalid address"},
+		{"undeclared domain field", func(td *TypedData) { td.Domain.Salt = "0x01" }, `no member "salt"`},
+	}
+	for _, test := range tests {
+		typedData := loadMail(t)
+		test.modify(&typedData)
+		if _, _, err := TypedDataAndHash(typedData); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestEncodePrimitive(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value interface{}
+		want  string // hex, empty for an error
+	}{
+		{"uint8", float64(255), "0x00000000000000000000000000000000000000000000000000000000000000ff"},
+		{"uint8", float64(256), ""},
+		{"uint256", "0x10", "0x0000000000000000000000000000000000000000000000000000000000000010"},
+		{"uint256", float64(1.5), ""},
+		{"uint256", json.Number("115792089237316195423570985008687907853269984665640564039457584007913129639935"), "0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"},
+		{"uint256", json.Number("1.5"), ""},
+		{"uint256", json.Number("1e3"), ""},
+		{"int8", json.Number("-128"), "0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff80"},
+		{"int8", "-128", "0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff80"},
+		{"int8", "-129", ""},
+		{"int8", float64(128), ""},
+		{"bool", true, "0x0000000000000000000000000000000000000000000000000000000000000001"},
+		{"bytes4", "0xdeadbeef", "0xdeadbeef00000000000000000000000000000000000000000000000000000000"},
+		{"bytes4", "0xdeadbeef00", ""},
+		{"bytes33", "0x00", ""},
+		{"uint7", float64(1), ""},
+	}
+	for _, test := range tests {
+		enc, err := encodePrimitive(test.typ, test.value)
+		switch {
+		case test.want == "" && err == nil:
+			t.Errorf("%s %v: expected error, got %x", test.typ, test.value, enc)
+		case test.want != "" && err != nil:
+			t.Errorf("%s %v: unexpected error: %v", test.typ, test.value, err)
+		case test.want != "" && hexutil.Encode(enc) != test.want:
+			t.Errorf("%s %v: got %x, want %s", test.typ, test.value, enc, test.want)
+		}
+	}
+}
+
+// Tests that integers in messages are decoded without losing precision.
+func TestTypedDataMessageNumbers(t *testing.T) {
+	var msg TypedDataMessage
+	if err := json.Unmarshal([]byte(`{"amount": 12345678901234567890123, "nested": {"value": 9007199254740993}}`), &msg); err != nil {
+		t.Fatalf("failed to decode message: %v", err)
+	}
+	if n, err := parseInteger(msg["amount"]); err != nil || n.String() != "12345678901234567890123" {
+		t.Errorf("amount mismatch: have %v (%v), want 12345678901234567890123", n, err)
+	}
+	nested := msg["nested"].(map[string]interface{})
+	if n, err := parseInteger(nested["value"]); err != nil || n.String() != "9007199254740993" {
+		t.Errorf("nested value mismatch: have %v (%v), want 9007199254740993", n, err)
+	}
+}
+
+func TestTypedDataFormat(t *testing.T) {
+	typedData := loadMail(t)
+	messages, err := typedData.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text string
+	for _, msg := range messages {
+		text += msg.Pprint(0)
+	}
+	for _, want := range []string{"EIP712Domain [domain]", "  chainId [uint256]: 1\n", "  from [Person]: \n    name [string]: Cow\n", "  contents [string]: Hello, Bob!\n"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("formatted message misses %q:\n%s", want, text)
+		}
+	}
+}
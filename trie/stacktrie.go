@@ -0,0 +1,172 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/ethdb"
+)
+
+var (
+	errStackOrder = errors.New("stack trie keys not in ascending order")
+	errStackEmpty = errors.New("stack trie can't store empty values")
+)
+
+// StackTrie is a trie built from keys inserted in ascending order. Any subtrie
+// left of the last inserted key can't change anymore, so it is hashed and queued
+// for writing right away, keeping only the path to the last key in memory.
+//
+// The nodes are written with Flush, and every node written has its whole subtrie
+// written too. StackTrie is not safe for concurrent use.
+type StackTrie struct {
+	trie   Trie          // Trie holding the path to the last key, with completed subtries as hashes
+	last   []byte        // Last inserted key, in hex encoding
+	stored []common.Hash // Completed subtries not yet written to disk
+}
+
+// NewStackTrie creates an empty stack trie writing its nodes into diskdb.
+func NewStackTrie(diskdb ethdb.Database) *StackTrie {
+	return &StackTrie{trie: Trie{db: NewDatabase(diskdb)}}
+}
+
+// TryUpdate inserts a key into the trie. Keys must be inserted in ascending
+// order, and values can't be empty.
+func (st *StackTrie) TryUpdate(key, value []byte) error {
+	if len(value) == 0 {
+		return errStackEmpty
+	}
+	k := keybytesToHex(key)
+	if st.last != nil && bytes.Compare(k, st.last) <= 0 {
+		return errStackOrder
+	}
+	_, root, err := st.trie.insert(st.trie.root, nil, k, valueNode(common.CopyBytes(value)))
+	if err != nil {
+		return err
+	}
+	st.trie.root, st.last = root, k
+
+	h := newHasher(0, 0, nil)
+	defer returnHasherToPool(h)
+
+	return st.hashLeft(h, root, k)
+}
+
+// hashLeft replaces the subtries left of the path of key by their hashes,
+// storing them in the trie database.
+func (st *StackTrie) hashLeft(h *hasher, n node, key []byte) error {
+	switch n := n.(type) {
+	case *shortNode:
+		if len(key) < len(n.Key) || !bytes.Equal(n.Key, key[:len(n.Key)]) {
+			return nil
+		}
+		return st.hashLeft(h, n.Val, key[len(n.Key):])
+
+	case *fullNode:
+		if len(key) == 0 {
+			return nil
+		}
+		// Replacing children by their hashes doesn't change the encoding of the
+		// node, so it can be modified in place without touching its cached hash
+		for i := 0; i < int(key[0]); i++ {
+			child := n.Children[i]
+			if _, ok := child.(hashNode); ok || child == nil {
+				continue
+			}
+			hashed, cached, err := h.hash(child, st.trie.db, false)
+			if err != nil {
+				return err
+			}
+			if hash, ok := hashed.(hashNode); ok {
+				n.Children[i] = hash
+				st.stored = append(st.stored, common.BytesToHash(hash))
+			} else {
+				n.Children[i] = cached // Small enough to be embedded in its parent
+			}
+		}
+		return st.hashLeft(h, n.Children[key[0]], key[1:])
+	}
+	return nil
+}
+
+// Hash returns the root hash of the keys inserted so far.
+func (st *StackTrie) Hash() common.Hash {
+	if st.trie.root == nil {
+		return emptyRoot
+	}
+	h := newHasher(0, 0, nil)
+	defer returnHasherToPool(h)
+
+	hashed, cached, _ := h.hash(st.trie.root, nil, true)
+	st.trie.root = cached
+	return common.BytesToHash(hashed.(hashNode))
+}
+
+// Commit hashes the whole trie and writes all its nodes to disk, returning the
+// root hash. The trie can't be updated anymore afterwards.
+func (st *StackTrie) Commit() (common.Hash, error) {
+	if st.trie.root == nil {
+		return emptyRoot, nil
+	}
+	h := newHasher(0, 0, nil)
+	hashed, _, err := h.hash(st.trie.root, st.trie.db, true)
+	returnHasherToPool(h)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	root := common.BytesToHash(hashed.(hashNode))
+	st.trie.root = hashed
+	st.stored = append(st.stored, root)
+
+	return root, st.Flush()
+}
+
+// Flush writes the completed subtries to disk and drops them from memory.
+func (st *StackTrie) Flush() error {
+	db := st.trie.db
+
+	// Only write the topmost subtries, the others get written along
+	db.lock.RLock()
+	batch := db.diskdb.NewBatch()
+	for _, hash := range st.stored {
+		if node, ok := db.nodes[hash]; !ok || node.parents > 0 {
+			continue
+		}
+		if err := db.commit(hash, batch); err != nil {
+			db.lock.RUnlock()
+			return err
+		}
+	}
+	if err := batch.Write(); err != nil {
+		db.lock.RUnlock()
+		return err
+	}
+	db.lock.RUnlock()
+
+	db.lock.Lock()
+	for _, hash := range st.stored {
+		if node, ok := db.nodes[hash]; ok && node.parents == 0 {
+			db.uncache(hash)
+		}
+	}
+	db.lock.Unlock()
+
+	st.stored = st.stored[:0]
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2018  The go-genchain Authors
+// This file is part of the go-genchain library.
+//
+// The go-genchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-genchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-genchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/genchain/go-genchain/common"
+	"github.com/genchain/go-genchain/crypto"
+	"github.com/genchain/go-genchain/ethdb"
+)
+
+// Tests that stack tries hash like regular tries, and write all their nodes out
+// gradually while keeping only the path to the last key in memory.
+func TestStackTrie(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 16, 100, 2000} {
+		// Generate sorted keys with small and large values
+		var keys [][]byte
+		values := make(map[string][]byte)
+		for i := 0; i < n; i++ {
+			key := crypto.Keccak256(common.BigToHash(big.NewInt(int64(i))).Bytes())
+			keys = append(keys, key)
+			values[string(key)] = bytes.Repeat([]byte{byte(i) + 1}, 1+rand.Intn(40))
+		}
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+
+		ref := newEmpty()
+		diskdb := ethdb.NewMemDatabase()
+		st := NewStackTrie(diskdb)
+		for i, key := range keys {
+			ref.Update(key, values[string(key)])
+			if err := st.TryUpdate(key, values[string(key)]); err != nil {
+				t.Fatalf("n %d: insert %d failed: %v", n, i, err)
+			}
+			if i%100 == 99 {
+				if hash := st.Hash(); hash != ref.Hash() {
+					t.Fatalf("n %d: hash mismatch after %d keys: have %x, want %x", n, i+1, hash, ref.Hash())
+				}
+				if err := st.Flush(); err != nil {
+					t.Fatalf("n %d: flush failed: %v", n, err)
+				}
+				if size := len(st.trie.db.nodes); size != 1 {
+					t.Fatalf("n %d: %d nodes left in memory after flush", n, size-1)
+				}
+			}
+		}
+		if n == 2000 && diskdb.Len() == 0 {
+			t.Errorf("n %d: no nodes written before commit", n)
+		}
+		root, err := st.Commit()
+		if err != nil {
+			t.Fatalf("n %d: commit failed: %v", n, err)
+		}
+		if root != ref.Hash() {
+			t.Fatalf("n %d: root mismatch: have %x, want %x", n, root, ref.Hash())
+		}
+		if n > 0 {
+			checkTrieContents(t, NewDatabase(diskdb), root[:], values)
+		}
+	}
+}
+
+// Tests that stack tries refuse keys out of order and empty values.
+func TestStackTrieErrors(t *testing.T) {
+	st := NewStackTrie(ethdb.NewMemDatabase())
+	if err := st.TryUpdate(common.Hex2Bytes("02"), []byte{1}); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if err := st.TryUpdate(common.Hex2Bytes("02"), []byte{1}); err != errStackOrder {
+		t.Errorf("duplicate key: have %v, want %v", err, errStackOrder)
+	}
+	if err := st.TryUpdate(common.Hex2Bytes("01"), []byte{1}); err != errStackOrder {
+		t.Errorf("lower key: have %v, want %v", err, errStackOrder)
+	}
+	if err := st.TryUpdate(common.Hex2Bytes("03"), nil); err != errStackEmpty {
+		t.Errorf("empty value: have %v, want %v", err, errStackEmpty)
+	}
+}